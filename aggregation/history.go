@@ -0,0 +1,112 @@
+package aggregation
+
+import (
+	"log"
+	"time"
+
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/network"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+// NetworkHistoryRecorder periodically stores the network state the api serves, the
+// snapshots back the network charts. The info of every state refresh is also stored by
+// layer, the last refresh in a layer replaces the earlier ones.
+type NetworkHistoryRecorder struct {
+	writeDB     database.WriteStore
+	state       *network.NetworkState
+	ticker      *time.Ticker
+	refreshTime int
+}
+
+// historyRefreshTime is the minutes between snapshots, 60 when not configured.
+func historyRefreshTime(configValues *config.Config) int {
+	if configValues.History.RefreshTime > 0 {
+		return configValues.History.RefreshTime
+	}
+	return 60
+}
+
+func NewNetworkHistoryRecorder(configValues *config.Config, writeDB database.WriteStore, state *network.NetworkState) *NetworkHistoryRecorder {
+	refreshTime := historyRefreshTime(configValues)
+	recorder := &NetworkHistoryRecorder{
+		writeDB: writeDB,
+		state:   state,
+	}
+	recorder.periodicRecord(refreshTime)
+	state.OnRefresh(recorder.recordLayer)
+	return recorder
+}
+
+func (n *NetworkHistoryRecorder) periodicRecord(refreshTime int) {
+	n.refreshTime = refreshTime
+	n.ticker = time.NewTicker(time.Duration(refreshTime) * time.Minute)
+	go func() {
+		for range n.ticker.C {
+			if n.writeDB.Fenced() {
+				continue
+			}
+			n.record()
+		}
+	}()
+}
+
+// Reload applies a changed refresh time, the next snapshot waits the new time.
+func (n *NetworkHistoryRecorder) Reload(configValues *config.Config) {
+	refreshTime := historyRefreshTime(configValues)
+	if refreshTime != n.refreshTime {
+		n.refreshTime = refreshTime
+		n.ticker.Reset(time.Duration(refreshTime) * time.Minute)
+	}
+}
+
+func (n *NetworkHistoryRecorder) record() {
+	info := n.state.GetInfo()
+	// the state is empty until the first fetch succeeds
+	if info.Layer == 0 {
+		return
+	}
+	err := n.writeDB.SaveNetworkSnapshot(&types.NetworkSnapshotDoc{
+		Timestamp:         time.Now(),
+		Epoch:             info.Epoch,
+		Layer:             info.Layer,
+		TotalWeight:       info.TotalWeight,
+		ActiveSmeshers:    info.TotalActiveSmeshers,
+		TotalAccounts:     info.TotalAccounts,
+		CirculatingSupply: info.CirculatingSupply,
+		Price:             info.Price,
+	})
+	if err != nil {
+		log.Printf("Failed to save network snapshot: %s", err.Error())
+		return
+	}
+	log.Println("Network snapshot recorded")
+}
+
+func (n *NetworkHistoryRecorder) recordLayer(snapshot *network.Snapshot) {
+	if n.writeDB.Fenced() {
+		return
+	}
+	info := snapshot.Info
+	history := &types.NetworkInfoHistoryDoc{
+		Layer:                  info.Layer,
+		Epoch:                  info.Epoch,
+		CreatedAt:              snapshot.CreatedAt,
+		TotalWeight:            info.TotalWeight,
+		TotalSlots:             info.TotalSlots,
+		EffectiveUnitsCommited: info.EffectiveUnitsCommited,
+		ActiveSmeshers:         info.TotalActiveSmeshers,
+		TotalAccounts:          info.TotalAccounts,
+		CirculatingSupply:      info.CirculatingSupply,
+		Price:                  info.Price,
+	}
+	if next := info.NextEpoch; next != nil {
+		history.NextEpochWeight = next.TotalWeight
+		history.NextEpochActiveSmeshers = uint64(next.TotalActiveSmeshers)
+		history.NextEpochEffectiveUnits = uint64(next.EffectiveUnitsCommited)
+	}
+	if err := n.writeDB.SaveNetworkInfoHistory(history); err != nil {
+		log.Printf("Failed to save network info of layer %d: %s", info.Layer, err.Error())
+	}
+}
@@ -0,0 +1,132 @@
+package aggregation
+
+import (
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/metrics"
+	"github.com/swarmbit/spacemesh-state-api/node"
+)
+
+// node state queries are split in batches of this many transactions
+const pendingStateBatch = 100
+
+// PendingExpirer periodically deletes the created transactions that got no result
+// within the duration of the expire layers since they were created, the node dropped
+// them or they will never be applied. When the node client runs, transactions the node
+// still holds are kept.
+type PendingExpirer struct {
+	writeDB      database.WriteStore
+	readDB       database.ReadStore
+	nodeClient   *node.NodeClient
+	expireLayers atomic.Uint32
+	ticker       *time.Ticker
+	refreshTime  int
+}
+
+// pendingRefreshTime is the minutes between expiries, 5 when not configured.
+func pendingRefreshTime(configValues *config.Config) int {
+	if configValues.Pending.RefreshTime > 0 {
+		return configValues.Pending.RefreshTime
+	}
+	return 5
+}
+
+// pendingExpireLayers is the layers a transaction stays pending, 20 when not configured.
+func pendingExpireLayers(configValues *config.Config) uint32 {
+	if configValues.Pending.ExpireLayers > 0 {
+		return uint32(configValues.Pending.ExpireLayers)
+	}
+	return 20
+}
+
+func NewPendingExpirer(configValues *config.Config, writeDB database.WriteStore, readDB database.ReadStore, nodeClient *node.NodeClient) *PendingExpirer {
+	expirer := &PendingExpirer{
+		writeDB:    writeDB,
+		readDB:     readDB,
+		nodeClient: nodeClient,
+	}
+	expirer.expireLayers.Store(pendingExpireLayers(configValues))
+	go expirer.expire()
+	expirer.periodicExpire(pendingRefreshTime(configValues))
+	return expirer
+}
+
+func (p *PendingExpirer) periodicExpire(refreshTime int) {
+	p.refreshTime = refreshTime
+	p.ticker = time.NewTicker(time.Duration(refreshTime) * time.Minute)
+	go func() {
+		for range p.ticker.C {
+			if p.writeDB.Fenced() {
+				continue
+			}
+			p.expire()
+		}
+	}()
+}
+
+// Reload applies a changed refresh time, the expire layers are used from the next run.
+func (p *PendingExpirer) Reload(configValues *config.Config) {
+	refreshTime := pendingRefreshTime(configValues)
+	if refreshTime != p.refreshTime {
+		p.refreshTime = refreshTime
+		p.ticker.Reset(time.Duration(refreshTime) * time.Minute)
+	}
+	p.expireLayers.Store(pendingExpireLayers(configValues))
+}
+
+// expire deletes the pending transactions created more than the expire layers ago. The
+// layer of a created transaction is 0 until its result, so their age is from the time
+// they were saved.
+func (p *PendingExpirer) expire() {
+	ttl := time.Duration(p.expireLayers.Load()) * time.Duration(config.LayerDuration) * time.Second
+	createdBefore := time.Now().Add(-ttl)
+
+	keep, ok := p.inMempool(createdBefore.Unix())
+	if !ok {
+		return
+	}
+	count, err := p.writeDB.ExpirePendingTransactions(createdBefore.Unix(), keep)
+	if err != nil {
+		log.Printf("Failed to expire pending transactions created before %s: %s", createdBefore.UTC().Format(time.RFC3339), err.Error())
+		return
+	}
+	if count > 0 {
+		metrics.PendingExpired.Add(float64(count))
+		log.Printf("Expired %d pending transactions created before %s", count, createdBefore.UTC().Format(time.RFC3339))
+	}
+}
+
+// inMempool returns the stale pending transactions the node still holds, it reports
+// false when the node can not be asked so nothing is expired on a guess.
+func (p *PendingExpirer) inMempool(createdBefore int64) ([]string, bool) {
+	if p.nodeClient == nil {
+		return nil, true
+	}
+	ids, err := p.readDB.GetPendingTransactionIds(createdBefore)
+	if err != nil {
+		log.Printf("Failed to get pending transactions: %s", err.Error())
+		return nil, false
+	}
+	keep := make([]string, 0)
+	for start := 0; start < len(ids); start += pendingStateBatch {
+		end := start + pendingStateBatch
+		if end > len(ids) {
+			end = len(ids)
+		}
+		states, err := p.nodeClient.TransactionsState(ids[start:end])
+		if err != nil {
+			log.Printf("Failed to get pending transactions state from the node: %s", err.Error())
+			return nil, false
+		}
+		for id, state := range states {
+			if state == node.TransactionStateMempool || state == node.TransactionStateMesh {
+				keep = append(keep, id)
+			}
+		}
+	}
+	return keep, true
+}
@@ -0,0 +1,151 @@
+package aggregation
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/metrics"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+// poetInfo holds the round fields of the poet info response, older poets report the
+// open round instead of the current one. Rounds are numbers or strings depending on the
+// poet version.
+type poetInfo struct {
+	CurrentRound json.RawMessage `json:"currentRound"`
+	OpenRoundId  json.RawMessage `json:"openRoundId"`
+}
+
+// PoetHealthChecker periodically probes the info endpoint of the configured poets and
+// stores whether each one is up, the poets api serves the result.
+type PoetHealthChecker struct {
+	writeDB     database.WriteStore
+	reloader    *config.Reloader
+	client      *http.Client
+	health      map[string]*types.PoetHealthDoc
+	ticker      *time.Ticker
+	refreshTime int
+}
+
+// poetHealthRefreshTime is the minutes between probes, 5 when not configured.
+func poetHealthRefreshTime(configValues *config.Config) int {
+	if configValues.PoetHealth.RefreshTime > 0 {
+		return configValues.PoetHealth.RefreshTime
+	}
+	return 5
+}
+
+func NewPoetHealthChecker(reloader *config.Reloader, writeDB database.WriteStore, readDB database.ReadStore) *PoetHealthChecker {
+	configValues := reloader.Current()
+	refreshTime := poetHealthRefreshTime(configValues)
+	timeout := 10
+	if configValues.PoetHealth.Timeout > 0 {
+		timeout = configValues.PoetHealth.Timeout
+	}
+	checker := &PoetHealthChecker{
+		writeDB:  writeDB,
+		reloader: reloader,
+		client:   &http.Client{Timeout: time.Duration(timeout) * time.Second},
+		health:   make(map[string]*types.PoetHealthDoc),
+	}
+	// keep since across restarts
+	stored, err := readDB.GetPoetsHealth()
+	if err != nil {
+		log.Printf("Failed to get poets health: %s", err.Error())
+	}
+	for _, health := range stored {
+		checker.health[health.Name] = health
+	}
+	go checker.check()
+	checker.periodicCheck(refreshTime)
+	return checker
+}
+
+func (p *PoetHealthChecker) periodicCheck(refreshTime int) {
+	p.refreshTime = refreshTime
+	p.ticker = time.NewTicker(time.Duration(refreshTime) * time.Minute)
+	go func() {
+		for range p.ticker.C {
+			if p.writeDB.Fenced() {
+				continue
+			}
+			p.check()
+		}
+	}()
+}
+
+// Reload applies a changed refresh time, the poets are read from the reloader on every
+// check.
+func (p *PoetHealthChecker) Reload(configValues *config.Config) {
+	refreshTime := poetHealthRefreshTime(configValues)
+	if refreshTime != p.refreshTime {
+		p.refreshTime = refreshTime
+		p.ticker.Reset(time.Duration(refreshTime) * time.Minute)
+	}
+}
+
+func (p *PoetHealthChecker) check() {
+	for _, poet := range p.reloader.Current().Poets {
+		if poet.Address == "" {
+			continue
+		}
+		now := time.Now()
+		health := &types.PoetHealthDoc{
+			Name:      poet.Name,
+			Address:   poet.Address,
+			Up:        true,
+			Since:     now,
+			CheckedAt: now,
+		}
+		round, err := p.probe(poet.Address)
+		if err != nil {
+			health.Up = false
+			health.Error = err.Error()
+			log.Printf("Poet %s is down: %s", poet.Name, err.Error())
+		}
+		health.CurrentRound = round
+
+		previous, exists := p.health[poet.Name]
+		if exists && previous.Up == health.Up {
+			health.Since = previous.Since
+		}
+		p.health[poet.Name] = health
+
+		if health.Up {
+			metrics.PoetUp.WithLabelValues(poet.Name).Set(1)
+		} else {
+			metrics.PoetUp.WithLabelValues(poet.Name).Set(0)
+		}
+		err = p.writeDB.SavePoetHealth(health)
+		if err != nil {
+			log.Printf("Failed to save health of poet %s: %s", poet.Name, err.Error())
+		}
+	}
+}
+
+func (p *PoetHealthChecker) probe(address string) (string, error) {
+	response, err := p.client.Get(strings.TrimRight(address, "/") + "/v1/info")
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		return "", fmt.Errorf("info returned %d: %s", response.StatusCode, strings.TrimSpace(string(message)))
+	}
+	info := &poetInfo{}
+	if err = json.NewDecoder(response.Body).Decode(info); err != nil {
+		return "", fmt.Errorf("invalid info response: %w", err)
+	}
+	if len(info.CurrentRound) > 0 {
+		return strings.Trim(string(info.CurrentRound), `"`), nil
+	}
+	return strings.Trim(string(info.OpenRoundId), `"`), nil
+}
@@ -0,0 +1,143 @@
+package aggregation
+
+import (
+	"log"
+	"time"
+
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/metrics"
+	"github.com/swarmbit/spacemesh-state-api/network"
+)
+
+// RetentionPruner periodically deletes per layer data older than the kept epochs of
+// each policy. Rewards are rolled up into the smeshers epoch aggregates and the rewards
+// rollups before they are deleted, whole epochs are pruned so the aggregates are never
+// recomputed from partial data.
+type RetentionPruner struct {
+	writeDB      database.WriteStore
+	readDB       database.ReadStore
+	networkUtils *network.NetworkUtils
+	policies     []*config.RetentionPolicy
+	dryRun       bool
+	// first epoch whose rewards are not rolled up yet
+	fromEpoch   uint32
+	ticker      *time.Ticker
+	refreshTime int
+}
+
+// retentionRefreshTime is the minutes between prunes, 60 when not configured.
+func retentionRefreshTime(configValues *config.Config) int {
+	if configValues.Retention.RefreshTime > 0 {
+		return configValues.Retention.RefreshTime
+	}
+	return 60
+}
+
+func NewRetentionPruner(configValues *config.Config, writeDB database.WriteStore, readDB database.ReadStore) *RetentionPruner {
+	refreshTime := retentionRefreshTime(configValues)
+	policies := make([]*config.RetentionPolicy, 0, len(configValues.Retention.Policies))
+	for _, policy := range configValues.Retention.Policies {
+		if !database.Prunable(policy.Collection) || policy.KeepEpochs <= 0 {
+			log.Printf("Ignoring retention policy for %s, only rewards, layers, transactions and blocks with keepEpochs above 0 can be pruned", policy.Collection)
+			continue
+		}
+		policies = append(policies, policy)
+	}
+	pruner := &RetentionPruner{
+		writeDB:      writeDB,
+		readDB:       readDB,
+		networkUtils: network.NewNetworkUtils(),
+		policies:     policies,
+		dryRun:       configValues.Retention.DryRun,
+	}
+	go pruner.prune()
+	pruner.periodicPrune(refreshTime)
+	return pruner
+}
+
+func (r *RetentionPruner) periodicPrune(refreshTime int) {
+	r.refreshTime = refreshTime
+	r.ticker = time.NewTicker(time.Duration(refreshTime) * time.Minute)
+	go func() {
+		for range r.ticker.C {
+			if r.writeDB.Fenced() {
+				continue
+			}
+			r.prune()
+		}
+	}()
+}
+
+// Reload applies a changed refresh time, the policies are only read at start.
+func (r *RetentionPruner) Reload(configValues *config.Config) {
+	refreshTime := retentionRefreshTime(configValues)
+	if refreshTime != r.refreshTime {
+		r.refreshTime = refreshTime
+		r.ticker.Reset(time.Duration(refreshTime) * time.Minute)
+	}
+}
+
+func (r *RetentionPruner) prune() {
+	if len(r.policies) == 0 {
+		return
+	}
+	layer, err := r.readDB.GetLastProcessedLayer()
+	if err != nil {
+		log.Printf("Failed to get last processed layer: %s", err.Error())
+		return
+	}
+	epoch := r.networkUtils.GetEpoch(uint64(layer.Layer)).Uint32()
+
+	for _, policy := range r.policies {
+		if epoch <= uint32(policy.KeepEpochs) {
+			continue
+		}
+		keepFrom := epoch - uint32(policy.KeepEpochs)
+
+		if policy.Collection == "rewards" && !r.rollUp(keepFrom) {
+			continue
+		}
+
+		count, err := r.writeDB.PruneCollection(policy.Collection, keepFrom*config.LayersPerEpoch, r.dryRun)
+		if err != nil {
+			log.Printf("Failed to prune %s before epoch %d: %s", policy.Collection, keepFrom, err.Error())
+			continue
+		}
+		if r.dryRun {
+			log.Printf("Retention dry run, would prune %d %s before epoch %d", count, policy.Collection, keepFrom)
+			continue
+		}
+		metrics.RetentionPruned.WithLabelValues(policy.Collection).Add(float64(count))
+		log.Printf("Pruned %d %s before epoch %d", count, policy.Collection, keepFrom)
+	}
+}
+
+// rollUp aggregates the rewards of the epochs about to be pruned, it reports if the
+// rewards can be deleted.
+func (r *RetentionPruner) rollUp(keepFrom uint32) bool {
+	if r.fromEpoch >= keepFrom {
+		return true
+	}
+	if r.dryRun {
+		log.Printf("Retention dry run, would roll up rewards from epoch %d", r.fromEpoch)
+		return true
+	}
+	err := r.writeDB.AggregateSmeshersEpochRewards(r.fromEpoch)
+	if err != nil {
+		log.Printf("Failed to roll up rewards before pruning: %s", err.Error())
+		return false
+	}
+	err = r.writeDB.AggregateSmeshersTotals(r.fromEpoch)
+	if err != nil {
+		log.Printf("Failed to roll up smeshers totals before pruning: %s", err.Error())
+		return false
+	}
+	err = r.writeDB.AggregateRewardsRollups(r.fromEpoch)
+	if err != nil {
+		log.Printf("Failed to roll up rewards charts before pruning: %s", err.Error())
+		return false
+	}
+	r.fromEpoch = keepFrom
+	return true
+}
@@ -0,0 +1,164 @@
+package aggregation
+
+import (
+	"log"
+	"time"
+
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/events"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+const digestDaySeconds = 24 * 60 * 60
+
+// RewardDigestAggregator periodically sums the rewards of every coinbase per UTC day and
+// values them at the last price recorded in the day. Days are recomputed from the first
+// one that was not complete, the digests of a day are published on the bus once all its
+// layers were processed.
+type RewardDigestAggregator struct {
+	writeDB database.WriteStore
+	readDB  database.ReadStore
+	bus     *events.Bus
+	// first day, the unix time of its start, that still needs to be recomputed
+	fromDay     int64
+	ticker      *time.Ticker
+	refreshTime int
+}
+
+// digestsRefreshTime is the minutes between runs, 15 when not configured.
+func digestsRefreshTime(configValues *config.Config) int {
+	if configValues.Digests != nil && configValues.Digests.RefreshTime > 0 {
+		return configValues.Digests.RefreshTime
+	}
+	return 15
+}
+
+func NewRewardDigestAggregator(configValues *config.Config, writeDB database.WriteStore, readDB database.ReadStore, bus *events.Bus) *RewardDigestAggregator {
+	aggregator := &RewardDigestAggregator{
+		writeDB: writeDB,
+		readDB:  readDB,
+		bus:     bus,
+		fromDay: config.GenesisEpochSeconds / digestDaySeconds * digestDaySeconds,
+	}
+	// the last day digested is recomputed unless it was complete
+	last, err := readDB.GetLastRewardDigest()
+	if err != nil {
+		log.Printf("Failed to get last reward digest: %s", err.Error())
+	} else if last.Id.Coinbase != "" {
+		aggregator.fromDay = last.Id.Day
+		if last.Complete {
+			aggregator.fromDay += digestDaySeconds
+		}
+	}
+	aggregator.refreshTime = digestsRefreshTime(configValues)
+	aggregator.ticker = time.NewTicker(time.Duration(aggregator.refreshTime) * time.Minute)
+	go func() {
+		aggregator.aggregate()
+		for range aggregator.ticker.C {
+			if aggregator.writeDB.Fenced() {
+				continue
+			}
+			aggregator.aggregate()
+		}
+	}()
+	return aggregator
+}
+
+// Reload applies a changed refresh time, the next run waits the new time.
+func (r *RewardDigestAggregator) Reload(configValues *config.Config) {
+	refreshTime := digestsRefreshTime(configValues)
+	if refreshTime != r.refreshTime {
+		r.refreshTime = refreshTime
+		r.ticker.Reset(time.Duration(refreshTime) * time.Minute)
+	}
+}
+
+func (r *RewardDigestAggregator) aggregate() {
+	layer, err := r.readDB.GetLastProcessedLayer()
+	if err != nil {
+		log.Printf("Failed to get last processed layer: %s", err.Error())
+		return
+	}
+	if layer.Layer == 0 {
+		return
+	}
+	// the last processed layer may still be receiving its rewards
+	processedUntil := config.GenesisEpochSeconds + layer.Layer*config.LayerDuration
+
+	days := 0
+	for day := r.fromDay; day < processedUntil; day += digestDaySeconds {
+		complete := day+digestDaySeconds <= processedUntil
+		digests, err := r.digest(day, complete)
+		if err != nil {
+			log.Printf("Failed to aggregate reward digests of %s: %s", time.Unix(day, 0).UTC().Format(time.DateOnly), err.Error())
+			return
+		}
+		if err := r.writeDB.SaveRewardDigests(digests); err != nil {
+			log.Printf("Failed to save reward digests of %s: %s", time.Unix(day, 0).UTC().Format(time.DateOnly), err.Error())
+			return
+		}
+		days++
+		if !complete {
+			break
+		}
+		lastLayer := DayLastLayer(day)
+		for _, v := range digests {
+			r.bus.Publish(&events.Event{Kind: events.RewardDigest, Layer: lastLayer, Payload: v})
+		}
+		r.fromDay = day + digestDaySeconds
+	}
+	log.Printf("Reward digests of %d days aggregated", days)
+}
+
+// digest sums the rewards of every coinbase in the day starting at day.
+func (r *RewardDigestAggregator) digest(day int64, complete bool) ([]*types.RewardDigestDoc, error) {
+	firstLayer := DayFirstLayer(day)
+	lastLayer := DayLastLayer(day)
+	rewards, err := r.readDB.GetCoinbasesRewards(firstLayer, lastLayer)
+	if err != nil {
+		return nil, err
+	}
+	prices, err := r.readDB.GetPrices(time.Unix(day, 0), time.Unix(day+digestDaySeconds-1, 0))
+	if err != nil {
+		return nil, err
+	}
+	usdPrice := -1.0
+	if len(prices) > 0 {
+		usdPrice = prices[len(prices)-1].USDPrice
+	}
+
+	digests := make([]*types.RewardDigestDoc, len(rewards))
+	for i, v := range rewards {
+		digests[i] = &types.RewardDigestDoc{
+			Id:       types.RewardDigestId{Coinbase: v.Coinbase, Day: day},
+			Count:    v.Count,
+			Rewards:  v.TotalReward,
+			USDPrice: usdPrice,
+			USDValue: -1,
+			Complete: complete,
+		}
+		if usdPrice >= 0 {
+			digests[i].USDValue = int64(usdPrice * float64(v.TotalReward))
+		}
+	}
+	return digests, nil
+}
+
+// DayFirstLayer is the first layer starting in the day starting at the unix time day,
+// layers belong to the day they start in like in the daily rollups.
+func DayFirstLayer(day int64) uint32 {
+	return uint32(max(ceilDiv(day-config.GenesisEpochSeconds, config.LayerDuration), 0))
+}
+
+// DayLastLayer is the last layer starting in the day starting at the unix time day.
+func DayLastLayer(day int64) uint32 {
+	return uint32(max(ceilDiv(day+digestDaySeconds-config.GenesisEpochSeconds, config.LayerDuration)-1, 0))
+}
+
+func ceilDiv(a int64, b int64) int64 {
+	if a <= 0 {
+		return a / b
+	}
+	return (a + b - 1) / b
+}
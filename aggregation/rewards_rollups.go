@@ -0,0 +1,80 @@
+package aggregation
+
+import (
+	"log"
+	"time"
+
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/network"
+)
+
+// RewardsRollupAggregator periodically sums rewards per day and per epoch, for every
+// account and network wide, so reward charts are served from the rollup collections.
+type RewardsRollupAggregator struct {
+	writeDB      database.WriteStore
+	readDB       database.ReadStore
+	networkUtils *network.NetworkUtils
+	// first epoch that still needs to be recomputed on the next run
+	fromEpoch   uint32
+	ticker      *time.Ticker
+	refreshTime int
+}
+
+func NewRewardsRollupAggregator(configValues *config.Config, writeDB database.WriteStore, readDB database.ReadStore) *RewardsRollupAggregator {
+	refreshTime := aggregationRefreshTime(configValues)
+	aggregator := &RewardsRollupAggregator{
+		writeDB:      writeDB,
+		readDB:       readDB,
+		networkUtils: network.NewNetworkUtils(),
+	}
+	// start from the last stored epoch, rewards before it may already be pruned
+	fromEpoch, err := readDB.GetLastRewardsRollupEpoch()
+	if err != nil {
+		log.Printf("Failed to get last rewards rollup epoch: %s", err.Error())
+	}
+	aggregator.fromEpoch = fromEpoch
+	go aggregator.aggregate()
+	aggregator.periodicAggregate(refreshTime)
+	return aggregator
+}
+
+func (r *RewardsRollupAggregator) periodicAggregate(refreshTime int) {
+	r.refreshTime = refreshTime
+	r.ticker = time.NewTicker(time.Duration(refreshTime) * time.Minute)
+	go func() {
+		for range r.ticker.C {
+			if r.writeDB.Fenced() {
+				continue
+			}
+			r.aggregate()
+		}
+	}()
+}
+
+// Reload applies a changed refresh time, the next aggregation waits the new time.
+func (r *RewardsRollupAggregator) Reload(configValues *config.Config) {
+	refreshTime := aggregationRefreshTime(configValues)
+	if refreshTime != r.refreshTime {
+		r.refreshTime = refreshTime
+		r.ticker.Reset(time.Duration(refreshTime) * time.Minute)
+	}
+}
+
+func (r *RewardsRollupAggregator) aggregate() {
+	layer, err := r.readDB.GetLastProcessedLayer()
+	if err != nil {
+		log.Printf("Failed to get last processed layer: %s", err.Error())
+		return
+	}
+	epoch := r.networkUtils.GetEpoch(uint64(layer.Layer)).Uint32()
+
+	err = r.writeDB.AggregateRewardsRollups(r.fromEpoch)
+	if err != nil {
+		log.Printf("Failed to aggregate rewards rollups: %s", err.Error())
+		return
+	}
+
+	r.fromEpoch = epoch
+	log.Println("Rewards rollups aggregated")
+}
@@ -0,0 +1,174 @@
+package aggregation
+
+import (
+	"log"
+	"time"
+
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/network"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+const performanceBatchSize = 1000
+
+// SmeshersAggregator periodically folds rewards and atxs into the smeshers
+// collections so top smeshers can be served without scanning raw data.
+type SmeshersAggregator struct {
+	writeDB      database.WriteStore
+	readDB       database.ReadStore
+	networkUtils *network.NetworkUtils
+	// first epoch that still needs to be recomputed on the next run
+	fromEpoch uint32
+	// first epoch whose performance still needs to be recomputed, read from the store on
+	// the first run
+	performanceFrom uint32
+	ticker          *time.Ticker
+	refreshTime     int
+}
+
+// aggregationRefreshTime is the minutes between aggregations, 10 when not configured.
+func aggregationRefreshTime(configValues *config.Config) int {
+	if configValues.Aggregation != nil && configValues.Aggregation.RefreshTime > 0 {
+		return configValues.Aggregation.RefreshTime
+	}
+	return 10
+}
+
+func NewSmeshersAggregator(configValues *config.Config, writeDB database.WriteStore, readDB database.ReadStore) *SmeshersAggregator {
+	refreshTime := aggregationRefreshTime(configValues)
+	aggregator := &SmeshersAggregator{
+		writeDB:      writeDB,
+		readDB:       readDB,
+		networkUtils: network.NewNetworkUtils(),
+	}
+	go aggregator.aggregate()
+	aggregator.periodicAggregate(refreshTime)
+	return aggregator
+}
+
+func (s *SmeshersAggregator) periodicAggregate(refreshTime int) {
+	s.refreshTime = refreshTime
+	s.ticker = time.NewTicker(time.Duration(refreshTime) * time.Minute)
+	go func() {
+		for range s.ticker.C {
+			if s.writeDB.Fenced() {
+				continue
+			}
+			s.aggregate()
+		}
+	}()
+}
+
+// Reload applies a changed refresh time, the next aggregation waits the new time.
+func (s *SmeshersAggregator) Reload(configValues *config.Config) {
+	refreshTime := aggregationRefreshTime(configValues)
+	if refreshTime != s.refreshTime {
+		s.refreshTime = refreshTime
+		s.ticker.Reset(time.Duration(refreshTime) * time.Minute)
+	}
+}
+
+func (s *SmeshersAggregator) aggregate() {
+	log.Println("Start smeshers aggregation")
+
+	layer, err := s.readDB.GetLastProcessedLayer()
+	if err != nil {
+		log.Printf("Failed to get last processed layer: %s", err.Error())
+		return
+	}
+	epoch := s.networkUtils.GetEpoch(uint64(layer.Layer)).Uint32()
+
+	err = s.writeDB.AggregateSmeshersEpochRewards(s.fromEpoch)
+	if err != nil {
+		log.Printf("Failed to aggregate smeshers epoch rewards: %s", err.Error())
+		return
+	}
+
+	err = s.writeDB.AggregateSmeshersTotals(s.fromEpoch)
+	if err != nil {
+		log.Printf("Failed to aggregate smeshers totals: %s", err.Error())
+		return
+	}
+
+	if s.performanceFrom == 0 {
+		last, err := s.readDB.GetLastSmeshersPerformanceEpoch()
+		if err != nil {
+			log.Printf("Failed to get last smeshers performance epoch: %s", err.Error())
+			return
+		}
+		// epochs before 2 have no atxs targeting them
+		s.performanceFrom = max(last, 2)
+	}
+	for performanceEpoch := s.performanceFrom; performanceEpoch <= epoch; performanceEpoch++ {
+		err = s.aggregatePerformance(performanceEpoch, uint32(layer.Layer))
+		if err != nil {
+			log.Printf("Failed to aggregate smeshers performance of epoch %d: %s", performanceEpoch, err.Error())
+			return
+		}
+	}
+
+	// rewards of past epochs are final, only the current one keeps changing
+	s.fromEpoch = epoch
+	s.performanceFrom = max(epoch, 2)
+	log.Println("Smeshers aggregated")
+}
+
+// aggregatePerformance stores the slots and rewards of every smesher with an atx
+// targeting epoch, the rewards are the ones aggregated in the smeshers epochs.
+func (s *SmeshersAggregator) aggregatePerformance(epoch uint32, lastLayer uint32) error {
+	epochAtx, err := s.readDB.GetAtxEpoch(uint64(epoch - 1))
+	if err != nil {
+		return err
+	}
+	if epochAtx.TotalWeight == 0 {
+		return nil
+	}
+	smeshers, err := s.readDB.GetTopSmeshersEpoch(epoch, 0, 0)
+	if err != nil {
+		return err
+	}
+	rewards := make(map[string]*types.SmesherEpochDoc, len(smeshers))
+	for _, smesher := range smeshers {
+		rewards[smesher.Id.NodeId] = smesher
+	}
+
+	complete := lastLayer/config.LayersPerEpoch > epoch
+	processed := 1.0
+	if !complete {
+		firstLayer := epoch * config.LayersPerEpoch
+		processed = float64(lastLayer-firstLayer+1) / float64(config.LayersPerEpoch)
+	}
+
+	batch := make([]*types.SmesherPerformanceDoc, 0, performanceBatchSize)
+	err = s.readDB.StreamAtxForEpoch(uint64(epoch-1), 1, func(atx *types.AtxDoc) error {
+		slots, err := s.networkUtils.GetNumberOfSlots(atx.Weight, epochAtx.TotalWeight, epoch)
+		if err != nil {
+			return err
+		}
+		doc := &types.SmesherPerformanceDoc{
+			Id:              types.SmesherEpochId{NodeId: atx.NodeID, Epoch: epoch},
+			Coinbase:        atx.Coinbase,
+			Weight:          atx.Weight,
+			Slots:           slots,
+			ExpectedRewards: int64(float64(slots) * processed),
+			Complete:        complete,
+		}
+		if reward, exists := rewards[atx.NodeID]; exists {
+			doc.Rewards = reward.Rewards
+			doc.RewardsCount = reward.RewardsCount
+		}
+		batch = append(batch, doc)
+		if len(batch) == performanceBatchSize {
+			if err := s.writeDB.SaveSmeshersPerformance(batch); err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return s.writeDB.SaveSmeshersPerformance(batch)
+}
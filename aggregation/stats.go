@@ -0,0 +1,118 @@
+package aggregation
+
+import (
+	"log"
+	"time"
+
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/metrics"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+var ingestedEntities = []string{
+	"reward",
+	"layer",
+	"atx",
+	"transaction_created",
+	"transaction_result",
+	"malfeasance",
+}
+
+// StatsRecorder periodically stores collection sizes and ingest and api rates
+// so capacity trends are available without an external prometheus.
+type StatsRecorder struct {
+	writeDB database.WriteStore
+	readDB  database.ReadStore
+	// counter values at the previous snapshot, rates are computed against them
+	lastTime     time.Time
+	lastIngested map[string]float64
+	lastRequests float64
+	ticker       *time.Ticker
+	refreshTime  int
+}
+
+// statsRefreshTime is the minutes between snapshots, 15 when not configured.
+func statsRefreshTime(configValues *config.Config) int {
+	if configValues.Stats.RefreshTime > 0 {
+		return configValues.Stats.RefreshTime
+	}
+	return 15
+}
+
+func NewStatsRecorder(configValues *config.Config, writeDB database.WriteStore, readDB database.ReadStore) *StatsRecorder {
+	refreshTime := statsRefreshTime(configValues)
+	retentionDays := 90
+	if configValues.Stats.RetentionDays > 0 {
+		retentionDays = configValues.Stats.RetentionDays
+	}
+	err := writeDB.EnableStatsRetention(time.Duration(retentionDays) * 24 * time.Hour)
+	if err != nil {
+		log.Printf("Failed to create stats retention index: %s", err.Error())
+	}
+	recorder := &StatsRecorder{
+		writeDB:      writeDB,
+		readDB:       readDB,
+		lastTime:     time.Now(),
+		lastIngested: make(map[string]float64),
+	}
+	recorder.periodicRecord(refreshTime)
+	return recorder
+}
+
+func (s *StatsRecorder) periodicRecord(refreshTime int) {
+	s.refreshTime = refreshTime
+	s.ticker = time.NewTicker(time.Duration(refreshTime) * time.Minute)
+	go func() {
+		for range s.ticker.C {
+			if s.writeDB.Fenced() {
+				continue
+			}
+			s.record()
+		}
+	}()
+}
+
+// Reload applies a changed refresh time, rates are computed over the time actually
+// elapsed so the first snapshot after it stays correct.
+func (s *StatsRecorder) Reload(configValues *config.Config) {
+	refreshTime := statsRefreshTime(configValues)
+	if refreshTime != s.refreshTime {
+		s.refreshTime = refreshTime
+		s.ticker.Reset(time.Duration(refreshTime) * time.Minute)
+	}
+}
+
+func (s *StatsRecorder) record() {
+	sizes, err := s.readDB.GetCollectionSizes()
+	if err != nil {
+		log.Printf("Failed to get collection sizes: %s", err.Error())
+		return
+	}
+
+	now := time.Now()
+	elapsed := now.Sub(s.lastTime).Seconds()
+
+	ingestRates := make(map[string]float64, len(ingestedEntities))
+	for _, entity := range ingestedEntities {
+		value := metrics.CounterValue(metrics.IngestedEvents.WithLabelValues(entity))
+		ingestRates[entity] = (value - s.lastIngested[entity]) / elapsed
+		s.lastIngested[entity] = value
+	}
+	requests := metrics.CounterValue(metrics.ApiRequests)
+	apiQps := (requests - s.lastRequests) / elapsed
+	s.lastRequests = requests
+	s.lastTime = now
+
+	err = s.writeDB.SaveStats(&types.StatsDoc{
+		Timestamp:   now,
+		Collections: sizes,
+		IngestRates: ingestRates,
+		ApiQps:      apiQps,
+	})
+	if err != nil {
+		log.Printf("Failed to save stats: %s", err.Error())
+		return
+	}
+	log.Println("Stats recorded")
+}
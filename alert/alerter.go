@@ -0,0 +1,270 @@
+package alert
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/network"
+)
+
+// alert is a condition that holds, epoch is the epoch it holds for so watched nodes and
+// coinbases are alerted again when they miss the next epoch too.
+type alert struct {
+	key     string
+	epoch   uint32
+	message string
+}
+
+// Alerter checks the watched nodes and coinbases and the ingestion lag and sends an
+// alert when a check fails, and once more when the alert resolves. Checks that can not
+// tell yet, before the atx deadline or while the epoch was not processed, keep the
+// alerts as they are.
+type Alerter struct {
+	reloader     *config.Reloader
+	writeDB      database.WriteStore
+	readDB       database.ReadStore
+	networkUtils *network.NetworkUtils
+	client       *http.Client
+	ticker       *time.Ticker
+	// mu guards the active alerts and the refresh time, checks and reloads run apart
+	mu          sync.Mutex
+	active      map[string]*alert
+	refreshTime int
+}
+
+// alertsRefreshTime is the minutes between checks, 5 when not configured.
+func alertsRefreshTime(configValues *config.Config) int {
+	if configValues.Alerts != nil && configValues.Alerts.RefreshTime > 0 {
+		return configValues.Alerts.RefreshTime
+	}
+	return 5
+}
+
+func NewAlerter(reloader *config.Reloader, writeDB database.WriteStore, readDB database.ReadStore, networkUtils *network.NetworkUtils) *Alerter {
+	a := &Alerter{
+		reloader:     reloader,
+		writeDB:      writeDB,
+		readDB:       readDB,
+		networkUtils: networkUtils,
+		client:       &http.Client{Timeout: 10 * time.Second},
+		active:       make(map[string]*alert),
+	}
+	a.refreshTime = alertsRefreshTime(reloader.Current())
+	a.ticker = time.NewTicker(time.Duration(a.refreshTime) * time.Minute)
+	go func() {
+		a.check()
+		for range a.ticker.C {
+			// only the leader alerts, a fenced off one would send them twice
+			if a.writeDB.Fenced() {
+				continue
+			}
+			a.check()
+		}
+	}()
+	return a
+}
+
+// Reload applies a changed refresh time and forgets the alerts of the nodes and
+// coinbases no longer watched, the watched lists and thresholds are read from the
+// reloader on every check.
+func (a *Alerter) Reload(configValues *config.Config) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	refreshTime := alertsRefreshTime(configValues)
+	if refreshTime != a.refreshTime {
+		a.refreshTime = refreshTime
+		a.ticker.Reset(time.Duration(refreshTime) * time.Minute)
+	}
+	watched := make(map[string]bool)
+	if alertsConfig := configValues.Alerts; alertsConfig != nil {
+		for _, nodeId := range alertsConfig.NodeIds {
+			watched["atx:"+nodeId] = true
+			watched["registration:"+nodeId] = true
+		}
+		for _, coinbase := range alertsConfig.Coinbases {
+			watched["rewards:"+coinbase] = true
+		}
+	}
+	for key := range a.active {
+		if key != "lag" && !watched[key] {
+			delete(a.active, key)
+		}
+	}
+}
+
+// checks collects the alerts that hold and the keys of the ones that do not.
+type checks struct {
+	firing   []*alert
+	resolved []string
+}
+
+func (c *checks) set(holds bool, key string, epoch uint32, message string) {
+	if holds {
+		c.firing = append(c.firing, &alert{key: key, epoch: epoch, message: message})
+	} else {
+		c.resolved = append(c.resolved, key)
+	}
+}
+
+func (a *Alerter) check() {
+	alertsConfig := a.reloader.Current().Alerts
+	if alertsConfig == nil || !alertsConfig.Enabled {
+		return
+	}
+	now := time.Now()
+	currentLayer := uint32((now.Unix() - config.GenesisEpochSeconds) / config.LayerDuration)
+	epoch := currentLayer / config.LayersPerEpoch
+
+	c := &checks{}
+	lastLayer, err := a.readDB.GetLastProcessedLayer()
+	if err != nil {
+		log.Printf("Failed to get last processed layer for alerts: %v", err)
+	} else if lastLayer.Layer > 0 {
+		a.checkLag(c, alertsConfig, uint32(lastLayer.Layer), now)
+		a.checkRewards(c, alertsConfig, uint32(lastLayer.Layer), epoch)
+	}
+	a.checkAtxs(c, alertsConfig, currentLayer, epoch, now)
+
+	// the alerts are sent once the lock is released, a slow channel does not hold reloads
+	var messages []string
+	a.mu.Lock()
+	for _, firing := range c.firing {
+		if active, exists := a.active[firing.key]; exists && active.epoch == firing.epoch {
+			continue
+		}
+		a.active[firing.key] = firing
+		messages = append(messages, firing.message)
+	}
+	for _, key := range c.resolved {
+		if active, exists := a.active[key]; exists {
+			delete(a.active, key)
+			messages = append(messages, "Resolved: "+active.message)
+		}
+	}
+	a.mu.Unlock()
+	for _, message := range messages {
+		a.send(alertsConfig, message)
+	}
+}
+
+// checkLag alerts when the last processed layer ended more than the max lag ago.
+func (a *Alerter) checkLag(c *checks, alertsConfig *config.AlertsConfig, lastLayer uint32, now time.Time) {
+	maxLag := 900 * time.Second
+	if alertsConfig.MaxLag > 0 {
+		maxLag = time.Duration(alertsConfig.MaxLag) * time.Second
+	}
+	lag := now.Sub(a.networkUtils.GetLayerTime(uint64(lastLayer) + 1))
+	c.set(lag > maxLag, "lag", 0, fmt.Sprintf("Ingestion lags, the last processed layer %d ended %s ago", lastLayer, lag.Truncate(time.Second)))
+}
+
+// checkAtxs alerts the watched nodes without an atx published in the epoch once the
+// deadline passed, and once the first poet registration for the next round closes in
+// the registration warning. Before, only nodes that published are resolved.
+func (a *Alerter) checkAtxs(c *checks, alertsConfig *config.AlertsConfig, currentLayer uint32, epoch uint32, now time.Time) {
+	deadline := 80
+	if alertsConfig.AtxDeadline > 0 {
+		deadline = alertsConfig.AtxDeadline
+	}
+	passed := int(currentLayer%config.LayersPerEpoch) * 100 / int(config.LayersPerEpoch)
+	closing, closes := a.closingRegistration(alertsConfig, epoch, now)
+	for _, nodeId := range alertsConfig.NodeIds {
+		atx, err := a.readDB.GetPreviousAtx(nodeId, epoch+1)
+		if err != nil {
+			log.Printf("Failed to get atx of node %s for alerts: %v", nodeId, err)
+			continue
+		}
+		published := atx.AtxID != "" && atx.PublishEpoch == epoch
+		if published {
+			c.set(false, "registration:"+nodeId, epoch, "")
+		} else if closing != nil {
+			left := time.Duration(closes-now.Unix()) * time.Second
+			c.set(true, "registration:"+nodeId, epoch, fmt.Sprintf("Node %s has not published an atx in epoch %d, the registration of poet %s closes in %s", nodeId, epoch, closing.Name, left.Truncate(time.Minute)))
+		}
+		if !published && passed < deadline {
+			continue
+		}
+		c.set(!published, "atx:"+nodeId, epoch, fmt.Sprintf("Node %s has not published an atx in epoch %d, %d%% of the epoch passed", nodeId, epoch, passed))
+	}
+}
+
+// closingRegistration is the poet whose registration for the round after the atxs of
+// epoch closes first, when it closes within the registration warning, and the unix time
+// it closes.
+func (a *Alerter) closingRegistration(alertsConfig *config.AlertsConfig, epoch uint32, now time.Time) (*config.PoetConfig, int64) {
+	warning := int64(60 * 60)
+	if alertsConfig.RegistrationWarning > 0 {
+		warning = int64(alertsConfig.RegistrationWarning) * 60
+	}
+	var closing *config.PoetConfig
+	closes := int64(0)
+	for _, poet := range a.reloader.Current().Poets {
+		if poet.Settings == nil {
+			continue
+		}
+		round := a.networkUtils.GetPoetRound(poet.Settings.PhaseShift, poet.Settings.CycleGap, now)
+		if round.Phase != network.PoetPhaseRegistration || round.PublishEpoch != epoch || round.SecondsToRegistrationEnd > warning {
+			continue
+		}
+		if closing == nil || round.RegistrationCloses < closes {
+			closing = poet
+			closes = round.RegistrationCloses
+		}
+	}
+	return closing, closes
+}
+
+// checkRewards compares the rewards of the watched coinbases in the processed layers of
+// the epoch with the rewards expected from their eligibility until the last of them.
+func (a *Alerter) checkRewards(c *checks, alertsConfig *config.AlertsConfig, lastLayer uint32, epoch uint32) {
+	if len(alertsConfig.Coinbases) == 0 || lastLayer/config.LayersPerEpoch != epoch || epoch == 0 {
+		return
+	}
+	threshold := 3
+	if alertsConfig.MissedRewards > 0 {
+		threshold = alertsConfig.MissedRewards
+	}
+	epochAtx, err := a.readDB.GetAtxEpoch(uint64(epoch - 1))
+	if err != nil || epochAtx.TotalWeight == 0 {
+		return
+	}
+	firstLayer := epoch * config.LayersPerEpoch
+	processed := float64(lastLayer-firstLayer+1) / float64(config.LayersPerEpoch)
+	for _, coinbase := range alertsConfig.Coinbases {
+		atxs, err := a.readDB.GetAccountAtxList(coinbase, uint64(epoch-1))
+		if err != nil {
+			log.Printf("Failed to get atxs of coinbase %s for alerts: %v", coinbase, err)
+			continue
+		}
+		slots := int32(0)
+		for _, atx := range atxs {
+			count, err := a.networkUtils.GetNumberOfSlots(atx.Weight, epochAtx.TotalWeight, epoch)
+			if err != nil {
+				log.Printf("Failed to get eligibility of atx %s for alerts: %v", atx.AtxID, err)
+				continue
+			}
+			slots += count
+		}
+		rewards, err := a.readDB.CountRewards(coinbase, int(firstLayer), int(lastLayer))
+		if err != nil {
+			log.Printf("Failed to count rewards of coinbase %s for alerts: %v", coinbase, err)
+			continue
+		}
+		expected := int64(float64(slots) * processed)
+		missed := expected - rewards
+		c.set(missed >= int64(threshold), "rewards:"+coinbase, epoch, fmt.Sprintf("Coinbase %s got %d rewards in epoch %d until layer %d, %d were expected", coinbase, rewards, epoch, lastLayer, expected))
+	}
+}
+
+func (a *Alerter) send(alertsConfig *config.AlertsConfig, text string) {
+	log.Printf("Alert: %s", text)
+	for _, channel := range channels(alertsConfig) {
+		if err := channel.send(a.client, text); err != nil {
+			log.Printf("Failed to send alert to %s: %v", channel.name(), err)
+		}
+	}
+}
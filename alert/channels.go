@@ -0,0 +1,83 @@
+package alert
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/swarmbit/spacemesh-state-api/config"
+)
+
+// channel is where alerts are sent, a telegram chat or a discord channel.
+type channel interface {
+	name() string
+	send(client *http.Client, text string) error
+}
+
+// channels are the configured channels of alertsConfig.
+func channels(alertsConfig *config.AlertsConfig) []channel {
+	var configured []channel
+	if alertsConfig.Telegram != nil {
+		configured = append(configured, &telegram{alertsConfig.Telegram})
+	}
+	if alertsConfig.Discord != nil {
+		configured = append(configured, &discord{alertsConfig.Discord})
+	}
+	return configured
+}
+
+type telegram struct {
+	config *config.TelegramConfig
+}
+
+func (t *telegram) name() string {
+	return "telegram"
+}
+
+func (t *telegram) send(client *http.Client, text string) error {
+	return postJson(client, "https://api.telegram.org/bot"+t.config.BotToken+"/sendMessage", map[string]string{
+		"chat_id": t.config.ChatId,
+		"text":    text,
+	})
+}
+
+type discord struct {
+	config *config.DiscordConfig
+}
+
+func (d *discord) name() string {
+	return "discord"
+}
+
+func (d *discord) send(client *http.Client, text string) error {
+	return postJson(client, d.config.WebhookUrl, map[string]string{
+		"content": text,
+	})
+}
+
+func postJson(client *http.Client, address string, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	response, err := client.Post(address, "application/json", bytes.NewReader(data))
+	if err != nil {
+		// the url holds the telegram bot token, it is not logged
+		var urlError *url.Error
+		if errors.As(err, &urlError) {
+			err = urlError.Err
+		}
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		return fmt.Errorf("answered %d: %s", response.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package checkpoint
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	sTypes "github.com/spacemeshos/go-spacemesh/common/types"
+	natsS "github.com/spacemeshos/go-spacemesh/nats"
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+// Version is the checkpoint schema go-spacemesh writes and reads.
+const Version = "https://spacemesh.io/checkpoint.schema.json.1.0"
+
+const accountsBatchSize = 1000
+
+// Import seeds the store from a go-spacemesh checkpoint file. The atxs are saved like the
+// sink saves them, the balances are set to the checkpoint ones and the layer before the
+// restore layer is marked processed, so the sink continues from the restore layer of a
+// node recovered from the same checkpoint. The restore layer is read from the checkpoint
+// id, snapshot-<layer>-restore-<layer>, when restoreLayer is 0, and returned. The sink
+// consumers must then be aligned with the restore layer, see sink.AlignConsumers.
+func Import(path string, restoreLayer uint32, writeDB database.WriteStore) (uint32, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	var checkpoint sTypes.Checkpoint
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return 0, fmt.Errorf("invalid checkpoint %s: %w", path, err)
+	}
+	if checkpoint.Version != Version {
+		return 0, fmt.Errorf("unsupported checkpoint version %q", checkpoint.Version)
+	}
+	if restoreLayer == 0 {
+		restoreLayer, err = parseRestoreLayer(checkpoint.Data.CheckpointId)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if restoreLayer == 0 {
+		return 0, fmt.Errorf("restore layer must be after genesis")
+	}
+
+	for _, atx := range checkpoint.Data.Atxs {
+		var coinbase sTypes.Address
+		copy(coinbase[:], atx.Coinbase)
+		err := writeDB.SaveAtx(context.TODO(), &natsS.Atx{
+			AtxID:             hex.EncodeToString(atx.ID),
+			NodeID:            hex.EncodeToString(atx.PublicKey),
+			Coinbase:          coinbase.String(),
+			PublishEpoch:      atx.Epoch,
+			EffectiveNumUnits: atx.NumUnits,
+			BaseTick:          atx.BaseTickHeight,
+			TickCount:         atx.TickCount,
+			Sequence:          atx.Sequence,
+		})
+		if err != nil {
+			return 0, fmt.Errorf("failed to import atx %x: %w", atx.ID, err)
+		}
+	}
+	log.Printf("Imported %d atxs", len(checkpoint.Data.Atxs))
+
+	// the checkpoint balances include the genesis ledger, the sink must not add it again
+	if _, err := writeDB.ImportGenesisLedger(nil); err != nil {
+		return 0, err
+	}
+	accounts := make([]*types.AccountDoc, 0, accountsBatchSize)
+	for i, account := range checkpoint.Data.Accounts {
+		var address sTypes.Address
+		copy(address[:], account.Address)
+		accounts = append(accounts, &types.AccountDoc{Address: address.String(), Balance: account.Balance})
+		if len(accounts) == accountsBatchSize || i == len(checkpoint.Data.Accounts)-1 {
+			if err := writeDB.ImportCheckpointAccounts(accounts, restoreLayer-1); err != nil {
+				return 0, fmt.Errorf("failed to import accounts: %w", err)
+			}
+			accounts = accounts[:0]
+		}
+	}
+	log.Printf("Imported %d accounts", len(checkpoint.Data.Accounts))
+
+	err = writeDB.SaveReplayedLayer(context.TODO(), &natsS.LayerUpdate{LayerID: restoreLayer - 1, Status: database.LayerStatusApplied})
+	if err != nil {
+		return 0, err
+	}
+	return restoreLayer, nil
+}
+
+// Export writes the balances and the atxs of the last two epochs at the last processed
+// layer as a go-spacemesh checkpoint, with restore layer the next one, to bootstrap
+// another connector without replaying the stream. Nonces, templates and account states
+// are not kept by the connector, the file can not recover a node.
+func Export(path string, readDB database.ReadStore) error {
+	layer, err := readDB.GetLastProcessedLayer()
+	if err != nil {
+		return err
+	}
+	if layer.Layer == 0 {
+		return fmt.Errorf("no layer was processed yet")
+	}
+	snapshotLayer := uint32(layer.Layer)
+	epoch := snapshotLayer / config.LayersPerEpoch
+
+	checkpoint := &sTypes.Checkpoint{
+		Version: Version,
+		Data: sTypes.InnerData{
+			CheckpointId: fmt.Sprintf("snapshot-%d-restore-%d", snapshotLayer, snapshotLayer+1),
+			Atxs:         make([]sTypes.AtxSnapshot, 0),
+			Accounts:     make([]sTypes.AccountSnapshot, 0),
+		},
+	}
+
+	for publishEpoch := max(epoch, 1) - 1; publishEpoch <= epoch; publishEpoch++ {
+		err = readDB.StreamAtxForEpoch(uint64(publishEpoch), 1, func(atx *types.AtxDoc) error {
+			id, err := hex.DecodeString(atx.AtxID)
+			if err != nil {
+				return fmt.Errorf("invalid atx id %s: %w", atx.AtxID, err)
+			}
+			nodeId, err := hex.DecodeString(atx.NodeID)
+			if err != nil {
+				return fmt.Errorf("invalid node id %s: %w", atx.NodeID, err)
+			}
+			coinbase, err := sTypes.StringToAddress(atx.Coinbase)
+			if err != nil {
+				return fmt.Errorf("invalid coinbase %s: %w", atx.Coinbase, err)
+			}
+			checkpoint.Data.Atxs = append(checkpoint.Data.Atxs, sTypes.AtxSnapshot{
+				ID:             id,
+				Epoch:          atx.PublishEpoch,
+				NumUnits:       atx.EffectiveNumUnits,
+				BaseTickHeight: atx.BaseTick,
+				TickCount:      atx.TickCount,
+				PublicKey:      nodeId,
+				Sequence:       atx.Sequence,
+				Coinbase:       coinbase.Bytes(),
+			})
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	err = readDB.StreamAccounts(-1, func(account *types.AccountDoc) error {
+		address, err := sTypes.StringToAddress(account.Address)
+		if err != nil {
+			return fmt.Errorf("invalid account %s: %w", account.Address, err)
+		}
+		checkpoint.Data.Accounts = append(checkpoint.Data.Accounts, sTypes.AccountSnapshot{
+			Address: address.Bytes(),
+			Balance: account.Balance,
+		})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return err
+	}
+	log.Printf("Exported checkpoint %s with %d atxs and %d accounts", checkpoint.Data.CheckpointId, len(checkpoint.Data.Atxs), len(checkpoint.Data.Accounts))
+	return nil
+}
+
+func parseRestoreLayer(checkpointId string) (uint32, error) {
+	_, restore, found := strings.Cut(checkpointId, "-restore-")
+	if !found {
+		return 0, fmt.Errorf("checkpoint id %q has no restore layer, set it explicitly", checkpointId)
+	}
+	layer, err := strconv.ParseUint(restore, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("checkpoint id %q has an invalid restore layer: %w", checkpointId, err)
+	}
+	return uint32(layer), nil
+}
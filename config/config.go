@@ -1,33 +1,583 @@
 package config
 
+// Run modes, a sink instance writes and runs the background jobs, an api instance only
+// serves reads and can be scaled out next to a single sink.
+const (
+    ModeAll  = "all"
+    ModeSink = "sink"
+    ModeApi  = "api"
+)
+
+// Highest atx strategies, honest skips the atxs of malfeasant nodes and any keeps them.
+// A rank above MaxHighestAtxRank is not looked up.
+const (
+    HighestAtxHonest  = "honest"
+    HighestAtxAny     = "any"
+    MaxHighestAtxRank = 100
+)
+
 type Config struct {
+    // all, sink or api, all when empty
+    Mode    string         `json:"mode"`
+    Network *NetworkConfig `json:"network"`
     Server *ServerConfig `json:"server"`
     Price  *PriceConfig  `json:"price"`
     DB     *DBConfig     `json:"db"`
     Nats   *NatsConfig   `json:"nats"`
     Poets  []*PoetConfig `json:"poets"`
+
+    Aggregation *AggregationConfig `json:"aggregation"`
+    Sync        *SyncConfig        `json:"sync"`
+    Stats       *StatsConfig       `json:"stats"`
+    ClickHouse  *ClickHouseConfig  `json:"clickhouse"`
+    Retention   *RetentionConfig   `json:"retention"`
+    History     *HistoryConfig     `json:"history"`
+    State       *StateConfig       `json:"state"`
+    Consistency *ConsistencyConfig `json:"consistency"`
+    Admin       *AdminConfig       `json:"admin"`
+    Tracing     *TracingConfig     `json:"tracing"`
+    Reload      *ReloadConfig      `json:"reload"`
+    PoetHealth  *PoetHealthConfig  `json:"poetHealth"`
+    Node        *NodeConfig        `json:"node"`
+    Pending     *PendingConfig     `json:"pending"`
+    Retry       *RetryConfig       `json:"retry"`
+    Cache       *CacheConfig       `json:"cache"`
+    Limits      *LimitsConfig      `json:"limits"`
+    Webhooks    *WebhooksConfig    `json:"webhooks"`
+    Alerts      *AlertsConfig      `json:"alerts"`
+    Watchlists  *WatchlistsConfig  `json:"watchlists"`
+    Processors  *ProcessorsConfig  `json:"processors"`
+    Pool        *PoolConfig        `json:"pool"`
+    Digests     *DigestsConfig     `json:"digests"`
+    Smtp        *SmtpConfig        `json:"smtp"`
+    Emails      *EmailsConfig      `json:"emails"`
+    Api         *ApiConfig         `json:"api"`
 }
 
-type PriceConfig struct {
-    Provider    string `json:"provider"`
+// ApiVersions are the versions of the api, served under /v1 and /v2. The routes without
+// a version prefix serve v1.
+var ApiVersions = []string{"v1", "v2"}
+
+// DocsAssets are the files of Swagger UI and Redoc the docs pages load.
+var DocsAssets = []string{"swagger-ui.css", "swagger-ui-bundle.js", "swagger-ui-standalone-preset.js", "redoc.standalone.js"}
+
+// ApiConfig renames the json fields of the responses of a version. FieldNames is keyed by
+// the version and the route without the version prefix, and maps a field to its name in
+// the responses of the route, an empty name drops it. The renames apply over the shapes
+// the version gives the route. A field of an inner object is given with its path, the
+// field names to it and [] for the items of an array joined with dots: [].layer.
+type ApiConfig struct {
+    FieldNames map[string]map[string]map[string]string `json:"fieldNames"`
+}
+
+// SmtpConfig is the mail server emails are sent through, as From. Port 465 connects with
+// tls, other ports upgrade with STARTTLS when the server offers it. Username and Password
+// authenticate with PLAIN when set.
+type SmtpConfig struct {
+    Host     string `json:"host"`
+    Port     int    `json:"port"`
+    Username string `json:"username"`
+    Password string `json:"password"`
+    From     string `json:"from"`
+}
+
+// EmailsConfig adds the /watchlist/emails endpoints, holders of a watchlist api key
+// register up to MaxEmails addresses, 5 when empty, to get a daily or weekly digest of
+// their watchlist through smtp. The instance running the sink checks every RefreshTime
+// minutes, 30 when empty, for digests due once the reward digests of the last day are
+// complete, so digests must be enabled. BaseUrl is the public url of the api the
+// confirmation and unsubscribe links point to.
+type EmailsConfig struct {
+    Enabled     bool   `json:"enabled"`
+    BaseUrl     string `json:"baseUrl"`
+    MaxEmails   int    `json:"maxEmails"`
     RefreshTime int    `json:"refreshTime"`
 }
 
+// DigestsConfig sums the rewards of every coinbase per UTC day every RefreshTime minutes,
+// 15 when empty, on the instance running the sink. Days are recomputed until all their
+// layers were processed, then webhooks following the coinbase get the digest.
+type DigestsConfig struct {
+    Enabled     bool `json:"enabled"`
+    RefreshTime int  `json:"refreshTime"`
+}
+
+// PoolConfig accounts the rewards of the member nodes of a smeshing pool for payouts. The
+// pool processor, which requires processors enabled with pool in their names, saves the
+// rewards of the member nodes and the /pool endpoints, behind ApiKeys, report what each
+// member is paid per epoch: Share percent of the rewards of all the members, what is left
+// goes to the operator. Members apply on reload, the rewards saved before a node became a
+// member are copied when it is added.
+type PoolConfig struct {
+    Enabled bool                `json:"enabled"`
+    ApiKeys []string            `json:"apiKeys"`
+    Members []*PoolMemberConfig `json:"members"`
+}
+
+type PoolMemberConfig struct {
+    Name    string   `json:"name"`
+    NodeIds []string `json:"nodeIds"`
+    Share   float64  `json:"share"`
+}
+
+// ProcessorsConfig runs the custom processors compiled into the server, the ones in Names
+// or every registered one when empty, on the instance running the sink. Options holds the
+// settings of each processor by name, passed to it as they are written.
+type ProcessorsConfig struct {
+    Enabled bool                              `json:"enabled"`
+    Names   []string                          `json:"names"`
+    Options map[string]map[string]interface{} `json:"options"`
+}
+
+// WatchlistsConfig adds the /watchlist endpoints, callers holding one of ApiKeys in the
+// x-api-key header keep a watchlist of up to MaxItems addresses and node ids, 200 when
+// empty. They are served by the instances that open the write store, the summary shows
+// the RecentRewards latest rewards of each item, 5 when empty.
+type WatchlistsConfig struct {
+    Enabled       bool     `json:"enabled"`
+    ApiKeys       []string `json:"apiKeys"`
+    MaxItems      int      `json:"maxItems"`
+    RecentRewards int      `json:"recentRewards"`
+}
+
+// AlertsConfig sends alerts for node operators to a telegram chat, a discord channel or
+// both, checked every RefreshTime minutes, 5 when empty, by the instance running the
+// sink. A watched node is alerted when it did not publish an atx in the epoch once
+// AtxDeadline percent of the epoch passed, 80 when empty, a watched coinbase when its
+// rewards in the epoch are MissedRewards behind its eligibility, 3 when empty, and the
+// ingestion when the last processed layer is MaxLag seconds old, 900 when empty. A watched
+// node without an atx in the epoch is also alerted once the registration of a configured
+// poet closes in RegistrationWarning minutes, 60 when empty. Alerts are sent once and
+// again when they resolve. The watched lists and thresholds apply on reload.
+type AlertsConfig struct {
+    Enabled       bool            `json:"enabled"`
+    Telegram      *TelegramConfig `json:"telegram"`
+    Discord       *DiscordConfig  `json:"discord"`
+    NodeIds       []string        `json:"nodeIds"`
+    Coinbases     []string        `json:"coinbases"`
+    AtxDeadline   int             `json:"atxDeadline"`
+    MissedRewards int             `json:"missedRewards"`
+    MaxLag        int             `json:"maxLag"`
+    RefreshTime   int             `json:"refreshTime"`
+    // minutes before a poet registration closes
+    RegistrationWarning int `json:"registrationWarning"`
+}
+
+// TelegramConfig sends alerts as the bot of BotToken to the chat ChatId, the bot must be
+// a member of the chat.
+type TelegramConfig struct {
+    BotToken string `json:"botToken"`
+    ChatId   string `json:"chatId"`
+}
+
+// DiscordConfig sends alerts to the channel of a discord webhook url.
+type DiscordConfig struct {
+    WebhookUrl string `json:"webhookUrl"`
+}
+
+// WebhooksConfig adds the /webhooks endpoints, callers holding one of ApiKeys in the
+// x-api-key header manage their own webhooks. Instances running the sink queue the
+// deliveries and the leader sends them every RefreshTime seconds, 5 when empty, waiting
+// Timeout seconds, 10 when empty. Failed deliveries are retried up to MaxAttempts times,
+// 8 when empty, MinDelay seconds after the first failure, 30 when empty, doubling up to
+// MaxDelay seconds, 3600 when empty.
+type WebhooksConfig struct {
+    Enabled     bool     `json:"enabled"`
+    ApiKeys     []string `json:"apiKeys"`
+    RefreshTime int      `json:"refreshTime"`
+    Timeout     int      `json:"timeout"`
+    MaxAttempts int      `json:"maxAttempts"`
+    MinDelay    int      `json:"minDelay"`
+    MaxDelay    int      `json:"maxDelay"`
+}
+
+// LimitsConfig protects the db from expensive requests. DefaultLimit is the limit of
+// list endpoints called without one, their own default when empty, and larger limits
+// are lowered to MaxLimit, 1000 when empty. Concurrency caps the requests served at
+// once by a route, keyed by the route as registered, e.g. /account/:accountAddress/rewards.
+// Requests over the cap wait up to QueueTimeout milliseconds for a slot, they are
+// answered with 503 right away when 0. MaxBatchAccounts is the most addresses of one
+// batch lookup, 100 when empty.
+type LimitsConfig struct {
+    DefaultLimit     int            `json:"defaultLimit"`
+    MaxLimit         int            `json:"maxLimit"`
+    Concurrency      map[string]int `json:"concurrency"`
+    QueueTimeout     int            `json:"queueTimeout"`
+    MaxBatchAccounts int            `json:"maxBatchAccounts"`
+}
+
+// CacheConfig sets the Cache-Control header of the routes in CacheControl, keyed by the
+// route as registered, e.g. /account/:accountAddress, over the defaults of the supply and
+// parameters routes. Routes in LayerRoutes, /network/info when empty, get an etag and a
+// last modified of the last processed layer and are answered with 304 before reading
+// anything. Every other GET gets an etag of the response body. DisableEtags turns off
+// both etags.
+type CacheConfig struct {
+    DisableEtags bool              `json:"disableEtags"`
+    CacheControl map[string]string `json:"cacheControl"`
+    LayerRoutes  []string          `json:"layerRoutes"`
+}
+
+// RetryConfig delays the redelivery of messages the sink failed to save, MinDelay
+// milliseconds after the first failure, 500 when empty, doubling on every delivery up to
+// MaxDelay seconds, 60 when empty. After BreakerFailures consecutive failed saves, 10
+// when empty, the sinks stop fetching for BreakerCooldown seconds, 30 when empty, and
+// then try again until a save succeeds.
+type RetryConfig struct {
+    MinDelay        int `json:"minDelay"`
+    MaxDelay        int `json:"maxDelay"`
+    BreakerFailures int `json:"breakerFailures"`
+    BreakerCooldown int `json:"breakerCooldown"`
+}
+
+// PendingConfig expires created transactions without a result every RefreshTime
+// minutes, 5 when empty, once they were created the duration of ExpireLayers layers ago,
+// 20 when empty. With the node enabled transactions still in its mempool are kept.
+type PendingConfig struct {
+    RefreshTime  int `json:"refreshTime"`
+    ExpireLayers int `json:"expireLayers"`
+}
+
+// NodeConfig connects to the grpc api of a go-spacemesh node at Address for the data the
+// nats streams do not carry. The node is queried every RefreshTime seconds, 10 when
+// empty, waiting up to Timeout seconds, 5 when empty. Lost connections are retried with
+// a backoff up to MaxReconnectDelay seconds, 120 when empty.
+type NodeConfig struct {
+    Enabled           bool   `json:"enabled"`
+    Address           string `json:"address"`
+    Tls               bool   `json:"tls"`
+    RefreshTime       int    `json:"refreshTime"`
+    Timeout           int    `json:"timeout"`
+    MaxReconnectDelay int    `json:"maxReconnectDelay"`
+}
+
+// PoetHealthConfig probes the info endpoint of every poet with an address every
+// RefreshTime minutes, 5 when empty, waiting up to Timeout seconds, 10 when empty.
+type PoetHealthConfig struct {
+    Enabled     bool `json:"enabled"`
+    RefreshTime int  `json:"refreshTime"`
+    Timeout     int  `json:"timeout"`
+}
+
+// ReloadConfig checks the config file for changes every Interval seconds, 10 when
+// empty, with Watch. The config is always reloaded on SIGHUP.
+type ReloadConfig struct {
+    Watch    bool `json:"watch"`
+    Interval int  `json:"interval"`
+}
+
+// TracingConfig exports opentelemetry spans over OTLP http to Endpoint, localhost:4318
+// when empty. SampleRatio is the share of traces kept, all when empty.
+type TracingConfig struct {
+    Enabled     bool    `json:"enabled"`
+    Endpoint    string  `json:"endpoint"`
+    Insecure    bool    `json:"insecure"`
+    ServiceName string  `json:"serviceName"`
+    SampleRatio float64 `json:"sampleRatio"`
+}
+
+// AdminConfig adds the /admin endpoints, they are only added with a non empty ApiKey
+// that callers send in the x-admin-key header.
+type AdminConfig struct {
+    Enabled bool   `json:"enabled"`
+    ApiKey  string `json:"apiKey"`
+}
+
+// ConsistencyConfig scans the last Epochs epochs, 2 when empty, every RefreshTime
+// minutes for missing layers, applied layers without rewards and epochs without atxs.
+// With Refetch the gaps are read again from the nats streams. Keep Epochs below the
+// retention of layers and rewards, pruned data is reported as missing.
+type ConsistencyConfig struct {
+    Enabled     bool `json:"enabled"`
+    RefreshTime int  `json:"refreshTime"`
+    Epochs      int  `json:"epochs"`
+    Refetch     bool `json:"refetch"`
+}
+
+// StateConfig sets how often the network state the api serves is refreshed, in seconds.
+// Every refresh waits up to Jitter seconds more so replicas do not query the database at
+// the same time. DisableRefresh keeps the state loaded at start, for sink only instances.
+// SubsidyRefreshTime is not used anymore, the subsidies are refreshed with the info.
+type StateConfig struct {
+    InfoRefreshTime    int               `json:"infoRefreshTime"`
+    SubsidyRefreshTime int               `json:"subsidyRefreshTime"`
+    Jitter             int               `json:"jitter"`
+    DisableRefresh     bool              `json:"disableRefresh"`
+    HighestAtx         *HighestAtxConfig `json:"highestAtx"`
+}
+
+// HighestAtxConfig chooses the highest atx of the network info. Strategy is honest when
+// empty and Rank picks the Nth highest of the strategy candidates, 1 when 0, to keep a
+// safety margin below the top.
+type HighestAtxConfig struct {
+    Strategy string `json:"strategy"`
+    Rank     int    `json:"rank"`
+}
+
+// HistoryConfig stores a snapshot of the network state every RefreshTime minutes for
+// the network charts.
+type HistoryConfig struct {
+    Enabled     bool `json:"enabled"`
+    RefreshTime int  `json:"refreshTime"`
+}
+
+// RetentionConfig prunes per layer data older than the kept epochs of each policy every
+// RefreshTime minutes. Collections without a policy are kept forever, with DryRun the
+// job only logs what it would delete.
+type RetentionConfig struct {
+    Enabled     bool               `json:"enabled"`
+    RefreshTime int                `json:"refreshTime"`
+    DryRun      bool               `json:"dryRun"`
+    Policies    []*RetentionPolicy `json:"policies"`
+}
+
+// RetentionPolicy keeps the last KeepEpochs epochs of rewards, layers, transactions or blocks.
+type RetentionPolicy struct {
+    Collection string `json:"collection"`
+    KeepEpochs int    `json:"keepEpochs"`
+}
+
+// ClickHouseConfig enables a copy of rewards, transactions and atxs in clickhouse. Rows
+// are buffered and inserted every FlushInterval seconds or once BatchSize rows are
+// waiting, QueueSize bounds the rows held in memory while clickhouse is unavailable.
+type ClickHouseConfig struct {
+    Enabled       bool   `json:"enabled"`
+    Uri           string `json:"uri"`
+    Database      string `json:"database"`
+    User          string `json:"user"`
+    Password      string `json:"password"`
+    BatchSize     int    `json:"batchSize"`
+    FlushInterval int    `json:"flushInterval"`
+    QueueSize     int    `json:"queueSize"`
+}
+
+type StatsConfig struct {
+    Enabled       bool `json:"enabled"`
+    RefreshTime   int  `json:"refreshTime"`
+    RetentionDays int  `json:"retentionDays"`
+}
+
+type SyncConfig struct {
+    Enabled       bool `json:"enabled"`
+    RetentionDays int  `json:"retentionDays"`
+}
+
+type AggregationConfig struct {
+    RefreshTime int `json:"refreshTime"`
+}
+
+type PriceConfig struct {
+    Provider     string                 `json:"provider"`
+    RefreshTime  int                    `json:"refreshTime"`
+    TTL          int                    `json:"ttl"`
+    MaxStaleTime int                    `json:"maxStaleTime"`
+    Providers    []*PriceProviderConfig `json:"providers"`
+    // fiat currencies besides USD, exchange rates are refreshed every RatesRefreshTime minutes
+    Currencies       []string `json:"currencies"`
+    RatesRefreshTime int      `json:"ratesRefreshTime"`
+}
+
+// PriceProviderConfig declares a price source, providers are tried in the order listed.
+type PriceProviderConfig struct {
+    Name   string `json:"name"`
+    ApiKey string `json:"apiKey"`
+}
+
+// ServerConfig timeouts are in seconds. ReadHeaderTimeout is 10 and IdleTimeout 120 when
+// empty, the read and write timeouts are not set when empty. MaxHeaderBytes is 1 MB when
+// empty. ShutdownTimeout is how long in flight requests and the sinks get to finish on
+// shutdown, 30 when empty.
 type ServerConfig struct {
-    Port string `json:"port"`
+    Port              string             `json:"port"`
+    Cors              *CorsConfig        `json:"cors"`
+    Compression       *CompressionConfig `json:"compression"`
+    Tls               *TlsConfig         `json:"tls"`
+    ReadTimeout       int                `json:"readTimeout"`
+    ReadHeaderTimeout int                `json:"readHeaderTimeout"`
+    WriteTimeout      int                `json:"writeTimeout"`
+    IdleTimeout       int                `json:"idleTimeout"`
+    MaxHeaderBytes    int                `json:"maxHeaderBytes"`
+    ShutdownTimeout   int                `json:"shutdownTimeout"`
+    AccessLog         *AccessLogConfig   `json:"accessLog"`
+    Docs              *DocsConfig        `json:"docs"`
+}
+
+// DocsConfig serves the OpenAPI doc of the routes of the instance with Swagger UI and
+// Redoc under /docs. ServerUrl is the public url of the api the explorer sends requests
+// to, the host serving the docs when empty. The pages load the pinned versions of the
+// swagger-ui-dist and redoc packages from unpkg, or from AssetsUrl when set, where the
+// packages are served in their swagger-ui-dist and redoc directories. Integrity maps the
+// names of the files to their subresource integrity hash, the browser refuses a file
+// that does not match.
+type DocsConfig struct {
+    Enabled   bool              `json:"enabled"`
+    ServerUrl string            `json:"serverUrl"`
+    AssetsUrl string            `json:"assetsUrl"`
+    Integrity map[string]string `json:"integrity"`
+}
+
+// AccessLogConfig writes a json line per request to Output, a file path or stdout or
+// stderr, stdout when empty. SampleRate is the share of requests logged from 0 to 1,
+// every request when empty. Requests slower than SlowThreshold milliseconds are always
+// logged as slow, and only those with SlowOnly. The threshold is not checked when 0.
+type AccessLogConfig struct {
+    Enabled       bool    `json:"enabled"`
+    Output        string  `json:"output"`
+    SampleRate    float64 `json:"sampleRate"`
+    SlowThreshold int     `json:"slowThreshold"`
+    SlowOnly      bool    `json:"slowOnly"`
+}
+
+// TlsConfig serves https, and http/2, with the certificate in CertFile and KeyFile, or
+// with certificates of Let's Encrypt for Domains when Autocert is set. Autocert keeps
+// the certificates in CacheDir, autocert when empty, and answers the http challenges on
+// HttpPort, :80 when empty, which redirects everything else to https.
+type TlsConfig struct {
+    Enabled  bool     `json:"enabled"`
+    CertFile string   `json:"certFile"`
+    KeyFile  string   `json:"keyFile"`
+    Autocert bool     `json:"autocert"`
+    Domains  []string `json:"domains"`
+    Email    string   `json:"email"`
+    CacheDir string   `json:"cacheDir"`
+    HttpPort string   `json:"httpPort"`
+}
+
+// CorsConfig answers browser requests from AllowedOrigins, every origin when empty or
+// with *, with AllowedMethods, GET, POST, PUT, DELETE and OPTIONS when empty, and
+// AllowedHeaders, every header when empty. Preflights are cached MaxAge seconds, not
+// sent when 0. Without the section every origin is allowed with credentials.
+type CorsConfig struct {
+    AllowedOrigins   []string `json:"allowedOrigins"`
+    AllowedMethods   []string `json:"allowedMethods"`
+    AllowedHeaders   []string `json:"allowedHeaders"`
+    AllowCredentials bool     `json:"allowCredentials"`
+    MaxAge           int      `json:"maxAge"`
+}
+
+// CompressionConfig compresses responses of at least MinSize bytes, 1024 when empty,
+// with the first of Encodings the client accepts, zstd and gzip when empty. Level is the
+// gzip level, the default level when 0.
+type CompressionConfig struct {
+    Enabled   bool     `json:"enabled"`
+    Encodings []string `json:"encodings"`
+    MinSize   int      `json:"minSize"`
+    Level     int      `json:"level"`
 }
 
 type NatsConfig struct {
+    Enabled    bool   `json:"enabled"`
+    Uri        string `json:"uri"`
+    // identifies this connector in the sink fence, hostname and pid when empty
+    InstanceId string `json:"instanceId"`
+    // LeaderElection makes sink instances wait for the writer lease instead of taking the
+    // fence at start, a standby takes over once the leader stops renewing the lease. A
+    // leader that can not renew stops writing before the lease expires and waits for it
+    // again
+    LeaderElection bool `json:"leaderElection"`
+    // seconds the lease is valid without a renewal, 30 when empty
+    LeaseSeconds int `json:"leaseSeconds"`
+    // ReplayGaps re-reads from the streams the messages a recreated consumer skipped
+    // since the last checkpoint, at most MaxReplay per consumer, 10000 when empty
+    ReplayGaps bool `json:"replayGaps"`
+    MaxReplay  int  `json:"maxReplay"`
+    // QueueSize bounds the decoded events of each consumer waiting to be saved, 1000 when
+    // empty, fetching pauses while the queue is full. Writers save the queued rewards and
+    // atxs, 16 when empty, the other consumers keep one writer to save in stream order.
+    // Mongo with transactions and sqlite save rewards and atxs with one writer as well
+    QueueSize int `json:"queueSize"`
+    Writers   int `json:"writers"`
+    // User and Password, Token, NkeyFile, the file with the nkey seed, or CredentialsFile,
+    // a .creds file with the user jwt and seed, authenticate to the server
+    User            string `json:"user"`
+    Password        string `json:"password"`
+    Token           string `json:"token"`
+    NkeyFile        string `json:"nkeyFile"`
+    CredentialsFile string `json:"credentialsFile"`
+    // tls settings, plain connections when empty
+    Tls *NatsTlsConfig `json:"tls"`
+    // seconds between reconnect attempts, 2 when empty, a lost connection is retried
+    // until it is back
+    ReconnectWait int `json:"reconnectWait"`
+    // Publish re-publishes what the sink saved, decoded and enriched, for other services
+    Publish *NatsPublishConfig `json:"publish"`
+}
+
+// NatsPublishConfig publishes the saved rewards, transaction results and atxs on the
+// rewards, transactions and atx subjects under Prefix, state when empty. A stream on the
+// subjects keeps them for consumers that are not connected.
+type NatsPublishConfig struct {
     Enabled bool   `json:"enabled"`
-    Uri     string `json:"uri"`
+    Prefix  string `json:"prefix"`
+}
+
+// NatsTlsConfig connects over tls verifying the server certificate with CaFile, the
+// system roots when empty. CertFile and KeyFile are the client certificate when the
+// server requires one.
+type NatsTlsConfig struct {
+    Enabled  bool   `json:"enabled"`
+    CaFile   string `json:"caFile"`
+    CertFile string `json:"certFile"`
+    KeyFile  string `json:"keyFile"`
 }
 
 type DBConfig struct {
-    Uri string `json:"uri"`
+    // mongo, postgres or sqlite, mongo when empty. The sqlite uri is the database file path
+    Backend string `json:"backend"`
+    Uri     string `json:"uri"`
+    // mongo database, spacemesh on mainnet and spacemesh_<network name> on other networks
+    // when empty so networks sharing a server stay apart. Sql backends use the uri
+    Database string       `json:"database"`
+    Mongo    *MongoConfig `json:"mongo"`
+    // QueryTimeout bounds in seconds the queries the sql backends run outside of write
+    // transactions, unbounded when empty. Mongo uses Mongo.QueryTimeout
+    QueryTimeout int `json:"queryTimeout"`
+}
+
+// NetworkConfig selects the network followed, mainnet when empty. Name picks the
+// parameters of a known network, mainnet or testnet, set fields override them and
+// other networks must set all of them. GenesisTime is RFC 3339, LayerDuration in
+// seconds and Hrp the address prefix. GenesisId is computed from GenesisTime and
+// ExtraData, the genesis extra data of the node config, when empty. GenesisAccounts and
+// GenesisVaults are the genesis ledger, the mainnet vaults when both are empty on
+// mainnet, imported into the accounts once by the first sink that starts.
+type NetworkConfig struct {
+    Name            string           `json:"name"`
+    GenesisId       string           `json:"genesisId"`
+    GenesisTime     string           `json:"genesisTime"`
+    ExtraData       string           `json:"extraData"`
+    LayerDuration   int              `json:"layerDuration"`
+    LayersPerEpoch  int              `json:"layersPerEpoch"`
+    Hrp             string           `json:"hrp"`
+    GenesisAccounts []GenesisAccount `json:"genesisAccounts"`
+    GenesisVaults   []GenesisVault   `json:"genesisVaults"`
+}
+
+// MongoConfig tunes the mongo clients, empty settings keep the driver defaults and a
+// pool of 10. ReadPreference is primary, primaryPreferred, secondary,
+// secondaryPreferred or nearest and only applies to reads served by the api, with
+// MaxStaleness in seconds. WriteConcern is majority or a number of nodes. QueryTimeout
+// bounds every operation of the read clients, the aggregators read with them too, the
+// driver cancels the operation context after it. Timeouts are in seconds except
+// WriteTimeout in milliseconds.
+type MongoConfig struct {
+    MaxPoolSize            int    `json:"maxPoolSize"`
+    MinPoolSize            int    `json:"minPoolSize"`
+    ReadPreference         string `json:"readPreference"`
+    MaxStaleness           int    `json:"maxStaleness"`
+    WriteConcern           string `json:"writeConcern"`
+    Journal                bool   `json:"journal"`
+    WriteTimeout           int    `json:"writeTimeout"`
+    ConnectTimeout         int    `json:"connectTimeout"`
+    SocketTimeout          int    `json:"socketTimeout"`
+    ServerSelectionTimeout int    `json:"serverSelectionTimeout"`
+    QueryTimeout           int    `json:"queryTimeout"`
 }
 
 type PoetConfig struct {
-    Name     string        `json:"name"`
+    Name string `json:"name"`
+    // base url of the poet rest api, like https://mmn.spacemesh.network, for health checks
+    Address  string        `json:"address"`
     Info     *PoetInfo     `json:"info"`
     Settings *PoetSettings `json:"settings"`
 }
@@ -1,37 +1,70 @@
 package config
 
-const GenesisEpochSeconds = 1689321600
-const LayerDuration = 300
-const LayersPerEpoch = 4032
+// GenesisVault is a vault account created in the genesis ledger with the amount of
+// smidge it holds, vested linearly by the vault template.
+type GenesisVault struct {
+	Address string `json:"address"`
+	Amount  uint64 `json:"amount"`
+}
+
+// GenesisAccount is an account with a balance in the genesis ledger that is not a vault.
+type GenesisAccount struct {
+	Address string `json:"address"`
+	Balance uint64 `json:"balance"`
+}
+
+// genesis ledger of the network, mainnet until ApplyNetwork sets the configured one
+var (
+	genesisAccounts []GenesisAccount
+	genesisVaults   = mainnetGenesisVaults
+)
+
+// GenesisAccounts are the accounts of the genesis ledger of the network, without the
+// vaults.
+func GenesisAccounts() []GenesisAccount {
+	return genesisAccounts
+}
+
+func GenesisVaults() []GenesisVault {
+	return genesisVaults
+}
+
+var mainnetGenesisVaults = []GenesisVault{
+	{Address: "sm1qqqqqqylyl2l0zsmmax0wnutt4dwnrkcwef5eeq3xladz", Amount: 2743200000000000},
+	{Address: "sm1qqqqqqyp8ueuuh2dgrc2g6ps4xvueyjpky6rfaqnxdy97", Amount: 5867100000000000},
+	{Address: "sm1qqqqqqzgmt5vv4jgucas8vvrlu4daa4r29cunwqpv0trt", Amount: 1022800000000000},
+	{Address: "sm1qqqqqq80we5pmwztmqgpxu6xasapgn65r4xjczqxu39a2", Amount: 409000000000000},
+	{Address: "sm1qqqqqqy6anfdew2sdtvuuaffjy0l7ssu9r8vjsss5c442", Amount: 2045400000000000},
+	{Address: "sm1qqqqqqyw9lvmmayckrxlnf8u7850tsjdg8zz6dg956gxg", Amount: 270600000000000},
+	{Address: "sm1qqqqqq9a8g5act6ewmmmmmux8l570kr6l68htzsq94wg4", Amount: 4090900000000000},
+	{Address: "sm1qqqqqqrgqc65x5q6exujgjs970fvcakd790na3gsr3uu7", Amount: 333300000000000},
+	{Address: "sm1qqqqqqpc4ppx8s4gmdaa5tzg35s6l3v6ujg6hmqz3s4lc", Amount: 859100000000000},
+	{Address: "sm1qqqqqq8za0geafhj4avegdwhtaw9fmgjh07s55cufk695", Amount: 293300000000000},
+	{Address: "sm1qqqqqqpf6djx3axy7aag8zhyf84ljsulhfypfxgpw5y0u", Amount: 1990600000000000},
+	{Address: "sm1qqqqqq827v998nt99vupxlrfucdk0tapp2hjyygmn3kyd", Amount: 409100000000000},
+	{Address: "sm1qqqqqqpc55ghjq6sxf5k77yc8n82fkwhlj0jedcgw2zck", Amount: 4909100000000000},
+	{Address: "sm1qqqqqqxq54zvz484hhcnrghnqrjlw26twwld32slz3lxa", Amount: 191800000000000},
+	{Address: "sm1qqqqqqyf5uc2n8mutm3tuateu5efcm9awvrclmcm5mhdf", Amount: 2933540000000000},
+	{Address: "sm1qqqqqq99klpy92mwlfcft5lmz8q5sef2v2qvtucd9y55v", Amount: 2933540000000000},
+	{Address: "sm1qqqqqqyjpjgup8fz32cufcv2nlqrr3nyvge7akqt0daea", Amount: 2933540000000000},
+	{Address: "sm1qqqqqq8zukfwtggnfq4jaqpv6m8xgtg5ay2ezaqpr2w6y", Amount: 2933540000000000},
+	{Address: "sm1qqqqqqrhftrq9knsetema7dt0qfzgd5a20m9rcczk0gk5", Amount: 2933540000000000},
+	{Address: "sm1qqqqqqyfq5f522mmrzs4lczhaf30jh4pmqyfrzcg8vrpc", Amount: 3303792000000000},
+	{Address: "sm1qqqqqqx55z5795569fq5kym3gw2h6zp6ajeh46c5wtrzf", Amount: 455300000000000},
+	{Address: "sm1qqqqqqyvet26gqsxjt6w50nnp80jvajr3n25xzsdpxn65", Amount: 831250000000000},
+	{Address: "sm1qqqqqqzgqpjxdw77aw74f8mz540rykda4x2jgjgaca7z5", Amount: 184375000000000},
+	{Address: "sm1qqqqqq9s5l9tc87wspycr68dfagmzxplzdn7zlcymnkup", Amount: 15000000000000},
+	{Address: "sm1qqqqqqptx3mdg4gm67arv4ykau6nfy6w9v03x9s49wmru", Amount: 100000000000000},
+	{Address: "sm1qqqqqq9fwfymdr7qv0tfc3ppa4q8ara6qm7kwugw9gdme", Amount: 500000000000000},
+	{Address: "sm1qqqqqqy3fc8nvdetan6qjz5cju7h4c60mjyvdlqnlqpxu", Amount: 15688500000000000},
+	{Address: "sm1qqqqqqrt64knhuxu3kzq50ak04nrkk9yf2zxprshmvkcy", Amount: 88818783000000000},
+}
 
 func VaultAccounts() []string {
-	return []string{
-		"sm1qqqqqqylyl2l0zsmmax0wnutt4dwnrkcwef5eeq3xladz",
-		"sm1qqqqqqyp8ueuuh2dgrc2g6ps4xvueyjpky6rfaqnxdy97",
-		"sm1qqqqqqzgmt5vv4jgucas8vvrlu4daa4r29cunwqpv0trt",
-		"sm1qqqqqq80we5pmwztmqgpxu6xasapgn65r4xjczqxu39a2",
-		"sm1qqqqqqy6anfdew2sdtvuuaffjy0l7ssu9r8vjsss5c442",
-		"sm1qqqqqqyw9lvmmayckrxlnf8u7850tsjdg8zz6dg956gxg",
-		"sm1qqqqqq9a8g5act6ewmmmmmux8l570kr6l68htzsq94wg4",
-		"sm1qqqqqqrgqc65x5q6exujgjs970fvcakd790na3gsr3uu7",
-		"sm1qqqqqqpc4ppx8s4gmdaa5tzg35s6l3v6ujg6hmqz3s4lc",
-		"sm1qqqqqq8za0geafhj4avegdwhtaw9fmgjh07s55cufk695",
-		"sm1qqqqqqpf6djx3axy7aag8zhyf84ljsulhfypfxgpw5y0u",
-		"sm1qqqqqq827v998nt99vupxlrfucdk0tapp2hjyygmn3kyd",
-		"sm1qqqqqqpc55ghjq6sxf5k77yc8n82fkwhlj0jedcgw2zck",
-		"sm1qqqqqqxq54zvz484hhcnrghnqrjlw26twwld32slz3lxa",
-		"sm1qqqqqqyf5uc2n8mutm3tuateu5efcm9awvrclmcm5mhdf",
-		"sm1qqqqqq99klpy92mwlfcft5lmz8q5sef2v2qvtucd9y55v",
-		"sm1qqqqqqyjpjgup8fz32cufcv2nlqrr3nyvge7akqt0daea",
-		"sm1qqqqqq8zukfwtggnfq4jaqpv6m8xgtg5ay2ezaqpr2w6y",
-		"sm1qqqqqqrhftrq9knsetema7dt0qfzgd5a20m9rcczk0gk5",
-		"sm1qqqqqqyfq5f522mmrzs4lczhaf30jh4pmqyfrzcg8vrpc",
-		"sm1qqqqqqx55z5795569fq5kym3gw2h6zp6ajeh46c5wtrzf",
-		"sm1qqqqqqyvet26gqsxjt6w50nnp80jvajr3n25xzsdpxn65",
-		"sm1qqqqqqzgqpjxdw77aw74f8mz540rykda4x2jgjgaca7z5",
-		"sm1qqqqqq9s5l9tc87wspycr68dfagmzxplzdn7zlcymnkup",
-		"sm1qqqqqqptx3mdg4gm67arv4ykau6nfy6w9v03x9s49wmru",
-		"sm1qqqqqq9fwfymdr7qv0tfc3ppa4q8ara6qm7kwugw9gdme",
-		"sm1qqqqqqy3fc8nvdetan6qjz5cju7h4c60mjyvdlqnlqpxu",
-		"sm1qqqqqqrt64knhuxu3kzq50ak04nrkk9yf2zxprshmvkcy"}
+	vaults := GenesisVaults()
+	accounts := make([]string, len(vaults))
+	for i, v := range vaults {
+		accounts[i] = v.Address
+	}
+	return accounts
 }
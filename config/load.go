@@ -0,0 +1,308 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix starts the environment variables that override config fields.
+const EnvPrefix = "SPACEMESH_"
+
+// Load reads the config with the following precedence, later ones win:
+//
+//  1. the file at path, yaml with a .yaml or .yml extension and json otherwise, skipped
+//     when path is empty
+//  2. environment variables, SPACEMESH_ followed by the json path of the field in upper
+//     snake case, like SPACEMESH_DB_URI for db.uri or SPACEMESH_NATS_INSTANCE_ID for
+//     nats.instanceId
+//  3. the overrides, path=value with the json path like db.uri=mongodb://localhost:27017
+//
+// Values are parsed by the type of the field. Lists and objects take json, a list of
+// strings also takes comma separated values. Optional sections left out are filled with
+// their defaults and the result is validated, all invalid fields are reported together.
+func Load(path string, overrides []string) (*Config, error) {
+	configValues := &Config{}
+	if path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		if err = decodeFile(file, filepath.Ext(path), configValues); err != nil {
+			return nil, fmt.Errorf("invalid config %s: %w", path, err)
+		}
+	}
+
+	for _, field := range fieldPaths(reflect.TypeOf(Config{}), "") {
+		value, exists := os.LookupEnv(EnvName(field))
+		if !exists {
+			continue
+		}
+		if err := Set(configValues, field, value); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", EnvName(field), err)
+		}
+	}
+
+	for _, override := range overrides {
+		field, value, found := strings.Cut(override, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid override %s, must be path=value", override)
+		}
+		if err := Set(configValues, field, value); err != nil {
+			return nil, fmt.Errorf("invalid override %s: %w", field, err)
+		}
+	}
+
+	setDefaults(configValues)
+	if err := Validate(configValues); err != nil {
+		return nil, fmt.Errorf("invalid config:\n%w", err)
+	}
+	return configValues, nil
+}
+
+// decodeFile reads json, yaml is converted to json first so both formats use the json
+// field names.
+func decodeFile(file io.Reader, extension string, configValues *Config) error {
+	if extension != ".yaml" && extension != ".yml" {
+		return json.NewDecoder(file).Decode(configValues)
+	}
+	var values interface{}
+	if err := yaml.NewDecoder(file).Decode(&values); err != nil && err != io.EOF {
+		return err
+	}
+	if values == nil {
+		return nil
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, configValues)
+}
+
+// setDefaults fills the sections left out, so no part of the connector has to check for
+// them, and the settings that no constructor defaults. Intervals and sizes keep their
+// defaults in the components that use them.
+func setDefaults(configValues *Config) {
+	if configValues.Mode == "" {
+		configValues.Mode = ModeAll
+	}
+	if configValues.Network == nil {
+		configValues.Network = &NetworkConfig{}
+	}
+	if configValues.Network.Name == "" {
+		configValues.Network.Name = MainnetName
+	}
+	fillNetwork(configValues.Network)
+	if configValues.Server == nil {
+		configValues.Server = &ServerConfig{}
+	}
+	if configValues.Server.Port == "" {
+		configValues.Server.Port = ":8080"
+	}
+	if configValues.DB == nil {
+		configValues.DB = &DBConfig{}
+	}
+	if configValues.DB.Backend == "" {
+		configValues.DB.Backend = "mongo"
+	}
+	if configValues.DB.Database == "" {
+		configValues.DB.Database = "spacemesh"
+		if configValues.Network.Name != MainnetName {
+			configValues.DB.Database = "spacemesh_" + configValues.Network.Name
+		}
+	}
+	if configValues.DB.Mongo == nil {
+		configValues.DB.Mongo = &MongoConfig{}
+	}
+	if configValues.Nats == nil {
+		configValues.Nats = &NatsConfig{}
+	}
+	if configValues.Price == nil {
+		configValues.Price = &PriceConfig{}
+	}
+	if configValues.Poets == nil {
+		configValues.Poets = make([]*PoetConfig, 0)
+	}
+	if configValues.Aggregation == nil {
+		configValues.Aggregation = &AggregationConfig{}
+	}
+	if configValues.Sync == nil {
+		configValues.Sync = &SyncConfig{}
+	}
+	if configValues.Stats == nil {
+		configValues.Stats = &StatsConfig{}
+	}
+	if configValues.ClickHouse == nil {
+		configValues.ClickHouse = &ClickHouseConfig{}
+	}
+	if configValues.Retention == nil {
+		configValues.Retention = &RetentionConfig{}
+	}
+	if configValues.History == nil {
+		configValues.History = &HistoryConfig{}
+	}
+	if configValues.State == nil {
+		configValues.State = &StateConfig{}
+	}
+	if configValues.Consistency == nil {
+		configValues.Consistency = &ConsistencyConfig{}
+	}
+	if configValues.Admin == nil {
+		configValues.Admin = &AdminConfig{}
+	}
+	if configValues.Tracing == nil {
+		configValues.Tracing = &TracingConfig{}
+	}
+	if configValues.Reload == nil {
+		configValues.Reload = &ReloadConfig{}
+	}
+	if configValues.PoetHealth == nil {
+		configValues.PoetHealth = &PoetHealthConfig{}
+	}
+	if configValues.Node == nil {
+		configValues.Node = &NodeConfig{}
+	}
+	if configValues.Pending == nil {
+		configValues.Pending = &PendingConfig{}
+	}
+	if configValues.Retry == nil {
+		configValues.Retry = &RetryConfig{}
+	}
+}
+
+// EnvName returns the environment variable of the json path of a field.
+func EnvName(path string) string {
+	var name strings.Builder
+	name.WriteString(EnvPrefix)
+	for i, r := range path {
+		switch {
+		case r == '.' || r == '-':
+			name.WriteRune('_')
+		case unicode.IsUpper(r) && i > 0 && path[i-1] != '.':
+			name.WriteRune('_')
+			name.WriteRune(r)
+		default:
+			name.WriteRune(unicode.ToUpper(r))
+		}
+	}
+	return name.String()
+}
+
+// fieldPaths lists the json paths of every field that holds a value, nested sections
+// are walked and lists and maps are leaves.
+func fieldPaths(t reflect.Type, prefix string) []string {
+	paths := make([]string, 0)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := jsonName(field)
+		if name == "" {
+			continue
+		}
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+		if fieldType.Kind() == reflect.Struct {
+			paths = append(paths, fieldPaths(fieldType, prefix+name+".")...)
+			continue
+		}
+		paths = append(paths, prefix+name)
+	}
+	return paths
+}
+
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "-" || !field.IsExported() {
+		return ""
+	}
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+// Set parses value into the field at the json path, creating the sections on the way.
+func Set(configValues *Config, path string, value string) error {
+	current := reflect.ValueOf(configValues).Elem()
+	for _, name := range strings.Split(path, ".") {
+		if current.Kind() == reflect.Pointer {
+			if current.IsNil() {
+				current.Set(reflect.New(current.Type().Elem()))
+			}
+			current = current.Elem()
+		}
+		if current.Kind() != reflect.Struct {
+			return fmt.Errorf("unknown field %s", path)
+		}
+		next, found := structField(current, name)
+		if !found {
+			return fmt.Errorf("unknown field %s", path)
+		}
+		current = next
+	}
+	return parseInto(current, value)
+}
+
+func structField(v reflect.Value, name string) (reflect.Value, bool) {
+	for i := 0; i < v.NumField(); i++ {
+		if jsonName(v.Type().Field(i)) == name {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+func parseInto(field reflect.Value, value string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(parsed)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(parsed)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		parsed, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(parsed)
+	case reflect.Float32, reflect.Float64:
+		parsed, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(parsed)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() == reflect.String && !strings.HasPrefix(strings.TrimSpace(value), "[") {
+			values := strings.Split(value, ",")
+			for i := range values {
+				values[i] = strings.TrimSpace(values[i])
+			}
+			field.Set(reflect.ValueOf(values))
+			return nil
+		}
+		return json.Unmarshal([]byte(value), field.Addr().Interface())
+	default:
+		return json.Unmarshal([]byte(value), field.Addr().Interface())
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+const MainnetName = "mainnet"
+
+// Network parameters, mainnet until ApplyNetwork sets the configured network at start.
+// They are read everywhere without locks and must not change after the start.
+var (
+	NetworkName                = MainnetName
+	GenesisId                  = ""
+	GenesisEpochSeconds int64  = 1689321600
+	LayerDuration       int64  = 300
+	LayersPerEpoch      uint32 = 4032
+	NetworkHrp                 = "sm"
+)
+
+// KnownNetworks holds the parameters of the public networks, from the go-spacemesh
+// presets.
+var KnownNetworks = map[string]NetworkConfig{
+	MainnetName: {
+		Name:           MainnetName,
+		GenesisTime:    "2023-07-14T08:00:00Z",
+		ExtraData:      "00000000000000000001a6bc150307b5c1998045752b3c87eccf3c013036f3cc",
+		LayerDuration:  300,
+		LayersPerEpoch: 4032,
+		Hrp:            "sm",
+		GenesisVaults:  mainnetGenesisVaults,
+	},
+	"testnet": {
+		Name:           "testnet",
+		GenesisTime:    "2023-09-13T18:00:00Z",
+		ExtraData:      "0000000000000000000000c76c58ebac180989673fd6d237b40e66ed5c976ec3",
+		LayerDuration:  300,
+		LayersPerEpoch: 288,
+		Hrp:            "stest",
+	},
+}
+
+// fillNetwork sets the parameters the config leaves empty from the known network of
+// the same name.
+func fillNetwork(networkConfig *NetworkConfig) {
+	known, exists := KnownNetworks[networkConfig.Name]
+	if !exists {
+		return
+	}
+	if networkConfig.GenesisTime == "" {
+		networkConfig.GenesisTime = known.GenesisTime
+	}
+	if networkConfig.ExtraData == "" {
+		networkConfig.ExtraData = known.ExtraData
+	}
+	if networkConfig.LayerDuration == 0 {
+		networkConfig.LayerDuration = known.LayerDuration
+	}
+	if networkConfig.LayersPerEpoch == 0 {
+		networkConfig.LayersPerEpoch = known.LayersPerEpoch
+	}
+	if networkConfig.Hrp == "" {
+		networkConfig.Hrp = known.Hrp
+	}
+	if len(networkConfig.GenesisAccounts) == 0 && len(networkConfig.GenesisVaults) == 0 {
+		networkConfig.GenesisAccounts = known.GenesisAccounts
+		networkConfig.GenesisVaults = known.GenesisVaults
+	}
+}
+
+func validateNetwork(networkConfig *NetworkConfig, invalid func(path string, format string, args ...interface{})) {
+	if networkConfig.GenesisTime == "" {
+		invalid("network.genesisTime", "is required for network %s", networkConfig.Name)
+	} else if _, err := time.Parse(time.RFC3339, networkConfig.GenesisTime); err != nil {
+		invalid("network.genesisTime", "must be an RFC 3339 time, got %q", networkConfig.GenesisTime)
+	}
+	if networkConfig.LayerDuration == 0 {
+		invalid("network.layerDuration", "is required for network %s", networkConfig.Name)
+	}
+	if networkConfig.LayersPerEpoch == 0 {
+		invalid("network.layersPerEpoch", "is required for network %s", networkConfig.Name)
+	}
+	if networkConfig.Hrp == "" {
+		invalid("network.hrp", "is required for network %s", networkConfig.Name)
+	}
+	ledger := make(map[string]bool)
+	checkLedger := func(path string, address string) {
+		if address == "" {
+			invalid(path, "is required")
+		} else if ledger[address] {
+			invalid(path, "%s is already in the genesis ledger", address)
+		}
+		ledger[address] = true
+	}
+	for i, account := range networkConfig.GenesisAccounts {
+		checkLedger(fmt.Sprintf("network.genesisAccounts[%d].address", i), account.Address)
+	}
+	for i, vault := range networkConfig.GenesisVaults {
+		checkLedger(fmt.Sprintf("network.genesisVaults[%d].address", i), vault.Address)
+	}
+}
+
+// ApplyNetwork sets the network parameters from a loaded config, before any of them is
+// read.
+func ApplyNetwork(networkConfig *NetworkConfig) error {
+	genesis, err := time.Parse(time.RFC3339, networkConfig.GenesisTime)
+	if err != nil {
+		return fmt.Errorf("invalid genesis time %s: %w", networkConfig.GenesisTime, err)
+	}
+	NetworkName = networkConfig.Name
+	GenesisEpochSeconds = genesis.Unix()
+	LayerDuration = int64(networkConfig.LayerDuration)
+	LayersPerEpoch = uint32(networkConfig.LayersPerEpoch)
+	NetworkHrp = networkConfig.Hrp
+	GenesisId = networkConfig.GenesisId
+	genesisAccounts = networkConfig.GenesisAccounts
+	genesisVaults = networkConfig.GenesisVaults
+	return nil
+}
@@ -0,0 +1,151 @@
+package config
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Reloader loads the config again on SIGHUP and, with reload.watch, when the file
+// changes. Only the settings read while running are applied, the poets and the refresh
+// intervals. Sections read at start keep their running values, changing them is logged
+// as needing a restart so the nats consumers and connections are never recreated.
+type Reloader struct {
+	path      string
+	overrides []string
+	current   atomic.Pointer[Config]
+	mutex     sync.Mutex
+	modified  time.Time
+	listeners []func(*Config)
+}
+
+func NewReloader(path string, overrides []string, configValues *Config) *Reloader {
+	reloader := &Reloader{
+		path:      path,
+		overrides: overrides,
+	}
+	reloader.current.Store(configValues)
+	if info, err := os.Stat(path); err == nil {
+		reloader.modified = info.ModTime()
+	}
+	return reloader
+}
+
+// Current returns the last loaded config, it must not be modified.
+func (r *Reloader) Current() *Config {
+	return r.current.Load()
+}
+
+// OnReload registers a listener called with every reloaded config, listeners are called
+// one at a time in the order registered.
+func (r *Reloader) OnReload(listener func(*Config)) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.listeners = append(r.listeners, listener)
+}
+
+// Start reloads on SIGHUP and watches the file when enabled in the config.
+func (r *Reloader) Start() {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	go func() {
+		for range hangup {
+			log.Println("Received SIGHUP, reloading config")
+			r.Reload()
+		}
+	}()
+
+	reloadConfig := r.Current().Reload
+	if r.path == "" || !reloadConfig.Watch {
+		return
+	}
+	interval := 10
+	if reloadConfig.Interval > 0 {
+		interval = reloadConfig.Interval
+	}
+	ticker := time.NewTicker(time.Duration(interval) * time.Second)
+	go func() {
+		for range ticker.C {
+			info, err := os.Stat(r.path)
+			if err != nil {
+				log.Printf("Failed to check config %s: %s", r.path, err.Error())
+				continue
+			}
+			r.mutex.Lock()
+			changed := !info.ModTime().Equal(r.modified)
+			r.mutex.Unlock()
+			if changed {
+				log.Printf("Config %s changed, reloading", r.path)
+				r.Reload()
+			}
+		}
+	}()
+}
+
+// Reload loads the config and notifies the listeners, an invalid config is logged and
+// the running one is kept.
+func (r *Reloader) Reload() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if info, err := os.Stat(r.path); err == nil {
+		r.modified = info.ModTime()
+	}
+	configValues, err := Load(r.path, r.overrides)
+	if err != nil {
+		log.Printf("Failed to reload config, keeping the running one: %s", err.Error())
+		return err
+	}
+	if changed := keepRunning(r.Current(), configValues); len(changed) > 0 {
+		log.Printf("Changes to %s need a restart and are not applied", strings.Join(changed, ", "))
+	}
+	r.current.Store(configValues)
+	for _, listener := range r.listeners {
+		listener(configValues)
+	}
+	log.Println("Reloaded config")
+	return nil
+}
+
+// keepRunning copies into loaded the settings only read at start and returns the ones
+// that changed.
+func keepRunning(running *Config, loaded *Config) []string {
+	changed := make([]string, 0)
+	keep := func(name string, runningValue interface{}, loadedValue interface{}) {
+		target := reflect.ValueOf(loadedValue).Elem()
+		if !reflect.DeepEqual(reflect.ValueOf(runningValue).Elem().Interface(), target.Interface()) {
+			changed = append(changed, name)
+			target.Set(reflect.ValueOf(runningValue).Elem())
+		}
+	}
+	keep("mode", &running.Mode, &loaded.Mode)
+	keep("network", &running.Network, &loaded.Network)
+	keep("server", &running.Server, &loaded.Server)
+	keep("db", &running.DB, &loaded.DB)
+	keep("nats", &running.Nats, &loaded.Nats)
+	keep("sync", &running.Sync, &loaded.Sync)
+	keep("clickhouse", &running.ClickHouse, &loaded.ClickHouse)
+	keep("admin", &running.Admin, &loaded.Admin)
+	keep("tracing", &running.Tracing, &loaded.Tracing)
+	keep("reload", &running.Reload, &loaded.Reload)
+	keep("price.providers", &running.Price.Providers, &loaded.Price.Providers)
+	keep("price.currencies", &running.Price.Currencies, &loaded.Price.Currencies)
+	keep("state.disableRefresh", &running.State.DisableRefresh, &loaded.State.DisableRefresh)
+	keep("stats.enabled", &running.Stats.Enabled, &loaded.Stats.Enabled)
+	keep("history.enabled", &running.History.Enabled, &loaded.History.Enabled)
+	keep("retention.enabled", &running.Retention.Enabled, &loaded.Retention.Enabled)
+	keep("retention.dryRun", &running.Retention.DryRun, &loaded.Retention.DryRun)
+	keep("retention.policies", &running.Retention.Policies, &loaded.Retention.Policies)
+	keep("consistency.enabled", &running.Consistency.Enabled, &loaded.Consistency.Enabled)
+	keep("poetHealth.enabled", &running.PoetHealth.Enabled, &loaded.PoetHealth.Enabled)
+	keep("node.enabled", &running.Node.Enabled, &loaded.Node.Enabled)
+	keep("node.address", &running.Node.Address, &loaded.Node.Address)
+	keep("node.tls", &running.Node.Tls, &loaded.Node.Tls)
+	keep("node.maxReconnectDelay", &running.Node.MaxReconnectDelay, &loaded.Node.MaxReconnectDelay)
+	return changed
+}
@@ -0,0 +1,406 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/swarmbit/spacemesh-state-api/pkg/address"
+)
+
+var backends = []string{"mongo", "postgres", "sqlite"}
+
+var prunableCollections = []string{"rewards", "layers", "transactions", "blocks"}
+
+var compressionEncodings = []string{"zstd", "gzip"}
+
+var readPreferences = []string{"primary", "primaryPreferred", "secondary", "secondaryPreferred", "nearest"}
+
+// Validate reports every invalid field of the config at once, each error names the
+// json path of the field.
+func Validate(configValues *Config) error {
+	var errs []error
+	invalid := func(path string, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s", path, fmt.Sprintf(format, args...)))
+	}
+
+	for _, path := range negativeFields(reflect.ValueOf(configValues).Elem(), "") {
+		invalid(path, "must not be negative")
+	}
+
+	if configValues.Mode != "" && !oneOf(configValues.Mode, []string{ModeAll, ModeSink, ModeApi}) {
+		invalid("mode", "must be %s, %s or %s, got %q", ModeAll, ModeSink, ModeApi, configValues.Mode)
+	}
+
+	if configValues.Network != nil {
+		validateNetwork(configValues.Network, invalid)
+	}
+
+	if configValues.Server == nil || configValues.Server.Port == "" {
+		invalid("server.port", "is required")
+	} else if err := validAddress(configValues.Server.Port); err != nil {
+		invalid("server.port", "%s", err)
+	}
+
+	if configValues.Server != nil && configValues.Server.Compression != nil {
+		compression := configValues.Server.Compression
+		for i, encoding := range compression.Encodings {
+			if !oneOf(encoding, compressionEncodings) {
+				invalid(fmt.Sprintf("server.compression.encodings[%d]", i), "must be one of %s, got %q", strings.Join(compressionEncodings, ", "), encoding)
+			}
+		}
+		if compression.Level > 9 {
+			invalid("server.compression.level", "must be at most 9, got %d", compression.Level)
+		}
+	}
+
+	if configValues.Server != nil && configValues.Server.Tls != nil && configValues.Server.Tls.Enabled {
+		tlsConfig := configValues.Server.Tls
+		switch {
+		case tlsConfig.Autocert && len(tlsConfig.Domains) == 0:
+			invalid("server.tls.domains", "is required with autocert")
+		case tlsConfig.Autocert && (tlsConfig.CertFile != "" || tlsConfig.KeyFile != ""):
+			invalid("server.tls.certFile", "must be empty with autocert")
+		case !tlsConfig.Autocert && (tlsConfig.CertFile == "" || tlsConfig.KeyFile == ""):
+			invalid("server.tls", "certFile and keyFile are required without autocert")
+		}
+		if tlsConfig.Autocert && tlsConfig.HttpPort != "" {
+			if err := validAddress(tlsConfig.HttpPort); err != nil {
+				invalid("server.tls.httpPort", "%s", err)
+			}
+		}
+	}
+
+	if configValues.Server != nil && configValues.Server.Docs != nil && configValues.Server.Docs.AssetsUrl != "" {
+		if err := validURI(configValues.Server.Docs.AssetsUrl, "http", "https"); err != nil {
+			invalid("server.docs.assetsUrl", "%s", err)
+		}
+	}
+	if configValues.Server != nil && configValues.Server.Docs != nil {
+		for file, hash := range configValues.Server.Docs.Integrity {
+			if !oneOf(file, DocsAssets) {
+				invalid("server.docs.integrity", "keys must be one of %s, got %q", strings.Join(DocsAssets, ", "), file)
+			}
+			if !strings.HasPrefix(hash, "sha256-") && !strings.HasPrefix(hash, "sha384-") && !strings.HasPrefix(hash, "sha512-") {
+				invalid("server.docs.integrity."+file, "must be a sha256, sha384 or sha512 integrity hash")
+			}
+		}
+	}
+	if configValues.Server != nil && configValues.Server.Docs != nil && configValues.Server.Docs.ServerUrl != "" {
+		if err := validURI(configValues.Server.Docs.ServerUrl, "http", "https"); err != nil {
+			invalid("server.docs.serverUrl", "%s", err)
+		}
+	}
+
+	if configValues.Server != nil && configValues.Server.AccessLog != nil && configValues.Server.AccessLog.Enabled {
+		accessLog := configValues.Server.AccessLog
+		if accessLog.SampleRate > 1 {
+			invalid("server.accessLog.sampleRate", "must be at most 1, got %v", accessLog.SampleRate)
+		}
+		if accessLog.SlowOnly && accessLog.SlowThreshold == 0 {
+			invalid("server.accessLog.slowThreshold", "is required with slowOnly")
+		}
+	}
+
+	if configValues.DB == nil || configValues.DB.Uri == "" {
+		invalid("db.uri", "is required")
+	} else {
+		backend := configValues.DB.Backend
+		switch {
+		case backend != "" && !oneOf(backend, backends):
+			invalid("db.backend", "must be one of %s, got %q", strings.Join(backends, ", "), backend)
+		case backend == "" || backend == "mongo":
+			if err := validURI(configValues.DB.Uri, "mongodb", "mongodb+srv"); err != nil {
+				invalid("db.uri", "%s", err)
+			}
+		case backend == "postgres" && strings.Contains(configValues.DB.Uri, "://"):
+			// lib/pq also takes key=value connection strings
+			if err := validURI(configValues.DB.Uri, "postgres", "postgresql"); err != nil {
+				invalid("db.uri", "%s", err)
+			}
+		}
+	}
+	if configValues.DB != nil && configValues.DB.Mongo != nil {
+		mongoConfig := configValues.DB.Mongo
+		if mongoConfig.ReadPreference != "" && !oneOf(mongoConfig.ReadPreference, readPreferences) {
+			invalid("db.mongo.readPreference", "must be one of %s, got %q", strings.Join(readPreferences, ", "), mongoConfig.ReadPreference)
+		}
+		if _, err := strconv.Atoi(mongoConfig.WriteConcern); err != nil && mongoConfig.WriteConcern != "" && mongoConfig.WriteConcern != "majority" {
+			invalid("db.mongo.writeConcern", "must be majority or a number of nodes, got %q", mongoConfig.WriteConcern)
+		}
+		if mongoConfig.MaxPoolSize > 0 && mongoConfig.MinPoolSize > mongoConfig.MaxPoolSize {
+			invalid("db.mongo.minPoolSize", "must not be above maxPoolSize %d", mongoConfig.MaxPoolSize)
+		}
+	}
+
+	if configValues.Nats != nil && configValues.Nats.Enabled {
+		if configValues.Nats.Uri == "" {
+			invalid("nats.uri", "is required when nats is enabled")
+		}
+		// the client takes a comma separated list of servers
+		for _, uri := range strings.Split(configValues.Nats.Uri, ",") {
+			if uri = strings.TrimSpace(uri); uri == "" {
+				continue
+			}
+			if err := validURI(uri, "nats", "tls", "ws", "wss"); err != nil {
+				invalid("nats.uri", "%s", err)
+			}
+		}
+	}
+
+	if configValues.ClickHouse != nil && configValues.ClickHouse.Enabled {
+		if err := validURI(configValues.ClickHouse.Uri, "http", "https"); err != nil {
+			invalid("clickhouse.uri", "%s", err)
+		}
+	}
+
+	if configValues.State != nil && configValues.State.HighestAtx != nil {
+		strategy := configValues.State.HighestAtx.Strategy
+		if strategy != "" && !oneOf(strategy, []string{HighestAtxHonest, HighestAtxAny}) {
+			invalid("state.highestAtx.strategy", "must be %s or %s, got %q", HighestAtxHonest, HighestAtxAny, strategy)
+		}
+		if configValues.State.HighestAtx.Rank > MaxHighestAtxRank {
+			invalid("state.highestAtx.rank", "must be at most %d, got %d", MaxHighestAtxRank, configValues.State.HighestAtx.Rank)
+		}
+	}
+
+	if configValues.Retention != nil {
+		for i, policy := range configValues.Retention.Policies {
+			path := fmt.Sprintf("retention.policies[%d]", i)
+			if policy == nil {
+				invalid(path, "is empty")
+				continue
+			}
+			if !oneOf(policy.Collection, prunableCollections) {
+				invalid(path+".collection", "must be one of %s, got %q", strings.Join(prunableCollections, ", "), policy.Collection)
+			}
+			if policy.KeepEpochs == 0 {
+				invalid(path+".keepEpochs", "must be above 0")
+			}
+		}
+	}
+
+	if configValues.Admin != nil && configValues.Admin.Enabled && configValues.Admin.ApiKey == "" {
+		invalid("admin.apiKey", "is required when admin is enabled")
+	}
+
+	if configValues.Webhooks != nil && configValues.Webhooks.Enabled && len(configValues.Webhooks.ApiKeys) == 0 {
+		invalid("webhooks.apiKeys", "is required when webhooks are enabled")
+	}
+
+	if configValues.Watchlists != nil && configValues.Watchlists.Enabled && len(configValues.Watchlists.ApiKeys) == 0 {
+		invalid("watchlists.apiKeys", "is required when watchlists are enabled")
+	}
+
+	if pool := configValues.Pool; pool != nil && pool.Enabled {
+		if len(pool.ApiKeys) == 0 {
+			invalid("pool.apiKeys", "is required when the pool is enabled")
+		}
+		if len(pool.Members) == 0 {
+			invalid("pool.members", "is required when the pool is enabled")
+		}
+		shares := 0.0
+		for i, member := range pool.Members {
+			path := fmt.Sprintf("pool.members[%d]", i)
+			if member == nil {
+				invalid(path, "is empty")
+				continue
+			}
+			if member.Name == "" {
+				invalid(path+".name", "is required")
+			}
+			if len(member.NodeIds) == 0 {
+				invalid(path+".nodeIds", "is required")
+			} else if _, err := address.NormalizeNodeIds(member.NodeIds); err != nil {
+				invalid(path+".nodeIds", "%s", err)
+			}
+			if member.Share < 0 || member.Share > 100 {
+				invalid(path+".share", "must be a percent between 0 and 100, got %v", member.Share)
+			}
+			shares += member.Share
+		}
+		if shares > 100 {
+			invalid("pool.members", "shares must add up to at most 100 percent, got %v", shares)
+		}
+		// the pool processor saves the rewards the payouts are computed from
+		if configValues.Processors == nil || !configValues.Processors.Enabled {
+			invalid("processors.enabled", "is required when the pool is enabled")
+		} else if names := configValues.Processors.Names; len(names) > 0 && !slices.Contains(names, "pool") {
+			invalid("processors.names", "must include pool when the pool is enabled")
+		}
+	}
+
+	if configValues.Api != nil {
+		for version := range configValues.Api.FieldNames {
+			if !oneOf(version, ApiVersions) {
+				invalid("api.fieldNames."+version, "must be one of %s", strings.Join(ApiVersions, ", "))
+			}
+		}
+	}
+
+	if emails := configValues.Emails; emails != nil && emails.Enabled {
+		if configValues.Watchlists == nil || !configValues.Watchlists.Enabled {
+			invalid("emails", "needs watchlists enabled, the digests are sent for watchlists")
+		}
+		if configValues.Digests == nil || !configValues.Digests.Enabled {
+			invalid("emails", "needs digests enabled, the rewards are read from the daily digests")
+		}
+		if err := validURI(emails.BaseUrl, "http", "https"); err != nil {
+			invalid("emails.baseUrl", "%s", err)
+		}
+		smtp := configValues.Smtp
+		if smtp == nil || smtp.Host == "" || smtp.From == "" {
+			invalid("smtp", "needs host and from when emails are enabled")
+		} else {
+			if smtp.Port <= 0 || smtp.Port > 65535 {
+				invalid("smtp.port", "must be between 1 and 65535, got %d", smtp.Port)
+			}
+			if _, err := mail.ParseAddress(smtp.From); err != nil {
+				invalid("smtp.from", "must be an email address: %s", err)
+			}
+		}
+	}
+
+	if alerts := configValues.Alerts; alerts != nil && alerts.Enabled {
+		if alerts.Telegram == nil && alerts.Discord == nil {
+			invalid("alerts", "needs telegram or discord when alerts are enabled")
+		}
+		if alerts.Telegram != nil && (alerts.Telegram.BotToken == "" || alerts.Telegram.ChatId == "") {
+			invalid("alerts.telegram", "needs botToken and chatId")
+		}
+		if alerts.Discord != nil {
+			if err := validURI(alerts.Discord.WebhookUrl, "https"); err != nil {
+				invalid("alerts.discord.webhookUrl", "%s", err)
+			}
+		}
+		if alerts.AtxDeadline < 0 || alerts.AtxDeadline > 100 {
+			invalid("alerts.atxDeadline", "must be a percent between 0 and 100, got %d", alerts.AtxDeadline)
+		}
+	}
+
+	if configValues.Tracing != nil {
+		if configValues.Tracing.SampleRatio > 1 {
+			invalid("tracing.sampleRatio", "must be between 0 and 1, got %v", configValues.Tracing.SampleRatio)
+		}
+		if configValues.Tracing.Endpoint != "" {
+			if err := validAddress(configValues.Tracing.Endpoint); err != nil {
+				invalid("tracing.endpoint", "%s", err)
+			}
+		}
+	}
+
+	if configValues.Price != nil {
+		for i, currency := range configValues.Price.Currencies {
+			if len(currency) != 3 {
+				invalid(fmt.Sprintf("price.currencies[%d]", i), "must be a 3 letter currency code, got %q", currency)
+			}
+		}
+		for i, provider := range configValues.Price.Providers {
+			if provider == nil || provider.Name == "" {
+				invalid(fmt.Sprintf("price.providers[%d].name", i), "is required")
+			}
+		}
+	}
+
+	if configValues.Node != nil && configValues.Node.Enabled {
+		if configValues.Node.Address == "" {
+			invalid("node.address", "is required when the node is enabled")
+		} else if err := validAddress(configValues.Node.Address); err != nil {
+			invalid("node.address", "%s", err)
+		}
+	}
+
+	for i, poet := range configValues.Poets {
+		if poet == nil || poet.Name == "" {
+			invalid(fmt.Sprintf("poets[%d].name", i), "is required")
+			continue
+		}
+		if poet.Address != "" {
+			if err := validURI(poet.Address, "http", "https"); err != nil {
+				invalid(fmt.Sprintf("poets[%d].address", i), "%s", err)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// negativeFields lists the json paths of the numbers below 0, no setting takes them.
+func negativeFields(v reflect.Value, prefix string) []string {
+	paths := make([]string, 0)
+	switch v.Kind() {
+	case reflect.Pointer:
+		if !v.IsNil() {
+			paths = append(paths, negativeFields(v.Elem(), prefix)...)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			name := jsonName(v.Type().Field(i))
+			if name == "" {
+				continue
+			}
+			path := name
+			if prefix != "" {
+				path = prefix + "." + name
+			}
+			paths = append(paths, negativeFields(v.Field(i), path)...)
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			paths = append(paths, negativeFields(v.Index(i), fmt.Sprintf("%s[%d]", prefix, i))...)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.Int() < 0 {
+			paths = append(paths, prefix)
+		}
+	case reflect.Float32, reflect.Float64:
+		if v.Float() < 0 {
+			paths = append(paths, prefix)
+		}
+	}
+	return paths
+}
+
+func validURI(uri string, schemes ...string) error {
+	if uri == "" {
+		return errors.New("is required")
+	}
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return fmt.Errorf("invalid uri %q: %w", uri, err)
+	}
+	if !oneOf(parsed.Scheme, schemes) {
+		return fmt.Errorf("uri %q must start with %s://", uri, strings.Join(schemes, ":// or "))
+	}
+	if parsed.Host == "" {
+		return fmt.Errorf("uri %q has no host", uri)
+	}
+	return nil
+}
+
+// validAddress checks a listen or dial address, host:port with an optional host.
+func validAddress(address string) error {
+	_, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("invalid address %q, must be host:port or :port", address)
+	}
+	if number, err := strconv.Atoi(port); err != nil || number < 0 || number > 65535 {
+		return fmt.Errorf("invalid port in %q", address)
+	}
+	return nil
+}
+
+func oneOf(value string, values []string) bool {
+	for _, v := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
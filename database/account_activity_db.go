@@ -0,0 +1,100 @@
+package database
+
+import (
+    "context"
+
+    sTypes "github.com/spacemeshos/go-spacemesh/common/types"
+    "github.com/swarmbit/spacemesh-state-api/migrations"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// accountActivityVersion is the schema migration that computes the activity of the
+// accounts saved before the sink maintained it.
+const accountActivityVersion = 3
+
+// aggregateAccountActivity computes the first seen and last activity layers and the
+// transfer counts of every account from the stored rewards and successful transfers,
+// the way the sink counts them as it saves them. A transfer has a receiver, the sender
+// of a vault drain is the vault.
+func aggregateAccountActivity(db *mongo.Database) error {
+    ctx := context.TODO()
+    _, err := db.Collection(accountsCollection).UpdateMany(ctx, bson.D{}, bson.D{
+        {Key: "$unset", Value: bson.D{
+            {Key: "firstSeenLayer", Value: ""},
+            {Key: "lastActivityLayer", Value: ""},
+        }},
+        {Key: "$set", Value: bson.D{
+            {Key: "incomingTransfers", Value: 0},
+            {Key: "outgoingTransfers", Value: 0},
+        }},
+    })
+    if err != nil {
+        return err
+    }
+
+    transfers := bson.D{
+        {Key: "complete", Value: true},
+        {Key: "status", Value: uint8(sTypes.TransactionSuccess)},
+        {Key: "receiver_account", Value: bson.D{{Key: "$ne", Value: ""}}},
+    }
+    incoming := append(transfers, bson.E{Key: "amount", Value: bson.D{{Key: "$gt", Value: 0}}})
+    sender := bson.D{{Key: "$cond", Value: bson.A{
+        bson.D{{Key: "$ne", Value: bson.A{"$vault_account", ""}}}, "$vault_account", "$principal_account",
+    }}}
+    aggregations := []struct {
+        collection string
+        match      bson.D
+        account    interface{}
+        count      string
+    }{
+        {collection: rewardsCollection, match: bson.D{}, account: "$coinbase"},
+        {collection: transactionsCollection, match: incoming, account: "$receiver_account", count: "incomingTransfers"},
+        {collection: transactionsCollection, match: transfers, account: sender, count: "outgoingTransfers"},
+    }
+    for _, aggregation := range aggregations {
+        set := bson.D{
+            {Key: "firstSeenLayer", Value: bson.D{{Key: "$min", Value: bson.A{"$firstSeenLayer", "$$new.first"}}}},
+            {Key: "lastActivityLayer", Value: bson.D{{Key: "$max", Value: bson.A{"$lastActivityLayer", "$$new.last"}}}},
+        }
+        if aggregation.count != "" {
+            set = append(set, bson.E{Key: aggregation.count, Value: "$$new.count"})
+        }
+        pipeline := mongo.Pipeline{
+            {{Key: "$match", Value: aggregation.match}},
+            {{Key: "$group", Value: bson.D{
+                {Key: "_id", Value: aggregation.account},
+                {Key: "first", Value: bson.D{{Key: "$min", Value: "$layer"}}},
+                {Key: "last", Value: bson.D{{Key: "$max", Value: "$layer"}}},
+                {Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+            }}},
+            {{Key: "$merge", Value: bson.D{
+                {Key: "into", Value: accountsCollection},
+                {Key: "on", Value: "_id"},
+                {Key: "whenMatched", Value: mongo.Pipeline{{{Key: "$set", Value: set}}}},
+                {Key: "whenNotMatched", Value: "discard"},
+            }}},
+        }
+        cursor, err := db.Collection(aggregation.collection).Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+        if err != nil {
+            return err
+        }
+        cursor.Close(ctx)
+    }
+    return nil
+}
+
+// AccountActivityReady reports if the activity of the accounts was computed, until then
+// it only counts what the sink saved since it maintains it.
+func (m *ReadDB) AccountActivityReady() (bool, error) {
+    if m.activityReady.Load() {
+        return true, nil
+    }
+    applied, err := migrations.AppliedVersions(m.client.Database(database))
+    if err != nil {
+        return false, err
+    }
+    m.activityReady.Store(applied[accountActivityVersion])
+    return applied[accountActivityVersion], nil
+}
@@ -0,0 +1,91 @@
+package database
+
+import (
+    "context"
+
+    "github.com/swarmbit/spacemesh-state-api/types"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Sort fields of the epoch atx lists, the height is the base tick plus the tick count.
+const (
+    AtxSortEffectiveUnits = "effectiveUnits"
+    AtxSortHeight         = "height"
+)
+
+// GetAtx returns the atx by id, an empty document when it is not found.
+func (m *ReadDB) GetAtx(atxId string) (*types.AtxDoc, error) {
+    atxColl := m.client.Database(database).Collection(atxsCollection)
+    atxResult := atxColl.FindOne(
+        context.TODO(),
+        bson.D{{Key: "_id", Value: atxId}},
+    )
+    atxDoc := &types.AtxDoc{}
+    err := atxResult.Decode(atxDoc)
+    if err != nil {
+        if err == mongo.ErrNoDocuments {
+            return &types.AtxDoc{}, nil
+        }
+        return &types.AtxDoc{}, err
+    }
+    return atxDoc, nil
+}
+
+// GetPreviousAtx returns the last atx of the node published before epoch, an empty
+// document when it has none.
+func (m *ReadDB) GetPreviousAtx(nodeId string, epoch uint32) (*types.AtxDoc, error) {
+    atxColl := m.client.Database(database).Collection(atxsCollection)
+    atxResult := atxColl.FindOne(
+        context.TODO(),
+        bson.D{
+            {Key: "node_id", Value: nodeId},
+            {Key: "publishepoch", Value: bson.D{{Key: "$lt", Value: epoch}}},
+        },
+        options.FindOne().SetSort(bson.D{{Key: "publishepoch", Value: -1}}),
+    )
+    atxDoc := &types.AtxDoc{}
+    err := atxResult.Decode(atxDoc)
+    if err != nil {
+        if err == mongo.ErrNoDocuments {
+            return &types.AtxDoc{}, nil
+        }
+        return &types.AtxDoc{}, err
+    }
+    return atxDoc, nil
+}
+
+func (m *ReadDB) GetNodeAtxs(nodeId string, skip int64, limit int64, sort int8) ([]*types.AtxDoc, error) {
+    atxColl := m.client.Database(database).Collection(atxsCollection)
+
+    findOptions := options.Find()
+    findOptions.SetSkip(skip)
+    findOptions.SetLimit(limit)
+    findOptions.SetSort(bson.D{{Key: "publishepoch", Value: sort}})
+
+    ctx := context.TODO()
+    cursor, err := atxColl.Find(
+        ctx,
+        bson.D{{Key: "node_id", Value: nodeId}},
+        findOptions,
+    )
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    var atx []*types.AtxDoc
+    if err = cursor.All(ctx, &atx); err != nil {
+        return nil, err
+    }
+    return atx, nil
+}
+
+func (m *ReadDB) CountNodeAtxs(nodeId string) (int64, error) {
+    atxColl := m.client.Database(database).Collection(atxsCollection)
+    return atxColl.CountDocuments(
+        context.TODO(),
+        bson.D{{Key: "node_id", Value: nodeId}},
+    )
+}
@@ -0,0 +1,47 @@
+package database
+
+import (
+    "context"
+
+    "github.com/swarmbit/spacemesh-state-api/types"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const balanceChangesCollection = "balanceChanges"
+
+// incBalanceChange adds delta to the balance change of account in layer. ctx must be the
+// one withTransaction gives the write of the account balance, so both are saved together
+// where the server runs transactions.
+func (m *WriteDB) incBalanceChange(ctx context.Context, account string, layer uint32, delta int64) (*mongo.UpdateResult, error) {
+    changesColl := m.client.Database(database).Collection(balanceChangesCollection)
+    return changesColl.UpdateOne(
+        ctx,
+        bson.D{{Key: "_id", Value: types.BalanceChangeId{Account: account, Layer: layer}}},
+        bson.D{{Key: "$inc", Value: bson.D{{Key: "delta", Value: delta}}}},
+        options.Update().SetUpsert(true),
+    )
+}
+
+// GetBalanceChanges returns the balance changes of account by layer, oldest first.
+func (m *ReadDB) GetBalanceChanges(account string) ([]*types.BalanceChangeDoc, error) {
+    changesColl := m.client.Database(database).Collection(balanceChangesCollection)
+
+    ctx := context.TODO()
+    cursor, err := changesColl.Find(
+        ctx,
+        bson.D{{Key: "_id.account", Value: account}},
+        options.Find().SetSort(bson.D{{Key: "_id.layer", Value: 1}}),
+    )
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    var docs []*types.BalanceChangeDoc
+    if err = cursor.All(ctx, &docs); err != nil {
+        return nil, err
+    }
+    return docs, nil
+}
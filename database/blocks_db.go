@@ -0,0 +1,58 @@
+package database
+
+import (
+    "context"
+
+    "github.com/swarmbit/spacemesh-state-api/types"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const blocksCollection = "blocks"
+
+// countBlockTransaction adds a transaction result applied in blockId to its block, the
+// nodes do not publish blocks so they are only known from their transactions.
+func (m *WriteDB) countBlockTransaction(ctx context.Context, blockId string, layer uint32) error {
+    blocksColl := m.client.Database(database).Collection(blocksCollection)
+    _, err := blocksColl.UpdateOne(
+        ctx,
+        bson.D{{Key: "_id", Value: blockId}},
+        bson.D{
+            {Key: "$set", Value: bson.D{{Key: "layer", Value: int64(layer)}}},
+            {Key: "$inc", Value: bson.D{{Key: "transactions", Value: 1}}},
+        },
+        options.Update().SetUpsert(true),
+    )
+    return err
+}
+
+func (m *ReadDB) GetBlock(blockId string) (*types.BlockDoc, error) {
+    blocksColl := m.client.Database(database).Collection(blocksCollection)
+    blockDoc := &types.BlockDoc{}
+    err := blocksColl.FindOne(context.TODO(), bson.D{{Key: "_id", Value: blockId}}).Decode(blockDoc)
+    if err != nil {
+        if err == mongo.ErrNoDocuments {
+            return &types.BlockDoc{}, nil
+        }
+        return &types.BlockDoc{}, err
+    }
+    return blockDoc, nil
+}
+
+func (m *ReadDB) GetLayerBlocks(layer int) ([]*types.BlockDoc, error) {
+    blocksColl := m.client.Database(database).Collection(blocksCollection)
+
+    ctx := context.TODO()
+    cursor, err := blocksColl.Find(ctx, bson.D{{Key: "layer", Value: layer}}, options.Find().SetSort(bson.M{"_id": 1}))
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    blocks := make([]*types.BlockDoc, 0)
+    if err = cursor.All(ctx, &blocks); err != nil {
+        return nil, err
+    }
+    return blocks, nil
+}
@@ -0,0 +1,137 @@
+package database
+
+import (
+    "context"
+    "time"
+
+    "github.com/swarmbit/spacemesh-state-api/migrations"
+    "github.com/swarmbit/spacemesh-state-api/pkg/apperror"
+    "github.com/swarmbit/spacemesh-state-api/types"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const changesCollection = "changes"
+const countersCollection = "counters"
+
+const (
+    ChangeInsert = "insert"
+    ChangeUpdate = "update"
+)
+
+const (
+    EntityReward      = "reward"
+    EntityAtx         = "atx"
+    EntityTransaction = "transaction"
+    EntityLayer       = "layer"
+    EntityNode        = "node"
+    EntityAccount     = "account"
+)
+
+// EnableChangeFeed makes every save also append to the changes collection, entries
+// older than retention are dropped by mongo. The changes are written in the transaction
+// of the save they record, so the feed needs a server with transactions.
+func (m *WriteDB) EnableChangeFeed(retention time.Duration) error {
+    if !m.transactions {
+        return apperror.New(apperror.NotSupported, "the change feed needs a replica set or mongos")
+    }
+    err := migrations.EnsureIndexes(m.client.Database(database), []migrations.CollectionIndexes{
+        {Collection: changesCollection, Indexes: []mongo.IndexModel{{
+            Keys:    bson.D{{Key: "timestamp", Value: 1}},
+            Options: options.Index().SetExpireAfterSeconds(int32(retention.Seconds())),
+        }}},
+    })
+    if err != nil {
+        return err
+    }
+    m.changeFeed = true
+    return nil
+}
+
+func (m *WriteDB) nextSequence(ctx context.Context, name string) (int64, error) {
+    countersColl := m.client.Database(database).Collection(countersCollection)
+    result := countersColl.FindOneAndUpdate(
+        ctx,
+        bson.D{{Key: "_id", Value: name}},
+        bson.D{{Key: "$inc", Value: bson.D{{Key: "seq", Value: 1}}}},
+        options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+    )
+    counter := struct {
+        Seq int64 `bson:"seq"`
+    }{}
+    if err := result.Decode(&counter); err != nil {
+        return 0, err
+    }
+    return counter.Seq, nil
+}
+
+// recordChange appends a change to the feed. It runs in the transaction of the save it
+// records, ctx is the context of that transaction, so the sequence is only taken when
+// the change and the save are committed together and a failure fails the save.
+func (m *WriteDB) recordChange(ctx context.Context, entity string, operation string, documentId interface{}, document interface{}) error {
+    if !m.changeFeed {
+        return nil
+    }
+    sequence, err := m.nextSequence(ctx, changesCollection)
+    if err != nil {
+        return err
+    }
+    changesColl := m.client.Database(database).Collection(changesCollection)
+    _, err = changesColl.InsertOne(ctx, bson.D{
+        {Key: "_id", Value: sequence},
+        {Key: "entity", Value: entity},
+        {Key: "operation", Value: operation},
+        {Key: "documentId", Value: documentId},
+        {Key: "document", Value: document},
+        {Key: "timestamp", Value: time.Now()},
+    })
+    return err
+}
+
+// recordAccountChange records the state of an account after its balance changed in the
+// transaction of ctx.
+func (m *WriteDB) recordAccountChange(ctx context.Context, address string) error {
+    if !m.changeFeed {
+        return nil
+    }
+    accountsColl := m.client.Database(database).Collection(accountsCollection)
+    account := bson.M{}
+    err := accountsColl.FindOne(ctx, bson.D{{Key: "_id", Value: address}}).Decode(&account)
+    if err != nil {
+        return err
+    }
+    return m.recordChange(ctx, EntityAccount, ChangeUpdate, address, account)
+}
+
+// GetChanges returns changes after since in sequence order. Changes younger than settle
+// are held back so sequences allocated by in flight writes are not skipped by readers.
+func (m *ReadDB) GetChanges(since int64, limit int64, settle time.Duration) ([]*types.ChangeDoc, error) {
+    changesColl := m.client.Database(database).Collection(changesCollection)
+
+    findOptions := options.Find()
+    findOptions.SetLimit(limit)
+    findOptions.SetSort(bson.M{"_id": 1})
+
+    filter := bson.D{
+        {Key: "_id", Value: bson.D{{Key: "$gt", Value: since}}},
+        {Key: "timestamp", Value: bson.D{{Key: "$lte", Value: time.Now().Add(-settle)}}},
+    }
+
+    ctx := context.TODO()
+    cursor, err := changesColl.Find(
+        ctx,
+        filter,
+        findOptions,
+    )
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    var changes []*types.ChangeDoc
+    if err = cursor.All(ctx, &changes); err != nil {
+        return nil, err
+    }
+    return changes, nil
+}
@@ -0,0 +1,41 @@
+package database
+
+import (
+    "context"
+
+    "github.com/swarmbit/spacemesh-state-api/types"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ImportCheckpointAccounts sets the balances of the accounts in one transaction, the
+// difference to the stored balance is the balance change of layer so the balance
+// history still sums to the balance.
+func (m *WriteDB) ImportCheckpointAccounts(accounts []*types.AccountDoc, layer uint32) error {
+    err := m.withTransaction(context.TODO(), func(ctx context.Context) error {
+        accountsColl := m.client.Database(database).Collection(accountsCollection)
+        for _, account := range accounts {
+            previous := &types.AccountDoc{}
+            err := accountsColl.FindOneAndUpdate(
+                ctx,
+                bson.D{{Key: "_id", Value: account.Address}},
+                bson.D{{Key: "$set", Value: bson.D{{Key: "balance", Value: account.Balance}}}},
+                options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.Before),
+            ).Decode(previous)
+            if err != nil && err != mongo.ErrNoDocuments {
+                return err
+            }
+            if delta := int64(account.Balance) - int64(previous.Balance); delta != 0 {
+                if _, err = m.incBalanceChange(ctx, account.Address, layer, delta); err != nil {
+                    return err
+                }
+            }
+            if err = m.recordAccountChange(ctx, account.Address); err != nil {
+                return err
+            }
+        }
+        return nil
+    })
+    return err
+}
@@ -0,0 +1,67 @@
+package database
+
+import (
+    "context"
+
+    "github.com/swarmbit/spacemesh-state-api/types"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const streamCheckpointsCollection = "streamCheckpoints"
+
+// SaveStreamCheckpoint never moves a checkpoint back, parallel consumers may save out
+// of order. The checkpoint records the fence generation of the writer and is not
+// updated anymore once a newer generation saved it.
+func (m *WriteDB) SaveStreamCheckpoint(checkpoint *types.StreamCheckpointDoc) error {
+    checkpointsColl := m.client.Database(database).Collection(streamCheckpointsCollection)
+    filter := bson.D{{Key: "_id", Value: checkpoint.Consumer}}
+    set := bson.D{
+        {Key: "stream", Value: checkpoint.Stream},
+        {Key: "updatedAt", Value: checkpoint.UpdatedAt},
+    }
+    fence := m.fence.Load()
+    if fence != nil {
+        filter = append(filter, bson.E{Key: "writerGeneration", Value: bson.D{{Key: "$not", Value: bson.D{{Key: "$gt", Value: fence.Generation}}}}})
+        set = append(set,
+            bson.E{Key: "writerInstance", Value: fence.InstanceId},
+            bson.E{Key: "writerGeneration", Value: fence.Generation},
+        )
+    }
+    _, err := checkpointsColl.UpdateOne(
+        context.TODO(),
+        filter,
+        bson.D{
+            {Key: "$max", Value: bson.D{
+                {Key: "sequence", Value: checkpoint.Sequence},
+                {Key: "layer", Value: checkpoint.Layer},
+            }},
+            {Key: "$set", Value: set},
+        },
+        options.Update().SetUpsert(true),
+    )
+    // the upsert conflicts with the checkpoint of a newer writer
+    if mongo.IsDuplicateKeyError(err) {
+        m.fenced.Store(true)
+        return ErrFenced
+    }
+    return err
+}
+
+func (m *ReadDB) GetStreamCheckpoints() ([]*types.StreamCheckpointDoc, error) {
+    checkpointsColl := m.client.Database(database).Collection(streamCheckpointsCollection)
+
+    ctx := context.TODO()
+    cursor, err := checkpointsColl.Find(ctx, bson.D{}, options.Find().SetSort(bson.M{"_id": 1}))
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    checkpoints := make([]*types.StreamCheckpointDoc, 0)
+    if err = cursor.All(ctx, &checkpoints); err != nil {
+        return nil, err
+    }
+    return checkpoints, nil
+}
@@ -0,0 +1,91 @@
+package database
+
+import (
+    "context"
+
+    "github.com/swarmbit/spacemesh-state-api/types"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GetLayersBetween returns the stored layers from and to included, with any status.
+func (m *ReadDB) GetLayersBetween(from uint32, to uint32) ([]*types.LayerDoc, error) {
+    layersColl := m.client.Database(database).Collection(layersCollection)
+
+    ctx := context.TODO()
+    cursor, err := layersColl.Find(
+        ctx,
+        bson.D{{Key: "_id", Value: bson.D{
+            {Key: "$gte", Value: from},
+            {Key: "$lte", Value: to},
+        }}},
+        options.Find().SetSort(bson.M{"_id": 1}),
+    )
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    layers := make([]*types.LayerDoc, 0)
+    if err = cursor.All(ctx, &layers); err != nil {
+        return nil, err
+    }
+    return layers, nil
+}
+
+// GetRewardedLayers returns the layers from and to included that have at least one
+// reward.
+func (m *ReadDB) GetRewardedLayers(from uint32, to uint32) ([]uint32, error) {
+    rewardsColl := m.client.Database(database).Collection(rewardsCollection)
+    values, err := rewardsColl.Distinct(
+        context.TODO(),
+        "layer",
+        bson.D{{Key: "layer", Value: bson.D{
+            {Key: "$gte", Value: from},
+            {Key: "$lte", Value: to},
+        }}},
+    )
+    if err != nil {
+        return nil, err
+    }
+    layers := make([]uint32, 0, len(values))
+    for _, value := range values {
+        switch layer := value.(type) {
+        case int32:
+            layers = append(layers, uint32(layer))
+        case int64:
+            layers = append(layers, uint32(layer))
+        }
+    }
+    return layers, nil
+}
+
+// GetLayersRewards returns the number and the total of the rewards of the layers from and
+// to included that have rewards, by layer.
+func (m *ReadDB) GetLayersRewards(from uint32, to uint32) ([]*types.LayerRewardsDoc, error) {
+    rewardsColl := m.client.Database(database).Collection(rewardsCollection)
+
+    ctx := context.TODO()
+    cursor, err := rewardsColl.Aggregate(ctx, bson.A{
+        bson.D{{Key: "$match", Value: bson.D{{Key: "layer", Value: bson.D{
+            {Key: "$gte", Value: from},
+            {Key: "$lte", Value: to},
+        }}}}},
+        bson.D{{Key: "$group", Value: bson.D{
+            {Key: "_id", Value: "$layer"},
+            {Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+            {Key: "totalReward", Value: bson.D{{Key: "$sum", Value: "$totalReward"}}},
+        }}},
+        bson.D{{Key: "$sort", Value: bson.D{{Key: "_id", Value: 1}}}},
+    })
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    layers := make([]*types.LayerRewardsDoc, 0)
+    if err = cursor.All(ctx, &layers); err != nil {
+        return nil, err
+    }
+    return layers, nil
+}
@@ -0,0 +1,109 @@
+package database
+
+import (
+    "context"
+    "encoding/base64"
+    "encoding/json"
+    "errors"
+
+    "github.com/swarmbit/spacemesh-state-api/types"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrInvalidCursor is returned when a cursor was not encoded by EncodeCursor.
+var ErrInvalidCursor = errors.New("cursor is not valid")
+
+// Cursor is the position of the last item of a page. Cursor lists are ordered by their
+// key and then by id, both in the Sort direction, so items with the same key keep their
+// order between pages and new items do not shift the next page.
+type Cursor struct {
+    Key  int64  `json:"k"`
+    ID   string `json:"i"`
+    Sort int8   `json:"s"`
+}
+
+// EncodeCursor returns the opaque form of cursor served to clients.
+func EncodeCursor(cursor *Cursor) string {
+    encoded, _ := json.Marshal(cursor)
+    return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+// DecodeCursor reads a cursor served by EncodeCursor.
+func DecodeCursor(value string) (*Cursor, error) {
+    decoded, err := base64.RawURLEncoding.DecodeString(value)
+    if err != nil {
+        return nil, ErrInvalidCursor
+    }
+    cursor := &Cursor{}
+    if err := json.Unmarshal(decoded, cursor); err != nil || cursor.ID == "" || (cursor.Sort != 1 && cursor.Sort != -1) {
+        return nil, ErrInvalidCursor
+    }
+    return cursor, nil
+}
+
+// cursorFilter selects the documents of filter after the cursor, every document on the
+// first page when after is nil.
+func cursorFilter(filter bson.D, field string, after *Cursor, sort int8) bson.D {
+    if after == nil {
+        return filter
+    }
+    op := "$gt"
+    if sort < 0 {
+        op = "$lt"
+    }
+    return bson.D{{Key: "$and", Value: bson.A{
+        filter,
+        bson.D{{Key: "$or", Value: bson.A{
+            bson.D{{Key: field, Value: bson.D{{Key: op, Value: after.Key}}}},
+            bson.D{{Key: field, Value: after.Key}, {Key: "_id", Value: bson.D{{Key: op, Value: after.ID}}}},
+        }}},
+    }}}
+}
+
+func cursorFindOptions(field string, limit int64, sort int8) *options.FindOptions {
+    return options.Find().
+        SetSort(bson.D{{Key: field, Value: sort}, {Key: "_id", Value: sort}}).
+        SetLimit(limit)
+}
+
+func (m *ReadDB) GetRewardsAfter(account string, after *Cursor, limit int64, sort int8, firstLayer int, lastLayer int) ([]*types.RewardsDoc, error) {
+    filter := cursorFilter(accountRewardsFilter(account, firstLayer, lastLayer), "layer", after, sort)
+    return findAll[types.RewardsDoc](m.client.Database(database).Collection(rewardsCollection), filter, cursorFindOptions("layer", limit, sort))
+}
+
+func (m *ReadDB) GetNodeRewardsAfter(node string, after *Cursor, limit int64, sort int8) ([]*types.RewardsDoc, error) {
+    filter := cursorFilter(bson.D{{Key: "node_id", Value: node}}, "layer", after, sort)
+    return findAll[types.RewardsDoc](m.client.Database(database).Collection(rewardsCollection), filter, cursorFindOptions("layer", limit, sort))
+}
+
+func (m *ReadDB) GetTransactionsAfter(account string, after *Cursor, limit int64, sort int8, state string) ([]*types.TransactionDoc, error) {
+    filter := cursorFilter(accountTransactionsFilter(account, state), "layer", after, sort)
+    return findAll[types.TransactionDoc](m.client.Database(database).Collection(transactionsCollection), filter, cursorFindOptions("layer", limit, sort))
+}
+
+func (m *ReadDB) GetAllTransactionsAfter(after *Cursor, limit int64, sort int8, filter *TransactionsFilter) ([]*types.TransactionDoc, error) {
+    query := cursorFilter(allTransactionsFilter(filter), "layer", after, sort)
+    return findAll[types.TransactionDoc](m.client.Database(database).Collection(transactionsCollection), query, cursorFindOptions("layer", limit, sort))
+}
+
+func (m *ReadDB) GetNodeAtxsAfter(nodeId string, after *Cursor, limit int64, sort int8) ([]*types.AtxDoc, error) {
+    filter := cursorFilter(bson.D{{Key: "node_id", Value: nodeId}}, "publishepoch", after, sort)
+    return findAll[types.AtxDoc](m.client.Database(database).Collection(atxsCollection), filter, cursorFindOptions("publishepoch", limit, sort))
+}
+
+func findAll[T any](coll *mongo.Collection, filter bson.D, findOptions *options.FindOptions) ([]*T, error) {
+    ctx := context.TODO()
+    cursor, err := coll.Find(ctx, filter, findOptions)
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    var docs []*T
+    if err = cursor.All(ctx, &docs); err != nil {
+        return nil, err
+    }
+    return docs, nil
+}
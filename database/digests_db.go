@@ -0,0 +1,102 @@
+package database
+
+import (
+    "context"
+
+    "github.com/swarmbit/spacemesh-state-api/types"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const rewardDigestsCollection = "rewardDigests"
+
+// SaveRewardDigests replaces the digests saved before for the same coinbase and day.
+func (m *WriteDB) SaveRewardDigests(digests []*types.RewardDigestDoc) error {
+    if m.Fenced() {
+        return ErrFenced
+    }
+    if len(digests) == 0 {
+        return nil
+    }
+    digestsColl := m.client.Database(database).Collection(rewardDigestsCollection)
+    models := make([]mongo.WriteModel, len(digests))
+    for i, digest := range digests {
+        models[i] = mongo.NewReplaceOneModel().
+            SetFilter(bson.D{{Key: "_id", Value: digest.Id}}).
+            SetReplacement(digest).
+            SetUpsert(true)
+    }
+    _, err := digestsColl.BulkWrite(context.TODO(), models, options.BulkWrite().SetOrdered(false))
+    return err
+}
+
+// GetCoinbasesRewards returns the number and the total of the rewards of every coinbase
+// in the layers from and to included.
+func (m *ReadDB) GetCoinbasesRewards(firstLayer uint32, lastLayer uint32) ([]*types.CoinbaseRewardsDoc, error) {
+    rewardsColl := m.client.Database(database).Collection(rewardsCollection)
+
+    ctx := context.TODO()
+    cursor, err := rewardsColl.Aggregate(ctx, bson.A{
+        bson.D{{Key: "$match", Value: bson.D{{Key: "layer", Value: bson.D{
+            {Key: "$gte", Value: firstLayer},
+            {Key: "$lte", Value: lastLayer},
+        }}}}},
+        bson.D{{Key: "$group", Value: bson.D{
+            {Key: "_id", Value: "$coinbase"},
+            {Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+            {Key: "totalReward", Value: bson.D{{Key: "$sum", Value: "$totalReward"}}},
+        }}},
+    }, options.Aggregate().SetAllowDiskUse(true))
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    rewards := make([]*types.CoinbaseRewardsDoc, 0)
+    if err = cursor.All(ctx, &rewards); err != nil {
+        return nil, err
+    }
+    return rewards, nil
+}
+
+// GetRewardDigests returns the digests of the coinbase of the days from and to included,
+// unix times of their start, sorted by day.
+func (m *ReadDB) GetRewardDigests(coinbase string, from int64, to int64) ([]*types.RewardDigestDoc, error) {
+    digestsColl := m.client.Database(database).Collection(rewardDigestsCollection)
+
+    ctx := context.TODO()
+    cursor, err := digestsColl.Find(ctx, bson.D{
+        {Key: "_id.coinbase", Value: coinbase},
+        {Key: "_id.day", Value: bson.D{{Key: "$gte", Value: from}, {Key: "$lte", Value: to}}},
+    }, options.Find().SetSort(bson.D{{Key: "_id.day", Value: 1}}))
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    digests := make([]*types.RewardDigestDoc, 0)
+    if err = cursor.All(ctx, &digests); err != nil {
+        return nil, err
+    }
+    return digests, nil
+}
+
+// GetLastRewardDigest returns a digest of the last day digested, empty when there is none.
+func (m *ReadDB) GetLastRewardDigest() (*types.RewardDigestDoc, error) {
+    digestsColl := m.client.Database(database).Collection(rewardDigestsCollection)
+
+    digest := &types.RewardDigestDoc{}
+    err := digestsColl.FindOne(
+        context.TODO(),
+        bson.D{},
+        options.FindOne().SetSort(bson.D{{Key: "_id.day", Value: -1}}),
+    ).Decode(digest)
+    if err == mongo.ErrNoDocuments {
+        return &types.RewardDigestDoc{}, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    return digest, nil
+}
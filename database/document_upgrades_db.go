@@ -0,0 +1,69 @@
+package database
+
+import (
+    "github.com/swarmbit/spacemesh-state-api/migrations"
+    "github.com/swarmbit/spacemesh-state-api/pkg/apperror"
+    "github.com/swarmbit/spacemesh-state-api/types"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/bsoncodec"
+)
+
+const upgradeBatchSize = 1000
+
+// transactionUpgrade evolves the stored transactions, add a step to change their format.
+// There is none yet, the stored transactions are version 0.
+var transactionUpgrade = &migrations.DocumentUpgrade[types.TransactionDoc]{
+    Collection: transactionsCollection,
+    Version: func(doc *types.TransactionDoc) *int {
+        return &doc.SchemaVersion
+    },
+}
+
+// atxUpgrade evolves the stored atxs, add a step to change their format. There is none
+// yet, the stored atxs are version 0.
+var atxUpgrade = &migrations.DocumentUpgrade[types.AtxDoc]{
+    Collection: atxsCollection,
+    Version: func(doc *types.AtxDoc) *int {
+        return &doc.SchemaVersion
+    },
+}
+
+// DocumentUpgrades lists the collections with versioned documents.
+var DocumentUpgrades = []migrations.Upgrader{
+    transactionUpgrade,
+    atxUpgrade,
+}
+
+// ErrUnknownUpgrade is returned by UpgradeDocuments for collections not in DocumentUpgrades.
+var ErrUnknownUpgrade error = apperror.New(apperror.InvalidInput, "collection has no versioned documents")
+
+func GetDocumentUpgrade(collection string) migrations.Upgrader {
+    for _, v := range DocumentUpgrades {
+        if v.CollectionName() == collection {
+            return v
+        }
+    }
+    return nil
+}
+
+// documentRegistry decodes the versioned documents in their current format. Only the
+// collections with steps get an upgrading decoder, the others decode as they are stored.
+func documentRegistry() *bsoncodec.Registry {
+    registry := bson.NewRegistry()
+    for _, v := range DocumentUpgrades {
+        if v.Current() > 0 {
+            v.Register(registry)
+        }
+    }
+    return registry
+}
+
+// UpgradeDocuments stores the current format of the outdated documents of collection,
+// reads already upgrade them in memory so it can run while the api serves.
+func (m *WriteDB) UpgradeDocuments(collection string) (int64, error) {
+    upgrade := GetDocumentUpgrade(collection)
+    if upgrade == nil {
+        return 0, ErrUnknownUpgrade
+    }
+    return upgrade.UpgradeCollection(m.client.Database(database), upgradeBatchSize)
+}
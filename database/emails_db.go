@@ -0,0 +1,131 @@
+package database
+
+import (
+    "context"
+
+    "github.com/swarmbit/spacemesh-state-api/types"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const emailSubscriptionsCollection = "emailSubscriptions"
+
+// AddEmailSubscription inserts subscription, the unique indexes on the owner with the
+// email and with the slot reject it with a Conflict when another one took either.
+func (m *WriteDB) AddEmailSubscription(subscription *types.EmailSubscriptionDoc) error {
+    subscriptionsColl := m.client.Database(database).Collection(emailSubscriptionsCollection)
+    _, err := subscriptionsColl.InsertOne(context.TODO(), subscription)
+    return Classify(err)
+}
+
+// ConfirmEmailSubscription activates the subscription of a confirmation link, it reports
+// false when no subscription has the token.
+func (m *WriteDB) ConfirmEmailSubscription(token string) (bool, error) {
+    subscriptionsColl := m.client.Database(database).Collection(emailSubscriptionsCollection)
+    result, err := subscriptionsColl.UpdateOne(
+        context.TODO(),
+        bson.D{{Key: "token", Value: token}},
+        bson.D{{Key: "$set", Value: bson.D{{Key: "pending", Value: false}}}},
+    )
+    if err != nil {
+        return false, err
+    }
+    return result.MatchedCount > 0, nil
+}
+
+// DeleteEmailSubscription deletes the subscription of owner, it reports false when owner
+// has no such subscription.
+func (m *WriteDB) DeleteEmailSubscription(id string, owner string) (bool, error) {
+    subscriptionsColl := m.client.Database(database).Collection(emailSubscriptionsCollection)
+    result, err := subscriptionsColl.DeleteOne(context.TODO(), bson.D{{Key: "_id", Value: id}, {Key: "owner", Value: owner}})
+    if err != nil {
+        return false, err
+    }
+    return result.DeletedCount > 0, nil
+}
+
+// DeleteEmailSubscriptionByToken deletes the subscription of an unsubscribe link, it
+// reports false when no subscription has the token.
+func (m *WriteDB) DeleteEmailSubscriptionByToken(token string) (bool, error) {
+    subscriptionsColl := m.client.Database(database).Collection(emailSubscriptionsCollection)
+    result, err := subscriptionsColl.DeleteOne(context.TODO(), bson.D{{Key: "token", Value: token}})
+    if err != nil {
+        return false, err
+    }
+    return result.DeletedCount > 0, nil
+}
+
+// DeletePendingEmailSubscriptions deletes the subscriptions created before createdBefore
+// that were never confirmed and returns how many.
+func (m *WriteDB) DeletePendingEmailSubscriptions(createdBefore int64) (int64, error) {
+    if m.Fenced() {
+        return 0, ErrFenced
+    }
+    subscriptionsColl := m.client.Database(database).Collection(emailSubscriptionsCollection)
+    result, err := subscriptionsColl.DeleteMany(context.TODO(), bson.D{
+        {Key: "pending", Value: true},
+        {Key: "createdAt", Value: bson.D{{Key: "$lt", Value: createdBefore}}},
+    })
+    if err != nil {
+        return 0, err
+    }
+    return result.DeletedCount, nil
+}
+
+// ClaimEmailSubscriptionLastDay moves the last day of the subscription to lastDay before
+// its digest is sent, only while it is earlier. It reports false when the period was
+// already claimed, so a digest is sent once.
+func (m *WriteDB) ClaimEmailSubscriptionLastDay(id string, lastDay int64) (bool, error) {
+    if m.Fenced() {
+        return false, ErrFenced
+    }
+    subscriptionsColl := m.client.Database(database).Collection(emailSubscriptionsCollection)
+    result, err := subscriptionsColl.UpdateOne(
+        context.TODO(),
+        bson.D{{Key: "_id", Value: id}, {Key: "lastDay", Value: bson.D{{Key: "$lt", Value: lastDay}}}},
+        bson.D{{Key: "$set", Value: bson.D{{Key: "lastDay", Value: lastDay}}}},
+    )
+    if err != nil {
+        return false, err
+    }
+    return result.ModifiedCount == 1, nil
+}
+
+// UpdateEmailSubscriptionLastDay records the last day a digest was sent for, a
+// subscription deleted meanwhile is not recreated.
+func (m *WriteDB) UpdateEmailSubscriptionLastDay(id string, lastDay int64) error {
+    if m.Fenced() {
+        return ErrFenced
+    }
+    subscriptionsColl := m.client.Database(database).Collection(emailSubscriptionsCollection)
+    _, err := subscriptionsColl.UpdateOne(
+        context.TODO(),
+        bson.D{{Key: "_id", Value: id}},
+        bson.D{{Key: "$set", Value: bson.D{{Key: "lastDay", Value: lastDay}}}},
+    )
+    return err
+}
+
+// GetEmailSubscriptions returns the subscriptions of owner, every subscription when owner
+// is empty, in the order they were created.
+func (m *ReadDB) GetEmailSubscriptions(owner string) ([]*types.EmailSubscriptionDoc, error) {
+    subscriptionsColl := m.client.Database(database).Collection(emailSubscriptionsCollection)
+
+    ctx := context.TODO()
+    filter := bson.D{}
+    if owner != "" {
+        filter = bson.D{{Key: "owner", Value: owner}}
+    }
+    cursor, err := subscriptionsColl.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}))
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    var docs []*types.EmailSubscriptionDoc
+    if err = cursor.All(ctx, &docs); err != nil {
+        return nil, err
+    }
+    return docs, nil
+}
+
@@ -0,0 +1,64 @@
+package database
+
+import (
+    "context"
+    "database/sql"
+    "database/sql/driver"
+    "errors"
+    "net"
+
+    "github.com/lib/pq"
+    "github.com/swarmbit/spacemesh-state-api/pkg/apperror"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/x/mongo/driver/topology"
+    "modernc.org/sqlite"
+)
+
+// postgres error code of unique constraint violations
+const pqUniqueViolation = "23505"
+
+// postgres error code of statements cancelled when their context ended
+const pqQueryCanceled = "57014"
+
+// sqlite extended error codes of unique and primary key constraint violations
+const (
+    sqliteConstraintPrimaryKey = 1555
+    sqliteConstraintUnique     = 2067
+)
+
+// Classify returns err as a typed error when it comes from a storage condition callers
+// handle on their own: a missing document or row is NotFound, a duplicate key Conflict
+// and a lost connection or timeout Unavailable. Errors that already have a kind other
+// than Internal and any other error are returned as they are.
+func Classify(err error) error {
+    if err == nil || apperror.KindOf(err) != apperror.Internal {
+        return err
+    }
+    if kind, ok := storageErrorKind(err); ok {
+        return apperror.Wrap(kind, "", err)
+    }
+    return err
+}
+
+func storageErrorKind(err error) (apperror.Kind, bool) {
+    if errors.Is(err, mongo.ErrNoDocuments) || errors.Is(err, sql.ErrNoRows) {
+        return apperror.NotFound, true
+    }
+    var pqErr *pq.Error
+    var sqliteErr *sqlite.Error
+    if mongo.IsDuplicateKeyError(err) || (errors.As(err, &pqErr) && pqErr.Code == pqUniqueViolation) ||
+        (errors.As(err, &sqliteErr) &&
+            (sqliteErr.Code() == sqliteConstraintUnique || sqliteErr.Code() == sqliteConstraintPrimaryKey)) {
+        return apperror.Conflict, true
+    }
+    var selectionErr topology.ServerSelectionError
+    var netErr net.Error
+    if mongo.IsNetworkError(err) || mongo.IsTimeout(err) ||
+        errors.Is(err, mongo.ErrClientDisconnected) || errors.As(err, &selectionErr) ||
+        errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) ||
+        errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) ||
+        (errors.As(err, &pqErr) && pqErr.Code == pqQueryCanceled) {
+        return apperror.Unavailable, true
+    }
+    return "", false
+}
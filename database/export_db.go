@@ -0,0 +1,141 @@
+package database
+
+import (
+    "context"
+    "time"
+
+    "github.com/swarmbit/spacemesh-state-api/types"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// The Stream methods run the same queries as their paginated Get counterparts without
+// skip and limit and hand the documents to each one at a time, so exports of any size
+// are served with constant memory.
+
+func streamFind[T any](coll *mongo.Collection, filter interface{}, sort bson.M, each func(*T) error) error {
+    findOptions := options.Find()
+    findOptions.SetSort(sort)
+
+    ctx := context.TODO()
+    cursor, err := coll.Find(ctx, filter, findOptions)
+    if err != nil {
+        return err
+    }
+    defer cursor.Close(ctx)
+
+    for cursor.Next(ctx) {
+        doc := new(T)
+        if err := cursor.Decode(doc); err != nil {
+            return err
+        }
+        if err := each(doc); err != nil {
+            return err
+        }
+    }
+    return cursor.Err()
+}
+
+func accountRewardsFilter(account string, firstLayer int, lastLayer int) bson.D {
+    filter := bson.D{{Key: "coinbase", Value: account}}
+    layerFilter := bson.D{}
+    if firstLayer > -1 {
+        layerFilter = append(layerFilter, bson.E{Key: "$gte", Value: firstLayer})
+    }
+    if lastLayer > -1 {
+        layerFilter = append(layerFilter, bson.E{Key: "$lte", Value: lastLayer})
+    }
+    if len(layerFilter) > 0 {
+        filter = append(filter, bson.E{Key: "layer", Value: layerFilter})
+    }
+    return filter
+}
+
+func (m *ReadDB) StreamRewards(account string, sort int8, firstLayer int, lastLayer int, each func(*types.RewardsDoc) error) error {
+    rewardsColl := m.client.Database(database).Collection(rewardsCollection)
+    return streamFind(rewardsColl, accountRewardsFilter(account, firstLayer, lastLayer), bson.M{"layer": sort}, each)
+}
+
+func (m *ReadDB) StreamLayerRewards(layer int, sort int8, each func(*types.RewardsDoc) error) error {
+    rewardsColl := m.client.Database(database).Collection(rewardsCollection)
+    return streamFind(rewardsColl, bson.D{{Key: "layer", Value: layer}}, bson.M{"layer": sort}, each)
+}
+
+func (m *ReadDB) StreamNodeRewards(node string, sort int8, each func(*types.RewardsDoc) error) error {
+    rewardsColl := m.client.Database(database).Collection(rewardsCollection)
+    return streamFind(rewardsColl, bson.D{{Key: "node_id", Value: node}}, bson.M{"layer": sort}, each)
+}
+
+func (m *ReadDB) StreamTransactions(account string, sort int8, state string, each func(*types.TransactionDoc) error) error {
+    transactionsColl := m.client.Database(database).Collection(transactionsCollection)
+    filter := accountTransactionsFilter(account, state)
+    return streamFind(transactionsColl, filter, bson.M{"layer": sort}, each)
+}
+
+func (m *ReadDB) StreamLayerTransactions(layer int, sort int8, state string, each func(*types.TransactionDoc) error) error {
+    transactionsColl := m.client.Database(database).Collection(transactionsCollection)
+    filter := transactionStateFilter(bson.D{{Key: "layer", Value: layer}}, state)
+    return streamFind(transactionsColl, filter, bson.M{"layer": sort}, each)
+}
+
+func (m *ReadDB) StreamAllTransactions(sort int8, filter *TransactionsFilter, each func(*types.TransactionDoc) error) error {
+    transactionsColl := m.client.Database(database).Collection(transactionsCollection)
+    return streamFind(transactionsColl, allTransactionsFilter(filter), bson.M{"layer": sort}, each)
+}
+
+func (m *ReadDB) StreamAccounts(sort int8, each func(*types.AccountDoc) error) error {
+    accountsColl := m.client.Database(database).Collection(accountsCollection)
+    return streamFind(accountsColl, bson.M{}, bson.M{"balance": sort}, each)
+}
+
+func (m *ReadDB) StreamAtxForEpoch(epoch uint64, sort int8, each func(*types.AtxDoc) error) error {
+    atxColl := m.client.Database(database).Collection(atxsCollection)
+    return streamFind(atxColl, bson.M{"publishepoch": epoch}, bson.M{"effective_num_units": sort}, each)
+}
+
+func (m *ReadDB) StreamAccountAtxEpoch(account string, epoch uint64, sort int8, each func(*types.AtxDoc) error) error {
+    atxColl := m.client.Database(database).Collection(atxsCollection)
+    filter := bson.M{
+        "coinbase":     account,
+        "publishepoch": epoch,
+    }
+    return streamFind(atxColl, filter, bson.M{"received": sort}, each)
+}
+
+// GetPrices returns the prices fetched between from and to, preceded by the last price
+// fetched before from when there is one.
+func (m *ReadDB) GetPrices(from time.Time, to time.Time) ([]*types.PriceDoc, error) {
+    pricesColl := m.client.Database(database).Collection(pricesCollection)
+
+    findOptions := options.Find()
+    findOptions.SetSort(bson.M{"timestamp": 1})
+
+    ctx := context.TODO()
+    cursor, err := pricesColl.Find(
+        ctx,
+        bson.D{{Key: "timestamp", Value: bson.D{
+            {Key: "$gte", Value: from},
+            {Key: "$lte", Value: to},
+        }}},
+        findOptions,
+    )
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    var prices []*types.PriceDoc
+    if err = cursor.All(ctx, &prices); err != nil {
+        return nil, err
+    }
+
+    previous, err := m.GetPriceAt(from)
+    if err != nil {
+        return nil, err
+    }
+    if previous != nil && (len(prices) == 0 || previous.Timestamp.Before(prices[0].Timestamp)) {
+        prices = append([]*types.PriceDoc{previous}, prices...)
+    }
+    return prices, nil
+}
@@ -0,0 +1,267 @@
+package database
+
+import (
+    "context"
+
+    "github.com/swarmbit/spacemesh-state-api/config"
+    "github.com/swarmbit/spacemesh-state-api/types"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const feesRollupsCollection = "feesRollups"
+
+// transactionFee is the fee paid by an applied transaction, the same gas times gas price
+// deducted from the balances.
+var transactionFee = bson.D{{Key: "$multiply", Value: bson.A{"$gas", "$gas_price"}}}
+
+func feesRollupBucket(granularity string) interface{} {
+    if granularity == RollupEpoch {
+        return bson.D{{Key: "$toLong", Value: bson.D{
+            {Key: "$floor", Value: bson.D{{Key: "$divide", Value: bson.A{"$layer", config.LayersPerEpoch}}}},
+        }}}
+    }
+    return bson.D{{Key: "$toLong", Value: "$layer"}}
+}
+
+func feesRollupPipeline(granularity string, fromLayer int64) mongo.Pipeline {
+    return mongo.Pipeline{
+        bson.D{
+            {Key: "$match", Value: bson.D{
+                {Key: "complete", Value: true},
+                {Key: "layer", Value: bson.D{{Key: "$gte", Value: fromLayer}}},
+            }},
+        },
+        bson.D{
+            {Key: "$group", Value: bson.D{
+                {Key: "_id", Value: bson.D{
+                    {Key: "granularity", Value: granularity},
+                    {Key: "bucket", Value: feesRollupBucket(granularity)},
+                }},
+                {Key: "transactions", Value: bson.D{{Key: "$sum", Value: 1}}},
+                {Key: "gas", Value: bson.D{{Key: "$sum", Value: "$gas"}}},
+                {Key: "fees", Value: bson.D{{Key: "$sum", Value: transactionFee}}},
+                {Key: "minFee", Value: bson.D{{Key: "$min", Value: transactionFee}}},
+                {Key: "maxFee", Value: bson.D{{Key: "$max", Value: transactionFee}}},
+                {Key: "minGasPrice", Value: bson.D{{Key: "$min", Value: "$gas_price"}}},
+                {Key: "maxGasPrice", Value: bson.D{{Key: "$max", Value: "$gas_price"}}},
+            }},
+        },
+        bson.D{
+            {Key: "$merge", Value: bson.D{
+                {Key: "into", Value: feesRollupsCollection},
+                {Key: "on", Value: "_id"},
+                {Key: "whenMatched", Value: "merge"},
+                {Key: "whenNotMatched", Value: "insert"},
+            }},
+        },
+    }
+}
+
+// feesRewardedPipeline sums the fees paid back to smeshers per epoch, the part of the
+// rewards above the layer reward. What was paid and not rewarded is burned.
+func feesRewardedPipeline(fromLayer int64) mongo.Pipeline {
+    return mongo.Pipeline{
+        bson.D{
+            {Key: "$match", Value: bson.D{
+                {Key: "layer", Value: bson.D{{Key: "$gte", Value: fromLayer}}},
+            }},
+        },
+        bson.D{
+            {Key: "$group", Value: bson.D{
+                {Key: "_id", Value: bson.D{
+                    {Key: "granularity", Value: RollupEpoch},
+                    {Key: "bucket", Value: feesRollupBucket(RollupEpoch)},
+                }},
+                {Key: "feesRewarded", Value: bson.D{{Key: "$sum", Value: bson.D{
+                    {Key: "$subtract", Value: bson.A{"$totalReward", "$layerReward"}},
+                }}}},
+            }},
+        },
+        bson.D{
+            {Key: "$merge", Value: bson.D{
+                {Key: "into", Value: feesRollupsCollection},
+                {Key: "on", Value: "_id"},
+                {Key: "whenMatched", Value: "merge"},
+                {Key: "whenNotMatched", Value: "insert"},
+            }},
+        },
+    }
+}
+
+// AggregateFeesRollups recomputes the fees of every layer and epoch starting at
+// fromEpoch, with the median fee of each epoch. Earlier buckets are left untouched.
+func (m *WriteDB) AggregateFeesRollups(fromEpoch uint32) error {
+    if m.Fenced() {
+        return ErrFenced
+    }
+    db := m.client.Database(database)
+    fromLayer := int64(fromEpoch) * int64(config.LayersPerEpoch)
+
+    aggregations := []struct {
+        collection string
+        pipeline   mongo.Pipeline
+    }{
+        {transactionsCollection, feesRollupPipeline(RollupLayer, fromLayer)},
+        {transactionsCollection, feesRollupPipeline(RollupEpoch, fromLayer)},
+        {rewardsCollection, feesRewardedPipeline(fromLayer)},
+    }
+    for _, aggregation := range aggregations {
+        cursor, err := db.Collection(aggregation.collection).Aggregate(context.TODO(), aggregation.pipeline, options.Aggregate().SetAllowDiskUse(true))
+        if err != nil {
+            return err
+        }
+        cursor.Close(context.TODO())
+    }
+
+    epochs, err := m.getFeesRollups(RollupEpoch, int64(fromEpoch))
+    if err != nil {
+        return err
+    }
+    for _, epoch := range epochs {
+        median, err := m.medianFee(epoch)
+        if err != nil {
+            return err
+        }
+        _, err = db.Collection(feesRollupsCollection).UpdateOne(
+            context.TODO(),
+            bson.D{{Key: "_id", Value: epoch.Id}},
+            bson.D{{Key: "$set", Value: bson.D{{Key: "medianFee", Value: median}}}},
+        )
+        if err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+func (m *WriteDB) getFeesRollups(granularity string, from int64) ([]*types.FeesRollupDoc, error) {
+    rollupsColl := m.client.Database(database).Collection(feesRollupsCollection)
+
+    ctx := context.TODO()
+    cursor, err := rollupsColl.Find(ctx, bson.D{
+        {Key: "_id.granularity", Value: granularity},
+        {Key: "_id.bucket", Value: bson.D{{Key: "$gte", Value: from}}},
+    })
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    rollups := make([]*types.FeesRollupDoc, 0)
+    if err = cursor.All(ctx, &rollups); err != nil {
+        return nil, err
+    }
+    return rollups, nil
+}
+
+// medianFee is the lower median of the fees of the applied transactions of an epoch.
+func (m *WriteDB) medianFee(epoch *types.FeesRollupDoc) (int64, error) {
+    if epoch.Transactions == 0 {
+        return 0, nil
+    }
+    transactionsColl := m.client.Database(database).Collection(transactionsCollection)
+    firstLayer := epoch.Id.Bucket * int64(config.LayersPerEpoch)
+
+    ctx := context.TODO()
+    cursor, err := transactionsColl.Aggregate(ctx, mongo.Pipeline{
+        bson.D{{Key: "$match", Value: bson.D{
+            {Key: "complete", Value: true},
+            {Key: "layer", Value: bson.D{
+                {Key: "$gte", Value: firstLayer},
+                {Key: "$lt", Value: firstLayer + int64(config.LayersPerEpoch)},
+            }},
+        }}},
+        bson.D{{Key: "$project", Value: bson.D{{Key: "fee", Value: transactionFee}}}},
+        bson.D{{Key: "$sort", Value: bson.D{{Key: "fee", Value: 1}}}},
+        bson.D{{Key: "$skip", Value: (epoch.Transactions - 1) / 2}},
+        bson.D{{Key: "$limit", Value: 1}},
+    }, options.Aggregate().SetAllowDiskUse(true))
+    if err != nil {
+        return 0, err
+    }
+    defer cursor.Close(ctx)
+
+    var fees []struct {
+        Fee int64 `bson:"fee"`
+    }
+    if err = cursor.All(ctx, &fees); err != nil {
+        return 0, err
+    }
+    if len(fees) == 0 {
+        return 0, nil
+    }
+    return fees[0].Fee, nil
+}
+
+// GetFeesRollups returns the layer or epoch fees between from and to included, sorted by
+// bucket.
+func (m *ReadDB) GetFeesRollups(granularity string, from int64, to int64) ([]*types.FeesRollupDoc, error) {
+    rollupsColl := m.client.Database(database).Collection(feesRollupsCollection)
+
+    ctx := context.TODO()
+    cursor, err := rollupsColl.Find(ctx, bson.D{
+        {Key: "_id.granularity", Value: granularity},
+        {Key: "_id.bucket", Value: bson.D{{Key: "$gte", Value: from}, {Key: "$lte", Value: to}}},
+    }, options.Find().SetSort(bson.D{{Key: "_id.bucket", Value: 1}}))
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    rollups := make([]*types.FeesRollupDoc, 0)
+    if err = cursor.All(ctx, &rollups); err != nil {
+        return nil, err
+    }
+    return rollups, nil
+}
+
+// GetLastFeesRollupEpoch returns the last epoch rolled up, zero when there is none.
+func (m *ReadDB) GetLastFeesRollupEpoch() (uint32, error) {
+    rollupsColl := m.client.Database(database).Collection(feesRollupsCollection)
+
+    rollup := &types.FeesRollupDoc{}
+    err := rollupsColl.FindOne(
+        context.TODO(),
+        bson.D{{Key: "_id.granularity", Value: RollupEpoch}},
+        options.FindOne().SetSort(bson.D{{Key: "_id.bucket", Value: -1}}),
+    ).Decode(rollup)
+    if err == mongo.ErrNoDocuments {
+        return 0, nil
+    }
+    if err != nil {
+        return 0, err
+    }
+    return uint32(rollup.Id.Bucket), nil
+}
+
+// GetRecentGas returns the gas and gas price of the last limit applied transactions from
+// fromLayer on, the fee estimation is computed from them. A method of -1 returns every
+// method.
+func (m *ReadDB) GetRecentGas(fromLayer uint32, method int, limit int64) ([]*types.TransactionDoc, error) {
+    transactionsColl := m.client.Database(database).Collection(transactionsCollection)
+
+    filter := bson.D{
+        {Key: "complete", Value: true},
+        {Key: "layer", Value: bson.D{{Key: "$gte", Value: fromLayer}}},
+    }
+    if method > -1 {
+        filter = append(filter, bson.E{Key: "method", Value: method})
+    }
+    ctx := context.TODO()
+    cursor, err := transactionsColl.Find(ctx, filter, options.Find().
+        SetProjection(bson.D{{Key: "gas", Value: 1}, {Key: "gas_price", Value: 1}}).
+        SetSort(bson.D{{Key: "layer", Value: -1}}).
+        SetLimit(limit))
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    transactions := make([]*types.TransactionDoc, 0)
+    if err = cursor.All(ctx, &transactions); err != nil {
+        return nil, err
+    }
+    return transactions, nil
+}
@@ -0,0 +1,218 @@
+package database
+
+import (
+    "context"
+    "errors"
+    "log"
+    "time"
+
+    "github.com/swarmbit/spacemesh-state-api/pkg/apperror"
+    "github.com/swarmbit/spacemesh-state-api/types"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const fencesCollection = "fences"
+const sinkFence = "sink"
+
+// ErrFenced is returned by writes once a newer instance acquired the sink fence.
+var ErrFenced error = apperror.New(apperror.Conflict, "sink fence acquired by a newer instance")
+
+// AcquireFence registers instanceId as the writer of the database with a new
+// generation. The generation is the start time of the instance, the fence is only
+// claimed while the stored one is older so of two instances started together only the
+// later one writes. An instance holding an older generation stops writing at its next
+// write, so two connectors never interleave their updates.
+func (m *WriteDB) AcquireFence(instanceId string) (*types.FenceDoc, error) {
+    fencesColl := m.client.Database(database).Collection(fencesCollection)
+    now := time.Now()
+    fence := &types.FenceDoc{}
+    err := fencesColl.FindOneAndUpdate(
+        context.TODO(),
+        bson.D{{Key: "_id", Value: sinkFence}, {Key: "generation", Value: bson.D{{Key: "$lt", Value: fenceGeneration(now)}}}},
+        bson.D{
+            {Key: "$set", Value: bson.D{
+                {Key: "generation", Value: fenceGeneration(now)},
+                {Key: "instanceId", Value: instanceId},
+                {Key: "acquiredAt", Value: now},
+            }},
+        },
+        options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+    ).Decode(fence)
+    // the upsert conflicts with the fence of a newer instance
+    if mongo.IsDuplicateKeyError(err) {
+        return nil, ErrFenced
+    }
+    if err != nil {
+        return nil, err
+    }
+    m.fence.Store(fence)
+    m.fenced.Store(false)
+    log.Printf("Acquired sink fence generation %d for %s", fence.Generation, instanceId)
+    return fence, nil
+}
+
+// fenceGeneration is the generation an instance claims the fence with at now.
+func fenceGeneration(now time.Time) int64 {
+    return now.UnixNano()
+}
+
+// StartFenceCheck periodically compares the stored fence with the one this instance
+// acquired and fences the instance off when a newer generation shows up. The check
+// stops once the instance is fenced off.
+func (m *WriteDB) StartFenceCheck(interval time.Duration) {
+    ticker := time.NewTicker(interval)
+    go func() {
+        for range ticker.C {
+            if m.Fenced() || m.checkFence() {
+                ticker.Stop()
+                return
+            }
+        }
+    }()
+}
+
+func (m *WriteDB) checkFence() bool {
+    fence := m.fence.Load()
+    if fence == nil {
+        return false
+    }
+    fencesColl := m.client.Database(database).Collection(fencesCollection)
+    current := &types.FenceDoc{}
+    err := fencesColl.FindOne(context.TODO(), bson.D{{Key: "_id", Value: sinkFence}}).Decode(current)
+    if err != nil {
+        log.Printf("Failed to check sink fence: %v", err)
+        return false
+    }
+    if current.Generation != fence.Generation {
+        log.Printf("Sink fence generation %d taken by %s, stop writing", current.Generation, current.InstanceId)
+        m.fenced.Store(true)
+        return true
+    }
+    return false
+}
+
+// verifyFence checks in the write transaction of ctx that the fence still has the
+// generation of this instance, so a takeover stops the writes of the old instance at
+// once instead of at the next check. A takeover committed while the transaction runs is
+// noticed by the next one.
+func (m *WriteDB) verifyFence(ctx context.Context) error {
+    fence := m.fence.Load()
+    if fence == nil {
+        return nil
+    }
+    fencesColl := m.client.Database(database).Collection(fencesCollection)
+    err := fencesColl.FindOne(ctx, bson.D{{Key: "_id", Value: sinkFence}, {Key: "generation", Value: fence.Generation}}).Err()
+    if errors.Is(err, mongo.ErrNoDocuments) {
+        log.Printf("Sink fence generation %d taken over, stop writing", fence.Generation)
+        m.fenced.Store(true)
+        return ErrFenced
+    }
+    return err
+}
+
+// Fenced reports if a newer instance took over and this one must not write anymore.
+func (m *WriteDB) Fenced() bool {
+    return m.fenced.Load()
+}
+
+// FenceOff stops the writes of this instance until it acquires the fence again, a
+// leader does it when its lease may have expired.
+func (m *WriteDB) FenceOff() {
+    m.fenced.Store(true)
+}
+
+// fenceToken is stored with the layer checkpoints to know which instance wrote them.
+func (m *WriteDB) fenceToken() bson.D {
+    fence := m.fence.Load()
+    if fence == nil {
+        return nil
+    }
+    return bson.D{
+        {Key: "instanceId", Value: fence.InstanceId},
+        {Key: "generation", Value: fence.Generation},
+    }
+}
+
+// ErrLeaseHeld is returned by AcquireLease while another instance holds a valid lease.
+var ErrLeaseHeld error = apperror.New(apperror.Conflict, "writer lease held by another instance")
+
+// AcquireLease takes the sink fence like AcquireFence but only when no other instance
+// holds an unexpired lease on it. The fence gets a newer generation so a leader that
+// stopped renewing but is still running is fenced off at its next write.
+func (m *WriteDB) AcquireLease(instanceId string, ttl time.Duration) (*types.FenceDoc, error) {
+    fencesColl := m.client.Database(database).Collection(fencesCollection)
+    now := time.Now()
+    fence := &types.FenceDoc{}
+    err := fencesColl.FindOneAndUpdate(
+        context.TODO(),
+        bson.D{
+            {Key: "_id", Value: sinkFence},
+            {Key: "generation", Value: bson.D{{Key: "$lt", Value: fenceGeneration(now)}}},
+            {Key: "$or", Value: bson.A{
+                bson.D{{Key: "leaseUntil", Value: bson.D{{Key: "$exists", Value: false}}}},
+                bson.D{{Key: "leaseUntil", Value: bson.D{{Key: "$lt", Value: now}}}},
+                bson.D{{Key: "instanceId", Value: instanceId}},
+            }},
+        },
+        bson.D{
+            {Key: "$set", Value: bson.D{
+                {Key: "generation", Value: fenceGeneration(now)},
+                {Key: "instanceId", Value: instanceId},
+                {Key: "acquiredAt", Value: now},
+                {Key: "leaseUntil", Value: now.Add(ttl)},
+            }},
+        },
+        options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+    ).Decode(fence)
+    // the upsert conflicts with the fence of the current leader
+    if mongo.IsDuplicateKeyError(err) {
+        return nil, ErrLeaseHeld
+    }
+    if err != nil {
+        return nil, err
+    }
+    m.fence.Store(fence)
+    m.fenced.Store(false)
+    log.Printf("Acquired writer lease generation %d for %s", fence.Generation, instanceId)
+    return fence, nil
+}
+
+// RenewLease extends the lease of the acquired generation, the instance is fenced off
+// when the generation was taken over in the meantime.
+func (m *WriteDB) RenewLease(ttl time.Duration) error {
+    fence := m.fence.Load()
+    if fence == nil {
+        return ErrFenced
+    }
+    fencesColl := m.client.Database(database).Collection(fencesCollection)
+    result, err := fencesColl.UpdateOne(
+        context.TODO(),
+        bson.D{{Key: "_id", Value: sinkFence}, {Key: "generation", Value: fence.Generation}},
+        bson.D{{Key: "$set", Value: bson.D{{Key: "leaseUntil", Value: time.Now().Add(ttl)}}}},
+    )
+    if err != nil {
+        return err
+    }
+    if result.MatchedCount == 0 {
+        m.fenced.Store(true)
+        return ErrFenced
+    }
+    return nil
+}
+
+// ReleaseLease expires the lease so a standby takes over without waiting for the ttl.
+func (m *WriteDB) ReleaseLease() error {
+    fence := m.fence.Load()
+    if fence == nil {
+        return nil
+    }
+    fencesColl := m.client.Database(database).Collection(fencesCollection)
+    _, err := fencesColl.UpdateOne(
+        context.TODO(),
+        bson.D{{Key: "_id", Value: sinkFence}, {Key: "generation", Value: fence.Generation}},
+        bson.D{{Key: "$set", Value: bson.D{{Key: "leaseUntil", Value: time.Now()}}}},
+    )
+    return err
+}
@@ -0,0 +1,118 @@
+package database
+
+import (
+    "context"
+    "time"
+
+    "github.com/swarmbit/spacemesh-state-api/types"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// genesisImportId marks in the network info collection that the genesis ledger was
+// imported.
+const genesisImportId = "genesis"
+
+// ImportGenesisLedger adds the genesis balances to the accounts at layer 0 in one
+// transaction with the import mark, so a second start or a concurrent sink does not add
+// them again. Without transactions the mark counts the accounts added, an import stopped
+// by a failure resumes after them on the next start.
+func (m *WriteDB) ImportGenesisLedger(accounts []*types.AccountDoc) (bool, error) {
+    if !m.transactions {
+        return m.importGenesisLedgerInSteps(accounts)
+    }
+    err := m.withTransaction(context.TODO(), func(ctx context.Context) error {
+        networkInfoColl := m.client.Database(database).Collection(networkInfoCollection)
+        _, err := networkInfoColl.InsertOne(ctx, bson.D{
+            {Key: "_id", Value: genesisImportId},
+            {Key: "accounts", Value: len(accounts)},
+            {Key: "importedAt", Value: time.Now().Unix()},
+        })
+        if err != nil {
+            return err
+        }
+        for _, account := range accounts {
+            if err = m.addGenesisAccount(ctx, account); err != nil {
+                return err
+            }
+        }
+        return nil
+    })
+    if mongo.IsDuplicateKeyError(err) {
+        return false, nil
+    }
+    if err != nil {
+        return false, err
+    }
+    return true, nil
+}
+
+// importGenesisLedgerInSteps adds the accounts one by one and records each in the
+// imported count of the mark. A failure between an account and its count adds that
+// account again on the resume.
+func (m *WriteDB) importGenesisLedgerInSteps(accounts []*types.AccountDoc) (bool, error) {
+    ctx := context.TODO()
+    networkInfoColl := m.client.Database(database).Collection(networkInfoCollection)
+    _, err := networkInfoColl.InsertOne(ctx, bson.D{
+        {Key: "_id", Value: genesisImportId},
+        {Key: "accounts", Value: len(accounts)},
+        {Key: "imported", Value: 0},
+        {Key: "importedAt", Value: time.Now().Unix()},
+    })
+    first := 0
+    if mongo.IsDuplicateKeyError(err) {
+        mark := struct {
+            Accounts int  `bson:"accounts"`
+            Imported *int `bson:"imported"`
+        }{}
+        err = networkInfoColl.FindOne(ctx, bson.D{{Key: "_id", Value: genesisImportId}}).Decode(&mark)
+        if err != nil {
+            return false, err
+        }
+        // marks saved in a transaction have no count, their import is complete
+        if mark.Imported == nil || *mark.Imported >= mark.Accounts {
+            return false, nil
+        }
+        first = *mark.Imported
+    } else if err != nil {
+        return false, err
+    }
+
+    for i := first; i < len(accounts); i++ {
+        err = m.withTransaction(ctx, func(ctx context.Context) error {
+            return m.addGenesisAccount(ctx, accounts[i])
+        })
+        if err != nil {
+            return false, err
+        }
+        _, err = networkInfoColl.UpdateOne(
+            ctx,
+            bson.D{{Key: "_id", Value: genesisImportId}},
+            bson.D{{Key: "$set", Value: bson.D{{Key: "imported", Value: i + 1}}}},
+        )
+        if err != nil {
+            return false, err
+        }
+    }
+    return true, nil
+}
+
+// addGenesisAccount adds the genesis balance of account at layer 0.
+func (m *WriteDB) addGenesisAccount(ctx context.Context, account *types.AccountDoc) error {
+    accountsColl := m.client.Database(database).Collection(accountsCollection)
+    _, err := accountsColl.UpdateOne(
+        ctx,
+        bson.D{{Key: "_id", Value: account.Address}},
+        bson.D{{Key: "$inc", Value: bson.D{{Key: "balance", Value: account.Balance}}}},
+        options.Update().SetUpsert(true),
+    )
+    if err != nil {
+        return err
+    }
+    _, err = m.incBalanceChange(ctx, account.Address, 0, int64(account.Balance))
+    if err != nil {
+        return err
+    }
+    return m.recordAccountChange(ctx, account.Address)
+}
@@ -0,0 +1,170 @@
+package database
+
+import (
+    "context"
+
+    "github.com/swarmbit/spacemesh-state-api/types"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GetHighestAtx returns the atx of epoch with the highest tick height whose node is not
+// in excludedNodes, an empty document when there is none. Equal heights are decided by
+// the lowest id.
+func (m *ReadDB) GetHighestAtx(epoch uint64, excludedNodes []string) (*types.AtxDoc, error) {
+    return findHighestAtx(context.TODO(), m.client, epoch, excludedNodes)
+}
+
+// GetHighestAtxs returns up to limit atxs of epoch whose node is not in excludedNodes,
+// highest tick height first in the order of GetHighestAtx.
+func (m *ReadDB) GetHighestAtxs(epoch uint64, excludedNodes []string, limit int64) ([]*types.AtxDoc, error) {
+    return findHighestAtxs(context.TODO(), m.client, epoch, excludedNodes, limit)
+}
+
+func findHighestAtx(ctx context.Context, client *mongo.Client, epoch uint64, excludedNodes []string) (*types.AtxDoc, error) {
+    atx, err := findHighestAtxs(ctx, client, epoch, excludedNodes, 1)
+    if err != nil {
+        return nil, err
+    }
+    if len(atx) == 0 {
+        return &types.AtxDoc{}, nil
+    }
+    return atx[0], nil
+}
+
+func findHighestAtxs(ctx context.Context, client *mongo.Client, epoch uint64, excludedNodes []string, limit int64) ([]*types.AtxDoc, error) {
+    atxColl := client.Database(database).Collection(atxsCollection)
+
+    match := bson.D{{Key: "publishepoch", Value: epoch}}
+    if len(excludedNodes) > 0 {
+        match = append(match, bson.E{Key: "node_id", Value: bson.D{{Key: "$nin", Value: excludedNodes}}})
+    }
+    pipeline := mongo.Pipeline{
+        {{Key: "$match", Value: match}},
+        {{Key: "$addFields", Value: bson.D{
+            {Key: "height", Value: bson.D{{Key: "$add", Value: bson.A{"$base_tick", "$tick_count"}}}},
+        }}},
+        {{Key: "$sort", Value: bson.D{
+            {Key: "height", Value: -1},
+            {Key: "_id", Value: 1},
+        }}},
+        {{Key: "$limit", Value: limit}},
+    }
+
+    cursor, err := atxColl.Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    var atx []*types.AtxDoc
+    if err = cursor.All(ctx, &atx); err != nil {
+        return nil, err
+    }
+    return atx, nil
+}
+
+// updateHighestAtx makes atxDoc the highest atx of its publish epoch when it is higher
+// than the current one, equal heights keep the lowest id. The epoch totals must exist,
+// atxs of malfeasant nodes are skipped.
+func (m *WriteDB) updateHighestAtx(ctx context.Context, atxDoc *types.AtxDoc) error {
+    nodesColl := m.client.Database(database).Collection(nodesCollection)
+    malfeasant, err := nodesColl.CountDocuments(ctx, bson.D{
+        {Key: "_id", Value: atxDoc.NodeID},
+        {Key: "malfeasance", Value: bson.D{{Key: "$exists", Value: true}}},
+    })
+    if err != nil || malfeasant > 0 {
+        return err
+    }
+
+    height := atxDoc.BaseTick + atxDoc.TickCount
+    atxsEpochsColl := m.client.Database(database).Collection(atxsEpochsCollection)
+    _, err = atxsEpochsColl.UpdateOne(
+        ctx,
+        bson.D{
+            {Key: "_id", Value: atxDoc.PublishEpoch},
+            {Key: "$or", Value: bson.A{
+                bson.D{{Key: "highestAtx", Value: bson.D{{Key: "$exists", Value: false}}}},
+                bson.D{{Key: "highestTick", Value: bson.D{{Key: "$lt", Value: height}}}},
+                bson.D{
+                    {Key: "highestTick", Value: height},
+                    {Key: "highestAtx", Value: bson.D{{Key: "$gt", Value: atxDoc.AtxID}}},
+                },
+            }},
+        },
+        bson.D{{Key: "$set", Value: bson.D{
+            {Key: "highestAtx", Value: atxDoc.AtxID},
+            {Key: "highestTick", Value: height},
+            {Key: "highestNode", Value: atxDoc.NodeID},
+        }}},
+    )
+    return err
+}
+
+// recomputeHighestAtxs finds the highest atx again for the epoch totals in collection
+// matching filter, after a malfeasance proof or a rebuild of the totals. ctx is the
+// session context when it runs in a transaction.
+func (m *WriteDB) recomputeHighestAtxs(ctx context.Context, collection string, filter bson.D) error {
+    atxsEpochsColl := m.client.Database(database).Collection(collection)
+    epochs, err := atxsEpochsColl.Distinct(ctx, "_id", filter)
+    if err != nil || len(epochs) == 0 {
+        return err
+    }
+
+    excludedNodes, err := m.malfeasanceNodeIds(ctx)
+    if err != nil {
+        return err
+    }
+    for _, value := range epochs {
+        var epoch uint64
+        switch v := value.(type) {
+        case int32:
+            epoch = uint64(v)
+        case int64:
+            epoch = uint64(v)
+        default:
+            continue
+        }
+        atx, err := findHighestAtx(ctx, m.client, epoch, excludedNodes)
+        if err != nil {
+            return err
+        }
+        update := bson.D{{Key: "$unset", Value: bson.D{
+            {Key: "highestAtx", Value: ""},
+            {Key: "highestTick", Value: ""},
+            {Key: "highestNode", Value: ""},
+        }}}
+        if atx.AtxID != "" {
+            update = bson.D{{Key: "$set", Value: bson.D{
+                {Key: "highestAtx", Value: atx.AtxID},
+                {Key: "highestTick", Value: atx.BaseTick + atx.TickCount},
+                {Key: "highestNode", Value: atx.NodeID},
+            }}}
+        }
+        _, err = atxsEpochsColl.UpdateOne(ctx, bson.D{{Key: "_id", Value: value}}, update)
+        if err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+func (m *WriteDB) malfeasanceNodeIds(ctx context.Context) ([]string, error) {
+    nodesColl := m.client.Database(database).Collection(nodesCollection)
+    values, err := nodesColl.Distinct(
+        ctx,
+        "_id",
+        bson.D{{Key: "malfeasance", Value: bson.D{{Key: "$exists", Value: true}}}},
+    )
+    if err != nil {
+        return nil, err
+    }
+    nodeIds := make([]string, 0, len(values))
+    for _, value := range values {
+        if nodeId, ok := value.(string); ok {
+            nodeIds = append(nodeIds, nodeId)
+        }
+    }
+    return nodeIds, nil
+}
@@ -0,0 +1,85 @@
+package database
+
+import (
+    "context"
+    "time"
+
+    "github.com/swarmbit/spacemesh-state-api/types"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const networkHistoryCollection = "networkHistory"
+
+const networkInfoHistoryCollection = "networkInfoHistory"
+
+func (m *WriteDB) SaveNetworkSnapshot(snapshot *types.NetworkSnapshotDoc) error {
+    if m.Fenced() {
+        return ErrFenced
+    }
+    historyColl := m.client.Database(database).Collection(networkHistoryCollection)
+    _, err := historyColl.InsertOne(context.TODO(), snapshot)
+    return err
+}
+
+func (m *ReadDB) GetNetworkSnapshots(from time.Time, to time.Time) ([]*types.NetworkSnapshotDoc, error) {
+    historyColl := m.client.Database(database).Collection(networkHistoryCollection)
+
+    ctx := context.TODO()
+    cursor, err := historyColl.Find(
+        ctx,
+        bson.D{{Key: "timestamp", Value: bson.D{
+            {Key: "$gte", Value: from},
+            {Key: "$lte", Value: to},
+        }}},
+        options.Find().SetSort(bson.M{"timestamp": 1}),
+    )
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    snapshots := make([]*types.NetworkSnapshotDoc, 0)
+    if err = cursor.All(ctx, &snapshots); err != nil {
+        return nil, err
+    }
+    return snapshots, nil
+}
+
+func (m *WriteDB) SaveNetworkInfoHistory(info *types.NetworkInfoHistoryDoc) error {
+    if m.Fenced() {
+        return ErrFenced
+    }
+    historyColl := m.client.Database(database).Collection(networkInfoHistoryCollection)
+    _, err := historyColl.ReplaceOne(
+        context.TODO(),
+        bson.D{{Key: "_id", Value: info.Layer}},
+        info,
+        options.Replace().SetUpsert(true),
+    )
+    return err
+}
+
+func (m *ReadDB) GetNetworkInfoHistory(from uint64, to uint64) ([]*types.NetworkInfoHistoryDoc, error) {
+    historyColl := m.client.Database(database).Collection(networkInfoHistoryCollection)
+
+    ctx := context.TODO()
+    cursor, err := historyColl.Find(
+        ctx,
+        bson.D{{Key: "_id", Value: bson.D{
+            {Key: "$gte", Value: from},
+            {Key: "$lte", Value: to},
+        }}},
+        options.Find().SetSort(bson.M{"_id": 1}),
+    )
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    history := make([]*types.NetworkInfoHistoryDoc, 0)
+    if err = cursor.All(ctx, &history); err != nil {
+        return nil, err
+    }
+    return history, nil
+}
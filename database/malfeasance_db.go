@@ -0,0 +1,70 @@
+package database
+
+import (
+    "context"
+
+    "github.com/swarmbit/spacemesh-state-api/types"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GetMalfeasanceNodesPaginated pages the malfeasant nodes, the latest proofs first.
+func (m *ReadDB) GetMalfeasanceNodesPaginated(skip int64, limit int64) ([]*types.NodeDoc, error) {
+    nodesColl := m.client.Database(database).Collection(nodesCollection)
+
+    findOptions := options.Find()
+    findOptions.SetSkip(skip)
+    findOptions.SetLimit(limit)
+    findOptions.SetSort(bson.D{
+        {Key: "malfeasance.received", Value: -1},
+        {Key: "_id", Value: 1},
+    })
+
+    ctx := context.TODO()
+    cursor, err := nodesColl.Find(
+        ctx,
+        bson.D{{Key: "malfeasance", Value: bson.D{{Key: "$exists", Value: true}}}},
+        findOptions,
+    )
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    var nodes []*types.NodeDoc
+    if err = cursor.All(ctx, &nodes); err != nil {
+        return nil, err
+    }
+    return nodes, nil
+}
+
+func (m *ReadDB) CountMalfeasanceNodes() (int64, error) {
+    nodesColl := m.client.Database(database).Collection(nodesCollection)
+    return nodesColl.CountDocuments(
+        context.TODO(),
+        bson.D{{Key: "malfeasance", Value: bson.D{{Key: "$exists", Value: true}}}},
+    )
+}
+
+// FilterMalfeasanceNodes returns the nodes of nodeIds that are malfeasant.
+func (m *ReadDB) FilterMalfeasanceNodes(nodeIds []string) ([]string, error) {
+    nodesColl := m.client.Database(database).Collection(nodesCollection)
+    values, err := nodesColl.Distinct(
+        context.TODO(),
+        "_id",
+        bson.D{
+            {Key: "_id", Value: bson.D{{Key: "$in", Value: nodeIds}}},
+            {Key: "malfeasance", Value: bson.D{{Key: "$exists", Value: true}}},
+        },
+    )
+    if err != nil {
+        return nil, err
+    }
+    malfeasant := make([]string, 0, len(values))
+    for _, value := range values {
+        if nodeId, ok := value.(string); ok {
+            malfeasant = append(malfeasant, nodeId)
+        }
+    }
+    return malfeasant, nil
+}
@@ -0,0 +1,193 @@
+package database
+
+import (
+    "context"
+    "log"
+
+    "github.com/swarmbit/spacemesh-state-api/config"
+    "github.com/swarmbit/spacemesh-state-api/migrations"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func index(keys ...string) mongo.IndexModel {
+    return indexWithOrder(keys, 1)
+}
+
+func descIndex(keys ...string) mongo.IndexModel {
+    return indexWithOrder(keys, -1)
+}
+
+func indexWithOrder(keys []string, order int) mongo.IndexModel {
+    d := bson.D{}
+    for _, v := range keys {
+        d = append(d, bson.E{Key: v, Value: order})
+    }
+    return mongo.IndexModel{
+        Keys:    d,
+        Options: options.Index().SetUnique(false),
+    }
+}
+
+// requiredIndexes are verified on every start, missing ones are created.
+var requiredIndexes = []migrations.CollectionIndexes{
+    {Collection: rewardsCollection, Indexes: []mongo.IndexModel{
+        index("coinbase", "layer"),
+        index("node_id", "layer"),
+        index("layer"),
+    }},
+    {Collection: transactionsCollection, Indexes: []mongo.IndexModel{
+        index("principal_account", "layer"),
+        index("receiver_account", "layer"),
+        index("layer"),
+        index("complete", "layer"),
+        index("complete", "created_at"),
+        index("vault_account"),
+    }},
+    {Collection: accountsCollection, Indexes: []mongo.IndexModel{
+        descIndex("balance"),
+    }},
+    {Collection: multisigSignaturesCollection, Indexes: []mongo.IndexModel{
+        index("account", "layer"),
+    }},
+    {Collection: atxsCollection, Indexes: []mongo.IndexModel{
+        index("_id", "publishepoch"),
+        index("node_id", "publishepoch"),
+        index("publishepoch", "node_id"),
+        index("coinbase", "publishepoch"),
+        index("publishepoch"),
+        index("publishepoch", "effective_num_units"),
+    }},
+    {Collection: accountAtxsEpochsCollection, Indexes: []mongo.IndexModel{
+        index("_id", "totalWeight"),
+    }},
+    {Collection: nodesCollection, Indexes: []mongo.IndexModel{
+        descIndex("malfeasance.received"),
+    }},
+    {Collection: smeshersCollection, Indexes: []mongo.IndexModel{
+        descIndex("effectiveNumUnits"),
+        descIndex("totalRewards"),
+        descIndex("totalAtx"),
+    }},
+    {Collection: smeshersEpochsCollection, Indexes: []mongo.IndexModel{
+        {
+            Keys: bson.D{
+                {Key: "_id.epoch", Value: 1},
+                {Key: "rewards", Value: -1},
+            },
+            Options: options.Index().SetUnique(false),
+        },
+        index("_id.node_id"),
+    }},
+    {Collection: pricesCollection, Indexes: []mongo.IndexModel{
+        index("timestamp"),
+    }},
+    {Collection: networkHistoryCollection, Indexes: []mongo.IndexModel{
+        index("timestamp"),
+    }},
+    {Collection: accountRewardsRollupsCollection, Indexes: []mongo.IndexModel{
+        index("_id.account", "_id.granularity", "_id.bucket"),
+    }},
+    {Collection: networkRewardsRollupsCollection, Indexes: []mongo.IndexModel{
+        index("_id.granularity", "_id.bucket"),
+    }},
+    {Collection: feesRollupsCollection, Indexes: []mongo.IndexModel{
+        index("_id.granularity", "_id.bucket"),
+    }},
+    {Collection: blocksCollection, Indexes: []mongo.IndexModel{
+        index("layer"),
+    }},
+    {Collection: balanceChangesCollection, Indexes: []mongo.IndexModel{
+        index("_id.account", "_id.layer"),
+    }},
+    {Collection: smeshersPerformanceCollection, Indexes: []mongo.IndexModel{
+        index("_id.node_id", "_id.epoch"),
+        index("_id.epoch"),
+    }},
+    {Collection: webhooksCollection, Indexes: []mongo.IndexModel{
+        index("owner"),
+    }},
+    {Collection: webhookDeliveriesCollection, Indexes: []mongo.IndexModel{
+        index("status", "nextAttempt"),
+        index("webhook", "createdAt"),
+    }},
+    {Collection: watchlistsCollection, Indexes: []mongo.IndexModel{
+        index("owner", "createdAt"),
+    }},
+    {Collection: emailSubscriptionsCollection, Indexes: []mongo.IndexModel{
+        index("owner", "createdAt"),
+        index("token"),
+        {
+            Keys:    bson.D{{Key: "owner", Value: 1}, {Key: "email", Value: 1}},
+            Options: options.Index().SetUnique(true),
+        },
+        // subscriptions saved before the slots were added have none
+        {
+            Keys: bson.D{{Key: "owner", Value: 1}, {Key: "slot", Value: 1}},
+            Options: options.Index().SetUnique(true).
+                SetPartialFilterExpression(bson.D{{Key: "slot", Value: bson.D{{Key: "$exists", Value: true}}}}),
+        },
+    }},
+    {Collection: poolRewardsCollection, Indexes: []mongo.IndexModel{
+        index("epoch", "nodeId"),
+    }},
+    {Collection: rewardDigestsCollection, Indexes: []mongo.IndexModel{
+        index("_id.coinbase", "_id.day"),
+        index("_id.day"),
+    }},
+}
+
+// schemaMigrations are applied once, in version order. New entries go at the end with
+// the next version number, applied versions must never change.
+var schemaMigrations = []migrations.Migration{
+    {
+        Version:     1,
+        Description: "baseline schema, collections and indexes as created by earlier releases",
+    },
+    {
+        Version:     2,
+        Description: "coinbase rewards totals summed from the stored rewards",
+        Up: func(db *mongo.Database) error {
+            return aggregateCoinbaseRewardsTotals(db, coinbaseRewardsTotalsCollection)
+        },
+    },
+    {
+        Version:     accountActivityVersion,
+        Description: "account first seen and last activity layers and transfer counts computed from the rewards and transactions",
+        Up:          aggregateAccountActivity,
+    },
+}
+
+// EnsureIndexes creates the required indexes that are missing, for indexes dropped by
+// hand while the connector runs.
+func (m *WriteDB) EnsureIndexes() error {
+    return migrations.EnsureIndexes(m.client.Database(database), requiredIndexes)
+}
+
+func migrate(client *mongo.Client) error {
+    err := migrations.Run(client.Database(database), requiredIndexes, schemaMigrations)
+    if err != nil {
+        log.Println(err)
+    }
+    return err
+}
+
+// RunMigrations connects to the database, applies the migrations and disconnects. The
+// sql schemas are created when the connection is opened.
+func RunMigrations(dbConfig *config.DBConfig) error {
+    if dbConfig.Backend == BackendPostgres || dbConfig.Backend == BackendSqlite {
+        writeDB, _, err := NewStores(dbConfig)
+        if err != nil {
+            return err
+        }
+        writeDB.CloseWrite()
+        return nil
+    }
+    client, err := connectMongo(dbConfig)
+    if err != nil {
+        return err
+    }
+    defer client.Disconnect(context.TODO())
+    return migrate(client)
+}
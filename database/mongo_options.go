@@ -0,0 +1,81 @@
+package database
+
+import (
+    "fmt"
+    "strconv"
+    "time"
+
+    "github.com/swarmbit/spacemesh-state-api/config"
+    "github.com/swarmbit/spacemesh-state-api/tracing"
+    "go.mongodb.org/mongo-driver/mongo/options"
+    "go.mongodb.org/mongo-driver/mongo/readpref"
+    "go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+// mongoClientOptions builds the client options from the config, settings left empty
+// keep the driver defaults except the pool size of 10. The read preference only
+// applies to read clients, the write client keeps reading from the primary so the
+// fence and the sink checks never see stale data. Every client decodes the versioned
+// documents in their current format.
+func mongoClientOptions(dbConnection string, mongoConfig *config.MongoConfig, read bool) (*options.ClientOptions, error) {
+    clientOptions := options.Client().ApplyURI(dbConnection).SetMaxPoolSize(10).SetRegistry(documentRegistry())
+    if tracing.Enabled() {
+        clientOptions.SetMonitor(tracing.MongoMonitor())
+    }
+    if mongoConfig == nil {
+        return clientOptions, nil
+    }
+
+    if mongoConfig.MaxPoolSize > 0 {
+        clientOptions.SetMaxPoolSize(uint64(mongoConfig.MaxPoolSize))
+    }
+    if mongoConfig.MinPoolSize > 0 {
+        clientOptions.SetMinPoolSize(uint64(mongoConfig.MinPoolSize))
+    }
+    if mongoConfig.ConnectTimeout > 0 {
+        clientOptions.SetConnectTimeout(time.Duration(mongoConfig.ConnectTimeout) * time.Second)
+    }
+    if mongoConfig.SocketTimeout > 0 {
+        clientOptions.SetSocketTimeout(time.Duration(mongoConfig.SocketTimeout) * time.Second)
+    }
+    if mongoConfig.ServerSelectionTimeout > 0 {
+        clientOptions.SetServerSelectionTimeout(time.Duration(mongoConfig.ServerSelectionTimeout) * time.Second)
+    }
+
+    if read && mongoConfig.QueryTimeout > 0 {
+        clientOptions.SetTimeout(time.Duration(mongoConfig.QueryTimeout) * time.Second)
+    }
+
+    if read && mongoConfig.ReadPreference != "" {
+        mode, err := readpref.ModeFromString(mongoConfig.ReadPreference)
+        if err != nil {
+            return nil, fmt.Errorf("invalid mongo read preference %s", mongoConfig.ReadPreference)
+        }
+        var readPrefOptions []readpref.Option
+        if mongoConfig.MaxStaleness > 0 {
+            readPrefOptions = append(readPrefOptions, readpref.WithMaxStaleness(time.Duration(mongoConfig.MaxStaleness)*time.Second))
+        }
+        readPreference, err := readpref.New(mode, readPrefOptions...)
+        if err != nil {
+            return nil, fmt.Errorf("invalid mongo read preference: %w", err)
+        }
+        clientOptions.SetReadPreference(readPreference)
+    }
+
+    if !read && (mongoConfig.WriteConcern != "" || mongoConfig.Journal || mongoConfig.WriteTimeout > 0) {
+        writeConcern := &writeconcern.WriteConcern{
+            WTimeout: time.Duration(mongoConfig.WriteTimeout) * time.Millisecond,
+        }
+        if w, err := strconv.Atoi(mongoConfig.WriteConcern); err == nil {
+            writeConcern.W = w
+        } else if mongoConfig.WriteConcern != "" {
+            writeConcern.W = mongoConfig.WriteConcern
+        }
+        if mongoConfig.Journal {
+            journal := true
+            writeConcern.Journal = &journal
+        }
+        clientOptions.SetWriteConcern(writeConcern)
+    }
+    return clientOptions, nil
+}
@@ -0,0 +1,104 @@
+package database
+
+import (
+    "context"
+    "encoding/hex"
+
+    sTypes "github.com/spacemeshos/go-spacemesh/common/types"
+    "github.com/spacemeshos/go-spacemesh/nats"
+    transactionparsertypes "github.com/swarmbit/spacemesh-state-api/pkg/transactionparser/transaction"
+    "github.com/swarmbit/spacemesh-state-api/types"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+    multisigsCollection          = "multisigs"
+    multisigSignaturesCollection = "multisigSignatures"
+)
+
+// spawnedMultisigDoc is the multisig a successful multisig or vesting spawn result
+// creates, nil for every other transaction.
+func spawnedMultisigDoc(transaction *nats.Transaction, transactionData *transactionparsertypes.TransactionData) *types.MultisigDoc {
+    if transactionData.Multisig == nil || transaction.Header.Status != uint8(sTypes.TransactionSuccess) {
+        return nil
+    }
+    template := types.MultisigTemplate
+    if transactionData.Type == transactionparsertypes.TypeVestingSpawn {
+        template = types.VestingTemplate
+    }
+    publicKeys := make([]string, 0)
+    for _, publicKey := range transactionData.Multisig.GetPublicKeys() {
+        publicKeys = append(publicKeys, hex.EncodeToString(publicKey))
+    }
+    return &types.MultisigDoc{
+        Address:          transactionData.Multisig.GetMultisig().String(),
+        Template:         template,
+        Required:         transactionData.Multisig.GetRequired(),
+        PublicKeys:       publicKeys,
+        SpawnTransaction: transaction.ID,
+        SpawnLayer:       transaction.Header.LayerID,
+    }
+}
+
+// multisigSignaturesDoc is the signature refs of a result signed with multisig parts, nil
+// for a single signature.
+func multisigSignaturesDoc(transaction *nats.Transaction, transactionData *transactionparsertypes.TransactionData) *types.MultisigSignaturesDoc {
+    if transactionData.Signatures == nil {
+        return nil
+    }
+    refs := make([]uint32, 0, len(*transactionData.Signatures))
+    for _, part := range *transactionData.Signatures {
+        refs = append(refs, uint32(part.Ref))
+    }
+    return &types.MultisigSignaturesDoc{
+        Transaction: transaction.ID,
+        Account:     transaction.Header.Principal,
+        Layer:       transaction.Header.LayerID,
+        Status:      transaction.Header.Status,
+        Type:        uint8(transactionData.Type),
+        Refs:        refs,
+    }
+}
+
+func (m *ReadDB) GetMultisig(address string) (*types.MultisigDoc, error) {
+    multisigsColl := m.client.Database(database).Collection(multisigsCollection)
+    multisigDoc := &types.MultisigDoc{}
+    err := multisigsColl.FindOne(context.TODO(), bson.D{{Key: "_id", Value: address}}).Decode(multisigDoc)
+    if err != nil {
+        if err == mongo.ErrNoDocuments {
+            return &types.MultisigDoc{}, nil
+        }
+        return &types.MultisigDoc{}, err
+    }
+    return multisigDoc, nil
+}
+
+// GetMultisigSignatures returns the signatures seen on transactions of the multisig
+// account, latest first.
+func (m *ReadDB) GetMultisigSignatures(address string, offset int64, limit int64) ([]*types.MultisigSignaturesDoc, error) {
+    signaturesColl := m.client.Database(database).Collection(multisigSignaturesCollection)
+
+    ctx := context.TODO()
+    findOptions := options.Find().
+        SetSort(bson.D{{Key: "layer", Value: -1}}).
+        SetSkip(offset).
+        SetLimit(limit)
+    cursor, err := signaturesColl.Find(ctx, bson.D{{Key: "account", Value: address}}, findOptions)
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    var docs []*types.MultisigSignaturesDoc
+    if err = cursor.All(ctx, &docs); err != nil {
+        return nil, err
+    }
+    return docs, nil
+}
+
+func (m *ReadDB) CountMultisigSignatures(address string) (int64, error) {
+    signaturesColl := m.client.Database(database).Collection(multisigSignaturesCollection)
+    return signaturesColl.CountDocuments(context.TODO(), bson.D{{Key: "account", Value: address}})
+}
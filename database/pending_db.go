@@ -0,0 +1,107 @@
+package database
+
+import (
+    "context"
+    "log"
+    "time"
+
+    "github.com/spacemeshos/go-spacemesh/nats"
+    "github.com/swarmbit/spacemesh-state-api/pkg/transactionparser"
+    transactionparsertypes "github.com/swarmbit/spacemesh-state-api/pkg/transactionparser/transaction"
+    "github.com/swarmbit/spacemesh-state-api/types"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// pendingTransactionDoc is the document of a created transaction, it stays pending until
+// the result completes it. The raw transaction is parsed so pending transactions are
+// found by their receiver, when it can not be parsed only the header is kept.
+func pendingTransactionDoc(transaction *nats.Transaction) *types.TransactionDoc {
+    transactionDoc := &types.TransactionDoc{
+        ID:              transaction.ID,
+        PrincipaAccount: transaction.Header.Principal,
+        Fee:             transaction.Header.Fee,
+        Gas:             transaction.Header.Gas,
+        Layer:           transaction.Header.LayerID,
+        Status:          transaction.Header.Status,
+        Method:          transaction.Header.Method,
+        Complete:        false,
+        CreatedAt:       time.Now().Unix(),
+        Raw:             transaction.Raw,
+        SchemaVersion:   transactionUpgrade.Current(),
+    }
+    transactionData, err := transactionparser.Parse(transaction.Raw)
+    if err != nil {
+        log.Printf("Failed to parse created transaction %s: %v", transaction.ID, err)
+        return transactionDoc
+    }
+    setTransactionFields(transactionDoc, transactionData)
+    return transactionDoc
+}
+
+// setTransactionFields sets the fields of transactionDoc read from its raw transaction.
+func setTransactionFields(transactionDoc *types.TransactionDoc, transactionData *transactionparsertypes.TransactionData) {
+    if receiver := transactionData.Tx.GetReceiver(); len(receiver.Bytes()) > 0 {
+        transactionDoc.ReceiverAccount = receiver.String()
+    }
+    if transactionData.Type == transactionparsertypes.TypeDrainVault {
+        transactionDoc.VaultAccount = transactionData.Vault.GetVault().String()
+    }
+    transactionDoc.Type = transactionData.Tx.GetType()
+    transactionDoc.Amount = transactionData.Tx.GetAmount()
+    transactionDoc.Counter = transactionData.Tx.GetCounter()
+    transactionDoc.GasPrice = transactionData.Tx.GetGasPrice()
+}
+
+// pendingCreatedBefore matches the pending transactions created before createdBefore,
+// the ones saved before the creation time was recorded have none and match too. Their
+// layer can not be used, the node publishes created transactions with layer 0.
+func pendingCreatedBefore(createdBefore int64) bson.D {
+    return bson.D{
+        {Key: "complete", Value: false},
+        {Key: "created_at", Value: bson.D{{Key: "$not", Value: bson.D{{Key: "$gte", Value: createdBefore}}}}},
+    }
+}
+
+// ExpirePendingTransactions deletes the pending transactions created before the unix
+// time createdBefore except the ones in keep and returns how many were deleted. A result
+// that still comes saves the transaction again. Deletes are not recorded in the change
+// feed.
+func (m *WriteDB) ExpirePendingTransactions(createdBefore int64, keep []string) (int64, error) {
+    if m.Fenced() {
+        return 0, ErrFenced
+    }
+    transactionsColl := m.client.Database(database).Collection(transactionsCollection)
+    filter := pendingCreatedBefore(createdBefore)
+    if len(keep) > 0 {
+        filter = append(filter, bson.E{Key: "_id", Value: bson.D{{Key: "$nin", Value: keep}}})
+    }
+    result, err := transactionsColl.DeleteMany(context.TODO(), filter)
+    if err != nil {
+        return 0, err
+    }
+    return result.DeletedCount, nil
+}
+
+// GetPendingTransactionIds returns the ids of the pending transactions created before
+// the unix time createdBefore.
+func (m *ReadDB) GetPendingTransactionIds(createdBefore int64) ([]string, error) {
+    transactionsColl := m.client.Database(database).Collection(transactionsCollection)
+
+    ctx := context.TODO()
+    cursor, err := transactionsColl.Find(ctx, pendingCreatedBefore(createdBefore), options.Find().SetProjection(bson.D{{Key: "_id", Value: 1}}))
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    var docs []*types.TransactionDoc
+    if err = cursor.All(ctx, &docs); err != nil {
+        return nil, err
+    }
+    ids := make([]string, len(docs))
+    for i, doc := range docs {
+        ids[i] = doc.ID
+    }
+    return ids, nil
+}
@@ -0,0 +1,42 @@
+package database
+
+import (
+    "context"
+
+    "github.com/swarmbit/spacemesh-state-api/types"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const poetsHealthCollection = "poetsHealth"
+
+func (m *WriteDB) SavePoetHealth(health *types.PoetHealthDoc) error {
+    if m.Fenced() {
+        return ErrFenced
+    }
+    healthColl := m.client.Database(database).Collection(poetsHealthCollection)
+    _, err := healthColl.ReplaceOne(
+        context.TODO(),
+        bson.D{{Key: "_id", Value: health.Name}},
+        health,
+        options.Replace().SetUpsert(true),
+    )
+    return err
+}
+
+func (m *ReadDB) GetPoetsHealth() ([]*types.PoetHealthDoc, error) {
+    healthColl := m.client.Database(database).Collection(poetsHealthCollection)
+
+    ctx := context.TODO()
+    cursor, err := healthColl.Find(ctx, bson.D{}, options.Find().SetSort(bson.M{"_id": 1}))
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    health := make([]*types.PoetHealthDoc, 0)
+    if err = cursor.All(ctx, &health); err != nil {
+        return nil, err
+    }
+    return health, nil
+}
@@ -0,0 +1,96 @@
+package database
+
+import (
+    "context"
+
+    "github.com/swarmbit/spacemesh-state-api/config"
+    "github.com/swarmbit/spacemesh-state-api/types"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const poolRewardsCollection = "poolRewards"
+
+func (m *WriteDB) SavePoolReward(reward *types.PoolRewardDoc) error {
+    if m.Fenced() {
+        return ErrFenced
+    }
+    poolColl := m.client.Database(database).Collection(poolRewardsCollection)
+    _, err := poolColl.ReplaceOne(
+        context.TODO(),
+        bson.D{{Key: "_id", Value: reward.ID}},
+        reward,
+        options.Replace().SetUpsert(true),
+    )
+    return err
+}
+
+// BackfillPoolRewards copies the saved rewards of nodeIds to the pool rewards, the ones
+// already there are kept.
+func (m *WriteDB) BackfillPoolRewards(nodeIds []string) error {
+    if m.Fenced() {
+        return ErrFenced
+    }
+    if len(nodeIds) == 0 {
+        return nil
+    }
+    rewardsColl := m.client.Database(database).Collection(rewardsCollection)
+
+    ctx := context.TODO()
+    cursor, err := rewardsColl.Aggregate(ctx, mongo.Pipeline{
+        {{Key: "$match", Value: bson.D{{Key: "node_id", Value: bson.D{{Key: "$in", Value: nodeIds}}}}}},
+        {{Key: "$project", Value: bson.D{
+            {Key: "nodeId", Value: "$node_id"},
+            {Key: "coinbase", Value: 1},
+            {Key: "layer", Value: 1},
+            {Key: "epoch", Value: bson.D{{Key: "$toLong", Value: bson.D{
+                {Key: "$floor", Value: bson.D{{Key: "$divide", Value: bson.A{"$layer", config.LayersPerEpoch}}}},
+            }}}},
+            {Key: "totalReward", Value: 1},
+        }}},
+        {{Key: "$merge", Value: bson.D{
+            {Key: "into", Value: poolRewardsCollection},
+            {Key: "on", Value: "_id"},
+            {Key: "whenMatched", Value: "keepExisting"},
+            {Key: "whenNotMatched", Value: "insert"},
+        }}},
+    }, options.Aggregate().SetAllowDiskUse(true))
+    if err != nil {
+        return err
+    }
+    return cursor.Close(ctx)
+}
+
+// GetPoolRewards returns the number and the total of the saved pool rewards of every node
+// in the epochs from and to included, by epoch and node.
+func (m *ReadDB) GetPoolRewards(fromEpoch uint32, toEpoch uint32) ([]*types.PoolNodeRewardsDoc, error) {
+    poolColl := m.client.Database(database).Collection(poolRewardsCollection)
+
+    ctx := context.TODO()
+    cursor, err := poolColl.Aggregate(ctx, bson.A{
+        bson.D{{Key: "$match", Value: bson.D{{Key: "epoch", Value: bson.D{
+            {Key: "$gte", Value: fromEpoch},
+            {Key: "$lte", Value: toEpoch},
+        }}}}},
+        bson.D{{Key: "$group", Value: bson.D{
+            {Key: "_id", Value: bson.D{
+                {Key: "epoch", Value: "$epoch"},
+                {Key: "nodeId", Value: "$nodeId"},
+            }},
+            {Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+            {Key: "totalReward", Value: bson.D{{Key: "$sum", Value: "$totalReward"}}},
+        }}},
+        bson.D{{Key: "$sort", Value: bson.D{{Key: "_id.epoch", Value: 1}, {Key: "_id.nodeId", Value: 1}}}},
+    })
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    rewards := make([]*types.PoolNodeRewardsDoc, 0)
+    if err = cursor.All(ctx, &rewards); err != nil {
+        return nil, err
+    }
+    return rewards, nil
+}
@@ -0,0 +1,415 @@
+package database
+
+import (
+    "database/sql"
+
+    _ "github.com/lib/pq"
+)
+
+var postgresDialect = &sqlDialect{
+    name: BackendPostgres,
+    rebind: func(query string) string {
+        return query
+    },
+    shareLock: " FOR SHARE",
+    unixMillis: func(column string) string {
+        return "(EXTRACT(EPOCH FROM " + column + ") * 1000)::BIGINT"
+    },
+    // the planner row estimates, like the estimated counts of mongo
+    tableRows: func(table string) (string, []interface{}) {
+        return `SELECT GREATEST(reltuples, 0)::BIGINT FROM pg_class WHERE relname = $1`, []interface{}{table}
+    },
+    // concurrent saves wait on the row locks of the totals instead of failing
+    capabilities: Capabilities{ParallelSaves: true},
+}
+
+var postgresSchema = []string{
+    `CREATE TABLE IF NOT EXISTS layers (
+        id BIGINT PRIMARY KEY,
+        status INTEGER NOT NULL,
+        writer_instance TEXT,
+        writer_generation BIGINT
+    )`,
+    `CREATE TABLE IF NOT EXISTS rewards (
+        id TEXT PRIMARY KEY,
+        node_id TEXT NOT NULL,
+        coinbase TEXT NOT NULL,
+        atx_id TEXT NOT NULL,
+        layer_reward BIGINT NOT NULL,
+        total_reward BIGINT NOT NULL,
+        layer BIGINT NOT NULL
+    )`,
+    `CREATE INDEX IF NOT EXISTS rewards_coinbase_layer ON rewards (coinbase, layer)`,
+    `CREATE INDEX IF NOT EXISTS rewards_node_id_layer ON rewards (node_id, layer)`,
+    `CREATE INDEX IF NOT EXISTS rewards_layer ON rewards (layer)`,
+    `CREATE TABLE IF NOT EXISTS atxs (
+        id TEXT PRIMARY KEY,
+        node_id TEXT NOT NULL,
+        coinbase TEXT NOT NULL,
+        publish_epoch BIGINT NOT NULL,
+        effective_num_units BIGINT NOT NULL,
+        base_tick BIGINT NOT NULL,
+        weight BIGINT NOT NULL,
+        tick_count BIGINT NOT NULL,
+        sequence BIGINT NOT NULL,
+        received BIGINT NOT NULL
+    )`,
+    `CREATE INDEX IF NOT EXISTS atxs_publish_epoch_node_id ON atxs (publish_epoch, node_id)`,
+    `CREATE INDEX IF NOT EXISTS atxs_node_id_publish_epoch ON atxs (node_id, publish_epoch)`,
+    `CREATE INDEX IF NOT EXISTS atxs_coinbase_publish_epoch ON atxs (coinbase, publish_epoch)`,
+    `CREATE INDEX IF NOT EXISTS atxs_publish_epoch_effective_num_units ON atxs (publish_epoch, effective_num_units)`,
+    `CREATE TABLE IF NOT EXISTS atxs_epochs (
+        epoch BIGINT PRIMARY KEY,
+        total_effective_num_units BIGINT NOT NULL DEFAULT 0,
+        total_weight BIGINT NOT NULL DEFAULT 0,
+        total_atx BIGINT NOT NULL DEFAULT 0,
+        highest_atx TEXT NOT NULL DEFAULT '',
+        highest_tick BIGINT NOT NULL DEFAULT 0,
+        highest_node TEXT NOT NULL DEFAULT ''
+    )`,
+    `ALTER TABLE atxs_epochs ADD COLUMN IF NOT EXISTS highest_atx TEXT NOT NULL DEFAULT ''`,
+    `ALTER TABLE atxs_epochs ADD COLUMN IF NOT EXISTS highest_tick BIGINT NOT NULL DEFAULT 0`,
+    `ALTER TABLE atxs_epochs ADD COLUMN IF NOT EXISTS highest_node TEXT NOT NULL DEFAULT ''`,
+    `CREATE TABLE IF NOT EXISTS account_atxs_epochs (
+        coinbase TEXT NOT NULL,
+        publish_epoch BIGINT NOT NULL,
+        total_effective_num_units BIGINT NOT NULL DEFAULT 0,
+        total_weight BIGINT NOT NULL DEFAULT 0,
+        total_atx BIGINT NOT NULL DEFAULT 0,
+        PRIMARY KEY (coinbase, publish_epoch)
+    )`,
+    `CREATE INDEX IF NOT EXISTS account_atxs_epochs_publish_epoch_total_weight ON account_atxs_epochs (publish_epoch, total_weight)`,
+    `CREATE TABLE IF NOT EXISTS nodes (
+        id TEXT PRIMARY KEY,
+        has_atx BOOLEAN NOT NULL DEFAULT FALSE,
+        malfeasance_received BIGINT,
+        malfeasance_layer BIGINT NOT NULL DEFAULT 0
+    )`,
+    `ALTER TABLE nodes ADD COLUMN IF NOT EXISTS malfeasance_layer BIGINT NOT NULL DEFAULT 0`,
+    `CREATE INDEX IF NOT EXISTS nodes_malfeasance_received ON nodes (malfeasance_received)`,
+    `CREATE TABLE IF NOT EXISTS accounts (
+        address TEXT PRIMARY KEY,
+        balance BIGINT NOT NULL DEFAULT 0,
+        total_rewards BIGINT NOT NULL DEFAULT 0,
+        fees BIGINT NOT NULL DEFAULT 0,
+        sent BIGINT NOT NULL DEFAULT 0,
+        received BIGINT NOT NULL DEFAULT 0
+    )`,
+    `ALTER TABLE accounts ADD COLUMN IF NOT EXISTS first_seen_layer BIGINT NOT NULL DEFAULT 0`,
+    `ALTER TABLE accounts ADD COLUMN IF NOT EXISTS last_activity_layer BIGINT NOT NULL DEFAULT 0`,
+    `ALTER TABLE accounts ADD COLUMN IF NOT EXISTS incoming_transfers BIGINT NOT NULL DEFAULT 0`,
+    `ALTER TABLE accounts ADD COLUMN IF NOT EXISTS outgoing_transfers BIGINT NOT NULL DEFAULT 0`,
+    `CREATE INDEX IF NOT EXISTS accounts_balance ON accounts (balance DESC)`,
+    `CREATE TABLE IF NOT EXISTS transactions (
+        id TEXT PRIMARY KEY,
+        status SMALLINT NOT NULL,
+        principal_account TEXT NOT NULL,
+        receiver_account TEXT NOT NULL,
+        vault_account TEXT NOT NULL,
+        fee BIGINT NOT NULL,
+        gas BIGINT NOT NULL,
+        gas_price BIGINT NOT NULL,
+        amount BIGINT NOT NULL,
+        layer BIGINT NOT NULL,
+        counter BIGINT NOT NULL,
+        method SMALLINT NOT NULL,
+        type SMALLINT NOT NULL,
+        complete BOOLEAN NOT NULL,
+        message TEXT NOT NULL DEFAULT '',
+        block_id TEXT NOT NULL DEFAULT '',
+        created_at BIGINT NOT NULL DEFAULT 0,
+        raw BYTEA
+    )`,
+    // columns added after the table was first created
+    `ALTER TABLE transactions ADD COLUMN IF NOT EXISTS message TEXT NOT NULL DEFAULT ''`,
+    `ALTER TABLE transactions ADD COLUMN IF NOT EXISTS block_id TEXT NOT NULL DEFAULT ''`,
+    `ALTER TABLE transactions ADD COLUMN IF NOT EXISTS created_at BIGINT NOT NULL DEFAULT 0`,
+    `ALTER TABLE transactions ADD COLUMN IF NOT EXISTS raw BYTEA`,
+    `CREATE INDEX IF NOT EXISTS transactions_principal_account_layer ON transactions (principal_account, layer)`,
+    `CREATE INDEX IF NOT EXISTS transactions_receiver_account_layer ON transactions (receiver_account, layer)`,
+    `CREATE INDEX IF NOT EXISTS transactions_layer ON transactions (layer)`,
+    `CREATE INDEX IF NOT EXISTS transactions_complete_layer ON transactions (complete, layer)`,
+    `CREATE INDEX IF NOT EXISTS transactions_complete_created_at ON transactions (complete, created_at)`,
+    `CREATE INDEX IF NOT EXISTS transactions_vault_account ON transactions (vault_account)`,
+    `CREATE TABLE IF NOT EXISTS network_info (
+        id TEXT PRIMARY KEY,
+        circulating_supply BIGINT NOT NULL DEFAULT 0,
+        issued_subsidy BIGINT NOT NULL DEFAULT 0,
+        fees_paid BIGINT NOT NULL DEFAULT 0
+    )`,
+    `CREATE TABLE IF NOT EXISTS genesis_imports (
+        id TEXT PRIMARY KEY,
+        accounts BIGINT NOT NULL,
+        imported_at BIGINT NOT NULL
+    )`,
+    `CREATE TABLE IF NOT EXISTS backfills (
+        id TEXT PRIMARY KEY,
+        applied_at BIGINT NOT NULL
+    )`,
+    `CREATE TABLE IF NOT EXISTS smeshers (
+        id TEXT PRIMARY KEY,
+        coinbase TEXT NOT NULL DEFAULT '',
+        effective_num_units BIGINT NOT NULL DEFAULT 0,
+        last_epoch BIGINT NOT NULL DEFAULT 0,
+        total_atx BIGINT NOT NULL DEFAULT 0,
+        total_rewards BIGINT NOT NULL DEFAULT 0,
+        rewards_count BIGINT NOT NULL DEFAULT 0
+    )`,
+    `CREATE INDEX IF NOT EXISTS smeshers_effective_num_units ON smeshers (effective_num_units DESC)`,
+    `CREATE INDEX IF NOT EXISTS smeshers_total_rewards ON smeshers (total_rewards DESC)`,
+    `CREATE INDEX IF NOT EXISTS smeshers_total_atx ON smeshers (total_atx DESC)`,
+    `CREATE TABLE IF NOT EXISTS smeshers_epochs (
+        node_id TEXT NOT NULL,
+        epoch BIGINT NOT NULL,
+        coinbase TEXT NOT NULL,
+        rewards BIGINT NOT NULL,
+        rewards_count BIGINT NOT NULL,
+        PRIMARY KEY (node_id, epoch)
+    )`,
+    `CREATE INDEX IF NOT EXISTS smeshers_epochs_epoch_rewards ON smeshers_epochs (epoch, rewards DESC)`,
+    `CREATE TABLE IF NOT EXISTS smeshers_performance (
+        node_id TEXT NOT NULL,
+        epoch BIGINT NOT NULL,
+        coinbase TEXT NOT NULL,
+        weight BIGINT NOT NULL,
+        slots BIGINT NOT NULL,
+        expected_rewards BIGINT NOT NULL,
+        rewards BIGINT NOT NULL,
+        rewards_count BIGINT NOT NULL,
+        complete BOOLEAN NOT NULL,
+        PRIMARY KEY (node_id, epoch)
+    )`,
+    `CREATE INDEX IF NOT EXISTS smeshers_performance_epoch ON smeshers_performance (epoch)`,
+    `CREATE TABLE IF NOT EXISTS account_rewards_rollups (
+        account TEXT NOT NULL,
+        granularity TEXT NOT NULL,
+        bucket BIGINT NOT NULL,
+        rewards BIGINT NOT NULL,
+        rewards_count BIGINT NOT NULL,
+        PRIMARY KEY (account, granularity, bucket)
+    )`,
+    `CREATE TABLE IF NOT EXISTS network_rewards_rollups (
+        granularity TEXT NOT NULL,
+        bucket BIGINT NOT NULL,
+        rewards BIGINT NOT NULL,
+        rewards_count BIGINT NOT NULL,
+        PRIMARY KEY (granularity, bucket)
+    )`,
+    `CREATE TABLE IF NOT EXISTS coinbase_rewards_totals (
+        coinbase TEXT NOT NULL,
+        epoch BIGINT NOT NULL,
+        rewards BIGINT NOT NULL,
+        rewards_count BIGINT NOT NULL,
+        PRIMARY KEY (coinbase, epoch)
+    )`,
+    `CREATE TABLE IF NOT EXISTS fees_rollups (
+        granularity TEXT NOT NULL,
+        bucket BIGINT NOT NULL,
+        transactions BIGINT NOT NULL DEFAULT 0,
+        gas BIGINT NOT NULL DEFAULT 0,
+        fees BIGINT NOT NULL DEFAULT 0,
+        min_fee BIGINT NOT NULL DEFAULT 0,
+        max_fee BIGINT NOT NULL DEFAULT 0,
+        median_fee BIGINT NOT NULL DEFAULT 0,
+        min_gas_price BIGINT NOT NULL DEFAULT 0,
+        max_gas_price BIGINT NOT NULL DEFAULT 0,
+        fees_rewarded BIGINT NOT NULL DEFAULT 0,
+        PRIMARY KEY (granularity, bucket)
+    )`,
+    `CREATE TABLE IF NOT EXISTS reorgs (
+        id BIGSERIAL PRIMARY KEY,
+        trigger_layer BIGINT NOT NULL,
+        last_applied_layer BIGINT NOT NULL,
+        depth BIGINT NOT NULL,
+        affected_documents BIGINT NOT NULL,
+        timestamp BIGINT NOT NULL
+    )`,
+    `CREATE TABLE IF NOT EXISTS prices (
+        timestamp TIMESTAMPTZ NOT NULL,
+        usd_price DOUBLE PRECISION NOT NULL,
+        source TEXT NOT NULL
+    )`,
+    `CREATE INDEX IF NOT EXISTS prices_timestamp ON prices (timestamp)`,
+    `CREATE TABLE IF NOT EXISTS stats (
+        timestamp TIMESTAMPTZ NOT NULL,
+        collections JSONB NOT NULL,
+        ingest_rates JSONB NOT NULL,
+        api_qps DOUBLE PRECISION NOT NULL
+    )`,
+    `CREATE INDEX IF NOT EXISTS stats_timestamp ON stats (timestamp)`,
+    `CREATE TABLE IF NOT EXISTS network_history (
+        timestamp TIMESTAMPTZ NOT NULL,
+        epoch BIGINT NOT NULL,
+        layer BIGINT NOT NULL,
+        total_weight BIGINT NOT NULL,
+        active_smeshers BIGINT NOT NULL,
+        total_accounts BIGINT NOT NULL,
+        circulating_supply BIGINT NOT NULL,
+        price DOUBLE PRECISION NOT NULL
+    )`,
+    `CREATE INDEX IF NOT EXISTS network_history_timestamp ON network_history (timestamp)`,
+    `CREATE TABLE IF NOT EXISTS network_info_history (
+        layer BIGINT PRIMARY KEY,
+        epoch BIGINT NOT NULL,
+        created_at TIMESTAMPTZ NOT NULL,
+        total_weight BIGINT NOT NULL,
+        total_slots BIGINT NOT NULL,
+        effective_units BIGINT NOT NULL,
+        active_smeshers BIGINT NOT NULL,
+        total_accounts BIGINT NOT NULL,
+        circulating_supply BIGINT NOT NULL,
+        price DOUBLE PRECISION NOT NULL,
+        next_epoch_weight BIGINT NOT NULL,
+        next_epoch_active_smeshers BIGINT NOT NULL,
+        next_epoch_effective_units BIGINT NOT NULL
+    )`,
+    `CREATE TABLE IF NOT EXISTS fences (
+        id TEXT PRIMARY KEY,
+        instance_id TEXT NOT NULL,
+        generation BIGINT NOT NULL,
+        acquired_at TIMESTAMPTZ NOT NULL
+    )`,
+    `CREATE TABLE IF NOT EXISTS stream_checkpoints (
+        consumer TEXT PRIMARY KEY,
+        stream TEXT NOT NULL,
+        sequence BIGINT NOT NULL,
+        layer BIGINT NOT NULL,
+        updated_at TIMESTAMPTZ NOT NULL,
+        writer_instance TEXT,
+        writer_generation BIGINT
+    )`,
+    `ALTER TABLE stream_checkpoints ADD COLUMN IF NOT EXISTS writer_instance TEXT`,
+    `ALTER TABLE stream_checkpoints ADD COLUMN IF NOT EXISTS writer_generation BIGINT`,
+    `CREATE TABLE IF NOT EXISTS poet_health (
+        name TEXT PRIMARY KEY,
+        address TEXT NOT NULL,
+        up BOOLEAN NOT NULL,
+        current_round TEXT NOT NULL,
+        error TEXT NOT NULL,
+        since TIMESTAMPTZ NOT NULL,
+        checked_at TIMESTAMPTZ NOT NULL
+    )`,
+    `CREATE TABLE IF NOT EXISTS vaults (
+        address TEXT PRIMARY KEY,
+        owner TEXT NOT NULL,
+        total_amount BIGINT NOT NULL,
+        initial_unlock_amount BIGINT NOT NULL,
+        vesting_start BIGINT NOT NULL,
+        vesting_end BIGINT NOT NULL,
+        spawn_transaction TEXT NOT NULL,
+        spawn_layer BIGINT NOT NULL
+    )`,
+    `CREATE TABLE IF NOT EXISTS multisigs (
+        address TEXT PRIMARY KEY,
+        template TEXT NOT NULL,
+        required BIGINT NOT NULL,
+        public_keys TEXT NOT NULL,
+        spawn_transaction TEXT NOT NULL,
+        spawn_layer BIGINT NOT NULL
+    )`,
+    `CREATE TABLE IF NOT EXISTS multisig_signatures (
+        transaction_id TEXT PRIMARY KEY,
+        account TEXT NOT NULL,
+        layer BIGINT NOT NULL,
+        status BIGINT NOT NULL,
+        type BIGINT NOT NULL,
+        refs TEXT NOT NULL
+    )`,
+    `CREATE INDEX IF NOT EXISTS multisig_signatures_account ON multisig_signatures (account, layer)`,
+    `CREATE TABLE IF NOT EXISTS balance_changes (
+        account TEXT NOT NULL,
+        layer BIGINT NOT NULL,
+        delta BIGINT NOT NULL,
+        PRIMARY KEY (account, layer)
+    )`,
+    `CREATE TABLE IF NOT EXISTS webhooks (
+        id TEXT PRIMARY KEY,
+        owner TEXT NOT NULL,
+        url TEXT NOT NULL,
+        addresses TEXT NOT NULL,
+        events TEXT NOT NULL,
+        secret TEXT NOT NULL,
+        created_at BIGINT NOT NULL
+    )`,
+    `CREATE INDEX IF NOT EXISTS webhooks_owner ON webhooks (owner)`,
+    `CREATE TABLE IF NOT EXISTS webhook_deliveries (
+        id TEXT PRIMARY KEY,
+        webhook TEXT NOT NULL,
+        event TEXT NOT NULL,
+        payload TEXT NOT NULL,
+        status TEXT NOT NULL,
+        attempts BIGINT NOT NULL,
+        next_attempt BIGINT NOT NULL,
+        last_status BIGINT NOT NULL,
+        last_error TEXT NOT NULL,
+        created_at BIGINT NOT NULL,
+        delivered_at BIGINT NOT NULL
+    )`,
+    `CREATE INDEX IF NOT EXISTS webhook_deliveries_due ON webhook_deliveries (status, next_attempt)`,
+    `CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook ON webhook_deliveries (webhook, created_at)`,
+    `CREATE TABLE IF NOT EXISTS watchlist_items (
+        id TEXT PRIMARY KEY,
+        owner TEXT NOT NULL,
+        kind TEXT NOT NULL,
+        item TEXT NOT NULL,
+        created_at BIGINT NOT NULL
+    )`,
+    `CREATE INDEX IF NOT EXISTS watchlist_items_owner ON watchlist_items (owner, created_at)`,
+    `CREATE TABLE IF NOT EXISTS email_subscriptions (
+        id TEXT PRIMARY KEY,
+        owner TEXT NOT NULL,
+        email TEXT NOT NULL,
+        frequency TEXT NOT NULL,
+        token TEXT NOT NULL,
+        created_at BIGINT NOT NULL,
+        last_day BIGINT NOT NULL
+    )`,
+    `CREATE INDEX IF NOT EXISTS email_subscriptions_owner ON email_subscriptions (owner, created_at)`,
+    `CREATE INDEX IF NOT EXISTS email_subscriptions_token ON email_subscriptions (token)`,
+    `ALTER TABLE email_subscriptions ADD COLUMN IF NOT EXISTS pending BOOLEAN NOT NULL DEFAULT FALSE`,
+    // subscriptions saved before the slots were added have -1
+    `ALTER TABLE email_subscriptions ADD COLUMN IF NOT EXISTS slot BIGINT NOT NULL DEFAULT -1`,
+    `CREATE UNIQUE INDEX IF NOT EXISTS email_subscriptions_owner_email ON email_subscriptions (owner, email)`,
+    `CREATE UNIQUE INDEX IF NOT EXISTS email_subscriptions_owner_slot ON email_subscriptions (owner, slot) WHERE slot >= 0`,
+    `CREATE TABLE IF NOT EXISTS blocks (
+        id TEXT PRIMARY KEY,
+        layer BIGINT NOT NULL,
+        transactions BIGINT NOT NULL
+    )`,
+    `CREATE INDEX IF NOT EXISTS blocks_layer ON blocks (layer)`,
+    `CREATE TABLE IF NOT EXISTS pool_rewards (
+        id TEXT PRIMARY KEY,
+        node_id TEXT NOT NULL,
+        coinbase TEXT NOT NULL,
+        layer BIGINT NOT NULL,
+        epoch BIGINT NOT NULL,
+        total_reward BIGINT NOT NULL
+    )`,
+    `CREATE INDEX IF NOT EXISTS pool_rewards_epoch ON pool_rewards (epoch, node_id)`,
+    `CREATE TABLE IF NOT EXISTS reward_digests (
+        coinbase TEXT NOT NULL,
+        day BIGINT NOT NULL,
+        count BIGINT NOT NULL,
+        rewards BIGINT NOT NULL,
+        usd_price DOUBLE PRECISION NOT NULL,
+        usd_value BIGINT NOT NULL,
+        complete BOOLEAN NOT NULL,
+        PRIMARY KEY (coinbase, day)
+    )`,
+    `CREATE INDEX IF NOT EXISTS reward_digests_day ON reward_digests (day)`,
+    `CREATE TABLE IF NOT EXISTS sink_leases (
+        id TEXT PRIMARY KEY,
+        instance_id TEXT NOT NULL,
+        generation BIGINT NOT NULL DEFAULT 0,
+        lease_until TIMESTAMPTZ NOT NULL
+    )`,
+}
+
+func NewPostgresDB(dbConnection string) (*SqlDB, error) {
+    db, err := sql.Open("postgres", dbConnection)
+    if err != nil {
+        return nil, err
+    }
+    db.SetMaxOpenConns(10)
+    return newSqlDB(db, postgresDialect, postgresSchema)
+}
@@ -0,0 +1,85 @@
+package database
+
+import (
+    "context"
+    "time"
+
+    "github.com/swarmbit/spacemesh-state-api/types"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (m *WriteDB) SavePrice(price *types.PriceDoc) error {
+    if m.Fenced() {
+        return ErrFenced
+    }
+    pricesColl := m.client.Database(database).Collection(pricesCollection)
+    _, err := pricesColl.InsertOne(context.TODO(), price)
+    return err
+}
+
+// GetPriceAt returns the last price fetched at or before timestamp, nil when there is none.
+func (m *ReadDB) GetPriceAt(timestamp time.Time) (*types.PriceDoc, error) {
+    pricesColl := m.client.Database(database).Collection(pricesCollection)
+
+    price := &types.PriceDoc{}
+    err := pricesColl.FindOne(
+        context.TODO(),
+        bson.D{{Key: "timestamp", Value: bson.D{{Key: "$lte", Value: timestamp}}}},
+        options.FindOne().SetSort(bson.D{{Key: "timestamp", Value: -1}}),
+    ).Decode(price)
+    if err == mongo.ErrNoDocuments {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    return price, nil
+}
+
+// GetPriceHistory groups the prices between from and to in buckets of resolution,
+// bucket ids are the bucket start in unix milliseconds.
+func (m *ReadDB) GetPriceHistory(from time.Time, to time.Time, resolution time.Duration) ([]*types.PriceBucketDoc, error) {
+    pricesColl := m.client.Database(database).Collection(pricesCollection)
+
+    timestampMillis := bson.D{{Key: "$toLong", Value: "$timestamp"}}
+    pipeline := mongo.Pipeline{
+        bson.D{
+            {Key: "$match", Value: bson.D{
+                {Key: "timestamp", Value: bson.D{
+                    {Key: "$gte", Value: from},
+                    {Key: "$lte", Value: to},
+                }},
+            }},
+        },
+        bson.D{
+            {Key: "$group", Value: bson.D{
+                {Key: "_id", Value: bson.D{{Key: "$subtract", Value: bson.A{
+                    timestampMillis,
+                    bson.D{{Key: "$mod", Value: bson.A{timestampMillis, resolution.Milliseconds()}}},
+                }}}},
+                {Key: "usdPrice", Value: bson.D{{Key: "$avg", Value: "$usdPrice"}}},
+                {Key: "min", Value: bson.D{{Key: "$min", Value: "$usdPrice"}}},
+                {Key: "max", Value: bson.D{{Key: "$max", Value: "$usdPrice"}}},
+                {Key: "samples", Value: bson.D{{Key: "$sum", Value: 1}}},
+            }},
+        },
+        bson.D{
+            {Key: "$sort", Value: bson.D{{Key: "_id", Value: 1}}},
+        },
+    }
+
+    ctx := context.TODO()
+    cursor, err := pricesColl.Aggregate(ctx, pipeline)
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    var buckets []*types.PriceBucketDoc
+    if err = cursor.All(ctx, &buckets); err != nil {
+        return nil, err
+    }
+    return buckets, nil
+}
@@ -3,9 +3,12 @@ package database
 import (
     "context"
     "errors"
+    "fmt"
     "log"
+    "sync/atomic"
     "time"
 
+    "github.com/swarmbit/spacemesh-state-api/config"
     "github.com/swarmbit/spacemesh-state-api/types"
     "go.mongodb.org/mongo-driver/bson"
     "go.mongodb.org/mongo-driver/mongo"
@@ -13,13 +16,18 @@ import (
 )
 
 type ReadDB struct {
-    client *mongo.Client
+    client        *mongo.Client
+    activityReady atomic.Bool
 }
 
-func NewReadDB(dbConnection string) (*ReadDB, error) {
+func NewReadDB(dbConnection string, mongoConfig *config.MongoConfig) (*ReadDB, error) {
     ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
     defer cancel()
-    client, err := mongo.Connect(ctx, options.Client().ApplyURI(dbConnection).SetMaxPoolSize(10))
+    clientOptions, err := mongoClientOptions(dbConnection, mongoConfig, true)
+    if err != nil {
+        return nil, err
+    }
+    client, err := mongo.Connect(ctx, clientOptions)
     log.Println("Created read db")
     return &ReadDB{
         client: client,
@@ -107,15 +115,64 @@ func (m *ReadDB) GetTransaction(transactionId string) (*types.TransactionDoc, er
     return txDoc, nil
 }
 
-func (m *ReadDB) CountTransactions(account string) (int64, error) {
-    transactionsColl := m.client.Database(database).Collection(transactionsCollection)
+// transactionStateFilter appends the conditions selecting the transactions in state, one
+// of the transaction states or applied for both results. No state selects every
+// transaction.
+func transactionStateFilter(filter bson.D, state string) bson.D {
+    switch state {
+    case types.TransactionCreated:
+        return append(filter, bson.E{Key: "complete", Value: false})
+    case types.TransactionApplied:
+        return append(filter, bson.E{Key: "complete", Value: true})
+    case types.TransactionSuccess:
+        return append(filter, bson.E{Key: "complete", Value: true}, bson.E{Key: "status", Value: 0})
+    case types.TransactionFailure:
+        return append(filter, bson.E{Key: "complete", Value: true}, bson.E{Key: "status", Value: bson.D{{Key: "$ne", Value: 0}}})
+    }
+    return filter
+}
 
-    filter := bson.M{
-        "$or": []bson.M{
-            {"principal_account": account},
-            {"receiver_account": account},
-        },
+// accountTransactionsFilter selects the transactions sent or received by account.
+func accountTransactionsFilter(account string, state string) bson.D {
+    filter := bson.D{
+        {Key: "$or", Value: bson.A{
+            bson.D{{Key: "principal_account", Value: account}},
+            bson.D{{Key: "receiver_account", Value: account}},
+        }},
+    }
+    return transactionStateFilter(filter, state)
+}
+
+// allTransactionsFilter selects the transactions of every account of filter, the receivers
+// and layers are served by the receiver account and layer index.
+func allTransactionsFilter(filter *TransactionsFilter) bson.D {
+    query := transactionStateFilter(bson.D{}, filter.State)
+    if len(filter.Receivers) > 0 {
+        query = append(query, bson.E{Key: "receiver_account", Value: bson.M{"$in": filter.Receivers}})
+    }
+    layers := bson.M{}
+    if filter.FirstLayer > -1 {
+        layers["$gte"] = filter.FirstLayer
+    }
+    if filter.LastLayer > -1 {
+        layers["$lte"] = filter.LastLayer
+    }
+    if len(layers) > 0 {
+        query = append(query, bson.E{Key: "layer", Value: layers})
+    }
+    if filter.Method > -1 {
+        query = append(query, bson.E{Key: "method", Value: filter.Method})
     }
+    if filter.MinAmount > -1 {
+        query = append(query, bson.E{Key: "amount", Value: bson.M{"$gte": filter.MinAmount}})
+    }
+    return query
+}
+
+func (m *ReadDB) CountTransactions(account string, state string) (int64, error) {
+    transactionsColl := m.client.Database(database).Collection(transactionsCollection)
+
+    filter := accountTransactionsFilter(account, state)
     accountResult, err := transactionsColl.CountDocuments(
         context.TODO(),
         filter,
@@ -126,26 +183,12 @@ func (m *ReadDB) CountTransactions(account string) (int64, error) {
     return accountResult, nil
 }
 
-func (m *ReadDB) CountAllTransactions(complete bool, method int, minAmount int) (int64, error) {
+func (m *ReadDB) CountAllTransactions(filter *TransactionsFilter) (int64, error) {
     transactionsColl := m.client.Database(database).Collection(transactionsCollection)
 
-    filter := bson.D{
-        {Key: "complete", Value: complete},
-    }
-
-    // Add method filter if method > -1
-    if method > -1 {
-        filter = append(filter, bson.E{Key: "method", Value: method})
-    }
-
-    // Add minAmount filter if minAmount > -1
-    if minAmount > -1 {
-        filter = append(filter, bson.E{Key: "amount", Value: bson.M{"$gte": minAmount}})
-    }
-    
     accountResult, err := transactionsColl.CountDocuments(
         context.TODO(),
-        filter,
+        allTransactionsFilter(filter),
     )
     if err != nil {
         return 0, err
@@ -153,12 +196,10 @@ func (m *ReadDB) CountAllTransactions(complete bool, method int, minAmount int)
     return accountResult, nil
 }
 
-func (m *ReadDB) CountLayerTransactions(layer int) (int64, error) {
+func (m *ReadDB) CountLayerTransactions(layer int, state string) (int64, error) {
     transactionsColl := m.client.Database(database).Collection(transactionsCollection)
 
-    filter := bson.D{
-        {Key: "layer", Value: layer},
-    }
+    filter := transactionStateFilter(bson.D{{Key: "layer", Value: layer}}, state)
     accountResult, err := transactionsColl.CountDocuments(
         context.TODO(),
         filter,
@@ -196,33 +237,33 @@ func (m *ReadDB) CountRewards(account string, firstLayer int, lastLayer int) (in
         if firstLayer > -1 && lastLayer > -1 {
             filter = bson.D{
                 {Key: "coinbase", Value: account},
-                {"layer", bson.D{{"$gte", firstLayer}}},
-                {"layer", bson.D{{"$lte", lastLayer}}},
+                {Key: "layer", Value: bson.D{{Key: "$gte", Value: firstLayer}}},
+                {Key: "layer", Value: bson.D{{Key: "$lte", Value: lastLayer}}},
             }
         } else if firstLayer > -1 {
             filter = bson.D{
                 {Key: "coinbase", Value: account},
-                {"layer", bson.D{{"$gte", firstLayer}}},
+                {Key: "layer", Value: bson.D{{Key: "$gte", Value: firstLayer}}},
             }
         } else if lastLayer > -1 {
             filter = bson.D{
                 {Key: "coinbase", Value: account},
-                {"layer", bson.D{{"$lte", lastLayer}}},
+                {Key: "layer", Value: bson.D{{Key: "$lte", Value: lastLayer}}},
             }
         }
     } else {
         if firstLayer > -1 && lastLayer > -1 {
             filter = bson.D{
-                {"layer", bson.D{{"$gte", firstLayer}}},
-                {"layer", bson.D{{"$lte", lastLayer}}},
+                {Key: "layer", Value: bson.D{{Key: "$gte", Value: firstLayer}}},
+                {Key: "layer", Value: bson.D{{Key: "$lte", Value: lastLayer}}},
             }
         } else if firstLayer > -1 {
             filter = bson.D{
-                {"layer", bson.D{{"$gte", firstLayer}}},
+                {Key: "layer", Value: bson.D{{Key: "$gte", Value: firstLayer}}},
             }
         } else if lastLayer > -1 {
             filter = bson.D{
-                {"layer", bson.D{{"$lte", lastLayer}}},
+                {Key: "layer", Value: bson.D{{Key: "$lte", Value: lastLayer}}},
             }
         }
     }
@@ -292,16 +333,16 @@ func (m *ReadDB) GetAccountsGroup(accounts []string) (*types.AccountGroup, error
     pipeline := mongo.Pipeline{
         bson.D{
             {Key: "$match", Value: bson.D{
-                {"_id", bson.D{
-                    {"$in", accounts},
+                {Key: "_id", Value: bson.D{
+                    {Key: "$in", Value: accounts},
                 }},
             },
             }},
         bson.D{
-            {"$group", bson.D{
-                {"_id", nil},
-                {"totalRewards", bson.D{{"$sum", "$totalRewards"}}},
-                {"balance", bson.D{{"$sum", "$balance"}}},
+            {Key: "$group", Value: bson.D{
+                {Key: "_id", Value: nil},
+                {Key: "totalRewards", Value: bson.D{{Key: "$sum", Value: "$totalRewards"}}},
+                {Key: "balance", Value: bson.D{{Key: "$sum", Value: "$balance"}}},
             }},
         },
     }
@@ -327,6 +368,58 @@ func (m *ReadDB) GetAccountsGroup(accounts []string) (*types.AccountGroup, error
     }
 }
 
+// GetAccountsByAddress returns the stored accounts of accounts, the ones not found are
+// left out.
+func (m *ReadDB) GetAccountsByAddress(accounts []string) ([]*types.AccountDoc, error) {
+    accountsColl := m.client.Database(database).Collection(accountsCollection)
+
+    ctx := context.TODO()
+    cursor, err := accountsColl.Find(
+        ctx,
+        bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: accounts}}}},
+    )
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    var results []*types.AccountDoc
+    if err = cursor.All(ctx, &results); err != nil {
+        return nil, err
+    }
+    return results, nil
+}
+
+// GetAccountsCounters returns the highest counter of the applied transactions of each of
+// accounts that sent one, failed transactions also use their counter.
+func (m *ReadDB) GetAccountsCounters(accounts []string) ([]*types.AccountCounterDoc, error) {
+    transactionsColl := m.client.Database(database).Collection(transactionsCollection)
+
+    pipeline := mongo.Pipeline{
+        {{Key: "$match", Value: bson.D{
+            {Key: "principal_account", Value: bson.D{{Key: "$in", Value: accounts}}},
+            {Key: "complete", Value: true},
+        }}},
+        {{Key: "$group", Value: bson.D{
+            {Key: "_id", Value: "$principal_account"},
+            {Key: "counter", Value: bson.D{{Key: "$max", Value: "$counter"}}},
+        }}},
+    }
+
+    ctx := context.TODO()
+    cursor, err := transactionsColl.Aggregate(ctx, pipeline)
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    var results []*types.AccountCounterDoc
+    if err = cursor.All(ctx, &results); err != nil {
+        return nil, err
+    }
+    return results, nil
+}
+
 func (m *ReadDB) GetAccountsPostEpoch(epoch int, skip int64, limit int64, sort int8) ([]*types.AccountAtxDoc, error) {
     accountAtxEpochsColl := m.client.Database(database).Collection(accountAtxsEpochsCollection)
 
@@ -463,18 +556,18 @@ func (m *ReadDB) GetRewards(account string, skip int64, limit int64, sort int8,
     if firstLayer > -1 && lastLayer > -1 {
         filter = bson.D{
             {Key: "coinbase", Value: account},
-            {"layer", bson.D{{"$gte", firstLayer}}},
-            {"layer", bson.D{{"$lte", lastLayer}}},
+            {Key: "layer", Value: bson.D{{Key: "$gte", Value: firstLayer}}},
+            {Key: "layer", Value: bson.D{{Key: "$lte", Value: lastLayer}}},
         }
     } else if firstLayer > -1 {
         filter = bson.D{
             {Key: "coinbase", Value: account},
-            {"layer", bson.D{{"$gte", firstLayer}}},
+            {Key: "layer", Value: bson.D{{Key: "$gte", Value: firstLayer}}},
         }
     } else if lastLayer > -1 {
         filter = bson.D{
             {Key: "coinbase", Value: account},
-            {"layer", bson.D{{"$lte", lastLayer}}},
+            {Key: "layer", Value: bson.D{{Key: "$lte", Value: lastLayer}}},
         }
     }
 
@@ -658,7 +751,7 @@ func (m *ReadDB) GetAtxWeightNode(node string, epoch uint64) (*types.Aggregation
     return &types.AggregationAtxTotals{}, nil
 }
 
-func (m *ReadDB) GetTransactions(account string, skip int64, limit int64, sort int8, complete bool) ([]*types.TransactionDoc, error) {
+func (m *ReadDB) GetTransactions(account string, skip int64, limit int64, sort int8, state string) ([]*types.TransactionDoc, error) {
     transactionsColl := m.client.Database(database).Collection(transactionsCollection)
 
     findOptions := options.Find()
@@ -667,12 +760,7 @@ func (m *ReadDB) GetTransactions(account string, skip int64, limit int64, sort i
     findOptions.SetSort(bson.M{"layer": sort})
 
     ctx := context.TODO()
-    filter := bson.M{
-        "$or": []bson.M{
-            {"principal_account": account, "complete": complete},
-            {"receiver_account": account, "complete": complete},
-        },
-    }
+    filter := accountTransactionsFilter(account, state)
     cursor, err := transactionsColl.Find(
         ctx,
         filter,
@@ -690,7 +778,7 @@ func (m *ReadDB) GetTransactions(account string, skip int64, limit int64, sort i
     return transactions, nil
 }
 
-func (m *ReadDB) GetLayerTransactions(layer int, skip int64, limit int64, sort int8, complete bool) ([]*types.TransactionDoc, error) {
+func (m *ReadDB) GetLayerTransactions(layer int, skip int64, limit int64, sort int8, state string) ([]*types.TransactionDoc, error) {
     transactionsColl := m.client.Database(database).Collection(transactionsCollection)
 
     findOptions := options.Find()
@@ -699,10 +787,7 @@ func (m *ReadDB) GetLayerTransactions(layer int, skip int64, limit int64, sort i
     findOptions.SetSort(bson.M{"layer": sort})
 
     ctx := context.TODO()
-    filter := bson.D{
-        {Key: "layer", Value: layer},
-        {Key: "complete", Value: complete},
-    }
+    filter := transactionStateFilter(bson.D{{Key: "layer", Value: layer}}, state)
     cursor, err := transactionsColl.Find(
         ctx,
         filter,
@@ -745,7 +830,7 @@ func (m *ReadDB) GetNodes(skip int64, limit int64) ([]*types.NodeDoc, error) {
     }
     return nodes, nil
 }
-func (m *ReadDB) GetAllTransactions(skip int64, limit int64, sort int8, complete bool, method int, minAmount int) ([]*types.TransactionDoc, error) {
+func (m *ReadDB) GetAllTransactions(skip int64, limit int64, sort int8, filter *TransactionsFilter) ([]*types.TransactionDoc, error) {
     transactionsColl := m.client.Database(database).Collection(transactionsCollection)
     findOptions := options.Find()
     findOptions.SetSkip(skip)
@@ -753,24 +838,9 @@ func (m *ReadDB) GetAllTransactions(skip int64, limit int64, sort int8, complete
     findOptions.SetSort(bson.M{"layer": sort})
     ctx := context.TODO()
 
-    // Start with the base filter
-    filter := bson.D{
-        {Key: "complete", Value: complete},
-    }
-
-    // Add method filter if method > -1
-    if method > -1 {
-        filter = append(filter, bson.E{Key: "method", Value: method})
-    }
-
-    // Add minAmount filter if minAmount > -1
-    if minAmount > -1 {
-        filter = append(filter, bson.E{Key: "amount", Value: bson.M{"$gte": minAmount}})
-    }
-
     cursor, err := transactionsColl.Find(
         ctx,
-        filter,
+        allTransactionsFilter(filter),
         findOptions,
     )
     if err != nil {
@@ -815,24 +885,11 @@ func (m *ReadDB) CountAccounts() (int64, error) {
     return count, nil
 }
 
-func (m *ReadDB) CountAtxEpoch(epoch uint64) (int64, error) {
-    atxEpochsColl := m.client.Database(database).Collection(atxsEpochsCollection)
-    atxResult := atxEpochsColl.FindOne(
-        context.TODO(),
-        bson.D{
-            {Key: "_id", Value: epoch},
-        },
-    )
-    doc := &types.AtxEpochDoc{}
-    atxResult.Decode(doc)
-    return int64(doc.TotalAtx), nil
-}
-
 func (m *ReadDB) FilterAccountAtxNodesForEpoch(account string, epoch uint64, nodes []string) ([]string, error) {
     atxColl := m.client.Database(database).Collection(atxsCollection)
 
     findOptions := options.Find()
-    findOptions.SetProjection(bson.D{{"node_id", 1}})
+    findOptions.SetProjection(bson.D{{Key: "node_id", Value: 1}})
 
     ctx := context.TODO()
     filter := bson.M{
@@ -884,24 +941,57 @@ func (m *ReadDB) CountAccountAtxEpoch(account string, epoch uint64) (int64, erro
     return int64(doc.TotalAtx), nil
 }
 
-func (m *ReadDB) GetAtxForEpochPaginated(epoch uint64, skip int64, limit int64, sort int8) ([]*types.AtxDoc, error) {
-    atxColl := m.client.Database(database).Collection(atxsCollection)
+// atxListProjection keeps the fields of the atx lists.
+var atxListProjection = bson.D{
+    {Key: "_id", Value: 1},
+    {Key: "node_id", Value: 1},
+    {Key: "effective_num_units", Value: 1},
+    {Key: "weight", Value: 1},
+    {Key: "base_tick", Value: 1},
+    {Key: "tick_count", Value: 1},
+    {Key: "received", Value: 1},
+}
 
-    findOptions := options.Find()
-    findOptions.SetSkip(skip)
-    findOptions.SetLimit(limit)
-    findOptions.SetSort(bson.M{"effective_num_units": sort})
+// GetAtxForEpochPaginated pages the atxs of epoch sorted by sortField, one of the
+// AtxSort values. Equal values are ordered by id so pages do not overlap.
+func (m *ReadDB) GetAtxForEpochPaginated(epoch uint64, sortField string, skip int64, limit int64, sort int8) ([]*types.AtxDoc, error) {
+    atxColl := m.client.Database(database).Collection(atxsCollection)
 
     ctx := context.TODO()
-    filter := bson.M{
-        "publishepoch": epoch,
+    var cursor *mongo.Cursor
+    var err error
+    switch sortField {
+    case AtxSortEffectiveUnits:
+        findOptions := options.Find()
+        findOptions.SetSkip(skip)
+        findOptions.SetLimit(limit)
+        findOptions.SetSort(bson.D{
+            {Key: "effective_num_units", Value: sort},
+            {Key: "_id", Value: 1},
+        })
+        findOptions.SetProjection(atxListProjection)
+        cursor, err = atxColl.Find(ctx, bson.D{{Key: "publishepoch", Value: epoch}}, findOptions)
+    case AtxSortHeight:
+        // the height is not stored, it is sorted in the pipeline
+        pipeline := mongo.Pipeline{
+            {{Key: "$match", Value: bson.D{{Key: "publishepoch", Value: epoch}}}},
+            {{Key: "$project", Value: atxListProjection}},
+            {{Key: "$addFields", Value: bson.D{
+                {Key: "height", Value: bson.D{{Key: "$add", Value: bson.A{"$base_tick", "$tick_count"}}}},
+            }}},
+            {{Key: "$sort", Value: bson.D{
+                {Key: "height", Value: sort},
+                {Key: "_id", Value: 1},
+            }}},
+            {{Key: "$skip", Value: skip}},
+        }
+        if limit > 0 {
+            pipeline = append(pipeline, bson.D{{Key: "$limit", Value: limit}})
+        }
+        cursor, err = atxColl.Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+    default:
+        return nil, fmt.Errorf("unknown atx sort field %s", sortField)
     }
-
-    cursor, err := atxColl.Find(
-        ctx,
-        filter,
-        findOptions,
-    )
     if err != nil {
         return nil, err
     }
@@ -945,38 +1035,6 @@ func (m *ReadDB) GetAccountAtxEpoch(account string, epoch uint64, skip int64, li
     return atx, nil
 }
 
-func (m *ReadDB) GetAtxForEpoch(epoch uint64) ([]*types.AtxDoc, error) {
-    atxColl := m.client.Database(database).Collection(atxsCollection)
-
-    sortDoc := bson.D{
-        {Key: "_id", Value: 1},
-        {Key: "publishepoch", Value: 1},
-    }
-
-    findOptions := options.Find()
-    findOptions.SetSort(sortDoc)
-
-    ctx := context.TODO()
-    filter := bson.M{
-        "publishepoch": epoch,
-    }
-    cursor, err := atxColl.Find(
-        ctx,
-        filter,
-        findOptions,
-    )
-    if err != nil {
-        return nil, err
-    }
-    defer cursor.Close(ctx)
-
-    var atx []*types.AtxDoc
-    if err = cursor.All(ctx, &atx); err != nil {
-        return nil, err
-    }
-    return atx, nil
-}
-
 func (m *ReadDB) GetMalfeasanceNodes() ([]*types.NodeDoc, error) {
     nodesColl := m.client.Database(database).Collection(nodesCollection)
 
@@ -1003,6 +1061,8 @@ func (m *ReadDB) GetMalfeasanceNodes() ([]*types.NodeDoc, error) {
     return node, nil
 }
 
+// GetAtxEpoch returns the totals of the atxs published in epoch, the sink updates them
+// with every new atx. An epoch without atxs has empty totals.
 func (m *ReadDB) GetAtxEpoch(epoch uint64) (*types.AtxEpochDoc, error) {
     atxEpochsColl := m.client.Database(database).Collection(atxsEpochsCollection)
     atxResult := atxEpochsColl.FindOne(
@@ -1012,7 +1072,10 @@ func (m *ReadDB) GetAtxEpoch(epoch uint64) (*types.AtxEpochDoc, error) {
         },
     )
     doc := &types.AtxEpochDoc{}
-    atxResult.Decode(doc)
+    err := atxResult.Decode(doc)
+    if err != nil && err != mongo.ErrNoDocuments {
+        return nil, err
+    }
     return doc, nil
 }
 
@@ -1092,6 +1155,10 @@ func (m *ReadDB) GetLastProcessedLayer() (*types.LayerDoc, error) {
     }
 }
 
+func (m *ReadDB) Capabilities() Capabilities {
+    return mongoCapabilities
+}
+
 func (m *ReadDB) CloseRead() {
     m.client.Disconnect(context.TODO())
 }
@@ -0,0 +1,190 @@
+package database
+
+import (
+    "context"
+    "fmt"
+    "log"
+
+    "github.com/swarmbit/spacemesh-state-api/pkg/apperror"
+    "github.com/swarmbit/spacemesh-state-api/config"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const shadowSuffix = "_rebuild"
+
+// Rebuild recomputes a derived collection from its source collections. Build must
+// write the complete result into the target collection it is given.
+type Rebuild struct {
+    Collection string
+    Build      func(m *WriteDB, target string) error
+}
+
+// Rebuilds lists the derived collections that can be recomputed. Account balances are
+// not included, the sink skips transactions with less than two addresses and those are
+// not stored so balances can not be derived again from the transactions collection.
+var Rebuilds = []*Rebuild{
+    {Collection: atxsEpochsCollection, Build: buildAtxsEpochs},
+    {Collection: accountAtxsEpochsCollection, Build: buildAccountAtxsEpochs},
+    {Collection: nodesCountCollection, Build: buildNodesCount},
+    {Collection: smeshersEpochsCollection, Build: buildSmeshersEpochs},
+    {Collection: coinbaseRewardsTotalsCollection, Build: buildCoinbaseRewardsTotals},
+}
+
+// ErrUnknownAggregate is returned by RebuildAggregate for collections not in Rebuilds.
+var ErrUnknownAggregate error = apperror.New(apperror.InvalidInput, "collection can not be rebuilt")
+
+func GetRebuild(collection string) *Rebuild {
+    for _, v := range Rebuilds {
+        if v.Collection == collection {
+            return v
+        }
+    }
+    return nil
+}
+
+// RebuildAggregate rebuilds the derived collection with the given name.
+func (m *WriteDB) RebuildAggregate(collection string) error {
+    rebuild := GetRebuild(collection)
+    if rebuild == nil {
+        return ErrUnknownAggregate
+    }
+    return m.RebuildCollection(rebuild)
+}
+
+// RebuildCollection builds rebuild into a shadow collection while reads keep using the
+// current one, copies the current indexes and then swaps the shadow in with a single
+// rename. Writes made to the current collection while the shadow is built are lost, so
+// the sink must be paused while it runs: POST /admin/rebuild pauses the sink of its
+// instance, the backfill command and the rebuild script need it stopped.
+func (m *WriteDB) RebuildCollection(rebuild *Rebuild) error {
+    db := m.client.Database(database)
+    shadow := rebuild.Collection + shadowSuffix
+
+    err := db.Collection(shadow).Drop(context.TODO())
+    if err != nil {
+        return err
+    }
+
+    log.Printf("Building %s into %s", rebuild.Collection, shadow)
+    err = rebuild.Build(m, shadow)
+    if err != nil {
+        return fmt.Errorf("build %s: %w", rebuild.Collection, err)
+    }
+
+    err = copyIndexes(db.Collection(rebuild.Collection), db.Collection(shadow))
+    if err != nil {
+        return fmt.Errorf("copy indexes of %s: %w", rebuild.Collection, err)
+    }
+
+    log.Printf("Swapping %s with %s", shadow, rebuild.Collection)
+    return m.client.Database("admin").RunCommand(context.TODO(), bson.D{
+        {Key: "renameCollection", Value: database + "." + shadow},
+        {Key: "to", Value: database + "." + rebuild.Collection},
+        {Key: "dropTarget", Value: true},
+    }).Err()
+}
+
+func copyIndexes(from *mongo.Collection, to *mongo.Collection) error {
+    cursor, err := from.Indexes().List(context.TODO())
+    if err != nil {
+        return err
+    }
+    var specs []bson.M
+    if err = cursor.All(context.TODO(), &specs); err != nil {
+        return err
+    }
+
+    var indexes []mongo.IndexModel
+    for _, spec := range specs {
+        name, _ := spec["name"].(string)
+        if name == "_id_" {
+            continue
+        }
+        indexOptions := options.Index().SetName(name)
+        if unique, ok := spec["unique"].(bool); ok {
+            indexOptions.SetUnique(unique)
+        }
+        if expireAfter, ok := spec["expireAfterSeconds"].(int32); ok {
+            indexOptions.SetExpireAfterSeconds(expireAfter)
+        }
+        indexes = append(indexes, mongo.IndexModel{
+            Keys:    spec["key"],
+            Options: indexOptions,
+        })
+    }
+    if len(indexes) == 0 {
+        return nil
+    }
+    _, err = to.Indexes().CreateMany(context.TODO(), indexes)
+    return err
+}
+
+func (m *WriteDB) aggregateInto(source string, pipeline mongo.Pipeline, target string) error {
+    pipeline = append(pipeline, bson.D{{Key: "$out", Value: target}})
+    sourceColl := m.client.Database(database).Collection(source)
+    cursor, err := sourceColl.Aggregate(context.TODO(), pipeline, options.Aggregate().SetAllowDiskUse(true))
+    if err != nil {
+        return err
+    }
+    return cursor.Close(context.TODO())
+}
+
+func buildAtxsEpochs(m *WriteDB, target string) error {
+    err := m.aggregateInto(atxsCollection, mongo.Pipeline{
+        bson.D{
+            {Key: "$group", Value: bson.D{
+                {Key: "_id", Value: "$publishepoch"},
+                {Key: "totalEffectiveNumUnits", Value: bson.D{{Key: "$sum", Value: "$effective_num_units"}}},
+                {Key: "totalWeight", Value: bson.D{{Key: "$sum", Value: "$weight"}}},
+                {Key: "totalAtx", Value: bson.D{{Key: "$sum", Value: 1}}},
+            }},
+        },
+    }, target)
+    if err != nil {
+        return err
+    }
+    return m.recomputeHighestAtxs(context.TODO(), target, bson.D{})
+}
+
+func buildAccountAtxsEpochs(m *WriteDB, target string) error {
+    return m.aggregateInto(atxsCollection, mongo.Pipeline{
+        bson.D{
+            {Key: "$group", Value: bson.D{
+                {Key: "_id", Value: bson.D{
+                    {Key: "coinbase", Value: "$coinbase"},
+                    {Key: "publish_epoch", Value: "$publishepoch"},
+                }},
+                {Key: "totalEffectiveNumUnits", Value: bson.D{{Key: "$sum", Value: "$effective_num_units"}}},
+                {Key: "totalWeight", Value: bson.D{{Key: "$sum", Value: "$weight"}}},
+                {Key: "totalAtx", Value: bson.D{{Key: "$sum", Value: 1}}},
+            }},
+        },
+    }, target)
+}
+
+func buildNodesCount(m *WriteDB, target string) error {
+    return m.aggregateInto(nodesCollection, mongo.Pipeline{
+        bson.D{{Key: "$count", Value: "count"}},
+        bson.D{{Key: "$set", Value: bson.D{{Key: "_id", Value: "nodesCount"}}}},
+    }, target)
+}
+
+func buildSmeshersEpochs(m *WriteDB, target string) error {
+    return m.aggregateInto(rewardsCollection, mongo.Pipeline{
+        bson.D{
+            {Key: "$group", Value: bson.D{
+                {Key: "_id", Value: bson.D{
+                    {Key: "node_id", Value: "$node_id"},
+                    {Key: "epoch", Value: bson.D{{Key: "$toInt", Value: bson.D{
+                        {Key: "$floor", Value: bson.D{{Key: "$divide", Value: bson.A{"$layer", config.LayersPerEpoch}}}},
+                    }}}},
+                }},
+                {Key: "coinbase", Value: bson.D{{Key: "$last", Value: "$coinbase"}}},
+                {Key: "rewards", Value: bson.D{{Key: "$sum", Value: "$totalReward"}}},
+                {Key: "rewardsCount", Value: bson.D{{Key: "$sum", Value: 1}}},
+            }},
+        },
+    }, target)
+}
@@ -0,0 +1,152 @@
+package database
+
+import (
+    "context"
+    "log"
+    "sync"
+    "time"
+
+    "github.com/swarmbit/spacemesh-state-api/metrics"
+    "github.com/swarmbit/spacemesh-state-api/types"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const reorgsCollection = "reorgs"
+
+const LayerStatusApplied = 3
+
+// rollbackTracker remembers the last layer the sink applied. The node applies every
+// layer again after a rollback, only the first layer applied below the last one starts
+// a rollback, the layers after it continue the same one. Layers saved again by a replay,
+// resync or refetch do not go through it.
+type rollbackTracker struct {
+    mu   sync.Mutex
+    last uint32
+}
+
+// applied records layer as the last applied layer and reports if it went back. The
+// first layer after a start is never a rollback, it may be a redelivery of a layer
+// saved before the stop.
+func (t *rollbackTracker) applied(layer uint32) bool {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+    rollback := t.last != 0 && layer < t.last
+    t.last = layer
+    return rollback
+}
+
+// sameRollback reports if last, the last recorded reorg, is the rollback to layer from
+// lastApplied, so a redelivered trigger layer is not recorded twice.
+func sameRollback(last *types.ReorgDoc, layer uint32, lastApplied uint32) bool {
+    return last != nil && last.TriggerLayer == layer && last.LastAppliedLayer == lastApplied
+}
+
+// detectRollback records a reorg when an already applied layer is applied again
+// while later layers were applied, which is what the node does after reverting state.
+func (m *WriteDB) detectRollback(ctx context.Context, layer uint32) error {
+    layersColl := m.client.Database(database).Collection(layersCollection)
+
+    existing := &types.LayerDoc{}
+    err := layersColl.FindOne(
+        ctx,
+        bson.D{{Key: "_id", Value: layer}},
+    ).Decode(existing)
+    if err == mongo.ErrNoDocuments {
+        return nil
+    }
+    if err != nil {
+        return err
+    }
+    if existing.Status != LayerStatusApplied {
+        return nil
+    }
+
+    last := &types.LayerDoc{}
+    err = layersColl.FindOne(
+        ctx,
+        bson.D{{Key: "status", Value: LayerStatusApplied}},
+        options.FindOne().SetSort(bson.D{{Key: "_id", Value: -1}}),
+    ).Decode(last)
+    if err != nil {
+        return err
+    }
+    if uint32(last.Layer) <= layer {
+        return nil
+    }
+
+    lastReorg := &types.ReorgDoc{}
+    err = m.client.Database(database).Collection(reorgsCollection).FindOne(
+        ctx,
+        bson.D{},
+        options.FindOne().SetSort(bson.D{{Key: "timestamp", Value: -1}}),
+    ).Decode(lastReorg)
+    if err == mongo.ErrNoDocuments {
+        lastReorg = nil
+    } else if err != nil {
+        return err
+    }
+    if sameRollback(lastReorg, layer, uint32(last.Layer)) {
+        return nil
+    }
+
+    layerFilter := bson.D{{Key: "layer", Value: bson.D{{Key: "$gte", Value: layer}}}}
+    affectedRewards, err := m.client.Database(database).Collection(rewardsCollection).CountDocuments(ctx, layerFilter)
+    if err != nil {
+        return err
+    }
+    affectedTransactions, err := m.client.Database(database).Collection(transactionsCollection).CountDocuments(ctx, layerFilter)
+    if err != nil {
+        return err
+    }
+
+    reorg := &types.ReorgDoc{
+        TriggerLayer:      layer,
+        LastAppliedLayer:  uint32(last.Layer),
+        Depth:             uint32(last.Layer) - layer,
+        AffectedDocuments: affectedRewards + affectedTransactions,
+        Timestamp:         time.Now().Unix(),
+    }
+    _, err = m.client.Database(database).Collection(reorgsCollection).InsertOne(ctx, reorg)
+    if err != nil {
+        return err
+    }
+
+    metrics.ReorgsTotal.Inc()
+    metrics.ReorgDepth.Observe(float64(reorg.Depth))
+    metrics.ReorgAffectedDocuments.Add(float64(reorg.AffectedDocuments))
+    log.Printf("Detected rollback to layer %d from layer %d", layer, last.Layer)
+    return nil
+}
+
+func (m *ReadDB) GetReorgs(skip int64, limit int64, sort int8) ([]*types.ReorgDoc, error) {
+    reorgsColl := m.client.Database(database).Collection(reorgsCollection)
+
+    findOptions := options.Find()
+    findOptions.SetSkip(skip)
+    findOptions.SetLimit(limit)
+    findOptions.SetSort(bson.M{"timestamp": sort})
+
+    ctx := context.TODO()
+    cursor, err := reorgsColl.Find(
+        ctx,
+        bson.D{},
+        findOptions,
+    )
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    var reorgs []*types.ReorgDoc
+    if err = cursor.All(ctx, &reorgs); err != nil {
+        return nil, err
+    }
+    return reorgs, nil
+}
+
+func (m *ReadDB) CountReorgs() (int64, error) {
+    reorgsColl := m.client.Database(database).Collection(reorgsCollection)
+    return reorgsColl.CountDocuments(context.TODO(), bson.D{})
+}
@@ -0,0 +1,47 @@
+package database
+
+import (
+    "context"
+    "fmt"
+
+    "go.mongodb.org/mongo-driver/bson"
+)
+
+// prunableCollections maps the per layer collections retention can prune to the field
+// holding the layer. Epoch aggregates, accounts and atxs are never pruned.
+var prunableCollections = map[string]string{
+    rewardsCollection:            "layer",
+    layersCollection:             "_id",
+    transactionsCollection:       "layer",
+    blocksCollection:             "layer",
+    networkInfoHistoryCollection: "_id",
+}
+
+// Prunable reports if retention policies can be set for collection.
+func Prunable(collection string) bool {
+    _, ok := prunableCollections[collection]
+    return ok
+}
+
+// PruneCollection deletes the documents of collection below beforeLayer and returns how
+// many were deleted, with dryRun they are only counted. Deletes are not recorded in the
+// change feed, retention is local to this database.
+func (m *WriteDB) PruneCollection(collection string, beforeLayer uint32, dryRun bool) (int64, error) {
+    field, ok := prunableCollections[collection]
+    if !ok {
+        return 0, fmt.Errorf("collection %s can not be pruned", collection)
+    }
+    if m.Fenced() {
+        return 0, ErrFenced
+    }
+    coll := m.client.Database(database).Collection(collection)
+    filter := bson.D{{Key: field, Value: bson.D{{Key: "$lt", Value: beforeLayer}}}}
+    if dryRun {
+        return coll.CountDocuments(context.TODO(), filter)
+    }
+    result, err := coll.DeleteMany(context.TODO(), filter)
+    if err != nil {
+        return 0, err
+    }
+    return result.DeletedCount, nil
+}
@@ -0,0 +1,113 @@
+package database
+
+import (
+    "fmt"
+    "path/filepath"
+    "testing"
+
+    "github.com/swarmbit/spacemesh-state-api/config"
+)
+
+const (
+    benchCoinbases         = 50
+    benchRewardsPerAccount = 2000
+    // a reward every few layers spreads each coinbase over several epochs
+    benchLayersPerReward = 10
+)
+
+// newRewardsBenchDB stores the rewards of benchCoinbases coinbases in a sqlite database
+// and sums them into the totals like a database written before the totals were kept.
+func newRewardsBenchDB(b *testing.B) *SqlDB {
+    b.Helper()
+    db, err := NewSqliteDB(filepath.Join(b.TempDir(), "rewards.db"))
+    if err != nil {
+        b.Fatal(err)
+    }
+    b.Cleanup(db.CloseWrite)
+
+    err = db.withTx(func(tx *sqlTx) error {
+        for i := 0; i < benchCoinbases; i++ {
+            for j := 0; j < benchRewardsPerAccount; j++ {
+                _, err := tx.Exec(
+                    `INSERT INTO rewards (`+rewardColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+                    fmt.Sprintf("reward-%d-%d", i, j), fmt.Sprintf("node-%d", i), benchCoinbase(i), "atx",
+                    1000, 1100, j*benchLayersPerReward,
+                )
+                if err != nil {
+                    return err
+                }
+            }
+        }
+        return nil
+    })
+    if err != nil {
+        b.Fatal(err)
+    }
+    if err = db.backfillCoinbaseRewardsTotals(); err != nil {
+        b.Fatal(err)
+    }
+
+    // the totals must agree with the scan they replace
+    sum, err := db.SumRewardsLayers(benchCoinbase(0), 0, benchRewardsPerAccount*benchLayersPerReward)
+    if err != nil {
+        b.Fatal(err)
+    }
+    total, err := db.GetCoinbaseRewardsTotal(benchCoinbase(0), AllEpochs)
+    if err != nil {
+        b.Fatal(err)
+    }
+    if total.Rewards != sum || total.RewardsCount != benchRewardsPerAccount {
+        b.Fatalf("totals %d in %d rewards, scan %d in %d", total.Rewards, total.RewardsCount, sum, benchRewardsPerAccount)
+    }
+    return db
+}
+
+func benchCoinbase(i int) string {
+    return fmt.Sprintf("coinbase-%d", i)
+}
+
+// BenchmarkCoinbaseRewards compares summing the reward documents of a coinbase, what
+// the account endpoints did on every call, with reading its pre-aggregated totals.
+func BenchmarkCoinbaseRewards(b *testing.B) {
+    db := newRewardsBenchDB(b)
+    lastLayer := uint32(benchRewardsPerAccount * benchLayersPerReward)
+    epoch := uint32(1)
+    firstEpochLayer := epoch * config.LayersPerEpoch
+
+    b.Run("all-time/scan", func(b *testing.B) {
+        for i := 0; i < b.N; i++ {
+            coinbase := benchCoinbase(i % benchCoinbases)
+            if _, err := db.SumRewardsLayers(coinbase, 0, lastLayer); err != nil {
+                b.Fatal(err)
+            }
+            if _, err := db.CountRewards(coinbase, -1, -1); err != nil {
+                b.Fatal(err)
+            }
+        }
+    })
+    b.Run("all-time/totals", func(b *testing.B) {
+        for i := 0; i < b.N; i++ {
+            if _, err := db.GetCoinbaseRewardsTotal(benchCoinbase(i%benchCoinbases), AllEpochs); err != nil {
+                b.Fatal(err)
+            }
+        }
+    })
+    b.Run("epoch/scan", func(b *testing.B) {
+        for i := 0; i < b.N; i++ {
+            coinbase := benchCoinbase(i % benchCoinbases)
+            if _, err := db.SumRewardsLayers(coinbase, firstEpochLayer, firstEpochLayer+config.LayersPerEpoch); err != nil {
+                b.Fatal(err)
+            }
+            if _, err := db.CountRewards(coinbase, int(firstEpochLayer), int(firstEpochLayer+config.LayersPerEpoch)); err != nil {
+                b.Fatal(err)
+            }
+        }
+    })
+    b.Run("epoch/totals", func(b *testing.B) {
+        for i := 0; i < b.N; i++ {
+            if _, err := db.GetCoinbaseRewardsTotal(benchCoinbase(i%benchCoinbases), int64(epoch)); err != nil {
+                b.Fatal(err)
+            }
+        }
+    })
+}
@@ -0,0 +1,104 @@
+package database
+
+import (
+    "context"
+
+    "github.com/swarmbit/spacemesh-state-api/config"
+    "github.com/swarmbit/spacemesh-state-api/types"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const coinbaseRewardsTotalsCollection = "coinbaseRewardsTotals"
+
+// AllEpochs is the epoch of the all-time rewards total of a coinbase.
+const AllEpochs int64 = -1
+
+// incCoinbaseRewardsTotals adds a new reward to the totals of its coinbase, it runs in
+// the transaction that saves the reward so the totals never count it twice.
+func (m *WriteDB) incCoinbaseRewardsTotals(ctx context.Context, coinbase string, layer uint32, total uint64) (*mongo.BulkWriteResult, error) {
+    totalsColl := m.client.Database(database).Collection(coinbaseRewardsTotalsCollection)
+    inc := bson.D{{Key: "$inc", Value: bson.D{
+        {Key: "rewards", Value: int64(total)},
+        {Key: "rewardsCount", Value: 1},
+    }}}
+    epoch := int64(layer / config.LayersPerEpoch)
+    return totalsColl.BulkWrite(ctx, []mongo.WriteModel{
+        mongo.NewUpdateOneModel().
+            SetFilter(bson.D{{Key: "_id", Value: types.CoinbaseRewardsTotalId{Coinbase: coinbase, Epoch: epoch}}}).
+            SetUpdate(inc).
+            SetUpsert(true),
+        mongo.NewUpdateOneModel().
+            SetFilter(bson.D{{Key: "_id", Value: types.CoinbaseRewardsTotalId{Coinbase: coinbase, Epoch: AllEpochs}}}).
+            SetUpdate(inc).
+            SetUpsert(true),
+    })
+}
+
+// coinbaseRewardsTotalsPipelines sum the rewards collection per coinbase and epoch and
+// per coinbase into the totals collection into.
+func coinbaseRewardsTotalsPipelines(into string) []mongo.Pipeline {
+    merge := bson.D{
+        {Key: "$merge", Value: bson.D{
+            {Key: "into", Value: into},
+            {Key: "on", Value: "_id"},
+            {Key: "whenMatched", Value: "replace"},
+            {Key: "whenNotMatched", Value: "insert"},
+        }},
+    }
+    epoch := bson.D{{Key: "$toLong", Value: bson.D{
+        {Key: "$floor", Value: bson.D{{Key: "$divide", Value: bson.A{"$layer", config.LayersPerEpoch}}}},
+    }}}
+    pipeline := func(epoch interface{}) mongo.Pipeline {
+        return mongo.Pipeline{
+            bson.D{
+                {Key: "$group", Value: bson.D{
+                    {Key: "_id", Value: bson.D{
+                        {Key: "coinbase", Value: "$coinbase"},
+                        {Key: "epoch", Value: epoch},
+                    }},
+                    {Key: "rewards", Value: bson.D{{Key: "$sum", Value: "$totalReward"}}},
+                    {Key: "rewardsCount", Value: bson.D{{Key: "$sum", Value: 1}}},
+                }},
+            },
+            merge,
+        }
+    }
+    return []mongo.Pipeline{pipeline(epoch), pipeline(AllEpochs)}
+}
+
+// aggregateCoinbaseRewardsTotals recomputes every coinbase total from the rewards, for
+// databases written before the totals were kept and for rebuilds.
+func aggregateCoinbaseRewardsTotals(db *mongo.Database, into string) error {
+    rewardsColl := db.Collection(rewardsCollection)
+    for _, pipeline := range coinbaseRewardsTotalsPipelines(into) {
+        cursor, err := rewardsColl.Aggregate(context.TODO(), pipeline, options.Aggregate().SetAllowDiskUse(true))
+        if err != nil {
+            return err
+        }
+        cursor.Close(context.TODO())
+    }
+    return nil
+}
+
+func buildCoinbaseRewardsTotals(m *WriteDB, target string) error {
+    return aggregateCoinbaseRewardsTotals(m.client.Database(database), target)
+}
+
+// GetCoinbaseRewardsTotal returns the rewards of coinbase in epoch, or in every epoch
+// with AllEpochs. A coinbase without rewards has a zero total.
+func (m *ReadDB) GetCoinbaseRewardsTotal(coinbase string, epoch int64) (*types.CoinbaseRewardsTotalDoc, error) {
+    totalsColl := m.client.Database(database).Collection(coinbaseRewardsTotalsCollection)
+
+    id := types.CoinbaseRewardsTotalId{Coinbase: coinbase, Epoch: epoch}
+    total := &types.CoinbaseRewardsTotalDoc{}
+    err := totalsColl.FindOne(context.TODO(), bson.D{{Key: "_id", Value: id}}).Decode(total)
+    if err == mongo.ErrNoDocuments {
+        return &types.CoinbaseRewardsTotalDoc{Id: id}, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    return total, nil
+}
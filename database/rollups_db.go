@@ -0,0 +1,157 @@
+package database
+
+import (
+    "context"
+
+    "github.com/swarmbit/spacemesh-state-api/config"
+    "github.com/swarmbit/spacemesh-state-api/types"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const accountRewardsRollupsCollection = "accountRewardsRollups"
+const networkRewardsRollupsCollection = "networkRewardsRollups"
+
+const (
+    RollupDay   = "day"
+    RollupEpoch = "epoch"
+    // fees are rolled up per layer and per epoch
+    RollupLayer = "layer"
+)
+
+const daySeconds = 24 * 60 * 60
+
+// rollupDayFromLayer is the first layer of the day fromEpoch starts in, days are
+// recomputed from their start so a day split by the epoch is not left partial.
+func rollupDayFromLayer(fromEpoch uint32) int64 {
+    epochStart := config.GenesisEpochSeconds + int64(fromEpoch)*int64(config.LayersPerEpoch)*config.LayerDuration
+    dayStart := epochStart / daySeconds * daySeconds
+    layer := (dayStart - config.GenesisEpochSeconds + config.LayerDuration - 1) / config.LayerDuration
+    if layer < 0 {
+        return 0
+    }
+    return layer
+}
+
+func rewardsRollupBucket(granularity string) bson.D {
+    if granularity == RollupEpoch {
+        return bson.D{{Key: "$toLong", Value: bson.D{
+            {Key: "$floor", Value: bson.D{{Key: "$divide", Value: bson.A{"$layer", config.LayersPerEpoch}}}},
+        }}}
+    }
+    layerTime := bson.D{{Key: "$add", Value: bson.A{
+        config.GenesisEpochSeconds,
+        bson.D{{Key: "$multiply", Value: bson.A{"$layer", config.LayerDuration}}},
+    }}}
+    return bson.D{{Key: "$toLong", Value: bson.D{{Key: "$multiply", Value: bson.A{
+        bson.D{{Key: "$floor", Value: bson.D{{Key: "$divide", Value: bson.A{layerTime, daySeconds}}}}},
+        daySeconds,
+    }}}}}
+}
+
+func rewardsRollupPipeline(granularity string, fromLayer int64, perAccount bool, into string) mongo.Pipeline {
+    id := bson.D{
+        {Key: "granularity", Value: granularity},
+        {Key: "bucket", Value: rewardsRollupBucket(granularity)},
+    }
+    if perAccount {
+        id = append(bson.D{{Key: "account", Value: "$coinbase"}}, id...)
+    }
+    return mongo.Pipeline{
+        bson.D{
+            {Key: "$match", Value: bson.D{
+                {Key: "layer", Value: bson.D{{Key: "$gte", Value: fromLayer}}},
+            }},
+        },
+        bson.D{
+            {Key: "$group", Value: bson.D{
+                {Key: "_id", Value: id},
+                {Key: "rewards", Value: bson.D{{Key: "$sum", Value: "$totalReward"}}},
+                {Key: "rewardsCount", Value: bson.D{{Key: "$sum", Value: 1}}},
+            }},
+        },
+        bson.D{
+            {Key: "$merge", Value: bson.D{
+                {Key: "into", Value: into},
+                {Key: "on", Value: "_id"},
+                {Key: "whenMatched", Value: "replace"},
+                {Key: "whenNotMatched", Value: "insert"},
+            }},
+        },
+    }
+}
+
+// AggregateRewardsRollups recomputes the daily and per epoch rewards of every account
+// and of the whole network, starting at fromEpoch. Earlier buckets are left untouched.
+func (m *WriteDB) AggregateRewardsRollups(fromEpoch uint32) error {
+    if m.Fenced() {
+        return ErrFenced
+    }
+    rewardsColl := m.client.Database(database).Collection(rewardsCollection)
+
+    epochFromLayer := int64(fromEpoch) * int64(config.LayersPerEpoch)
+    dayFromLayer := rollupDayFromLayer(fromEpoch)
+    pipelines := []mongo.Pipeline{
+        rewardsRollupPipeline(RollupEpoch, epochFromLayer, true, accountRewardsRollupsCollection),
+        rewardsRollupPipeline(RollupDay, dayFromLayer, true, accountRewardsRollupsCollection),
+        rewardsRollupPipeline(RollupEpoch, epochFromLayer, false, networkRewardsRollupsCollection),
+        rewardsRollupPipeline(RollupDay, dayFromLayer, false, networkRewardsRollupsCollection),
+    }
+    for _, pipeline := range pipelines {
+        cursor, err := rewardsColl.Aggregate(context.TODO(), pipeline, options.Aggregate().SetAllowDiskUse(true))
+        if err != nil {
+            return err
+        }
+        cursor.Close(context.TODO())
+    }
+    return nil
+}
+
+// GetRewardsRollups returns the buckets of account between from and to included, sorted
+// by bucket. An empty account returns the network wide rollups.
+func (m *ReadDB) GetRewardsRollups(account string, granularity string, from int64, to int64) ([]*types.RewardsRollupDoc, error) {
+    collection := networkRewardsRollupsCollection
+    filter := bson.D{}
+    if account != "" {
+        collection = accountRewardsRollupsCollection
+        filter = append(filter, bson.E{Key: "_id.account", Value: account})
+    }
+    filter = append(filter,
+        bson.E{Key: "_id.granularity", Value: granularity},
+        bson.E{Key: "_id.bucket", Value: bson.D{{Key: "$gte", Value: from}, {Key: "$lte", Value: to}}},
+    )
+    rollupsColl := m.client.Database(database).Collection(collection)
+
+    ctx := context.TODO()
+    cursor, err := rollupsColl.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "_id.bucket", Value: 1}}))
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    rollups := make([]*types.RewardsRollupDoc, 0)
+    if err = cursor.All(ctx, &rollups); err != nil {
+        return nil, err
+    }
+    return rollups, nil
+}
+
+// GetLastRewardsRollupEpoch returns the last epoch rolled up, zero when there is none.
+func (m *ReadDB) GetLastRewardsRollupEpoch() (uint32, error) {
+    rollupsColl := m.client.Database(database).Collection(networkRewardsRollupsCollection)
+
+    rollup := &types.RewardsRollupDoc{}
+    err := rollupsColl.FindOne(
+        context.TODO(),
+        bson.D{{Key: "_id.granularity", Value: RollupEpoch}},
+        options.FindOne().SetSort(bson.D{{Key: "_id.bucket", Value: -1}}),
+    ).Decode(rollup)
+    if err == mongo.ErrNoDocuments {
+        return 0, nil
+    }
+    if err != nil {
+        return 0, err
+    }
+    return uint32(rollup.Id.Bucket), nil
+}
@@ -0,0 +1,50 @@
+package database
+
+import (
+    "context"
+    "log"
+
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/mongo"
+)
+
+// transactionsSupported reports if the server runs multi-document transactions, replica
+// set members and mongos do, standalone servers reject them.
+func transactionsSupported(client *mongo.Client) bool {
+    hello := bson.M{}
+    err := client.Database("admin").RunCommand(context.TODO(), bson.D{{Key: "hello", Value: 1}}).Decode(&hello)
+    if err != nil {
+        log.Printf("Failed to check the mongo topology, writing without transactions: %v", err)
+        return false
+    }
+    _, replicaSet := hello["setName"]
+    return replicaSet || hello["msg"] == "isdbgrid"
+}
+
+// withTransaction runs fn in a transaction so the documents it writes are saved together
+// or not at all, every write of fn must use the context it is given, derived from ctx. fn is run again
+// when the transaction hits a transient error, it must reset what it captured. Without
+// transactions fn runs once with a plain context, a failure then can leave the writes
+// made before it, the derived collections are recomputed with RebuildAggregate. The
+// transaction fails with ErrFenced once a newer instance holds the sink fence.
+func (m *WriteDB) withTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+    if !m.transactions {
+        if err := m.verifyFence(ctx); err != nil {
+            return err
+        }
+        return fn(ctx)
+    }
+    session, err := m.client.StartSession()
+    if err != nil {
+        return err
+    }
+    defer session.EndSession(context.TODO())
+
+    _, err = session.WithTransaction(ctx, func(sessionContext mongo.SessionContext) (interface{}, error) {
+        if err := m.verifyFence(sessionContext); err != nil {
+            return nil, err
+        }
+        return nil, fn(sessionContext)
+    })
+    return err
+}
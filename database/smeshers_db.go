@@ -0,0 +1,239 @@
+package database
+
+import (
+    "context"
+
+    "github.com/swarmbit/spacemesh-state-api/config"
+    "github.com/swarmbit/spacemesh-state-api/types"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AggregateSmeshersEpochRewards recomputes the per epoch rewards of every smesher
+// starting at the first layer of fromEpoch. Earlier epochs are left untouched.
+func (m *WriteDB) AggregateSmeshersEpochRewards(fromEpoch uint32) error {
+    if m.Fenced() {
+        return ErrFenced
+    }
+    rewardsColl := m.client.Database(database).Collection(rewardsCollection)
+
+    pipeline := mongo.Pipeline{
+        bson.D{
+            {Key: "$match", Value: bson.D{
+                {Key: "layer", Value: bson.D{{Key: "$gte", Value: int64(fromEpoch) * int64(config.LayersPerEpoch)}}},
+            }},
+        },
+        bson.D{
+            {Key: "$group", Value: bson.D{
+                {Key: "_id", Value: bson.D{
+                    {Key: "node_id", Value: "$node_id"},
+                    {Key: "epoch", Value: bson.D{{Key: "$toInt", Value: bson.D{
+                        {Key: "$floor", Value: bson.D{{Key: "$divide", Value: bson.A{"$layer", config.LayersPerEpoch}}}},
+                    }}}},
+                }},
+                {Key: "coinbase", Value: bson.D{{Key: "$last", Value: "$coinbase"}}},
+                {Key: "rewards", Value: bson.D{{Key: "$sum", Value: "$totalReward"}}},
+                {Key: "rewardsCount", Value: bson.D{{Key: "$sum", Value: 1}}},
+            }},
+        },
+        bson.D{
+            {Key: "$merge", Value: bson.D{
+                {Key: "into", Value: smeshersEpochsCollection},
+                {Key: "on", Value: "_id"},
+                {Key: "whenMatched", Value: "replace"},
+                {Key: "whenNotMatched", Value: "insert"},
+            }},
+        },
+    }
+
+    cursor, err := rewardsColl.Aggregate(context.TODO(), pipeline, options.Aggregate().SetAllowDiskUse(true))
+    if err != nil {
+        return err
+    }
+    return cursor.Close(context.TODO())
+}
+
+// AggregateSmeshersTotals folds the per epoch rewards and the atx collection into
+// one document per smesher. Only the smeshers with rewards or atxs in fromEpoch or later
+// are recomputed, the totals of the others can not have changed.
+func (m *WriteDB) AggregateSmeshersTotals(fromEpoch uint32) error {
+    if m.Fenced() {
+        return ErrFenced
+    }
+    smeshersEpochsColl := m.client.Database(database).Collection(smeshersEpochsCollection)
+    atxsColl := m.client.Database(database).Collection(atxsCollection)
+
+    merge := bson.D{
+        {Key: "$merge", Value: bson.D{
+            {Key: "into", Value: smeshersCollection},
+            {Key: "on", Value: "_id"},
+            {Key: "whenMatched", Value: "merge"},
+            {Key: "whenNotMatched", Value: "insert"},
+        }},
+    }
+
+    rewardsPipeline := mongo.Pipeline{
+        bson.D{{Key: "$match", Value: bson.D{{Key: "_id.epoch", Value: bson.D{{Key: "$gte", Value: fromEpoch}}}}}},
+        bson.D{{Key: "$group", Value: bson.D{{Key: "_id", Value: "$_id.node_id"}}}},
+        bson.D{{Key: "$lookup", Value: bson.D{
+            {Key: "from", Value: smeshersEpochsCollection},
+            {Key: "localField", Value: "_id"},
+            {Key: "foreignField", Value: "_id.node_id"},
+            {Key: "as", Value: "epochs"},
+        }}},
+        bson.D{{Key: "$project", Value: bson.D{
+            {Key: "totalRewards", Value: bson.D{{Key: "$sum", Value: "$epochs.rewards"}}},
+            {Key: "rewardsCount", Value: bson.D{{Key: "$sum", Value: "$epochs.rewardsCount"}}},
+        }}},
+        merge,
+    }
+
+    cursor, err := smeshersEpochsColl.Aggregate(context.TODO(), rewardsPipeline, options.Aggregate().SetAllowDiskUse(true))
+    if err != nil {
+        return err
+    }
+    if err = cursor.Close(context.TODO()); err != nil {
+        return err
+    }
+
+    // the atx with the highest publish epoch has the current coinbase and units
+    latestAtx := bson.D{{Key: "$reduce", Value: bson.D{
+        {Key: "input", Value: "$atxs"},
+        {Key: "initialValue", Value: bson.D{{Key: "publishepoch", Value: -1}}},
+        {Key: "in", Value: bson.D{{Key: "$cond", Value: bson.A{
+            bson.D{{Key: "$gt", Value: bson.A{"$$this.publishepoch", "$$value.publishepoch"}}},
+            "$$this",
+            "$$value",
+        }}}},
+    }}}
+    atxPipeline := mongo.Pipeline{
+        bson.D{{Key: "$match", Value: bson.D{{Key: "publishepoch", Value: bson.D{{Key: "$gte", Value: fromEpoch}}}}}},
+        bson.D{{Key: "$group", Value: bson.D{{Key: "_id", Value: "$node_id"}}}},
+        bson.D{{Key: "$lookup", Value: bson.D{
+            {Key: "from", Value: atxsCollection},
+            {Key: "localField", Value: "_id"},
+            {Key: "foreignField", Value: "node_id"},
+            {Key: "as", Value: "atxs"},
+        }}},
+        bson.D{{Key: "$set", Value: bson.D{{Key: "latest", Value: latestAtx}}}},
+        bson.D{{Key: "$project", Value: bson.D{
+            {Key: "coinbase", Value: "$latest.coinbase"},
+            {Key: "effectiveNumUnits", Value: "$latest.effective_num_units"},
+            {Key: "lastEpoch", Value: "$latest.publishepoch"},
+            {Key: "totalAtx", Value: bson.D{{Key: "$size", Value: "$atxs"}}},
+        }}},
+        merge,
+    }
+
+    cursor, err = atxsColl.Aggregate(context.TODO(), atxPipeline, options.Aggregate().SetAllowDiskUse(true))
+    if err != nil {
+        return err
+    }
+    return cursor.Close(context.TODO())
+}
+
+func (m *ReadDB) GetTopSmeshers(sortField string, skip int64, limit int64) ([]*types.SmesherDoc, error) {
+    smeshersColl := m.client.Database(database).Collection(smeshersCollection)
+
+    findOptions := options.Find()
+    findOptions.SetSkip(skip)
+    findOptions.SetLimit(limit)
+    findOptions.SetSort(bson.D{{Key: sortField, Value: -1}, {Key: "_id", Value: 1}})
+
+    ctx := context.TODO()
+    cursor, err := smeshersColl.Find(
+        ctx,
+        bson.D{},
+        findOptions,
+    )
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    var smeshers []*types.SmesherDoc
+    if err = cursor.All(ctx, &smeshers); err != nil {
+        return nil, err
+    }
+    return smeshers, nil
+}
+
+func (m *ReadDB) GetTopSmeshersEpoch(epoch uint32, skip int64, limit int64) ([]*types.SmesherEpochDoc, error) {
+    smeshersEpochsColl := m.client.Database(database).Collection(smeshersEpochsCollection)
+
+    findOptions := options.Find()
+    findOptions.SetSkip(skip)
+    findOptions.SetLimit(limit)
+    findOptions.SetSort(bson.D{{Key: "rewards", Value: -1}, {Key: "_id.node_id", Value: 1}})
+
+    ctx := context.TODO()
+    cursor, err := smeshersEpochsColl.Find(
+        ctx,
+        bson.D{{Key: "_id.epoch", Value: epoch}},
+        findOptions,
+    )
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    var smeshers []*types.SmesherEpochDoc
+    if err = cursor.All(ctx, &smeshers); err != nil {
+        return nil, err
+    }
+    return smeshers, nil
+}
+
+func (m *ReadDB) GetSmeshers(nodeIds []string) ([]*types.SmesherDoc, error) {
+    smeshersColl := m.client.Database(database).Collection(smeshersCollection)
+
+    ctx := context.TODO()
+    cursor, err := smeshersColl.Find(
+        ctx,
+        bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: nodeIds}}}},
+    )
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    var smeshers []*types.SmesherDoc
+    if err = cursor.All(ctx, &smeshers); err != nil {
+        return nil, err
+    }
+    return smeshers, nil
+}
+
+// GetCoinbaseNodes returns the ids of the nodes that were rewarded to coinbase.
+func (m *ReadDB) GetCoinbaseNodes(coinbase string) ([]string, error) {
+    rewardsColl := m.client.Database(database).Collection(rewardsCollection)
+    values, err := rewardsColl.Distinct(
+        context.TODO(),
+        "node_id",
+        bson.D{{Key: "coinbase", Value: coinbase}},
+    )
+    if err != nil {
+        return nil, err
+    }
+    nodeIds := make([]string, 0, len(values))
+    for _, value := range values {
+        if nodeId, ok := value.(string); ok {
+            nodeIds = append(nodeIds, nodeId)
+        }
+    }
+    return nodeIds, nil
+}
+
+func (m *ReadDB) CountSmeshers() (int64, error) {
+    smeshersColl := m.client.Database(database).Collection(smeshersCollection)
+    return smeshersColl.EstimatedDocumentCount(context.TODO())
+}
+
+func (m *ReadDB) CountSmeshersEpoch(epoch uint32) (int64, error) {
+    smeshersEpochsColl := m.client.Database(database).Collection(smeshersEpochsCollection)
+    return smeshersEpochsColl.CountDocuments(
+        context.TODO(),
+        bson.D{{Key: "_id.epoch", Value: epoch}},
+    )
+}
@@ -0,0 +1,108 @@
+package database
+
+import (
+    "context"
+
+    "github.com/swarmbit/spacemesh-state-api/types"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (m *WriteDB) SaveSmeshersPerformance(docs []*types.SmesherPerformanceDoc) error {
+    if m.Fenced() {
+        return ErrFenced
+    }
+    if len(docs) == 0 {
+        return nil
+    }
+    performanceColl := m.client.Database(database).Collection(smeshersPerformanceCollection)
+    models := make([]mongo.WriteModel, len(docs))
+    for i, doc := range docs {
+        models[i] = mongo.NewReplaceOneModel().
+            SetFilter(bson.D{{Key: "_id", Value: doc.Id}}).
+            SetReplacement(doc).
+            SetUpsert(true)
+    }
+    _, err := performanceColl.BulkWrite(context.TODO(), models, options.BulkWrite().SetOrdered(false))
+    return err
+}
+
+func (m *ReadDB) GetSmesherPerformance(nodeId string, skip int64, limit int64) ([]*types.SmesherPerformanceDoc, error) {
+    performanceColl := m.client.Database(database).Collection(smeshersPerformanceCollection)
+
+    findOptions := options.Find()
+    findOptions.SetSkip(skip)
+    findOptions.SetLimit(limit)
+    findOptions.SetSort(bson.D{{Key: "_id.epoch", Value: -1}})
+
+    ctx := context.TODO()
+    cursor, err := performanceColl.Find(ctx, bson.D{{Key: "_id.node_id", Value: nodeId}}, findOptions)
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    var docs []*types.SmesherPerformanceDoc
+    if err = cursor.All(ctx, &docs); err != nil {
+        return nil, err
+    }
+    return docs, nil
+}
+
+func (m *ReadDB) CountSmesherPerformance(nodeId string) (int64, error) {
+    performanceColl := m.client.Database(database).Collection(smeshersPerformanceCollection)
+    return performanceColl.CountDocuments(context.TODO(), bson.D{{Key: "_id.node_id", Value: nodeId}})
+}
+
+// GetLastSmeshersPerformanceEpoch returns the last epoch with performance stored, zero
+// when there is none.
+func (m *ReadDB) GetLastSmeshersPerformanceEpoch() (uint32, error) {
+    performanceColl := m.client.Database(database).Collection(smeshersPerformanceCollection)
+
+    doc := &types.SmesherPerformanceDoc{}
+    err := performanceColl.FindOne(
+        context.TODO(),
+        bson.D{},
+        options.FindOne().SetSort(bson.D{{Key: "_id.epoch", Value: -1}}),
+    ).Decode(doc)
+    if err == mongo.ErrNoDocuments {
+        return 0, nil
+    }
+    if err != nil {
+        return 0, err
+    }
+    return doc.Id.Epoch, nil
+}
+
+func (m *ReadDB) GetEpochPerformance(epoch uint32) (*types.EpochPerformanceDoc, error) {
+    performanceColl := m.client.Database(database).Collection(smeshersPerformanceCollection)
+
+    match := bson.D{{Key: "$match", Value: bson.D{{Key: "_id.epoch", Value: epoch}}}}
+    group := bson.D{
+        {Key: "$group", Value: bson.D{
+            {Key: "_id", Value: nil},
+            {Key: "smeshers", Value: bson.D{{Key: "$sum", Value: 1}}},
+            {Key: "slots", Value: bson.D{{Key: "$sum", Value: "$slots"}}},
+            {Key: "expectedRewards", Value: bson.D{{Key: "$sum", Value: "$expectedRewards"}}},
+            {Key: "rewardsCount", Value: bson.D{{Key: "$sum", Value: "$rewardsCount"}}},
+            {Key: "complete", Value: bson.D{{Key: "$min", Value: "$complete"}}},
+        }},
+    }
+
+    ctx := context.TODO()
+    cursor, err := performanceColl.Aggregate(ctx, mongo.Pipeline{match, group})
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    var results []*types.EpochPerformanceDoc
+    if err = cursor.All(ctx, &results); err != nil {
+        return nil, err
+    }
+    if len(results) == 0 {
+        return &types.EpochPerformanceDoc{}, nil
+    }
+    return results[0], nil
+}
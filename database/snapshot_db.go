@@ -0,0 +1,272 @@
+package database
+
+import (
+    "bufio"
+    "compress/gzip"
+    "context"
+    "encoding/json"
+    "fmt"
+    "log"
+    "os"
+    "path/filepath"
+    "strings"
+    "time"
+
+    "github.com/swarmbit/spacemesh-state-api/config"
+    "github.com/swarmbit/spacemesh-state-api/types"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const snapshotManifestFile = "manifest.json"
+
+const snapshotBatchSize = 1000
+
+// SnapshotManifest describes a snapshot, the documents of each collection are in
+// <collection>.jsonl.gz next to it as canonical extended json. The last processed layer
+// and the stream checkpoints are the ones restored, so the sink resumes the consumers
+// where the snapshot was taken.
+type SnapshotManifest struct {
+    CreatedAt          int64                     `json:"createdAt"`
+    Network            string                    `json:"network"`
+    Database           string                    `json:"database"`
+    LastProcessedLayer uint32                    `json:"lastProcessedLayer"`
+    StreamCheckpoints  []*types.StreamCheckpoint `json:"streamCheckpoints"`
+    Collections        map[string]int64          `json:"collections"`
+}
+
+// connectMongo opens a client with primary reads for the one off commands.
+func connectMongo(dbConfig *config.DBConfig) (*mongo.Client, error) {
+    useDatabase(dbConfig)
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+    clientOptions, err := mongoClientOptions(dbConfig.Uri, dbConfig.Mongo, false)
+    if err != nil {
+        return nil, err
+    }
+    return mongo.Connect(ctx, clientOptions)
+}
+
+func snapshotsSupported(dbConfig *config.DBConfig) error {
+    if dbConfig.Backend == BackendPostgres || dbConfig.Backend == BackendSqlite {
+        return fmt.Errorf("snapshots are only supported by the mongo backend, use the %s tools to copy the database", dbConfig.Backend)
+    }
+    return nil
+}
+
+// CreateSnapshot dumps every collection of the database into dir. The sink must not
+// write meanwhile, the snapshot fails when the last processed layer moved while it was
+// taken.
+func CreateSnapshot(dbConfig *config.DBConfig, dir string) (*SnapshotManifest, error) {
+    if err := snapshotsSupported(dbConfig); err != nil {
+        return nil, err
+    }
+    client, err := connectMongo(dbConfig)
+    if err != nil {
+        return nil, err
+    }
+    defer client.Disconnect(context.TODO())
+    if err := os.MkdirAll(dir, 0o755); err != nil {
+        return nil, err
+    }
+
+    readDB := &ReadDB{client: client}
+    layer, err := readDB.GetLastProcessedLayer()
+    if err != nil {
+        return nil, err
+    }
+    checkpoints, err := readDB.GetStreamCheckpoints()
+    if err != nil {
+        return nil, err
+    }
+    manifest := &SnapshotManifest{
+        CreatedAt:          time.Now().Unix(),
+        Network:            config.NetworkName,
+        Database:           database,
+        LastProcessedLayer: uint32(layer.Layer),
+        StreamCheckpoints:  make([]*types.StreamCheckpoint, len(checkpoints)),
+        Collections:        make(map[string]int64),
+    }
+    for i, v := range checkpoints {
+        manifest.StreamCheckpoints[i] = &types.StreamCheckpoint{
+            Consumer:  v.Consumer,
+            Stream:    v.Stream,
+            Sequence:  v.Sequence,
+            Layer:     v.Layer,
+            UpdatedAt: v.UpdatedAt.Unix(),
+            WriterInstance:   v.WriterInstance,
+            WriterGeneration: v.WriterGeneration,
+        }
+    }
+
+    db := client.Database(database)
+    names, err := db.ListCollectionNames(context.TODO(), bson.D{{Key: "type", Value: "collection"}})
+    if err != nil {
+        return nil, err
+    }
+    for _, name := range names {
+        if strings.HasPrefix(name, "system.") {
+            continue
+        }
+        count, err := dumpCollection(db.Collection(name), filepath.Join(dir, name+".jsonl.gz"))
+        if err != nil {
+            return nil, fmt.Errorf("failed to dump %s: %w", name, err)
+        }
+        manifest.Collections[name] = count
+        log.Printf("Dumped %d documents of %s", count, name)
+    }
+
+    after, err := readDB.GetLastProcessedLayer()
+    if err != nil {
+        return nil, err
+    }
+    if uint32(after.Layer) != manifest.LastProcessedLayer {
+        return nil, fmt.Errorf("layer %d was processed while the snapshot was taken, stop the sink and take it again", after.Layer)
+    }
+
+    data, err := json.MarshalIndent(manifest, "", "  ")
+    if err != nil {
+        return nil, err
+    }
+    return manifest, os.WriteFile(filepath.Join(dir, snapshotManifestFile), data, 0o644)
+}
+
+func dumpCollection(coll *mongo.Collection, path string) (int64, error) {
+    file, err := os.Create(path)
+    if err != nil {
+        return 0, err
+    }
+    defer file.Close()
+    writer := gzip.NewWriter(file)
+
+    ctx := context.TODO()
+    cursor, err := coll.Find(ctx, bson.D{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+    if err != nil {
+        return 0, err
+    }
+    defer cursor.Close(ctx)
+
+    var count int64
+    for cursor.Next(ctx) {
+        line, err := bson.MarshalExtJSON(cursor.Current, true, false)
+        if err != nil {
+            return count, err
+        }
+        if _, err := writer.Write(append(line, '\n')); err != nil {
+            return count, err
+        }
+        count++
+    }
+    if err := cursor.Err(); err != nil {
+        return count, err
+    }
+    if err := writer.Close(); err != nil {
+        return count, err
+    }
+    return count, file.Close()
+}
+
+// RestoreSnapshot loads a snapshot of dir into the database, which must be empty, and
+// creates the indexes. The sink continues from the last processed layer and the stream
+// checkpoints of the manifest.
+func RestoreSnapshot(dbConfig *config.DBConfig, dir string) (*SnapshotManifest, error) {
+    data, err := os.ReadFile(filepath.Join(dir, snapshotManifestFile))
+    if err != nil {
+        return nil, err
+    }
+    if err := snapshotsSupported(dbConfig); err != nil {
+        return nil, err
+    }
+    manifest := &SnapshotManifest{}
+    if err := json.Unmarshal(data, manifest); err != nil {
+        return nil, fmt.Errorf("invalid snapshot manifest: %w", err)
+    }
+    if manifest.Network != config.NetworkName {
+        return nil, fmt.Errorf("snapshot of network %s can not be restored on network %s", manifest.Network, config.NetworkName)
+    }
+
+    client, err := connectMongo(dbConfig)
+    if err != nil {
+        return nil, err
+    }
+    defer client.Disconnect(context.TODO())
+
+    db := client.Database(database)
+    existing, err := db.ListCollectionNames(context.TODO(), bson.D{})
+    if err != nil {
+        return nil, err
+    }
+    for _, name := range existing {
+        if !strings.HasPrefix(name, "system.") {
+            return nil, fmt.Errorf("database %s is not empty, snapshots are only restored into an empty database", database)
+        }
+    }
+
+    for name, expected := range manifest.Collections {
+        count, err := loadCollection(db.Collection(name), filepath.Join(dir, name+".jsonl.gz"))
+        if err != nil {
+            return nil, fmt.Errorf("failed to load %s: %w", name, err)
+        }
+        if count != expected {
+            return nil, fmt.Errorf("loaded %d documents of %s, the manifest has %d", count, name, expected)
+        }
+        log.Printf("Loaded %d documents of %s", count, name)
+    }
+
+    if err := migrate(client); err != nil {
+        return nil, err
+    }
+    layer, err := (&ReadDB{client: client}).GetLastProcessedLayer()
+    if err != nil {
+        return nil, err
+    }
+    if uint32(layer.Layer) != manifest.LastProcessedLayer {
+        return nil, fmt.Errorf("restored last processed layer %d, the manifest has %d", layer.Layer, manifest.LastProcessedLayer)
+    }
+    return manifest, nil
+}
+
+func loadCollection(coll *mongo.Collection, path string) (int64, error) {
+    file, err := os.Open(path)
+    if err != nil {
+        return 0, err
+    }
+    defer file.Close()
+    reader, err := gzip.NewReader(file)
+    if err != nil {
+        return 0, err
+    }
+    defer reader.Close()
+
+    scanner := bufio.NewScanner(reader)
+    // documents are at most 16MB
+    scanner.Buffer(make([]byte, 0, 1024*1024), 64*1024*1024)
+    var count int64
+    batch := make([]interface{}, 0, snapshotBatchSize)
+    insert := func() error {
+        if len(batch) == 0 {
+            return nil
+        }
+        _, err := coll.InsertMany(context.TODO(), batch)
+        count += int64(len(batch))
+        batch = batch[:0]
+        return err
+    }
+    for scanner.Scan() {
+        var doc bson.D
+        if err := bson.UnmarshalExtJSON(scanner.Bytes(), true, &doc); err != nil {
+            return count, err
+        }
+        batch = append(batch, doc)
+        if len(batch) == snapshotBatchSize {
+            if err := insert(); err != nil {
+                return count, err
+            }
+        }
+    }
+    if err := scanner.Err(); err != nil {
+        return count, err
+    }
+    return count, insert()
+}
@@ -0,0 +1,1631 @@
+package database
+
+import (
+    "context"
+    "database/sql"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "log"
+    "sync"
+    "sync/atomic"
+    "time"
+
+    sTypes "github.com/spacemeshos/go-spacemesh/common/types"
+    "github.com/spacemeshos/go-spacemesh/nats"
+    "github.com/swarmbit/spacemesh-state-api/config"
+    "github.com/swarmbit/spacemesh-state-api/metrics"
+    "github.com/swarmbit/spacemesh-state-api/pkg/apperror"
+    "github.com/swarmbit/spacemesh-state-api/pkg/transactionparser"
+    transactionparsertypes "github.com/swarmbit/spacemesh-state-api/pkg/transactionparser/transaction"
+    "github.com/swarmbit/spacemesh-state-api/types"
+)
+
+// sqlDialect holds what differs between the sql backends, the queries are otherwise
+// shared and written for postgres.
+type sqlDialect struct {
+    name string
+    // rebind rewrites the $1, $2... placeholders
+    rebind func(query string) string
+    // shareLock is appended to selects of rows that must not change until the commit
+    shareLock string
+    // unixMillis converts a timestamp column to unix milliseconds
+    unixMillis func(column string) string
+    // tableRows returns the query counting the rows of a table for the stats
+    tableRows func(table string) (string, []interface{})
+    // existingColumn reports if a schema error is a column that was already added, nil
+    // when the dialect adds columns only if they are missing
+    existingColumn func(err error) bool
+    capabilities   Capabilities
+}
+
+// SqlDB stores the same data as the mongo backend in relational tables so it can be
+// queried with plain SQL. It implements both WriteStore and ReadStore and is created
+// by NewPostgresDB or NewSqliteDB.
+type SqlDB struct {
+    db             *sqlConn
+    dialect        *sqlDialect
+    schema         []string
+    fence          atomic.Pointer[types.FenceDoc]
+    fenced         atomic.Bool
+    statsRetention time.Duration
+    closeOnce      sync.Once
+    rollbacks      rollbackTracker
+    activityReady  atomic.Bool
+}
+
+var (
+    _ WriteStore = (*SqlDB)(nil)
+    _ ReadStore  = (*SqlDB)(nil)
+)
+
+// sqlTables maps the mongo collection names used in stats to their tables.
+var sqlTables = map[string]string{
+    rewardsCollection:            "rewards",
+    layersCollection:             "layers",
+    atxsCollection:               "atxs",
+    nodesCollection:              "nodes",
+    accountsCollection:           "accounts",
+    transactionsCollection:       "transactions",
+    smeshersCollection:           "smeshers",
+    blocksCollection:             "blocks",
+    networkInfoHistoryCollection: "network_info_history",
+}
+
+// sqlConn and sqlTx rebind the placeholders of every query they run.
+type sqlConn struct {
+    *sql.DB
+    rebind func(query string) string
+    // queryTimeout bounds Query and QueryRow, unbounded when 0
+    queryTimeout time.Duration
+}
+
+type sqlTx struct {
+    *sql.Tx
+    rebind func(query string) string
+}
+
+func (c *sqlConn) Exec(query string, args ...interface{}) (sql.Result, error) {
+    return c.DB.Exec(c.rebind(query), args...)
+}
+
+func (c *sqlConn) Query(query string, args ...interface{}) (*sql.Rows, error) {
+    return c.DB.QueryContext(c.queryContext(), c.rebind(query), args...)
+}
+
+func (c *sqlConn) QueryRow(query string, args ...interface{}) *sql.Row {
+    return c.DB.QueryRowContext(c.queryContext(), c.rebind(query), args...)
+}
+
+// queryContext ends once the query timeout passed. It is released by a timer rather than
+// by the caller so the rows can still be read after Query returned.
+func (c *sqlConn) queryContext() context.Context {
+    if c.queryTimeout <= 0 {
+        return context.Background()
+    }
+    ctx, cancel := context.WithTimeout(context.Background(), c.queryTimeout)
+    time.AfterFunc(c.queryTimeout, cancel)
+    return ctx
+}
+
+func (c *sqlConn) Begin() (*sqlTx, error) {
+    tx, err := c.DB.Begin()
+    if err != nil {
+        return nil, err
+    }
+    return &sqlTx{Tx: tx, rebind: c.rebind}, nil
+}
+
+func (t *sqlTx) Exec(query string, args ...interface{}) (sql.Result, error) {
+    return t.Tx.Exec(t.rebind(query), args...)
+}
+
+func (t *sqlTx) QueryRow(query string, args ...interface{}) *sql.Row {
+    return t.Tx.QueryRow(t.rebind(query), args...)
+}
+
+func newSqlDB(db *sql.DB, dialect *sqlDialect, schema []string) (*SqlDB, error) {
+    if err := db.Ping(); err != nil {
+        return nil, err
+    }
+    if err := applySchema(db, dialect, schema); err != nil {
+        return nil, err
+    }
+    sqlDB := &SqlDB{
+        db:      &sqlConn{DB: db, rebind: dialect.rebind},
+        dialect: dialect,
+        schema:  schema,
+    }
+    if err := sqlDB.backfillCoinbaseRewardsTotals(); err != nil {
+        return nil, err
+    }
+    log.Printf("Created %s db", dialect.name)
+    return sqlDB, nil
+}
+
+// sqlTime binds times in UTC, sqlite stores them as text and compares them as strings.
+func sqlTime(t time.Time) time.Time {
+    return t.UTC()
+}
+
+func (s *SqlDB) Capabilities() Capabilities {
+    return s.dialect.capabilities
+}
+
+// insertOrUpdate runs insert and falls back to update when the row exists, it reports
+// if the row is new so counters are only updated once.
+func insertOrUpdate(tx *sqlTx, insert string, update string, args ...interface{}) (bool, error) {
+    result, err := tx.Exec(insert, args...)
+    if err != nil {
+        return false, err
+    }
+    inserted, err := result.RowsAffected()
+    if err != nil || inserted > 0 {
+        return inserted > 0, err
+    }
+    _, err = tx.Exec(update, args...)
+    return false, err
+}
+
+// withTx runs fn in a transaction, committed when fn returns no error. The transaction
+// fails with ErrFenced once a newer instance holds the sink fence, the fence row is
+// locked so a takeover waits for the commit.
+func (s *SqlDB) withTx(fn func(tx *sqlTx) error) error {
+    return s.inTx(func(tx *sqlTx) error {
+        if err := s.verifyFence(tx); err != nil {
+            return err
+        }
+        return fn(tx)
+    })
+}
+
+func (s *SqlDB) verifyFence(tx *sqlTx) error {
+    fence := s.fence.Load()
+    if fence == nil {
+        return nil
+    }
+    var generation int64
+    err := tx.QueryRow(`SELECT generation FROM fences WHERE id = $1`+s.dialect.shareLock, sinkFence).Scan(&generation)
+    if err != nil && !errors.Is(err, sql.ErrNoRows) {
+        return err
+    }
+    if generation != fence.Generation {
+        log.Printf("Sink fence generation %d taken over, stop writing", fence.Generation)
+        s.fenced.Store(true)
+        return ErrFenced
+    }
+    return nil
+}
+
+// inTx runs fn in a transaction, committed when fn returns no error.
+func (s *SqlDB) inTx(fn func(tx *sqlTx) error) error {
+    tx, err := s.db.Begin()
+    if err != nil {
+        return err
+    }
+    if err = fn(tx); err != nil {
+        tx.Rollback()
+        return err
+    }
+    return tx.Commit()
+}
+
+func (s *SqlDB) SaveLayer(_ context.Context, layer *nats.LayerUpdate) error {
+    return s.saveLayer(layer, true)
+}
+
+// SaveReplayedLayer saves a layer read again by a replay, resync or refetch, applying
+// it again is not a rollback.
+func (s *SqlDB) SaveReplayedLayer(_ context.Context, layer *nats.LayerUpdate) error {
+    return s.saveLayer(layer, false)
+}
+
+func (s *SqlDB) saveLayer(layer *nats.LayerUpdate, checkRollback bool) error {
+    if s.Fenced() {
+        return ErrFenced
+    }
+    // only store processed layers
+    if layer.Status == 0 {
+        return nil
+    }
+    if checkRollback && layer.Status == LayerStatusApplied && s.rollbacks.applied(layer.LayerID) {
+        if err := s.detectRollback(layer.LayerID); err != nil {
+            log.Printf("Failed to check layer %d for rollback: %v", layer.LayerID, err)
+        }
+    }
+    var instance sql.NullString
+    var generation sql.NullInt64
+    fence := s.fence.Load()
+    if fence != nil {
+        instance = sql.NullString{String: fence.InstanceId, Valid: true}
+        generation = sql.NullInt64{Int64: fence.Generation, Valid: true}
+    }
+    _, err := s.db.Exec(
+        `INSERT INTO layers (id, status, writer_instance, writer_generation) VALUES ($1, $2, $3, $4)
+        ON CONFLICT (id) DO UPDATE SET status = EXCLUDED.status,
+            writer_instance = EXCLUDED.writer_instance, writer_generation = EXCLUDED.writer_generation`,
+        layer.LayerID, layer.Status, instance, generation,
+    )
+    return err
+}
+
+func (s *SqlDB) detectRollback(layer uint32) error {
+    var status int
+    err := s.db.QueryRow(`SELECT status FROM layers WHERE id = $1`, layer).Scan(&status)
+    if err == sql.ErrNoRows || (err == nil && status != LayerStatusApplied) {
+        return nil
+    }
+    if err != nil {
+        return err
+    }
+
+    var last int64
+    err = s.db.QueryRow(`SELECT MAX(id) FROM layers WHERE status = $1`, LayerStatusApplied).Scan(&last)
+    if err != nil {
+        return err
+    }
+    if uint32(last) <= layer {
+        return nil
+    }
+
+    lastReorg := &types.ReorgDoc{}
+    err = s.db.QueryRow(
+        `SELECT trigger_layer, last_applied_layer FROM reorgs ORDER BY timestamp DESC LIMIT 1`,
+    ).Scan(&lastReorg.TriggerLayer, &lastReorg.LastAppliedLayer)
+    if err == sql.ErrNoRows {
+        lastReorg = nil
+    } else if err != nil {
+        return err
+    }
+    if sameRollback(lastReorg, layer, uint32(last)) {
+        return nil
+    }
+
+    var affected int64
+    err = s.db.QueryRow(
+        `SELECT (SELECT COUNT(*) FROM rewards WHERE layer >= $1) + (SELECT COUNT(*) FROM transactions WHERE layer >= $1)`,
+        layer,
+    ).Scan(&affected)
+    if err != nil {
+        return err
+    }
+
+    reorg := &types.ReorgDoc{
+        TriggerLayer:      layer,
+        LastAppliedLayer:  uint32(last),
+        Depth:             uint32(last) - layer,
+        AffectedDocuments: affected,
+        Timestamp:         time.Now().Unix(),
+    }
+    _, err = s.db.Exec(
+        `INSERT INTO reorgs (trigger_layer, last_applied_layer, depth, affected_documents, timestamp) VALUES ($1, $2, $3, $4, $5)`,
+        reorg.TriggerLayer, reorg.LastAppliedLayer, reorg.Depth, reorg.AffectedDocuments, reorg.Timestamp,
+    )
+    if err != nil {
+        return err
+    }
+
+    metrics.ReorgsTotal.Inc()
+    metrics.ReorgDepth.Observe(float64(reorg.Depth))
+    metrics.ReorgAffectedDocuments.Add(float64(reorg.AffectedDocuments))
+    log.Printf("Detected rollback to layer %d from layer %d", layer, last)
+    return nil
+}
+
+func (s *SqlDB) SaveAtx(_ context.Context, atx *nats.Atx) error {
+    if s.Fenced() {
+        return ErrFenced
+    }
+    weight := ATXWeight(atx.TickCount, uint64(atx.EffectiveNumUnits))
+    err := s.withTx(func(tx *sqlTx) error {
+        args := []interface{}{atx.AtxID, atx.NodeID, atx.Coinbase, atx.PublishEpoch, atx.EffectiveNumUnits,
+            atx.BaseTick, weight, atx.TickCount, atx.Sequence, atx.Received}
+        inserted, err := insertOrUpdate(tx,
+            `INSERT INTO atxs (`+atxColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) ON CONFLICT (id) DO NOTHING`,
+            `UPDATE atxs SET node_id = $2, coinbase = $3, publish_epoch = $4, effective_num_units = $5,
+                base_tick = $6, weight = $7, tick_count = $8, sequence = $9, received = $10 WHERE id = $1`,
+            args...)
+        if err != nil || !inserted {
+            return err
+        }
+
+        // only update counts if inserted new ATX
+        _, err = tx.Exec(
+            `INSERT INTO atxs_epochs (epoch, total_effective_num_units, total_weight, total_atx) VALUES ($1, $2, $3, 1)
+            ON CONFLICT (epoch) DO UPDATE SET
+                total_effective_num_units = atxs_epochs.total_effective_num_units + EXCLUDED.total_effective_num_units,
+                total_weight = atxs_epochs.total_weight + EXCLUDED.total_weight,
+                total_atx = atxs_epochs.total_atx + 1`,
+            atx.PublishEpoch, atx.EffectiveNumUnits, weight,
+        )
+        if err != nil {
+            return err
+        }
+
+        // equal heights keep the lowest id, atxs of malfeasant nodes are skipped
+        _, err = tx.Exec(
+            `UPDATE atxs_epochs SET highest_atx = $2, highest_tick = $3, highest_node = $4
+            WHERE epoch = $1 AND (highest_atx = '' OR highest_tick < $3 OR (highest_tick = $3 AND highest_atx > $2))
+            AND NOT EXISTS (SELECT 1 FROM nodes WHERE id = $4 AND malfeasance_received IS NOT NULL)`,
+            atx.PublishEpoch, atx.AtxID, atx.BaseTick+atx.TickCount, atx.NodeID,
+        )
+        if err != nil {
+            return err
+        }
+
+        _, err = tx.Exec(
+            `INSERT INTO account_atxs_epochs (coinbase, publish_epoch, total_effective_num_units, total_weight, total_atx) VALUES ($1, $2, $3, $4, 1)
+            ON CONFLICT (coinbase, publish_epoch) DO UPDATE SET
+                total_effective_num_units = account_atxs_epochs.total_effective_num_units + EXCLUDED.total_effective_num_units,
+                total_weight = account_atxs_epochs.total_weight + EXCLUDED.total_weight,
+                total_atx = account_atxs_epochs.total_atx + 1`,
+            atx.Coinbase, atx.PublishEpoch, atx.EffectiveNumUnits, weight,
+        )
+        if err != nil {
+            return err
+        }
+
+        _, err = tx.Exec(
+            `INSERT INTO nodes (id, has_atx) VALUES ($1, TRUE) ON CONFLICT (id) DO UPDATE SET has_atx = TRUE`,
+            atx.NodeID,
+        )
+        if err != nil {
+            return err
+        }
+
+        _, err = tx.Exec(`INSERT INTO accounts (address) VALUES ($1) ON CONFLICT (address) DO NOTHING`, atx.Coinbase)
+        return err
+    })
+    if err != nil {
+        log.Printf("Atx transaction failed: %v", err)
+    }
+    return err
+}
+
+func (s *SqlDB) SaveMalfeasance(_ context.Context, malfeasance *nats.Malfeasance) error {
+    if s.Fenced() {
+        return ErrFenced
+    }
+    _, err := s.db.Exec(
+        `INSERT INTO nodes (id, malfeasance_received, malfeasance_layer) VALUES ($1, $2, $3)
+        ON CONFLICT (id) DO UPDATE SET malfeasance_received = EXCLUDED.malfeasance_received,
+            malfeasance_layer = EXCLUDED.malfeasance_layer`,
+        malfeasance.NodeID, malfeasance.Received, malfeasance.LayerID,
+    )
+    if err != nil {
+        return err
+    }
+    // the node can not be the highest atx of an epoch anymore
+    return s.recomputeHighestAtxs(malfeasance.NodeID)
+}
+
+// recomputeHighestAtxs finds the highest atx again for the epochs whose highest atx was
+// published by nodeId.
+func (s *SqlDB) recomputeHighestAtxs(nodeId string) error {
+    epochs, err := queryAll(s.db, func(row scanner) (*uint64, error) {
+        var epoch uint64
+        err := row.Scan(&epoch)
+        return &epoch, err
+    }, `SELECT epoch FROM atxs_epochs WHERE highest_node = $1`, nodeId)
+    if err != nil || len(epochs) == 0 {
+        return err
+    }
+
+    malfeasanceNodes, err := s.GetMalfeasanceNodes()
+    if err != nil {
+        return err
+    }
+    excludedNodes := make([]string, len(malfeasanceNodes))
+    for i, v := range malfeasanceNodes {
+        excludedNodes[i] = v.ID
+    }
+    for _, epoch := range epochs {
+        atx, err := s.GetHighestAtx(*epoch, excludedNodes)
+        if err != nil {
+            return err
+        }
+        _, err = s.db.Exec(
+            `UPDATE atxs_epochs SET highest_atx = $2, highest_tick = $3, highest_node = $4 WHERE epoch = $1`,
+            *epoch, atx.AtxID, atx.BaseTick+atx.TickCount, atx.NodeID,
+        )
+        if err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+func (s *SqlDB) SaveTransactions(_ context.Context, transaction *nats.Transaction, result bool) error {
+    if s.Fenced() {
+        return ErrFenced
+    }
+    if !result {
+        transactionDoc := pendingTransactionDoc(transaction)
+        _, err := s.db.Exec(
+            `INSERT INTO transactions (`+transactionColumns+`)
+            VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, FALSE, '', '', $14, $15)
+            ON CONFLICT (id) DO NOTHING`,
+            transactionDoc.ID, transactionDoc.Status, transactionDoc.PrincipaAccount, transactionDoc.ReceiverAccount,
+            transactionDoc.VaultAccount, transactionDoc.Fee, transactionDoc.Gas, transactionDoc.GasPrice,
+            transactionDoc.Amount, transactionDoc.Layer, transactionDoc.Counter, transactionDoc.Method, transactionDoc.Type,
+            transactionDoc.CreatedAt, transactionDoc.Raw,
+        )
+        if err != nil {
+            log.Printf("Transaction failed: %v", err)
+        }
+        return err
+    }
+
+    transactionData, err := transactionparser.Parse(transaction.Raw)
+    if err != nil {
+        // the raw transaction fails the same way on every delivery
+        return apperror.Wrap(apperror.InvalidInput, "invalid transaction", err)
+    }
+    receiver := transactionData.Tx.GetReceiver()
+    receiverString := ""
+    if len(receiver.Bytes()) > 0 {
+        receiverString = receiver.String()
+    }
+    vaultString := ""
+    if transactionData.Type == transactionparsertypes.TypeDrainVault {
+        vaultString = transactionData.Vault.GetVault().String()
+    }
+
+    transactionDoc := &types.TransactionDoc{
+        ID:              transaction.ID,
+        PrincipaAccount: transaction.Header.Principal,
+        ReceiverAccount: receiverString,
+        VaultAccount:    vaultString,
+        Fee:             transaction.Header.Fee,
+        Gas:             transaction.Header.Gas,
+        Layer:           transaction.Header.LayerID,
+        Status:          transaction.Header.Status,
+        Method:          transaction.Header.Method,
+        Type:            transactionData.Tx.GetType(),
+        Amount:          transactionData.Tx.GetAmount(),
+        Counter:         transactionData.Tx.GetCounter(),
+        GasPrice:        transactionData.Tx.GetGasPrice(),
+        Complete:        true,
+        Message:         transaction.Header.Message,
+        BlockID:         transaction.Header.BlockID,
+        Raw:             transaction.Raw,
+    }
+
+    err = s.withTx(func(tx *sqlTx) error {
+        // the upsert skips a row that is already complete, the result is then a duplicate.
+        // A concurrent save of the same result waits on the conflicting row and skips it
+        // once this one commits.
+        result, err := tx.Exec(
+            `INSERT INTO transactions (`+transactionColumns+`)
+            VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, TRUE, $14, $15, 0, $16)
+            ON CONFLICT (id) DO UPDATE SET status = EXCLUDED.status, principal_account = EXCLUDED.principal_account,
+                receiver_account = EXCLUDED.receiver_account, vault_account = EXCLUDED.vault_account,
+                fee = EXCLUDED.fee, gas = EXCLUDED.gas, gas_price = EXCLUDED.gas_price, amount = EXCLUDED.amount,
+                layer = EXCLUDED.layer, counter = EXCLUDED.counter, method = EXCLUDED.method,
+                type = EXCLUDED.type, complete = TRUE, message = EXCLUDED.message,
+                block_id = EXCLUDED.block_id, raw = EXCLUDED.raw
+            WHERE transactions.complete = FALSE`,
+            transactionDoc.ID, transactionDoc.Status, transactionDoc.PrincipaAccount, transactionDoc.ReceiverAccount,
+            transactionDoc.VaultAccount, transactionDoc.Fee, transactionDoc.Gas, transactionDoc.GasPrice,
+            transactionDoc.Amount, transactionDoc.Layer, transactionDoc.Counter, transactionDoc.Method, transactionDoc.Type,
+            transactionDoc.Message, transactionDoc.BlockID, transactionDoc.Raw,
+        )
+        if err != nil {
+            return err
+        }
+        completed, err := result.RowsAffected()
+        if err != nil {
+            return err
+        }
+        updateBalances := completed > 0
+
+        // a duplicate result was already counted in its block
+        if updateBalances && transactionDoc.BlockID != "" {
+            _, err = tx.Exec(
+                `INSERT INTO blocks (id, layer, transactions) VALUES ($1, $2, 1)
+                ON CONFLICT (id) DO UPDATE SET layer = EXCLUDED.layer, transactions = blocks.transactions + 1`,
+                transactionDoc.BlockID, int64(transactionDoc.Layer),
+            )
+            if err != nil {
+                return err
+            }
+        }
+
+        if vaultDoc := spawnedVaultDoc(transaction, transactionData); vaultDoc != nil {
+            _, err = tx.Exec(
+                `INSERT INTO vaults (address, owner, total_amount, initial_unlock_amount, vesting_start, vesting_end, spawn_transaction, spawn_layer)
+                VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+                ON CONFLICT (address) DO UPDATE SET owner = EXCLUDED.owner, total_amount = EXCLUDED.total_amount,
+                    initial_unlock_amount = EXCLUDED.initial_unlock_amount, vesting_start = EXCLUDED.vesting_start,
+                    vesting_end = EXCLUDED.vesting_end, spawn_transaction = EXCLUDED.spawn_transaction,
+                    spawn_layer = EXCLUDED.spawn_layer`,
+                vaultDoc.Address, vaultDoc.Owner, vaultDoc.TotalAmount, vaultDoc.InitialUnlockAmount,
+                vaultDoc.VestingStart, vaultDoc.VestingEnd, vaultDoc.SpawnTransaction, vaultDoc.SpawnLayer,
+            )
+            if err != nil {
+                return err
+            }
+        }
+        if multisigDoc := spawnedMultisigDoc(transaction, transactionData); multisigDoc != nil {
+            publicKeys, err := json.Marshal(multisigDoc.PublicKeys)
+            if err != nil {
+                return err
+            }
+            _, err = tx.Exec(
+                `INSERT INTO multisigs (address, template, required, public_keys, spawn_transaction, spawn_layer)
+                VALUES ($1, $2, $3, $4, $5, $6)
+                ON CONFLICT (address) DO UPDATE SET template = EXCLUDED.template, required = EXCLUDED.required,
+                    public_keys = EXCLUDED.public_keys, spawn_transaction = EXCLUDED.spawn_transaction,
+                    spawn_layer = EXCLUDED.spawn_layer`,
+                multisigDoc.Address, multisigDoc.Template, multisigDoc.Required, string(publicKeys),
+                multisigDoc.SpawnTransaction, multisigDoc.SpawnLayer,
+            )
+            if err != nil {
+                return err
+            }
+        }
+        if signaturesDoc := multisigSignaturesDoc(transaction, transactionData); signaturesDoc != nil {
+            refs, err := json.Marshal(signaturesDoc.Refs)
+            if err != nil {
+                return err
+            }
+            _, err = tx.Exec(
+                `INSERT INTO multisig_signatures (transaction_id, account, layer, status, type, refs)
+                VALUES ($1, $2, $3, $4, $5, $6)
+                ON CONFLICT (transaction_id) DO UPDATE SET account = EXCLUDED.account, layer = EXCLUDED.layer,
+                    status = EXCLUDED.status, type = EXCLUDED.type, refs = EXCLUDED.refs`,
+                signaturesDoc.Transaction, signaturesDoc.Account, signaturesDoc.Layer, signaturesDoc.Status,
+                signaturesDoc.Type, string(refs),
+            )
+            if err != nil {
+                return err
+            }
+        }
+
+        // if transaction not sucessfull or addressess length less than 2 it means is an ineffective transaction
+        if transaction.Header.Status != uint8(sTypes.TransactionSuccess) || len(transaction.Header.Addresses) < 2 {
+            updateBalances = false
+        }
+        if !updateBalances {
+            return nil
+        }
+
+        if transactionDoc.Amount > 0 {
+            _, err = tx.Exec(
+                `INSERT INTO accounts (address, balance, received, incoming_transfers, first_seen_layer, last_activity_layer)
+                VALUES ($1, $2, $2, 1, $3, $3)
+                ON CONFLICT (address) DO UPDATE SET balance = accounts.balance + EXCLUDED.balance,
+                    received = accounts.received + EXCLUDED.received,
+                    incoming_transfers = accounts.incoming_transfers + 1, `+sqlAccountActivity,
+                transactionDoc.ReceiverAccount, transactionDoc.Amount, transactionDoc.Layer,
+            )
+            if err != nil {
+                return err
+            }
+            if err = incBalanceChange(tx, transactionDoc.ReceiverAccount, transactionDoc.Layer, int64(transactionDoc.Amount)); err != nil {
+                return err
+            }
+        }
+
+        senderAccount := transactionDoc.PrincipaAccount
+        // if drain vault transaction deduct fees and balance from vault account
+        if transactionData.Type == transactionparsertypes.TypeDrainVault {
+            senderAccount = transactionDoc.VaultAccount
+        }
+        fee := transactionDoc.Gas * transactionDoc.GasPrice
+        _, err = tx.Exec(
+            `INSERT INTO accounts (address, balance, sent, fees, outgoing_transfers, first_seen_layer, last_activity_layer)
+            VALUES ($1, $2, $3, $4, 1, $5, $5)
+            ON CONFLICT (address) DO UPDATE SET balance = accounts.balance + EXCLUDED.balance,
+                sent = accounts.sent + EXCLUDED.sent, fees = accounts.fees + EXCLUDED.fees,
+                outgoing_transfers = accounts.outgoing_transfers + 1, `+sqlAccountActivity,
+            senderAccount, (int64(transactionDoc.Amount)+int64(fee))*-1, transactionDoc.Amount, fee, transactionDoc.Layer,
+        )
+        if err != nil {
+            return err
+        }
+        if err = incBalanceChange(tx, senderAccount, transactionDoc.Layer, (int64(transactionDoc.Amount)+int64(fee))*-1); err != nil {
+            return err
+        }
+
+        _, err = tx.Exec(
+            `INSERT INTO network_info (id, fees_paid) VALUES ('info', $1)
+            ON CONFLICT (id) DO UPDATE SET fees_paid = network_info.fees_paid + EXCLUDED.fees_paid`,
+            fee,
+        )
+        return err
+    })
+    if err != nil {
+        log.Printf("Transaction failed: %v", err)
+    }
+    return err
+}
+
+// sqlAccountActivity keeps the earliest layer of an account upsert as first seen and the
+// latest as last activity, 0 is an account not seen yet.
+const sqlAccountActivity = `first_seen_layer = CASE WHEN accounts.first_seen_layer = 0 OR EXCLUDED.first_seen_layer < accounts.first_seen_layer
+        THEN EXCLUDED.first_seen_layer ELSE accounts.first_seen_layer END,
+    last_activity_layer = CASE WHEN EXCLUDED.last_activity_layer > accounts.last_activity_layer
+        THEN EXCLUDED.last_activity_layer ELSE accounts.last_activity_layer END`
+
+// accountActivityBackfill computes the account activity kept by sqlAccountActivity and
+// the transfer counts from the stored rewards and successful transfers, $1 is the
+// success status. A transfer has a receiver, the sender of a vault drain is the vault.
+var accountActivityBackfill = []string{
+    `UPDATE accounts SET first_seen_layer = activity.first_layer, last_activity_layer = activity.last_layer
+    FROM (
+        SELECT address, MIN(layer) AS first_layer, MAX(layer) AS last_layer FROM (
+            SELECT coinbase AS address, layer FROM rewards
+            UNION ALL
+            SELECT receiver_account, layer FROM transactions
+            WHERE complete = TRUE AND status = $1 AND receiver_account <> '' AND amount > 0
+            UNION ALL
+            SELECT CASE WHEN vault_account <> '' THEN vault_account ELSE principal_account END, layer FROM transactions
+            WHERE complete = TRUE AND status = $1 AND receiver_account <> ''
+        ) AS events GROUP BY address
+    ) AS activity
+    WHERE accounts.address = activity.address`,
+    `UPDATE accounts SET incoming_transfers = transfers.count
+    FROM (
+        SELECT receiver_account AS address, COUNT(*) AS count FROM transactions
+        WHERE complete = TRUE AND status = $1 AND receiver_account <> '' AND amount > 0
+        GROUP BY receiver_account
+    ) AS transfers
+    WHERE accounts.address = transfers.address`,
+    `UPDATE accounts SET outgoing_transfers = transfers.count
+    FROM (
+        SELECT CASE WHEN vault_account <> '' THEN vault_account ELSE principal_account END AS address, COUNT(*) AS count
+        FROM transactions
+        WHERE complete = TRUE AND status = $1 AND receiver_account <> ''
+        GROUP BY 1
+    ) AS transfers
+    WHERE accounts.address = transfers.address`,
+}
+
+// accountActivityBackfillId records the backfill in the backfills table.
+const accountActivityBackfillId = "account_activity"
+
+// backfillAccountActivity computes the activity of the accounts saved before the sink
+// maintained it, once per database in the transaction that records it.
+func (s *SqlDB) backfillAccountActivity() error {
+    return s.withTx(func(tx *sqlTx) error {
+        result, err := tx.Exec(
+            `INSERT INTO backfills (id, applied_at) VALUES ($1, $2) ON CONFLICT (id) DO NOTHING`,
+            accountActivityBackfillId, time.Now().Unix(),
+        )
+        if err != nil {
+            return err
+        }
+        if inserted, err := result.RowsAffected(); err != nil || inserted == 0 {
+            return err
+        }
+        log.Println("Computing the activity of the accounts")
+        _, err = tx.Exec(`UPDATE accounts SET first_seen_layer = 0, last_activity_layer = 0, incoming_transfers = 0, outgoing_transfers = 0`)
+        if err != nil {
+            return err
+        }
+        for _, statement := range accountActivityBackfill {
+            if _, err = tx.Exec(statement, uint8(sTypes.TransactionSuccess)); err != nil {
+                return err
+            }
+        }
+        return nil
+    })
+}
+
+func (s *SqlDB) SaveReward(_ context.Context, reward *nats.Reward) error {
+    if s.Fenced() {
+        return ErrFenced
+    }
+    err := s.withTx(func(tx *sqlTx) error {
+        inserted, err := insertOrUpdate(tx,
+            `INSERT INTO rewards (`+rewardColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7) ON CONFLICT (id) DO NOTHING`,
+            `UPDATE rewards SET node_id = $2, coinbase = $3, atx_id = $4, layer_reward = $5,
+                total_reward = $6, layer = $7 WHERE id = $1`,
+            reward.ID, reward.NodeID, reward.Coinbase, reward.AtxID, reward.LayerReward, reward.Total, reward.Layer)
+        if err != nil || !inserted {
+            return err
+        }
+
+        // only update counts if inserted new reward
+        _, err = tx.Exec(
+            `INSERT INTO accounts (address, balance, total_rewards, first_seen_layer, last_activity_layer) VALUES ($1, $2, $2, $3, $3)
+            ON CONFLICT (address) DO UPDATE SET balance = accounts.balance + EXCLUDED.balance,
+                total_rewards = accounts.total_rewards + EXCLUDED.total_rewards, `+sqlAccountActivity,
+            reward.Coinbase, reward.Total, reward.Layer,
+        )
+        if err != nil {
+            return err
+        }
+        if err = incBalanceChange(tx, reward.Coinbase, reward.Layer, int64(reward.Total)); err != nil {
+            return err
+        }
+        if err = incCoinbaseRewardsTotals(tx, reward.Coinbase, reward.Layer, int64(reward.Total)); err != nil {
+            return err
+        }
+
+        _, err = tx.Exec(
+            `INSERT INTO network_info (id, circulating_supply, issued_subsidy) VALUES ('info', $1, $2)
+            ON CONFLICT (id) DO UPDATE SET circulating_supply = network_info.circulating_supply + EXCLUDED.circulating_supply,
+                issued_subsidy = network_info.issued_subsidy + EXCLUDED.issued_subsidy`,
+            reward.Total, reward.LayerReward,
+        )
+        return err
+    })
+    if err != nil {
+        log.Printf("Rewards transaction failed: %v", err)
+    }
+    return err
+}
+
+// ImportGenesisLedger adds the genesis balances at layer 0 in the transaction that marks
+// the import, a second start does not add them again.
+func (s *SqlDB) ImportGenesisLedger(accounts []*types.AccountDoc) (bool, error) {
+    imported := false
+    err := s.withTx(func(tx *sqlTx) error {
+        result, err := tx.Exec(
+            `INSERT INTO genesis_imports (id, accounts, imported_at) VALUES ('genesis', $1, $2) ON CONFLICT (id) DO NOTHING`,
+            len(accounts), time.Now().Unix(),
+        )
+        if err != nil {
+            return err
+        }
+        if rows, err := result.RowsAffected(); err != nil || rows == 0 {
+            return err
+        }
+        for _, account := range accounts {
+            _, err = tx.Exec(
+                `INSERT INTO accounts (address, balance) VALUES ($1, $2)
+                ON CONFLICT (address) DO UPDATE SET balance = accounts.balance + EXCLUDED.balance`,
+                account.Address, account.Balance,
+            )
+            if err != nil {
+                return err
+            }
+            if err = incBalanceChange(tx, account.Address, 0, int64(account.Balance)); err != nil {
+                return err
+            }
+        }
+        imported = true
+        return nil
+    })
+    return imported, err
+}
+
+func (s *SqlDB) ImportCheckpointAccounts(accounts []*types.AccountDoc, layer uint32) error {
+    return s.withTx(func(tx *sqlTx) error {
+        for _, account := range accounts {
+            var balance int64
+            err := tx.QueryRow(`SELECT balance FROM accounts WHERE address = $1`, account.Address).Scan(&balance)
+            if err != nil && err != sql.ErrNoRows {
+                return err
+            }
+            _, err = tx.Exec(
+                `INSERT INTO accounts (address, balance) VALUES ($1, $2)
+                ON CONFLICT (address) DO UPDATE SET balance = EXCLUDED.balance`,
+                account.Address, account.Balance,
+            )
+            if err != nil {
+                return err
+            }
+            if delta := int64(account.Balance) - balance; delta != 0 {
+                if err = incBalanceChange(tx, account.Address, layer, delta); err != nil {
+                    return err
+                }
+            }
+        }
+        return nil
+    })
+}
+
+// incBalanceChange adds delta to the balance change of account in layer.
+func incBalanceChange(tx *sqlTx, account string, layer uint32, delta int64) error {
+    _, err := tx.Exec(
+        `INSERT INTO balance_changes (account, layer, delta) VALUES ($1, $2, $3)
+        ON CONFLICT (account, layer) DO UPDATE SET delta = balance_changes.delta + EXCLUDED.delta`,
+        account, layer, delta,
+    )
+    return err
+}
+
+// incCoinbaseRewardsTotals adds a new reward to the epoch and all-time totals of its
+// coinbase.
+func incCoinbaseRewardsTotals(tx *sqlTx, coinbase string, layer uint32, total int64) error {
+    for _, epoch := range []int64{int64(layer / config.LayersPerEpoch), AllEpochs} {
+        _, err := tx.Exec(
+            `INSERT INTO coinbase_rewards_totals (coinbase, epoch, rewards, rewards_count) VALUES ($1, $2, $3, 1)
+            ON CONFLICT (coinbase, epoch) DO UPDATE SET rewards = coinbase_rewards_totals.rewards + EXCLUDED.rewards,
+                rewards_count = coinbase_rewards_totals.rewards_count + 1`,
+            coinbase, epoch, total,
+        )
+        if err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// backfillCoinbaseRewardsTotals sums the stored rewards into the coinbase totals while
+// the table is still empty, for databases written before the totals were kept.
+func (s *SqlDB) backfillCoinbaseRewardsTotals() error {
+    _, err := s.db.Exec(
+        `INSERT INTO coinbase_rewards_totals (coinbase, epoch, rewards, rewards_count)
+        SELECT coinbase, epoch, rewards, rewards_count FROM (
+            SELECT coinbase, layer / $1 AS epoch, SUM(total_reward) AS rewards, COUNT(*) AS rewards_count
+            FROM rewards GROUP BY 1, 2
+            UNION ALL
+            SELECT coinbase, $2 AS epoch, SUM(total_reward) AS rewards, COUNT(*) AS rewards_count
+            FROM rewards GROUP BY 1
+        ) totals WHERE NOT EXISTS (SELECT 1 FROM coinbase_rewards_totals)`,
+        int64(config.LayersPerEpoch), AllEpochs,
+    )
+    return err
+}
+
+func (s *SqlDB) SavePrice(price *types.PriceDoc) error {
+    if s.Fenced() {
+        return ErrFenced
+    }
+    _, err := s.db.Exec(
+        `INSERT INTO prices (timestamp, usd_price, source) VALUES ($1, $2, $3)`,
+        sqlTime(price.Timestamp), price.USDPrice, price.Source,
+    )
+    return err
+}
+
+func (s *SqlDB) SaveStats(stats *types.StatsDoc) error {
+    if s.Fenced() {
+        return ErrFenced
+    }
+    collections, err := json.Marshal(stats.Collections)
+    if err != nil {
+        return err
+    }
+    ingestRates, err := json.Marshal(stats.IngestRates)
+    if err != nil {
+        return err
+    }
+    _, err = s.db.Exec(
+        `INSERT INTO stats (timestamp, collections, ingest_rates, api_qps) VALUES ($1, $2, $3, $4)`,
+        sqlTime(stats.Timestamp), string(collections), string(ingestRates), stats.ApiQps,
+    )
+    if err != nil || s.statsRetention == 0 {
+        return err
+    }
+    // sql has no ttl indexes, old snapshots are dropped on every save
+    _, err = s.db.Exec(`DELETE FROM stats WHERE timestamp < $1`, sqlTime(time.Now().Add(-s.statsRetention)))
+    return err
+}
+
+func (s *SqlDB) SaveNetworkInfoHistory(info *types.NetworkInfoHistoryDoc) error {
+    if s.Fenced() {
+        return ErrFenced
+    }
+    _, err := s.db.Exec(
+        `INSERT INTO network_info_history (layer, epoch, created_at, total_weight, total_slots, effective_units, active_smeshers,
+            total_accounts, circulating_supply, price, next_epoch_weight, next_epoch_active_smeshers, next_epoch_effective_units)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+        ON CONFLICT (layer) DO UPDATE SET epoch = EXCLUDED.epoch, created_at = EXCLUDED.created_at,
+            total_weight = EXCLUDED.total_weight, total_slots = EXCLUDED.total_slots, effective_units = EXCLUDED.effective_units,
+            active_smeshers = EXCLUDED.active_smeshers, total_accounts = EXCLUDED.total_accounts,
+            circulating_supply = EXCLUDED.circulating_supply, price = EXCLUDED.price, next_epoch_weight = EXCLUDED.next_epoch_weight,
+            next_epoch_active_smeshers = EXCLUDED.next_epoch_active_smeshers, next_epoch_effective_units = EXCLUDED.next_epoch_effective_units`,
+        info.Layer, info.Epoch, sqlTime(info.CreatedAt), info.TotalWeight, info.TotalSlots, info.EffectiveUnitsCommited,
+        info.ActiveSmeshers, info.TotalAccounts, info.CirculatingSupply, info.Price, info.NextEpochWeight,
+        info.NextEpochActiveSmeshers, info.NextEpochEffectiveUnits,
+    )
+    return err
+}
+
+func (s *SqlDB) SaveNetworkSnapshot(snapshot *types.NetworkSnapshotDoc) error {
+    if s.Fenced() {
+        return ErrFenced
+    }
+    _, err := s.db.Exec(
+        `INSERT INTO network_history (timestamp, epoch, layer, total_weight, active_smeshers, total_accounts, circulating_supply, price)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+        sqlTime(snapshot.Timestamp), snapshot.Epoch, snapshot.Layer, snapshot.TotalWeight, snapshot.ActiveSmeshers,
+        snapshot.TotalAccounts, snapshot.CirculatingSupply, snapshot.Price,
+    )
+    return err
+}
+
+// SaveStreamCheckpoint never moves a checkpoint back, parallel consumers may save out
+// of order. The checkpoint records the fence generation of the writer and is not
+// updated anymore once a newer generation saved it.
+func (s *SqlDB) SaveStreamCheckpoint(checkpoint *types.StreamCheckpointDoc) error {
+    var instance sql.NullString
+    var generation sql.NullInt64
+    fence := s.fence.Load()
+    if fence != nil {
+        instance = sql.NullString{String: fence.InstanceId, Valid: true}
+        generation = sql.NullInt64{Int64: fence.Generation, Valid: true}
+    }
+    _, err := s.db.Exec(
+        `INSERT INTO stream_checkpoints (consumer, stream, sequence, layer, updated_at, writer_instance, writer_generation)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        ON CONFLICT (consumer) DO UPDATE SET sequence = EXCLUDED.sequence, layer = EXCLUDED.layer,
+            stream = EXCLUDED.stream, updated_at = EXCLUDED.updated_at,
+            writer_instance = EXCLUDED.writer_instance, writer_generation = EXCLUDED.writer_generation
+        WHERE EXCLUDED.sequence > stream_checkpoints.sequence
+            AND (stream_checkpoints.writer_generation IS NULL OR EXCLUDED.writer_generation IS NULL
+                OR stream_checkpoints.writer_generation <= EXCLUDED.writer_generation)`,
+        checkpoint.Consumer, checkpoint.Stream, checkpoint.Sequence, checkpoint.Layer, sqlTime(checkpoint.UpdatedAt),
+        instance, generation,
+    )
+    return err
+}
+
+func (s *SqlDB) SavePoetHealth(health *types.PoetHealthDoc) error {
+    if s.Fenced() {
+        return ErrFenced
+    }
+    _, err := s.db.Exec(
+        `INSERT INTO poet_health (name, address, up, current_round, error, since, checked_at) VALUES ($1, $2, $3, $4, $5, $6, $7)
+        ON CONFLICT (name) DO UPDATE SET address = EXCLUDED.address, up = EXCLUDED.up, current_round = EXCLUDED.current_round,
+            error = EXCLUDED.error, since = EXCLUDED.since, checked_at = EXCLUDED.checked_at`,
+        health.Name, health.Address, health.Up, health.CurrentRound, health.Error, sqlTime(health.Since), sqlTime(health.CheckedAt),
+    )
+    return err
+}
+
+func (s *SqlDB) SavePoolReward(reward *types.PoolRewardDoc) error {
+    if s.Fenced() {
+        return ErrFenced
+    }
+    _, err := s.db.Exec(
+        `INSERT INTO pool_rewards (id, node_id, coinbase, layer, epoch, total_reward) VALUES ($1, $2, $3, $4, $5, $6)
+        ON CONFLICT (id) DO UPDATE SET node_id = EXCLUDED.node_id, coinbase = EXCLUDED.coinbase, layer = EXCLUDED.layer,
+            epoch = EXCLUDED.epoch, total_reward = EXCLUDED.total_reward`,
+        reward.ID, reward.NodeId, reward.Coinbase, reward.Layer, reward.Epoch, reward.TotalReward,
+    )
+    return err
+}
+
+// BackfillPoolRewards copies the saved rewards of nodeIds to the pool rewards, the ones
+// already there are kept.
+func (s *SqlDB) BackfillPoolRewards(nodeIds []string) error {
+    if s.Fenced() {
+        return ErrFenced
+    }
+    if len(nodeIds) == 0 {
+        return nil
+    }
+    // $1 is the epoch length, the node ids follow it
+    filter := &sqlFilter{args: []interface{}{config.LayersPerEpoch}}
+    filter.in("node_id", nodeIds)
+    _, err := s.db.Exec(
+        `INSERT INTO pool_rewards (id, node_id, coinbase, layer, epoch, total_reward)
+        SELECT id, node_id, coinbase, layer, layer / $1, total_reward FROM rewards`+filter.where()+`
+        ON CONFLICT (id) DO NOTHING`,
+        filter.args...,
+    )
+    return err
+}
+
+func (s *SqlDB) SaveRewardDigests(digests []*types.RewardDigestDoc) error {
+    return s.withTx(func(tx *sqlTx) error {
+        for _, digest := range digests {
+            _, err := tx.Exec(
+                `INSERT INTO reward_digests (coinbase, day, count, rewards, usd_price, usd_value, complete) VALUES ($1, $2, $3, $4, $5, $6, $7)
+                ON CONFLICT (coinbase, day) DO UPDATE SET count = EXCLUDED.count, rewards = EXCLUDED.rewards,
+                    usd_price = EXCLUDED.usd_price, usd_value = EXCLUDED.usd_value, complete = EXCLUDED.complete`,
+                digest.Id.Coinbase, digest.Id.Day, digest.Count, digest.Rewards, digest.USDPrice, digest.USDValue, digest.Complete,
+            )
+            if err != nil {
+                return err
+            }
+        }
+        return nil
+    })
+}
+
+func (s *SqlDB) SaveWebhook(webhook *types.WebhookDoc) error {
+    addresses, err := json.Marshal(webhook.Addresses)
+    if err != nil {
+        return err
+    }
+    events, err := json.Marshal(webhook.Events)
+    if err != nil {
+        return err
+    }
+    _, err = s.db.Exec(
+        `INSERT INTO webhooks (id, owner, url, addresses, events, secret, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7)
+        ON CONFLICT (id) DO UPDATE SET owner = EXCLUDED.owner, url = EXCLUDED.url, addresses = EXCLUDED.addresses,
+            events = EXCLUDED.events, secret = EXCLUDED.secret, created_at = EXCLUDED.created_at`,
+        webhook.ID, webhook.Owner, webhook.Url, string(addresses), string(events), webhook.Secret, webhook.CreatedAt,
+    )
+    return err
+}
+
+func (s *SqlDB) DeleteWebhook(id string, owner string) (bool, error) {
+    deleted := false
+    err := s.withTx(func(tx *sqlTx) error {
+        result, err := tx.Exec(`DELETE FROM webhooks WHERE id = $1 AND owner = $2`, id, owner)
+        if err != nil {
+            return err
+        }
+        rows, err := result.RowsAffected()
+        if err != nil || rows == 0 {
+            return err
+        }
+        deleted = true
+        _, err = tx.Exec(`DELETE FROM webhook_deliveries WHERE webhook = $1`, id)
+        return err
+    })
+    return deleted, err
+}
+
+const webhookDeliveryColumns = "id, webhook, event, payload, status, attempts, next_attempt, last_status, last_error, created_at, delivered_at"
+
+func (s *SqlDB) QueueWebhookDelivery(delivery *types.WebhookDeliveryDoc) error {
+    if s.Fenced() {
+        return ErrFenced
+    }
+    _, err := s.db.Exec(
+        `INSERT INTO webhook_deliveries (`+webhookDeliveryColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+        ON CONFLICT (id) DO NOTHING`,
+        delivery.ID, delivery.Webhook, delivery.Event, delivery.Payload, delivery.Status, delivery.Attempts,
+        delivery.NextAttempt, delivery.LastStatus, delivery.LastError, delivery.CreatedAt, delivery.DeliveredAt,
+    )
+    return err
+}
+
+func (s *SqlDB) GetDueWebhookDeliveries(now int64, limit int64) ([]*types.WebhookDeliveryDoc, error) {
+    filter := &sqlFilter{}
+    filter.add("status = ?", types.WebhookDeliveryPending)
+    filter.add("next_attempt <= ?", now)
+    return queryAll(s.db, scanWebhookDelivery,
+        "SELECT "+webhookDeliveryColumns+" FROM webhook_deliveries"+filter.where()+" ORDER BY next_attempt"+filter.page(0, limit),
+        filter.args...)
+}
+
+func (s *SqlDB) ClaimWebhookDelivery(id string, attempts int, nextAttempt int64) (bool, error) {
+    if s.Fenced() {
+        return false, ErrFenced
+    }
+    result, err := s.db.Exec(
+        `UPDATE webhook_deliveries SET attempts = attempts + 1, next_attempt = $1
+        WHERE id = $2 AND status = $3 AND attempts = $4`,
+        nextAttempt, id, types.WebhookDeliveryPending, attempts,
+    )
+    if err != nil {
+        return false, err
+    }
+    claimed, err := result.RowsAffected()
+    if err != nil {
+        return false, err
+    }
+    return claimed == 1, nil
+}
+
+func (s *SqlDB) UpdateWebhookDelivery(delivery *types.WebhookDeliveryDoc) error {
+    if s.Fenced() {
+        return ErrFenced
+    }
+    _, err := s.db.Exec(
+        `UPDATE webhook_deliveries SET status = $2, attempts = $3, next_attempt = $4, last_status = $5,
+            last_error = $6, delivered_at = $7 WHERE id = $1`,
+        delivery.ID, delivery.Status, delivery.Attempts, delivery.NextAttempt, delivery.LastStatus,
+        delivery.LastError, delivery.DeliveredAt,
+    )
+    return err
+}
+
+// AddWatchlistItems inserts the items that are not watched yet.
+func (s *SqlDB) AddWatchlistItems(items []*types.WatchlistItemDoc) error {
+    return s.withTx(func(tx *sqlTx) error {
+        for _, item := range items {
+            _, err := tx.Exec(
+                `INSERT INTO watchlist_items (id, owner, kind, item, created_at) VALUES ($1, $2, $3, $4, $5)
+                ON CONFLICT (id) DO NOTHING`,
+                item.ID, item.Owner, item.Kind, item.Item, item.CreatedAt,
+            )
+            if err != nil {
+                return err
+            }
+        }
+        return nil
+    })
+}
+
+func (s *SqlDB) RemoveWatchlistItem(owner string, kind string, item string) (bool, error) {
+    result, err := s.db.Exec(`DELETE FROM watchlist_items WHERE owner = $1 AND kind = $2 AND item = $3`, owner, kind, item)
+    if err != nil {
+        return false, err
+    }
+    rows, err := result.RowsAffected()
+    return rows > 0, err
+}
+
+func (s *SqlDB) AddEmailSubscription(subscription *types.EmailSubscriptionDoc) error {
+    _, err := s.db.Exec(
+        `INSERT INTO email_subscriptions (id, owner, email, frequency, token, created_at, last_day, pending, slot)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+        subscription.ID, subscription.Owner, subscription.Email, subscription.Frequency, subscription.Token,
+        subscription.CreatedAt, subscription.LastDay, subscription.Pending, subscription.Slot,
+    )
+    return Classify(err)
+}
+
+func (s *SqlDB) ConfirmEmailSubscription(token string) (bool, error) {
+    result, err := s.db.Exec(`UPDATE email_subscriptions SET pending = FALSE WHERE token = $1`, token)
+    if err != nil {
+        return false, err
+    }
+    rows, err := result.RowsAffected()
+    return rows > 0, err
+}
+
+func (s *SqlDB) DeleteEmailSubscription(id string, owner string) (bool, error) {
+    result, err := s.db.Exec(`DELETE FROM email_subscriptions WHERE id = $1 AND owner = $2`, id, owner)
+    if err != nil {
+        return false, err
+    }
+    rows, err := result.RowsAffected()
+    return rows > 0, err
+}
+
+func (s *SqlDB) DeleteEmailSubscriptionByToken(token string) (bool, error) {
+    result, err := s.db.Exec(`DELETE FROM email_subscriptions WHERE token = $1`, token)
+    if err != nil {
+        return false, err
+    }
+    rows, err := result.RowsAffected()
+    return rows > 0, err
+}
+
+func (s *SqlDB) DeletePendingEmailSubscriptions(createdBefore int64) (int64, error) {
+    if s.Fenced() {
+        return 0, ErrFenced
+    }
+    result, err := s.db.Exec(`DELETE FROM email_subscriptions WHERE pending AND created_at < $1`, createdBefore)
+    if err != nil {
+        return 0, err
+    }
+    return result.RowsAffected()
+}
+
+func (s *SqlDB) ClaimEmailSubscriptionLastDay(id string, lastDay int64) (bool, error) {
+    if s.Fenced() {
+        return false, ErrFenced
+    }
+    result, err := s.db.Exec(`UPDATE email_subscriptions SET last_day = $1 WHERE id = $2 AND last_day < $1`, lastDay, id)
+    if err != nil {
+        return false, err
+    }
+    claimed, err := result.RowsAffected()
+    if err != nil {
+        return false, err
+    }
+    return claimed == 1, nil
+}
+
+func (s *SqlDB) UpdateEmailSubscriptionLastDay(id string, lastDay int64) error {
+    if s.Fenced() {
+        return ErrFenced
+    }
+    _, err := s.db.Exec(`UPDATE email_subscriptions SET last_day = $1 WHERE id = $2`, lastDay, id)
+    return err
+}
+
+func (s *SqlDB) EnableStatsRetention(retention time.Duration) error {
+    s.statsRetention = retention
+    return nil
+}
+
+// EnableChangeFeed is not supported, postgres users can use logical replication instead.
+// Callers check Capabilities first.
+func (s *SqlDB) EnableChangeFeed(retention time.Duration) error {
+    return ErrNotSupported
+}
+
+func (s *SqlDB) AggregateSmeshersEpochRewards(fromEpoch uint32) error {
+    if s.Fenced() {
+        return ErrFenced
+    }
+    _, err := s.db.Exec(
+        `INSERT INTO smeshers_epochs (node_id, epoch, coinbase, rewards, rewards_count)
+        SELECT node_id, epoch, MAX(CASE WHEN latest = 1 THEN coinbase END), SUM(total_reward), COUNT(*)
+        FROM (
+            SELECT node_id, layer / $1 AS epoch, coinbase, total_reward,
+                ROW_NUMBER() OVER (PARTITION BY node_id, layer / $1 ORDER BY layer DESC) AS latest
+            FROM rewards WHERE layer >= $2
+        ) epoch_rewards WHERE TRUE GROUP BY node_id, epoch
+        ON CONFLICT (node_id, epoch) DO UPDATE SET coinbase = EXCLUDED.coinbase,
+            rewards = EXCLUDED.rewards, rewards_count = EXCLUDED.rewards_count`,
+        config.LayersPerEpoch, int64(fromEpoch)*int64(config.LayersPerEpoch),
+    )
+    return err
+}
+
+// AggregateSmeshersTotals recomputes the smeshers with rewards or atxs in fromEpoch or
+// later, the totals of the others can not have changed.
+func (s *SqlDB) AggregateSmeshersTotals(fromEpoch uint32) error {
+    if s.Fenced() {
+        return ErrFenced
+    }
+    _, err := s.db.Exec(
+        `INSERT INTO smeshers (id, total_rewards, rewards_count)
+        SELECT node_id, SUM(rewards), SUM(rewards_count) FROM smeshers_epochs
+        WHERE node_id IN (SELECT node_id FROM smeshers_epochs WHERE epoch >= $1) GROUP BY node_id
+        ON CONFLICT (id) DO UPDATE SET total_rewards = EXCLUDED.total_rewards, rewards_count = EXCLUDED.rewards_count`,
+        int64(fromEpoch),
+    )
+    if err != nil {
+        return err
+    }
+    _, err = s.db.Exec(
+        `INSERT INTO smeshers (id, coinbase, effective_num_units, last_epoch, total_atx)
+        SELECT node_id, MAX(CASE WHEN latest = 1 THEN coinbase END),
+            MAX(CASE WHEN latest = 1 THEN effective_num_units END), MAX(publish_epoch), COUNT(*)
+        FROM (
+            SELECT node_id, coinbase, effective_num_units, publish_epoch,
+                ROW_NUMBER() OVER (PARTITION BY node_id ORDER BY publish_epoch DESC) AS latest
+            FROM atxs WHERE node_id IN (SELECT node_id FROM atxs WHERE publish_epoch >= $1)
+        ) node_atxs WHERE TRUE GROUP BY node_id
+        ON CONFLICT (id) DO UPDATE SET coinbase = EXCLUDED.coinbase, effective_num_units = EXCLUDED.effective_num_units,
+            last_epoch = EXCLUDED.last_epoch, total_atx = EXCLUDED.total_atx`,
+        int64(fromEpoch),
+    )
+    return err
+}
+
+func (s *SqlDB) SaveSmeshersPerformance(docs []*types.SmesherPerformanceDoc) error {
+    return s.withTx(func(tx *sqlTx) error {
+        for _, doc := range docs {
+            _, err := tx.Exec(
+                `INSERT INTO smeshers_performance (node_id, epoch, coinbase, weight, slots, expected_rewards, rewards, rewards_count, complete)
+                VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+                ON CONFLICT (node_id, epoch) DO UPDATE SET coinbase = EXCLUDED.coinbase, weight = EXCLUDED.weight,
+                    slots = EXCLUDED.slots, expected_rewards = EXCLUDED.expected_rewards, rewards = EXCLUDED.rewards,
+                    rewards_count = EXCLUDED.rewards_count, complete = EXCLUDED.complete`,
+                doc.Id.NodeId, int64(doc.Id.Epoch), doc.Coinbase, int64(doc.Weight), int64(doc.Slots),
+                doc.ExpectedRewards, doc.Rewards, doc.RewardsCount, doc.Complete,
+            )
+            if err != nil {
+                return err
+            }
+        }
+        return nil
+    })
+}
+
+// sqlRollupBucket computes the rollup bucket of a reward from its layer.
+func sqlRollupBucket(granularity string) string {
+    if granularity == RollupEpoch {
+        return fmt.Sprintf("layer / %d", config.LayersPerEpoch)
+    }
+    return fmt.Sprintf("(%d + layer * %d) / %d * %d", config.GenesisEpochSeconds, config.LayerDuration, daySeconds, daySeconds)
+}
+
+func (s *SqlDB) AggregateRewardsRollups(fromEpoch uint32) error {
+    if s.Fenced() {
+        return ErrFenced
+    }
+    fromLayers := map[string]int64{
+        RollupEpoch: int64(fromEpoch) * int64(config.LayersPerEpoch),
+        RollupDay:   rollupDayFromLayer(fromEpoch),
+    }
+    for _, granularity := range []string{RollupEpoch, RollupDay} {
+        bucket := sqlRollupBucket(granularity)
+        _, err := s.db.Exec(
+            `INSERT INTO account_rewards_rollups (account, granularity, bucket, rewards, rewards_count)
+            SELECT coinbase, $1, `+bucket+`, SUM(total_reward), COUNT(*)
+            FROM rewards WHERE layer >= $2 GROUP BY 1, 3
+            ON CONFLICT (account, granularity, bucket) DO UPDATE SET
+                rewards = EXCLUDED.rewards, rewards_count = EXCLUDED.rewards_count`,
+            granularity, fromLayers[granularity],
+        )
+        if err != nil {
+            return err
+        }
+        _, err = s.db.Exec(
+            `INSERT INTO network_rewards_rollups (granularity, bucket, rewards, rewards_count)
+            SELECT $1, `+bucket+`, SUM(total_reward), COUNT(*)
+            FROM rewards WHERE layer >= $2 GROUP BY 2
+            ON CONFLICT (granularity, bucket) DO UPDATE SET
+                rewards = EXCLUDED.rewards, rewards_count = EXCLUDED.rewards_count`,
+            granularity, fromLayers[granularity],
+        )
+        if err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+func (s *SqlDB) AggregateFeesRollups(fromEpoch uint32) error {
+    if s.Fenced() {
+        return ErrFenced
+    }
+    fromLayer := int64(fromEpoch) * int64(config.LayersPerEpoch)
+    buckets := map[string]string{
+        RollupLayer: "layer",
+        RollupEpoch: fmt.Sprintf("layer / %d", config.LayersPerEpoch),
+    }
+    for _, granularity := range []string{RollupLayer, RollupEpoch} {
+        _, err := s.db.Exec(
+            `INSERT INTO fees_rollups (granularity, bucket, transactions, gas, fees, min_fee, max_fee, min_gas_price, max_gas_price)
+            SELECT $1, `+buckets[granularity]+`, COUNT(*), SUM(gas), SUM(gas * gas_price), MIN(gas * gas_price), MAX(gas * gas_price),
+                MIN(gas_price), MAX(gas_price)
+            FROM transactions WHERE complete = TRUE AND layer >= $2 GROUP BY 2
+            ON CONFLICT (granularity, bucket) DO UPDATE SET transactions = EXCLUDED.transactions, gas = EXCLUDED.gas,
+                fees = EXCLUDED.fees, min_fee = EXCLUDED.min_fee, max_fee = EXCLUDED.max_fee,
+                min_gas_price = EXCLUDED.min_gas_price, max_gas_price = EXCLUDED.max_gas_price`,
+            granularity, fromLayer,
+        )
+        if err != nil {
+            return err
+        }
+    }
+    _, err := s.db.Exec(
+        `INSERT INTO fees_rollups (granularity, bucket, fees_rewarded)
+        SELECT $1, `+buckets[RollupEpoch]+`, SUM(total_reward - layer_reward)
+        FROM rewards WHERE layer >= $2 GROUP BY 2
+        ON CONFLICT (granularity, bucket) DO UPDATE SET fees_rewarded = EXCLUDED.fees_rewarded`,
+        RollupEpoch, fromLayer,
+    )
+    if err != nil {
+        return err
+    }
+
+    epochs, err := queryAll(s.db, scanFeesRollup,
+        "SELECT "+feesRollupColumns+" FROM fees_rollups WHERE granularity = $1 AND bucket >= $2", RollupEpoch, int64(fromEpoch))
+    if err != nil {
+        return err
+    }
+    for _, epoch := range epochs {
+        var median int64
+        if epoch.Transactions > 0 {
+            firstLayer := epoch.Id.Bucket * int64(config.LayersPerEpoch)
+            err = s.db.QueryRow(
+                `SELECT gas * gas_price FROM transactions WHERE complete = TRUE AND layer >= $1 AND layer < $2
+                ORDER BY 1 LIMIT 1 OFFSET $3`,
+                firstLayer, firstLayer+int64(config.LayersPerEpoch), (epoch.Transactions-1)/2,
+            ).Scan(&median)
+            if err != nil && err != sql.ErrNoRows {
+                return err
+            }
+        }
+        _, err = s.db.Exec("UPDATE fees_rollups SET median_fee = $1 WHERE granularity = $2 AND bucket = $3", median, RollupEpoch, epoch.Id.Bucket)
+        if err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// sqlLayerColumns are the columns holding the layer of the prunable tables.
+var sqlLayerColumns = map[string]string{
+    rewardsCollection:            "layer",
+    layersCollection:             "id",
+    transactionsCollection:       "layer",
+    blocksCollection:             "layer",
+    networkInfoHistoryCollection: "layer",
+}
+
+func (s *SqlDB) PruneCollection(collection string, beforeLayer uint32, dryRun bool) (int64, error) {
+    column, ok := sqlLayerColumns[collection]
+    if !ok {
+        return 0, fmt.Errorf("collection %s can not be pruned", collection)
+    }
+    if s.Fenced() {
+        return 0, ErrFenced
+    }
+    table := sqlTables[collection]
+    if dryRun {
+        return s.count("SELECT COUNT(*) FROM "+table+" WHERE "+column+" < $1", beforeLayer)
+    }
+    result, err := s.db.Exec("DELETE FROM "+table+" WHERE "+column+" < $1", beforeLayer)
+    if err != nil {
+        return 0, err
+    }
+    return result.RowsAffected()
+}
+
+// ExpirePendingTransactions deletes the pending transactions created before the unix
+// time createdBefore except the ones in keep, a result that still comes saves the
+// transaction again. The layer of created transactions is 0, it can not be used.
+func (s *SqlDB) ExpirePendingTransactions(createdBefore int64, keep []string) (int64, error) {
+    if s.Fenced() {
+        return 0, ErrFenced
+    }
+    filter := (&sqlFilter{}).add("complete = ?", false).add("created_at < ?", createdBefore).notIn("id", keep)
+    result, err := s.db.Exec("DELETE FROM transactions"+filter.where(), filter.args...)
+    if err != nil {
+        return 0, err
+    }
+    return result.RowsAffected()
+}
+
+// RebuildAggregate is not supported, the sql aggregates are updated in the same
+// transaction as the rows they derive from. Callers check Capabilities first.
+func (s *SqlDB) RebuildAggregate(collection string) error {
+    return ErrNotSupported
+}
+
+// UpgradeDocuments is not supported, the sql rows have no stored format to upgrade
+// and their columns change with the schema. Callers check Capabilities first.
+func (s *SqlDB) UpgradeDocuments(collection string) (int64, error) {
+    return 0, ErrNotSupported
+}
+
+// EnsureIndexes runs the schema again, every statement only creates what is missing.
+func (s *SqlDB) EnsureIndexes() error {
+    return applySchema(s.db.DB, s.dialect, s.schema)
+}
+
+// applySchema runs every schema statement, they only create what is missing.
+func applySchema(db *sql.DB, dialect *sqlDialect, schema []string) error {
+    for _, statement := range schema {
+        _, err := db.Exec(statement)
+        if err != nil && !(dialect.existingColumn != nil && dialect.existingColumn(err)) {
+            return fmt.Errorf("failed to create %s schema: %w", dialect.name, err)
+        }
+    }
+    return nil
+}
+
+func (s *SqlDB) AcquireFence(instanceId string) (*types.FenceDoc, error) {
+    now := time.Now()
+    fence := &types.FenceDoc{}
+    err := s.db.QueryRow(
+        `INSERT INTO fences (id, instance_id, generation, acquired_at) VALUES ($1, $2, $3, $4)
+        ON CONFLICT (id) DO UPDATE SET generation = EXCLUDED.generation,
+            instance_id = EXCLUDED.instance_id, acquired_at = EXCLUDED.acquired_at
+        WHERE fences.generation < EXCLUDED.generation
+        RETURNING id, instance_id, generation, acquired_at`,
+        sinkFence, instanceId, fenceGeneration(now), sqlTime(now),
+    ).Scan(&fence.Id, &fence.InstanceId, &fence.Generation, &fence.AcquiredAt)
+    // the fence has the generation of a newer instance
+    if errors.Is(err, sql.ErrNoRows) {
+        return nil, ErrFenced
+    }
+    if err != nil {
+        return nil, err
+    }
+    s.fence.Store(fence)
+    s.fenced.Store(false)
+    log.Printf("Acquired sink fence generation %d for %s", fence.Generation, instanceId)
+    return fence, nil
+}
+
+func (s *SqlDB) StartFenceCheck(interval time.Duration) {
+    ticker := time.NewTicker(interval)
+    go func() {
+        for range ticker.C {
+            if s.Fenced() || s.checkFence() {
+                ticker.Stop()
+                return
+            }
+        }
+    }()
+}
+
+func (s *SqlDB) checkFence() bool {
+    fence := s.fence.Load()
+    if fence == nil {
+        return false
+    }
+    var generation int64
+    var instanceId string
+    err := s.db.QueryRow(`SELECT generation, instance_id FROM fences WHERE id = $1`, sinkFence).Scan(&generation, &instanceId)
+    if err != nil {
+        log.Printf("Failed to check sink fence: %v", err)
+        return false
+    }
+    if generation != fence.Generation {
+        log.Printf("Sink fence generation %d taken by %s, stop writing", generation, instanceId)
+        s.fenced.Store(true)
+        return true
+    }
+    return false
+}
+
+func (s *SqlDB) Fenced() bool {
+    return s.fenced.Load()
+}
+
+func (s *SqlDB) FenceOff() {
+    s.fenced.Store(true)
+}
+
+// AcquireLease takes the lease row only when it expired or is already held by
+// instanceId, the fence generation is increased in the same transaction.
+func (s *SqlDB) AcquireLease(instanceId string, ttl time.Duration) (*types.FenceDoc, error) {
+    now := time.Now()
+    fence := &types.FenceDoc{}
+    err := s.inTx(func(tx *sqlTx) error {
+        result, err := tx.Exec(
+            `INSERT INTO sink_leases (id, instance_id, lease_until) VALUES ($1, $2, $3)
+            ON CONFLICT (id) DO UPDATE SET instance_id = EXCLUDED.instance_id, lease_until = EXCLUDED.lease_until
+            WHERE sink_leases.lease_until < $4 OR sink_leases.instance_id = EXCLUDED.instance_id`,
+            sinkFence, instanceId, sqlTime(now.Add(ttl)), sqlTime(now),
+        )
+        if err != nil {
+            return err
+        }
+        acquired, err := result.RowsAffected()
+        if err != nil {
+            return err
+        }
+        if acquired == 0 {
+            return ErrLeaseHeld
+        }
+        err = tx.QueryRow(
+            `INSERT INTO fences (id, instance_id, generation, acquired_at) VALUES ($1, $2, $3, $4)
+            ON CONFLICT (id) DO UPDATE SET generation = EXCLUDED.generation,
+                instance_id = EXCLUDED.instance_id, acquired_at = EXCLUDED.acquired_at
+            WHERE fences.generation < EXCLUDED.generation
+            RETURNING id, instance_id, generation, acquired_at`,
+            sinkFence, instanceId, fenceGeneration(now), sqlTime(now),
+        ).Scan(&fence.Id, &fence.InstanceId, &fence.Generation, &fence.AcquiredAt)
+        if errors.Is(err, sql.ErrNoRows) {
+            return ErrLeaseHeld
+        }
+        if err != nil {
+            return err
+        }
+        _, err = tx.Exec(`UPDATE sink_leases SET generation = $1 WHERE id = $2`, fence.Generation, sinkFence)
+        return err
+    })
+    if err != nil {
+        return nil, err
+    }
+    fence.LeaseUntil = now.Add(ttl)
+    s.fence.Store(fence)
+    s.fenced.Store(false)
+    log.Printf("Acquired writer lease generation %d for %s", fence.Generation, instanceId)
+    return fence, nil
+}
+
+func (s *SqlDB) RenewLease(ttl time.Duration) error {
+    fence := s.fence.Load()
+    if fence == nil {
+        return ErrFenced
+    }
+    result, err := s.db.Exec(
+        `UPDATE sink_leases SET lease_until = $1 WHERE id = $2 AND generation = $3`,
+        sqlTime(time.Now().Add(ttl)), sinkFence, fence.Generation,
+    )
+    if err != nil {
+        return err
+    }
+    renewed, err := result.RowsAffected()
+    if err != nil {
+        return err
+    }
+    if renewed == 0 {
+        s.fenced.Store(true)
+        return ErrFenced
+    }
+    return nil
+}
+
+func (s *SqlDB) ReleaseLease() error {
+    fence := s.fence.Load()
+    if fence == nil {
+        return nil
+    }
+    _, err := s.db.Exec(
+        `UPDATE sink_leases SET lease_until = $1 WHERE id = $2 AND generation = $3`,
+        sqlTime(time.Now()), sinkFence, fence.Generation,
+    )
+    return err
+}
+
+func (s *SqlDB) CloseWrite() {
+    s.close()
+}
+
+func (s *SqlDB) CloseRead() {
+    s.close()
+}
+
+// close is shared by CloseWrite and CloseRead as both stores use the same pool.
+func (s *SqlDB) close() {
+    s.closeOnce.Do(func() {
+        s.db.Close()
+    })
+}
@@ -0,0 +1,1311 @@
+package database
+
+import (
+    "database/sql"
+    "encoding/json"
+    "fmt"
+    "math"
+    "strconv"
+    "strings"
+    "time"
+
+    sTypes "github.com/spacemeshos/go-spacemesh/common/types"
+    transactionparsertypes "github.com/swarmbit/spacemesh-state-api/pkg/transactionparser/transaction"
+    "github.com/swarmbit/spacemesh-state-api/types"
+)
+
+const rewardColumns = "id, node_id, coinbase, atx_id, layer_reward, total_reward, layer"
+const atxColumns = "id, node_id, coinbase, publish_epoch, effective_num_units, base_tick, weight, tick_count, sequence, received"
+const transactionColumns = "id, status, principal_account, receiver_account, vault_account, fee, gas, gas_price, amount, layer, counter, method, type, complete, message, block_id, created_at, raw"
+const accountColumns = "address, balance, total_rewards, fees, sent, first_seen_layer, last_activity_layer, incoming_transfers, outgoing_transfers"
+const nodeColumns = "id, malfeasance_received, malfeasance_layer"
+const smesherColumns = "id, coinbase, effective_num_units, last_epoch, total_atx, total_rewards, rewards_count"
+
+// smesherSortColumns maps the mongo field names GetTopSmeshers is called with.
+var smesherSortColumns = map[string]string{
+    "effectiveNumUnits": "effective_num_units",
+    "totalRewards":      "total_rewards",
+    "totalAtx":          "total_atx",
+}
+
+// atxSortColumns maps the AtxSort values GetAtxForEpochPaginated is called with.
+var atxSortColumns = map[string]string{
+    AtxSortEffectiveUnits: "effective_num_units",
+    AtxSortHeight:         "base_tick + tick_count",
+}
+
+type scanner interface {
+    Scan(dest ...interface{}) error
+}
+
+// sqlFilter builds a where clause with numbered placeholders.
+type sqlFilter struct {
+    conditions []string
+    args       []interface{}
+}
+
+// add appends condition with every ? replaced by the placeholder of arg.
+func (f *sqlFilter) add(condition string, arg interface{}) *sqlFilter {
+    f.args = append(f.args, arg)
+    f.conditions = append(f.conditions, strings.ReplaceAll(condition, "?", "$"+strconv.Itoa(len(f.args))))
+    return f
+}
+
+// after appends the rows after the cursor ordered by column and then id, nothing is
+// appended on the first page when cursor is nil.
+func (f *sqlFilter) after(column string, cursor *Cursor, sort int8) *sqlFilter {
+    if cursor == nil {
+        return f
+    }
+    op := ">"
+    if sort < 0 {
+        op = "<"
+    }
+    f.args = append(f.args, cursor.Key, cursor.ID)
+    key, id := "$"+strconv.Itoa(len(f.args)-1), "$"+strconv.Itoa(len(f.args))
+    f.conditions = append(f.conditions, "("+column+" "+op+" "+key+" OR ("+column+" = "+key+" AND id "+op+" "+id+"))")
+    return f
+}
+
+// cursorOrder orders the rows of a cursor page by column and then id.
+func cursorOrder(column string, sort int8) string {
+    return " ORDER BY " + column + " " + sqlOrder(sort) + ", id " + sqlOrder(sort)
+}
+
+// in appends column IN with a placeholder per value, no values match no rows.
+func (f *sqlFilter) in(column string, values []string) *sqlFilter {
+    if len(values) == 0 {
+        f.conditions = append(f.conditions, "FALSE")
+        return f
+    }
+    placeholders := make([]string, len(values))
+    for i, v := range values {
+        f.args = append(f.args, v)
+        placeholders[i] = "$" + strconv.Itoa(len(f.args))
+    }
+    f.conditions = append(f.conditions, column+" IN ("+strings.Join(placeholders, ", ")+")")
+    return f
+}
+
+// notIn appends column NOT IN with a placeholder per value, no values exclude no rows.
+func (f *sqlFilter) notIn(column string, values []string) *sqlFilter {
+    if len(values) == 0 {
+        return f
+    }
+    placeholders := make([]string, len(values))
+    for i, v := range values {
+        f.args = append(f.args, v)
+        placeholders[i] = "$" + strconv.Itoa(len(f.args))
+    }
+    f.conditions = append(f.conditions, column+" NOT IN ("+strings.Join(placeholders, ", ")+")")
+    return f
+}
+
+func (f *sqlFilter) where() string {
+    if len(f.conditions) == 0 {
+        return ""
+    }
+    return " WHERE " + strings.Join(f.conditions, " AND ")
+}
+
+// page appends the limit and offset placeholders, a zero limit means no limit like in mongo.
+func (f *sqlFilter) page(skip int64, limit int64) string {
+    // sqlite has no OFFSET without LIMIT
+    if limit <= 0 {
+        limit = math.MaxInt64
+    }
+    f.args = append(f.args, limit, skip)
+    return fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(f.args)-1, len(f.args))
+}
+
+func sqlOrder(sort int8) string {
+    if sort < 0 {
+        return "DESC"
+    }
+    return "ASC"
+}
+
+func queryEach[T any](db *sqlConn, scan func(scanner) (*T, error), each func(*T) error, query string, args ...interface{}) error {
+    rows, err := db.Query(query, args...)
+    if err != nil {
+        return err
+    }
+    defer rows.Close()
+
+    for rows.Next() {
+        doc, err := scan(rows)
+        if err != nil {
+            return err
+        }
+        if err := each(doc); err != nil {
+            return err
+        }
+    }
+    return rows.Err()
+}
+
+func queryAll[T any](db *sqlConn, scan func(scanner) (*T, error), query string, args ...interface{}) ([]*T, error) {
+    results := make([]*T, 0)
+    err := queryEach(db, scan, func(doc *T) error {
+        results = append(results, doc)
+        return nil
+    }, query, args...)
+    if err != nil {
+        return nil, err
+    }
+    return results, nil
+}
+
+func (s *SqlDB) count(query string, args ...interface{}) (int64, error) {
+    var count int64
+    err := s.db.QueryRow(query, args...).Scan(&count)
+    return count, err
+}
+
+func scanReward(row scanner) (*types.RewardsDoc, error) {
+    doc := &types.RewardsDoc{}
+    err := row.Scan(&doc.Id, &doc.NodeId, &doc.Coinbase, &doc.AtxID, &doc.LayerReward, &doc.TotalReward, &doc.Layer)
+    return doc, err
+}
+
+func scanAtx(row scanner) (*types.AtxDoc, error) {
+    doc := &types.AtxDoc{}
+    err := row.Scan(&doc.AtxID, &doc.NodeID, &doc.Coinbase, &doc.PublishEpoch, &doc.EffectiveNumUnits,
+        &doc.BaseTick, &doc.Weight, &doc.TickCount, &doc.Sequence, &doc.Received)
+    return doc, err
+}
+
+func scanTransaction(row scanner) (*types.TransactionDoc, error) {
+    doc := &types.TransactionDoc{}
+    err := row.Scan(&doc.ID, &doc.Status, &doc.PrincipaAccount, &doc.ReceiverAccount, &doc.VaultAccount,
+        &doc.Fee, &doc.Gas, &doc.GasPrice, &doc.Amount, &doc.Layer, &doc.Counter, &doc.Method, &doc.Type, &doc.Complete, &doc.Message,
+        &doc.BlockID, &doc.CreatedAt, &doc.Raw)
+    return doc, err
+}
+
+func scanAccount(row scanner) (*types.AccountDoc, error) {
+    doc := &types.AccountDoc{}
+    err := row.Scan(&doc.Address, &doc.Balance, &doc.TotalRewards, &doc.Fees, &doc.Sent,
+        &doc.FirstSeenLayer, &doc.LastActivityLayer, &doc.IncomingTransfers, &doc.OutgoingTransfers)
+    return doc, err
+}
+
+func scanSmesher(row scanner) (*types.SmesherDoc, error) {
+    doc := &types.SmesherDoc{}
+    err := row.Scan(&doc.ID, &doc.Coinbase, &doc.EffectiveNumUnits, &doc.LastEpoch, &doc.TotalAtx, &doc.TotalRewards, &doc.RewardsCount)
+    return doc, err
+}
+
+func scanLayer(row scanner) (*types.LayerDoc, error) {
+    doc := &types.LayerDoc{}
+    err := row.Scan(&doc.Layer, &doc.Status)
+    return doc, err
+}
+
+func scanRewardsRollup(row scanner) (*types.RewardsRollupDoc, error) {
+    doc := &types.RewardsRollupDoc{}
+    err := row.Scan(&doc.Id.Account, &doc.Id.Granularity, &doc.Id.Bucket, &doc.Rewards, &doc.RewardsCount)
+    return doc, err
+}
+
+func scanPrice(row scanner) (*types.PriceDoc, error) {
+    doc := &types.PriceDoc{}
+    err := row.Scan(&doc.Timestamp, &doc.USDPrice, &doc.Source)
+    return doc, err
+}
+
+func accountRewardsSqlFilter(account string, firstLayer int, lastLayer int) *sqlFilter {
+    filter := &sqlFilter{}
+    if account != "" {
+        filter.add("coinbase = ?", account)
+    }
+    if firstLayer > -1 {
+        filter.add("layer >= ?", firstLayer)
+    }
+    if lastLayer > -1 {
+        filter.add("layer <= ?", lastLayer)
+    }
+    return filter
+}
+
+// state appends the conditions selecting the transactions in state, one of the
+// transaction states or applied for both results. No state selects every transaction.
+func (f *sqlFilter) state(state string) *sqlFilter {
+    switch state {
+    case types.TransactionCreated:
+        f.add("complete = ?", false)
+    case types.TransactionApplied:
+        f.add("complete = ?", true)
+    case types.TransactionSuccess:
+        f.add("complete = ?", true).add("status = ?", 0)
+    case types.TransactionFailure:
+        f.add("complete = ?", true).add("status <> ?", 0)
+    }
+    return f
+}
+
+func allTransactionsSqlFilter(filter *TransactionsFilter) *sqlFilter {
+    query := (&sqlFilter{}).state(filter.State)
+    if len(filter.Receivers) > 0 {
+        query.in("receiver_account", filter.Receivers)
+    }
+    if filter.FirstLayer > -1 {
+        query.add("layer >= ?", filter.FirstLayer)
+    }
+    if filter.LastLayer > -1 {
+        query.add("layer <= ?", filter.LastLayer)
+    }
+    if filter.Method > -1 {
+        query.add("method = ?", filter.Method)
+    }
+    if filter.MinAmount > -1 {
+        query.add("amount >= ?", filter.MinAmount)
+    }
+    return query
+}
+
+func (s *SqlDB) GetAccounts(skip int64, limit int64, sort int8) ([]*types.AccountDoc, error) {
+    filter := &sqlFilter{}
+    return queryAll(s.db, scanAccount,
+        "SELECT "+accountColumns+" FROM accounts ORDER BY balance "+sqlOrder(sort)+filter.page(skip, limit),
+        filter.args...)
+}
+
+func (s *SqlDB) GetAccount(account string) (*types.AccountDoc, error) {
+    doc, err := scanAccount(s.db.QueryRow("SELECT "+accountColumns+" FROM accounts WHERE address = $1", account))
+    if err == sql.ErrNoRows {
+        return &types.AccountDoc{}, nil
+    }
+    return doc, err
+}
+
+func (s *SqlDB) GetAccountsGroup(accounts []string) (*types.AccountGroup, error) {
+    group := &types.AccountGroup{}
+    filter := (&sqlFilter{}).in("address", accounts)
+    err := s.db.QueryRow(
+        `SELECT COALESCE(SUM(balance), 0), COALESCE(SUM(total_rewards), 0) FROM accounts`+filter.where(),
+        filter.args...,
+    ).Scan(&group.Balance, &group.TotalRewards)
+    if err != nil {
+        return nil, err
+    }
+    return group, nil
+}
+
+func (s *SqlDB) GetAccountsByAddress(accounts []string) ([]*types.AccountDoc, error) {
+    filter := (&sqlFilter{}).in("address", accounts)
+    return queryAll(s.db, scanAccount, "SELECT "+accountColumns+" FROM accounts"+filter.where(), filter.args...)
+}
+
+func (s *SqlDB) GetAccountsCounters(accounts []string) ([]*types.AccountCounterDoc, error) {
+    filter := (&sqlFilter{}).in("principal_account", accounts).add("complete = ?", true)
+    return queryAll(s.db, func(row scanner) (*types.AccountCounterDoc, error) {
+        doc := &types.AccountCounterDoc{}
+        err := row.Scan(&doc.Address, &doc.Counter)
+        return doc, err
+    }, "SELECT principal_account, MAX(counter) FROM transactions"+filter.where()+" GROUP BY principal_account", filter.args...)
+}
+
+func (s *SqlDB) CountAccounts() (int64, error) {
+    return s.count(`SELECT COUNT(*) FROM accounts`)
+}
+
+func (s *SqlDB) GetAccountsPostEpoch(epoch int, skip int64, limit int64, sort int8) ([]*types.AccountAtxDoc, error) {
+    filter := (&sqlFilter{}).add("publish_epoch = ?", epoch)
+    return queryAll(s.db, func(row scanner) (*types.AccountAtxDoc, error) {
+        doc := &types.AccountAtxDoc{}
+        err := row.Scan(&doc.Id.Coinbase, &doc.Id.PublishEpoch, &doc.TotalEffectiveNumUnits, &doc.TotalWeight, &doc.TotalAtx)
+        return doc, err
+    },
+        "SELECT coinbase, publish_epoch, total_effective_num_units, total_weight, total_atx FROM account_atxs_epochs"+
+            filter.where()+" ORDER BY total_weight "+sqlOrder(sort)+filter.page(skip, limit),
+        filter.args...)
+}
+
+func (s *SqlDB) CountAccountsPostEpoch(epoch int) (int64, error) {
+    return s.count(`SELECT COUNT(DISTINCT coinbase) FROM account_atxs_epochs WHERE publish_epoch = $1`, epoch)
+}
+
+// nodeAtxs returns the atxs of the nodes, in mongo they are kept in the node document.
+func (s *SqlDB) nodeAtxs(nodeIds []string) (map[string][]types.NodeAtxDoc, error) {
+    atxs := make(map[string][]types.NodeAtxDoc)
+    filter := (&sqlFilter{}).in("node_id", nodeIds)
+    err := queryEach(s.db, scanAtx, func(atx *types.AtxDoc) error {
+        atxs[atx.NodeID] = append(atxs[atx.NodeID], types.NodeAtxDoc{
+            Coinbase:          atx.Coinbase,
+            PublishEpoch:      atx.PublishEpoch,
+            EffectiveNumUnits: atx.EffectiveNumUnits,
+            Weight:            atx.Weight,
+            Sequence:          atx.Sequence,
+            Received:          atx.Received,
+        })
+        return nil
+    }, "SELECT "+atxColumns+" FROM atxs"+filter.where()+" ORDER BY publish_epoch", filter.args...)
+    return atxs, err
+}
+
+func (s *SqlDB) getNodes(query string, args ...interface{}) ([]*types.NodeDoc, error) {
+    nodes, err := queryAll(s.db, func(row scanner) (*types.NodeDoc, error) {
+        doc := &types.NodeDoc{}
+        var malfeasance sql.NullInt64
+        err := row.Scan(&doc.ID, &malfeasance, &doc.Malfeasance.Layer)
+        doc.Malfeasance.Received = malfeasance.Int64
+        return doc, err
+    }, query, args...)
+    if err != nil || len(nodes) == 0 {
+        return nodes, err
+    }
+
+    nodeIds := make([]string, len(nodes))
+    for i, v := range nodes {
+        nodeIds[i] = v.ID
+    }
+    atxs, err := s.nodeAtxs(nodeIds)
+    if err != nil {
+        return nil, err
+    }
+    for _, v := range nodes {
+        v.Atxs = atxs[v.ID]
+    }
+    return nodes, nil
+}
+
+func (s *SqlDB) GetNode(nodeId string) (*types.NodeDoc, error) {
+    nodes, err := s.getNodes("SELECT "+nodeColumns+" FROM nodes WHERE id = $1", nodeId)
+    if err != nil {
+        return &types.NodeDoc{}, err
+    }
+    if len(nodes) == 0 {
+        return &types.NodeDoc{}, nil
+    }
+    return nodes[0], nil
+}
+
+func (s *SqlDB) GetNodes(skip int64, limit int64) ([]*types.NodeDoc, error) {
+    filter := &sqlFilter{}
+    return s.getNodes("SELECT "+nodeColumns+" FROM nodes ORDER BY id"+filter.page(skip, limit), filter.args...)
+}
+
+func (s *SqlDB) CountNodes() (int64, error) {
+    return s.count(`SELECT COUNT(*) FROM nodes WHERE has_atx`)
+}
+
+func (s *SqlDB) GetMalfeasanceNodes() ([]*types.NodeDoc, error) {
+    return s.getNodes("SELECT "+nodeColumns+" FROM nodes WHERE malfeasance_received IS NOT NULL")
+}
+
+func (s *SqlDB) GetMalfeasanceNodesPaginated(skip int64, limit int64) ([]*types.NodeDoc, error) {
+    filter := &sqlFilter{}
+    return s.getNodes("SELECT "+nodeColumns+" FROM nodes WHERE malfeasance_received IS NOT NULL"+
+        " ORDER BY malfeasance_received DESC, id"+filter.page(skip, limit), filter.args...)
+}
+
+func (s *SqlDB) CountMalfeasanceNodes() (int64, error) {
+    return s.count(`SELECT COUNT(*) FROM nodes WHERE malfeasance_received IS NOT NULL`)
+}
+
+func (s *SqlDB) FilterMalfeasanceNodes(nodeIds []string) ([]string, error) {
+    filter := (&sqlFilter{}).in("id", nodeIds)
+    nodes, err := queryAll(s.db, func(row scanner) (*string, error) {
+        var nodeId string
+        err := row.Scan(&nodeId)
+        return &nodeId, err
+    }, "SELECT id FROM nodes"+filter.where()+" AND malfeasance_received IS NOT NULL", filter.args...)
+    if err != nil {
+        return nil, err
+    }
+    result := make([]string, len(nodes))
+    for i, nodeId := range nodes {
+        result[i] = *nodeId
+    }
+    return result, nil
+}
+
+func (s *SqlDB) GetTransaction(transactionId string) (*types.TransactionDoc, error) {
+    doc, err := scanTransaction(s.db.QueryRow("SELECT "+transactionColumns+" FROM transactions WHERE id = $1", transactionId))
+    if err == sql.ErrNoRows {
+        return &types.TransactionDoc{}, nil
+    }
+    return doc, err
+}
+
+func (s *SqlDB) GetTransactions(account string, skip int64, limit int64, sort int8, state string) ([]*types.TransactionDoc, error) {
+    filter := (&sqlFilter{}).add("(principal_account = ? OR receiver_account = ?)", account).state(state)
+    return queryAll(s.db, scanTransaction,
+        "SELECT "+transactionColumns+" FROM transactions"+filter.where()+" ORDER BY layer "+sqlOrder(sort)+filter.page(skip, limit),
+        filter.args...)
+}
+
+func (s *SqlDB) GetTransactionsAfter(account string, after *Cursor, limit int64, sort int8, state string) ([]*types.TransactionDoc, error) {
+    filter := (&sqlFilter{}).add("(principal_account = ? OR receiver_account = ?)", account).state(state).after("layer", after, sort)
+    return queryAll(s.db, scanTransaction,
+        "SELECT "+transactionColumns+" FROM transactions"+filter.where()+cursorOrder("layer", sort)+filter.page(0, limit),
+        filter.args...)
+}
+
+func (s *SqlDB) CountTransactions(account string, state string) (int64, error) {
+    filter := (&sqlFilter{}).add("(principal_account = ? OR receiver_account = ?)", account).state(state)
+    return s.count("SELECT COUNT(*) FROM transactions"+filter.where(), filter.args...)
+}
+
+func (s *SqlDB) GetPendingTransactionIds(createdBefore int64) ([]string, error) {
+    ids, err := queryAll(s.db, func(row scanner) (*string, error) {
+        var id string
+        err := row.Scan(&id)
+        return &id, err
+    }, `SELECT id FROM transactions WHERE complete = FALSE AND created_at < $1`, createdBefore)
+    if err != nil {
+        return nil, err
+    }
+    result := make([]string, len(ids))
+    for i, id := range ids {
+        result[i] = *id
+    }
+    return result, nil
+}
+
+func (s *SqlDB) GetLayerTransactions(layer int, skip int64, limit int64, sort int8, state string) ([]*types.TransactionDoc, error) {
+    filter := (&sqlFilter{}).add("layer = ?", layer).state(state)
+    return queryAll(s.db, scanTransaction,
+        "SELECT "+transactionColumns+" FROM transactions"+filter.where()+" ORDER BY layer "+sqlOrder(sort)+filter.page(skip, limit),
+        filter.args...)
+}
+
+func (s *SqlDB) CountLayerTransactions(layer int, state string) (int64, error) {
+    filter := (&sqlFilter{}).add("layer = ?", layer).state(state)
+    return s.count("SELECT COUNT(*) FROM transactions"+filter.where(), filter.args...)
+}
+
+func (s *SqlDB) GetAllTransactions(skip int64, limit int64, sort int8, transactionsFilter *TransactionsFilter) ([]*types.TransactionDoc, error) {
+    filter := allTransactionsSqlFilter(transactionsFilter)
+    return queryAll(s.db, scanTransaction,
+        "SELECT "+transactionColumns+" FROM transactions"+filter.where()+" ORDER BY layer "+sqlOrder(sort)+filter.page(skip, limit),
+        filter.args...)
+}
+
+func (s *SqlDB) GetAllTransactionsAfter(after *Cursor, limit int64, sort int8, transactionsFilter *TransactionsFilter) ([]*types.TransactionDoc, error) {
+    filter := allTransactionsSqlFilter(transactionsFilter).after("layer", after, sort)
+    return queryAll(s.db, scanTransaction,
+        "SELECT "+transactionColumns+" FROM transactions"+filter.where()+cursorOrder("layer", sort)+filter.page(0, limit),
+        filter.args...)
+}
+
+func (s *SqlDB) CountAllTransactions(transactionsFilter *TransactionsFilter) (int64, error) {
+    filter := allTransactionsSqlFilter(transactionsFilter)
+    return s.count("SELECT COUNT(*) FROM transactions"+filter.where(), filter.args...)
+}
+
+func (s *SqlDB) GetRewards(account string, skip int64, limit int64, sort int8, firstLayer int, lastLayer int) ([]*types.RewardsDoc, error) {
+    filter := accountRewardsSqlFilter(account, firstLayer, lastLayer)
+    return queryAll(s.db, scanReward,
+        "SELECT "+rewardColumns+" FROM rewards"+filter.where()+" ORDER BY layer "+sqlOrder(sort)+filter.page(skip, limit),
+        filter.args...)
+}
+
+func (s *SqlDB) GetRewardsAfter(account string, after *Cursor, limit int64, sort int8, firstLayer int, lastLayer int) ([]*types.RewardsDoc, error) {
+    filter := accountRewardsSqlFilter(account, firstLayer, lastLayer).after("layer", after, sort)
+    return queryAll(s.db, scanReward,
+        "SELECT "+rewardColumns+" FROM rewards"+filter.where()+cursorOrder("layer", sort)+filter.page(0, limit),
+        filter.args...)
+}
+
+func (s *SqlDB) CountRewards(account string, firstLayer int, lastLayer int) (int64, error) {
+    filter := accountRewardsSqlFilter(account, firstLayer, lastLayer)
+    return s.count("SELECT COUNT(*) FROM rewards"+filter.where(), filter.args...)
+}
+
+func (s *SqlDB) SumRewardsLayers(account string, minLayer uint32, maxLayer uint32) (int64, error) {
+    filter := (&sqlFilter{}).add("layer >= ?", minLayer).add("layer < ?", maxLayer)
+    if account != "" {
+        filter.add("coinbase = ?", account)
+    }
+    return s.count("SELECT COALESCE(SUM(total_reward), 0) FROM rewards"+filter.where(), filter.args...)
+}
+
+func (s *SqlDB) GetCoinbaseRewardsTotal(coinbase string, epoch int64) (*types.CoinbaseRewardsTotalDoc, error) {
+    total := &types.CoinbaseRewardsTotalDoc{Id: types.CoinbaseRewardsTotalId{Coinbase: coinbase, Epoch: epoch}}
+    err := s.db.QueryRow(
+        `SELECT rewards, rewards_count FROM coinbase_rewards_totals WHERE coinbase = $1 AND epoch = $2`,
+        coinbase, epoch,
+    ).Scan(&total.Rewards, &total.RewardsCount)
+    if err == sql.ErrNoRows {
+        return total, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    return total, nil
+}
+
+func (s *SqlDB) GetLayerRewards(layer int, skip int64, limit int64, sort int8) ([]*types.RewardsDoc, error) {
+    filter := (&sqlFilter{}).add("layer = ?", layer)
+    return queryAll(s.db, scanReward,
+        "SELECT "+rewardColumns+" FROM rewards"+filter.where()+" ORDER BY layer "+sqlOrder(sort)+filter.page(skip, limit),
+        filter.args...)
+}
+
+func (s *SqlDB) CountLayerRewards(layer int) (int64, error) {
+    return s.count(`SELECT COUNT(*) FROM rewards WHERE layer = $1`, layer)
+}
+
+func (s *SqlDB) GetNodeRewards(node string, skip int64, limit int64, sort int8) ([]*types.RewardsDoc, error) {
+    filter := (&sqlFilter{}).add("node_id = ?", node)
+    return queryAll(s.db, scanReward,
+        "SELECT "+rewardColumns+" FROM rewards"+filter.where()+" ORDER BY layer "+sqlOrder(sort)+filter.page(skip, limit),
+        filter.args...)
+}
+
+func (s *SqlDB) GetNodeRewardsAfter(node string, after *Cursor, limit int64, sort int8) ([]*types.RewardsDoc, error) {
+    filter := (&sqlFilter{}).add("node_id = ?", node).after("layer", after, sort)
+    return queryAll(s.db, scanReward,
+        "SELECT "+rewardColumns+" FROM rewards"+filter.where()+cursorOrder("layer", sort)+filter.page(0, limit),
+        filter.args...)
+}
+
+func (s *SqlDB) CountNodeRewards(node string) (int64, error) {
+    return s.count(`SELECT COUNT(*) FROM rewards WHERE node_id = $1`, node)
+}
+
+func (s *SqlDB) CountNodeRewardsLayers(node string, minLayer uint32, maxLayer uint32) (int64, error) {
+    return s.count(`SELECT COUNT(*) FROM rewards WHERE node_id = $1 AND layer >= $2 AND layer < $3`, node, minLayer, maxLayer)
+}
+
+func (s *SqlDB) SumNodeRewardsLayers(node string, minLayer uint32, maxLayer uint32) (int64, error) {
+    return s.count(
+        `SELECT COALESCE(SUM(total_reward), 0) FROM rewards WHERE node_id = $1 AND layer >= $2 AND layer < $3`,
+        node, minLayer, maxLayer,
+    )
+}
+
+func (s *SqlDB) atxTotals(column string, value string, epoch uint64) (*types.AggregationAtxTotals, error) {
+    totals := &types.AggregationAtxTotals{}
+    err := s.db.QueryRow(
+        "SELECT COALESCE(SUM(weight), 0), COALESCE(SUM(effective_num_units), 0) FROM atxs WHERE "+column+" = $1 AND publish_epoch = $2",
+        value, epoch,
+    ).Scan(&totals.TotalWeight, &totals.TotalEffectiveNumUnits)
+    if err != nil {
+        return nil, err
+    }
+    return totals, nil
+}
+
+func (s *SqlDB) GetAtxWeightAccount(account string, epoch uint64) (*types.AggregationAtxTotals, error) {
+    return s.atxTotals("coinbase", account, epoch)
+}
+
+func (s *SqlDB) GetAtxWeightNode(node string, epoch uint64) (*types.AggregationAtxTotals, error) {
+    return s.atxTotals("node_id", node, epoch)
+}
+
+func (s *SqlDB) GetAccountAtxList(account string, epoch uint64) ([]*types.AtxDoc, error) {
+    return queryAll(s.db, scanAtx, "SELECT "+atxColumns+" FROM atxs WHERE coinbase = $1 AND publish_epoch = $2", account, epoch)
+}
+
+func (s *SqlDB) GetAccountAtxEpoch(account string, epoch uint64, skip int64, limit int64, sort int8) ([]*types.AtxDoc, error) {
+    filter := (&sqlFilter{}).add("coinbase = ?", account).add("publish_epoch = ?", epoch)
+    return queryAll(s.db, scanAtx,
+        "SELECT "+atxColumns+" FROM atxs"+filter.where()+" ORDER BY received "+sqlOrder(sort)+filter.page(skip, limit),
+        filter.args...)
+}
+
+func (s *SqlDB) CountAccountAtxEpoch(account string, epoch uint64) (int64, error) {
+    count, err := s.count(`SELECT total_atx FROM account_atxs_epochs WHERE coinbase = $1 AND publish_epoch = $2`, account, epoch)
+    if err == sql.ErrNoRows {
+        return 0, nil
+    }
+    return count, err
+}
+
+func (s *SqlDB) FilterAccountAtxNodesForEpoch(account string, epoch uint64, nodes []string) ([]string, error) {
+    results := make([]string, 0)
+    filter := (&sqlFilter{}).add("coinbase = ?", account).add("publish_epoch = ?", epoch).in("node_id", nodes)
+    err := queryEach(s.db, func(row scanner) (*string, error) {
+        var nodeId string
+        err := row.Scan(&nodeId)
+        return &nodeId, err
+    }, func(nodeId *string) error {
+        results = append(results, *nodeId)
+        return nil
+    },
+        "SELECT node_id FROM atxs"+filter.where(), filter.args...)
+    if err != nil {
+        return nil, err
+    }
+    return results, nil
+}
+
+func (s *SqlDB) GetHighestAtx(epoch uint64, excludedNodes []string) (*types.AtxDoc, error) {
+    atxs, err := s.GetHighestAtxs(epoch, excludedNodes, 1)
+    if err != nil {
+        return nil, err
+    }
+    if len(atxs) == 0 {
+        return &types.AtxDoc{}, nil
+    }
+    return atxs[0], nil
+}
+
+func (s *SqlDB) GetHighestAtxs(epoch uint64, excludedNodes []string, limit int64) ([]*types.AtxDoc, error) {
+    filter := (&sqlFilter{}).add("publish_epoch = ?", epoch).notIn("node_id", excludedNodes)
+    return queryAll(s.db, scanAtx,
+        "SELECT "+atxColumns+" FROM atxs"+filter.where()+" ORDER BY base_tick + tick_count DESC, id"+filter.page(0, limit),
+        filter.args...)
+}
+
+func (s *SqlDB) GetAtxForEpochPaginated(epoch uint64, sortField string, skip int64, limit int64, sort int8) ([]*types.AtxDoc, error) {
+    column, ok := atxSortColumns[sortField]
+    if !ok {
+        return nil, fmt.Errorf("unknown atx sort field %s", sortField)
+    }
+    filter := (&sqlFilter{}).add("publish_epoch = ?", epoch)
+    return queryAll(s.db, scanAtx,
+        "SELECT "+atxColumns+" FROM atxs"+filter.where()+" ORDER BY "+column+" "+sqlOrder(sort)+", id"+filter.page(skip, limit),
+        filter.args...)
+}
+
+func (s *SqlDB) GetAtx(atxId string) (*types.AtxDoc, error) {
+    atxs, err := queryAll(s.db, scanAtx, "SELECT "+atxColumns+" FROM atxs WHERE id = $1", atxId)
+    if err != nil {
+        return &types.AtxDoc{}, err
+    }
+    if len(atxs) == 0 {
+        return &types.AtxDoc{}, nil
+    }
+    return atxs[0], nil
+}
+
+func (s *SqlDB) GetPreviousAtx(nodeId string, epoch uint32) (*types.AtxDoc, error) {
+    atxs, err := queryAll(s.db, scanAtx,
+        "SELECT "+atxColumns+" FROM atxs WHERE node_id = $1 AND publish_epoch < $2 ORDER BY publish_epoch DESC LIMIT 1",
+        nodeId, epoch)
+    if err != nil {
+        return &types.AtxDoc{}, err
+    }
+    if len(atxs) == 0 {
+        return &types.AtxDoc{}, nil
+    }
+    return atxs[0], nil
+}
+
+func (s *SqlDB) GetNodeAtxs(nodeId string, skip int64, limit int64, sort int8) ([]*types.AtxDoc, error) {
+    filter := (&sqlFilter{}).add("node_id = ?", nodeId)
+    return queryAll(s.db, scanAtx,
+        "SELECT "+atxColumns+" FROM atxs"+filter.where()+" ORDER BY publish_epoch "+sqlOrder(sort)+filter.page(skip, limit),
+        filter.args...)
+}
+
+func (s *SqlDB) GetNodeAtxsAfter(nodeId string, after *Cursor, limit int64, sort int8) ([]*types.AtxDoc, error) {
+    filter := (&sqlFilter{}).add("node_id = ?", nodeId).after("publish_epoch", after, sort)
+    return queryAll(s.db, scanAtx,
+        "SELECT "+atxColumns+" FROM atxs"+filter.where()+cursorOrder("publish_epoch", sort)+filter.page(0, limit),
+        filter.args...)
+}
+
+func (s *SqlDB) CountNodeAtxs(nodeId string) (int64, error) {
+    return s.count(`SELECT COUNT(*) FROM atxs WHERE node_id = $1`, nodeId)
+}
+
+func (s *SqlDB) GetAtxEpoch(epoch uint64) (*types.AtxEpochDoc, error) {
+    doc := &types.AtxEpochDoc{}
+    err := s.db.QueryRow(
+        `SELECT epoch, total_effective_num_units, total_weight, total_atx, highest_atx, highest_tick, highest_node
+        FROM atxs_epochs WHERE epoch = $1`,
+        epoch,
+    ).Scan(&doc.ID, &doc.TotalEffectiveNumUnits, &doc.TotalWeight, &doc.TotalAtx, &doc.HighestAtx, &doc.HighestTick, &doc.HighestNode)
+    if err != nil && err != sql.ErrNoRows {
+        return nil, err
+    }
+    return doc, nil
+}
+
+func (s *SqlDB) GetNetworkInfo() (*types.NetworkInfoDoc, error) {
+    doc := &types.NetworkInfoDoc{}
+    err := s.db.QueryRow(
+        `SELECT id, circulating_supply, issued_subsidy, fees_paid FROM network_info WHERE id = 'info'`,
+    ).Scan(&doc.Id, &doc.CirculatingSupply, &doc.IssuedSubsidy, &doc.FeesPaid)
+    return doc, err
+}
+
+func (s *SqlDB) GetProcessedsLayers(skip int64, limit int64, sort int8) ([]*types.LayerDoc, error) {
+    filter := (&sqlFilter{}).add("status = ?", LayerStatusApplied)
+    return queryAll(s.db, scanLayer,
+        "SELECT id, status FROM layers"+filter.where()+" ORDER BY id "+sqlOrder(sort)+filter.page(skip, limit),
+        filter.args...)
+}
+
+func (s *SqlDB) GetLayersBetween(from uint32, to uint32) ([]*types.LayerDoc, error) {
+    return queryAll(s.db, scanLayer, `SELECT id, status FROM layers WHERE id >= $1 AND id <= $2 ORDER BY id`, from, to)
+}
+
+func (s *SqlDB) GetRewardedLayers(from uint32, to uint32) ([]uint32, error) {
+    layers, err := queryAll(s.db, func(row scanner) (*uint32, error) {
+        var layer uint32
+        err := row.Scan(&layer)
+        return &layer, err
+    }, `SELECT DISTINCT layer FROM rewards WHERE layer >= $1 AND layer <= $2 ORDER BY layer`, from, to)
+    if err != nil {
+        return nil, err
+    }
+    rewarded := make([]uint32, len(layers))
+    for i, layer := range layers {
+        rewarded[i] = *layer
+    }
+    return rewarded, nil
+}
+
+func (s *SqlDB) GetLayersRewards(from uint32, to uint32) ([]*types.LayerRewardsDoc, error) {
+    return queryAll(s.db, func(row scanner) (*types.LayerRewardsDoc, error) {
+        doc := &types.LayerRewardsDoc{}
+        err := row.Scan(&doc.Layer, &doc.Count, &doc.TotalReward)
+        return doc, err
+    }, `SELECT layer, COUNT(*), COALESCE(SUM(total_reward), 0) FROM rewards WHERE layer >= $1 AND layer <= $2 GROUP BY layer ORDER BY layer`, from, to)
+}
+
+func (s *SqlDB) GetPoolRewards(fromEpoch uint32, toEpoch uint32) ([]*types.PoolNodeRewardsDoc, error) {
+    return queryAll(s.db, func(row scanner) (*types.PoolNodeRewardsDoc, error) {
+        doc := &types.PoolNodeRewardsDoc{}
+        err := row.Scan(&doc.Id.Epoch, &doc.Id.NodeId, &doc.Count, &doc.TotalReward)
+        return doc, err
+    }, `SELECT epoch, node_id, COUNT(*), COALESCE(SUM(total_reward), 0) FROM pool_rewards WHERE epoch >= $1 AND epoch <= $2
+        GROUP BY epoch, node_id ORDER BY epoch, node_id`, fromEpoch, toEpoch)
+}
+
+func (s *SqlDB) GetCoinbasesRewards(firstLayer uint32, lastLayer uint32) ([]*types.CoinbaseRewardsDoc, error) {
+    return queryAll(s.db, func(row scanner) (*types.CoinbaseRewardsDoc, error) {
+        doc := &types.CoinbaseRewardsDoc{}
+        err := row.Scan(&doc.Coinbase, &doc.Count, &doc.TotalReward)
+        return doc, err
+    }, `SELECT coinbase, COUNT(*), COALESCE(SUM(total_reward), 0) FROM rewards WHERE layer >= $1 AND layer <= $2 GROUP BY coinbase`,
+        firstLayer, lastLayer)
+}
+
+const rewardDigestColumns = "coinbase, day, count, rewards, usd_price, usd_value, complete"
+
+func scanRewardDigest(row scanner) (*types.RewardDigestDoc, error) {
+    doc := &types.RewardDigestDoc{}
+    err := row.Scan(&doc.Id.Coinbase, &doc.Id.Day, &doc.Count, &doc.Rewards, &doc.USDPrice, &doc.USDValue, &doc.Complete)
+    return doc, err
+}
+
+func (s *SqlDB) GetRewardDigests(coinbase string, from int64, to int64) ([]*types.RewardDigestDoc, error) {
+    return queryAll(s.db, scanRewardDigest,
+        "SELECT "+rewardDigestColumns+" FROM reward_digests WHERE coinbase = $1 AND day >= $2 AND day <= $3 ORDER BY day",
+        coinbase, from, to)
+}
+
+func (s *SqlDB) GetLastRewardDigest() (*types.RewardDigestDoc, error) {
+    doc, err := scanRewardDigest(s.db.QueryRow("SELECT " + rewardDigestColumns + " FROM reward_digests ORDER BY day DESC LIMIT 1"))
+    if err == sql.ErrNoRows {
+        return &types.RewardDigestDoc{}, nil
+    }
+    return doc, err
+}
+
+func (s *SqlDB) GetLastProcessedLayer() (*types.LayerDoc, error) {
+    doc, err := scanLayer(s.db.QueryRow(`SELECT id, status FROM layers WHERE status = $1 ORDER BY id DESC LIMIT 1`, LayerStatusApplied))
+    if err == sql.ErrNoRows {
+        return &types.LayerDoc{}, nil
+    }
+    return doc, err
+}
+
+func (s *SqlDB) GetReorgs(skip int64, limit int64, sort int8) ([]*types.ReorgDoc, error) {
+    filter := &sqlFilter{}
+    return queryAll(s.db, func(row scanner) (*types.ReorgDoc, error) {
+        doc := &types.ReorgDoc{}
+        err := row.Scan(&doc.TriggerLayer, &doc.LastAppliedLayer, &doc.Depth, &doc.AffectedDocuments, &doc.Timestamp)
+        return doc, err
+    },
+        "SELECT trigger_layer, last_applied_layer, depth, affected_documents, timestamp FROM reorgs ORDER BY timestamp "+
+            sqlOrder(sort)+filter.page(skip, limit),
+        filter.args...)
+}
+
+func (s *SqlDB) CountReorgs() (int64, error) {
+    return s.count(`SELECT COUNT(*) FROM reorgs`)
+}
+
+func (s *SqlDB) GetTopSmeshers(sortField string, skip int64, limit int64) ([]*types.SmesherDoc, error) {
+    column, ok := smesherSortColumns[sortField]
+    if !ok {
+        return nil, fmt.Errorf("unknown smeshers sort field %s", sortField)
+    }
+    filter := &sqlFilter{}
+    return queryAll(s.db, scanSmesher,
+        "SELECT "+smesherColumns+" FROM smeshers ORDER BY "+column+" DESC, id"+filter.page(skip, limit),
+        filter.args...)
+}
+
+func (s *SqlDB) GetTopSmeshersEpoch(epoch uint32, skip int64, limit int64) ([]*types.SmesherEpochDoc, error) {
+    filter := (&sqlFilter{}).add("epoch = ?", epoch)
+    return queryAll(s.db, func(row scanner) (*types.SmesherEpochDoc, error) {
+        doc := &types.SmesherEpochDoc{}
+        err := row.Scan(&doc.Id.NodeId, &doc.Id.Epoch, &doc.Coinbase, &doc.Rewards, &doc.RewardsCount)
+        return doc, err
+    },
+        "SELECT node_id, epoch, coinbase, rewards, rewards_count FROM smeshers_epochs"+filter.where()+
+            " ORDER BY rewards DESC, node_id"+filter.page(skip, limit),
+        filter.args...)
+}
+
+func (s *SqlDB) GetSmesherPerformance(nodeId string, skip int64, limit int64) ([]*types.SmesherPerformanceDoc, error) {
+    filter := (&sqlFilter{}).add("node_id = ?", nodeId)
+    return queryAll(s.db, func(row scanner) (*types.SmesherPerformanceDoc, error) {
+        doc := &types.SmesherPerformanceDoc{}
+        err := row.Scan(&doc.Id.NodeId, &doc.Id.Epoch, &doc.Coinbase, &doc.Weight, &doc.Slots,
+            &doc.ExpectedRewards, &doc.Rewards, &doc.RewardsCount, &doc.Complete)
+        return doc, err
+    },
+        "SELECT node_id, epoch, coinbase, weight, slots, expected_rewards, rewards, rewards_count, complete FROM smeshers_performance"+
+            filter.where()+" ORDER BY epoch DESC"+filter.page(skip, limit),
+        filter.args...)
+}
+
+func (s *SqlDB) CountSmesherPerformance(nodeId string) (int64, error) {
+    return s.count("SELECT COUNT(*) FROM smeshers_performance WHERE node_id = $1", nodeId)
+}
+
+func (s *SqlDB) GetLastSmeshersPerformanceEpoch() (uint32, error) {
+    count, err := s.count("SELECT COALESCE(MAX(epoch), 0) FROM smeshers_performance")
+    return uint32(count), err
+}
+
+func (s *SqlDB) GetEpochPerformance(epoch uint32) (*types.EpochPerformanceDoc, error) {
+    doc := &types.EpochPerformanceDoc{}
+    var incomplete int64
+    err := s.db.QueryRow(
+        `SELECT COUNT(*), COALESCE(SUM(slots), 0), COALESCE(SUM(expected_rewards), 0), COALESCE(SUM(rewards_count), 0),
+            COALESCE(SUM(CASE WHEN complete THEN 0 ELSE 1 END), 0)
+        FROM smeshers_performance WHERE epoch = $1`,
+        int64(epoch),
+    ).Scan(&doc.Smeshers, &doc.Slots, &doc.ExpectedRewards, &doc.RewardsCount, &incomplete)
+    doc.Complete = doc.Smeshers > 0 && incomplete == 0
+    return doc, err
+}
+
+func (s *SqlDB) GetSmeshers(nodeIds []string) ([]*types.SmesherDoc, error) {
+    filter := (&sqlFilter{}).in("id", nodeIds)
+    return queryAll(s.db, scanSmesher, "SELECT "+smesherColumns+" FROM smeshers"+filter.where(), filter.args...)
+}
+
+func (s *SqlDB) GetCoinbaseNodes(coinbase string) ([]string, error) {
+    nodeIds, err := queryAll(s.db, func(row scanner) (*string, error) {
+        var nodeId string
+        err := row.Scan(&nodeId)
+        return &nodeId, err
+    }, `SELECT DISTINCT node_id FROM rewards WHERE coinbase = $1`, coinbase)
+    if err != nil {
+        return nil, err
+    }
+    result := make([]string, len(nodeIds))
+    for i, nodeId := range nodeIds {
+        result[i] = *nodeId
+    }
+    return result, nil
+}
+
+func (s *SqlDB) CountSmeshers() (int64, error) {
+    return s.count(`SELECT COUNT(*) FROM smeshers`)
+}
+
+func (s *SqlDB) CountSmeshersEpoch(epoch uint32) (int64, error) {
+    return s.count(`SELECT COUNT(*) FROM smeshers_epochs WHERE epoch = $1`, epoch)
+}
+
+func (s *SqlDB) GetRewardsRollups(account string, granularity string, from int64, to int64) ([]*types.RewardsRollupDoc, error) {
+    table := "network_rewards_rollups"
+    accountColumn := "''"
+    filter := &sqlFilter{}
+    if account != "" {
+        table = "account_rewards_rollups"
+        accountColumn = "account"
+        filter.add("account = ?", account)
+    }
+    filter.add("granularity = ?", granularity).add("bucket >= ?", from).add("bucket <= ?", to)
+    return queryAll(s.db, scanRewardsRollup,
+        "SELECT "+accountColumn+", granularity, bucket, rewards, rewards_count FROM "+table+filter.where()+" ORDER BY bucket",
+        filter.args...)
+}
+
+func (s *SqlDB) GetLastRewardsRollupEpoch() (uint32, error) {
+    count, err := s.count(`SELECT COALESCE(MAX(bucket), 0) FROM network_rewards_rollups WHERE granularity = $1`, RollupEpoch)
+    return uint32(count), err
+}
+
+func (s *SqlDB) GetPriceAt(timestamp time.Time) (*types.PriceDoc, error) {
+    doc, err := scanPrice(s.db.QueryRow(
+        `SELECT timestamp, usd_price, source FROM prices WHERE timestamp <= $1 ORDER BY timestamp DESC LIMIT 1`,
+        sqlTime(timestamp),
+    ))
+    if err == sql.ErrNoRows {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    return doc, nil
+}
+
+func (s *SqlDB) GetPriceHistory(from time.Time, to time.Time, resolution time.Duration) ([]*types.PriceBucketDoc, error) {
+    return queryAll(s.db, func(row scanner) (*types.PriceBucketDoc, error) {
+        doc := &types.PriceBucketDoc{}
+        err := row.Scan(&doc.Bucket, &doc.USDPrice, &doc.Min, &doc.Max, &doc.Samples)
+        return doc, err
+    },
+        `SELECT `+s.dialect.unixMillis("timestamp")+` / $1 * $1 AS bucket,
+            AVG(usd_price), MIN(usd_price), MAX(usd_price), COUNT(*)
+        FROM prices WHERE timestamp >= $2 AND timestamp <= $3 GROUP BY 1 ORDER BY 1`,
+        resolution.Milliseconds(), sqlTime(from), sqlTime(to))
+}
+
+func (s *SqlDB) GetPrices(from time.Time, to time.Time) ([]*types.PriceDoc, error) {
+    prices, err := queryAll(s.db, scanPrice,
+        `SELECT timestamp, usd_price, source FROM prices WHERE timestamp >= $1 AND timestamp <= $2 ORDER BY timestamp`,
+        sqlTime(from), sqlTime(to))
+    if err != nil {
+        return nil, err
+    }
+
+    previous, err := s.GetPriceAt(from)
+    if err != nil {
+        return nil, err
+    }
+    if previous != nil && (len(prices) == 0 || previous.Timestamp.Before(prices[0].Timestamp)) {
+        prices = append([]*types.PriceDoc{previous}, prices...)
+    }
+    return prices, nil
+}
+
+func (s *SqlDB) GetChanges(since int64, limit int64, settle time.Duration) ([]*types.ChangeDoc, error) {
+    return nil, ErrNotSupported
+}
+
+func (s *SqlDB) GetCollectionSizes() (map[string]int64, error) {
+    sizes := make(map[string]int64, len(StatsCollections))
+    for _, name := range StatsCollections {
+        query, args := s.dialect.tableRows(sqlTables[name])
+        count, err := s.count(query, args...)
+        if err != nil {
+            return nil, err
+        }
+        sizes[name] = count
+    }
+    return sizes, nil
+}
+
+func (s *SqlDB) GetStats(from time.Time, to time.Time, limit int64) ([]*types.StatsDoc, error) {
+    return queryAll(s.db, func(row scanner) (*types.StatsDoc, error) {
+        doc := &types.StatsDoc{}
+        var collections, ingestRates []byte
+        if err := row.Scan(&doc.Timestamp, &collections, &ingestRates, &doc.ApiQps); err != nil {
+            return nil, err
+        }
+        if err := json.Unmarshal(collections, &doc.Collections); err != nil {
+            return nil, err
+        }
+        return doc, json.Unmarshal(ingestRates, &doc.IngestRates)
+    },
+        `SELECT timestamp, collections, ingest_rates, api_qps FROM stats
+        WHERE timestamp >= $1 AND timestamp <= $2 ORDER BY timestamp LIMIT $3`,
+        sqlTime(from), sqlTime(to), limit)
+}
+
+func (s *SqlDB) GetNetworkSnapshots(from time.Time, to time.Time) ([]*types.NetworkSnapshotDoc, error) {
+    return queryAll(s.db, func(row scanner) (*types.NetworkSnapshotDoc, error) {
+        doc := &types.NetworkSnapshotDoc{}
+        err := row.Scan(&doc.Timestamp, &doc.Epoch, &doc.Layer, &doc.TotalWeight, &doc.ActiveSmeshers,
+            &doc.TotalAccounts, &doc.CirculatingSupply, &doc.Price)
+        return doc, err
+    },
+        `SELECT timestamp, epoch, layer, total_weight, active_smeshers, total_accounts, circulating_supply, price
+        FROM network_history WHERE timestamp >= $1 AND timestamp <= $2 ORDER BY timestamp`,
+        sqlTime(from), sqlTime(to))
+}
+
+func (s *SqlDB) GetNetworkInfoHistory(from uint64, to uint64) ([]*types.NetworkInfoHistoryDoc, error) {
+    return queryAll(s.db, func(row scanner) (*types.NetworkInfoHistoryDoc, error) {
+        doc := &types.NetworkInfoHistoryDoc{}
+        err := row.Scan(&doc.Layer, &doc.Epoch, &doc.CreatedAt, &doc.TotalWeight, &doc.TotalSlots, &doc.EffectiveUnitsCommited,
+            &doc.ActiveSmeshers, &doc.TotalAccounts, &doc.CirculatingSupply, &doc.Price, &doc.NextEpochWeight,
+            &doc.NextEpochActiveSmeshers, &doc.NextEpochEffectiveUnits)
+        return doc, err
+    },
+        `SELECT layer, epoch, created_at, total_weight, total_slots, effective_units, active_smeshers, total_accounts,
+            circulating_supply, price, next_epoch_weight, next_epoch_active_smeshers, next_epoch_effective_units
+        FROM network_info_history WHERE layer >= $1 AND layer <= $2 ORDER BY layer`,
+        from, to)
+}
+
+// AccountActivityReady reports if backfillAccountActivity ran.
+func (s *SqlDB) AccountActivityReady() (bool, error) {
+    if s.activityReady.Load() {
+        return true, nil
+    }
+    var count int64
+    err := s.db.QueryRow(`SELECT COUNT(*) FROM backfills WHERE id = $1`, accountActivityBackfillId).Scan(&count)
+    if err != nil {
+        return false, err
+    }
+    s.activityReady.Store(count > 0)
+    return count > 0, nil
+}
+
+func (s *SqlDB) GetStreamCheckpoints() ([]*types.StreamCheckpointDoc, error) {
+    return queryAll(s.db, func(row scanner) (*types.StreamCheckpointDoc, error) {
+        doc := &types.StreamCheckpointDoc{}
+        var instance sql.NullString
+        var generation sql.NullInt64
+        err := row.Scan(&doc.Consumer, &doc.Stream, &doc.Sequence, &doc.Layer, &doc.UpdatedAt, &instance, &generation)
+        doc.WriterInstance = instance.String
+        doc.WriterGeneration = generation.Int64
+        return doc, err
+    },
+        `SELECT consumer, stream, sequence, layer, updated_at, writer_instance, writer_generation FROM stream_checkpoints ORDER BY consumer`)
+}
+
+func (s *SqlDB) GetPoetsHealth() ([]*types.PoetHealthDoc, error) {
+    return queryAll(s.db, func(row scanner) (*types.PoetHealthDoc, error) {
+        doc := &types.PoetHealthDoc{}
+        err := row.Scan(&doc.Name, &doc.Address, &doc.Up, &doc.CurrentRound, &doc.Error, &doc.Since, &doc.CheckedAt)
+        return doc, err
+    },
+        `SELECT name, address, up, current_round, error, since, checked_at FROM poet_health ORDER BY name`)
+}
+
+const feesRollupColumns = "granularity, bucket, transactions, gas, fees, min_fee, max_fee, median_fee, min_gas_price, max_gas_price, fees_rewarded"
+
+func scanFeesRollup(row scanner) (*types.FeesRollupDoc, error) {
+    doc := &types.FeesRollupDoc{}
+    err := row.Scan(&doc.Id.Granularity, &doc.Id.Bucket, &doc.Transactions, &doc.Gas, &doc.Fees, &doc.MinFee, &doc.MaxFee,
+        &doc.MedianFee, &doc.MinGasPrice, &doc.MaxGasPrice, &doc.FeesRewarded)
+    return doc, err
+}
+
+func (s *SqlDB) GetFeesRollups(granularity string, from int64, to int64) ([]*types.FeesRollupDoc, error) {
+    return queryAll(s.db, scanFeesRollup,
+        "SELECT "+feesRollupColumns+" FROM fees_rollups WHERE granularity = $1 AND bucket >= $2 AND bucket <= $3 ORDER BY bucket",
+        granularity, from, to)
+}
+
+func (s *SqlDB) GetLastFeesRollupEpoch() (uint32, error) {
+    count, err := s.count(`SELECT COALESCE(MAX(bucket), 0) FROM fees_rollups WHERE granularity = $1`, RollupEpoch)
+    return uint32(count), err
+}
+
+func (s *SqlDB) GetRecentGas(fromLayer uint32, method int, limit int64) ([]*types.TransactionDoc, error) {
+    filter := (&sqlFilter{}).add("complete = ?", true).add("layer >= ?", fromLayer)
+    if method > -1 {
+        filter.add("method = ?", method)
+    }
+    return queryAll(s.db, func(row scanner) (*types.TransactionDoc, error) {
+        doc := &types.TransactionDoc{}
+        err := row.Scan(&doc.Gas, &doc.GasPrice)
+        return doc, err
+    },
+        "SELECT gas, gas_price FROM transactions"+filter.where()+" ORDER BY layer DESC"+filter.page(0, limit), filter.args...)
+}
+
+func (s *SqlDB) GetVault(address string) (*types.VaultDoc, error) {
+    doc := &types.VaultDoc{}
+    err := s.db.QueryRow(
+        `SELECT address, owner, total_amount, initial_unlock_amount, vesting_start, vesting_end, spawn_transaction, spawn_layer
+        FROM vaults WHERE address = $1`, address,
+    ).Scan(&doc.Address, &doc.Owner, &doc.TotalAmount, &doc.InitialUnlockAmount, &doc.VestingStart, &doc.VestingEnd,
+        &doc.SpawnTransaction, &doc.SpawnLayer)
+    if err == sql.ErrNoRows {
+        return &types.VaultDoc{}, nil
+    }
+    return doc, err
+}
+
+func (s *SqlDB) GetVaultDrained(address string) (uint64, error) {
+    drained, err := s.count(
+        `SELECT COALESCE(SUM(amount), 0) FROM transactions WHERE vault_account = $1 AND type = $2 AND complete = TRUE AND status = $3`,
+        address, transactionparsertypes.TypeDrainVault, uint8(sTypes.TransactionSuccess),
+    )
+    return uint64(drained), err
+}
+
+func (s *SqlDB) GetMultisig(address string) (*types.MultisigDoc, error) {
+    doc := &types.MultisigDoc{}
+    var publicKeys []byte
+    err := s.db.QueryRow(
+        `SELECT address, template, required, public_keys, spawn_transaction, spawn_layer
+        FROM multisigs WHERE address = $1`, address,
+    ).Scan(&doc.Address, &doc.Template, &doc.Required, &publicKeys, &doc.SpawnTransaction, &doc.SpawnLayer)
+    if err == sql.ErrNoRows {
+        return &types.MultisigDoc{}, nil
+    }
+    if err != nil {
+        return doc, err
+    }
+    return doc, json.Unmarshal(publicKeys, &doc.PublicKeys)
+}
+
+func (s *SqlDB) GetMultisigSignatures(address string, offset int64, limit int64) ([]*types.MultisigSignaturesDoc, error) {
+    filter := &sqlFilter{}
+    filter.add("account = ?", address)
+    return queryAll(s.db, func(row scanner) (*types.MultisigSignaturesDoc, error) {
+        doc := &types.MultisigSignaturesDoc{}
+        var refs []byte
+        if err := row.Scan(&doc.Transaction, &doc.Account, &doc.Layer, &doc.Status, &doc.Type, &refs); err != nil {
+            return nil, err
+        }
+        return doc, json.Unmarshal(refs, &doc.Refs)
+    },
+        "SELECT transaction_id, account, layer, status, type, refs FROM multisig_signatures"+filter.where()+
+            " ORDER BY layer DESC"+filter.page(offset, limit), filter.args...)
+}
+
+func (s *SqlDB) CountMultisigSignatures(address string) (int64, error) {
+    return s.count("SELECT COUNT(*) FROM multisig_signatures WHERE account = $1", address)
+}
+
+func (s *SqlDB) GetBalanceChanges(account string) ([]*types.BalanceChangeDoc, error) {
+    return queryAll(s.db, func(row scanner) (*types.BalanceChangeDoc, error) {
+        doc := &types.BalanceChangeDoc{}
+        err := row.Scan(&doc.Id.Account, &doc.Id.Layer, &doc.Delta)
+        return doc, err
+    }, "SELECT account, layer, delta FROM balance_changes WHERE account = $1 ORDER BY layer", account)
+}
+
+func scanWebhook(row scanner) (*types.WebhookDoc, error) {
+    doc := &types.WebhookDoc{}
+    var addresses, events []byte
+    err := row.Scan(&doc.ID, &doc.Owner, &doc.Url, &addresses, &events, &doc.Secret, &doc.CreatedAt)
+    if err != nil {
+        return nil, err
+    }
+    if err = json.Unmarshal(addresses, &doc.Addresses); err != nil {
+        return nil, err
+    }
+    return doc, json.Unmarshal(events, &doc.Events)
+}
+
+func (s *SqlDB) GetWebhooks(owner string) ([]*types.WebhookDoc, error) {
+    filter := &sqlFilter{}
+    if owner != "" {
+        filter.add("owner = ?", owner)
+    }
+    return queryAll(s.db, scanWebhook,
+        "SELECT id, owner, url, addresses, events, secret, created_at FROM webhooks"+filter.where()+" ORDER BY created_at",
+        filter.args...)
+}
+
+func (s *SqlDB) GetWebhook(id string) (*types.WebhookDoc, error) {
+    doc, err := scanWebhook(s.db.QueryRow(
+        `SELECT id, owner, url, addresses, events, secret, created_at FROM webhooks WHERE id = $1`, id,
+    ))
+    if err == sql.ErrNoRows {
+        return &types.WebhookDoc{}, nil
+    }
+    return doc, err
+}
+
+func (s *SqlDB) GetWatchlistItems(owner string) ([]*types.WatchlistItemDoc, error) {
+    return queryAll(s.db, func(row scanner) (*types.WatchlistItemDoc, error) {
+        doc := &types.WatchlistItemDoc{}
+        err := row.Scan(&doc.ID, &doc.Owner, &doc.Kind, &doc.Item, &doc.CreatedAt)
+        return doc, err
+    }, "SELECT id, owner, kind, item, created_at FROM watchlist_items WHERE owner = $1 ORDER BY created_at, id", owner)
+}
+
+func (s *SqlDB) GetEmailSubscriptions(owner string) ([]*types.EmailSubscriptionDoc, error) {
+    filter := &sqlFilter{}
+    if owner != "" {
+        filter.add("owner = ?", owner)
+    }
+    return queryAll(s.db, func(row scanner) (*types.EmailSubscriptionDoc, error) {
+        doc := &types.EmailSubscriptionDoc{}
+        err := row.Scan(&doc.ID, &doc.Owner, &doc.Email, &doc.Frequency, &doc.Token, &doc.CreatedAt, &doc.LastDay,
+            &doc.Pending, &doc.Slot)
+        return doc, err
+    }, "SELECT id, owner, email, frequency, token, created_at, last_day, pending, slot FROM email_subscriptions"+filter.where()+" ORDER BY created_at, id",
+        filter.args...)
+}
+
+func scanWebhookDelivery(row scanner) (*types.WebhookDeliveryDoc, error) {
+    doc := &types.WebhookDeliveryDoc{}
+    err := row.Scan(&doc.ID, &doc.Webhook, &doc.Event, &doc.Payload, &doc.Status, &doc.Attempts,
+        &doc.NextAttempt, &doc.LastStatus, &doc.LastError, &doc.CreatedAt, &doc.DeliveredAt)
+    return doc, err
+}
+
+func (s *SqlDB) GetWebhookDeliveries(webhook string, skip int64, limit int64) ([]*types.WebhookDeliveryDoc, error) {
+    filter := &sqlFilter{}
+    filter.add("webhook = ?", webhook)
+    return queryAll(s.db, scanWebhookDelivery,
+        "SELECT "+webhookDeliveryColumns+" FROM webhook_deliveries"+filter.where()+" ORDER BY created_at DESC"+filter.page(skip, limit),
+        filter.args...)
+}
+
+func (s *SqlDB) CountWebhookDeliveries(webhook string) (int64, error) {
+    return s.count("SELECT COUNT(*) FROM webhook_deliveries WHERE webhook = $1", webhook)
+}
+
+func scanBlock(row scanner) (*types.BlockDoc, error) {
+    doc := &types.BlockDoc{}
+    err := row.Scan(&doc.ID, &doc.Layer, &doc.Transactions)
+    return doc, err
+}
+
+func (s *SqlDB) GetBlock(blockId string) (*types.BlockDoc, error) {
+    doc, err := scanBlock(s.db.QueryRow("SELECT id, layer, transactions FROM blocks WHERE id = $1", blockId))
+    if err == sql.ErrNoRows {
+        return &types.BlockDoc{}, nil
+    }
+    return doc, err
+}
+
+func (s *SqlDB) GetLayerBlocks(layer int) ([]*types.BlockDoc, error) {
+    return queryAll(s.db, scanBlock, "SELECT id, layer, transactions FROM blocks WHERE layer = $1 ORDER BY id", layer)
+}
+
+func (s *SqlDB) StreamRewards(account string, sort int8, firstLayer int, lastLayer int, each func(*types.RewardsDoc) error) error {
+    filter := accountRewardsSqlFilter(account, firstLayer, lastLayer)
+    return queryEach(s.db, scanReward, each,
+        "SELECT "+rewardColumns+" FROM rewards"+filter.where()+" ORDER BY layer "+sqlOrder(sort), filter.args...)
+}
+
+func (s *SqlDB) StreamLayerRewards(layer int, sort int8, each func(*types.RewardsDoc) error) error {
+    return queryEach(s.db, scanReward, each,
+        "SELECT "+rewardColumns+" FROM rewards WHERE layer = $1 ORDER BY layer "+sqlOrder(sort), layer)
+}
+
+func (s *SqlDB) StreamNodeRewards(node string, sort int8, each func(*types.RewardsDoc) error) error {
+    return queryEach(s.db, scanReward, each,
+        "SELECT "+rewardColumns+" FROM rewards WHERE node_id = $1 ORDER BY layer "+sqlOrder(sort), node)
+}
+
+func (s *SqlDB) StreamTransactions(account string, sort int8, state string, each func(*types.TransactionDoc) error) error {
+    filter := (&sqlFilter{}).add("(principal_account = ? OR receiver_account = ?)", account).state(state)
+    return queryEach(s.db, scanTransaction, each,
+        "SELECT "+transactionColumns+" FROM transactions"+filter.where()+" ORDER BY layer "+sqlOrder(sort), filter.args...)
+}
+
+func (s *SqlDB) StreamLayerTransactions(layer int, sort int8, state string, each func(*types.TransactionDoc) error) error {
+    filter := (&sqlFilter{}).add("layer = ?", layer).state(state)
+    return queryEach(s.db, scanTransaction, each,
+        "SELECT "+transactionColumns+" FROM transactions"+filter.where()+" ORDER BY layer "+sqlOrder(sort), filter.args...)
+}
+
+func (s *SqlDB) StreamAllTransactions(sort int8, transactionsFilter *TransactionsFilter, each func(*types.TransactionDoc) error) error {
+    filter := allTransactionsSqlFilter(transactionsFilter)
+    return queryEach(s.db, scanTransaction, each,
+        "SELECT "+transactionColumns+" FROM transactions"+filter.where()+" ORDER BY layer "+sqlOrder(sort), filter.args...)
+}
+
+func (s *SqlDB) StreamAccounts(sort int8, each func(*types.AccountDoc) error) error {
+    return queryEach(s.db, scanAccount, each, "SELECT "+accountColumns+" FROM accounts ORDER BY balance "+sqlOrder(sort))
+}
+
+func (s *SqlDB) StreamAtxForEpoch(epoch uint64, sort int8, each func(*types.AtxDoc) error) error {
+    return queryEach(s.db, scanAtx, each,
+        "SELECT "+atxColumns+" FROM atxs WHERE publish_epoch = $1 ORDER BY effective_num_units "+sqlOrder(sort), epoch)
+}
+
+func (s *SqlDB) StreamAccountAtxEpoch(account string, epoch uint64, sort int8, each func(*types.AtxDoc) error) error {
+    return queryEach(s.db, scanAtx, each,
+        "SELECT "+atxColumns+" FROM atxs WHERE coinbase = $1 AND publish_epoch = $2 ORDER BY received "+sqlOrder(sort),
+        account, epoch)
+}
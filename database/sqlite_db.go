@@ -0,0 +1,69 @@
+package database
+
+import (
+    "database/sql"
+    "regexp"
+    "strings"
+
+    _ "modernc.org/sqlite"
+)
+
+// sqlitePlaceholder matches the postgres placeholders, sqlite numbers $1 by first use
+// and ?1 by its number.
+var sqlitePlaceholder = regexp.MustCompile(`\$([0-9]+)`)
+
+var sqliteDialect = &sqlDialect{
+    name: BackendSqlite,
+    rebind: func(query string) string {
+        return sqlitePlaceholder.ReplaceAllString(query, "?$1")
+    },
+    // transactions take the write lock when they begin, rows can not change under them
+    shareLock: "",
+    unixMillis: func(column string) string {
+        return "CAST(strftime('%s', " + column + ") AS INTEGER) * 1000"
+    },
+    // a local file is small enough for exact counts
+    tableRows: func(table string) (string, []interface{}) {
+        return "SELECT COUNT(*) FROM " + table, nil
+    },
+    // sqlite can not add a column only if it is missing
+    existingColumn: func(err error) bool {
+        return strings.Contains(err.Error(), "duplicate column name")
+    },
+    // a single transaction writes at a time, parallel saves would only wait for the lock
+    capabilities: Capabilities{},
+}
+
+// sqliteTypes swaps the postgres types sqlite does not know. TIMESTAMP is the declared
+// type the driver reads back as time.Time.
+var sqliteTypes = strings.NewReplacer(
+    "BIGSERIAL PRIMARY KEY", "INTEGER PRIMARY KEY AUTOINCREMENT",
+    "TIMESTAMPTZ", "TIMESTAMP",
+    "JSONB", "TEXT",
+    "BYTEA", "BLOB",
+    "ADD COLUMN IF NOT EXISTS", "ADD COLUMN",
+)
+
+// sqliteOptions enable concurrent readers while the sink writes, make writers wait for
+// each other instead of failing and store times in the format the date functions read.
+const sqliteOptions = "_pragma=journal_mode(WAL)&_pragma=busy_timeout(10000)&_txlock=immediate&_time_format=sqlite"
+
+// NewSqliteDB opens or creates the database file at path, for single node deployments
+// that do not want to run a database server.
+func NewSqliteDB(path string) (*SqlDB, error) {
+    separator := "?"
+    if strings.Contains(path, "?") {
+        separator = "&"
+    }
+    db, err := sql.Open("sqlite", path+separator+sqliteOptions)
+    if err != nil {
+        return nil, err
+    }
+    db.SetMaxOpenConns(10)
+
+    schema := make([]string, len(postgresSchema))
+    for i, statement := range postgresSchema {
+        schema[i] = sqliteTypes.Replace(statement)
+    }
+    return newSqlDB(db, sqliteDialect, schema)
+}
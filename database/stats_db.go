@@ -0,0 +1,84 @@
+package database
+
+import (
+    "context"
+    "time"
+
+    "github.com/swarmbit/spacemesh-state-api/types"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const statsCollection = "stats"
+
+// StatsCollections are the collections whose sizes are tracked in stats snapshots.
+var StatsCollections = []string{
+    rewardsCollection,
+    layersCollection,
+    atxsCollection,
+    nodesCollection,
+    accountsCollection,
+    transactionsCollection,
+    smeshersCollection,
+}
+
+// EnableStatsRetention drops stats snapshots older than retention.
+func (m *WriteDB) EnableStatsRetention(retention time.Duration) error {
+    statsColl := m.client.Database(database).Collection(statsCollection)
+    _, err := statsColl.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+        Keys:    bson.D{{Key: "timestamp", Value: 1}},
+        Options: options.Index().SetExpireAfterSeconds(int32(retention.Seconds())),
+    })
+    return err
+}
+
+func (m *WriteDB) SaveStats(stats *types.StatsDoc) error {
+    if m.Fenced() {
+        return ErrFenced
+    }
+    statsColl := m.client.Database(database).Collection(statsCollection)
+    _, err := statsColl.InsertOne(context.TODO(), stats)
+    return err
+}
+
+func (m *ReadDB) GetCollectionSizes() (map[string]int64, error) {
+    sizes := make(map[string]int64, len(StatsCollections))
+    for _, name := range StatsCollections {
+        coll := m.client.Database(database).Collection(name)
+        count, err := coll.EstimatedDocumentCount(context.TODO())
+        if err != nil {
+            return nil, err
+        }
+        sizes[name] = count
+    }
+    return sizes, nil
+}
+
+func (m *ReadDB) GetStats(from time.Time, to time.Time, limit int64) ([]*types.StatsDoc, error) {
+    statsColl := m.client.Database(database).Collection(statsCollection)
+
+    findOptions := options.Find()
+    findOptions.SetLimit(limit)
+    findOptions.SetSort(bson.M{"timestamp": 1})
+
+    ctx := context.TODO()
+    cursor, err := statsColl.Find(
+        ctx,
+        bson.D{{Key: "timestamp", Value: bson.D{
+            {Key: "$gte", Value: from},
+            {Key: "$lte", Value: to},
+        }}},
+        findOptions,
+    )
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    var stats []*types.StatsDoc
+    if err = cursor.All(ctx, &stats); err != nil {
+        return nil, err
+    }
+    return stats, nil
+}
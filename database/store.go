@@ -0,0 +1,349 @@
+package database
+
+import (
+    "context"
+    "fmt"
+    "time"
+
+    "github.com/spacemeshos/go-spacemesh/nats"
+    "github.com/swarmbit/spacemesh-state-api/pkg/apperror"
+    "github.com/swarmbit/spacemesh-state-api/config"
+    "github.com/swarmbit/spacemesh-state-api/types"
+)
+
+const (
+    BackendMongo    = "mongo"
+    BackendPostgres = "postgres"
+    BackendSqlite   = "sqlite"
+)
+
+// ErrNotSupported is returned by backends for features they do not implement.
+var ErrNotSupported error = apperror.New(apperror.NotSupported, "not supported by the storage backend")
+
+// Capabilities lists the optional features of a backend. Callers check them before
+// enabling a feature instead of failing on ErrNotSupported.
+type Capabilities struct {
+    // ChangeFeed backs the sync endpoint
+    ChangeFeed bool
+    // SchemaMigrations is set when versioned migrations are recorded, sql backends only
+    // create missing tables and indexes when opened
+    SchemaMigrations bool
+    // Rebuilds is set when RebuildAggregate can recompute the derived collections
+    Rebuilds bool
+    // DocumentUpgrades is set when UpgradeDocuments stores the current format of
+    // versioned documents
+    DocumentUpgrades bool
+    // ParallelSaves is set when events of one kind can be saved concurrently, mongo
+    // transactions of the same kind conflict on the totals they all increment
+    ParallelSaves bool
+}
+
+// TransactionsFilter selects the transactions of every account in State. Method, MinAmount,
+// FirstLayer and LastLayer are not applied when -1. Receivers selects the transactions to
+// any of the accounts, like deposits, none are applied when empty.
+type TransactionsFilter struct {
+    State      string
+    Method     int
+    MinAmount  int
+    Receivers  []string
+    FirstLayer int
+    LastLayer  int
+}
+
+// WriteStore is what the sink, the aggregators and the price resolver write through.
+type WriteStore interface {
+    // the sink saves pass the context of the message span, the mongo commands are traced
+    // as its children
+    SaveLayer(ctx context.Context, layer *nats.LayerUpdate) error
+    SaveReplayedLayer(ctx context.Context, layer *nats.LayerUpdate) error
+    SaveAtx(ctx context.Context, atx *nats.Atx) error
+    SaveMalfeasance(ctx context.Context, malfeasance *nats.Malfeasance) error
+    SaveTransactions(ctx context.Context, transaction *nats.Transaction, result bool) error
+    SaveReward(ctx context.Context, reward *nats.Reward) error
+    SavePrice(price *types.PriceDoc) error
+    SaveStats(stats *types.StatsDoc) error
+    SaveNetworkSnapshot(snapshot *types.NetworkSnapshotDoc) error
+    // SaveNetworkInfoHistory replaces the info saved before in the same layer
+    SaveNetworkInfoHistory(info *types.NetworkInfoHistoryDoc) error
+    SaveStreamCheckpoint(checkpoint *types.StreamCheckpointDoc) error
+    SavePoetHealth(health *types.PoetHealthDoc) error
+    // ImportGenesisLedger adds the genesis balances once per database, false when they
+    // were imported before
+    ImportGenesisLedger(accounts []*types.AccountDoc) (bool, error)
+    // ImportCheckpointAccounts sets the balances of a checkpoint, the difference to the
+    // stored balance is recorded as a balance change in layer
+    ImportCheckpointAccounts(accounts []*types.AccountDoc, layer uint32) error
+    SaveWebhook(webhook *types.WebhookDoc) error
+    DeleteWebhook(id string, owner string) (bool, error)
+    QueueWebhookDelivery(delivery *types.WebhookDeliveryDoc) error
+    GetDueWebhookDeliveries(now int64, limit int64) ([]*types.WebhookDeliveryDoc, error)
+    ClaimWebhookDelivery(id string, attempts int, nextAttempt int64) (bool, error)
+    UpdateWebhookDelivery(delivery *types.WebhookDeliveryDoc) error
+    AddWatchlistItems(items []*types.WatchlistItemDoc) error
+    RemoveWatchlistItem(owner string, kind string, item string) (bool, error)
+    // SavePoolReward replaces the pool reward saved before with the same id
+    SavePoolReward(reward *types.PoolRewardDoc) error
+    // BackfillPoolRewards copies the saved rewards of the nodes to the pool rewards, for
+    // nodes that became members after their rewards were saved
+    BackfillPoolRewards(nodeIds []string) error
+    SaveRewardDigests(digests []*types.RewardDigestDoc) error
+    // AddEmailSubscription fails with a Conflict when the owner has the email or the slot
+    AddEmailSubscription(subscription *types.EmailSubscriptionDoc) error
+    ConfirmEmailSubscription(token string) (bool, error)
+    DeleteEmailSubscription(id string, owner string) (bool, error)
+    DeleteEmailSubscriptionByToken(token string) (bool, error)
+    DeletePendingEmailSubscriptions(createdBefore int64) (int64, error)
+    ClaimEmailSubscriptionLastDay(id string, lastDay int64) (bool, error)
+    UpdateEmailSubscriptionLastDay(id string, lastDay int64) error
+
+    AggregateSmeshersEpochRewards(fromEpoch uint32) error
+    AggregateSmeshersTotals(fromEpoch uint32) error
+    SaveSmeshersPerformance(docs []*types.SmesherPerformanceDoc) error
+    AggregateRewardsRollups(fromEpoch uint32) error
+    AggregateFeesRollups(fromEpoch uint32) error
+    PruneCollection(collection string, beforeLayer uint32, dryRun bool) (int64, error)
+    ExpirePendingTransactions(createdBefore int64, keep []string) (int64, error)
+    RebuildAggregate(collection string) error
+    UpgradeDocuments(collection string) (int64, error)
+    EnsureIndexes() error
+
+    EnableChangeFeed(retention time.Duration) error
+    EnableStatsRetention(retention time.Duration) error
+
+    AcquireFence(instanceId string) (*types.FenceDoc, error)
+    StartFenceCheck(interval time.Duration)
+    Fenced() bool
+    FenceOff()
+    AcquireLease(instanceId string, ttl time.Duration) (*types.FenceDoc, error)
+    RenewLease(ttl time.Duration) error
+    ReleaseLease() error
+
+    Capabilities() Capabilities
+    CloseWrite()
+}
+
+// ReadStore is what the api and the network state read through.
+type ReadStore interface {
+    GetAccounts(skip int64, limit int64, sort int8) ([]*types.AccountDoc, error)
+    GetAccount(account string) (*types.AccountDoc, error)
+    GetAccountsGroup(accounts []string) (*types.AccountGroup, error)
+    GetAccountsByAddress(accounts []string) ([]*types.AccountDoc, error)
+    GetAccountsCounters(accounts []string) ([]*types.AccountCounterDoc, error)
+    CountAccounts() (int64, error)
+    GetAccountsPostEpoch(epoch int, skip int64, limit int64, sort int8) ([]*types.AccountAtxDoc, error)
+    CountAccountsPostEpoch(epoch int) (int64, error)
+
+    GetNode(nodeId string) (*types.NodeDoc, error)
+    GetNodes(skip int64, limit int64) ([]*types.NodeDoc, error)
+    CountNodes() (int64, error)
+    GetMalfeasanceNodes() ([]*types.NodeDoc, error)
+    GetMalfeasanceNodesPaginated(skip int64, limit int64) ([]*types.NodeDoc, error)
+    CountMalfeasanceNodes() (int64, error)
+    FilterMalfeasanceNodes(nodeIds []string) ([]string, error)
+
+    GetTransaction(transactionId string) (*types.TransactionDoc, error)
+    // transactions are filtered by one of the transaction states, every state when empty.
+    // The After methods page by cursor, the first page when after is nil.
+    GetTransactions(account string, skip int64, limit int64, sort int8, state string) ([]*types.TransactionDoc, error)
+    GetTransactionsAfter(account string, after *Cursor, limit int64, sort int8, state string) ([]*types.TransactionDoc, error)
+    CountTransactions(account string, state string) (int64, error)
+    GetLayerTransactions(layer int, skip int64, limit int64, sort int8, state string) ([]*types.TransactionDoc, error)
+    CountLayerTransactions(layer int, state string) (int64, error)
+    GetAllTransactions(skip int64, limit int64, sort int8, filter *TransactionsFilter) ([]*types.TransactionDoc, error)
+    GetAllTransactionsAfter(after *Cursor, limit int64, sort int8, filter *TransactionsFilter) ([]*types.TransactionDoc, error)
+    CountAllTransactions(filter *TransactionsFilter) (int64, error)
+    GetPendingTransactionIds(createdBefore int64) ([]string, error)
+
+    GetRewards(account string, skip int64, limit int64, sort int8, firstLayer int, lastLayer int) ([]*types.RewardsDoc, error)
+    GetRewardsAfter(account string, after *Cursor, limit int64, sort int8, firstLayer int, lastLayer int) ([]*types.RewardsDoc, error)
+    CountRewards(account string, firstLayer int, lastLayer int) (int64, error)
+    SumRewardsLayers(account string, minLayer uint32, maxLayer uint32) (int64, error)
+    // rewards of coinbase in epoch, or in every epoch with AllEpochs, zero when it has none
+    GetCoinbaseRewardsTotal(coinbase string, epoch int64) (*types.CoinbaseRewardsTotalDoc, error)
+    GetLayerRewards(layer int, skip int64, limit int64, sort int8) ([]*types.RewardsDoc, error)
+    CountLayerRewards(layer int) (int64, error)
+    GetNodeRewards(node string, skip int64, limit int64, sort int8) ([]*types.RewardsDoc, error)
+    GetNodeRewardsAfter(node string, after *Cursor, limit int64, sort int8) ([]*types.RewardsDoc, error)
+    CountNodeRewards(node string) (int64, error)
+    CountNodeRewardsLayers(node string, minLayer uint32, maxLayer uint32) (int64, error)
+    SumNodeRewardsLayers(node string, minLayer uint32, maxLayer uint32) (int64, error)
+
+    GetAtxWeightAccount(account string, epoch uint64) (*types.AggregationAtxTotals, error)
+    GetAtxWeightNode(node string, epoch uint64) (*types.AggregationAtxTotals, error)
+    GetAccountAtxList(account string, epoch uint64) ([]*types.AtxDoc, error)
+    GetAccountAtxEpoch(account string, epoch uint64, skip int64, limit int64, sort int8) ([]*types.AtxDoc, error)
+    CountAccountAtxEpoch(account string, epoch uint64) (int64, error)
+    FilterAccountAtxNodesForEpoch(account string, epoch uint64, nodes []string) ([]string, error)
+    GetHighestAtx(epoch uint64, excludedNodes []string) (*types.AtxDoc, error)
+    GetHighestAtxs(epoch uint64, excludedNodes []string, limit int64) ([]*types.AtxDoc, error)
+    GetAtxForEpochPaginated(epoch uint64, sortField string, skip int64, limit int64, sort int8) ([]*types.AtxDoc, error)
+    GetAtxEpoch(epoch uint64) (*types.AtxEpochDoc, error)
+    GetAtx(atxId string) (*types.AtxDoc, error)
+    GetPreviousAtx(nodeId string, epoch uint32) (*types.AtxDoc, error)
+    GetNodeAtxs(nodeId string, skip int64, limit int64, sort int8) ([]*types.AtxDoc, error)
+    GetNodeAtxsAfter(nodeId string, after *Cursor, limit int64, sort int8) ([]*types.AtxDoc, error)
+    CountNodeAtxs(nodeId string) (int64, error)
+
+    GetNetworkInfo() (*types.NetworkInfoDoc, error)
+    GetProcessedsLayers(skip int64, limit int64, sort int8) ([]*types.LayerDoc, error)
+    GetLastProcessedLayer() (*types.LayerDoc, error)
+    GetLayersBetween(from uint32, to uint32) ([]*types.LayerDoc, error)
+    GetRewardedLayers(from uint32, to uint32) ([]uint32, error)
+    // rewards count and total of the layers from and to included that have rewards
+    GetLayersRewards(from uint32, to uint32) ([]*types.LayerRewardsDoc, error)
+    GetReorgs(skip int64, limit int64, sort int8) ([]*types.ReorgDoc, error)
+    CountReorgs() (int64, error)
+
+    GetTopSmeshers(sortField string, skip int64, limit int64) ([]*types.SmesherDoc, error)
+    GetTopSmeshersEpoch(epoch uint32, skip int64, limit int64) ([]*types.SmesherEpochDoc, error)
+    GetSmeshers(nodeIds []string) ([]*types.SmesherDoc, error)
+    GetCoinbaseNodes(coinbase string) ([]string, error)
+    CountSmeshers() (int64, error)
+    CountSmeshersEpoch(epoch uint32) (int64, error)
+    // performance of the smesher, latest epoch first
+    GetSmesherPerformance(nodeId string, skip int64, limit int64) ([]*types.SmesherPerformanceDoc, error)
+    CountSmesherPerformance(nodeId string) (int64, error)
+    GetLastSmeshersPerformanceEpoch() (uint32, error)
+    // performance of all the smeshers of the epoch, empty when not aggregated yet
+    GetEpochPerformance(epoch uint32) (*types.EpochPerformanceDoc, error)
+
+    GetRewardsRollups(account string, granularity string, from int64, to int64) ([]*types.RewardsRollupDoc, error)
+    // rewards of every coinbase in the layers from and to included
+    GetCoinbasesRewards(firstLayer uint32, lastLayer uint32) ([]*types.CoinbaseRewardsDoc, error)
+    // digests of the days from and to included, unix times of their start
+    GetRewardDigests(coinbase string, from int64, to int64) ([]*types.RewardDigestDoc, error)
+    GetLastRewardDigest() (*types.RewardDigestDoc, error)
+    GetLastRewardsRollupEpoch() (uint32, error)
+    GetFeesRollups(granularity string, from int64, to int64) ([]*types.FeesRollupDoc, error)
+    GetLastFeesRollupEpoch() (uint32, error)
+    GetRecentGas(fromLayer uint32, method int, limit int64) ([]*types.TransactionDoc, error)
+
+    GetPriceAt(timestamp time.Time) (*types.PriceDoc, error)
+    GetPriceHistory(from time.Time, to time.Time, resolution time.Duration) ([]*types.PriceBucketDoc, error)
+    GetPrices(from time.Time, to time.Time) ([]*types.PriceDoc, error)
+
+    GetChanges(since int64, limit int64, settle time.Duration) ([]*types.ChangeDoc, error)
+    GetCollectionSizes() (map[string]int64, error)
+    GetStats(from time.Time, to time.Time, limit int64) ([]*types.StatsDoc, error)
+    GetNetworkSnapshots(from time.Time, to time.Time) ([]*types.NetworkSnapshotDoc, error)
+    // network info of the layers from and to included, by layer
+    GetNetworkInfoHistory(from uint64, to uint64) ([]*types.NetworkInfoHistoryDoc, error)
+    GetStreamCheckpoints() ([]*types.StreamCheckpointDoc, error)
+    GetPoetsHealth() ([]*types.PoetHealthDoc, error)
+    GetBlock(blockId string) (*types.BlockDoc, error)
+    GetVault(address string) (*types.VaultDoc, error)
+    GetVaultDrained(address string) (uint64, error)
+    GetMultisig(address string) (*types.MultisigDoc, error)
+    GetMultisigSignatures(address string, offset int64, limit int64) ([]*types.MultisigSignaturesDoc, error)
+    CountMultisigSignatures(address string) (int64, error)
+    GetBalanceChanges(account string) ([]*types.BalanceChangeDoc, error)
+    // AccountActivityReady reports if the first seen and last activity layers and the
+    // transfer counts of the accounts were computed from their rewards and transactions
+    AccountActivityReady() (bool, error)
+    GetWebhooks(owner string) ([]*types.WebhookDoc, error)
+    GetWebhook(id string) (*types.WebhookDoc, error)
+    GetWebhookDeliveries(webhook string, skip int64, limit int64) ([]*types.WebhookDeliveryDoc, error)
+    CountWebhookDeliveries(webhook string) (int64, error)
+    GetWatchlistItems(owner string) ([]*types.WatchlistItemDoc, error)
+    // subscriptions of owner, every subscription when empty
+    GetEmailSubscriptions(owner string) ([]*types.EmailSubscriptionDoc, error)
+    // rewards of the pool nodes of the epochs from and to included, by epoch and node
+    GetPoolRewards(fromEpoch uint32, toEpoch uint32) ([]*types.PoolNodeRewardsDoc, error)
+    GetLayerBlocks(layer int) ([]*types.BlockDoc, error)
+
+    StreamRewards(account string, sort int8, firstLayer int, lastLayer int, each func(*types.RewardsDoc) error) error
+    StreamLayerRewards(layer int, sort int8, each func(*types.RewardsDoc) error) error
+    StreamNodeRewards(node string, sort int8, each func(*types.RewardsDoc) error) error
+    StreamTransactions(account string, sort int8, state string, each func(*types.TransactionDoc) error) error
+    StreamLayerTransactions(layer int, sort int8, state string, each func(*types.TransactionDoc) error) error
+    StreamAllTransactions(sort int8, filter *TransactionsFilter, each func(*types.TransactionDoc) error) error
+    StreamAccounts(sort int8, each func(*types.AccountDoc) error) error
+    StreamAtxForEpoch(epoch uint64, sort int8, each func(*types.AtxDoc) error) error
+    StreamAccountAtxEpoch(account string, epoch uint64, sort int8, each func(*types.AtxDoc) error) error
+
+    Capabilities() Capabilities
+    CloseRead()
+}
+
+// mongoCapabilities is every optional feature, the backend they were written for.
+var mongoCapabilities = Capabilities{
+    ChangeFeed:       true,
+    SchemaMigrations: true,
+    Rebuilds:         true,
+    DocumentUpgrades: true,
+    ParallelSaves:    true,
+}
+
+var (
+    _ WriteStore = (*WriteDB)(nil)
+    _ ReadStore  = (*ReadDB)(nil)
+)
+
+// useDatabase selects the mongo database of the config, networks sharing a mongo server
+// use separate databases.
+func useDatabase(dbConfig *config.DBConfig) {
+    if dbConfig.Database != "" {
+        database = dbConfig.Database
+    }
+}
+
+// NewReadStore opens only the read store of the configured backend, for api only
+// instances that must not write.
+func NewReadStore(dbConfig *config.DBConfig) (ReadStore, error) {
+    useDatabase(dbConfig)
+    switch dbConfig.Backend {
+    case "", BackendMongo:
+        return NewReadDB(dbConfig.Uri, dbConfig.Mongo)
+    case BackendPostgres, BackendSqlite:
+        return openSqlDB(dbConfig)
+    default:
+        return nil, fmt.Errorf("unknown db backend %s", dbConfig.Backend)
+    }
+}
+
+// NewStores opens the write and read stores of the configured backend, mongo when none
+// is set.
+func NewStores(dbConfig *config.DBConfig) (WriteStore, ReadStore, error) {
+    useDatabase(dbConfig)
+    switch dbConfig.Backend {
+    case "", BackendMongo:
+        writeDB, err := NewWriteDB(dbConfig.Uri, dbConfig.Mongo)
+        if err != nil {
+            return nil, nil, err
+        }
+        readDB, err := NewReadDB(dbConfig.Uri, dbConfig.Mongo)
+        if err != nil {
+            return nil, nil, err
+        }
+        return writeDB, readDB, nil
+    case BackendPostgres, BackendSqlite:
+        db, err := openSqlDB(dbConfig)
+        if err != nil {
+            return nil, nil, err
+        }
+        // only the write store changes data, like the mongo migrations
+        if err := db.backfillAccountActivity(); err != nil {
+            db.CloseWrite()
+            return nil, nil, err
+        }
+        return db, db, nil
+    default:
+        return nil, nil, fmt.Errorf("unknown db backend %s", dbConfig.Backend)
+    }
+}
+
+// openSqlDB opens the sql backend of the config with its query timeout.
+func openSqlDB(dbConfig *config.DBConfig) (*SqlDB, error) {
+    var db *SqlDB
+    var err error
+    if dbConfig.Backend == BackendPostgres {
+        db, err = NewPostgresDB(dbConfig.Uri)
+    } else {
+        db, err = NewSqliteDB(dbConfig.Uri)
+    }
+    if err != nil {
+        return nil, err
+    }
+    db.db.queryTimeout = time.Duration(dbConfig.QueryTimeout) * time.Second
+    return db, nil
+}
@@ -0,0 +1,80 @@
+package database
+
+import (
+    "context"
+
+    sTypes "github.com/spacemeshos/go-spacemesh/common/types"
+    "github.com/spacemeshos/go-spacemesh/nats"
+    transactionparsertypes "github.com/swarmbit/spacemesh-state-api/pkg/transactionparser/transaction"
+    "github.com/swarmbit/spacemesh-state-api/types"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/mongo"
+)
+
+const vaultsCollection = "vaults"
+
+// spawnedVaultDoc is the vault a successful vault spawn result creates, nil for every
+// other transaction.
+func spawnedVaultDoc(transaction *nats.Transaction, transactionData *transactionparsertypes.TransactionData) *types.VaultDoc {
+    if transactionData.Type != transactionparsertypes.TypeVaultSpawn || transaction.Header.Status != uint8(sTypes.TransactionSuccess) {
+        return nil
+    }
+    return &types.VaultDoc{
+        Address:             transactionData.Vault.GetVault().String(),
+        Owner:               transactionData.Vault.GetOwner().String(),
+        TotalAmount:         transactionData.Vault.GetTotalAmount(),
+        InitialUnlockAmount: transactionData.Vault.GetInitialUnlockAmount(),
+        VestingStart:        transactionData.Vault.GetVestingStart().Uint32(),
+        VestingEnd:          transactionData.Vault.GetVestingEnd().Uint32(),
+        SpawnTransaction:    transaction.ID,
+        SpawnLayer:          transaction.Header.LayerID,
+    }
+}
+
+func (m *ReadDB) GetVault(address string) (*types.VaultDoc, error) {
+    vaultsColl := m.client.Database(database).Collection(vaultsCollection)
+    vaultDoc := &types.VaultDoc{}
+    err := vaultsColl.FindOne(context.TODO(), bson.D{{Key: "_id", Value: address}}).Decode(vaultDoc)
+    if err != nil {
+        if err == mongo.ErrNoDocuments {
+            return &types.VaultDoc{}, nil
+        }
+        return &types.VaultDoc{}, err
+    }
+    return vaultDoc, nil
+}
+
+// GetVaultDrained returns the amount drained from the vault by successful drain vault
+// transactions.
+func (m *ReadDB) GetVaultDrained(address string) (uint64, error) {
+    transactionsColl := m.client.Database(database).Collection(transactionsCollection)
+
+    ctx := context.TODO()
+    cursor, err := transactionsColl.Aggregate(ctx, mongo.Pipeline{
+        bson.D{{Key: "$match", Value: bson.D{
+            {Key: "vault_account", Value: address},
+            {Key: "type", Value: transactionparsertypes.TypeDrainVault},
+            {Key: "complete", Value: true},
+            {Key: "status", Value: uint8(sTypes.TransactionSuccess)},
+        }}},
+        bson.D{{Key: "$group", Value: bson.D{
+            {Key: "_id", Value: nil},
+            {Key: "drained", Value: bson.D{{Key: "$sum", Value: "$amount"}}},
+        }}},
+    })
+    if err != nil {
+        return 0, err
+    }
+    defer cursor.Close(ctx)
+
+    var result []struct {
+        Drained int64 `bson:"drained"`
+    }
+    if err = cursor.All(ctx, &result); err != nil {
+        return 0, err
+    }
+    if len(result) == 0 {
+        return 0, nil
+    }
+    return uint64(result[0].Drained), nil
+}
@@ -0,0 +1,61 @@
+package database
+
+import (
+    "context"
+
+    "github.com/swarmbit/spacemesh-state-api/types"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const watchlistsCollection = "watchlists"
+
+// AddWatchlistItems inserts the items that are not watched yet.
+func (m *WriteDB) AddWatchlistItems(items []*types.WatchlistItemDoc) error {
+    if len(items) == 0 {
+        return nil
+    }
+    watchlistsColl := m.client.Database(database).Collection(watchlistsCollection)
+    models := make([]mongo.WriteModel, len(items))
+    for i, item := range items {
+        models[i] = mongo.NewUpdateOneModel().
+            SetFilter(bson.D{{Key: "_id", Value: item.ID}}).
+            SetUpdate(bson.D{{Key: "$setOnInsert", Value: item}}).
+            SetUpsert(true)
+    }
+    _, err := watchlistsColl.BulkWrite(context.TODO(), models, options.BulkWrite().SetOrdered(false))
+    return err
+}
+
+func (m *WriteDB) RemoveWatchlistItem(owner string, kind string, item string) (bool, error) {
+    watchlistsColl := m.client.Database(database).Collection(watchlistsCollection)
+    result, err := watchlistsColl.DeleteOne(context.TODO(), bson.D{
+        {Key: "owner", Value: owner},
+        {Key: "kind", Value: kind},
+        {Key: "item", Value: item},
+    })
+    if err != nil {
+        return false, err
+    }
+    return result.DeletedCount > 0, nil
+}
+
+// GetWatchlistItems returns the items watched by owner in the order they were added.
+func (m *ReadDB) GetWatchlistItems(owner string) ([]*types.WatchlistItemDoc, error) {
+    watchlistsColl := m.client.Database(database).Collection(watchlistsCollection)
+
+    ctx := context.TODO()
+    findOptions := options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}})
+    cursor, err := watchlistsColl.Find(ctx, bson.D{{Key: "owner", Value: owner}}, findOptions)
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    var docs []*types.WatchlistItemDoc
+    if err = cursor.All(ctx, &docs); err != nil {
+        return nil, err
+    }
+    return docs, nil
+}
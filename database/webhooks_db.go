@@ -0,0 +1,173 @@
+package database
+
+import (
+    "context"
+
+    "github.com/swarmbit/spacemesh-state-api/types"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+    webhooksCollection          = "webhooks"
+    webhookDeliveriesCollection = "webhookDeliveries"
+)
+
+func (m *WriteDB) SaveWebhook(webhook *types.WebhookDoc) error {
+    webhooksColl := m.client.Database(database).Collection(webhooksCollection)
+    _, err := webhooksColl.ReplaceOne(
+        context.TODO(),
+        bson.D{{Key: "_id", Value: webhook.ID}},
+        webhook,
+        options.Replace().SetUpsert(true),
+    )
+    return err
+}
+
+// DeleteWebhook deletes the webhook of owner and its deliveries, it reports false when
+// owner has no such webhook.
+func (m *WriteDB) DeleteWebhook(id string, owner string) (bool, error) {
+    webhooksColl := m.client.Database(database).Collection(webhooksCollection)
+    result, err := webhooksColl.DeleteOne(context.TODO(), bson.D{{Key: "_id", Value: id}, {Key: "owner", Value: owner}})
+    if err != nil || result.DeletedCount == 0 {
+        return false, err
+    }
+    deliveriesColl := m.client.Database(database).Collection(webhookDeliveriesCollection)
+    _, err = deliveriesColl.DeleteMany(context.TODO(), bson.D{{Key: "webhook", Value: id}})
+    return true, err
+}
+
+// QueueWebhookDelivery inserts the delivery unless it is already queued.
+func (m *WriteDB) QueueWebhookDelivery(delivery *types.WebhookDeliveryDoc) error {
+    if m.Fenced() {
+        return ErrFenced
+    }
+    deliveriesColl := m.client.Database(database).Collection(webhookDeliveriesCollection)
+    _, err := deliveriesColl.InsertOne(context.TODO(), delivery)
+    if err != nil && docExistsErr(err) {
+        return nil
+    }
+    return err
+}
+
+// GetDueWebhookDeliveries returns the pending deliveries to send at now, oldest first.
+// They are read from the primary so a delivery just sent is not sent again.
+func (m *WriteDB) GetDueWebhookDeliveries(now int64, limit int64) ([]*types.WebhookDeliveryDoc, error) {
+    deliveriesColl := m.client.Database(database).Collection(webhookDeliveriesCollection)
+
+    ctx := context.TODO()
+    filter := bson.D{
+        {Key: "status", Value: types.WebhookDeliveryPending},
+        {Key: "nextAttempt", Value: bson.D{{Key: "$lte", Value: now}}},
+    }
+    findOptions := options.Find().SetSort(bson.D{{Key: "nextAttempt", Value: 1}}).SetLimit(limit)
+    cursor, err := deliveriesColl.Find(ctx, filter, findOptions)
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    var docs []*types.WebhookDeliveryDoc
+    if err = cursor.All(ctx, &docs); err != nil {
+        return nil, err
+    }
+    return docs, nil
+}
+
+// ClaimWebhookDelivery counts the next attempt of the pending delivery and moves it to
+// nextAttempt, only while it still has attempts. It reports false when another run
+// claimed it first, so an attempt is posted once.
+func (m *WriteDB) ClaimWebhookDelivery(id string, attempts int, nextAttempt int64) (bool, error) {
+    if m.Fenced() {
+        return false, ErrFenced
+    }
+    deliveriesColl := m.client.Database(database).Collection(webhookDeliveriesCollection)
+    result, err := deliveriesColl.UpdateOne(
+        context.TODO(),
+        bson.D{
+            {Key: "_id", Value: id},
+            {Key: "status", Value: types.WebhookDeliveryPending},
+            {Key: "attempts", Value: attempts},
+        },
+        bson.D{
+            {Key: "$inc", Value: bson.D{{Key: "attempts", Value: 1}}},
+            {Key: "$set", Value: bson.D{{Key: "nextAttempt", Value: nextAttempt}}},
+        },
+    )
+    if err != nil {
+        return false, err
+    }
+    return result.ModifiedCount == 1, nil
+}
+
+func (m *WriteDB) UpdateWebhookDelivery(delivery *types.WebhookDeliveryDoc) error {
+    if m.Fenced() {
+        return ErrFenced
+    }
+    deliveriesColl := m.client.Database(database).Collection(webhookDeliveriesCollection)
+    _, err := deliveriesColl.ReplaceOne(context.TODO(), bson.D{{Key: "_id", Value: delivery.ID}}, delivery)
+    return err
+}
+
+// GetWebhooks returns the webhooks of owner, every webhook when owner is empty.
+func (m *ReadDB) GetWebhooks(owner string) ([]*types.WebhookDoc, error) {
+    webhooksColl := m.client.Database(database).Collection(webhooksCollection)
+
+    ctx := context.TODO()
+    filter := bson.D{}
+    if owner != "" {
+        filter = bson.D{{Key: "owner", Value: owner}}
+    }
+    cursor, err := webhooksColl.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}))
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    var docs []*types.WebhookDoc
+    if err = cursor.All(ctx, &docs); err != nil {
+        return nil, err
+    }
+    return docs, nil
+}
+
+func (m *ReadDB) GetWebhook(id string) (*types.WebhookDoc, error) {
+    webhooksColl := m.client.Database(database).Collection(webhooksCollection)
+    webhookDoc := &types.WebhookDoc{}
+    err := webhooksColl.FindOne(context.TODO(), bson.D{{Key: "_id", Value: id}}).Decode(webhookDoc)
+    if err != nil {
+        if err == mongo.ErrNoDocuments {
+            return &types.WebhookDoc{}, nil
+        }
+        return &types.WebhookDoc{}, err
+    }
+    return webhookDoc, nil
+}
+
+// GetWebhookDeliveries returns the deliveries of the webhook, latest first.
+func (m *ReadDB) GetWebhookDeliveries(webhook string, skip int64, limit int64) ([]*types.WebhookDeliveryDoc, error) {
+    deliveriesColl := m.client.Database(database).Collection(webhookDeliveriesCollection)
+
+    ctx := context.TODO()
+    findOptions := options.Find().
+        SetSort(bson.D{{Key: "createdAt", Value: -1}}).
+        SetSkip(skip).
+        SetLimit(limit)
+    cursor, err := deliveriesColl.Find(ctx, bson.D{{Key: "webhook", Value: webhook}}, findOptions)
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    var docs []*types.WebhookDeliveryDoc
+    if err = cursor.All(ctx, &docs); err != nil {
+        return nil, err
+    }
+    return docs, nil
+}
+
+func (m *ReadDB) CountWebhookDeliveries(webhook string) (int64, error) {
+    deliveriesColl := m.client.Database(database).Collection(webhookDeliveriesCollection)
+    return deliveriesColl.CountDocuments(context.TODO(), bson.D{{Key: "webhook", Value: webhook}})
+}
@@ -4,12 +4,15 @@ import (
     "context"
     "fmt"
     "log"
+    "sync/atomic"
     "time"
 
     sTypes "github.com/spacemeshos/go-spacemesh/common/types"
     "github.com/spacemeshos/go-spacemesh/nats"
+    "github.com/swarmbit/spacemesh-state-api/pkg/apperror"
     "github.com/swarmbit/spacemesh-state-api/pkg/transactionparser"
     transactionparsertypes "github.com/swarmbit/spacemesh-state-api/pkg/transactionparser/transaction"
+    "github.com/swarmbit/spacemesh-state-api/config"
     "github.com/swarmbit/spacemesh-state-api/types"
     "go.mongodb.org/mongo-driver/bson"
     "go.mongodb.org/mongo-driver/mongo"
@@ -17,10 +20,18 @@ import (
 )
 
 type WriteDB struct {
-    client *mongo.Client
+    client     *mongo.Client
+    changeFeed bool
+    fence      atomic.Pointer[types.FenceDoc]
+    fenced     atomic.Bool
+    // transactions is set when the server runs multi-document transactions
+    transactions bool
+    rollbacks    rollbackTracker
 }
 
-const database = "spacemesh"
+// database is the mongo database of the network, set from the config by the store
+// constructors before any client is opened
+var database = "spacemesh"
 const rewardsCollection = "rewards"
 const layersCollection = "layers"
 const atxsCollection = "atxs"
@@ -33,324 +44,254 @@ const nodesCountCollection = "nodesCount"
 const networkInfoCollection = "networkInfo"
 const accountsCollection = "accounts"
 const transactionsCollection = "transactions"
+const smeshersCollection = "smeshers"
+const smeshersEpochsCollection = "smeshersEpochs"
+const smeshersPerformanceCollection = "smeshersPerformance"
+const pricesCollection = "prices"
 
-func NewWriteDB(dbConnection string) (*WriteDB, error) {
+func NewWriteDB(dbConnection string, mongoConfig *config.MongoConfig) (*WriteDB, error) {
     ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
     defer cancel()
-    client, err := mongo.Connect(ctx, options.Client().ApplyURI(dbConnection).SetMaxPoolSize(10))
-    err = createIndexes(client)
+    clientOptions, err := mongoClientOptions(dbConnection, mongoConfig, false)
+    if err != nil {
+        return nil, err
+    }
+    client, err := mongo.Connect(ctx, clientOptions)
+    err = migrate(client)
+    transactions := transactionsSupported(client)
+    if !transactions {
+        log.Println("Mongo server does not support transactions, saving without them")
+    }
     log.Println("Created write db")
     return &WriteDB{
-        client: client,
+        client:       client,
+        transactions: transactions,
     }, err
 }
 
-func createIndexes(client *mongo.Client) error {
-    rewardsColl := client.Database(database).Collection(rewardsCollection)
-    rewardsIndexes := []mongo.IndexModel{
-        {
-            Keys: bson.D{
-                {Key: "coinbase", Value: 1},
-                {Key: "layer", Value: 1},
-            },
-            Options: options.Index().SetUnique(false),
-        },
-        {
-            Keys: bson.D{
-                {Key: "node_id", Value: 1},
-                {Key: "layer", Value: 1},
-            },
-            Options: options.Index().SetUnique(false),
-        },
-        {
-            Keys: bson.D{
-                {Key: "layer", Value: 1},
-            },
-            Options: options.Index().SetUnique(false),
-        },
-    }
-
-    _, err := rewardsColl.Indexes().CreateMany(context.TODO(), rewardsIndexes)
-    if err != nil {
-        log.Println(err)
-        return err
-    }
-
-    transactionsColl := client.Database(database).Collection(transactionsCollection)
-    transactionsIndexes := []mongo.IndexModel{
-        {
-            Keys: bson.D{
-                {Key: "principal_account", Value: 1},
-                {Key: "layer", Value: 1},
-            },
-            Options: options.Index().SetUnique(false),
-        },
-        {
-            Keys: bson.D{
-                {Key: "receiver_account", Value: 1},
-                {Key: "layer", Value: 1},
-            },
-            Options: options.Index().SetUnique(false),
-        },
-        {
-            Keys: bson.D{
-                {Key: "layer", Value: 1},
-            },
-            Options: options.Index().SetUnique(false),
-        },
-    }
-
-    _, err = transactionsColl.Indexes().CreateMany(context.TODO(), transactionsIndexes)
-    if err != nil {
-        log.Println(err)
-        return err
-    }
-
-    accountsColl := client.Database(database).Collection(accountsCollection)
-    accountsIndexes := []mongo.IndexModel{
-        {
-            Keys: bson.D{
-                {Key: "balance", Value: -1},
-            },
-            Options: options.Index().SetUnique(false),
-        },
-    }
-
-    _, err = accountsColl.Indexes().CreateMany(context.TODO(), accountsIndexes)
-    if err != nil {
-        log.Println(err)
-        return err
-    }
-
-    atxColl := client.Database(database).Collection(atxsCollection)
-    atxIndexes := []mongo.IndexModel{
-        {
-            Keys: bson.D{
-                {Key: "_id", Value: 1},
-                {Key: "publishepoch", Value: 1},
-            },
-            Options: options.Index().SetUnique(false),
-        },
-        {
-            Keys: bson.D{
-                {Key: "node_id", Value: 1},
-                {Key: "publishepoch", Value: 1},
-            },
-            Options: options.Index().SetUnique(false),
-        },
-        {
-            Keys: bson.D{
-                {Key: "coinbase", Value: 1},
-                {Key: "publishepoch", Value: 1},
-            },
-            Options: options.Index().SetUnique(false),
-        },
-        {
-            Keys: bson.D{
-                {Key: "publishepoch", Value: 1},
-            },
-            Options: options.Index().SetUnique(false),
-        },
-        {
-            Keys: bson.D{
-                {Key: "publishepoch", Value: 1},
-                {Key: "effective_num_units", Value: 1},
-            },
-            Options: options.Index().SetUnique(false),
-        },
-    }
-
-    _, err = atxColl.Indexes().CreateMany(context.TODO(), atxIndexes)
-    if err != nil {
-        log.Println(err)
-        return err
-    }
-
-    accountAtxsEpochsColl := client.Database(database).Collection(accountAtxsEpochsCollection)
-    accountAtxEpochsIndexes := []mongo.IndexModel{
-        {
-            Keys: bson.D{
-                {Key: "_id", Value: 1},
-                {Key: "totalWeight", Value: 1},
-            },
-            Options: options.Index().SetUnique(false),
-        },
-    }
+func (m *WriteDB) SaveLayer(ctx context.Context, layer *nats.LayerUpdate) error {
+    return m.saveLayer(ctx, layer, true)
+}
 
-    _, err = accountAtxsEpochsColl.Indexes().CreateMany(context.TODO(), accountAtxEpochsIndexes)
-    if err != nil {
-        log.Println(err)
-        return err
-    }
-    return nil
+// SaveReplayedLayer saves a layer read again by a replay, resync or refetch, applying
+// it again is not a rollback.
+func (m *WriteDB) SaveReplayedLayer(ctx context.Context, layer *nats.LayerUpdate) error {
+    return m.saveLayer(ctx, layer, false)
 }
 
-func (m *WriteDB) SaveLayer(layer *nats.LayerUpdate) error {
+func (m *WriteDB) saveLayer(ctx context.Context, layer *nats.LayerUpdate, checkRollback bool) error {
+    if m.Fenced() {
+        return ErrFenced
+    }
     // only store processed layers
     if layer.Status > 0 {
+        if checkRollback && layer.Status == LayerStatusApplied && m.rollbacks.applied(layer.LayerID) {
+            if err := m.detectRollback(ctx, layer.LayerID); err != nil {
+                log.Printf("Failed to check layer %d for rollback: %v", layer.LayerID, err)
+            }
+        }
         layersColl := m.client.Database(database).Collection(layersCollection)
-        _, err := layersColl.UpdateOne(
-            context.TODO(),
-            bson.D{{Key: "_id", Value: layer.LayerID}},
-            bson.D{{Key: "$set", Value: bson.D{{Key: "status", Value: layer.Status}}}},
-            options.Update().SetUpsert(true),
-        )
-        return err
+        return m.withTransaction(ctx, func(ctx context.Context) error {
+            _, err := layersColl.UpdateOne(
+                ctx,
+                bson.D{{Key: "_id", Value: layer.LayerID}},
+                bson.D{{Key: "$set", Value: bson.D{
+                    {Key: "status", Value: layer.Status},
+                    {Key: "writer", Value: m.fenceToken()},
+                }}},
+                options.Update().SetUpsert(true),
+            )
+            if err != nil {
+                return err
+            }
+            return m.recordChange(ctx, EntityLayer, ChangeUpdate, layer.LayerID, bson.D{{Key: "status", Value: layer.Status}})
+        })
     }
     return nil
 }
 
-func (m *WriteDB) SaveAtx(atx *nats.Atx) error {
-    session, err := m.client.StartSession()
-    defer session.EndSession(context.TODO())
+// SaveAtx saves the atx with its epoch totals, the totals of its coinbase and the atxs
+// of its node in one transaction, the aggregates are only updated for a new atx.
+func (m *WriteDB) SaveAtx(ctx context.Context, atx *nats.Atx) error {
+    if m.Fenced() {
+        return ErrFenced
+    }
 
-    callback := func(sessionContext mongo.SessionContext) (interface{}, error) {
+    weight := ATXWeight(atx.TickCount, uint64(atx.EffectiveNumUnits))
+    atxDoc := &types.AtxDoc{
+        AtxID:             atx.AtxID,
+        NodeID:            atx.NodeID,
+        EffectiveNumUnits: atx.EffectiveNumUnits,
+        BaseTick:          atx.BaseTick,
+        TickCount:         atx.TickCount,
+        Sequence:          atx.Sequence,
+        PublishEpoch:      atx.PublishEpoch,
+        Coinbase:          atx.Coinbase,
+        Received:          atx.Received,
+        Weight:            weight,
+        SchemaVersion:     atxUpgrade.Current(),
+    }
+    inserted := false
+    err := m.withTransaction(ctx, func(ctx context.Context) error {
         atxsColl := m.client.Database(database).Collection(atxsCollection)
         atxsEpochsColl := m.client.Database(database).Collection(atxsEpochsCollection)
         accountAtxsEpochsColl := m.client.Database(database).Collection(accountAtxsEpochsCollection)
         nodesColl := m.client.Database(database).Collection(nodesCollection)
         nodesCountColl := m.client.Database(database).Collection(nodesCountCollection)
         accountsColl := m.client.Database(database).Collection(accountsCollection)
-        weight := getATXWeight(atx.TickCount, uint64(atx.EffectiveNumUnits))
-        atxDoc := &types.AtxDoc{
-            AtxID:             atx.AtxID,
-            NodeID:            atx.NodeID,
-            EffectiveNumUnits: atx.EffectiveNumUnits,
-            BaseTick:          atx.BaseTick,
-            TickCount:         atx.TickCount,
-            Sequence:          atx.Sequence,
-            PublishEpoch:      atx.PublishEpoch,
-            Coinbase:          atx.Coinbase,
-            Received:          atx.Received,
-            Weight:            weight,
-        }
+
         updateResult, err := atxsColl.UpdateOne(
-            context.TODO(),
+            ctx,
             bson.D{{Key: "_id", Value: atx.AtxID}},
             bson.D{{Key: "$set", Value: atxDoc}},
             options.Update().SetUpsert(true))
         if err != nil {
-            return updateResult, err
+            return err
         }
 
         // only update counts if inserted new ATX
-        if updateResult.UpsertedCount == 1 {
-            updateResult, err = atxsEpochsColl.UpdateOne(
-                context.TODO(),
-                bson.D{{Key: "_id", Value: atxDoc.PublishEpoch}},
-                bson.D{{Key: "$inc", Value: bson.D{
-                    {Key: "totalEffectiveNumUnits", Value: atx.EffectiveNumUnits},
-                    {Key: "totalWeight", Value: weight},
-                    {Key: "totalAtx", Value: 1},
-                }}},
-                options.Update().SetUpsert(true),
-            )
-            if err != nil {
-                return updateResult, err
-            }
-
-            updateResult, err = accountAtxsEpochsColl.UpdateOne(
-                context.TODO(),
-                bson.D{{Key: "_id", Value: bson.M{
-                    "coinbase":      atx.Coinbase,
-                    "publish_epoch": atx.PublishEpoch,
-                }}},
-                bson.D{{Key: "$inc", Value: bson.D{
-                    {Key: "totalEffectiveNumUnits", Value: atx.EffectiveNumUnits},
-                    {Key: "totalWeight", Value: weight},
-                    {Key: "totalAtx", Value: 1},
-                }}},
-                options.Update().SetUpsert(true),
-            )
-            if err != nil {
-                return updateResult, err
-            }
+        inserted = updateResult.UpsertedCount == 1
+        if !inserted {
+            return nil
+        }
+        _, err = atxsEpochsColl.UpdateOne(
+            ctx,
+            bson.D{{Key: "_id", Value: atxDoc.PublishEpoch}},
+            bson.D{{Key: "$inc", Value: bson.D{
+                {Key: "totalEffectiveNumUnits", Value: atx.EffectiveNumUnits},
+                {Key: "totalWeight", Value: weight},
+                {Key: "totalAtx", Value: 1},
+            }}},
+            options.Update().SetUpsert(true),
+        )
+        if err != nil {
+            return err
+        }
 
-            updateResult, err = nodesColl.UpdateOne(
-                context.TODO(),
-                bson.D{{Key: "_id", Value: atxDoc.NodeID}},
-                bson.D{{Key: "$addToSet", Value: bson.D{
-                    {Key: "atxs", Value: bson.D{
-                        {Key: "coinbase", Value: atxDoc.Coinbase},
-                        {Key: "effectiveNumUnits", Value: atxDoc.EffectiveNumUnits},
-                        {Key: "sequence", Value: atxDoc.Sequence},
-                        {Key: "weight", Value: atxDoc.Weight},
-                        {Key: "publishEpoch", Value: atxDoc.PublishEpoch},
-                        {Key: "received", Value: atxDoc.Received},
-                    }},
-                }}},
-                options.Update().SetUpsert(true),
-            )
+        err = m.updateHighestAtx(ctx, atxDoc)
+        if err != nil {
+            return err
+        }
 
-            if updateResult.UpsertedCount == 1 {
-                updateResult, err = nodesCountColl.UpdateOne(
-                    context.TODO(),
-                    bson.D{{Key: "_id", Value: "nodesCount"}},
-                    bson.D{{Key: "$inc", Value: bson.D{
-                        {Key: "count", Value: 1},
-                    }}},
-                    options.Update().SetUpsert(true),
-                )
+        _, err = accountAtxsEpochsColl.UpdateOne(
+            ctx,
+            bson.D{{Key: "_id", Value: bson.M{
+                "coinbase":      atx.Coinbase,
+                "publish_epoch": atx.PublishEpoch,
+            }}},
+            bson.D{{Key: "$inc", Value: bson.D{
+                {Key: "totalEffectiveNumUnits", Value: atx.EffectiveNumUnits},
+                {Key: "totalWeight", Value: weight},
+                {Key: "totalAtx", Value: 1},
+            }}},
+            options.Update().SetUpsert(true),
+        )
+        if err != nil {
+            return err
+        }
 
-                if err != nil {
-                    return updateResult, err
-                }
-            }
+        updateResult, err = nodesColl.UpdateOne(
+            ctx,
+            bson.D{{Key: "_id", Value: atxDoc.NodeID}},
+            bson.D{{Key: "$addToSet", Value: bson.D{
+                {Key: "atxs", Value: bson.D{
+                    {Key: "coinbase", Value: atxDoc.Coinbase},
+                    {Key: "effectiveNumUnits", Value: atxDoc.EffectiveNumUnits},
+                    {Key: "sequence", Value: atxDoc.Sequence},
+                    {Key: "weight", Value: atxDoc.Weight},
+                    {Key: "publishEpoch", Value: atxDoc.PublishEpoch},
+                    {Key: "received", Value: atxDoc.Received},
+                }},
+            }}},
+            options.Update().SetUpsert(true),
+        )
+        if err != nil {
+            return err
+        }
 
-            updateResult, err = accountsColl.UpdateOne(
-                context.TODO(),
-                bson.D{{Key: "_id", Value: atxDoc.Coinbase}},
-                bson.D{{Key: "$setOnInsert", Value: bson.D{
-                    {Key: "_id", Value: atxDoc.Coinbase},
+        if updateResult.UpsertedCount == 1 {
+            _, err = nodesCountColl.UpdateOne(
+                ctx,
+                bson.D{{Key: "_id", Value: "nodesCount"}},
+                bson.D{{Key: "$inc", Value: bson.D{
+                    {Key: "count", Value: 1},
                 }}},
                 options.Update().SetUpsert(true),
             )
-            return updateResult, err
+            if err != nil {
+                return err
+            }
         }
 
-        return updateResult, err
-    }
-
-    // Execute the operations in a transaction
-    if _, err := session.WithTransaction(context.TODO(), callback); err != nil {
-        log.Println("Atx transaction failed: %v", err)
+        _, err = accountsColl.UpdateOne(
+            ctx,
+            bson.D{{Key: "_id", Value: atxDoc.Coinbase}},
+            bson.D{{Key: "$setOnInsert", Value: bson.D{
+                {Key: "_id", Value: atxDoc.Coinbase},
+            }}},
+            options.Update().SetUpsert(true),
+        )
+        if err != nil {
+            return err
+        }
+        if err = m.recordChange(ctx, EntityAtx, ChangeInsert, atxDoc.AtxID, atxDoc); err != nil {
+            return err
+        }
+        return m.recordAccountChange(ctx, atxDoc.Coinbase)
+    })
+    if err != nil {
+        log.Printf("Atx transaction failed: %v", err)
+        return err
     }
-
-    fmt.Println("Atx transaction succeeded")
-
-    return err
-
+    return nil
 }
 
-func (m *WriteDB) SaveMalfeasance(malfeasance *nats.Malfeasance) error {
+func (m *WriteDB) SaveMalfeasance(ctx context.Context, malfeasance *nats.Malfeasance) error {
+    if m.Fenced() {
+        return ErrFenced
+    }
     nodesColl := m.client.Database(database).Collection(nodesCollection)
-    _, err := nodesColl.UpdateOne(
-        context.TODO(),
-        bson.D{{Key: "_id", Value: malfeasance.NodeID}},
-        bson.D{{Key: "$set", Value: bson.D{
-            {Key: "malfeasance", Value: bson.D{
-                {Key: "received", Value: malfeasance.Received},
-            }},
-        }}},
-        options.Update().SetUpsert(true),
-    )
+    update := bson.D{
+        {Key: "malfeasance", Value: bson.D{
+            {Key: "received", Value: malfeasance.Received},
+            {Key: "layer", Value: malfeasance.LayerID},
+        }},
+    }
+    err := m.withTransaction(ctx, func(ctx context.Context) error {
+        _, err := nodesColl.UpdateOne(
+            ctx,
+            bson.D{{Key: "_id", Value: malfeasance.NodeID}},
+            bson.D{{Key: "$set", Value: update}},
+            options.Update().SetUpsert(true),
+        )
+        if err != nil {
+            return err
+        }
+        err = m.recordChange(ctx, EntityNode, ChangeUpdate, malfeasance.NodeID, update)
+        if err != nil {
+            return err
+        }
+        // the node can not be the highest atx of an epoch anymore
+        return m.recomputeHighestAtxs(ctx, atxsEpochsCollection, bson.D{{Key: "highestNode", Value: malfeasance.NodeID}})
+    })
     fmt.Println("Malfeasance succeeded")
     return err
 }
 
-func (m *WriteDB) SaveTransactions(transaction *nats.Transaction, result bool) error {
-    session, err := m.client.StartSession()
-    defer session.EndSession(context.TODO())
-
-    callback := func(sessionContext mongo.SessionContext) (interface{}, error) {
-
-        var transactionDoc *types.TransactionDoc
+func (m *WriteDB) SaveTransactions(ctx context.Context, transaction *nats.Transaction, result bool) error {
+    if m.Fenced() {
+        return ErrFenced
+    }
+    var transactionDoc *types.TransactionDoc
+    var changedAccounts []string
+    duplicate := false
+    save := func(ctx context.Context) error {
         if result {
 
             transactionData, err := transactionparser.Parse(transaction.Raw)
             if err != nil {
-                fmt.Println("Failed to parse transaction: ", err)
-                return nil, err
+                // the raw transaction fails the same way on every delivery
+                return apperror.Wrap(apperror.InvalidInput, "invalid transaction", err)
             }
             receiver := transactionData.Tx.GetReceiver()
             receiverString := ""
@@ -378,20 +319,58 @@ func (m *WriteDB) SaveTransactions(transaction *nats.Transaction, result bool) e
                 Counter:         transactionData.Tx.GetCounter(),
                 GasPrice:        transactionData.Tx.GetGasPrice(),
                 Complete:        true,
+                Message:         transaction.Header.Message,
+                BlockID:         transaction.Header.BlockID,
+                Raw:             transaction.Raw,
+                SchemaVersion:   transactionUpgrade.Current(),
             }
 
             transactionsColl := m.client.Database(database).Collection(transactionsCollection)
             accountsColl := m.client.Database(database).Collection(accountsCollection)
 
             previousTransaction := transactionsColl.FindOneAndUpdate(
-                context.TODO(),
+                ctx,
                 bson.D{{Key: "_id", Value: transaction.ID}},
                 bson.D{{Key: "$set", Value: transactionDoc}},
                 options.FindOneAndUpdate().SetUpsert(true))
 
             err = previousTransaction.Err()
             if err != nil && err != mongo.ErrNoDocuments {
-                return previousTransaction, err
+                return err
+            }
+
+            if vaultDoc := spawnedVaultDoc(transaction, transactionData); vaultDoc != nil {
+                _, err := m.client.Database(database).Collection(vaultsCollection).ReplaceOne(
+                    ctx,
+                    bson.D{{Key: "_id", Value: vaultDoc.Address}},
+                    vaultDoc,
+                    options.Replace().SetUpsert(true),
+                )
+                if err != nil {
+                    return err
+                }
+            }
+            if multisigDoc := spawnedMultisigDoc(transaction, transactionData); multisigDoc != nil {
+                _, err := m.client.Database(database).Collection(multisigsCollection).ReplaceOne(
+                    ctx,
+                    bson.D{{Key: "_id", Value: multisigDoc.Address}},
+                    multisigDoc,
+                    options.Replace().SetUpsert(true),
+                )
+                if err != nil {
+                    return err
+                }
+            }
+            if signaturesDoc := multisigSignaturesDoc(transaction, transactionData); signaturesDoc != nil {
+                _, err := m.client.Database(database).Collection(multisigSignaturesCollection).ReplaceOne(
+                    ctx,
+                    bson.D{{Key: "_id", Value: signaturesDoc.Transaction}},
+                    signaturesDoc,
+                    options.Replace().SetUpsert(true),
+                )
+                if err != nil {
+                    return err
+                }
             }
 
             updateBalances := false
@@ -403,12 +382,19 @@ func (m *WriteDB) SaveTransactions(transaction *nats.Transaction, result bool) e
                 previousTransactionDoc := &types.TransactionDoc{}
                 err := previousTransaction.Decode(previousTransactionDoc)
                 if err != nil {
-                    return previousTransaction, err
+                    return err
                 }
                 // if not complete means it should update balance, if complete is a duplicate so don't update balances
                 updateBalances = !previousTransactionDoc.Complete
             }
 
+            // a duplicate result was already counted in its block
+            if updateBalances && transactionDoc.BlockID != "" {
+                if err := m.countBlockTransaction(ctx, transactionDoc.BlockID, transactionDoc.Layer); err != nil {
+                    return err
+                }
+            }
+
             // if transaction not sucessfull or addressess length less than 2 it means is an ineffective transaction
             if transaction.Header.Status != uint8(sTypes.TransactionSuccess) || len(transaction.Header.Addresses) < 2 {
                 updateBalances = false
@@ -416,18 +402,24 @@ func (m *WriteDB) SaveTransactions(transaction *nats.Transaction, result bool) e
 
             // if amount is 0 there is not point updating the balance for receiver account
             if updateBalances && transactionDoc.Amount > 0 {
-                updateResult, err := accountsColl.UpdateOne(
-                    context.TODO(),
+                _, err := accountsColl.UpdateOne(
+                    ctx,
                     bson.D{{Key: "_id", Value: transactionDoc.ReceiverAccount}},
-                    bson.D{{Key: "$inc", Value: bson.D{
+                    accountActivity(bson.D{{Key: "$inc", Value: bson.D{
                         {Key: "balance", Value: transactionDoc.Amount},
                         {Key: "received", Value: transactionDoc.Amount},
-                    }}},
+                        {Key: "incomingTransfers", Value: 1},
+                    }}}, transactionDoc.Layer),
                     options.Update().SetUpsert(true),
                 )
                 if err != nil {
-                    return updateResult, err
+                    return err
+                }
+                _, err = m.incBalanceChange(ctx, transactionDoc.ReceiverAccount, transactionDoc.Layer, int64(transactionDoc.Amount))
+                if err != nil {
+                    return err
                 }
+                changedAccounts = append(changedAccounts, transactionDoc.ReceiverAccount)
             }
 
             // update balance for sender account
@@ -441,134 +433,192 @@ func (m *WriteDB) SaveTransactions(transaction *nats.Transaction, result bool) e
 
                 fee := transactionDoc.Gas * transactionDoc.GasPrice
                 valueToDeduct := (int64(transactionDoc.Amount) + int64(fee)) * -1
-                updateResult, err := accountsColl.UpdateOne(
-                    context.TODO(),
+                _, err := accountsColl.UpdateOne(
+                    ctx,
                     bson.D{{Key: "_id", Value: senderAccount}},
-                    bson.D{{Key: "$inc", Value: bson.D{
+                    accountActivity(bson.D{{Key: "$inc", Value: bson.D{
                         {Key: "balance", Value: valueToDeduct},
                         {Key: "sent", Value: transactionDoc.Amount},
                         {Key: "fees", Value: fee},
+                        {Key: "outgoingTransfers", Value: 1},
+                    }}}, transactionDoc.Layer),
+                    options.Update().SetUpsert(true),
+                )
+                if err != nil {
+                    return err
+                }
+                _, err = m.incBalanceChange(ctx, senderAccount, transactionDoc.Layer, valueToDeduct)
+                if err != nil {
+                    return err
+                }
+                changedAccounts = append(changedAccounts, senderAccount)
+
+                networkInfoColl := m.client.Database(database).Collection(networkInfoCollection)
+                _, err = networkInfoColl.UpdateOne(
+                    ctx,
+                    bson.D{{Key: "_id", Value: "info"}},
+                    bson.D{{Key: "$inc", Value: bson.D{
+                        {Key: "feesPaid", Value: fee},
                     }}},
                     options.Update().SetUpsert(true),
                 )
                 if err != nil {
-                    return updateResult, err
+                    return err
                 }
             }
 
-            return previousTransaction, err
+            return nil
         } else {
-            transactionDoc = &types.TransactionDoc{
-                ID:              transaction.ID,
-                PrincipaAccount: transaction.Header.Principal,
-                Fee:             transaction.Header.Fee,
-                Gas:             transaction.Header.Gas,
-                Layer:           transaction.Header.LayerID,
-                Status:          transaction.Header.Status,
-                Method:          transaction.Header.Method,
-                Complete:        false,
-            }
+            transactionDoc = pendingTransactionDoc(transaction)
 
             transactionsColl := m.client.Database(database).Collection(transactionsCollection)
 
-            insertResult, err := transactionsColl.InsertOne(
-                context.TODO(),
-                transactionDoc,
+            // a duplicate key error would abort the transaction, an existing transaction
+            // is left as it is instead
+            updateResult, err := transactionsColl.UpdateOne(
+                ctx,
+                bson.D{{Key: "_id", Value: transactionDoc.ID}},
+                bson.D{{Key: "$setOnInsert", Value: transactionDoc}},
+                options.Update().SetUpsert(true),
             )
-
-            // if already saved ignore error
-            if err != nil && docExistsErr(err) {
-                return insertResult, nil
+            if err != nil {
+                return err
             }
-
-            return insertResult, err
+            duplicate = updateResult.UpsertedCount == 0
+            return nil
         }
     }
-
-    // Execute the operations in a transaction
-    if _, err := session.WithTransaction(context.TODO(), callback); err != nil {
-        log.Println("Transaction failed: %v", err)
+    err := m.withTransaction(ctx, func(ctx context.Context) error {
+        changedAccounts = nil
+        duplicate = false
+        if err := save(ctx); err != nil || duplicate {
+            return err
+        }
+        operation := ChangeInsert
+        if result {
+            operation = ChangeUpdate
+        }
+        if err := m.recordChange(ctx, EntityTransaction, operation, transactionDoc.ID, transactionDoc); err != nil {
+            return err
+        }
+        for _, address := range changedAccounts {
+            if err := m.recordAccountChange(ctx, address); err != nil {
+                return err
+            }
+        }
+        return nil
+    })
+    if err != nil {
+        log.Printf("Transaction failed: %v", err)
+        return err
     }
-
-    fmt.Println("Transaction succeeded")
-
-    return err
-
+    return nil
 }
 
-func (m *WriteDB) SaveReward(reward *nats.Reward) error {
-    session, err := m.client.StartSession()
-    defer session.EndSession(context.TODO())
+// accountActivity adds the layer of a reward or transfer to an account update, the
+// earliest is kept as first seen and the latest as last activity.
+func accountActivity(update bson.D, layer uint32) bson.D {
+    return append(update,
+        bson.E{Key: "$min", Value: bson.D{{Key: "firstSeenLayer", Value: layer}}},
+        bson.E{Key: "$max", Value: bson.D{{Key: "lastActivityLayer", Value: layer}}},
+    )
+}
 
-    callback := func(sessionContext mongo.SessionContext) (interface{}, error) {
+func (m *WriteDB) SaveReward(ctx context.Context, reward *nats.Reward) error {
+    if m.Fenced() {
+        return ErrFenced
+    }
 
+    rewardDoc := &types.RewardsDoc{
+        Id:          reward.ID,
+        Coinbase:    reward.Coinbase,
+        LayerReward: int64(reward.LayerReward),
+        TotalReward: int64(reward.Total),
+        AtxID:       reward.AtxID,
+        NodeId:      reward.NodeID,
+        Layer:       int64(reward.Layer),
+    }
+    inserted := false
+    err := m.withTransaction(ctx, func(ctx context.Context) error {
         rewardsColl := m.client.Database(database).Collection(rewardsCollection)
         accountsColl := m.client.Database(database).Collection(accountsCollection)
         networkInfoColl := m.client.Database(database).Collection(networkInfoCollection)
 
-        rewardDoc := &types.RewardsDoc{
-            Id:          reward.ID,
-            Coinbase:    reward.Coinbase,
-            LayerReward: int64(reward.LayerReward),
-            TotalReward: int64(reward.Total),
-            AtxID:       reward.AtxID,
-            NodeId:      reward.NodeID,
-            Layer:       int64(reward.Layer),
-        }
-
         updateResult, err := rewardsColl.UpdateOne(
-            context.TODO(),
+            ctx,
             bson.D{{Key: "_id", Value: rewardDoc.Id}},
             bson.D{{Key: "$set", Value: rewardDoc}},
             options.Update().SetUpsert(true))
         if err != nil {
-            return updateResult, err
+            return err
         }
 
         // only update counts if inserted new reward
-        if updateResult.UpsertedCount == 1 {
-            updateResult, err = accountsColl.UpdateOne(
-                context.TODO(),
-                bson.D{{Key: "_id", Value: reward.Coinbase}},
-                bson.D{{Key: "$inc", Value: bson.D{
-                    {Key: "totalRewards", Value: reward.Total},
-                    {Key: "balance", Value: reward.Total},
-                }}},
-                options.Update().SetUpsert(true),
-            )
-            if err != nil {
-                return updateResult, err
-            }
-
-            updateResult, err = networkInfoColl.UpdateOne(
-                context.TODO(),
-                bson.D{{Key: "_id", Value: "info"}},
-                bson.D{{Key: "$inc", Value: bson.D{
-                    {Key: "circulatingSupply", Value: reward.Total},
-                }}},
-                options.Update().SetUpsert(true),
-            )
-            return updateResult, err
+        inserted = updateResult.UpsertedCount == 1
+        if !inserted {
+            return nil
+        }
+        _, err = accountsColl.UpdateOne(
+            ctx,
+            bson.D{{Key: "_id", Value: reward.Coinbase}},
+            accountActivity(bson.D{{Key: "$inc", Value: bson.D{
+                {Key: "totalRewards", Value: reward.Total},
+                {Key: "balance", Value: reward.Total},
+            }}}, reward.Layer),
+            options.Update().SetUpsert(true),
+        )
+        if err != nil {
+            return err
+        }
+        _, err = m.incBalanceChange(ctx, reward.Coinbase, reward.Layer, int64(reward.Total))
+        if err != nil {
+            return err
+        }
+        _, err = m.incCoinbaseRewardsTotals(ctx, reward.Coinbase, reward.Layer, reward.Total)
+        if err != nil {
+            return err
         }
-        return updateResult, err
-    }
 
-    // Execute the operations in a transaction
-    if _, err := session.WithTransaction(context.TODO(), callback); err != nil {
-        log.Println("Rewards transaction failed: %v", err)
+        _, err = networkInfoColl.UpdateOne(
+            ctx,
+            bson.D{{Key: "_id", Value: "info"}},
+            bson.D{{Key: "$inc", Value: bson.D{
+                {Key: "circulatingSupply", Value: reward.Total},
+                {Key: "issuedSubsidy", Value: reward.LayerReward},
+            }}},
+            options.Update().SetUpsert(true),
+        )
+        if err != nil {
+            return err
+        }
+        if err = m.recordChange(ctx, EntityReward, ChangeInsert, rewardDoc.Id, rewardDoc); err != nil {
+            return err
+        }
+        return m.recordAccountChange(ctx, rewardDoc.Coinbase)
+    })
+    if err != nil {
+        log.Printf("Rewards transaction failed: %v", err)
+        return err
     }
+    return nil
+}
 
-    fmt.Println("Rewards transaction succeeded")
-
-    return err
-
+func (m *WriteDB) Capabilities() Capabilities {
+    capabilities := mongoCapabilities
+    // the changes are written in the transactions of the saves they record
+    capabilities.ChangeFeed = m.transactions
+    // concurrent transactions fail with write conflicts on the epoch, network and change
+    // counters and are retried right away, a single writer saves them faster
+    capabilities.ParallelSaves = !m.transactions
+    return capabilities
 }
 
 func (m *WriteDB) CloseWrite() {
     m.client.Disconnect(context.TODO())
 }
 
-func getATXWeight(numUnits, tickCount uint64) uint64 {
+// ATXWeight is the weight of an atx, it panics when the product overflows.
+func ATXWeight(numUnits, tickCount uint64) uint64 {
     return safeMul(numUnits, tickCount)
 }
 
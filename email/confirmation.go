@@ -0,0 +1,35 @@
+package email
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+// PendingExpiry is the seconds a subscription waits for its confirmation, the digest
+// mailer deletes the pending ones older than that.
+const PendingExpiry = daySeconds
+
+// ConfirmationLink is the url on the base url that confirms the subscription of token.
+func ConfirmationLink(baseUrl string, token string) string {
+	return strings.TrimRight(baseUrl, "/") + "/emails/confirm?token=" + url.QueryEscape(token)
+}
+
+// SendConfirmation emails the confirmation link of a pending subscription to its
+// address, no digest is sent to it until the link is opened.
+func SendConfirmation(configValues *config.Config, subscription *types.EmailSubscriptionDoc) error {
+	if configValues.Emails == nil || configValues.Smtp == nil {
+		return errors.New("emails are not configured")
+	}
+	link := ConfirmationLink(configValues.Emails.BaseUrl, subscription.Token)
+	return send(configValues.Smtp, &message{
+		to:      subscription.Email,
+		subject: "Confirm your Spacemesh watchlist digest",
+		body: "This address was registered for the " + subscription.Frequency + " digest of a Spacemesh watchlist.\n" +
+			"\nConfirm it within a day to start getting the digests: " + link + "\n" +
+			"\nIgnore this email if you did not ask for it, nothing will be sent to you.\n",
+	})
+}
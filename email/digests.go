@@ -0,0 +1,292 @@
+package email
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/swarmbit/spacemesh-state-api/aggregation"
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/network"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+const daySeconds = 24 * 60 * 60
+
+// DigestMailer emails the digest of their watchlist to the registered addresses, daily
+// for the last UTC day or weekly for the last 7 days, once the reward digests of the
+// last day are complete. A digest that failed to send is sent on the next run, a
+// subscription that missed several days only gets the last period. Subscriptions get
+// nothing until they are confirmed and are deleted when not confirmed within a day.
+type DigestMailer struct {
+	reloader     *config.Reloader
+	writeDB      database.WriteStore
+	readDB       database.ReadStore
+	networkUtils *network.NetworkUtils
+	ticker       *time.Ticker
+	refreshTime  int
+}
+
+// emailsRefreshTime is the minutes between runs, 30 when not configured.
+func emailsRefreshTime(configValues *config.Config) int {
+	if configValues.Emails != nil && configValues.Emails.RefreshTime > 0 {
+		return configValues.Emails.RefreshTime
+	}
+	return 30
+}
+
+func NewDigestMailer(reloader *config.Reloader, writeDB database.WriteStore, readDB database.ReadStore, networkUtils *network.NetworkUtils) *DigestMailer {
+	d := &DigestMailer{
+		reloader:     reloader,
+		writeDB:      writeDB,
+		readDB:       readDB,
+		networkUtils: networkUtils,
+	}
+	d.refreshTime = emailsRefreshTime(reloader.Current())
+	d.ticker = time.NewTicker(time.Duration(d.refreshTime) * time.Minute)
+	go func() {
+		d.run()
+		for range d.ticker.C {
+			d.run()
+		}
+	}()
+	return d
+}
+
+// Reload applies a changed refresh time, the smtp server and base url are read from the
+// reloader on every run.
+func (d *DigestMailer) Reload(configValues *config.Config) {
+	refreshTime := emailsRefreshTime(configValues)
+	if refreshTime != d.refreshTime {
+		d.refreshTime = refreshTime
+		d.ticker.Reset(time.Duration(refreshTime) * time.Minute)
+	}
+}
+
+// FirstLastDay is the LastDay of a subscription created at createdAt, its first digest
+// covers the day it was created in.
+func FirstLastDay(createdAt int64) int64 {
+	return createdAt/daySeconds*daySeconds - daySeconds
+}
+
+func (d *DigestMailer) run() {
+	configValues := d.reloader.Current()
+	if configValues.Emails == nil || !configValues.Emails.Enabled || configValues.Smtp == nil {
+		return
+	}
+	// a fenced off leader leaves the digests to the new one
+	if d.writeDB.Fenced() {
+		return
+	}
+	expired, err := d.writeDB.DeletePendingEmailSubscriptions(time.Now().Unix() - PendingExpiry)
+	if err != nil {
+		log.Printf("Failed to delete unconfirmed email subscriptions: %v", err)
+	} else if expired > 0 {
+		log.Printf("Deleted %d unconfirmed email subscriptions", expired)
+	}
+	lastDay, ok := d.lastCompleteDay()
+	if !ok {
+		return
+	}
+	subscriptions, err := d.readDB.GetEmailSubscriptions("")
+	if err != nil {
+		log.Printf("Failed to get email subscriptions: %v", err)
+		return
+	}
+
+	sent := 0
+	for _, subscription := range subscriptions {
+		if subscription.Pending {
+			continue
+		}
+		days := int64(1)
+		if subscription.Frequency == types.EmailWeekly {
+			days = 7
+		}
+		if subscription.LastDay+days*daySeconds > lastDay {
+			continue
+		}
+		fromDay := lastDay - (days-1)*daySeconds
+		body, err := d.digest(subscription.Owner, fromDay, lastDay)
+		if err != nil {
+			log.Printf("Failed to build email digest %s: %v", subscription.ID, err)
+			continue
+		}
+		// the period is claimed before sending so a digest is not sent twice, a failed
+		// send gives it back for the next run
+		claimed, err := d.writeDB.ClaimEmailSubscriptionLastDay(subscription.ID, lastDay)
+		if err != nil {
+			log.Printf("Failed to claim email digest %s: %v", subscription.ID, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+		// an empty watchlist has nothing to report, the period is skipped
+		if body != "" {
+			unsubscribe := strings.TrimRight(configValues.Emails.BaseUrl, "/") + "/emails/unsubscribe?token=" + url.QueryEscape(subscription.Token)
+			err = send(configValues.Smtp, &message{
+				to:          subscription.Email,
+				subject:     "Spacemesh watchlist digest " + period(fromDay, lastDay),
+				body:        body + "\nUnsubscribe: " + unsubscribe + "\n",
+				unsubscribe: unsubscribe,
+			})
+			if err != nil {
+				log.Printf("Failed to send email digest %s: %v", subscription.ID, err)
+				if err := d.writeDB.UpdateEmailSubscriptionLastDay(subscription.ID, subscription.LastDay); err != nil {
+					log.Printf("Failed to update email digest %s: %v", subscription.ID, err)
+				}
+				continue
+			}
+			sent++
+		}
+	}
+	if sent > 0 {
+		log.Printf("Sent %d email digests for %s", sent, time.Unix(lastDay, 0).UTC().Format(time.DateOnly))
+	}
+}
+
+// lastCompleteDay is the start of the last day whose reward digests are complete, the
+// digest aggregator completes the days in order.
+func (d *DigestMailer) lastCompleteDay() (int64, bool) {
+	last, err := d.readDB.GetLastRewardDigest()
+	if err != nil {
+		log.Printf("Failed to get last reward digest for emails: %v", err)
+		return 0, false
+	}
+	if last.Id.Coinbase == "" {
+		return 0, false
+	}
+	if last.Complete {
+		return last.Id.Day, true
+	}
+	return last.Id.Day - daySeconds, true
+}
+
+func period(fromDay int64, toDay int64) string {
+	from := time.Unix(fromDay, 0).UTC().Format(time.DateOnly)
+	if fromDay == toDay {
+		return from
+	}
+	return from + " to " + time.Unix(toDay, 0).UTC().Format(time.DateOnly)
+}
+
+// digest is the text of the digest of the watchlist of owner for the days from and to
+// included, empty when nothing is watched. Rewards are read from the reward digests, the
+// atx status and eligibility are for the current epoch.
+func (d *DigestMailer) digest(owner string, fromDay int64, toDay int64) (string, error) {
+	items, err := d.readDB.GetWatchlistItems(owner)
+	if err != nil || len(items) == 0 {
+		return "", err
+	}
+	currentLayer := uint32((time.Now().Unix() - config.GenesisEpochSeconds) / config.LayerDuration)
+	epoch := currentLayer / config.LayersPerEpoch
+	epochAtx, err := d.readDB.GetAtxEpoch(uint64(epoch))
+	if err != nil {
+		return "", err
+	}
+
+	var addresses, nodes strings.Builder
+	for _, item := range items {
+		switch item.Kind {
+		case types.WatchlistKindAddress:
+			if err := d.addressDigest(&addresses, item.Item, fromDay, toDay, epoch, epochAtx); err != nil {
+				return "", err
+			}
+		case types.WatchlistKindNode:
+			if err := d.nodeDigest(&nodes, item.Item, fromDay, toDay, epoch, epochAtx); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Watchlist digest for %s\n", period(fromDay, toDay))
+	if addresses.Len() > 0 {
+		b.WriteString("\nAddresses\n" + addresses.String())
+	}
+	if nodes.Len() > 0 {
+		b.WriteString("\nNodes\n" + nodes.String())
+	}
+	fmt.Fprintf(&b, "\nEligibility for epoch %d is from the atxs published so far in epoch %d, more may still arrive.\n", epoch+1, epoch)
+	return b.String(), nil
+}
+
+func (d *DigestMailer) addressDigest(b *strings.Builder, address string, fromDay int64, toDay int64, epoch uint32, epochAtx *types.AtxEpochDoc) error {
+	digests, err := d.readDB.GetRewardDigests(address, fromDay, toDay)
+	if err != nil {
+		return err
+	}
+	count, rewards, usdValue := int64(0), int64(0), int64(0)
+	for _, v := range digests {
+		count += v.Count
+		rewards += v.Rewards
+		if v.USDValue < 0 || usdValue < 0 {
+			usdValue = -1
+		} else {
+			usdValue += v.USDValue
+		}
+	}
+	atxs, err := d.readDB.GetAccountAtxList(address, uint64(epoch))
+	if err != nil {
+		return err
+	}
+	slots := int32(0)
+	for _, atx := range atxs {
+		atxSlots, err := d.slots(atx, epoch, epochAtx)
+		if err != nil {
+			return err
+		}
+		slots += atxSlots
+	}
+
+	fmt.Fprintf(b, "\n%s\n", address)
+	if count == 0 {
+		b.WriteString("  Rewards: none\n")
+	} else {
+		value := ""
+		if usdValue >= 0 {
+			value = fmt.Sprintf(" ($%.2f)", float64(usdValue)/network.OneSmesh)
+		}
+		fmt.Fprintf(b, "  Rewards: %d rewards, %s SMH%s\n", count, network.ToSmesh(uint64(rewards)), value)
+	}
+	fmt.Fprintf(b, "  Epoch %d: %d eligible slots from %d atxs\n", epoch+1, slots, len(atxs))
+	return nil
+}
+
+func (d *DigestMailer) nodeDigest(b *strings.Builder, nodeId string, fromDay int64, toDay int64, epoch uint32, epochAtx *types.AtxEpochDoc) error {
+	atx, err := d.readDB.GetPreviousAtx(nodeId, epoch+1)
+	if err != nil {
+		return err
+	}
+	rewards, err := d.readDB.CountNodeRewardsLayers(nodeId, aggregation.DayFirstLayer(fromDay), aggregation.DayLastLayer(toDay))
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(b, "\n%s\n", nodeId)
+	fmt.Fprintf(b, "  Rewards: %d\n", rewards)
+	if atx.AtxID == "" || atx.PublishEpoch != epoch {
+		fmt.Fprintf(b, "  Atx of epoch %d: not published yet\n", epoch)
+		fmt.Fprintf(b, "  Epoch %d: not eligible yet\n", epoch+1)
+		return nil
+	}
+	slots, err := d.slots(atx, epoch, epochAtx)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(b, "  Atx of epoch %d: published, %s\n", epoch, atx.AtxID)
+	fmt.Fprintf(b, "  Epoch %d: %d eligible slots\n", epoch+1, slots)
+	return nil
+}
+
+// slots are the slots in the next epoch of an atx published in epoch.
+func (d *DigestMailer) slots(atx *types.AtxDoc, epoch uint32, epochAtx *types.AtxEpochDoc) (int32, error) {
+	if epochAtx.TotalWeight == 0 {
+		return 0, nil
+	}
+	return d.networkUtils.GetNumberOfSlots(atx.Weight, epochAtx.TotalWeight, epoch+1)
+}
@@ -0,0 +1,107 @@
+package email
+
+import (
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/swarmbit/spacemesh-state-api/config"
+)
+
+// message is a plain text email, unsubscribe is the url of its unsubscribe link.
+type message struct {
+	to          string
+	subject     string
+	body        string
+	unsubscribe string
+}
+
+// send delivers the message through the smtp server. The connection is encrypted with
+// tls on port 465 and with STARTTLS on other ports when the server offers it, the
+// credentials are only sent over an encrypted connection.
+func send(smtpConfig *config.SmtpConfig, msg *message) error {
+	from, err := mail.ParseAddress(smtpConfig.From)
+	if err != nil {
+		return fmt.Errorf("invalid from address: %w", err)
+	}
+	address := net.JoinHostPort(smtpConfig.Host, strconv.Itoa(smtpConfig.Port))
+	tlsConfig := &tls.Config{ServerName: smtpConfig.Host}
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+
+	var conn net.Conn
+	if smtpConfig.Port == 465 {
+		conn, err = tls.DialWithDialer(dialer, "tcp", address, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", address)
+	}
+	if err != nil {
+		return err
+	}
+	if err := conn.SetDeadline(time.Now().Add(30 * time.Second)); err != nil {
+		conn.Close()
+		return err
+	}
+	client, err := smtp.NewClient(conn, smtpConfig.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if smtpConfig.Port != 465 {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(tlsConfig); err != nil {
+				return err
+			}
+		}
+	}
+	if smtpConfig.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", smtpConfig.Username, smtpConfig.Password, smtpConfig.Host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(from.Address); err != nil {
+		return err
+	}
+	if err := client.Rcpt(msg.to); err != nil {
+		return err
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(msg.bytes(from)); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+func (m *message) bytes(from *mail.Address) []byte {
+	var b strings.Builder
+	header := func(key string, value string) {
+		b.WriteString(key + ": " + value + "\r\n")
+	}
+	header("From", from.String())
+	header("To", m.to)
+	header("Subject", mime.QEncoding.Encode("utf-8", m.subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+	header("Content-Type", "text/plain; charset=utf-8")
+	header("Content-Transfer-Encoding", "8bit")
+	if m.unsubscribe != "" {
+		header("List-Unsubscribe", "<"+m.unsubscribe+">")
+		header("List-Unsubscribe-Post", "List-Unsubscribe=One-Click")
+	}
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(m.body, "\n", "\r\n"))
+	return []byte(b.String())
+}
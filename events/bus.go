@@ -0,0 +1,102 @@
+package events
+
+import (
+	"log"
+	"sync"
+
+	"github.com/swarmbit/spacemesh-state-api/metrics"
+)
+
+// Kinds of the events the sink publishes once they are saved, the payload of each is
+// the decoded message of its stream, and of the events the aggregators publish.
+const (
+	// Layer carries a *nats.LayerUpdate
+	Layer = "layer"
+	// Reward carries a *nats.Reward
+	Reward = "reward"
+	// Atx carries a *nats.Atx
+	Atx = "atx"
+	// TransactionResult carries the *nats.Transaction of an applied transaction
+	TransactionResult = "transaction_result"
+	// TransactionCreated carries the *nats.Transaction of a transaction not applied yet
+	TransactionCreated = "transaction_created"
+	// Malfeasance carries a *nats.Malfeasance
+	Malfeasance = "malfeasance"
+	// RewardDigest carries the *types.RewardDigestDoc of a coinbase once its day is
+	// complete, published by the digest aggregator
+	RewardDigest = "reward_digest"
+)
+
+// Event is an entity written by the sink or an aggregator. Layer is the layer it belongs
+// to, 0 when it is not tied to one.
+type Event struct {
+	Kind    string
+	Layer   uint32
+	Payload interface{}
+}
+
+type subscriber struct {
+	name    string
+	handler func(event *Event)
+}
+
+// Bus delivers the events written by the sink to the modules that subscribed to them, the
+// sink does not know who consumes what it saved. Handlers run in the goroutine of the
+// consumer that saved the event, after the save and before the ack, so they must queue
+// any slow work instead of blocking the sink. A redelivered message is published again.
+// Aggregators publish from their own goroutine.
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers map[string][]*subscriber
+}
+
+func NewBus() *Bus {
+	return &Bus{subscribers: make(map[string][]*subscriber)}
+}
+
+// Subscribe runs handler for every event of kind whose payload is a *T, name labels the
+// subscriber in the logs and metrics. Subscribers of a kind run in the order they
+// subscribed.
+func Subscribe[T any](b *Bus, name string, kind string, handler func(payload *T)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers[kind] = append(b.subscribers[kind], &subscriber{
+		name: name,
+		handler: func(event *Event) {
+			payload, ok := event.Payload.(*T)
+			if !ok {
+				log.Printf("Subscriber %s of %s events got a %T payload", name, kind, event.Payload)
+				return
+			}
+			handler(payload)
+		},
+	})
+}
+
+// SubscribeEvents runs handler for every event of kind, whatever its payload.
+func (b *Bus) SubscribeEvents(name string, kind string, handler func(event *Event)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers[kind] = append(b.subscribers[kind], &subscriber{name: name, handler: handler})
+}
+
+// Publish delivers the event to the subscribers of its kind. A subscriber that panics is
+// logged and skipped, the others still get the event.
+func (b *Bus) Publish(event *Event) {
+	b.mu.RLock()
+	subscribers := b.subscribers[event.Kind]
+	b.mu.RUnlock()
+	for _, v := range subscribers {
+		v.deliver(event)
+	}
+}
+
+func (s *subscriber) deliver(event *Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Subscriber %s failed on %s event of layer %d: %v", s.name, event.Kind, event.Layer, r)
+			metrics.EventHandlerFailures.WithLabelValues(s.name).Inc()
+		}
+	}()
+	s.handler(event)
+}
@@ -0,0 +1,158 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const namespace = "spacemesh_state_api"
+
+var (
+	ReorgsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reorgs_total",
+		Help:      "Number of detected layer rollbacks",
+	})
+	ReorgDepth = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "reorg_depth_layers",
+		Help:      "Depth in layers of detected rollbacks",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 10),
+	})
+	ReorgAffectedDocuments = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reorg_affected_documents_total",
+		Help:      "Rewards and transactions at or above the rollback layer",
+	})
+	IngestedEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ingested_events_total",
+		Help:      "Events saved by the nats sink",
+	}, []string{"entity"})
+	ApiRequests = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "api_requests_total",
+		Help:      "Requests served by the api",
+	})
+	RetentionPruned = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "retention_pruned_documents_total",
+		Help:      "Documents deleted by the retention policies",
+	}, []string{"collection"})
+	ReplayedMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "replayed_messages_total",
+		Help:      "Messages read again from a stream to fill a gap after a consumer was recreated",
+	}, []string{"stream"})
+	ConsistencyGaps = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "consistency_gaps",
+		Help:      "Gaps found by the last consistency check",
+	}, []string{"kind"})
+	SinkLeader = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "sink_leader",
+		Help:      "1 while this instance holds the writer lease",
+	})
+	PoetUp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "poet_up",
+		Help:      "1 when the last probe of the poet info endpoint succeeded",
+	}, []string{"poet"})
+	PendingExpired = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "pending_transactions_expired_total",
+		Help:      "Created transactions deleted after no result came within the expire layers",
+	})
+	NodeConnected = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "node_connected",
+		Help:      "1 while the grpc connection to the node is ready",
+	})
+	NodeSynced = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "node_synced",
+		Help:      "1 when the node reported it is synced on the last query",
+	})
+	ClickHouseInserted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "clickhouse_inserted_rows_total",
+		Help:      "Rows inserted in clickhouse",
+	}, []string{"table"})
+	ClickHouseFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "clickhouse_failed_inserts_total",
+		Help:      "Batch inserts in clickhouse that failed and will be retried",
+	}, []string{"table"})
+	ClickHouseDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "clickhouse_dropped_rows_total",
+		Help:      "Rows not written to clickhouse because the queue was full",
+	}, []string{"table"})
+	ClickHousePending = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "clickhouse_pending_rows",
+		Help:      "Rows waiting to be inserted in clickhouse",
+	}, []string{"table"})
+	ClickHouseLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "clickhouse_lag_seconds",
+		Help:      "Age of the oldest row of the last batch inserted in clickhouse",
+	}, []string{"table"})
+	NatsConnected = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "nats_connected",
+		Help:      "1 while the sink is connected to the nats server",
+	})
+	SinkSaveFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "sink_save_failures_total",
+		Help:      "Messages the sink failed to save, by entity and error kind",
+	}, []string{"entity", "kind"})
+	SinkQueueDepth = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "sink_queue_depth",
+		Help:      "Decoded events waiting for a sink writer, by entity",
+	}, []string{"entity"})
+	SinkBackpressureWaits = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "sink_backpressure_waits_total",
+		Help:      "Times a sink stopped fetching because its write queue was full, by entity",
+	}, []string{"entity"})
+	SinkBreakerState = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "sink_breaker_state",
+		Help:      "State of the sink circuit breaker, 0 closed, 1 open and 2 half open",
+	})
+	SinkBreakerTrips = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "sink_breaker_trips_total",
+		Help:      "Times the sink circuit breaker opened and the sinks stopped fetching",
+	})
+	WebhookDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_delivery_attempts_total",
+		Help:      "Webhook delivery attempts by outcome, delivered, retried or failed",
+	}, []string{"outcome"})
+	PublishedEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "published_events_total",
+		Help:      "Enriched events published on the state subjects by subject and outcome, published or failed",
+	}, []string{"subject", "outcome"})
+	EventHandlerFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "event_handler_failures_total",
+		Help:      "Sink events a subscriber of the event bus or a processor failed on, by subscriber",
+	}, []string{"subscriber"})
+)
+
+// CounterValue reads the current value of a counter, it is used to persist
+// rates next to the data without going through a prometheus server.
+func CounterValue(counter prometheus.Counter) float64 {
+	metric := &dto.Metric{}
+	if err := counter.Write(metric); err != nil {
+		return 0
+	}
+	return metric.GetCounter().GetValue()
+}
@@ -0,0 +1,161 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"reflect"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
+	"go.mongodb.org/mongo-driver/bson/bsonrw"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// VersionField is the field every versioned document records the schema version it was
+// written with in, documents without it were written before versioning and have version 0.
+const VersionField = "schemaVersion"
+
+// Upgrader is a DocumentUpgrade of any document type.
+type Upgrader interface {
+	CollectionName() string
+	Current() int
+	Register(reg *bsoncodec.Registry)
+	UpgradeCollection(db *mongo.Database, batchSize int) (int64, error)
+}
+
+// DocumentUpgrade evolves the stored format of the documents of a collection without a
+// resync. Steps[i] brings a document of version i to version i+1, so the current version
+// is the number of steps, a step must leave the document unchanged when it fails.
+// Documents are upgraded in memory when they are decoded and stored by
+// UpgradeCollection.
+type DocumentUpgrade[T any] struct {
+	Collection string
+	// Version returns the schema version field of doc
+	Version func(doc *T) *int
+	Steps   []func(doc *T) error
+}
+
+func (u *DocumentUpgrade[T]) CollectionName() string {
+	return u.Collection
+}
+
+// Current is the version new documents are written with.
+func (u *DocumentUpgrade[T]) Current() int {
+	return len(u.Steps)
+}
+
+// Upgrade applies the steps after the version of doc and reports if any was applied.
+// A failed step leaves doc at the version before it.
+func (u *DocumentUpgrade[T]) Upgrade(doc *T) (bool, error) {
+	version := u.Version(doc)
+	upgraded := false
+	for *version < u.Current() {
+		if err := u.Steps[*version](doc); err != nil {
+			return upgraded, fmt.Errorf("upgrade %s document to version %d: %w", u.Collection, *version+1, err)
+		}
+		*version++
+		upgraded = true
+	}
+	return upgraded, nil
+}
+
+// Register makes reg upgrade the documents of type T as they are decoded, so reads get
+// the current format before UpgradeCollection stored it. Nothing is written back, read
+// clients may not write.
+func (u *DocumentUpgrade[T]) Register(reg *bsoncodec.Registry) {
+	reg.RegisterTypeDecoder(reflect.TypeOf((*T)(nil)).Elem(), bsoncodec.ValueDecoderFunc(u.decode))
+}
+
+func (u *DocumentUpgrade[T]) decode(_ bsoncodec.DecodeContext, vr bsonrw.ValueReader, val reflect.Value) error {
+	if vr.Type() == bsontype.Null {
+		val.Set(reflect.Zero(val.Type()))
+		return vr.ReadNull()
+	}
+	raw, err := bsonrw.Copier{}.CopyDocumentToBytes(vr)
+	if err != nil {
+		return err
+	}
+	var doc T
+	// the default registry decodes the fields without coming back here
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		return err
+	}
+	// a failed step leaves the version it was read with, UpgradeCollection reports it
+	u.Upgrade(&doc)
+	val.Set(reflect.ValueOf(doc))
+	return nil
+}
+
+// UpgradeCollection stores the upgraded documents older than the current version in
+// batches of batchSize and returns how many were stored. A document is only updated
+// while it keeps the version it was read with, a write made meanwhile already stored
+// the current version. Documents a step fails on are logged and left as they are.
+func (u *DocumentUpgrade[T]) UpgradeCollection(db *mongo.Database, batchSize int) (int64, error) {
+	coll := db.Collection(u.Collection)
+	outdated := bson.E{Key: VersionField, Value: bson.D{{Key: "$not", Value: bson.D{{Key: "$gte", Value: u.Current()}}}}}
+	findOptions := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(int64(batchSize))
+
+	var upgraded int64
+	var after *bson.RawValue
+	for {
+		filter := bson.D{outdated}
+		if after != nil {
+			filter = append(filter, bson.E{Key: "_id", Value: bson.D{{Key: "$gt", Value: *after}}})
+		}
+		cursor, err := coll.Find(context.TODO(), filter, findOptions)
+		if err != nil {
+			return upgraded, err
+		}
+
+		read := 0
+		var models []mongo.WriteModel
+		for cursor.Next(context.TODO()) {
+			read++
+			id := cursor.Current.Lookup("_id")
+			// the cursor reuses its buffer for the next batch
+			id.Value = append([]byte(nil), id.Value...)
+			after = &id
+
+			var doc T
+			if err := bson.Unmarshal(cursor.Current, &doc); err != nil {
+				cursor.Close(context.TODO())
+				return upgraded, err
+			}
+			version := *u.Version(&doc)
+			ok, err := u.Upgrade(&doc)
+			if err != nil {
+				log.Printf("Skipping %s document %s: %v", u.Collection, id, err)
+				continue
+			}
+			if !ok {
+				continue
+			}
+			models = append(models, mongo.NewUpdateOneModel().
+				SetFilter(bson.D{
+					{Key: "_id", Value: id},
+					{Key: VersionField, Value: bson.D{{Key: "$not", Value: bson.D{{Key: "$gt", Value: version}}}}},
+				}).
+				SetUpdate(bson.D{{Key: "$set", Value: &doc}}))
+		}
+		err = cursor.Err()
+		cursor.Close(context.TODO())
+		if err != nil {
+			return upgraded, err
+		}
+
+		if len(models) > 0 {
+			result, err := coll.BulkWrite(context.TODO(), models, options.BulkWrite().SetOrdered(false))
+			if err != nil {
+				return upgraded, err
+			}
+			upgraded += result.ModifiedCount
+			log.Printf("Upgraded %d %s documents", upgraded, u.Collection)
+		}
+		if read < batchSize {
+			return upgraded, nil
+		}
+	}
+}
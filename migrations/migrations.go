@@ -0,0 +1,195 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const migrationsCollection = "migrations"
+
+// CollectionIndexes are the indexes a collection needs to serve its queries.
+type CollectionIndexes struct {
+	Collection string
+	Indexes    []mongo.IndexModel
+}
+
+// Migration is a schema change applied once per database. Applied versions are
+// recorded in the migrations collection and skipped on the next run.
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(db *mongo.Database) error
+}
+
+type MigrationDoc struct {
+	Version     int    `bson:"_id"`
+	Description string `bson:"description"`
+	AppliedAt   int64  `bson:"appliedAt"`
+}
+
+// Run creates the missing indexes and applies the pending migrations in version order.
+func Run(db *mongo.Database, indexes []CollectionIndexes, migrations []Migration) error {
+	err := EnsureIndexes(db, indexes)
+	if err != nil {
+		return err
+	}
+
+	applied, err := AppliedVersions(db)
+	if err != nil {
+		return err
+	}
+
+	pending := make([]Migration, 0, len(migrations))
+	for _, v := range migrations {
+		if !applied[v.Version] {
+			pending = append(pending, v)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].Version < pending[j].Version
+	})
+
+	migrationsColl := db.Collection(migrationsCollection)
+	for _, v := range pending {
+		log.Printf("Apply migration %d: %s", v.Version, v.Description)
+		if v.Up != nil {
+			if err := v.Up(db); err != nil {
+				return fmt.Errorf("migration %d failed: %w", v.Version, err)
+			}
+		}
+		_, err := migrationsColl.InsertOne(context.TODO(), &MigrationDoc{
+			Version:     v.Version,
+			Description: v.Description,
+			AppliedAt:   time.Now().Unix(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EnsureIndexes creates every index that does not exist yet. Creating an index that
+// already exists with the same keys and options is a no-op, an existing index with other
+// options is changed to the ones given, see replaceIndex.
+func EnsureIndexes(db *mongo.Database, indexes []CollectionIndexes) error {
+	for _, v := range indexes {
+		coll := db.Collection(v.Collection)
+		names, err := coll.Indexes().CreateMany(context.TODO(), v.Indexes)
+		if indexConflict(err) {
+			names, err = createIndexes(db, coll, v.Indexes)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create indexes on %s: %w", v.Collection, err)
+		}
+		log.Printf("Verified indexes on %s: %v", v.Collection, names)
+	}
+	return nil
+}
+
+// indexConflict reports if an index exists with the same name or keys as the one
+// created but other options.
+func indexConflict(err error) bool {
+	var serverErr mongo.ServerError
+	return errors.As(err, &serverErr) && (serverErr.HasErrorCode(indexOptionsConflict) || serverErr.HasErrorCode(indexKeySpecsConflict))
+}
+
+const (
+	indexNotFound         = 27
+	indexOptionsConflict  = 85
+	indexKeySpecsConflict = 86
+)
+
+// createIndexes creates the indexes one by one and replaces the conflicting ones.
+func createIndexes(db *mongo.Database, coll *mongo.Collection, indexes []mongo.IndexModel) ([]string, error) {
+	names := make([]string, 0, len(indexes))
+	for _, index := range indexes {
+		name, err := coll.Indexes().CreateOne(context.TODO(), index)
+		if indexConflict(err) {
+			name, err = replaceIndex(db, coll, index)
+		}
+		if err != nil {
+			return names, err
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// replaceIndex changes an existing index to the options of index. A changed TTL is set
+// in place with collMod, other options can not be changed so the index is dropped and
+// created again.
+func replaceIndex(db *mongo.Database, coll *mongo.Collection, index mongo.IndexModel) (string, error) {
+	name := indexName(index)
+	if index.Options != nil && index.Options.ExpireAfterSeconds != nil {
+		err := db.RunCommand(context.TODO(), bson.D{
+			{Key: "collMod", Value: coll.Name()},
+			{Key: "index", Value: bson.D{
+				{Key: "name", Value: name},
+				{Key: "expireAfterSeconds", Value: *index.Options.ExpireAfterSeconds},
+			}},
+		}).Err()
+		if err == nil {
+			log.Printf("Changed the expiry of index %s on %s", name, coll.Name())
+			return name, nil
+		}
+		log.Printf("Failed to change the expiry of index %s on %s, recreating it: %v", name, coll.Name(), err)
+	}
+	_, err := coll.Indexes().DropOne(context.TODO(), name)
+	var serverErr mongo.ServerError
+	if errors.As(err, &serverErr) && serverErr.HasErrorCode(indexNotFound) {
+		// the index with the same keys has another name
+		err = db.RunCommand(context.TODO(), bson.D{
+			{Key: "dropIndexes", Value: coll.Name()},
+			{Key: "index", Value: index.Keys},
+		}).Err()
+	}
+	if err != nil {
+		return "", err
+	}
+	log.Printf("Recreating index %s on %s with new options", name, coll.Name())
+	return coll.Indexes().CreateOne(context.TODO(), index)
+}
+
+// indexName is the name of index, the one mongo derives from its keys when it has none.
+func indexName(index mongo.IndexModel) string {
+	if index.Options != nil && index.Options.Name != nil {
+		return *index.Options.Name
+	}
+	keys, ok := index.Keys.(bson.D)
+	if !ok {
+		return ""
+	}
+	parts := make([]string, 0, len(keys)*2)
+	for _, v := range keys {
+		parts = append(parts, v.Key, fmt.Sprint(v.Value))
+	}
+	return strings.Join(parts, "_")
+}
+
+func AppliedVersions(db *mongo.Database) (map[int]bool, error) {
+	cursor, err := db.Collection(migrationsCollection).Find(context.TODO(), bson.D{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.TODO())
+
+	var docs []*MigrationDoc
+	if err = cursor.All(context.TODO(), &docs); err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int]bool, len(docs))
+	for _, v := range docs {
+		applied[v.Version] = true
+	}
+	return applied, nil
+}
@@ -0,0 +1,75 @@
+package network
+
+import (
+    "math/big"
+
+    "github.com/swarmbit/spacemesh-state-api/types"
+)
+
+// RewardsCalculator exposes the subsidy and slot math of NetworkUtils for smeshers
+// that are not yet part of the network.
+type RewardsCalculator struct {
+    networkUtils *NetworkUtils
+}
+
+func NewRewardsCalculator(networkUtils *NetworkUtils) *RewardsCalculator {
+    return &RewardsCalculator{
+        networkUtils: networkUtils,
+    }
+}
+
+// EstimateWeight derives the weight of numUnits from the average ticks per unit
+// of the atxs already in the network.
+func (r *RewardsCalculator) EstimateWeight(numUnits uint64, totalWeight uint64, totalEffectiveNumUnits uint64) uint64 {
+    if totalEffectiveNumUnits == 0 {
+        return 0
+    }
+    weight := new(big.Int).SetUint64(totalWeight)
+    weight.Mul(weight, new(big.Int).SetUint64(numUnits))
+    weight.Div(weight, new(big.Int).SetUint64(totalEffectiveNumUnits))
+    return weight.Uint64()
+}
+
+// EpochRewards returns the share of the epoch subsidy for weight out of totalWeight.
+func (r *RewardsCalculator) EpochRewards(weight uint64, totalWeight uint64, epoch uint32) uint64 {
+    if totalWeight == 0 {
+        return 0
+    }
+    rewards := new(big.Int).SetUint64(r.networkUtils.GetEpochSubsidy(uint64(epoch)))
+    rewards.Mul(rewards, new(big.Int).SetUint64(weight))
+    rewards.Div(rewards, new(big.Int).SetUint64(totalWeight))
+    return rewards.Uint64()
+}
+
+// EstimateRewards projects the rewards of a smesher with numUnits joining a network with
+// the given totals for epochs starting at fromEpoch, assuming the total weight stays the same.
+func (r *RewardsCalculator) EstimateRewards(numUnits uint64, totalWeight uint64, totalEffectiveNumUnits uint64, fromEpoch uint32, epochs int) (*types.EstimatedRewards, error) {
+    weight := r.EstimateWeight(numUnits, totalWeight, totalEffectiveNumUnits)
+    networkWeight := totalWeight + weight
+
+    estimated := &types.EstimatedRewards{
+        NumUnits:        numUnits,
+        EstimatedWeight: weight,
+        TotalWeight:     networkWeight,
+        Epochs:          make([]*types.EstimatedEpochRewards, 0, epochs),
+    }
+
+    for i := 0; i < epochs; i++ {
+        epoch := fromEpoch + uint32(i)
+        slots := int32(0)
+        if weight > 0 {
+            var err error
+            slots, err = r.networkUtils.GetNumberOfSlots(weight, networkWeight, epoch)
+            if err != nil {
+                return nil, err
+            }
+        }
+        estimated.Epochs = append(estimated.Epochs, &types.EstimatedEpochRewards{
+            Epoch:            epoch,
+            EpochSubsidy:     r.networkUtils.GetEpochSubsidy(uint64(epoch)),
+            Slots:            slots,
+            PredictedRewards: r.EpochRewards(weight, networkWeight, epoch),
+        })
+    }
+    return estimated, nil
+}
@@ -0,0 +1,21 @@
+package network
+
+import (
+    "github.com/swarmbit/spacemesh-state-api/config"
+    "github.com/swarmbit/spacemesh-state-api/types"
+)
+
+// GenesisLedger returns the balances of the genesis ledger of the network, the vaults
+// hold their whole amount at genesis.
+func GenesisLedger() []*types.AccountDoc {
+    genesisAccounts := config.GenesisAccounts()
+    genesisVaults := config.GenesisVaults()
+    accounts := make([]*types.AccountDoc, 0, len(genesisAccounts)+len(genesisVaults))
+    for _, v := range genesisAccounts {
+        accounts = append(accounts, &types.AccountDoc{Address: v.Address, Balance: v.Balance})
+    }
+    for _, v := range genesisVaults {
+        accounts = append(accounts, &types.AccountDoc{Address: v.Address, Balance: v.Amount})
+    }
+    return accounts
+}
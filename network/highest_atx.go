@@ -0,0 +1,68 @@
+package network
+
+import (
+    "fmt"
+
+    "github.com/swarmbit/spacemesh-state-api/config"
+    "github.com/swarmbit/spacemesh-state-api/types"
+)
+
+// HighestAtxStrategy picks the Rank highest atx of a publish epoch by tick height, the
+// atxs of malfeasant nodes are candidates only with the any strategy.
+type HighestAtxStrategy struct {
+    Name string
+    Rank int
+}
+
+var defaultHighestAtxStrategy = &HighestAtxStrategy{Name: config.HighestAtxHonest, Rank: 1}
+
+// NewHighestAtxStrategy returns the strategy name with rank, honest when name is empty and
+// the highest when rank is 0.
+func NewHighestAtxStrategy(name string, rank int) (*HighestAtxStrategy, error) {
+    if name == "" {
+        name = config.HighestAtxHonest
+    }
+    if name != config.HighestAtxHonest && name != config.HighestAtxAny {
+        return nil, fmt.Errorf("highest atx strategy must be %s or %s, got %q", config.HighestAtxHonest, config.HighestAtxAny, name)
+    }
+    if rank == 0 {
+        rank = 1
+    }
+    if rank < 0 || rank > config.MaxHighestAtxRank {
+        return nil, fmt.Errorf("highest atx rank must be between 1 and %d, got %d", config.MaxHighestAtxRank, rank)
+    }
+    return &HighestAtxStrategy{Name: name, Rank: rank}, nil
+}
+
+func (s *HighestAtxStrategy) isDefault() bool {
+    return *s == *defaultHighestAtxStrategy
+}
+
+// HighestAtxStrategy is the configured strategy of the network info highest atx.
+func (n *NetworkState) HighestAtxStrategy() *HighestAtxStrategy {
+    return n.highestAtxStrategy.Load()
+}
+
+// HighestAtx returns the atx of the publish epoch strategy picks, an empty document when
+// the epoch has fewer candidates than the rank. Equal heights are decided by the lowest id.
+func (n *NetworkState) HighestAtx(epoch uint64, strategy *HighestAtxStrategy) (*types.AtxDoc, error) {
+    var excludedNodes []string
+    if strategy.Name == config.HighestAtxHonest {
+        malfeasanceNodes, err := n.db.GetMalfeasanceNodes()
+        if err != nil {
+            return nil, err
+        }
+        excludedNodes = make([]string, len(malfeasanceNodes))
+        for i, v := range malfeasanceNodes {
+            excludedNodes[i] = v.ID
+        }
+    }
+    atxs, err := n.db.GetHighestAtxs(epoch, excludedNodes, int64(strategy.Rank))
+    if err != nil {
+        return nil, err
+    }
+    if len(atxs) < strategy.Rank {
+        return &types.AtxDoc{}, nil
+    }
+    return atxs[strategy.Rank-1], nil
+}
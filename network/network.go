@@ -1,11 +1,17 @@
 package network
 
 import (
+	"encoding/hex"
+	"log"
 	"github.com/swarmbit/spacemesh-state-api/config"
     "math/big"
+	"strconv"
+	"strings"
+	"time"
 
     "github.com/spacemeshos/economics/rewards"
 	sTypes "github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/hash"
 	"github.com/spacemeshos/go-spacemesh/proposals/util"
 	"github.com/spacemeshos/go-spacemesh/tortoise"
 )
@@ -27,14 +33,60 @@ func NewNetworkUtils() *NetworkUtils {
 	}
 }
 
+// UseNetwork applies the network of the config, the genesis id is computed from the
+// genesis time and extra data when not set and addresses are read and printed with the
+// network prefix.
+func UseNetwork(networkConfig *config.NetworkConfig) error {
+	err := config.ApplyNetwork(networkConfig)
+	if err != nil {
+		return err
+	}
+	if config.GenesisId == "" && networkConfig.ExtraData != "" {
+		config.GenesisId = GenesisId(networkConfig.GenesisTime, networkConfig.ExtraData)
+	}
+	sTypes.SetNetworkHRP(config.NetworkHrp)
+	log.Printf("Following network %s, genesis id %s", config.NetworkName, config.GenesisId)
+	return nil
+}
+
+// GenesisId returns the genesis id a node reports for the genesis time and extra data
+// of its config, computed like the node does.
+func GenesisId(genesisTime string, extraData string) string {
+	parsed, err := time.Parse(time.RFC3339, genesisTime)
+	if err != nil {
+		return ""
+	}
+	golden := hash.Sum([]byte(strconv.FormatInt(parsed.Unix(), 10)), []byte(extraData))
+	return hex.EncodeToString(golden[:20])
+}
+
 func (n *NetworkUtils) GetEpoch(layer uint64) sTypes.EpochID {
-	return sTypes.EpochID(layer / config.LayersPerEpoch)
+	return sTypes.EpochID(layer / uint64(config.LayersPerEpoch))
 }
 
 func (n *NetworkUtils) GetEpochFirst(epoch uint64) sTypes.LayerID {
 	return sTypes.LayerID(sTypes.EpochID(epoch)).Mul(config.LayersPerEpoch)
 }
 
+// GetLayerTime returns the time at which layer starts, counted from genesis.
+func (n *NetworkUtils) GetLayerTime(layer uint64) time.Time {
+	return time.Unix(config.GenesisEpochSeconds+int64(layer)*config.LayerDuration, 0)
+}
+
+// GetEpochTime returns the time at which the first layer of epoch starts.
+func (n *NetworkUtils) GetEpochTime(epoch uint64) time.Time {
+	return n.GetLayerTime(epoch * uint64(config.LayersPerEpoch))
+}
+
+// GetLayerAt returns the layer running at t, false when t is before genesis.
+func (n *NetworkUtils) GetLayerAt(t time.Time) (uint64, bool) {
+	seconds := t.Unix() - config.GenesisEpochSeconds
+	if seconds < 0 {
+		return 0, false
+	}
+	return uint64(seconds / config.LayerDuration), true
+}
+
 func (n *NetworkUtils) GetNumberOfSlots(weight uint64, totalWeight uint64, epoch uint32) (int32, error) {
 	layerSize := n.tortoiseConfig.LayerSize
 	minimalWeight := uint64(7_879_129_244)
@@ -42,7 +94,7 @@ func (n *NetworkUtils) GetNumberOfSlots(weight uint64, totalWeight uint64, epoch
 		minimalWeight = 107467138
 	}
 
-	slots, err := util.GetNumEligibleSlots(weight, minimalWeight, totalWeight, layerSize, 4032)
+	slots, err := util.GetNumEligibleSlots(weight, minimalWeight, totalWeight, layerSize, config.LayersPerEpoch)
 	return int32(slots), err
 }
 
@@ -54,7 +106,7 @@ func (n *NetworkUtils) GetEpochSubsidy(epoch uint64) uint64 {
 	genisesLayer := n.FirstEffectiveGenesis()
 	epochFirstLayer := n.GetEpochFirst(epoch)
 	var totalEpochSubsidy uint64 = 0
-	for i := epochFirstLayer; i < epochFirstLayer+config.LayersPerEpoch; i++ {
+	for i := epochFirstLayer; i < epochFirstLayer.Add(config.LayersPerEpoch); i++ {
 		totalEpochSubsidy += rewards.TotalSubsidyAtLayer(i.Difference(genisesLayer))
 	}
 	return totalEpochSubsidy
@@ -77,3 +129,13 @@ func (n *NetworkUtils) Vested(layer uint64) uint64 {
 	vested.Div(vested, new(big.Int).SetUint64(uint64(VestEnd - VestStart)))
 	return vested.Uint64()
 }
+
+// ToSmesh formats a smidge amount as a decimal smesh value without trailing zeros.
+func ToSmesh(smidge uint64) string {
+	whole := strconv.FormatUint(smidge/OneSmesh, 10)
+	fraction := strings.TrimRight(strconv.FormatUint(smidge%OneSmesh+OneSmesh, 10)[1:], "0")
+	if fraction == "" {
+		return whole
+	}
+	return whole + "." + fraction
+}
@@ -0,0 +1,37 @@
+package network
+
+import (
+    "github.com/spacemeshos/economics/constants"
+    "github.com/spacemeshos/economics/rewards"
+    "github.com/swarmbit/spacemesh-state-api/config"
+    "github.com/swarmbit/spacemesh-state-api/types"
+)
+
+// GetParameters returns the parameters of the followed network, with the times left for
+// the caller to format.
+func (n *NetworkUtils) GetParameters() *types.NetworkParameters {
+    lambda, _ := rewards.Lambda.Float64()
+    halfLife, _ := rewards.HalfLife.Float64()
+    finalLayer, _ := rewards.FinalLayer.Float64()
+    return &types.NetworkParameters{
+        Network:               config.NetworkName,
+        GenesisId:             config.GenesisId,
+        GenesisTimestamp:      config.GenesisEpochSeconds,
+        LayerDuration:         config.LayerDuration,
+        LayersPerEpoch:        config.LayersPerEpoch,
+        EpochDuration:         config.LayerDuration * int64(config.LayersPerEpoch),
+        EffectiveGenesisLayer: n.FirstEffectiveGenesis().Uint32(),
+        AddressPrefix:         config.NetworkHrp,
+        Subsidy: &types.SubsidyParameters{
+            TotalIssuance:  constants.TotalIssuance,
+            TotalSubsidy:   constants.TotalSubsidy,
+            TotalVaulted:   constants.TotalVaulted,
+            TenYearTarget:  constants.TenYearTarget,
+            Lambda:         lambda,
+            HalfLifeLayers: halfLife,
+            FinalLayer:     finalLayer,
+            VestStart:      VestStart,
+            VestEnd:        VestEnd,
+        },
+    }
+}
@@ -0,0 +1,54 @@
+package network
+
+import (
+    "time"
+
+    "github.com/swarmbit/spacemesh-state-api/config"
+    "github.com/swarmbit/spacemesh-state-api/types"
+)
+
+const (
+    PoetPhaseRound        = "round"
+    PoetPhaseRegistration = "registration"
+)
+
+// GetPoetRound returns the round of a poet with the phase shift and cycle gap in hours
+// at now, times are unix seconds left for the caller to format.
+func (n *NetworkUtils) GetPoetRound(phaseShift int, cycleGap int, now time.Time) *types.PoetRound {
+    epochDuration := config.LayerDuration * int64(config.LayersPerEpoch)
+    shift := int64(phaseShift) * int64(time.Hour/time.Second)
+    gap := int64(cycleGap) * int64(time.Hour/time.Second)
+
+    // rounds start phaseShift after every epoch start, the current one started in epoch
+    sinceFirst := now.Unix() - config.GenesisEpochSeconds - shift
+    epoch := sinceFirst / epochDuration
+    if sinceFirst < 0 {
+        epoch = 0
+    }
+    roundStart := config.GenesisEpochSeconds + epoch*epochDuration + shift
+    roundEnd := roundStart + epochDuration - gap
+    nextStart := roundStart + epochDuration
+
+    round := &types.PoetRound{
+        Phase:                    PoetPhaseRound,
+        PublishEpoch:             uint32(epoch + 1),
+        RoundStart:               roundStart,
+        RoundEnd:                 roundEnd,
+        RegistrationOpens:        roundEnd,
+        RegistrationCloses:       nextStart,
+        SecondsToRegistrationEnd: nextStart - now.Unix(),
+    }
+    if now.Unix() < roundStart {
+        // before the first round the registration for it is open
+        round.Phase = PoetPhaseRegistration
+        round.PublishEpoch = 0
+        round.RegistrationOpens = config.GenesisEpochSeconds
+        round.RegistrationCloses = roundStart
+        round.SecondsToRegistrationEnd = roundStart - now.Unix()
+    } else if now.Unix() >= roundEnd {
+        round.Phase = PoetPhaseRegistration
+    } else {
+        round.SecondsToRegistration = roundEnd - now.Unix()
+    }
+    return round
+}
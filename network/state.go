@@ -3,71 +3,233 @@ package network
 import (
     "encoding/base64"
     "encoding/hex"
-    "fmt"
     "log"
+    "math/rand"
     "sync"
+    "sync/atomic"
     "time"
 
+    "github.com/swarmbit/spacemesh-state-api/config"
     "github.com/swarmbit/spacemesh-state-api/database"
+    "github.com/swarmbit/spacemesh-state-api/node"
+    "github.com/swarmbit/spacemesh-state-api/pkg/apperror"
     "github.com/swarmbit/spacemesh-state-api/price"
     "github.com/swarmbit/spacemesh-state-api/types"
 )
 
-const INFO_KEY = "info"
+// ErrNotReady is returned until the network info is loaded for the first time.
+var ErrNotReady error = apperror.New(apperror.Unavailable, "Network info not loaded yet")
+
+// Snapshot is the network state after a refresh. It is not changed once published, the
+// values read from one snapshot are all of the same refresh.
+type Snapshot struct {
+    Version   uint64
+    CreatedAt time.Time
+    // nil until the network info is loaded
+    Info           *types.NetworkInfo
+    epochSubsidies map[uint32]uint64
+}
+
+func (s *Snapshot) EpochSubsidy(epoch uint32) uint64 {
+    return s.epochSubsidies[epoch]
+}
 
 type NetworkState struct {
-    db             *database.ReadDB
-    networkUtils   *NetworkUtils
-    networkInfo    *sync.Map
-    epochSubsidies *sync.Map
-    priceResolver  *price.PriceResolver
+    db              database.ReadStore
+    networkUtils    *NetworkUtils
+    vestingSchedule *VestingSchedule
+    snapshot        atomic.Pointer[Snapshot]
+    // held while the next snapshot is built so refreshes do not drop each other's values
+    refresh         sync.Mutex
+    onRefresh       []func(snapshot *Snapshot)
+    priceResolver   *price.PriceResolver
+    // nil unless the node is enabled
+    nodeClient      *node.NodeClient
+    // refresh interval and jitter as durations, read before every wait so reloads apply
+    infoInterval    atomic.Int64
+    jitter          atomic.Int64
+
+    // strategy of the highest atx in the network info, replaced on reload
+    highestAtxStrategy atomic.Pointer[HighestAtxStrategy]
 }
 
-func NewNetworkState(db *database.ReadDB, networkUtils *NetworkUtils, priceResolver *price.PriceResolver) *NetworkState {
+func NewNetworkState(db database.ReadStore, networkUtils *NetworkUtils, priceResolver *price.PriceResolver, nodeClient *node.NodeClient, stateConfig *config.StateConfig) *NetworkState {
+    disableRefresh := stateConfig != nil && stateConfig.DisableRefresh
+
     state := &NetworkState{
-        db:             db,
-        networkUtils:   networkUtils,
-        networkInfo:    &sync.Map{},
-        epochSubsidies: &sync.Map{},
-        priceResolver:  priceResolver,
+        db:              db,
+        networkUtils:    networkUtils,
+        vestingSchedule: NewMainnetVestingSchedule(),
+        priceResolver:   priceResolver,
+        nodeClient:      nodeClient,
     }
+    state.Reload(stateConfig)
     state.fetchNetworkInfo()
-    state.periodicNetworkInfoFetch()
-    state.calculateEpochSubsidies()
-    state.periodicCalculateSubsidy()
+    if disableRefresh {
+        log.Println("Network state refresh disabled")
+        return state
+    }
+    periodic(&state.infoInterval, &state.jitter, state.fetchNetworkInfo)
     return state
 }
 
-func (n *NetworkState) GetInfo() *types.NetworkInfo {
-    networkInfo, exists := n.networkInfo.Load(INFO_KEY)
-    if !exists {
-        return &types.NetworkInfo{}
+// Reload applies changed refresh times, jitter and highest atx strategy, they are used
+// from the refresh after the current one. DisableRefresh is only read at start.
+func (n *NetworkState) Reload(stateConfig *config.StateConfig) {
+    infoRefreshTime := 60
+    jitter := 0
+    highestAtxStrategy := defaultHighestAtxStrategy
+    if stateConfig != nil {
+        if stateConfig.InfoRefreshTime > 0 {
+            infoRefreshTime = stateConfig.InfoRefreshTime
+        }
+        if stateConfig.Jitter > 0 {
+            jitter = stateConfig.Jitter
+        }
+        if stateConfig.HighestAtx != nil {
+            strategy, err := NewHighestAtxStrategy(stateConfig.HighestAtx.Strategy, stateConfig.HighestAtx.Rank)
+            if err != nil {
+                log.Printf("Keeping the highest atx strategy: %s", err.Error())
+                strategy = n.highestAtxStrategy.Load()
+            }
+            if strategy != nil {
+                highestAtxStrategy = strategy
+            }
+        }
+    }
+    n.infoInterval.Store(int64(time.Duration(infoRefreshTime) * time.Second))
+    n.jitter.Store(int64(time.Duration(jitter) * time.Second))
+    n.highestAtxStrategy.Store(highestAtxStrategy)
+}
+
+// Refresh reloads the network info and the epoch subsidies without waiting for the
+// periodic refresh.
+func (n *NetworkState) Refresh() {
+    n.fetchNetworkInfo()
+}
+
+// GetSnapshot returns the last published snapshot, nil before the first refresh. Callers
+// reading several values read them from one snapshot to get a consistent view.
+func (n *NetworkState) GetSnapshot() *Snapshot {
+    return n.snapshot.Load()
+}
+
+// publish builds the next snapshot from the last one, update sets what the refresh
+// computed and must replace the values it changes instead of modifying them.
+func (n *NetworkState) publish(update func(next *Snapshot)) *Snapshot {
+    n.refresh.Lock()
+    defer n.refresh.Unlock()
+    next := &Snapshot{epochSubsidies: make(map[uint32]uint64)}
+    if current := n.snapshot.Load(); current != nil {
+        *next = *current
+    }
+    update(next)
+    next.Version++
+    next.CreatedAt = time.Now()
+    if next.Info != nil {
+        info := *next.Info
+        info.Snapshot = &types.StateSnapshot{
+            Version:   next.Version,
+            Layer:     info.Layer,
+            CreatedAt: next.CreatedAt.Unix(),
+        }
+        next.Info = &info
     }
-    return networkInfo.(*types.NetworkInfo)
+    n.snapshot.Store(next)
+    return next
+}
+
+// OnRefresh calls fn with every snapshot published by a network info refresh after it is
+// registered, from the refreshing goroutine.
+func (n *NetworkState) OnRefresh(fn func(snapshot *Snapshot)) {
+    n.refresh.Lock()
+    defer n.refresh.Unlock()
+    n.onRefresh = append(n.onRefresh, fn)
+}
+
+// View reads the values of one snapshot. A request reads everything from the view of the
+// snapshot it started with, so one response never mixes two refreshes.
+type View struct {
+    state    *NetworkState
+    snapshot *Snapshot
+}
+
+// View returns the view of snapshot, which is nil before the first refresh.
+func (n *NetworkState) View(snapshot *Snapshot) *View {
+    return &View{state: n, snapshot: snapshot}
 }
 
-func (n *NetworkState) GetEpochSubsidy(epoch uint32) uint64 {
-    subsidy, exists := n.epochSubsidies.Load(epoch)
-    if !exists {
+// Snapshot is the snapshot the view reads, nil before the first refresh.
+func (v *View) Snapshot() *Snapshot {
+    return v.snapshot
+}
+
+// GetInfo returns the network info of the snapshot, with the node status merged in when
+// the node client runs. The node is queried more often than the info is refreshed, its
+// status is read on every call.
+func (v *View) GetInfo() *types.NetworkInfo {
+    nodeClient := v.state.nodeClient
+    if v.snapshot == nil || v.snapshot.Info == nil {
+        return &types.NetworkInfo{Node: nodeClient.Status()}
+    }
+    info := v.snapshot.Info
+    if nodeClient == nil {
+        return info
+    }
+    merged := *info
+    merged.Node = nodeClient.Status()
+    return &merged
+}
+
+func (v *View) GetSupply() *types.SupplyBreakdown {
+    supply := v.GetInfo().Supply
+    if supply == nil {
+        return &types.SupplyBreakdown{}
+    }
+    return supply
+}
+
+func (v *View) GetEpochSubsidy(epoch uint32) uint64 {
+    if v.snapshot == nil {
         return 0
     }
-    return subsidy.(uint64)
+    return v.snapshot.EpochSubsidy(epoch)
 }
 
-func (n *NetworkState) periodicNetworkInfoFetch() {
-    ticker := time.NewTicker(60 * time.Second)
-    go func() {
-        for range ticker.C {
-            n.fetchNetworkInfo()
-        }
-    }()
+// GetInfo returns the network info of the last snapshot, see View.GetInfo.
+func (n *NetworkState) GetInfo() *types.NetworkInfo {
+    return n.View(n.snapshot.Load()).GetInfo()
+}
+
+// Ready returns ErrNotReady until the network info is loaded, GetInfo returns an empty
+// info before that.
+func (n *NetworkState) Ready() error {
+    return n.View(n.snapshot.Load()).Ready()
+}
+
+// Ready returns ErrNotReady when the snapshot has no network info.
+func (v *View) Ready() error {
+    if v.snapshot == nil || v.snapshot.Info == nil {
+        return ErrNotReady
+    }
+    return nil
 }
 
-func (n *NetworkState) periodicCalculateSubsidy() {
-    ticker := time.NewTicker(60 * time.Second)
+func (n *NetworkState) GetVestingSchedule() *VestingSchedule {
+    return n.vestingSchedule
+}
+
+// periodic runs fn every interval plus a random delay up to jitter, drawn again on every
+// run so replicas started together drift apart.
+func periodic(interval *atomic.Int64, jitter *atomic.Int64, fn func()) {
     go func() {
-        for range ticker.C {
-            n.calculateEpochSubsidies()
+        for {
+            delay := time.Duration(interval.Load())
+            if maxJitter := jitter.Load(); maxJitter > 0 {
+                delay += time.Duration(rand.Int63n(maxJitter))
+            }
+            time.Sleep(delay)
+            fn()
         }
     }()
 }
@@ -78,67 +240,78 @@ func (n *NetworkState) fetchNetworkInfo() {
 
     layer, err := n.db.GetLastProcessedLayer()
     if err != nil {
-        fmt.Printf("Failed to get last processed layer: %s", err.Error())
+        log.Printf("Failed to get last processed layer: %s", err.Error())
         return
     }
     log.Println("Got last processed layer")
 
     epoch := n.networkUtils.GetEpoch(uint64(layer.Layer))
 
-    atxEpoch, err := n.db.CountAtxEpoch(uint64(epoch - 1))
-    if err != nil {
-        fmt.Printf("Failed to count atx epoch: %s", err.Error())
-        return
-    }
-    log.Println("Got atx for epoch count")
-
-    atxNextEpoch, err := n.db.CountAtxEpoch(uint64(epoch))
-    if err != nil {
-        fmt.Printf("Failed to count next atx epoch: %s", err.Error())
-        return
-    }
-    log.Println("Got atx for next epoch count")
-
     totalAccounts, err := n.db.CountAccounts()
     if err != nil {
-        fmt.Printf("Failed to count accounts: %s", err.Error())
+        log.Printf("Failed to count accounts: %s", err.Error())
         return
     }
     log.Println("Got count accounts")
 
     networkInfo, err := n.db.GetNetworkInfo()
     if err != nil {
-        fmt.Printf("Failed to get network info: %s", err.Error())
+        log.Printf("Failed to get network info: %s", err.Error())
         return
     }
     log.Println("Got network info")
 
+    // the sink keeps the atx totals of each epoch in one document
     atxEpochTotals, err := n.db.GetAtxEpoch(uint64(epoch - 1))
     if err != nil {
-        fmt.Printf("Failed to get epoch totals: %s", err.Error())
+        log.Printf("Failed to get epoch totals: %s", err.Error())
         return
     }
     log.Println("Got atx totals")
 
     atxNextEpochTotals, err := n.db.GetAtxEpoch(uint64(epoch))
     if err != nil {
-        fmt.Printf("Failed to get next epoch totals: %s", err.Error())
+        log.Printf("Failed to get next epoch totals: %s", err.Error())
         return
     }
     log.Println("Got atx next epoch totals")
 
+    atxHex, err := n.getHigestAtx(atxEpochTotals)
+    if err != nil {
+        log.Printf("Failed to get highest atx: %s", err.Error())
+        return
+    }
+    atxBase64, err := hexToBase64(atxHex)
+    if err != nil {
+        log.Printf("Failed to encode highest atx %s: %s", atxHex, err.Error())
+    }
+    log.Println("Got highest atx")
+
     totalSlots, err := n.networkUtils.GetNumberOfSlots(uint64(atxEpochTotals.TotalWeight), atxEpochTotals.TotalWeight, epoch.Uint32())
     if err != nil {
-        fmt.Printf("Failed to get total slots: %s", err.Error())
+        log.Printf("Failed to get total slots: %s", err.Error())
         return
     }
     log.Println("Got total slots")
 
-    var genisesAccounts int64 = 28
+    nextEpoch, err := n.nextEpoch(epoch.Uint32()+1, atxNextEpochTotals)
+    if err != nil {
+        log.Printf("Failed to get next epoch projections: %s", err.Error())
+        return
+    }
+    log.Println("Got next epoch projections")
+
+    performance, err := n.db.GetEpochPerformance(epoch.Uint32())
+    if err != nil {
+        log.Printf("Failed to get epoch performance: %s", err.Error())
+        return
+    }
+    log.Println("Got epoch performance")
+
     var p = n.priceResolver.GetPrice()
     log.Println("Got price")
 
-    n.networkInfo.Store(INFO_KEY, &types.NetworkInfo{
+    info := &types.NetworkInfo{
         Epoch:                  epoch.Uint32(),
         EpochSubsidy:           n.networkUtils.GetEpochSubsidy(uint64(epoch)),
         Layer:                  uint64(layer.Layer),
@@ -148,67 +321,106 @@ func (n *NetworkState) fetchNetworkInfo() {
         CirculatingSupply:      networkInfo.CirculatingSupply + n.networkUtils.Vested(uint64(layer.Layer)),
         Price:                  p,
         MarketCap:              uint64(float64(networkInfo.CirculatingSupply) * p),
-        TotalAccounts:          uint64(totalAccounts + genisesAccounts),
-        AtxHex:                 "",
-        AtxBase64:              "",
-        TotalActiveSmeshers:    uint64(atxEpoch),
+        TotalAccounts:          uint64(totalAccounts),
+        AtxHex:                 atxHex,
+        AtxBase64:              atxBase64,
+        TotalActiveSmeshers:    atxEpochTotals.TotalAtx,
         TotalRewards:           networkInfo.CirculatingSupply,
         Vested:                 n.networkUtils.Vested(uint64(layer.Layer)),
         TotalVaulted:           TotalVaulted,
-        NextEpoch: &types.NetworkInfoNextEpoch{
-            Epoch:                  epoch.Uint32() + 1,
-            EffectiveUnitsCommited: int64(atxNextEpochTotals.TotalEffectiveNumUnits),
-            TotalActiveSmeshers:    atxNextEpoch,
-        },
+        Supply:                 n.supplyBreakdown(uint64(layer.Layer), networkInfo),
+        NextEpoch:              nextEpoch,
+        MissedRewards:          performance.MissedRewards(),
+    }
+    // the subsidies are published with the info of the same layer
+    subsidies := n.epochSubsidies(epoch.Uint32())
+    snapshot := n.publish(func(next *Snapshot) {
+        next.Info = info
+        next.epochSubsidies = subsidies
     })
-
+    n.refresh.Lock()
+    listeners := n.onRefresh
+    n.refresh.Unlock()
+    for _, fn := range listeners {
+        fn(snapshot)
+    }
 }
 
-func (n *NetworkState) calculateEpochSubsidies() {
-    layer, err := n.db.GetLastProcessedLayer()
-    if err != nil {
-        fmt.Printf("Failed to get last processed layer: %s", err.Error())
-        return
+// nextEpoch projects the slots and the reward per slot of epoch from the atxs published
+// for it so far, as if no more were published.
+func (n *NetworkState) nextEpoch(epoch uint32, totals *types.AtxEpochDoc) (*types.NetworkInfoNextEpoch, error) {
+    next := &types.NetworkInfoNextEpoch{
+        Epoch:                  epoch,
+        EffectiveUnitsCommited: int64(totals.TotalEffectiveNumUnits),
+        TotalActiveSmeshers:    int64(totals.TotalAtx),
+        TotalWeight:            totals.TotalWeight,
+        EpochSubsidy:           n.networkUtils.GetEpochSubsidy(uint64(epoch)),
+        RegistrationDeadline:   n.networkUtils.GetEpochTime(uint64(epoch)).Unix(),
     }
-
-    epoch := n.networkUtils.GetEpoch(uint64(layer.Layer))
-    for i := epoch + 1; i >= 2; i-- {
-        epochSubsidy := n.networkUtils.GetEpochSubsidy(uint64(i))
-        n.epochSubsidies.Store(i.Uint32(), epochSubsidy)
+    if totals.TotalWeight == 0 {
+        return next, nil
     }
-}
-
-func (n *NetworkState) getHigestAtx(epoch uint64) (string, error) {
-    atxs, err := n.db.GetAtxForEpoch(epoch)
+    slots, err := n.networkUtils.GetNumberOfSlots(totals.TotalWeight, totals.TotalWeight, epoch)
     if err != nil {
-        return "", err
+        return nil, err
     }
-
-    malfeasanceNodes, err := n.db.GetMalfeasanceNodes()
-    if err != nil {
-        return "", err
+    next.ProjectedTotalSlots = uint64(slots)
+    if slots > 0 {
+        next.ProjectedRewardPerSlot = next.EpochSubsidy / uint64(slots)
     }
+    return next, nil
+}
 
-    malfeasanceNodesMap := make(map[string]bool)
+func (n *NetworkState) supplyBreakdown(layer uint64, networkInfo *types.NetworkInfoDoc) *types.SupplyBreakdown {
+    genesisVaults := n.vestingSchedule.TotalVaulted()
+    vested := n.vestingSchedule.VestedAt(layer)
 
-    for _, v := range malfeasanceNodes {
-        malfeasanceNodesMap[v.ID] = true
+    // rewards include the fees paid back to smeshers, only the subsidy is new supply
+    var feesRewarded uint64 = 0
+    if networkInfo.CirculatingSupply > networkInfo.IssuedSubsidy {
+        feesRewarded = networkInfo.CirculatingSupply - networkInfo.IssuedSubsidy
+    }
+    var burnedFees uint64 = 0
+    if networkInfo.FeesPaid > feesRewarded {
+        burnedFees = networkInfo.FeesPaid - feesRewarded
     }
 
-    var maxHeight uint64 = 0
-    atxID := ""
-
-    for _, atx := range atxs {
+    return &types.SupplyBreakdown{
+        Layer:             layer,
+        GenesisVaults:     genesisVaults,
+        Vested:            vested,
+        Locked:            genesisVaults - vested,
+        RewardsIssued:     networkInfo.CirculatingSupply,
+        SubsidyIssued:     networkInfo.IssuedSubsidy,
+        FeesPaid:          networkInfo.FeesPaid,
+        BurnedFees:        burnedFees,
+        CirculatingSupply: vested + networkInfo.IssuedSubsidy - burnedFees,
+        TotalSupply:       genesisVaults + networkInfo.IssuedSubsidy - burnedFees,
+    }
+}
 
-        atxHeight := atx.BaseTick + atx.TickCount
-        if atxHeight > uint64(maxHeight) && !malfeasanceNodesMap[atx.NodeID] {
-            maxHeight = atxHeight
-            atxID = atx.AtxID
-        }
+// epochSubsidies are the subsidies of the epochs from 2 to the one after epoch.
+func (n *NetworkState) epochSubsidies(epoch uint32) map[uint32]uint64 {
+    subsidies := make(map[uint32]uint64)
+    for i := epoch + 1; i >= 2; i-- {
+        subsidies[i] = n.networkUtils.GetEpochSubsidy(uint64(i))
+    }
+    return subsidies
+}
 
+// getHigestAtx returns the highest atx the sink keeps in the epoch totals for the default
+// strategy. Other strategies and totals saved before the sink kept it find it in the atxs.
+func (n *NetworkState) getHigestAtx(totals *types.AtxEpochDoc) (string, error) {
+    strategy := n.HighestAtxStrategy()
+    if totals.TotalAtx == 0 || (totals.HighestAtx != "" && strategy.isDefault()) {
+        return totals.HighestAtx, nil
     }
 
-    return atxID, nil
+    atx, err := n.HighestAtx(uint64(totals.ID), strategy)
+    if err != nil {
+        return "", err
+    }
+    return atx.AtxID, nil
 }
 
 func hexToBase64(hexString string) (string, error) {
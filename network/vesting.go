@@ -0,0 +1,79 @@
+package network
+
+import (
+    "math/big"
+
+    "github.com/swarmbit/spacemesh-state-api/config"
+)
+
+// Vault models a mainnet genesis vault contract: the total amount unlocks linearly
+// between VestingStart and VestingEnd.
+type Vault struct {
+    Address      string
+    TotalAmount  uint64
+    VestingStart uint64
+    VestingEnd   uint64
+}
+
+// VestedAt returns the amount of the vault unlocked at layer.
+func (v *Vault) VestedAt(layer uint64) uint64 {
+    if layer < v.VestingStart {
+        return 0
+    }
+    if layer >= v.VestingEnd {
+        return v.TotalAmount
+    }
+    vested := new(big.Int).SetUint64(v.TotalAmount)
+    vested.Mul(vested, new(big.Int).SetUint64(layer-v.VestingStart))
+    vested.Div(vested, new(big.Int).SetUint64(v.VestingEnd-v.VestingStart))
+    return vested.Uint64()
+}
+
+type VestingSchedule struct {
+    vaults []*Vault
+}
+
+func NewMainnetVestingSchedule() *VestingSchedule {
+    genesisVaults := config.GenesisVaults()
+    vaults := make([]*Vault, len(genesisVaults))
+    for i, v := range genesisVaults {
+        vaults[i] = &Vault{
+            Address:      v.Address,
+            TotalAmount:  v.Amount,
+            VestingStart: VestStart,
+            VestingEnd:   VestEnd,
+        }
+    }
+    return &VestingSchedule{
+        vaults: vaults,
+    }
+}
+
+func (s *VestingSchedule) Vaults() []*Vault {
+    return s.vaults
+}
+
+func (s *VestingSchedule) GetVault(address string) *Vault {
+    for _, v := range s.vaults {
+        if v.Address == address {
+            return v
+        }
+    }
+    return nil
+}
+
+func (s *VestingSchedule) TotalVaulted() uint64 {
+    var total uint64 = 0
+    for _, v := range s.vaults {
+        total += v.TotalAmount
+    }
+    return total
+}
+
+func (s *VestingSchedule) VestedAt(layer uint64) uint64 {
+    var vested uint64 = 0
+    for _, v := range s.vaults {
+        vested += v.VestedAt(layer)
+    }
+    return vested
+}
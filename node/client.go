@@ -0,0 +1,236 @@
+package node
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/metrics"
+	"github.com/swarmbit/spacemesh-state-api/types"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// NodeClient queries the grpc api of a go-spacemesh node for the current layer and the
+// sync status, the nats streams only carry what the node already applied. The last
+// status is kept in memory for the network state, the connection is retried in the
+// background while the node is unreachable.
+type NodeClient struct {
+	conn        *grpc.ClientConn
+	address     string
+	timeout     time.Duration
+	status      atomic.Pointer[types.NodeStatus]
+	ticker      *time.Ticker
+	refreshTime int
+}
+
+// nodeRefreshTime is the seconds between queries, 10 when not configured.
+func nodeRefreshTime(configValues *config.Config) int {
+	if configValues.Node.RefreshTime > 0 {
+		return configValues.Node.RefreshTime
+	}
+	return 10
+}
+
+func NewNodeClient(configValues *config.Config) (*NodeClient, error) {
+	nodeConfig := configValues.Node
+	timeout := 5
+	if nodeConfig.Timeout > 0 {
+		timeout = nodeConfig.Timeout
+	}
+	maxReconnectDelay := 120
+	if nodeConfig.MaxReconnectDelay > 0 {
+		maxReconnectDelay = nodeConfig.MaxReconnectDelay
+	}
+
+	transportCredentials := insecure.NewCredentials()
+	if nodeConfig.Tls {
+		transportCredentials = credentials.NewTLS(&tls.Config{})
+	}
+	reconnect := backoff.DefaultConfig
+	reconnect.MaxDelay = time.Duration(maxReconnectDelay) * time.Second
+	conn, err := grpc.NewClient(nodeConfig.Address,
+		grpc.WithTransportCredentials(transportCredentials),
+		grpc.WithConnectParams(grpc.ConnectParams{
+			Backoff:           reconnect,
+			MinConnectTimeout: time.Duration(timeout) * time.Second,
+		}),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(codec{})),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create node client for %s: %w", nodeConfig.Address, err)
+	}
+
+	client := &NodeClient{
+		conn:    conn,
+		address: nodeConfig.Address,
+		timeout: time.Duration(timeout) * time.Second,
+	}
+	conn.Connect()
+	go client.watchConnection()
+	client.fetchStatus()
+	client.periodicFetch(nodeRefreshTime(configValues))
+	return client, nil
+}
+
+func (c *NodeClient) periodicFetch(refreshTime int) {
+	c.refreshTime = refreshTime
+	c.ticker = time.NewTicker(time.Duration(refreshTime) * time.Second)
+	go func() {
+		for range c.ticker.C {
+			c.fetchStatus()
+		}
+	}()
+}
+
+// Reload applies a changed refresh time, the address and the connection settings are
+// only read at start.
+func (c *NodeClient) Reload(configValues *config.Config) {
+	refreshTime := nodeRefreshTime(configValues)
+	if refreshTime != c.refreshTime {
+		c.refreshTime = refreshTime
+		c.ticker.Reset(time.Duration(refreshTime) * time.Second)
+	}
+}
+
+// Close stops the queries and closes the connection.
+func (c *NodeClient) Close() {
+	c.ticker.Stop()
+	c.conn.Close()
+}
+
+// Status returns the last queried status, nil without a client or before the first
+// query.
+func (c *NodeClient) Status() *types.NodeStatus {
+	if c == nil {
+		return nil
+	}
+	return c.status.Load()
+}
+
+// TransactionsState returns the node state of each transaction by id, one of the
+// TransactionState values. Transactions the node does not know are missing or
+// unspecified.
+func (c *NodeClient) TransactionsState(ids []string) (map[string]uint64, error) {
+	request := &transactionsStateRequest{Ids: make([][]byte, 0, len(ids))}
+	for _, id := range ids {
+		raw, err := hex.DecodeString(id)
+		if err != nil {
+			return nil, fmt.Errorf("invalid transaction id %s: %w", id, err)
+		}
+		request.Ids = append(request.Ids, raw)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	response := &transactionsStateResponse{}
+	if err := c.conn.Invoke(ctx, transactionsStateMethod, request, response); err != nil {
+		return nil, err
+	}
+	states := make(map[string]uint64, len(response.States))
+	for id, state := range response.States {
+		states[hex.EncodeToString([]byte(id))] = state
+	}
+	return states, nil
+}
+
+// Layer is a layer of the node mesh, the hash is raw bytes.
+type Layer struct {
+	Number uint32
+	Hash   []byte
+}
+
+// Layer returns the layer as the node has it, with the hash light clients verify
+// inclusion against. It is nil when the node does not have the layer.
+func (c *NodeClient) Layer(layer uint32) (*Layer, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	response := &layersQueryResponse{}
+	if err := c.conn.Invoke(ctx, layersQueryMethod, &layersQueryRequest{StartLayer: layer, EndLayer: layer}, response); err != nil {
+		return nil, err
+	}
+	for _, l := range response.Layers {
+		if l.Number == layer {
+			return l, nil
+		}
+	}
+	return nil, nil
+}
+
+// watchConnection logs the connection state changes and asks for a new connection when
+// the channel goes idle, failed connections are retried by grpc with the backoff.
+func (c *NodeClient) watchConnection() {
+	state := c.conn.GetState()
+	for {
+		switch state {
+		case connectivity.Ready:
+			metrics.NodeConnected.Set(1)
+		case connectivity.Idle:
+			metrics.NodeConnected.Set(0)
+			c.conn.Connect()
+		case connectivity.Shutdown:
+			metrics.NodeConnected.Set(0)
+			return
+		default:
+			metrics.NodeConnected.Set(0)
+		}
+		if !c.conn.WaitForStateChange(context.Background(), state) {
+			return
+		}
+		previous := state
+		state = c.conn.GetState()
+		if state == connectivity.Ready || previous == connectivity.Ready {
+			log.Printf("Node %s connection %s", c.address, state)
+		}
+	}
+}
+
+func (c *NodeClient) fetchStatus() {
+	status := &types.NodeStatus{}
+	if previous := c.status.Load(); previous != nil {
+		*status = *previous
+	}
+	status.Connected = true
+	status.Error = ""
+	status.CheckedAt = time.Now().UnixMilli()
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	nodeStatus := &nodeStatusResponse{}
+	err := c.conn.Invoke(ctx, nodeStatusMethod, emptyRequest{}, nodeStatus)
+	if err == nil {
+		currentLayer := &currentLayerResponse{}
+		err = c.conn.Invoke(ctx, currentLayerMethod, emptyRequest{}, currentLayer)
+		if err == nil {
+			status.CurrentLayer = currentLayer.Layer
+		}
+	}
+	if err != nil {
+		log.Printf("Failed to query node %s: %s", c.address, err.Error())
+		status.Connected = false
+		status.Synced = false
+		status.Error = err.Error()
+		metrics.NodeSynced.Set(0)
+		c.status.Store(status)
+		return
+	}
+
+	status.Synced = nodeStatus.IsSynced
+	status.ConnectedPeers = nodeStatus.ConnectedPeers
+	status.SyncedLayer = nodeStatus.SyncedLayer
+	status.TopLayer = nodeStatus.TopLayer
+	status.VerifiedLayer = nodeStatus.VerifiedLayer
+	if status.Synced {
+		metrics.NodeSynced.Set(1)
+	} else {
+		metrics.NodeSynced.Set(0)
+	}
+	c.status.Store(status)
+}
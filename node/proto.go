@@ -0,0 +1,297 @@
+package node
+
+import (
+	"fmt"
+
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Full method names of the spacemesh v1 api calls the client makes.
+const (
+	nodeStatusMethod        = "/spacemesh.v1.NodeService/Status"
+	currentLayerMethod      = "/spacemesh.v1.MeshService/CurrentLayer"
+	transactionsStateMethod = "/spacemesh.v1.TransactionService/TransactionsState"
+	layersQueryMethod       = "/spacemesh.v1.MeshService/LayersQuery"
+)
+
+// Transaction states of the node, the values of spacemesh.v1.TransactionState.TransactionState.
+const (
+	TransactionStateUnspecified uint64 = iota
+	TransactionStateRejected
+	TransactionStateInsufficientFunds
+	TransactionStateConflicting
+	TransactionStateMempool
+	TransactionStateMesh
+	TransactionStateProcessed
+)
+
+// message is implemented by the few api messages the client sends and reads, they are
+// encoded by hand so the generated api module is not needed.
+type message interface {
+	marshal() []byte
+	unmarshal(data []byte) error
+}
+
+// codec encodes messages in the protobuf wire format under the proto name, the node
+// sees the same content type as from a generated client.
+type codec struct{}
+
+func (codec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(message)
+	if !ok {
+		return nil, fmt.Errorf("unsupported message %T", v)
+	}
+	return m.marshal(), nil
+}
+
+func (codec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(message)
+	if !ok {
+		return fmt.Errorf("unsupported message %T", v)
+	}
+	return m.unmarshal(data)
+}
+
+func (codec) Name() string {
+	return "proto"
+}
+
+var _ encoding.Codec = codec{}
+
+// emptyRequest is StatusRequest and CurrentLayerRequest, neither has fields.
+type emptyRequest struct{}
+
+func (emptyRequest) marshal() []byte {
+	return nil
+}
+
+func (emptyRequest) unmarshal([]byte) error {
+	return nil
+}
+
+// nodeStatusResponse is StatusResponse, the status is field 1.
+type nodeStatusResponse struct {
+	ConnectedPeers uint64
+	IsSynced       bool
+	SyncedLayer    uint32
+	TopLayer       uint32
+	VerifiedLayer  uint32
+}
+
+func (r *nodeStatusResponse) marshal() []byte {
+	return nil
+}
+
+func (r *nodeStatusResponse) unmarshal(data []byte) error {
+	return eachField(data, func(number protowire.Number, value uint64, bytes []byte) error {
+		if number != 1 {
+			return nil
+		}
+		return eachField(bytes, func(number protowire.Number, value uint64, bytes []byte) error {
+			var err error
+			switch number {
+			case 1:
+				r.ConnectedPeers = value
+			case 2:
+				r.IsSynced = value != 0
+			case 3:
+				r.SyncedLayer, err = layerNumber(bytes)
+			case 4:
+				r.TopLayer, err = layerNumber(bytes)
+			case 5:
+				r.VerifiedLayer, err = layerNumber(bytes)
+			}
+			return err
+		})
+	})
+}
+
+// currentLayerResponse is CurrentLayerResponse, the layer is field 1.
+type currentLayerResponse struct {
+	Layer uint32
+}
+
+func (r *currentLayerResponse) marshal() []byte {
+	return nil
+}
+
+func (r *currentLayerResponse) unmarshal(data []byte) error {
+	return eachField(data, func(number protowire.Number, value uint64, bytes []byte) error {
+		var err error
+		if number == 1 {
+			r.Layer, err = layerNumber(bytes)
+		}
+		return err
+	})
+}
+
+// transactionsStateRequest is TransactionsStateRequest without the transactions, only
+// the states are read.
+type transactionsStateRequest struct {
+	Ids [][]byte
+}
+
+func (r *transactionsStateRequest) marshal() []byte {
+	var data []byte
+	for _, id := range r.Ids {
+		var transactionId []byte
+		transactionId = protowire.AppendTag(transactionId, 1, protowire.BytesType)
+		transactionId = protowire.AppendBytes(transactionId, id)
+		data = protowire.AppendTag(data, 1, protowire.BytesType)
+		data = protowire.AppendBytes(data, transactionId)
+	}
+	return data
+}
+
+func (r *transactionsStateRequest) unmarshal([]byte) error {
+	return nil
+}
+
+// transactionsStateResponse is TransactionsStateResponse, states are keyed by the raw
+// transaction id.
+type transactionsStateResponse struct {
+	States map[string]uint64
+}
+
+func (r *transactionsStateResponse) marshal() []byte {
+	return nil
+}
+
+func (r *transactionsStateResponse) unmarshal(data []byte) error {
+	r.States = make(map[string]uint64)
+	return eachField(data, func(number protowire.Number, value uint64, bytes []byte) error {
+		if number != 1 {
+			return nil
+		}
+		var id []byte
+		var state uint64
+		err := eachField(bytes, func(number protowire.Number, value uint64, bytes []byte) error {
+			switch number {
+			case 1:
+				return eachField(bytes, func(number protowire.Number, value uint64, bytes []byte) error {
+					if number == 1 {
+						id = bytes
+					}
+					return nil
+				})
+			case 2:
+				state = value
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		r.States[string(id)] = state
+		return nil
+	})
+}
+
+// layersQueryRequest is LayersQueryRequest, the start and end layers are fields 1 and 2.
+type layersQueryRequest struct {
+	StartLayer uint32
+	EndLayer   uint32
+}
+
+func (r *layersQueryRequest) marshal() []byte {
+	var data []byte
+	for number, layer := range []uint32{r.StartLayer, r.EndLayer} {
+		var layerNumber []byte
+		layerNumber = protowire.AppendTag(layerNumber, 1, protowire.VarintType)
+		layerNumber = protowire.AppendVarint(layerNumber, uint64(layer))
+		data = protowire.AppendTag(data, protowire.Number(number+1), protowire.BytesType)
+		data = protowire.AppendBytes(data, layerNumber)
+	}
+	return data
+}
+
+func (r *layersQueryRequest) unmarshal([]byte) error {
+	return nil
+}
+
+// layersQueryResponse is LayersQueryResponse with only the number and the hash of each
+// layer, the blocks and activations are skipped.
+type layersQueryResponse struct {
+	Layers []*Layer
+}
+
+func (r *layersQueryResponse) marshal() []byte {
+	return nil
+}
+
+func (r *layersQueryResponse) unmarshal(data []byte) error {
+	return eachField(data, func(number protowire.Number, value uint64, bytes []byte) error {
+		if number != 1 {
+			return nil
+		}
+		layer := &Layer{}
+		err := eachField(bytes, func(number protowire.Number, value uint64, bytes []byte) error {
+			var err error
+			switch number {
+			case 1:
+				layer.Number, err = layerNumber(bytes)
+			case 3:
+				layer.Hash = bytes
+			}
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		r.Layers = append(r.Layers, layer)
+		return nil
+	})
+}
+
+// layerNumber reads a LayerNumber message, the number is field 1.
+func layerNumber(data []byte) (uint32, error) {
+	var layer uint32
+	err := eachField(data, func(number protowire.Number, value uint64, bytes []byte) error {
+		if number == 1 {
+			layer = uint32(value)
+		}
+		return nil
+	})
+	return layer, err
+}
+
+// eachField calls fn with the number and value of every field of an encoded message,
+// varints are passed in value and length delimited fields in bytes. Fixed width fields
+// are skipped, none of the read messages has them.
+func eachField(data []byte, fn func(number protowire.Number, value uint64, bytes []byte) error) error {
+	for len(data) > 0 {
+		number, wireType, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+		switch wireType {
+		case protowire.VarintType:
+			value, n := protowire.ConsumeVarint(data)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			data = data[n:]
+			if err := fn(number, value, nil); err != nil {
+				return err
+			}
+		case protowire.BytesType:
+			bytes, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			data = data[n:]
+			if err := fn(number, 0, bytes); err != nil {
+				return err
+			}
+		default:
+			n := protowire.ConsumeFieldValue(number, wireType, data)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			data = data[n:]
+		}
+	}
+	return nil
+}
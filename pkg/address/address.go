@@ -0,0 +1,69 @@
+package address
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+)
+
+// node ids are the 32 byte ed25519 public keys of the smeshers
+const nodeIdSize = 32
+
+var (
+	// ErrInvalidAddress is returned when an address is not a bech32 account address of the network.
+	ErrInvalidAddress = errors.New("address must be a bech32 account address of the network")
+	// ErrInvalidNodeId is returned when a node id is not a hex encoded 32 byte id.
+	ErrInvalidNodeId = errors.New("node id must be a hex encoded 32 byte id")
+)
+
+// NormalizeAddress validates a bech32 account address of the configured network and
+// returns it lowercase, the form addresses are stored in.
+func NormalizeAddress(address string) (string, error) {
+	addr, err := types.StringToAddress(strings.TrimSpace(address))
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidAddress, err)
+	}
+	return addr.String(), nil
+}
+
+// NormalizeNodeId validates a hex encoded node id, with or without 0x, and returns it
+// lowercase without the prefix, the form node ids are stored in.
+func NormalizeNodeId(nodeId string) (string, error) {
+	nodeId = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(nodeId)), "0x")
+	bytes, err := hex.DecodeString(nodeId)
+	if err != nil || len(bytes) != nodeIdSize {
+		return "", ErrInvalidNodeId
+	}
+	return nodeId, nil
+}
+
+// NormalizeAddresses normalizes every address of addresses, it fails on the first
+// invalid one.
+func NormalizeAddresses(addresses []string) ([]string, error) {
+	normalized := make([]string, len(addresses))
+	for i, v := range addresses {
+		addr, err := NormalizeAddress(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", v, err)
+		}
+		normalized[i] = addr
+	}
+	return normalized, nil
+}
+
+// NormalizeNodeIds normalizes every node id of nodeIds, it fails on the first invalid
+// one.
+func NormalizeNodeIds(nodeIds []string) ([]string, error) {
+	normalized := make([]string, len(nodeIds))
+	for i, v := range nodeIds {
+		nodeId, err := NormalizeNodeId(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", v, err)
+		}
+		normalized[i] = nodeId
+	}
+	return normalized, nil
+}
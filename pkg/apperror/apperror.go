@@ -0,0 +1,108 @@
+package apperror
+
+// Kind classifies an error by what the caller can do about it, the api answers every
+// kind with its own status and code.
+type Kind string
+
+const (
+	Internal     Kind = "internal"
+	NotFound     Kind = "not_found"
+	Conflict     Kind = "conflict"
+	Unavailable  Kind = "unavailable"
+	InvalidInput Kind = "invalid_input"
+	NotSupported Kind = "not_supported"
+	Unauthorized Kind = "unauthorized"
+)
+
+// Error is an error of a kind. Message is safe to show to api users, the wrapped error
+// is the cause and is only logged.
+type Error struct {
+	Kind    Kind
+	Message string
+	Err     error
+}
+
+var (
+	ErrNotFound     = &Error{Kind: NotFound}
+	ErrConflict     = &Error{Kind: Conflict}
+	ErrUnavailable  = &Error{Kind: Unavailable}
+	ErrInvalidInput = &Error{Kind: InvalidInput}
+	ErrNotSupported = &Error{Kind: NotSupported}
+)
+
+func New(kind Kind, message string) *Error {
+	return &Error{Kind: kind, Message: message}
+}
+
+func Wrap(kind Kind, message string, err error) *Error {
+	return &Error{Kind: kind, Message: message, Err: err}
+}
+
+func (e *Error) Error() string {
+	message := e.Message
+	if message == "" {
+		message = string(e.Kind)
+	}
+	if e.Err != nil {
+		return message + ": " + e.Err.Error()
+	}
+	return message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is matches the kind sentinels, errors.Is(err, ErrNotFound) holds for every not found
+// error.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Message == "" && t.Err == nil && t.Kind == e.Kind
+}
+
+// KindOf returns the kind of the first Error in err that is not Internal, an Internal
+// error wrapping a typed cause has the kind of the cause. Errors without kind are
+// Internal.
+func KindOf(err error) Kind {
+	kind := Internal
+	walk(err, func(e *Error) bool {
+		if e.Kind != Internal {
+			kind = e.Kind
+			return true
+		}
+		return false
+	})
+	return kind
+}
+
+// MessageOf returns the message of the first Error in err with one, an empty string
+// when there is none.
+func MessageOf(err error) string {
+	message := ""
+	walk(err, func(e *Error) bool {
+		message = e.Message
+		return message != ""
+	})
+	return message
+}
+
+// walk calls fn with the Errors in the tree of err, depth first, until fn returns true.
+func walk(err error, fn func(e *Error) bool) bool {
+	if err == nil {
+		return false
+	}
+	if e, ok := err.(*Error); ok && fn(e) {
+		return true
+	}
+	switch wrapped := err.(type) {
+	case interface{ Unwrap() error }:
+		return walk(wrapped.Unwrap(), fn)
+	case interface{ Unwrap() []error }:
+		for _, v := range wrapped.Unwrap() {
+			if walk(v, fn) {
+				return true
+			}
+		}
+	}
+	return false
+}
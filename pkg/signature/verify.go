@@ -0,0 +1,45 @@
+package signature
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/genvm/templates/wallet"
+
+	v0 "github.com/swarmbit/spacemesh-state-api/pkg/transactionparser/v0"
+)
+
+var (
+	// ErrInvalidPublicKey is returned when the public key is not a hex encoded ed25519 key.
+	ErrInvalidPublicKey = errors.New("public key must be a hex encoded ed25519 public key")
+	// ErrInvalidSignature is returned when the signature is not a hex encoded ed25519 signature.
+	ErrInvalidSignature = errors.New("signature must be a hex encoded ed25519 signature")
+)
+
+// DecodePublicKey decodes a hex encoded ed25519 public key, node ids use the same encoding.
+func DecodePublicKey(publicKey string) (ed25519.PublicKey, error) {
+	bytes, err := hex.DecodeString(strings.TrimPrefix(publicKey, "0x"))
+	if err != nil || len(bytes) != ed25519.PublicKeySize {
+		return nil, ErrInvalidPublicKey
+	}
+	return ed25519.PublicKey(bytes), nil
+}
+
+// Verify checks an ed25519 signature over message for publicKey.
+func Verify(publicKey ed25519.PublicKey, message []byte, signature string) (bool, error) {
+	sig, err := hex.DecodeString(strings.TrimPrefix(signature, "0x"))
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return false, ErrInvalidSignature
+	}
+	return ed25519.Verify(publicKey, message, sig), nil
+}
+
+// WalletAddress computes the address of the single signature wallet spawned with publicKey.
+func WalletAddress(publicKey ed25519.PublicKey) types.Address {
+	args := v0.SpawnArguments{}
+	copy(args.PublicKey[:], publicKey)
+	return v0.ComputePrincipal(wallet.TemplateAddress, &args)
+}
@@ -27,6 +27,7 @@ type TransactionData struct {
 	Sig        *core.Signature
 	Signatures *multisig.Signatures
 	Vault      DecodedVault
+	Multisig   DecodedMultisig
 	Type       int
 }
 
@@ -38,3 +39,9 @@ type DecodedVault interface {
 	GetVestingStart() core.LayerID
 	GetVestingEnd() core.LayerID
 }
+
+type DecodedMultisig interface {
+	GetMultisig() core.Address
+	GetRequired() uint8
+	GetPublicKeys() [][]byte
+}
@@ -50,6 +50,7 @@ func ParseTransaction(rawTx *bytes.Buffer, method uint8, template *core.Address)
 				return nil, err
 			}
 			txData.Tx = &spawnMultisigTx
+			txData.Multisig = &spawnMultisigTx
 			txData.Type = transaction.TypeMultisigSpawn
 		case vault.TemplateAddress:
 			var spawnVaultTx SpawnVaultTransaction
@@ -65,6 +66,7 @@ func ParseTransaction(rawTx *bytes.Buffer, method uint8, template *core.Address)
 				return nil, err
 			}
 			txData.Tx = &spawnMultisigTx
+			txData.Multisig = &spawnMultisigTx
 			txData.Type = transaction.TypeVestingSpawn
 		}
 	case methodSend:
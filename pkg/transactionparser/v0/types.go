@@ -4,7 +4,7 @@ import (
 	"github.com/spacemeshos/go-spacemesh/common/types"
 	"github.com/spacemeshos/go-scale"
 	"github.com/spacemeshos/go-spacemesh/genvm/core"
-	"github.com/spacemeshos/go-spacemesh/genvm/templates/multisig"
+	"github.com/spacemeshos/go-spacemesh/genvm/templates/vault"
 	"github.com/spacemeshos/go-spacemesh/genvm/templates/wallet"
 	"github.com/spacemeshos/go-spacemesh/hash"
 
@@ -131,13 +131,17 @@ func (t *SpawnMultisigTransaction) GetCounter() uint64 {
 	return 0
 }
 
-// GetReceiver returns receiver address of the transaction.
+// GetReceiver returns receiver address of the transaction. The template is multisig or
+// vesting, both spawn from the required signatures and the public keys.
 func (t *SpawnMultisigTransaction) GetReceiver() types.Address {
-	args := SpawnMultisigArguments{PublicKeys: make([]PublicKey, len(t.Payload.Arguments.PublicKeys))}
+	args := SpawnMultisigArguments{
+		Required:   t.Payload.Arguments.Required,
+		PublicKeys: make([]PublicKey, len(t.Payload.Arguments.PublicKeys)),
+	}
 	for i := range t.Payload.Arguments.PublicKeys {
 		copy(args.PublicKeys[i][:], t.Payload.Arguments.PublicKeys[i][:])
 	}
-	return ComputePrincipal(multisig.TemplateAddress, &args)
+	return ComputePrincipal(t.Template, &args)
 }
 
 // GetGasPrice returns gas price of the transaction.
@@ -159,6 +163,16 @@ func (t *SpawnMultisigTransaction) GetPublicKeys() [][]byte {
 	return result
 }
 
+// GetMultisig returns the address of the spawned multisig account.
+func (t *SpawnMultisigTransaction) GetMultisig() core.Address {
+	return t.GetReceiver()
+}
+
+// GetRequired returns the number of signatures required by the multisig account.
+func (t *SpawnMultisigTransaction) GetRequired() uint8 {
+	return t.Payload.Arguments.Required
+}
+
 // SpendTransaction coin transfer transaction. also includes multisig.
 type SpendTransaction struct {
 	Type      uint8
@@ -295,8 +309,17 @@ func (t *SpawnVaultTransaction) GetVestingEnd() core.LayerID {
 	return t.Payload.Arguments.VestingEnd
 }
 
+// GetVault returns the address of the spawned vault, derived from the vault template and
+// the spawn arguments like the node does.
 func (t *SpawnVaultTransaction) GetVault() core.Address {
-	return core.Address{}
+	args := t.Payload.Arguments
+	return core.ComputePrincipal(vault.TemplateAddress, &vault.SpawnArguments{
+		Owner:               args.Owner,
+		TotalAmount:         args.TotalAmount,
+		InitialUnlockAmount: args.InitialUnlockAmount,
+		VestingStart:        args.VestingStart,
+		VestingEnd:          args.VestingEnd,
+	})
 }
 
 // DrainVaultTransaction initial transaction for vault.
@@ -0,0 +1,232 @@
+package pool
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"math/big"
+	"slices"
+	"sync"
+
+	natsS "github.com/spacemeshos/go-spacemesh/nats"
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/events"
+	"github.com/swarmbit/spacemesh-state-api/pkg/address"
+	"github.com/swarmbit/spacemesh-state-api/processor"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+// ProcessorName is the name of the pool processor in processors.names.
+const ProcessorName = "pool"
+
+func init() {
+	processor.Register(&payoutProcessor{})
+}
+
+// payoutProcessor saves the rewards of the member nodes of the pool. The members are read
+// from the current config, the rewards saved before a node became a member are copied
+// when the processor starts and when the reload adds it.
+type payoutProcessor struct {
+	reloader *config.Reloader
+	writeDB  database.WriteStore
+	mu       sync.Mutex
+	// members is built from poolConfig, the config it was last built from
+	poolConfig *config.PoolConfig
+	members    map[string]bool
+}
+
+func (p *payoutProcessor) Name() string {
+	return ProcessorName
+}
+
+func (p *payoutProcessor) Start(env *processor.Environment) error {
+	p.reloader = env.Reloader
+	p.writeDB = env.WriteDB
+	current := p.reloader.Current().Pool
+	if err := p.backfill(memberNodeIds(current)); err != nil {
+		return fmt.Errorf("failed to copy the rewards of the pool members: %w", err)
+	}
+	p.reloader.OnReload(func(reloaded *config.Config) {
+		previous := memberNodeIds(current)
+		current = reloaded.Pool
+		added := make([]string, 0)
+		for _, nodeId := range memberNodeIds(current) {
+			if !slices.Contains(previous, nodeId) {
+				added = append(added, nodeId)
+			}
+		}
+		// the reload does not wait for the copy
+		go func() {
+			if err := p.backfill(added); err != nil {
+				log.Printf("Failed to copy the rewards of the new pool members: %v", err)
+			}
+		}()
+	})
+	return nil
+}
+
+// backfill copies the saved rewards of nodeIds to the pool rewards.
+func (p *payoutProcessor) backfill(nodeIds []string) error {
+	if len(nodeIds) == 0 {
+		return nil
+	}
+	if err := p.writeDB.BackfillPoolRewards(nodeIds); err != nil {
+		return err
+	}
+	log.Printf("Copied the saved rewards of %d pool nodes", len(nodeIds))
+	return nil
+}
+
+// Kinds is empty when the pool is not enabled, so the processor can stay registered in
+// every build.
+func (p *payoutProcessor) Kinds() []string {
+	if poolConfig := p.reloader.Current().Pool; poolConfig == nil || !poolConfig.Enabled {
+		return nil
+	}
+	return []string{events.Reward}
+}
+
+func (p *payoutProcessor) Process(event *events.Event) error {
+	reward, ok := event.Payload.(*natsS.Reward)
+	if !ok || !p.member(reward.NodeID) {
+		return nil
+	}
+	return p.writeDB.SavePoolReward(&types.PoolRewardDoc{
+		ID:          reward.ID,
+		NodeId:      reward.NodeID,
+		Coinbase:    reward.Coinbase,
+		Layer:       reward.Layer,
+		Epoch:       reward.Layer / config.LayersPerEpoch,
+		TotalReward: int64(reward.Total),
+	})
+}
+
+// member tells if the node is a member of the pool in the current config.
+func (p *payoutProcessor) member(nodeId string) bool {
+	poolConfig := p.reloader.Current().Pool
+	if poolConfig == nil || !poolConfig.Enabled {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.poolConfig != poolConfig {
+		p.members = make(map[string]bool)
+		for _, member := range poolConfig.Members {
+			for _, v := range memberNodes(member) {
+				p.members[v] = true
+			}
+		}
+		p.poolConfig = poolConfig
+	}
+	return p.members[nodeId]
+}
+
+// memberNodeIds are the node ids of the members of an enabled pool.
+func memberNodeIds(poolConfig *config.PoolConfig) []string {
+	if poolConfig == nil || !poolConfig.Enabled {
+		return nil
+	}
+	nodeIds := make([]string, 0)
+	for _, member := range poolConfig.Members {
+		nodeIds = append(nodeIds, memberNodes(member)...)
+	}
+	return nodeIds
+}
+
+// memberNodes are the node ids of the member as they are stored, they were validated
+// with the config.
+func memberNodes(member *config.PoolMemberConfig) []string {
+	nodeIds, err := address.NormalizeNodeIds(member.NodeIds)
+	if err != nil {
+		return member.NodeIds
+	}
+	return nodeIds
+}
+
+// Payouts reports the payouts of the current members for every epoch from and to
+// included that has rewards of them. The rewards saved for nodes that are no longer
+// members are left out.
+func Payouts(readDB database.ReadStore, poolConfig *config.PoolConfig, fromEpoch uint32, toEpoch uint32) ([]*types.PoolPayoutReport, error) {
+	rewards, err := readDB.GetPoolRewards(fromEpoch, toEpoch)
+	if err != nil {
+		return nil, err
+	}
+	byEpoch := make(map[uint32]map[string]*types.PoolNodeRewardsDoc)
+	epochs := make([]uint32, 0)
+	for _, v := range rewards {
+		nodes, exists := byEpoch[v.Id.Epoch]
+		if !exists {
+			nodes = make(map[string]*types.PoolNodeRewardsDoc)
+			byEpoch[v.Id.Epoch] = nodes
+			epochs = append(epochs, v.Id.Epoch)
+		}
+		nodes[v.Id.NodeId] = v
+	}
+
+	reports := make([]*types.PoolPayoutReport, 0, len(epochs))
+	for _, epoch := range epochs {
+		report := payout(epoch, poolConfig, byEpoch[epoch])
+		if report.Rewards > 0 {
+			reports = append(reports, report)
+		}
+	}
+	return reports, nil
+}
+
+// Payout reports the payouts of the current members for epoch, with no rewards when
+// none were saved for them.
+func Payout(readDB database.ReadStore, poolConfig *config.PoolConfig, epoch uint32) (*types.PoolPayoutReport, error) {
+	rewards, err := readDB.GetPoolRewards(epoch, epoch)
+	if err != nil {
+		return nil, err
+	}
+	nodes := make(map[string]*types.PoolNodeRewardsDoc, len(rewards))
+	for _, v := range rewards {
+		nodes[v.Id.NodeId] = v
+	}
+	return payout(epoch, poolConfig, nodes), nil
+}
+
+func payout(epoch uint32, poolConfig *config.PoolConfig, nodes map[string]*types.PoolNodeRewardsDoc) *types.PoolPayoutReport {
+	report := &types.PoolPayoutReport{
+		Epoch:      epoch,
+		FirstLayer: epoch * config.LayersPerEpoch,
+		LastLayer:  (epoch+1)*config.LayersPerEpoch - 1,
+		Members:    make([]*types.PoolPayoutMember, 0, len(poolConfig.Members)),
+	}
+	// a node listed by two members is counted once, for the first one
+	counted := make(map[string]bool)
+	for _, member := range poolConfig.Members {
+		payoutMember := &types.PoolPayoutMember{
+			Epoch:   epoch,
+			Name:    member.Name,
+			NodeIds: memberNodes(member),
+			Share:   member.Share,
+		}
+		for _, nodeId := range payoutMember.NodeIds {
+			if rewards, exists := nodes[nodeId]; exists && !counted[nodeId] {
+				counted[nodeId] = true
+				payoutMember.Rewards += rewards.Count
+				payoutMember.Earned += rewards.TotalReward
+			}
+		}
+		report.Rewards += payoutMember.Rewards
+		report.TotalRewards += payoutMember.Earned
+		report.Members = append(report.Members, payoutMember)
+	}
+	for _, member := range report.Members {
+		member.Payout = share(report.TotalRewards, member.Share)
+		report.Payouts += member.Payout
+	}
+	report.OperatorFee = report.TotalRewards - report.Payouts
+	return report
+}
+
+// share is percent of total rounded down, in hundredths of a percent so large totals do
+// not lose precision.
+func share(total int64, percent float64) int64 {
+	basisPoints := big.NewInt(int64(math.Round(percent * 100)))
+	amount := new(big.Int).Mul(big.NewInt(total), basisPoints)
+	return amount.Quo(amount, big.NewInt(10000)).Int64()
+}
@@ -0,0 +1,32 @@
+package price
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const usd = "USD"
+
+type exchangeRatesResponse struct {
+	Rates map[string]float64 `json:"rates"`
+}
+
+// fetchExchangeRates returns how much of each currency one USD buys, using the
+// ECB reference rates published by frankfurter.
+func fetchExchangeRates(currencies []string) (map[string]float64, error) {
+	query := url.Values{}
+	query.Set("from", usd)
+	query.Set("to", strings.Join(currencies, ","))
+
+	var response exchangeRatesResponse
+	if err := getJSON("https://api.frankfurter.app/latest?"+query.Encode(), nil, &response); err != nil {
+		return nil, err
+	}
+	for _, currency := range currencies {
+		if _, exists := response.Rates[currency]; !exists {
+			return nil, fmt.Errorf("no exchange rate for %s", currency)
+		}
+	}
+	return response.Rates, nil
+}
@@ -1,139 +1,260 @@
 package price
 
 import (
-	"encoding/json"
 	"fmt"
-	"github.com/swarmbit/spacemesh-state-api/config"
-	"net/http"
+	"log"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
-	"strconv"
+
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/types"
 )
 
 const priceKey = "priceKey"
+const ratesKey = "ratesKey"
 
 type PriceResolver struct {
-	priceMap *sync.Map
-	isXT     bool
+	priceMap  *sync.Map
+	providers []PriceProvider
+	// fetched prices are stored here for history, nil when history is not kept
+	writeDB database.WriteStore
+	// a cached price older than ttl is served while a refresh runs in background
+	ttl time.Duration
+	// a cached price older than maxStale is no longer served
+	maxStale   time.Duration
+	refreshing atomic.Bool
+	// fiat currencies other than USD that prices can be converted to
+	currencies []string
+
+	priceTicker    *time.Ticker
+	ratesTicker    *time.Ticker
+	fetchTime      int
+	ratesFetchTime int
 }
 
-func NewPriceResolver(config *config.Config) *PriceResolver {
+// refreshTimes returns the minutes between price fetches and between exchange rates
+// fetches, 15 and 60 when not configured.
+func refreshTimes(priceConfig *config.PriceConfig) (int, int) {
 	fetchTime := 15
-	isXT := false
+	ratesFetchTime := 60
+	if priceConfig != nil {
+		if priceConfig.RefreshTime > 0 {
+			fetchTime = priceConfig.RefreshTime
+		}
+		if priceConfig.RatesRefreshTime > 0 {
+			ratesFetchTime = priceConfig.RatesRefreshTime
+		}
+	}
+	return fetchTime, ratesFetchTime
+}
+
+func NewPriceResolver(config *config.Config, writeDB database.WriteStore) *PriceResolver {
+	fetchTime, ratesFetchTime := refreshTimes(config.Price)
+	ttl := fetchTime
+	maxStale := 60
+	var providers []PriceProvider
+	var currencies []string
 	if config.Price != nil {
-		if config.Price.RefreshTime > 0 {
-			fetchTime = config.Price.RefreshTime
+		for _, v := range config.Price.Currencies {
+			currency := strings.ToUpper(v)
+			if currency != usd {
+				currencies = append(currencies, currency)
+			}
 		}
-		if strings.ToLower(config.Price.Provider) == "xt" {
-			isXT = true
+		if config.Price.TTL > 0 {
+			ttl = config.Price.TTL
 		}
+		if config.Price.MaxStaleTime > 0 {
+			maxStale = config.Price.MaxStaleTime
+		}
+		for _, v := range config.Price.Providers {
+			provider, err := newProvider(v.Name, v.ApiKey)
+			if err != nil {
+				log.Printf("Skip price provider %s: %v", v.Name, err)
+				continue
+			}
+			providers = append(providers, provider)
+		}
+	}
+	if len(providers) == 0 {
+		providers = defaultProviders(config.Price)
 	}
+
 	priceResolver := &PriceResolver{
-		priceMap: &sync.Map{},
-		isXT:     isXT,
+		priceMap:   &sync.Map{},
+		providers:  providers,
+		writeDB:    writeDB,
+		currencies: currencies,
+		ttl:        time.Duration(ttl) * time.Minute,
+		maxStale:   time.Duration(maxStale) * time.Minute,
 	}
 
 	priceResolver.fetchPrice()
 	priceResolver.periodicPriceFetch(fetchTime)
+	if len(currencies) > 0 {
+		priceResolver.fetchRates()
+		priceResolver.periodicRatesFetch(ratesFetchTime)
+	}
 	return priceResolver
 }
 
+// defaultProviders keeps the single provider setting working, the chosen
+// provider goes first and the other one is the fallback.
+func defaultProviders(priceConfig *config.PriceConfig) []PriceProvider {
+	if priceConfig != nil && strings.ToLower(priceConfig.Provider) == "xt" {
+		return []PriceProvider{&xtProvider{}, &coinpaprikaProvider{}}
+	}
+	return []PriceProvider{&coinpaprikaProvider{}, &xtProvider{}}
+}
+
+// Flush drops the cached price and rates and fetches them again.
+func (p *PriceResolver) Flush() {
+	p.priceMap.Delete(priceKey)
+	p.priceMap.Delete(ratesKey)
+	p.fetchPrice()
+	if len(p.currencies) > 0 {
+		p.fetchRates()
+	}
+}
+
 func (p *PriceResolver) GetPrice() float64 {
 	priceResponse, present := p.priceMap.Load(priceKey)
 	if !present {
 		return -1
 	}
-	return priceResponse.(*PriceCache).usdPrice
-}
-
-func (p *PriceResolver) periodicPriceFetch(refreshTime int) {
-	ticker := time.NewTicker(time.Duration(refreshTime) * time.Minute)
-	go func() {
-		for range ticker.C {
-			p.fetchPrice()
-		}
-	}()
+	priceCache := priceResponse.(*PriceCache)
+	age := time.Since(priceCache.fetchedAt)
+	if age > p.ttl {
+		p.refresh()
+	}
+	if age > p.maxStale {
+		return -1
+	}
+	return priceCache.usdPrice
 }
 
-func (p *PriceResolver) fetchPrice() {
-	if p.isXT {
-		if !p.fetchXT() {
-			p.fetchCoinpaprikaPrice()
-		}
-	} else {
-		if !p.fetchCoinpaprikaPrice() {
-			p.fetchXT()
+// IsSupportedCurrency reports if prices can be converted to currency.
+func (p *PriceResolver) IsSupportedCurrency(currency string) bool {
+	if currency == usd {
+		return true
+	}
+	for _, v := range p.currencies {
+		if v == currency {
+			return true
 		}
 	}
-
+	return false
 }
 
-func (p *PriceResolver) fetchXT() bool {
-	fmt.Println("Fetch price from XT")
-	respXT, err := http.Get("https://www.xt.com/sapi/v4/market/public/ticker/24h?symbol=smh_usdt")
-	if err != nil || respXT.StatusCode == 404 {
-		return false
+// GetPriceIn returns the price in currency, -1 when the price or the exchange rate is unknown.
+func (p *PriceResolver) GetPriceIn(currency string) float64 {
+	price := p.GetPrice()
+	if currency == usd || price < 0 {
+		return price
 	}
-	defer respXT.Body.Close()
-
-	var xtResponce PriceXTResponse
-	if err := json.NewDecoder(respXT.Body).Decode(&xtResponce); err != nil {
-		p.priceMap.Delete(priceKey)
-		fmt.Println("Error:", err)
-		return false
+	rates, present := p.priceMap.Load(ratesKey)
+	if !present {
+		return -1
 	}
+	rate, exists := rates.(map[string]float64)[currency]
+	if !exists {
+		return -1
+	}
+	return price * rate
+}
 
-	if len(xtResponce.Result) > 0 {
-		price, err := strconv.ParseFloat(xtResponce.Result[0].Current, 64)
-		if err != nil {
-			p.priceMap.Delete(priceKey)
-
-			fmt.Println("Error no price on XT response")
-			return false
+func (p *PriceResolver) periodicRatesFetch(refreshTime int) {
+	p.ratesFetchTime = refreshTime
+	p.ratesTicker = time.NewTicker(time.Duration(refreshTime) * time.Minute)
+	go func() {
+		for range p.ratesTicker.C {
+			p.fetchRates()
 		}
-		p.priceMap.Store(priceKey, &PriceCache{
-			usdPrice: price,
-		})
-		return true
-	} else {
-		p.priceMap.Delete(priceKey)
-		fmt.Println("Error no price on XT response")
+	}()
+}
 
-		return false
+// fetchRates keeps the previous rates when fetching fails, they move slowly enough.
+func (p *PriceResolver) fetchRates() {
+	fmt.Println("Fetch exchange rates")
+	rates, err := fetchExchangeRates(p.currencies)
+	if err != nil {
+		log.Printf("Failed to fetch exchange rates: %v", err)
+		return
 	}
+	p.priceMap.Store(ratesKey, rates)
+}
 
+func (p *PriceResolver) periodicPriceFetch(refreshTime int) {
+	p.fetchTime = refreshTime
+	p.priceTicker = time.NewTicker(time.Duration(refreshTime) * time.Minute)
+	go func() {
+		for range p.priceTicker.C {
+			p.fetchPrice()
+		}
+	}()
 }
 
-func (p *PriceResolver) fetchCoinpaprikaPrice() bool {
-	fmt.Println("Fetch price from coinpaprika")
-	resp, err := http.Get("https://api.coinpaprika.com/v1/tickers/smh-spacemesh")
-	if err != nil || resp.StatusCode == 404 {
-		return false
+// Reload applies changed refresh times, the providers, currencies and ttls are only
+// read at start.
+func (p *PriceResolver) Reload(configValues *config.Config) {
+	fetchTime, ratesFetchTime := refreshTimes(configValues.Price)
+	if fetchTime != p.fetchTime {
+		p.fetchTime = fetchTime
+		p.priceTicker.Reset(time.Duration(fetchTime) * time.Minute)
 	}
-	defer resp.Body.Close()
-
-	var response PriceResponse
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		p.priceMap.Delete(priceKey)
-		fmt.Println("Error:", err)
-		return false
+	if p.ratesTicker != nil && ratesFetchTime != p.ratesFetchTime {
+		p.ratesFetchTime = ratesFetchTime
+		p.ratesTicker.Reset(time.Duration(ratesFetchTime) * time.Minute)
 	}
+}
 
-	value := response.Quotes["USD"]
-	if value == nil {
-		return false
+// refresh fetches the price in background unless a fetch is already running.
+func (p *PriceResolver) refresh() {
+	if !p.refreshing.CompareAndSwap(false, true) {
+		return
 	}
+	go func() {
+		defer p.refreshing.Store(false)
+		p.fetchPrice()
+	}()
+}
 
-	p.priceMap.Store(priceKey, &PriceCache{
-		usdPrice: value.Price,
-	})
-	return true
-
+// fetchPrice tries the providers in priority order and caches the first price found.
+// When all of them fail the previous price is kept until it is too stale to serve.
+func (p *PriceResolver) fetchPrice() {
+	for _, provider := range p.providers {
+		fmt.Println("Fetch price from", provider.Name())
+		price, err := provider.FetchUSDPrice()
+		if err != nil {
+			fmt.Println("Error:", err)
+			continue
+		}
+		fetchedAt := time.Now()
+		p.priceMap.Store(priceKey, &PriceCache{
+			usdPrice:  price,
+			fetchedAt: fetchedAt,
+		})
+		if p.writeDB != nil {
+			err := p.writeDB.SavePrice(&types.PriceDoc{
+				Timestamp: fetchedAt,
+				USDPrice:  price,
+				Source:    provider.Name(),
+			})
+			if err != nil {
+				log.Printf("Failed to save price: %v", err)
+			}
+		}
+		return
+	}
+	log.Println("Failed to fetch price from all providers")
 }
 
 type PriceCache struct {
-	usdPrice float64
+	usdPrice  float64
+	fetchedAt time.Time
 }
 
 type PriceResponse struct {
@@ -151,3 +272,11 @@ type PriceXTResponse struct {
 type PriceXTResult struct {
 	Current string `json:"c"`
 }
+
+type PriceCMCResponse struct {
+	Data map[string][]*PriceCMCData `json:"data"`
+}
+
+type PriceCMCData struct {
+	Quote map[string]*PriceQuote `json:"quote"`
+}
@@ -0,0 +1,138 @@
+package price
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var errNoPrice = errors.New("no usd price in response")
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// PriceProvider fetches the current SMH price in USD from a single source.
+type PriceProvider interface {
+	Name() string
+	FetchUSDPrice() (float64, error)
+}
+
+func newProvider(name string, apiKey string) (PriceProvider, error) {
+	switch strings.ToLower(name) {
+	case "coinpaprika":
+		return &coinpaprikaProvider{}, nil
+	case "xt":
+		return &xtProvider{}, nil
+	case "coingecko":
+		return &coingeckoProvider{apiKey: apiKey}, nil
+	case "coinmarketcap":
+		if apiKey == "" {
+			return nil, errors.New("coinmarketcap requires an api key")
+		}
+		return &coinmarketcapProvider{apiKey: apiKey}, nil
+	default:
+		return nil, fmt.Errorf("unknown price provider %s", name)
+	}
+}
+
+func getJSON(url string, headers map[string]string, target interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(target)
+}
+
+type coinpaprikaProvider struct{}
+
+func (p *coinpaprikaProvider) Name() string {
+	return "coinpaprika"
+}
+
+func (p *coinpaprikaProvider) FetchUSDPrice() (float64, error) {
+	var response PriceResponse
+	if err := getJSON("https://api.coinpaprika.com/v1/tickers/smh-spacemesh", nil, &response); err != nil {
+		return 0, err
+	}
+	value := response.Quotes["USD"]
+	if value == nil {
+		return 0, errNoPrice
+	}
+	return value.Price, nil
+}
+
+type xtProvider struct{}
+
+func (p *xtProvider) Name() string {
+	return "xt"
+}
+
+func (p *xtProvider) FetchUSDPrice() (float64, error) {
+	var response PriceXTResponse
+	if err := getJSON("https://www.xt.com/sapi/v4/market/public/ticker/24h?symbol=smh_usdt", nil, &response); err != nil {
+		return 0, err
+	}
+	if len(response.Result) == 0 {
+		return 0, errNoPrice
+	}
+	return strconv.ParseFloat(response.Result[0].Current, 64)
+}
+
+type coingeckoProvider struct {
+	apiKey string
+}
+
+func (p *coingeckoProvider) Name() string {
+	return "coingecko"
+}
+
+func (p *coingeckoProvider) FetchUSDPrice() (float64, error) {
+	var headers map[string]string
+	if p.apiKey != "" {
+		headers = map[string]string{"x-cg-demo-api-key": p.apiKey}
+	}
+	var response map[string]map[string]float64
+	if err := getJSON("https://api.coingecko.com/api/v3/simple/price?ids=spacemesh&vs_currencies=usd", headers, &response); err != nil {
+		return 0, err
+	}
+	price, exists := response["spacemesh"]["usd"]
+	if !exists {
+		return 0, errNoPrice
+	}
+	return price, nil
+}
+
+type coinmarketcapProvider struct {
+	apiKey string
+}
+
+func (p *coinmarketcapProvider) Name() string {
+	return "coinmarketcap"
+}
+
+func (p *coinmarketcapProvider) FetchUSDPrice() (float64, error) {
+	var response PriceCMCResponse
+	headers := map[string]string{"X-CMC_PRO_API_KEY": p.apiKey}
+	if err := getJSON("https://pro-api.coinmarketcap.com/v2/cryptocurrency/quotes/latest?symbol=SMH", headers, &response); err != nil {
+		return 0, err
+	}
+	quotes := response.Data["SMH"]
+	if len(quotes) == 0 || quotes[0].Quote["USD"] == nil {
+		return 0, errNoPrice
+	}
+	return quotes[0].Quote["USD"].Price, nil
+}
@@ -0,0 +1,118 @@
+package processor
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/events"
+	"github.com/swarmbit/spacemesh-state-api/metrics"
+)
+
+// Processor is a custom processor of the events the sink saved, like a pool computing
+// the payout shares of its smeshers. A deployment registers it from an init function of
+// a package its build of the server imports, so the sink loop does not need a fork.
+//
+// Process runs in the consumer goroutine after the event is saved and before its message
+// is acked, a slow processor slows the sink and should queue its work. A message the
+// sink processes again is processed again, processors dedupe by the id of the event.
+type Processor interface {
+	// Name identifies the processor in the config, the logs and the metrics
+	Name() string
+	// Start runs once before the sink consumes, an error stops the server
+	Start(env *Environment) error
+	// Kinds are the kinds of the events package the processor receives, read after Start
+	// so a processor left disabled by its config can receive none
+	Kinds() []string
+	// Process handles one saved event, an error is logged and the event is not retried
+	Process(event *events.Event) error
+}
+
+// Environment is what a processor is started with. Reloader holds the current config,
+// Options are the settings of the processor in the config at start, empty when it has
+// none.
+type Environment struct {
+	Reloader *config.Reloader
+	Options  map[string]interface{}
+	WriteDB database.WriteStore
+	ReadDB  database.ReadStore
+}
+
+var (
+	mu         sync.Mutex
+	registered = make(map[string]Processor)
+)
+
+// Register adds a processor, it panics when the name is empty or already registered.
+func Register(processor Processor) {
+	mu.Lock()
+	defer mu.Unlock()
+	name := processor.Name()
+	if name == "" {
+		panic("processor: Register of a processor without a name")
+	}
+	if _, exists := registered[name]; exists {
+		panic("processor: Register called twice for " + name)
+	}
+	registered[name] = processor
+}
+
+// Registered lists the names of the registered processors, sorted.
+func Registered() []string {
+	mu.Lock()
+	defer mu.Unlock()
+	names := make([]string, 0, len(registered))
+	for name := range registered {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Start starts the processors the config enables and subscribes them to bus, before the
+// sink is created so they get its first events.
+func Start(reloader *config.Reloader, bus *events.Bus, writeDB database.WriteStore, readDB database.ReadStore) error {
+	processorsConfig := reloader.Current().Processors
+	names := processorsConfig.Names
+	if len(names) == 0 {
+		names = Registered()
+	}
+	for _, name := range names {
+		mu.Lock()
+		processor, exists := registered[name]
+		mu.Unlock()
+		if !exists {
+			return fmt.Errorf("processor %s is not registered, the server has %v", name, Registered())
+		}
+		options := processorsConfig.Options[name]
+		if options == nil {
+			options = make(map[string]interface{})
+		}
+		err := processor.Start(&Environment{
+			Reloader: reloader,
+			Options:  options,
+			WriteDB:  writeDB,
+			ReadDB:   readDB,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to start processor %s: %w", name, err)
+		}
+		kinds := slices.Clone(processor.Kinds())
+		sort.Strings(kinds)
+		kinds = slices.Compact(kinds)
+		for _, kind := range kinds {
+			bus.SubscribeEvents("processor "+name, kind, func(event *events.Event) {
+				if err := processor.Process(event); err != nil {
+					log.Printf("Processor %s failed on %s event of layer %d: %v", name, event.Kind, event.Layer, err)
+					metrics.EventHandlerFailures.WithLabelValues("processor " + name).Inc()
+				}
+			})
+		}
+		log.Printf("Started processor %s of %v events", name, kinds)
+	}
+	return nil
+}
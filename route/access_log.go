@@ -0,0 +1,84 @@
+package route
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/swarmbit/spacemesh-state-api/config"
+)
+
+// AccessLog writes a json line per sampled request with the method, route, status,
+// latency, client and the fingerprint of the api key, so expensive queries can be found
+// without logging every request. Slow requests are always logged at warn level.
+func AccessLog(accessLogConfig *config.AccessLogConfig) gin.HandlerFunc {
+	logger := slog.New(slog.NewJSONHandler(accessLogOutput(accessLogConfig.Output), nil))
+	sampleRate := accessLogConfig.SampleRate
+	if sampleRate == 0 {
+		sampleRate = 1
+	}
+	slowThreshold := time.Duration(accessLogConfig.SlowThreshold) * time.Millisecond
+
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		latency := time.Since(start)
+
+		slow := slowThreshold > 0 && latency >= slowThreshold
+		if !slow && (accessLogConfig.SlowOnly || rand.Float64() >= sampleRate) {
+			return
+		}
+		level := slog.LevelInfo
+		if slow {
+			level = slog.LevelWarn
+		}
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.String("route", c.FullPath()),
+			slog.String("query", c.Request.URL.RawQuery),
+			slog.Int("status", c.Writer.Status()),
+			slog.Float64("latencyMs", float64(latency.Microseconds())/1000),
+			slog.Int("bytes", max(c.Writer.Size(), 0)),
+			slog.String("client", c.ClientIP()),
+			slog.String("userAgent", c.Request.UserAgent()),
+			slog.Bool("slow", slow),
+		}
+		if apiKey := c.GetHeader("x-api-key"); apiKey != "" {
+			attrs = append(attrs, slog.String("apiKey", apiKeyFingerprint(apiKey)))
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("error", c.Errors.Last().Error()))
+		}
+		logger.LogAttrs(context.Background(), level, "request", attrs...)
+	}
+}
+
+// accessLogOutput opens the log file of output, stdout when it can not be opened.
+func accessLogOutput(output string) io.Writer {
+	switch output {
+	case "", "stdout":
+		return os.Stdout
+	case "stderr":
+		return os.Stderr
+	}
+	file, err := os.OpenFile(output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		log.Printf("Failed to open access log %s, logging to stdout: %v", output, err)
+		return os.Stdout
+	}
+	return file
+}
+
+// apiKeyFingerprint identifies the api key of a request without writing it to the log.
+func apiKeyFingerprint(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:4])
+}
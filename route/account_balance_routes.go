@@ -0,0 +1,92 @@
+package route
+
+import (
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/pkg/apperror"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+// GetAccountBalanceHistory returns the balance of the account at the end of every layer,
+// day or epoch it changed in. The changes are recorded by the sink, the balance before
+// the first recorded change is the current balance less every change so the history
+// always ends at the current balance. from and to are layers, unix times or epochs
+// depending on the resolution, both included.
+func (a *AccountRoutes) GetAccountBalanceHistory(c *gin.Context) {
+	resolution := c.DefaultQuery("resolution", database.RollupDay)
+	if resolution != database.RollupLayer && resolution != database.RollupDay && resolution != database.RollupEpoch {
+		respondError(c, apperror.New(apperror.InvalidInput, "resolution must be layer, day or epoch"))
+		return
+	}
+	from, err := strconv.ParseInt(c.DefaultQuery("from", "0"), 10, 64)
+	if err != nil {
+		respondError(c, apperror.New(apperror.InvalidInput, "from must be a valid integer"))
+		return
+	}
+	to, err := strconv.ParseInt(c.DefaultQuery("to", strconv.FormatInt(math.MaxInt64, 10)), 10, 64)
+	if err != nil {
+		respondError(c, apperror.New(apperror.InvalidInput, "to must be a valid integer"))
+		return
+	}
+
+	times, ok := newTimeFormatter(c, a.networkUtils)
+	if !ok {
+		return
+	}
+	accountAddress := c.Param("accountAddress")
+
+	account, err := a.db.GetAccount(accountAddress)
+	if err != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch account", err))
+		return
+	}
+	changes, err := a.db.GetBalanceChanges(accountAddress)
+	if err != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch balance history", err))
+		return
+	}
+
+	balance := int64(account.Balance)
+	for _, v := range changes {
+		balance -= v.Delta
+	}
+	points := make([]*types.BalanceHistoryPoint, 0)
+	var last *types.BalanceHistoryPoint
+	for _, v := range changes {
+		balance += v.Delta
+		bucket, start := a.balanceBucket(resolution, v.Id.Layer)
+		if last == nil || last.Bucket != bucket {
+			last = &types.BalanceHistoryPoint{
+				Bucket:    bucket,
+				Timestamp: start.Unix(),
+				Time:      times.format(start),
+			}
+			if bucket >= from && bucket <= to {
+				points = append(points, last)
+			}
+		}
+		last.Change += v.Delta
+		last.Balance = balance
+	}
+
+	c.JSON(200, points)
+}
+
+// balanceBucket is the layer, the unix time of the start of the day or the epoch of
+// layer and the time the bucket starts.
+func (a *AccountRoutes) balanceBucket(resolution string, layer uint32) (int64, time.Time) {
+	switch resolution {
+	case database.RollupLayer:
+		return int64(layer), a.networkUtils.GetLayerTime(uint64(layer))
+	case database.RollupEpoch:
+		epoch := uint64(a.networkUtils.GetEpoch(uint64(layer)))
+		return int64(epoch), a.networkUtils.GetEpochTime(epoch)
+	default:
+		day := a.networkUtils.GetLayerTime(uint64(layer)).Unix() / (24 * 60 * 60) * (24 * 60 * 60)
+		return day, time.Unix(day, 0)
+	}
+}
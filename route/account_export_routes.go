@@ -0,0 +1,81 @@
+package route
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/network"
+	"github.com/swarmbit/spacemesh-state-api/pkg/apperror"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+// ExportAccountRewards streams every reward of the account between from and to, unix
+// seconds, as CSV with its USD value using the price recorded when it was received.
+func (a *AccountRoutes) ExportAccountRewards(c *gin.Context) {
+	format := c.DefaultQuery("format", "csv")
+	fromStr := c.DefaultQuery("from", strconv.FormatInt(config.GenesisEpochSeconds, 10))
+	toStr := c.DefaultQuery("to", strconv.FormatInt(time.Now().Unix(), 10))
+
+	if format != "csv" {
+		respondError(c, apperror.New(apperror.InvalidInput, "format must be csv"))
+		return
+	}
+	from, err := strconv.ParseInt(fromStr, 10, 64)
+	if err != nil {
+		respondError(c, apperror.New(apperror.InvalidInput, "from must be a valid integer"))
+		return
+	}
+	to, err := strconv.ParseInt(toStr, 10, 64)
+	if err != nil {
+		respondError(c, apperror.New(apperror.InvalidInput, "to must be a valid integer"))
+		return
+	}
+	if from < config.GenesisEpochSeconds {
+		from = config.GenesisEpochSeconds
+	}
+	if to < from {
+		respondError(c, apperror.New(apperror.InvalidInput, "to must be greater or equal to from"))
+		return
+	}
+
+	firstLayer := uint32((from - config.GenesisEpochSeconds) / config.LayerDuration)
+	lastLayer := uint32((to - config.GenesisEpochSeconds) / config.LayerDuration)
+
+	prices, err := a.db.GetPrices(time.Unix(from, 0), time.Unix(to, 0))
+	if err != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch prices", err))
+		return
+	}
+
+	accountAddress := c.Param("accountAddress")
+	writer := newExportWriter(c, exportCSV, accountAddress+"-rewards")
+	err = a.db.StreamRewards(accountAddress, 1, int(firstLayer), int(lastLayer), func(reward *types.RewardsDoc) error {
+		receivedAt := a.networkUtils.GetLayerTime(uint64(reward.Layer))
+		row := &types.RewardExport{
+			Layer:     reward.Layer,
+			Time:      receivedAt.UTC().Format(time.RFC3339),
+			SmesherId: reward.NodeId,
+			AmountSMH: network.ToSmesh(uint64(reward.TotalReward)),
+		}
+		if price := priceAt(prices, receivedAt); price != nil {
+			row.USDPrice = strconv.FormatFloat(price.USDPrice, 'f', -1, 64)
+			row.USDValue = strconv.FormatFloat(price.USDPrice*float64(reward.TotalReward)/network.OneSmesh, 'f', 2, 64)
+		}
+		return writer.Write(row)
+	})
+	writer.Close(err)
+}
+
+// priceAt returns the last price recorded at or before t, prices must be sorted by time.
+func priceAt(prices []*types.PriceDoc, t time.Time) *types.PriceDoc {
+	i := sort.Search(len(prices), func(i int) bool {
+		return prices[i].Timestamp.After(t)
+	})
+	if i == 0 {
+		return nil
+	}
+	return prices[i-1]
+}
@@ -0,0 +1,88 @@
+package route
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/swarmbit/spacemesh-state-api/pkg/apperror"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+// GetAccountMultisig returns the configuration a multisig or vesting account was spawned
+// with and the signatures seen on its transactions, latest first. Signers reference the
+// public keys by their position.
+func (a *AccountRoutes) GetAccountMultisig(c *gin.Context) {
+	offsetStr := c.DefaultQuery("offset", "0")
+	limitStr := c.DefaultQuery("limit", "20")
+
+	offset, err := strconv.Atoi(offsetStr)
+	if err != nil {
+		respondError(c, apperror.New(apperror.InvalidInput, "offset must be a valid integer"))
+		return
+	}
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil {
+		respondError(c, apperror.New(apperror.InvalidInput, "limit must be a valid integer"))
+		return
+	}
+	if offset < 0 || limit < 0 {
+		respondError(c, apperror.New(apperror.InvalidInput, "offset and limit must be greater or equal to 0"))
+		return
+	}
+
+	times, ok := newTimeFormatter(c, a.networkUtils)
+	if !ok {
+		return
+	}
+	accountAddress := c.Param("accountAddress")
+
+	multisigDoc, err := a.db.GetMultisig(accountAddress)
+	if err != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch multisig", err))
+		return
+	}
+	if multisigDoc.Address == "" {
+		respondError(c, apperror.New(apperror.NotFound, "Multisig not found"))
+		return
+	}
+
+	signatures, errSignatures := a.db.GetMultisigSignatures(accountAddress, int64(offset), int64(limit))
+	count, errCount := a.db.CountMultisigSignatures(accountAddress)
+	if errSignatures != nil || errCount != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch multisig signatures", errors.Join(errSignatures, errCount)))
+		return
+	}
+
+	response := &types.AccountMultisig{
+		Address:          multisigDoc.Address,
+		Template:         multisigDoc.Template,
+		Required:         multisigDoc.Required,
+		PublicKeys:       multisigDoc.PublicKeys,
+		SpawnTransaction: multisigDoc.SpawnTransaction,
+		SpawnLayer:       multisigDoc.SpawnLayer,
+		SpawnTime:        times.layer(uint64(multisigDoc.SpawnLayer)),
+		Signatures:       make([]*types.MultisigSignatures, len(signatures)),
+	}
+	for i, v := range signatures {
+		signers := make([]*types.MultisigSigner, len(v.Refs))
+		for j, ref := range v.Refs {
+			signers[j] = &types.MultisigSigner{Ref: ref}
+			// a ref out of range fails verification, it has no public key
+			if int(ref) < len(multisigDoc.PublicKeys) {
+				signers[j].PublicKey = multisigDoc.PublicKeys[ref]
+			}
+		}
+		response.Signatures[i] = &types.MultisigSignatures{
+			TransactionId: v.Transaction,
+			Layer:         v.Layer,
+			Time:          times.layer(uint64(v.Layer)),
+			Status:        v.Status,
+			Type:          v.Type,
+			Signers:       signers,
+		}
+	}
+
+	c.Header("total", strconv.FormatInt(count, 10))
+	c.JSON(200, response)
+}
@@ -1,37 +1,41 @@
 package route
 
 import (
-    "fmt"
+    "errors"
     "log"
-    "net/http"
     "strconv"
 
     "github.com/gin-gonic/gin"
     "github.com/swarmbit/spacemesh-state-api/config"
     "github.com/swarmbit/spacemesh-state-api/database"
     "github.com/swarmbit/spacemesh-state-api/network"
+    "github.com/swarmbit/spacemesh-state-api/pkg/address"
+    "github.com/swarmbit/spacemesh-state-api/pkg/apperror"
     "github.com/swarmbit/spacemesh-state-api/price"
     "github.com/swarmbit/spacemesh-state-api/types"
 )
 
 type AccountRoutes struct {
-    db            *database.ReadDB
+    db            database.ReadStore
     networkUtils  *network.NetworkUtils
     state         *network.NetworkState
     priceResolver *price.PriceResolver
+    reloader      *config.Reloader
 }
 
 func NewAccountRoutes(
-    readDB *database.ReadDB,
+    readDB database.ReadStore,
     networkUtils *network.NetworkUtils,
     state *network.NetworkState,
     priceResolver *price.PriceResolver,
+    reloader *config.Reloader,
 ) *AccountRoutes {
     return &AccountRoutes{
         db:            readDB,
         networkUtils:  networkUtils,
         state:         state,
         priceResolver: priceResolver,
+        reloader:      reloader,
     }
 }
 
@@ -42,23 +46,17 @@ func (a *AccountRoutes) GetAccountsPost(c *gin.Context) {
 
     offset, err := strconv.Atoi(offsetStr)
     if err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{
-            "error": "offset must be a valid integer",
-        })
+        respondError(c, apperror.New(apperror.InvalidInput, "offset must be a valid integer"))
         return
     }
     limit, err := strconv.Atoi(limitStr)
     if err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{
-            "error": "limit must be a valid integer",
-        })
+        respondError(c, apperror.New(apperror.InvalidInput, "limit must be a valid integer"))
         return
     }
 
     if offset < 0 || limit < 0 {
-        c.JSON(http.StatusBadRequest, gin.H{
-            "error": "offset and limit must be greater or equal to 0",
-        })
+        respondError(c, apperror.New(apperror.InvalidInput, "offset and limit must be greater or equal to 0"))
         return
     }
 
@@ -72,35 +70,15 @@ func (a *AccountRoutes) GetAccountsPost(c *gin.Context) {
     epochStr := c.Param("epoch")
     epoch, err := strconv.Atoi(epochStr)
     if err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{
-            "error": "epoch must be a valid integer",
-        })
+        respondError(c, apperror.New(apperror.InvalidInput, "epoch must be a valid integer"))
         return
     }
 
     accounts, errAccounts := a.db.GetAccountsPostEpoch(epoch-1, int64(offset), int64(limit), sort)
-    if err != nil {
-        fmt.Println(err)
-        c.JSON(http.StatusBadRequest, gin.H{
-            "error": "failed to fetch accounts",
-        })
-        return
-    }
-
     count, errCount := a.db.CountAccountsPostEpoch(epoch - 1)
-    if err != nil {
-        fmt.Println(err)
-        c.JSON(http.StatusBadRequest, gin.H{
-            "error": "failed to count accounts",
-        })
-        return
-    }
 
     if errAccounts != nil || errCount != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{
-            "status": "Internal Error",
-            "error":  "Failed to fetch account",
-        })
+        respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch account", errors.Join(errAccounts, errCount)))
     } else if accounts != nil {
 
         accountsResponse := make([]*types.AccountPostResponse, len(accounts))
@@ -130,23 +108,17 @@ func (a *AccountRoutes) GetAccounts(c *gin.Context) {
 
     offset, err := strconv.Atoi(offsetStr)
     if err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{
-            "error": "offset must be a valid integer",
-        })
+        respondError(c, apperror.New(apperror.InvalidInput, "offset must be a valid integer"))
         return
     }
     limit, err := strconv.Atoi(limitStr)
     if err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{
-            "error": "limit must be a valid integer",
-        })
+        respondError(c, apperror.New(apperror.InvalidInput, "limit must be a valid integer"))
         return
     }
 
     if offset < 0 || limit < 0 {
-        c.JSON(http.StatusBadRequest, gin.H{
-            "error": "offset and limit must be greater or equal to 0",
-        })
+        respondError(c, apperror.New(apperror.InvalidInput, "offset and limit must be greater or equal to 0"))
         return
     }
 
@@ -157,45 +129,42 @@ func (a *AccountRoutes) GetAccounts(c *gin.Context) {
         sort = -1
     }
 
+    currency, ok := fiatCurrency(c, a.priceResolver)
+    if !ok {
+        return
+    }
+
     accounts, errAccounts := a.db.GetAccounts(int64(offset), int64(limit), sort)
     if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{
-            "status": "Internal Error",
-            "error":  "Failed to get accounts",
-        })
+        respondError(c, apperror.Wrap(apperror.Internal, "Failed to get accounts", err))
         return
     }
 
     count, errCount := a.db.CountAccounts()
     if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{
-            "status": "Internal Error",
-            "error":  "Failed to count accounts",
-        })
+        respondError(c, apperror.Wrap(apperror.Internal, "Failed to count accounts", err))
         return
     }
 
     if errAccounts != nil || errCount != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{
-            "status": "Internal Error",
-            "error":  "Failed to fetch transactions for account",
-        })
+        respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch transactions for account", errors.Join(errAccounts, errCount)))
     } else if accounts != nil {
 
         accountsResponse := make([]*types.ShortAccount, len(accounts))
 
+        priceValue := a.priceResolver.GetPrice()
+        fiatPrice := a.priceResolver.GetPriceIn(currency)
         for i, v := range accounts {
-            priceValue := a.priceResolver.GetPrice()
-            dollarValue := int64(-1)
-            if priceValue > -1 {
-                dollarValue = int64(priceValue * float64(v.Balance))
-            }
             accountsResponse[i] = &types.ShortAccount{
                 Balance:      v.Balance,
                 Address:      v.Address,
-                USDValue:     dollarValue,
+                USDValue:     fiatValue(priceValue, v.Balance),
                 TotalRewards: v.TotalRewards,
             }
+            if currency != "" {
+                accountsResponse[i].Currency = currency
+                accountsResponse[i].FiatValue = fiatValue(fiatPrice, v.Balance)
+            }
         }
 
         c.Header("total", strconv.FormatInt(count, 10))
@@ -210,85 +179,180 @@ func (a *AccountRoutes) GetAccountGroup(c *gin.Context) {
     var req types.AccounGroupRequest
 
     if err := c.ShouldBindJSON(&req); err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        respondError(c, apperror.New(apperror.InvalidInput, err.Error()))
         return
     }
-    result, err := a.db.GetAccountsGroup(req.Accounts)
+    accounts, err := address.NormalizeAddresses(req.Accounts)
     if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{
-            "status": "Internal Error",
-            "error":  "Failed to fetch account group",
-        })
+        respondError(c, apperror.New(apperror.InvalidInput, err.Error()))
         return
     }
-
-    priceValue := a.priceResolver.GetPrice()
-    dollarValue := int64(-1)
-    if priceValue > -1 {
-        dollarValue = int64(priceValue * float64(result.Balance))
+    currency, ok := fiatCurrency(c, a.priceResolver)
+    if !ok {
+        return
+    }
+    result, err := a.db.GetAccountsGroup(accounts)
+    if err != nil {
+        respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch account group", err))
+        return
     }
 
-    c.JSON(200, &types.AccountGroupResponse{
+    response := &types.AccountGroupResponse{
         Balance:      uint64(result.Balance),
-        USDValue:     dollarValue,
+        USDValue:     fiatValue(a.priceResolver.GetPrice(), uint64(result.Balance)),
         TotalRewards: uint64(result.TotalRewards),
-    })
+    }
+    if currency != "" {
+        response.Currency = currency
+        response.FiatValue = fiatValue(a.priceResolver.GetPriceIn(currency), uint64(result.Balance))
+    }
+
+    c.JSON(200, response)
 
 }
 
+// GetAccountsBatch returns the balance, reward total and next nonce of every account of
+// the request in one round trip, addresses repeated in the request are returned once.
+func (a *AccountRoutes) GetAccountsBatch(c *gin.Context) {
+    var req types.AccounGroupRequest
+
+    if err := c.ShouldBindJSON(&req); err != nil {
+        respondError(c, apperror.New(apperror.InvalidInput, err.Error()))
+        return
+    }
+    maxAccounts := maxBatchAccounts(a.reloader)
+    if len(req.Accounts) == 0 || len(req.Accounts) > maxAccounts {
+        respondError(c, apperror.New(apperror.InvalidInput, "accounts must have between 1 and "+strconv.Itoa(maxAccounts)+" addresses"))
+        return
+    }
+    accounts, err := address.NormalizeAddresses(req.Accounts)
+    if err != nil {
+        respondError(c, apperror.New(apperror.InvalidInput, err.Error()))
+        return
+    }
+    currency, ok := fiatCurrency(c, a.priceResolver)
+    if !ok {
+        return
+    }
+
+    unique := make([]string, 0, len(accounts))
+    seen := make(map[string]bool, len(accounts))
+    for _, v := range accounts {
+        if !seen[v] {
+            seen[v] = true
+            unique = append(unique, v)
+        }
+    }
+
+    accountDocs, errAccounts := a.db.GetAccountsByAddress(unique)
+    counters, errCounters := a.db.GetAccountsCounters(unique)
+    if errAccounts != nil || errCounters != nil {
+        respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch accounts", errors.Join(errAccounts, errCounters)))
+        return
+    }
+    byAddress := make(map[string]*types.AccountDoc, len(accountDocs))
+    for _, v := range accountDocs {
+        byAddress[v.Address] = v
+    }
+    nonces := make(map[string]uint64, len(counters))
+    for _, v := range counters {
+        nonces[v.Address] = v.Counter + 1
+    }
+
+    priceValue := a.priceResolver.GetPrice()
+    fiatPrice := a.priceResolver.GetPriceIn(currency)
+    response := make([]*types.AccountBatchItem, len(unique))
+    for i, v := range unique {
+        item := &types.AccountBatchItem{
+            Address: v,
+            Nonce:   nonces[v],
+        }
+        if account, exists := byAddress[v]; exists {
+            item.Found = true
+            item.Balance = account.Balance
+            item.TotalRewards = account.TotalRewards
+        }
+        item.USDValue = fiatValue(priceValue, item.Balance)
+        if currency != "" {
+            item.Currency = currency
+            item.FiatValue = fiatValue(fiatPrice, item.Balance)
+        }
+        response[i] = item
+    }
+
+    c.JSON(200, response)
+}
+
 func (a *AccountRoutes) GetAccount(c *gin.Context) {
+    currency, ok := fiatCurrency(c, a.priceResolver)
+    if !ok {
+        return
+    }
+    times, ok := newTimeFormatter(c, a.networkUtils)
+    if !ok {
+        return
+    }
     accountAddress := c.Param("accountAddress")
     account, err := a.db.GetAccount(accountAddress)
     if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{
-            "status": "Internal Error",
-            "error":  "Failed to fetch account",
-        })
+        respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch account", err))
         return
     }
     if account.Address == "" {
-        c.JSON(http.StatusNotFound, gin.H{
-            "status": "Not Found",
-            "error":  "Account not found",
-        })
+        respondError(c, apperror.New(apperror.NotFound, "Account not found"))
         return
     }
-    numberOfTransactions, err := a.db.CountTransactions(accountAddress)
+    numberOfTransactions, err := a.db.CountTransactions(accountAddress, "")
     if err != nil {
         log.Println(err)
-        c.JSON(http.StatusInternalServerError, gin.H{
-            "status": "Internal Error",
-            "error":  "Failed to fetch account",
-        })
+        respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch account", err))
         return
     }
-    numberOfRewards, err := a.db.CountRewards(accountAddress, -1, -1)
+    rewardsTotal, err := a.db.GetCoinbaseRewardsTotal(accountAddress, database.AllEpochs)
     if err != nil {
         log.Println(err)
-        c.JSON(http.StatusInternalServerError, gin.H{
-            "status": "Internal Error",
-            "error":  "Failed to fetch account",
-        })
+        respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch account", err))
         return
     }
 
-    priceValue := a.priceResolver.GetPrice()
-    dollarValue := int64(-1)
-    if priceValue > -1 {
-        dollarValue = int64(priceValue * float64(account.Balance))
-    }
-
-    c.JSON(200, &types.Account{
+    response := &types.Account{
         Balance:  account.Balance,
-        USDValue: dollarValue,
+        USDValue: fiatValue(a.priceResolver.GetPrice(), account.Balance),
         // legacy
         BalanceDisplay:       "",
         Address:              accountAddress,
         TotalRewards:         account.TotalRewards,
         NumberOfTransactions: numberOfTransactions,
         Counter:              numberOfTransactions,
-        NumberOfRewards:      numberOfRewards,
-    })
+        NumberOfRewards:      rewardsTotal.RewardsCount,
+        TotalFees:            account.Fees,
+    }
+    activityReady, err := a.db.AccountActivityReady()
+    if err != nil {
+        log.Println(err)
+        respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch account", err))
+        return
+    }
+    if activityReady {
+        response.FirstSeenLayer = &account.FirstSeenLayer
+        response.LastActivityLayer = &account.LastActivityLayer
+        response.IncomingTransfers = &account.IncomingTransfers
+        response.OutgoingTransfers = &account.OutgoingTransfers
+        if account.FirstSeenLayer > 0 {
+            response.FirstSeenTime = times.layer(uint64(account.FirstSeenLayer))
+            response.FirstSeenTimestamp = config.GenesisEpochSeconds + int64(account.FirstSeenLayer)*config.LayerDuration
+        }
+        if account.LastActivityLayer > 0 {
+            response.LastActivityTime = times.layer(uint64(account.LastActivityLayer))
+            response.LastActivityTimestamp = config.GenesisEpochSeconds + int64(account.LastActivityLayer)*config.LayerDuration
+        }
+    }
+    if currency != "" {
+        response.Currency = currency
+        response.FiatValue = fiatValue(a.priceResolver.GetPriceIn(currency), account.Balance)
+    }
+
+    c.JSON(200, response)
 }
 
 func (a *AccountRoutes) GetAccountRewards(c *gin.Context) {
@@ -301,39 +365,29 @@ func (a *AccountRoutes) GetAccountRewards(c *gin.Context) {
 
     firstLayer, err := strconv.Atoi(firstLayerStr)
     if err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{
-            "error": "firstLayer must be a valid integer",
-        })
+        respondError(c, apperror.New(apperror.InvalidInput, "firstLayer must be a valid integer"))
         return
     }
 
     lastLayer, err := strconv.Atoi(lastLayerStr)
     if err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{
-            "error": "lastLayer must be a valid integer",
-        })
+        respondError(c, apperror.New(apperror.InvalidInput, "lastLayer must be a valid integer"))
         return
     }
 
     offset, err := strconv.Atoi(offsetStr)
     if err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{
-            "error": "offset must be a valid integer",
-        })
+        respondError(c, apperror.New(apperror.InvalidInput, "offset must be a valid integer"))
         return
     }
     limit, err := strconv.Atoi(limitStr)
     if err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{
-            "error": "limit must be a valid integer",
-        })
+        respondError(c, apperror.New(apperror.InvalidInput, "limit must be a valid integer"))
         return
     }
 
     if offset < 0 || limit < 0 {
-        c.JSON(http.StatusBadRequest, gin.H{
-            "error": "offset and limit must be greater or equal to 0",
-        })
+        respondError(c, apperror.New(apperror.InvalidInput, "offset and limit must be greater or equal to 0"))
         return
     }
 
@@ -344,30 +398,53 @@ func (a *AccountRoutes) GetAccountRewards(c *gin.Context) {
         sort = 1
     }
 
+    times, ok := newTimeFormatter(c, a.networkUtils)
+    if !ok {
+        return
+    }
+
     accountAddress := c.Param("accountAddress")
+    if format := exportFormat(c); format != "" {
+        writer := newExportWriter(c, format, accountAddress+"-rewards")
+        writer.Close(a.db.StreamRewards(accountAddress, sort, firstLayer, lastLayer, func(v *types.RewardsDoc) error {
+            return writer.Write(toReward(v, times))
+        }))
+        return
+    }
+
+    page, ok := pageCursor(c, sort)
+    if !ok {
+        return
+    }
+    if page != nil {
+        rewards, err := a.db.GetRewardsAfter(accountAddress, page.after, int64(limit), page.sort, firstLayer, lastLayer)
+        if err != nil {
+            respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch rewards for account", err))
+            return
+        }
+        rewardsResponse := make([]*types.Reward, len(rewards))
+        for i, v := range rewards {
+            rewardsResponse[i] = toListedReward(v, times)
+        }
+        if len(rewards) > 0 {
+            last := rewards[len(rewards)-1]
+            page.next(c, limit, len(rewards), last.Layer, last.Id)
+        }
+        c.JSON(200, rewardsResponse)
+        return
+    }
+
     rewards, errRewards := a.db.GetRewards(accountAddress, int64(offset), int64(limit), sort, firstLayer, lastLayer)
     count, errCount := a.db.CountRewards(accountAddress, firstLayer, lastLayer)
 
     if errRewards != nil || errCount != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{
-            "status": "Internal Error",
-            "error":  "Failed to fetch rewards for account",
-        })
+        respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch rewards for account", errors.Join(errRewards, errCount)))
     } else if rewards != nil {
 
         rewardsResponse := make([]*types.Reward, len(rewards))
 
         for i, v := range rewards {
-            rewardsResponse[i] = &types.Reward{
-                Rewards: int64(v.TotalReward),
-                // legacy
-                RewardsDisplay: "",
-                Layer:          v.Layer,
-                SmesherId:      v.NodeId,
-                // legacy
-                Time:      "2023-09-05T00:00:00Z",
-                Timestamp: config.GenesisEpochSeconds + (v.Layer * config.LayerDuration),
-            }
+            rewardsResponse[i] = toListedReward(v, times)
         }
 
         c.Header("total", strconv.FormatInt(count, 10))
@@ -382,27 +459,20 @@ func (a *AccountRoutes) GetAccountTransactions(c *gin.Context) {
     offsetStr := c.DefaultQuery("offset", "0")
     limitStr := c.DefaultQuery("limit", "20")
     sortStr := c.DefaultQuery("sort", "asc")
-    completeStr := c.DefaultQuery("complete", "true")
 
     offset, err := strconv.Atoi(offsetStr)
     if err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{
-            "error": "offset must be a valid integer",
-        })
+        respondError(c, apperror.New(apperror.InvalidInput, "offset must be a valid integer"))
         return
     }
     limit, err := strconv.Atoi(limitStr)
     if err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{
-            "error": "limit must be a valid integer",
-        })
+        respondError(c, apperror.New(apperror.InvalidInput, "limit must be a valid integer"))
         return
     }
 
     if offset < 0 || limit < 0 {
-        c.JSON(http.StatusBadRequest, gin.H{
-            "error": "offset and limit must be greater or equal to 0",
-        })
+        respondError(c, apperror.New(apperror.InvalidInput, "offset and limit must be greater or equal to 0"))
         return
     }
 
@@ -413,46 +483,58 @@ func (a *AccountRoutes) GetAccountTransactions(c *gin.Context) {
         sort = 1
     }
 
-    complete := completeStr == "true"
+    state, ok := transactionState(c)
+    if !ok {
+        return
+    }
+
+    times, ok := newTimeFormatter(c, a.networkUtils)
+    if !ok {
+        return
+    }
 
     accountAddress := c.Param("accountAddress")
-    transactions, errRewards := a.db.GetTransactions(accountAddress, int64(offset), int64(limit), sort, complete)
-    count, errCount := a.db.CountTransactions(accountAddress)
+    if format := exportFormat(c); format != "" {
+        writer := newExportWriter(c, format, accountAddress+"-transactions")
+        writer.Close(a.db.StreamTransactions(accountAddress, sort, state, func(v *types.TransactionDoc) error {
+            return writer.Write(toTransaction(v, times))
+        }))
+        return
+    }
+
+    page, ok := pageCursor(c, sort)
+    if !ok {
+        return
+    }
+    if page != nil {
+        transactions, err := a.db.GetTransactionsAfter(accountAddress, page.after, int64(limit), page.sort, state)
+        if err != nil {
+            respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch transactions for account", err))
+            return
+        }
+        transactionsResponse := make([]*types.Transaction, len(transactions))
+        for i, v := range transactions {
+            transactionsResponse[i] = toTransaction(v, times)
+        }
+        if len(transactions) > 0 {
+            last := transactions[len(transactions)-1]
+            page.next(c, limit, len(transactions), int64(last.Layer), last.ID)
+        }
+        c.JSON(200, transactionsResponse)
+        return
+    }
+
+    transactions, errRewards := a.db.GetTransactions(accountAddress, int64(offset), int64(limit), sort, state)
+    count, errCount := a.db.CountTransactions(accountAddress, state)
 
     if errRewards != nil || errCount != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{
-            "status": "Internal Error",
-            "error":  "Failed to fetch transactions for account",
-        })
+        respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch transactions for account", errors.Join(errRewards, errCount)))
     } else if transactions != nil {
 
         transactionsResponse := make([]*types.Transaction, len(transactions))
 
         for i, v := range transactions {
-            method := ""
-            if v.Method == 0 {
-                method = "Spawn"
-            }
-            if v.Method == 16 {
-                method = "Spend"
-            }
-            if v.Method == 17 {
-                method = "DrainVault"
-            }
-            transactionsResponse[i] = &types.Transaction{
-                ID:               v.ID,
-                Status:           v.Status,
-                PrincipalAccount: v.PrincipaAccount,
-                ReceiverAccount:  v.ReceiverAccount,
-                VaultAccount:     v.VaultAccount,
-                Fee:              v.Gas * v.GasPrice,
-                Amount:           v.Amount,
-                Layer:            v.Layer,
-                Counter:          v.Counter,
-                Method:           method,
-                Type:             v.Type,
-                Timestamp:        int64(config.GenesisEpochSeconds + (v.Layer * config.LayerDuration)),
-            }
+            transactionsResponse[i] = toTransaction(v, times)
         }
 
         c.Header("total", strconv.FormatInt(count, 10))
@@ -463,10 +545,66 @@ func (a *AccountRoutes) GetAccountTransactions(c *gin.Context) {
     }
 }
 
+// GetAccountPendingTransactions lists the created transactions of the account that have
+// no result yet, stale ones are expired by the sink.
+func (a *AccountRoutes) GetAccountPendingTransactions(c *gin.Context) {
+    offsetStr := c.DefaultQuery("offset", "0")
+    limitStr := c.DefaultQuery("limit", "20")
+    sortStr := c.DefaultQuery("sort", "desc")
+
+    offset, err := strconv.Atoi(offsetStr)
+    if err != nil {
+        respondError(c, apperror.New(apperror.InvalidInput, "offset must be a valid integer"))
+        return
+    }
+    limit, err := strconv.Atoi(limitStr)
+    if err != nil {
+        respondError(c, apperror.New(apperror.InvalidInput, "limit must be a valid integer"))
+        return
+    }
+
+    if offset < 0 || limit < 0 {
+        respondError(c, apperror.New(apperror.InvalidInput, "offset and limit must be greater or equal to 0"))
+        return
+    }
+
+    var sort int8
+    if sortStr == "asc" {
+        sort = 1
+    } else {
+        sort = -1
+    }
+
+    times, ok := newTimeFormatter(c, a.networkUtils)
+    if !ok {
+        return
+    }
+
+    accountAddress := c.Param("accountAddress")
+    transactions, errTransactions := a.db.GetTransactions(accountAddress, int64(offset), int64(limit), sort, types.TransactionCreated)
+    count, errCount := a.db.CountTransactions(accountAddress, types.TransactionCreated)
+
+    if errTransactions != nil || errCount != nil {
+        respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch pending transactions for account", errors.Join(errTransactions, errCount)))
+        return
+    }
+
+    transactionsResponse := make([]*types.Transaction, len(transactions))
+    for i, v := range transactions {
+        transactionsResponse[i] = toTransaction(v, times)
+    }
+    c.Header("total", strconv.FormatInt(count, 10))
+    c.JSON(200, transactionsResponse)
+}
+
+func (a *AccountRoutes) GetAccountRewardsChart(c *gin.Context) {
+    rewardsChart(c, a.db, a.networkUtils, c.Param("accountAddress"))
+}
+
 func (a *AccountRoutes) GetAccountRewardsDetails(c *gin.Context) {
     accountAddress := c.Param("accountAddress")
 
-    networkInfo := a.state.GetInfo()
+    networkInfo := requestState(c, a.state).GetInfo()
     epoch := networkInfo.Epoch
 
     a.getAccountRewardDetailsForEpoch(c, accountAddress, int(epoch))
@@ -480,20 +618,22 @@ func (a *AccountRoutes) FilterEpochActiveNodes(c *gin.Context) {
     epochStr := c.Param("epoch")
     epoch, err := strconv.Atoi(epochStr)
     if err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{
-            "error": "epoch must be a valid integer",
-        })
+        respondError(c, apperror.New(apperror.InvalidInput, "epoch must be a valid integer"))
         return
     }
 
     var req types.NodeFilterRequest
 
     if err := c.ShouldBindJSON(&req); err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        respondError(c, apperror.New(apperror.InvalidInput, err.Error()))
         return
     }
 
-    nodes := req.Nodes
+    nodes, err := address.NormalizeNodeIds(req.Nodes)
+    if err != nil {
+        respondError(c, apperror.New(apperror.InvalidInput, err.Error()))
+        return
+    }
 
     if epoch == 8 {
         c.JSON(200, &types.ActiveNodesEpoch{
@@ -502,9 +642,7 @@ func (a *AccountRoutes) FilterEpochActiveNodes(c *gin.Context) {
     } else {
         activeNodes, err := a.db.FilterAccountAtxNodesForEpoch(accountAddress, uint64(epoch-1), nodes)
         if err != nil {
-            c.JSON(http.StatusInternalServerError, gin.H{
-                "error": "failed to filter nodes",
-            })
+            respondError(c, apperror.Wrap(apperror.Internal, "failed to filter nodes", err))
             return
         }
 
@@ -522,9 +660,7 @@ func (a *AccountRoutes) GetEpochAtx(c *gin.Context) {
     epoch, err := strconv.Atoi(epochStr)
 
     if err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{
-            "error": "epoch must be a valid integer",
-        })
+        respondError(c, apperror.New(apperror.InvalidInput, "epoch must be a valid integer"))
         return
     }
 
@@ -534,23 +670,17 @@ func (a *AccountRoutes) GetEpochAtx(c *gin.Context) {
 
     offset, err := strconv.Atoi(offsetStr)
     if err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{
-            "error": "offset must be a valid integer",
-        })
+        respondError(c, apperror.New(apperror.InvalidInput, "offset must be a valid integer"))
         return
     }
     limit, err := strconv.Atoi(limitStr)
     if err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{
-            "error": "limit must be a valid integer",
-        })
+        respondError(c, apperror.New(apperror.InvalidInput, "limit must be a valid integer"))
         return
     }
 
     if offset < 0 || limit < 0 {
-        c.JSON(http.StatusBadRequest, gin.H{
-            "error": "offset and limit must be greater or equal to 0",
-        })
+        respondError(c, apperror.New(apperror.InvalidInput, "offset and limit must be greater or equal to 0"))
         return
     }
 
@@ -561,32 +691,35 @@ func (a *AccountRoutes) GetEpochAtx(c *gin.Context) {
         sort = 1
     }
 
+    times, ok := newTimeFormatter(c, a.networkUtils)
+    if !ok {
+        return
+    }
+
+    if format := exportFormat(c); format != "" {
+        writer := newExportWriter(c, format, accountAddress+"-atx-"+strconv.Itoa(epoch))
+        writer.Close(a.db.StreamAccountAtxEpoch(accountAddress, uint64(epoch-1), sort, func(v *types.AtxDoc) error {
+            return writer.Write(toAtx(v, times))
+        }))
+        return
+    }
+
     atxs, errAtx := a.db.GetAccountAtxEpoch(accountAddress, uint64(epoch-1), int64(offset), int64(limit), sort)
     count, errCount := a.db.CountAccountAtxEpoch(accountAddress, uint64(epoch-1))
 
     if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{
-            "error": "failed to filter nodes",
-        })
+        respondError(c, apperror.Wrap(apperror.Internal, "failed to filter nodes", err))
         return
     }
 
     if errAtx != nil || errCount != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{
-            "status": "Internal Error",
-            "error":  "Failed to fetch atx for account",
-        })
+        respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch atx for account", errors.Join(errAtx, errCount)))
     } else if atxs != nil {
 
         atxResponse := make([]*types.Atx, len(atxs))
 
         for i, a := range atxs {
-            atxResponse[i] = &types.Atx{
-                NodeId:            a.NodeID,
-                AtxId:             a.AtxID,
-                EffectiveNumUnits: a.EffectiveNumUnits,
-                Received:          a.Received,
-            }
+            atxResponse[i] = toAccountAtx(a, times)
         }
 
         c.Header("total", strconv.FormatInt(count, 10))
@@ -604,15 +737,11 @@ func (a *AccountRoutes) GetAccountRewardsDetailsEpoch(c *gin.Context) {
     epochStr := c.Param("epoch")
     epoch, err := strconv.Atoi(epochStr)
     if err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{
-            "error": "epoch must be a valid integer",
-        })
+        respondError(c, apperror.New(apperror.InvalidInput, "epoch must be a valid integer"))
         return
     }
     if epoch < 2 {
-        c.JSON(http.StatusBadRequest, gin.H{
-            "error": "epoch should be equal or greater than 2",
-        })
+        respondError(c, apperror.New(apperror.InvalidInput, "epoch should be equal or greater than 2"))
         return
     }
 
@@ -622,49 +751,24 @@ func (a *AccountRoutes) GetAccountRewardsDetailsEpoch(c *gin.Context) {
 func (a *AccountRoutes) getAccountRewardDetailsForEpoch(c *gin.Context, accountAddress string, epoch int) {
     epochAtx, err := a.db.GetAtxEpoch(uint64(epoch - 1))
     if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{
-            "status": "Internal Error",
-            "error":  "Failed to get atx epoch",
-        })
+        respondError(c, apperror.Wrap(apperror.Internal, "Failed to get atx epoch", err))
         return
     }
 
     if epochAtx.TotalWeight == 0 {
-        c.JSON(http.StatusNotFound, gin.H{
-            "status": "Not found",
-            "error":  "No details for epoch",
-        })
+        respondError(c, apperror.New(apperror.NotFound, "No details for epoch"))
         return
     }
 
-    firstLayer := uint32(epoch * config.LayersPerEpoch)
-    lastLayer := firstLayer + config.LayersPerEpoch
-
-    countEpochResult, err := a.db.CountRewards(accountAddress, int(firstLayer), int(lastLayer))
+    epochRewards, err := a.db.GetCoinbaseRewardsTotal(accountAddress, int64(epoch))
     if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{
-            "status": "Internal Error",
-            "error":  "Failed to get epoch rewards count",
-        })
-        return
-    }
-
-    sumEpochResult, err := a.db.SumRewardsLayers(accountAddress, firstLayer, lastLayer)
-    if err != nil {
-        fmt.Println(err)
-        c.JSON(http.StatusInternalServerError, gin.H{
-            "status": "Internal Error",
-            "error":  "Failed to get epoch rewards sum",
-        })
+        respondError(c, apperror.Wrap(apperror.Internal, "Failed to get epoch rewards", err))
         return
     }
 
     accountAtxs, err := a.db.GetAccountAtxList(accountAddress, uint64(epoch-1))
     if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{
-            "status": "Internal Error",
-            "error":  "Failed to get account weight",
-        })
+        respondError(c, apperror.Wrap(apperror.Internal, "Failed to get account weight", err))
         return
     }
 
@@ -674,10 +778,7 @@ func (a *AccountRoutes) getAccountRewardDetailsForEpoch(c *gin.Context, accountA
     for _, atx := range accountAtxs {
         eligibilityCountTemp, err := a.networkUtils.GetNumberOfSlots(uint64(atx.Weight), epochAtx.TotalWeight, uint32(epoch))
         if err != nil {
-            c.JSON(http.StatusInternalServerError, gin.H{
-                "status": "Internal Error",
-                "error":  "Failed to get eligibility",
-            })
+            respondError(c, apperror.Wrap(apperror.Internal, "Failed to get eligibility", err))
             return
         }
         eligibilityCount += eligibilityCountTemp
@@ -686,20 +787,17 @@ func (a *AccountRoutes) getAccountRewardDetailsForEpoch(c *gin.Context, accountA
     }
 
     if totalWeight == 0 {
-        c.JSON(http.StatusNotFound, gin.H{
-            "status": "Not found",
-            "error":  "Account not active for epoch",
-        })
+        respondError(c, apperror.New(apperror.NotFound, "Account not active for epoch"))
         return
     }
 
-    unitReward := a.state.GetEpochSubsidy(uint32(epoch)) / epochAtx.TotalWeight
+    unitReward := requestState(c, a.state).GetEpochSubsidy(uint32(epoch)) / epochAtx.TotalWeight
     predictedRewards := unitReward * uint64(totalWeight)
 
     c.JSON(200, &types.RewardDetailsEpoch{
         Epoch:        int64(epoch),
-        RewardsSum:   sumEpochResult,
-        RewardsCount: countEpochResult,
+        RewardsSum:   epochRewards.Rewards,
+        RewardsCount: epochRewards.RewardsCount,
         Eligibility: &types.Eligibility{
             Count:             eligibilityCount,
             EffectiveNumUnits: int64(totalEffectiveNumUnits),
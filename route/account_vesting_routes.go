@@ -0,0 +1,112 @@
+package route
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/network"
+	"github.com/swarmbit/spacemesh-state-api/pkg/apperror"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+// maxVestingPoints bounds the vesting schedule of a response.
+const maxVestingPoints = 100
+
+// GetAccountVesting returns the vault of an account, a vault spawned by a transaction or
+// a genesis vault of the vesting schedule that was not spawned yet. Vested amounts are
+// at the last processed layer.
+func (a *AccountRoutes) GetAccountVesting(c *gin.Context) {
+	times, ok := newTimeFormatter(c, a.networkUtils)
+	if !ok {
+		return
+	}
+	accountAddress := c.Param("accountAddress")
+
+	vaultDoc, err := a.db.GetVault(accountAddress)
+	if err != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch vault", err))
+		return
+	}
+	genesisVault := a.state.GetVestingSchedule().GetVault(accountAddress)
+
+	response := &types.AccountVesting{
+		Address: accountAddress,
+		Genesis: genesisVault != nil,
+		Spawned: vaultDoc.Address != "",
+	}
+	var vault *network.Vault
+	if vaultDoc.Address != "" {
+		vault = &network.Vault{
+			Address:      vaultDoc.Address,
+			TotalAmount:  vaultDoc.TotalAmount,
+			VestingStart: uint64(vaultDoc.VestingStart),
+			VestingEnd:   uint64(vaultDoc.VestingEnd),
+		}
+		response.Owner = vaultDoc.Owner
+		response.InitialUnlockAmount = vaultDoc.InitialUnlockAmount
+	} else if genesisVault != nil {
+		vault = genesisVault
+	} else {
+		respondError(c, apperror.New(apperror.NotFound, "Vault not found"))
+		return
+	}
+
+	lastLayer, err := a.db.GetLastProcessedLayer()
+	if err != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to get last processed layer", err))
+		return
+	}
+	drained, err := a.db.GetVaultDrained(accountAddress)
+	if err != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch vault drains", err))
+		return
+	}
+
+	layer := uint64(lastLayer.Layer)
+	vested := vault.VestedAt(layer)
+	response.TotalVaulted = vault.TotalAmount
+	response.VestingStart = vault.VestingStart
+	response.VestingStartTime = times.layer(vault.VestingStart)
+	response.VestingEnd = vault.VestingEnd
+	response.VestingEndTime = times.layer(vault.VestingEnd)
+	response.Layer = layer
+	response.Vested = vested
+	response.Drained = drained
+	response.Locked = vault.TotalAmount - vested
+	if vested > drained {
+		response.Available = vested - drained
+	}
+
+	// the next epochs from the vesting start until the first one starting after the
+	// vesting end, every few epochs when there are more than maxVestingPoints
+	response.Schedule = make([]*types.VestingPoint, 0)
+	layersPerEpoch := uint64(config.LayersPerEpoch)
+	if layer < vault.VestingEnd {
+		first := layer/layersPerEpoch + 1
+		if startEpoch := (vault.VestingStart + layersPerEpoch - 1) / layersPerEpoch; startEpoch > first {
+			first = startEpoch
+		}
+		last := (vault.VestingEnd + layersPerEpoch - 1) / layersPerEpoch
+		step := uint64(1)
+		if epochs := last - first + 1; epochs > maxVestingPoints {
+			step = (epochs + maxVestingPoints - 1) / maxVestingPoints
+		}
+		point := func(epoch uint64) *types.VestingPoint {
+			epochLayer := epoch * layersPerEpoch
+			return &types.VestingPoint{
+				Epoch:     epoch,
+				Layer:     epochLayer,
+				Time:      times.layer(epochLayer),
+				Timestamp: config.GenesisEpochSeconds + int64(epochLayer)*config.LayerDuration,
+				Vested:    vault.VestedAt(epochLayer),
+			}
+		}
+		for epoch := first; epoch <= last; epoch += step {
+			response.Schedule = append(response.Schedule, point(epoch))
+		}
+		if (last-first)%step != 0 {
+			response.Schedule = append(response.Schedule, point(last))
+		}
+	}
+
+	c.JSON(200, response)
+}
@@ -0,0 +1,298 @@
+package route
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/network"
+	"github.com/swarmbit/spacemesh-state-api/pkg/apperror"
+	"github.com/swarmbit/spacemesh-state-api/price"
+	"github.com/swarmbit/spacemesh-state-api/sink"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+// maxAdminOperations is how many finished operations are kept for the operations list
+const maxAdminOperations = 100
+
+// AdminRoutes runs maintenance actions without restarting the service. Long actions
+// run in background and are listed in the operations with their result. The write
+// store is nil on api instances and the sink is only set once it runs, actions that
+// need them fail with 503.
+type AdminRoutes struct {
+	db            database.ReadStore
+	writeDB       database.WriteStore
+	priceResolver *price.PriceResolver
+	state         *network.NetworkState
+	sink          atomic.Pointer[sink.Sink]
+
+	mu         sync.Mutex
+	operations []*types.AdminOperation
+	nextId     int64
+}
+
+func NewAdminRoutes(db database.ReadStore, writeDB database.WriteStore, priceResolver *price.PriceResolver, state *network.NetworkState) *AdminRoutes {
+	return &AdminRoutes{
+		db:            db,
+		writeDB:       writeDB,
+		priceResolver: priceResolver,
+		state:         state,
+		operations:    make([]*types.AdminOperation, 0),
+	}
+}
+
+// SetSink makes the running sink available to the admin actions.
+func (a *AdminRoutes) SetSink(s *sink.Sink) {
+	if a == nil {
+		return
+	}
+	a.sink.Store(s)
+}
+
+// AddAdminRoutes adds the /admin endpoints behind the admin key.
+func AddAdminRoutes(router *gin.Engine, adminRoutes *AdminRoutes, adminConfig *config.AdminConfig) {
+	admin := router.Group("/admin", adminAuth(adminConfig.ApiKey))
+
+	admin.GET("/checkpoints", adminRoutes.GetCheckpoints)
+	admin.GET("/operations", adminRoutes.GetOperations)
+	admin.POST("/resync", adminRoutes.Resync)
+	admin.POST("/rebuild/:collection", adminRoutes.Rebuild)
+	admin.POST("/upgrade/:collection", adminRoutes.UpgradeDocuments)
+	admin.POST("/cache/flush", adminRoutes.FlushCache)
+	admin.POST("/reindex", adminRoutes.Reindex)
+	admin.GET("/sinks", adminRoutes.GetSinks)
+	admin.POST("/sinks/:sink/pause", func(c *gin.Context) {
+		adminRoutes.SetSinkPaused(c, true)
+	})
+	admin.POST("/sinks/:sink/resume", func(c *gin.Context) {
+		adminRoutes.SetSinkPaused(c, false)
+	})
+
+	log.Println("Added admin routes")
+}
+
+func adminAuth(apiKey string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("x-admin-key")
+		if subtle.ConstantTimeCompare([]byte(key), []byte(apiKey)) != 1 {
+			respondError(c, apperror.New(apperror.Unauthorized, "invalid admin key"))
+			return
+		}
+		c.Next()
+	}
+}
+
+// GetCheckpoints returns the last stream position the sink saved for every consumer,
+// to compare with the jetstream consumers when looking for gaps.
+func (a *AdminRoutes) GetCheckpoints(c *gin.Context) {
+	checkpoints, err := a.db.GetStreamCheckpoints()
+	if err != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch checkpoints", err))
+		return
+	}
+
+	checkpointsResponse := make([]*types.StreamCheckpoint, len(checkpoints))
+	for i, v := range checkpoints {
+		checkpointsResponse[i] = &types.StreamCheckpoint{
+			Consumer:         v.Consumer,
+			Stream:           v.Stream,
+			Sequence:         v.Sequence,
+			Layer:            v.Layer,
+			UpdatedAt:        v.UpdatedAt.Unix(),
+			WriterInstance:   v.WriterInstance,
+			WriterGeneration: v.WriterGeneration,
+		}
+	}
+
+	c.JSON(200, checkpointsResponse)
+}
+
+// GetOperations lists the started admin operations, the newest first.
+func (a *AdminRoutes) GetOperations(c *gin.Context) {
+	a.mu.Lock()
+	operations := make([]types.AdminOperation, len(a.operations))
+	for i, v := range a.operations {
+		operations[len(a.operations)-1-i] = *v
+	}
+	a.mu.Unlock()
+
+	c.JSON(200, operations)
+}
+
+// Resync saves again the data of the layers from and to included, read from the nats
+// streams.
+func (a *AdminRoutes) Resync(c *gin.Context) {
+	from, err := strconv.ParseUint(c.Query("from"), 10, 32)
+	if err != nil {
+		respondError(c, apperror.New(apperror.InvalidInput, "from must be a valid layer"))
+		return
+	}
+	to, err := strconv.ParseUint(c.Query("to"), 10, 32)
+	if err != nil || to < from {
+		respondError(c, apperror.New(apperror.InvalidInput, "to must be a valid layer greater or equal to from"))
+		return
+	}
+	// a resync is bounded to one epoch of layers
+	if to-from+1 > uint64(config.LayersPerEpoch) {
+		respondError(c, apperror.New(apperror.InvalidInput, fmt.Sprintf("at most %d layers can be resynced at once", config.LayersPerEpoch)))
+		return
+	}
+	s := a.sink.Load()
+	if s == nil {
+		a.unavailable(c, "sink")
+		return
+	}
+
+	a.start(c, fmt.Sprintf("resync layers %d to %d", from, to), func() (string, error) {
+		resynced := s.Resync(uint32(from), uint32(to))
+		return fmt.Sprintf("%d messages saved", resynced), nil
+	})
+}
+
+// Rebuild recomputes an aggregate collection from its source collections. The sink of
+// this instance is paused while it runs, the saves it makes to the current collection
+// would be lost when the rebuilt one is swapped in.
+func (a *AdminRoutes) Rebuild(c *gin.Context) {
+	collection := c.Param("collection")
+	if a.writeDB == nil {
+		a.unavailable(c, "write store")
+		return
+	}
+	if !a.writeDB.Capabilities().Rebuilds {
+		respondError(c, database.ErrNotSupported)
+		return
+	}
+	if database.GetRebuild(collection) == nil {
+		respondError(c, database.ErrUnknownAggregate)
+		return
+	}
+
+	a.start(c, "rebuild "+collection, func() (string, error) {
+		if s := a.sink.Load(); s != nil {
+			resume, err := s.PauseAll()
+			defer resume()
+			if err != nil {
+				return "", err
+			}
+		}
+		return "", a.writeDB.RebuildAggregate(collection)
+	})
+}
+
+// UpgradeDocuments stores the current format of the outdated documents of a versioned
+// collection.
+func (a *AdminRoutes) UpgradeDocuments(c *gin.Context) {
+	collection := c.Param("collection")
+	if a.writeDB == nil {
+		a.unavailable(c, "write store")
+		return
+	}
+	if !a.writeDB.Capabilities().DocumentUpgrades {
+		respondError(c, database.ErrNotSupported)
+		return
+	}
+	if database.GetDocumentUpgrade(collection) == nil {
+		respondError(c, database.ErrUnknownUpgrade)
+		return
+	}
+
+	a.start(c, "upgrade "+collection, func() (string, error) {
+		upgraded, err := a.writeDB.UpgradeDocuments(collection)
+		return fmt.Sprintf("upgraded %d documents", upgraded), err
+	})
+}
+
+// FlushCache drops the cached price and reloads the network state served by the api.
+func (a *AdminRoutes) FlushCache(c *gin.Context) {
+	a.priceResolver.Flush()
+	if a.state != nil {
+		a.state.Refresh()
+	}
+	c.JSON(200, gin.H{
+		"status": "flushed",
+	})
+}
+
+// Reindex creates the required indexes that are missing.
+func (a *AdminRoutes) Reindex(c *gin.Context) {
+	if a.writeDB == nil {
+		a.unavailable(c, "write store")
+		return
+	}
+	a.start(c, "reindex", func() (string, error) {
+		return "", a.writeDB.EnsureIndexes()
+	})
+}
+
+// GetSinks reports for every sink if it is paused.
+func (a *AdminRoutes) GetSinks(c *gin.Context) {
+	s := a.sink.Load()
+	if s == nil {
+		a.unavailable(c, "sink")
+		return
+	}
+	c.JSON(200, s.PausedSinks())
+}
+
+func (a *AdminRoutes) SetSinkPaused(c *gin.Context, paused bool) {
+	s := a.sink.Load()
+	if s == nil {
+		a.unavailable(c, "sink")
+		return
+	}
+	if err := s.SetPaused(c.Param("sink"), paused); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(200, s.PausedSinks())
+}
+
+func (a *AdminRoutes) unavailable(c *gin.Context, component string) {
+	respondError(c, apperror.New(apperror.Unavailable, fmt.Sprintf("the %s is not running in this instance", component)))
+}
+
+// start runs action in background and answers with the operation to follow it.
+func (a *AdminRoutes) start(c *gin.Context, name string, action func() (string, error)) {
+	a.mu.Lock()
+	a.nextId++
+	operation := &types.AdminOperation{
+		Id:        a.nextId,
+		Action:    name,
+		StartedAt: time.Now().Unix(),
+	}
+	a.operations = append(a.operations, operation)
+	if len(a.operations) > maxAdminOperations {
+		a.operations = a.operations[len(a.operations)-maxAdminOperations:]
+	}
+	started := *operation
+	a.mu.Unlock()
+
+	log.Printf("Admin operation %d started: %s", operation.Id, name)
+	go func() {
+		result, err := action()
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		operation.FinishedAt = time.Now().Unix()
+		operation.Result = result
+		if err != nil {
+			operation.Error = err.Error()
+			if errors.Is(err, database.ErrFenced) {
+				operation.Error = "this instance is fenced off, run the action on the current sink"
+			}
+			log.Printf("Admin operation %d failed: %v", operation.Id, err)
+			return
+		}
+		log.Printf("Admin operation %d finished: %s", operation.Id, result)
+	}()
+
+	c.JSON(http.StatusAccepted, started)
+}
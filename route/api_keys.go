@@ -0,0 +1,37 @@
+package route
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+
+	"github.com/gin-gonic/gin"
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/pkg/apperror"
+)
+
+// apiKeyOwner is the context key of the owner of a request authenticated by api key
+const apiKeyOwner = "apiKeyOwner"
+
+// apiKeyAuth accepts the api keys that keys returns for the current config and sets the
+// owner of the request, the hash of its key so keys are not stored.
+func apiKeyAuth(reloader *config.Reloader, keys func(configValues *config.Config) []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("x-api-key")
+		valid := false
+		if key != "" {
+			for _, apiKey := range keys(reloader.Current()) {
+				if subtle.ConstantTimeCompare([]byte(key), []byte(apiKey)) == 1 {
+					valid = true
+				}
+			}
+		}
+		if !valid {
+			respondError(c, apperror.New(apperror.Unauthorized, "invalid api key"))
+			return
+		}
+		sum := sha256.Sum256([]byte(key))
+		c.Set(apiKeyOwner, hex.EncodeToString(sum[:]))
+		c.Next()
+	}
+}
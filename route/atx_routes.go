@@ -0,0 +1,126 @@
+package route
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/network"
+	"github.com/swarmbit/spacemesh-state-api/pkg/apperror"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+type AtxRoutes struct {
+	db           database.ReadStore
+	networkUtils *network.NetworkUtils
+}
+
+func NewAtxRoutes(db database.ReadStore, networkUtils *network.NetworkUtils) *AtxRoutes {
+	return &AtxRoutes{
+		db:           db,
+		networkUtils: networkUtils,
+	}
+}
+
+// GetAtx returns an atx with the previous atx of its node. The num units and the poet
+// of the atx are not in the atx stream so they are not stored.
+func (a *AtxRoutes) GetAtx(c *gin.Context) {
+	times, ok := newTimeFormatter(c, a.networkUtils)
+	if !ok {
+		return
+	}
+
+	atx, err := a.db.GetAtx(c.Param("atxId"))
+	if err != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch atx", err))
+		return
+	}
+	if atx.AtxID == "" {
+		respondError(c, apperror.New(apperror.NotFound, "Atx not found"))
+		return
+	}
+
+	previous, err := a.db.GetPreviousAtx(atx.NodeID, atx.PublishEpoch)
+	if err != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch previous atx", err))
+		return
+	}
+
+	atxResponse := toAtxDetail(atx, times)
+	atxResponse.PreviousAtx = previous.AtxID
+	c.JSON(200, atxResponse)
+}
+
+func (a *AtxRoutes) GetSmesherAtxs(c *gin.Context) {
+	offsetStr := c.DefaultQuery("offset", "0")
+	limitStr := c.DefaultQuery("limit", "20")
+	sortStr := c.DefaultQuery("sort", "desc")
+
+	offset, err := strconv.Atoi(offsetStr)
+	if err != nil {
+		respondError(c, apperror.New(apperror.InvalidInput, "offset must be a valid integer"))
+		return
+	}
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil {
+		respondError(c, apperror.New(apperror.InvalidInput, "limit must be a valid integer"))
+		return
+	}
+
+	if offset < 0 || limit < 0 {
+		respondError(c, apperror.New(apperror.InvalidInput, "offset and limit must be greater or equal to 0"))
+		return
+	}
+
+	var sort int8
+	if sortStr == "asc" {
+		sort = 1
+	} else {
+		sort = -1
+	}
+
+	times, ok := newTimeFormatter(c, a.networkUtils)
+	if !ok {
+		return
+	}
+
+	nodeId := c.Param("nodeId")
+	page, ok := pageCursor(c, sort)
+	if !ok {
+		return
+	}
+	if page != nil {
+		atxs, err := a.db.GetNodeAtxsAfter(nodeId, page.after, int64(limit), page.sort)
+		if err != nil {
+			respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch atxs for smesher", err))
+			return
+		}
+		atxsResponse := make([]*types.AtxDetail, len(atxs))
+		for i, v := range atxs {
+			atxsResponse[i] = toAtxDetail(v, times)
+		}
+		if len(atxs) > 0 {
+			last := atxs[len(atxs)-1]
+			page.next(c, limit, len(atxs), int64(last.PublishEpoch), last.AtxID)
+		}
+		c.JSON(200, atxsResponse)
+		return
+	}
+
+	atxs, errAtxs := a.db.GetNodeAtxs(nodeId, int64(offset), int64(limit), sort)
+	count, errCount := a.db.CountNodeAtxs(nodeId)
+
+	if errAtxs != nil || errCount != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch atxs for smesher", errors.Join(errAtxs, errCount)))
+		return
+	}
+
+	atxsResponse := make([]*types.AtxDetail, len(atxs))
+	for i, v := range atxs {
+		atxsResponse[i] = toAtxDetail(v, times)
+	}
+
+	c.Header("total", strconv.FormatInt(count, 10))
+	c.JSON(200, atxsResponse)
+}
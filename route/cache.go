@@ -0,0 +1,171 @@
+package route
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/network"
+)
+
+// defaultCacheControl is served on the routes without a cache control in the config.
+var defaultCacheControl = map[string]string{
+	"/network/circulating-supply": supplyCacheControl,
+	"/network/total-supply":       supplyCacheControl,
+	"/network/parameters":         parametersCacheControl,
+}
+
+// defaultLayerRoutes are polled by dashboards and only change with a new layer. Accounts
+// are not, their balance changes with transactions and rewards saved during a layer.
+var defaultLayerRoutes = []string{"/network/info"}
+
+// cacheHeaders sets the Cache-Control and ETag headers of GET requests and answers 304
+// when the client holds the current version. Layer routes are checked against the last
+// processed layer of the network state before the handler runs, every other route is
+// buffered and its etag is the hash of the body. Exports are streamed and get neither.
+func cacheHeaders(reloader *config.Reloader, state *network.NetworkState) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet {
+			c.Next()
+			return
+		}
+		cacheConfig := reloader.Current().Cache
+		if cacheConfig == nil {
+			cacheConfig = &config.CacheConfig{}
+		}
+		route := versionPath(c.FullPath())
+		if cacheControl, ok := cacheConfig.CacheControl[route]; ok {
+			c.Header("Cache-Control", cacheControl)
+		} else if cacheControl, ok := defaultCacheControl[route]; ok {
+			c.Header("Cache-Control", cacheControl)
+		}
+		if cacheConfig.DisableEtags || exportFormat(c) != "" {
+			c.Next()
+			return
+		}
+
+		layerRoutes := cacheConfig.LayerRoutes
+		if len(layerRoutes) == 0 {
+			layerRoutes = defaultLayerRoutes
+		}
+		if slices.Contains(layerRoutes, route) {
+			layerEtag(c, state)
+			return
+		}
+		bodyEtag(c)
+	}
+}
+
+// layerEtag answers 304 when the client saw the last processed layer, nothing is
+// checked until the network state is loaded.
+func layerEtag(c *gin.Context, state *network.NetworkState) {
+	view := requestState(c, state)
+	if view.Ready() != nil {
+		c.Next()
+		return
+	}
+	layer := view.GetInfo().Layer
+	etag := `W/"layer-` + strconv.FormatUint(layer, 10) + `"`
+	lastModified := time.Unix(config.GenesisEpochSeconds+int64(layer)*config.LayerDuration, 0).UTC()
+	c.Header("ETag", etag)
+	c.Header("Last-Modified", lastModified.Format(http.TimeFormat))
+
+	if ifNoneMatch := c.GetHeader("If-None-Match"); ifNoneMatch != "" {
+		if etagMatch(ifNoneMatch, etag) {
+			c.AbortWithStatus(http.StatusNotModified)
+			return
+		}
+	} else if since, err := http.ParseTime(c.GetHeader("If-Modified-Since")); err == nil && !lastModified.After(since) {
+		c.AbortWithStatus(http.StatusNotModified)
+		return
+	}
+	c.Next()
+}
+
+// bodyEtag buffers the response and serves it with the hash of the body as etag, only
+// successful responses get one.
+func bodyEtag(c *gin.Context) {
+	writer := &bufferedWriter{ResponseWriter: c.Writer, status: c.Writer.Status()}
+	c.Writer = writer
+	c.Next()
+	c.Writer = writer.ResponseWriter
+	// errors and unknown routes are answered once the handlers return
+	if !writer.written {
+		return
+	}
+
+	if writer.status == http.StatusOK {
+		hash := sha256.Sum256(writer.body.Bytes())
+		etag := `"` + hex.EncodeToString(hash[:16]) + `"`
+		c.Header("ETag", etag)
+		if etagMatch(c.GetHeader("If-None-Match"), etag) {
+			c.Writer.WriteHeader(http.StatusNotModified)
+			c.Writer.WriteHeaderNow()
+			return
+		}
+	}
+	c.Writer.WriteHeader(writer.status)
+	c.Writer.WriteHeaderNow()
+	c.Writer.Write(writer.body.Bytes())
+}
+
+// etagMatch reports if etag is in an If-None-Match header, compared weakly as the header
+// asks for.
+func etagMatch(ifNoneMatch string, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, value := range strings.Split(ifNoneMatch, ",") {
+		value = strings.TrimSpace(value)
+		if value == "*" || strings.TrimPrefix(value, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// bufferedWriter holds the status and body of a response until its etag is known.
+type bufferedWriter struct {
+	gin.ResponseWriter
+	body    bytes.Buffer
+	status  int
+	written bool
+}
+
+func (w *bufferedWriter) WriteHeader(code int) {
+	w.status = code
+	w.written = true
+}
+
+func (w *bufferedWriter) WriteHeaderNow() {
+	w.written = true
+}
+
+func (w *bufferedWriter) Write(data []byte) (int, error) {
+	w.written = true
+	return w.body.Write(data)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	w.written = true
+	return w.body.WriteString(s)
+}
+
+func (w *bufferedWriter) Status() int {
+	return w.status
+}
+
+func (w *bufferedWriter) Size() int {
+	return w.body.Len()
+}
+
+func (w *bufferedWriter) Written() bool {
+	return w.written
+}
+
+func (w *bufferedWriter) Flush() {}
@@ -0,0 +1,74 @@
+package route
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/swarmbit/spacemesh-state-api/pkg/apperror"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+// maxDigestDays bounds the days a single request of daily digests returns.
+const maxDigestDays = 366
+
+// GetCoinbaseDaily returns the daily reward digests of the coinbase, computed by the
+// digest aggregator. from and to are unix times, the days they fall in are both
+// included, the last 30 days by default. With a currency the digests are also valued
+// in it at the current exchange rate to USD.
+func (a *AccountRoutes) GetCoinbaseDaily(c *gin.Context) {
+	now := time.Now().Unix()
+	from, err := strconv.ParseInt(c.DefaultQuery("from", strconv.FormatInt(now-30*24*60*60, 10)), 10, 64)
+	if err != nil {
+		respondError(c, apperror.New(apperror.InvalidInput, "from must be a valid integer"))
+		return
+	}
+	to, err := strconv.ParseInt(c.DefaultQuery("to", strconv.FormatInt(now, 10)), 10, 64)
+	if err != nil {
+		respondError(c, apperror.New(apperror.InvalidInput, "to must be a valid integer"))
+		return
+	}
+	fromDay := from / (24 * 60 * 60) * (24 * 60 * 60)
+	toDay := to / (24 * 60 * 60) * (24 * 60 * 60)
+	if fromDay > toDay {
+		respondError(c, apperror.New(apperror.InvalidInput, "from must be lower or equal to to"))
+		return
+	}
+	if (toDay-fromDay)/(24*60*60) >= maxDigestDays {
+		respondError(c, apperror.New(apperror.InvalidInput, "from and to must be at most 366 days apart"))
+		return
+	}
+	currency, ok := fiatCurrency(c, a.priceResolver)
+	if !ok {
+		return
+	}
+
+	digests, err := a.db.GetRewardDigests(c.Param("address"), fromDay, toDay)
+	if err != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch daily digests", err))
+		return
+	}
+
+	// the USD value is converted with the rate between the current prices
+	rate := -1.0
+	if currency != "" {
+		usdPrice := a.priceResolver.GetPrice()
+		currencyPrice := a.priceResolver.GetPriceIn(currency)
+		if usdPrice > 0 && currencyPrice >= 0 {
+			rate = currencyPrice / usdPrice
+		}
+	}
+	response := make([]*types.CoinbaseDigest, len(digests))
+	for i, v := range digests {
+		response[i] = v.Digest()
+		if currency != "" {
+			response[i].Currency = currency
+			response[i].FiatValue = -1
+			if rate >= 0 && v.USDValue >= 0 {
+				response[i].FiatValue = int64(rate * float64(v.USDValue))
+			}
+		}
+	}
+
+	c.JSON(200, response)
+}
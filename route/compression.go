@@ -0,0 +1,214 @@
+package route
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/swarmbit/spacemesh-state-api/config"
+)
+
+const defaultCompressionMinSize = 1024
+
+var defaultCompressionEncodings = []string{"zstd", "gzip"}
+
+// encoder is the compressing writer of an encoding, encoders are pooled and reset to the
+// response they compress.
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// Compression compresses the responses of clients that accept one of the configured
+// encodings. Bodies are held until they reach the minimum size, smaller ones are sent as
+// they are. Streamed exports are compressed from their first flush.
+func Compression(compressionConfig *config.CompressionConfig) gin.HandlerFunc {
+	if compressionConfig == nil || !compressionConfig.Enabled {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+	encodings := compressionConfig.Encodings
+	if len(encodings) == 0 {
+		encodings = defaultCompressionEncodings
+	}
+	minSize := compressionConfig.MinSize
+	if minSize == 0 {
+		minSize = defaultCompressionMinSize
+	}
+	level := compressionConfig.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	pools := map[string]*sync.Pool{
+		"gzip": {New: func() interface{} {
+			writer, _ := gzip.NewWriterLevel(io.Discard, level)
+			return writer
+		}},
+		"zstd": {New: func() interface{} {
+			writer, _ := zstd.NewWriter(io.Discard, zstd.WithEncoderConcurrency(1))
+			return writer
+		}},
+	}
+
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodHead {
+			c.Next()
+			return
+		}
+		encoding := acceptedEncoding(c.GetHeader("Accept-Encoding"), encodings)
+		c.Writer.Header().Add("Vary", "Accept-Encoding")
+		if encoding == "" {
+			c.Next()
+			return
+		}
+		writer := &compressWriter{
+			ResponseWriter: c.Writer,
+			encoding:       encoding,
+			pool:           pools[encoding],
+			minSize:        minSize,
+			status:         c.Writer.Status(),
+		}
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+		writer.finish()
+	}
+}
+
+// acceptedEncoding returns the first of encodings the Accept-Encoding header accepts,
+// empty when none is.
+func acceptedEncoding(acceptEncoding string, encodings []string) string {
+	accepted := make(map[string]bool)
+	for _, value := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(value), ";")
+		quality := strings.ReplaceAll(params, " ", "")
+		accepted[strings.ToLower(name)] = quality != "q=0" && quality != "q=0.0" && quality != "q=0.00" && quality != "q=0.000"
+	}
+	for _, encoding := range encodings {
+		if ok, exists := accepted[encoding]; ok || (!exists && accepted["*"]) {
+			return encoding
+		}
+	}
+	return ""
+}
+
+// compressWriter holds the status and the start of the body until it knows if the
+// response is compressed.
+type compressWriter struct {
+	gin.ResponseWriter
+	encoding string
+	pool     *sync.Pool
+	minSize  int
+	status   int
+	written  bool
+	started  bool
+	pending  []byte
+	encoder  encoder
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if !w.started {
+		w.status = code
+	}
+	w.written = true
+}
+
+func (w *compressWriter) WriteHeaderNow() {
+	w.written = true
+}
+
+func (w *compressWriter) Write(data []byte) (int, error) {
+	w.written = true
+	if !w.started {
+		w.pending = append(w.pending, data...)
+		if len(w.pending) < w.minSize {
+			return len(data), nil
+		}
+		if err := w.start(true); err != nil {
+			return 0, err
+		}
+		return len(data), nil
+	}
+	if w.encoder != nil {
+		return w.encoder.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *compressWriter) Status() int {
+	return w.status
+}
+
+func (w *compressWriter) Written() bool {
+	return w.written
+}
+
+// Flush starts compressing a streamed body whatever its size.
+func (w *compressWriter) Flush() {
+	if !w.started {
+		w.start(true)
+	}
+	if w.encoder != nil {
+		w.encoder.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// start writes the headers, compressed when asked for and the response can be, and the
+// pending body.
+func (w *compressWriter) start(compress bool) error {
+	w.started = true
+	header := w.Header()
+	if compress && w.status >= http.StatusOK && w.status != http.StatusNoContent && w.status != http.StatusNotModified &&
+		header.Get("Content-Encoding") == "" {
+		header.Set("Content-Encoding", w.encoding)
+		header.Del("Content-Length")
+		// the compressed body is another representation of a strong etag
+		if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			header.Set("ETag", "W/"+etag)
+		}
+		w.encoder = w.pool.Get().(encoder)
+		w.encoder.Reset(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	w.ResponseWriter.WriteHeaderNow()
+	pending := w.pending
+	w.pending = nil
+	if len(pending) == 0 {
+		return nil
+	}
+	var err error
+	if w.encoder != nil {
+		_, err = w.encoder.Write(pending)
+	} else {
+		_, err = w.ResponseWriter.Write(pending)
+	}
+	return err
+}
+
+// finish sends what is still held and closes the encoder. Responses never written are
+// left to the handlers after this one, nothing is sent for them.
+func (w *compressWriter) finish() {
+	if !w.started {
+		if !w.written {
+			return
+		}
+		w.start(len(w.pending) >= w.minSize)
+	}
+	if w.encoder != nil {
+		w.encoder.Close()
+		w.encoder.Reset(io.Discard)
+		w.pool.Put(w.encoder)
+		w.encoder = nil
+	}
+}
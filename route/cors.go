@@ -0,0 +1,65 @@
+package route
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/swarmbit/spacemesh-state-api/config"
+)
+
+const (
+	defaultCorsMethods = "POST, OPTIONS, GET, PUT, DELETE"
+	defaultCorsHeaders = "*"
+)
+
+// Cors sets the CORS headers browsers need to call the api from an explorer and answers
+// preflights with 204. Origins that are not allowed get no CORS headers, the browser
+// blocks them. Without a config every origin is allowed.
+func Cors(corsConfig *config.CorsConfig) gin.HandlerFunc {
+	if corsConfig == nil {
+		corsConfig = &config.CorsConfig{AllowCredentials: true}
+	}
+	anyOrigin := len(corsConfig.AllowedOrigins) == 0 || slices.Contains(corsConfig.AllowedOrigins, "*")
+	methods := defaultCorsMethods
+	if len(corsConfig.AllowedMethods) > 0 {
+		methods = strings.Join(corsConfig.AllowedMethods, ", ")
+	}
+	headers := defaultCorsHeaders
+	if len(corsConfig.AllowedHeaders) > 0 {
+		headers = strings.Join(corsConfig.AllowedHeaders, ", ")
+	}
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		allowed := true
+		if anyOrigin {
+			c.Header("Access-Control-Allow-Origin", "*")
+		} else {
+			c.Writer.Header().Add("Vary", "Origin")
+			allowed = slices.Contains(corsConfig.AllowedOrigins, origin)
+			if allowed {
+				c.Header("Access-Control-Allow-Origin", origin)
+			}
+		}
+		if allowed {
+			if corsConfig.AllowCredentials {
+				c.Header("Access-Control-Allow-Credentials", "true")
+			}
+			c.Header("Access-Control-Allow-Headers", headers)
+			c.Header("Access-Control-Allow-Methods", methods)
+			c.Header("Access-Control-Expose-Headers", "total, next-cursor, Content-Disposition, state-version, state-layer, state-created-at, verified-layer, Content-Profile")
+			if corsConfig.MaxAge > 0 {
+				c.Header("Access-Control-Max-Age", strconv.Itoa(corsConfig.MaxAge))
+			}
+		}
+
+		if c.Request.Method == http.MethodOptions {
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		c.Next()
+	}
+}
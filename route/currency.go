@@ -0,0 +1,31 @@
+package route
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/swarmbit/spacemesh-state-api/pkg/apperror"
+	"github.com/swarmbit/spacemesh-state-api/price"
+)
+
+// fiatCurrency reads the optional ?currency= parameter, an empty currency means
+// only the USD values are returned.
+func fiatCurrency(c *gin.Context, priceResolver *price.PriceResolver) (string, bool) {
+	currency := strings.ToUpper(c.Query("currency"))
+	if currency == "" {
+		return "", true
+	}
+	if !priceResolver.IsSupportedCurrency(currency) {
+		respondError(c, apperror.New(apperror.InvalidInput, "currency is not supported"))
+		return "", false
+	}
+	return currency, true
+}
+
+// fiatValue converts a smidge amount with the given price, -1 when the price is unknown.
+func fiatValue(priceValue float64, smidge uint64) int64 {
+	if priceValue < 0 {
+		return -1
+	}
+	return int64(priceValue * float64(smidge))
+}
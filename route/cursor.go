@@ -0,0 +1,45 @@
+package route
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/pkg/apperror"
+)
+
+// listCursor is the page asked for with the cursor query parameter, after is nil on the
+// first page. The sort of a cursor replaces the sort parameter so every page of a list
+// keeps the order it started with.
+type listCursor struct {
+	after *database.Cursor
+	sort  int8
+}
+
+// pageCursor reads the cursor of a list, it is nil when the list is paged by offset. An
+// empty cursor asks for the first page. ok is false when the cursor was not valid and
+// the error was served.
+func pageCursor(c *gin.Context, sort int8) (*listCursor, bool) {
+	value, exists := c.GetQuery("cursor")
+	if !exists {
+		return nil, true
+	}
+	page := &listCursor{sort: sort}
+	if value == "" {
+		return page, true
+	}
+	after, err := database.DecodeCursor(value)
+	if err != nil {
+		respondError(c, apperror.New(apperror.InvalidInput, err.Error()))
+		return nil, false
+	}
+	page.after = after
+	page.sort = after.Sort
+	return page, true
+}
+
+// next serves the cursor of the last item in the next-cursor header when the page is
+// full, there is none on the last page.
+func (p *listCursor) next(c *gin.Context, limit int, count int, key int64, id string) {
+	if limit > 0 && count == limit {
+		c.Header("next-cursor", database.EncodeCursor(&database.Cursor{Key: key, ID: id, Sort: p.sort}))
+	}
+}
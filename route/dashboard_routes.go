@@ -0,0 +1,177 @@
+package route
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/network"
+	"github.com/swarmbit/spacemesh-state-api/pkg/address"
+	"github.com/swarmbit/spacemesh-state-api/pkg/apperror"
+	"github.com/swarmbit/spacemesh-state-api/price"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+const (
+	// maxDashboardItems is how many node ids and coinbases a dashboard shows together
+	maxDashboardItems = 50
+	// dashboardWorkers bound the nodes and coinbases read at once for a request
+	dashboardWorkers = 8
+)
+
+// DashboardRoutes serve the state of a set of nodes and coinbases with the network
+// summary in one call, for node operator dashboards.
+type DashboardRoutes struct {
+	networkUtils  *network.NetworkUtils
+	state         *network.NetworkState
+	priceResolver *price.PriceResolver
+	network       *NetworkRoutes
+	summaries     *summaries
+}
+
+func NewDashboardRoutes(db database.ReadStore, networkUtils *network.NetworkUtils, state *network.NetworkState, priceResolver *price.PriceResolver, networkRoutes *NetworkRoutes) *DashboardRoutes {
+	return &DashboardRoutes{
+		networkUtils:  networkUtils,
+		state:         state,
+		priceResolver: priceResolver,
+		network:       networkRoutes,
+		summaries:     newSummaries(db, networkUtils, state),
+	}
+}
+
+// listParam is the comma separated values of a query parameter, empty values dropped.
+func listParam(c *gin.Context, key string) []string {
+	values := make([]string, 0)
+	for _, v := range strings.Split(c.Query(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// uniqueValues drops the repeated values, keeping the order of the first ones.
+func uniqueValues(values []string) []string {
+	unique := make([]string, 0, len(values))
+	seen := make(map[string]bool, len(values))
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
+
+// GetDashboard returns for every node of nodeIds its last and pending atx, effective
+// units, eligibility in the current and next epoch and latest rewards, for every
+// address of coinbases its balance, pending atxs, next epoch eligibility and latest
+// rewards, and the network info. The nodes and coinbases are read concurrently.
+func (d *DashboardRoutes) GetDashboard(c *gin.Context) {
+	view := requestState(c, d.state)
+	if err := view.Ready(); err != nil {
+		respondError(c, err)
+		return
+	}
+	nodeIds, err := address.NormalizeNodeIds(listParam(c, "nodeIds"))
+	if err != nil {
+		respondError(c, apperror.New(apperror.InvalidInput, err.Error()))
+		return
+	}
+	coinbases, err := address.NormalizeAddresses(listParam(c, "coinbases"))
+	if err != nil {
+		respondError(c, apperror.New(apperror.InvalidInput, err.Error()))
+		return
+	}
+	nodeIds = uniqueValues(nodeIds)
+	coinbases = uniqueValues(coinbases)
+	if len(nodeIds) == 0 && len(coinbases) == 0 {
+		respondError(c, apperror.New(apperror.InvalidInput, "nodeIds or coinbases are required"))
+		return
+	}
+	if len(nodeIds)+len(coinbases) > maxDashboardItems {
+		respondError(c, apperror.New(apperror.InvalidInput, "dashboards are limited to "+strconv.Itoa(maxDashboardItems)+" node ids and coinbases"))
+		return
+	}
+	recentRewards, err := strconv.ParseInt(c.DefaultQuery("rewards", "5"), 10, 64)
+	if err != nil || recentRewards < 0 || recentRewards > 50 {
+		respondError(c, apperror.New(apperror.InvalidInput, "rewards must be an integer between 0 and 50"))
+		return
+	}
+	times, ok := newTimeFormatter(c, d.networkUtils)
+	if !ok {
+		return
+	}
+	currency, ok := fiatCurrency(c, d.priceResolver)
+	if !ok {
+		return
+	}
+
+	info := d.network.info(view, times, currency)
+	dashboard := &types.Dashboard{
+		Network:   info,
+		Nodes:     make([]*types.DashboardNode, len(nodeIds)),
+		Coinbases: make([]*types.WatchlistAccount, len(coinbases)),
+	}
+	priceValue := d.priceResolver.GetPrice()
+	errs := make([]error, len(nodeIds)+len(coinbases))
+
+	var wg sync.WaitGroup
+	workers := make(chan struct{}, dashboardWorkers)
+	run := func(work func()) {
+		wg.Add(1)
+		workers <- struct{}{}
+		go func() {
+			defer func() {
+				<-workers
+				wg.Done()
+			}()
+			work()
+		}()
+	}
+	for i, nodeId := range nodeIds {
+		run(func() {
+			dashboard.Nodes[i], errs[i] = d.node(view, nodeId, info.Epoch, recentRewards, times)
+		})
+	}
+	for i, coinbase := range coinbases {
+		run(func() {
+			dashboard.Coinbases[i], errs[len(nodeIds)+i] = d.summaries.account(view, coinbase, info.Epoch, recentRewards, priceValue, times)
+		})
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch dashboard", err))
+		return
+	}
+	c.JSON(200, dashboard)
+}
+
+func (d *DashboardRoutes) node(view *network.View, nodeId string, epoch uint32, recentRewards int64, times *timeFormatter) (*types.DashboardNode, error) {
+	summary, atx, err := d.summaries.node(view, nodeId, epoch, recentRewards, times)
+	if err != nil {
+		return nil, err
+	}
+	eligibility, err := d.summaries.nodes.getEpochEligibility(view, nodeId, epoch)
+	if err != nil {
+		return nil, err
+	}
+	eligibility.StartTime = times.epoch(uint64(eligibility.Epoch))
+
+	node := &types.DashboardNode{
+		NodeId:        nodeId,
+		PendingAtx:    summary.PendingAtx,
+		Eligibility:   eligibility,
+		NextEpoch:     summary.NextEpoch,
+		RecentRewards: summary.RecentRewards,
+	}
+	if atx.AtxID != "" {
+		node.LastAtx = toAtx(atx, times)
+		node.EffectiveNumUnits = atx.EffectiveNumUnits
+	}
+	return node, nil
+}
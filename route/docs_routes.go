@@ -0,0 +1,321 @@
+package route
+
+import (
+	"bytes"
+	"html/template"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/swarmbit/spacemesh-state-api/config"
+)
+
+// docsPrefixes are the paths left out of the OpenAPI doc.
+var docsPrefixes = []string{"/docs", "/metrics"}
+
+// securedPrefixes are the paths behind a key and the header of the key.
+var securedPrefixes = map[string]string{
+	"/admin":     "x-admin-key",
+	"/pool":      "x-api-key",
+	"/watchlist": "x-api-key",
+	"/webhooks":  "x-api-key",
+}
+
+// pathParams describes the path parameters used across the routes, the others are
+// strings.
+var pathParams = map[string]map[string]interface{}{
+	"accountAddress": {"type": "string", "description": "bech32 address of the account"},
+	"address":        {"type": "string", "description": "bech32 address of the coinbase"},
+	"nodeId":         {"type": "string", "description": "hex node id, with or without 0x"},
+	"epoch":          {"type": "integer", "minimum": 0},
+	"layer":          {"type": "integer", "minimum": 0},
+}
+
+// DocsRoutes serve the OpenAPI doc of the routes of the router, generated on the first
+// request once every route is added, and the Swagger UI and Redoc explorers of it.
+type DocsRoutes struct {
+	router     *gin.Engine
+	docsConfig *config.DocsConfig
+	once       sync.Once
+	docs       map[string]map[string]interface{}
+	swaggerUI  []byte
+	redoc      []byte
+}
+
+func NewDocsRoutes(router *gin.Engine, docsConfig *config.DocsConfig) *DocsRoutes {
+	return &DocsRoutes{
+		router:     router,
+		docsConfig: docsConfig,
+		swaggerUI:  renderDocsPage(swaggerUIPage, docsConfig),
+		redoc:      renderDocsPage(redocPage, docsConfig),
+	}
+}
+
+// AddDocsRoutes adds /docs with Swagger UI, /docs/redoc and the doc of each version
+// under /docs/openapi.
+func AddDocsRoutes(router *gin.Engine, docsRoutes *DocsRoutes) {
+	router.GET("/docs", docsRoutes.GetSwaggerUI)
+	router.GET("/docs/redoc", docsRoutes.GetRedoc)
+	router.GET("/docs/openapi/:version", docsRoutes.GetOpenAPI)
+
+	log.Println("Added docs routes")
+}
+
+// GetOpenAPI returns the doc of a version, v1.json or v2.json.
+func (d *DocsRoutes) GetOpenAPI(c *gin.Context) {
+	d.once.Do(func() {
+		d.docs = make(map[string]map[string]interface{})
+		for _, version := range config.ApiVersions {
+			d.docs[version] = openAPIDoc(d.router.Routes(), version, d.docsConfig.ServerUrl)
+		}
+	})
+	doc, exists := d.docs[strings.TrimSuffix(c.Param("version"), ".json")]
+	if !exists {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	c.JSON(200, doc)
+}
+
+func (d *DocsRoutes) GetSwaggerUI(c *gin.Context) {
+	c.Data(200, "text/html; charset=utf-8", d.swaggerUI)
+}
+
+func (d *DocsRoutes) GetRedoc(c *gin.Context) {
+	c.Data(200, "text/html; charset=utf-8", d.redoc)
+}
+
+// openAPIDoc describes the routes of version. v1 are the routes without a version
+// prefix, the /v1 ones are the same, and the other versions their prefixed routes with
+// the prefix in the server url. The routes outside of the versions are in every doc.
+func openAPIDoc(routes gin.RoutesInfo, version string, serverUrl string) map[string]interface{} {
+	versionPrefix := "/" + version
+	paths := make(map[string]map[string]interface{})
+	for _, route := range routes {
+		path := route.Path
+		if slices.ContainsFunc(docsPrefixes, func(prefix string) bool { return strings.HasPrefix(path, prefix) }) {
+			continue
+		}
+		versioned := slices.ContainsFunc(config.ApiVersions, func(v string) bool {
+			return path == "/"+v || strings.HasPrefix(path, "/"+v+"/")
+		})
+		if versioned {
+			if version == apiV1 || !strings.HasPrefix(path, versionPrefix+"/") {
+				continue
+			}
+			path = strings.TrimPrefix(path, versionPrefix)
+		} else if version != apiV1 && isApiRoute(routes, path) {
+			continue
+		}
+
+		openAPIPath, parameters := openAPIPathParams(path)
+		if _, exists := paths[openAPIPath]; !exists {
+			paths[openAPIPath] = make(map[string]interface{})
+		}
+		paths[openAPIPath][strings.ToLower(route.Method)] = openAPIOperation(route.Method, path, parameters)
+	}
+
+	server := strings.TrimRight(serverUrl, "/")
+	if version != apiV1 {
+		server += versionPrefix
+	} else if server == "" {
+		server = "/"
+	}
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "Spacemesh State API",
+			"version":     version,
+			"description": "Amounts are in smidge, add ?units=smh for decimal SMH strings. Times are ISO8601 in UTC unless ?tz= sets an IANA time zone.",
+		},
+		"servers": []interface{}{map[string]interface{}{"url": server}},
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"securitySchemes": map[string]interface{}{
+				"x-api-key":   map[string]interface{}{"type": "apiKey", "in": "header", "name": "x-api-key"},
+				"x-admin-key": map[string]interface{}{"type": "apiKey", "in": "header", "name": "x-admin-key"},
+			},
+			"schemas": map[string]interface{}{
+				"Error": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"status": map[string]interface{}{"type": "string"},
+						"code":   map[string]interface{}{"type": "string"},
+						"error":  map[string]interface{}{"type": "string"},
+					},
+				},
+			},
+		},
+	}
+}
+
+// isApiRoute reports if an unprefixed path is a route of the versions, the ones served
+// under /v1 too.
+func isApiRoute(routes gin.RoutesInfo, path string) bool {
+	return slices.ContainsFunc(routes, func(route gin.RouteInfo) bool {
+		return route.Path == "/"+apiV1+path
+	})
+}
+
+// openAPIPathParams converts the gin parameters of path to the OpenAPI ones.
+func openAPIPathParams(path string) (string, []interface{}) {
+	segments := strings.Split(path, "/")
+	parameters := make([]interface{}, 0)
+	for i, segment := range segments {
+		if !strings.HasPrefix(segment, ":") && !strings.HasPrefix(segment, "*") {
+			continue
+		}
+		name := segment[1:]
+		segments[i] = "{" + name + "}"
+		schema, known := pathParams[name]
+		if !known {
+			schema = map[string]interface{}{"type": "string"}
+		}
+		parameter := map[string]interface{}{"name": name, "in": "path", "required": true, "schema": schema}
+		if description, ok := schema["description"]; ok {
+			parameter["description"] = description
+		}
+		parameters = append(parameters, parameter)
+	}
+	return strings.Join(segments, "/"), parameters
+}
+
+func openAPIOperation(method string, path string, parameters []interface{}) map[string]interface{} {
+	tag := strings.Split(strings.TrimPrefix(path, "/"), "/")[0]
+	if method == http.MethodGet {
+		parameters = append(parameters, map[string]interface{}{
+			"name":        "units",
+			"in":          "query",
+			"description": "smh adds the amounts as decimal SMH strings",
+			"schema":      map[string]interface{}{"type": "string", "enum": []string{"smidge", "smh"}},
+		})
+	}
+	errorResponse := map[string]interface{}{
+		"description": "error",
+		"content": map[string]interface{}{
+			"application/json": map[string]interface{}{"schema": map[string]interface{}{"$ref": "#/components/schemas/Error"}},
+		},
+	}
+	operation := map[string]interface{}{
+		"tags":        []string{tag},
+		"operationId": strings.ToLower(method) + operationName(path),
+		"parameters":  parameters,
+		"responses": map[string]interface{}{
+			"200":     map[string]interface{}{"description": "success"},
+			"default": errorResponse,
+		},
+	}
+	if method == http.MethodPost || method == http.MethodPut {
+		operation["requestBody"] = map[string]interface{}{
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": map[string]interface{}{"type": "object"}},
+			},
+		}
+	}
+	for prefix, header := range securedPrefixes {
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			operation["security"] = []interface{}{map[string]interface{}{header: []string{}}}
+		}
+	}
+	return operation
+}
+
+// operationName is the path in camel case, /account/:accountAddress/rewards is
+// AccountByAccountAddressRewards.
+func operationName(path string) string {
+	var name strings.Builder
+	for _, segment := range strings.Split(path, "/") {
+		if segment == "" {
+			continue
+		}
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			name.WriteString("By")
+			segment = segment[1:]
+		}
+		for _, word := range strings.FieldsFunc(segment, func(r rune) bool { return r == '-' || r == '_' || r == '.' }) {
+			name.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		}
+	}
+	return name.String()
+}
+
+// The versions of the swagger-ui-dist and redoc packages the docs pages load, pinned so
+// a release of either can not change what the pages run.
+const (
+	swaggerUIVersion = "5.17.14"
+	redocVersion     = "2.1.5"
+)
+
+// docsAsset is a file a docs page loads, with its integrity hash when configured.
+type docsAsset struct {
+	Url       string
+	Integrity string
+}
+
+// docsAssets are the files of config.DocsAssets, from unpkg or the assets url.
+func docsAssets(docsConfig *config.DocsConfig) map[string]docsAsset {
+	swaggerUI := "https://unpkg.com/swagger-ui-dist@" + swaggerUIVersion
+	redoc := "https://unpkg.com/redoc@" + redocVersion
+	if docsConfig.AssetsUrl != "" {
+		assetsUrl := strings.TrimRight(docsConfig.AssetsUrl, "/")
+		swaggerUI = assetsUrl + "/swagger-ui-dist"
+		redoc = assetsUrl + "/redoc"
+	}
+	return map[string]docsAsset{
+		"swaggerUICss":    {Url: swaggerUI + "/swagger-ui.css", Integrity: docsConfig.Integrity["swagger-ui.css"]},
+		"swaggerUIBundle": {Url: swaggerUI + "/swagger-ui-bundle.js", Integrity: docsConfig.Integrity["swagger-ui-bundle.js"]},
+		"swaggerUIPreset": {Url: swaggerUI + "/swagger-ui-standalone-preset.js", Integrity: docsConfig.Integrity["swagger-ui-standalone-preset.js"]},
+		"redoc":           {Url: redoc + "/bundles/redoc.standalone.js", Integrity: docsConfig.Integrity["redoc.standalone.js"]},
+	}
+}
+
+func renderDocsPage(page *template.Template, docsConfig *config.DocsConfig) []byte {
+	var out bytes.Buffer
+	if err := page.Execute(&out, docsAssets(docsConfig)); err != nil {
+		panic(err)
+	}
+	return out.Bytes()
+}
+
+var swaggerUIPage = template.Must(template.New("swaggerUI").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Spacemesh State API</title>
+  <link rel="stylesheet" href="{{.swaggerUICss.Url}}"{{with .swaggerUICss.Integrity}} integrity="{{.}}"{{end}} crossorigin="anonymous">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="{{.swaggerUIBundle.Url}}"{{with .swaggerUIBundle.Integrity}} integrity="{{.}}"{{end}} crossorigin="anonymous"></script>
+  <script src="{{.swaggerUIPreset.Url}}"{{with .swaggerUIPreset.Integrity}} integrity="{{.}}"{{end}} crossorigin="anonymous"></script>
+  <script>
+    window.ui = SwaggerUIBundle({
+      urls: [
+        {url: "docs/openapi/v1.json", name: "v1"},
+        {url: "docs/openapi/v2.json", name: "v2"}
+      ],
+      dom_id: "#swagger-ui",
+      presets: [SwaggerUIBundle.presets.apis, SwaggerUIStandalonePreset],
+      layout: "StandaloneLayout",
+      tryItOutEnabled: true
+    });
+  </script>
+</body>
+</html>
+`))
+
+var redocPage = template.Must(template.New("redoc").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Spacemesh State API</title>
+</head>
+<body>
+  <redoc spec-url="openapi/v1.json"></redoc>
+  <script src="{{.redoc.Url}}"{{with .redoc.Integrity}} integrity="{{.}}"{{end}} crossorigin="anonymous"></script>
+</body>
+</html>
+`))
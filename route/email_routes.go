@@ -0,0 +1,267 @@
+package route
+
+import (
+	"bytes"
+	"errors"
+	"html/template"
+	"log"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/email"
+	"github.com/swarmbit/spacemesh-state-api/pkg/apperror"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+// EmailRoutes manage the email addresses getting the digest of the watchlist of the api
+// key of the caller, and the confirmation and unsubscribe links sent to them. They are
+// served by the instances that open the write store.
+type EmailRoutes struct {
+	db       database.ReadStore
+	writeDB  database.WriteStore
+	reloader *config.Reloader
+}
+
+func NewEmailRoutes(db database.ReadStore, writeDB database.WriteStore, reloader *config.Reloader) *EmailRoutes {
+	return &EmailRoutes{
+		db:       db,
+		writeDB:  writeDB,
+		reloader: reloader,
+	}
+}
+
+// AddEmailRoutes adds the /watchlist/emails endpoints behind the watchlist api keys and
+// the public confirmation and unsubscribe links. Opening a link only shows a page whose
+// form posts to it, so mail scanners and prefetchers following the links change nothing.
+func AddEmailRoutes(router *gin.Engine, emailRoutes *EmailRoutes, reloader *config.Reloader) {
+	emails := router.Group("/watchlist/emails", apiKeyAuth(reloader, func(configValues *config.Config) []string {
+		if configValues.Watchlists == nil {
+			return nil
+		}
+		return configValues.Watchlists.ApiKeys
+	}))
+
+	emails.POST("", emailRoutes.Subscribe)
+	emails.GET("", emailRoutes.GetSubscriptions)
+	emails.DELETE("/:subscriptionId", emailRoutes.DeleteSubscription)
+
+	router.GET("/emails/confirm", emailRoutes.ConfirmPage)
+	router.POST("/emails/confirm", emailRoutes.Confirm)
+	// mail clients unsubscribe in one click with a POST to the link, RFC 8058
+	router.GET("/emails/unsubscribe", emailRoutes.UnsubscribePage)
+	router.POST("/emails/unsubscribe", emailRoutes.Unsubscribe)
+
+	log.Println("Added email routes")
+}
+
+func toEmailSubscription(s *types.EmailSubscriptionDoc) *types.EmailSubscription {
+	return &types.EmailSubscription{
+		ID:        s.ID,
+		Email:     s.Email,
+		Frequency: s.Frequency,
+		CreatedAt: s.CreatedAt,
+		Pending:   s.Pending,
+	}
+}
+
+// Subscribe registers an email address for the digest of the watchlist and emails it the
+// confirmation link, the subscription stays pending until the link is confirmed. Its
+// first digest covers the day it was registered.
+func (e *EmailRoutes) Subscribe(c *gin.Context) {
+	var req types.EmailSubscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, apperror.New(apperror.InvalidInput, err.Error()))
+		return
+	}
+	parsed, err := mail.ParseAddress(req.Email)
+	if err != nil || parsed.Name != "" {
+		respondError(c, apperror.New(apperror.InvalidInput, "email must be a valid email address"))
+		return
+	}
+	emailAddress := strings.ToLower(parsed.Address)
+	frequency := req.Frequency
+	if frequency == "" {
+		frequency = types.EmailDaily
+	}
+	if frequency != types.EmailDaily && frequency != types.EmailWeekly {
+		respondError(c, apperror.New(apperror.InvalidInput, "frequency must be daily or weekly"))
+		return
+	}
+
+	configValues := e.reloader.Current()
+	maxEmails := 5
+	if configValues.Emails != nil && configValues.Emails.MaxEmails > 0 {
+		maxEmails = configValues.Emails.MaxEmails
+	}
+	id, errId := randomHex(16)
+	token, errToken := randomHex(32)
+	if errId != nil || errToken != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to subscribe email", errors.Join(errId, errToken)))
+		return
+	}
+	now := time.Now().Unix()
+	owner := c.GetString(apiKeyOwner)
+	subscription := &types.EmailSubscriptionDoc{
+		ID:        id,
+		Owner:     owner,
+		Email:     emailAddress,
+		Frequency: frequency,
+		Token:     token,
+		CreatedAt: now,
+		LastDay:   email.FirstLastDay(now),
+		Pending:   true,
+	}
+	if err := e.addSubscription(subscription, maxEmails); err != nil {
+		respondError(c, err)
+		return
+	}
+	if err := email.SendConfirmation(configValues, subscription); err != nil {
+		if _, errDelete := e.writeDB.DeleteEmailSubscription(subscription.ID, owner); errDelete != nil {
+			log.Printf("Failed to delete email subscription %s: %v", subscription.ID, errDelete)
+		}
+		respondError(c, apperror.Wrap(apperror.Unavailable, "Failed to send the confirmation email", err))
+		return
+	}
+	c.JSON(201, toEmailSubscription(subscription))
+}
+
+// addSubscription saves subscription in the lowest free slot of its owner. The unique
+// indexes on the owner with the email and with the slot reject a concurrent subscribe
+// that took either, the checks then run again against the subscriptions saved meanwhile.
+func (e *EmailRoutes) addSubscription(subscription *types.EmailSubscriptionDoc, maxEmails int) error {
+	for attempt := 0; attempt <= maxEmails; attempt++ {
+		subscriptions, err := e.db.GetEmailSubscriptions(subscription.Owner)
+		if err != nil {
+			return apperror.Wrap(apperror.Internal, "Failed to fetch email subscriptions", err)
+		}
+		used := make(map[int]bool, len(subscriptions))
+		for _, v := range subscriptions {
+			if v.Email == subscription.Email {
+				return apperror.New(apperror.Conflict, "email is already subscribed")
+			}
+			used[v.Slot] = true
+		}
+		if len(subscriptions) >= maxEmails {
+			return apperror.New(apperror.InvalidInput, "watchlists are limited to "+strconv.Itoa(maxEmails)+" emails")
+		}
+		subscription.Slot = 0
+		for used[subscription.Slot] {
+			subscription.Slot++
+		}
+		err = e.writeDB.AddEmailSubscription(subscription)
+		if apperror.KindOf(err) == apperror.Conflict {
+			continue
+		}
+		if err != nil {
+			return apperror.Wrap(apperror.Internal, "Failed to subscribe email", err)
+		}
+		return nil
+	}
+	return apperror.New(apperror.Conflict, "email subscriptions changed while subscribing, try again")
+}
+
+func (e *EmailRoutes) GetSubscriptions(c *gin.Context) {
+	subscriptions, err := e.db.GetEmailSubscriptions(c.GetString(apiKeyOwner))
+	if err != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch email subscriptions", err))
+		return
+	}
+	response := make([]*types.EmailSubscription, len(subscriptions))
+	for i, v := range subscriptions {
+		response[i] = toEmailSubscription(v)
+	}
+	c.JSON(200, response)
+}
+
+func (e *EmailRoutes) DeleteSubscription(c *gin.Context) {
+	deleted, err := e.writeDB.DeleteEmailSubscription(c.Param("subscriptionId"), c.GetString(apiKeyOwner))
+	if err != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to delete email subscription", err))
+		return
+	}
+	if !deleted {
+		respondError(c, apperror.New(apperror.NotFound, "Email subscription not found"))
+		return
+	}
+	c.Status(204)
+}
+
+// linkPage is the page of a confirmation or unsubscribe link, its form posts to the link.
+var linkPage = template.Must(template.New("link").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>{{.Title}}</title>
+</head>
+<body>
+  <form method="post">
+    <p>{{.Text}}</p>
+    <button type="submit">{{.Title}}</button>
+  </form>
+</body>
+</html>
+`))
+
+func renderLinkPage(c *gin.Context, title string, text string) {
+	if c.Query("token") == "" {
+		respondError(c, apperror.New(apperror.InvalidInput, "token is required"))
+		return
+	}
+	var page bytes.Buffer
+	if err := linkPage.Execute(&page, map[string]string{"Title": title, "Text": text}); err != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to render page", err))
+		return
+	}
+	c.Data(200, "text/html; charset=utf-8", page.Bytes())
+}
+
+func (e *EmailRoutes) ConfirmPage(c *gin.Context) {
+	renderLinkPage(c, "Confirm", "Confirm to get the Spacemesh watchlist digests at this address.")
+}
+
+// Confirm activates the subscription of the token of a confirmation link.
+func (e *EmailRoutes) Confirm(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		respondError(c, apperror.New(apperror.InvalidInput, "token is required"))
+		return
+	}
+	confirmed, err := e.writeDB.ConfirmEmailSubscription(token)
+	if err != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to confirm", err))
+		return
+	}
+	if !confirmed {
+		respondError(c, apperror.New(apperror.NotFound, "Email subscription not found"))
+		return
+	}
+	c.String(200, "You are subscribed to the watchlist digests.\n")
+}
+
+func (e *EmailRoutes) UnsubscribePage(c *gin.Context) {
+	renderLinkPage(c, "Unsubscribe", "Unsubscribe this address from the Spacemesh watchlist digests.")
+}
+
+// Unsubscribe deletes the subscription of the token of an unsubscribe link.
+func (e *EmailRoutes) Unsubscribe(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		respondError(c, apperror.New(apperror.InvalidInput, "token is required"))
+		return
+	}
+	deleted, err := e.writeDB.DeleteEmailSubscriptionByToken(token)
+	if err != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to unsubscribe", err))
+		return
+	}
+	if !deleted {
+		respondError(c, apperror.New(apperror.NotFound, "Email subscription not found"))
+		return
+	}
+	c.String(200, "You are unsubscribed from the watchlist digests.\n")
+}
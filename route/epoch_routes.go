@@ -1,22 +1,23 @@
 package route
 
 import (
+	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/swarmbit/spacemesh-state-api/config"
 	"github.com/swarmbit/spacemesh-state-api/database"
 	"github.com/swarmbit/spacemesh-state-api/network"
+	"github.com/swarmbit/spacemesh-state-api/pkg/apperror"
 	"github.com/swarmbit/spacemesh-state-api/types"
-	"net/http"
 	"strconv"
 )
 
 type EpochRoutes struct {
-	db           *database.ReadDB
+	db           database.ReadStore
 	networkUtils *network.NetworkUtils
 	state        *network.NetworkState
 }
 
-func NewEpochRoutes(db *database.ReadDB, networkUtils *network.NetworkUtils, state *network.NetworkState) *EpochRoutes {
+func NewEpochRoutes(db database.ReadStore, networkUtils *network.NetworkUtils, state *network.NetworkState) *EpochRoutes {
 	routes := &EpochRoutes{
 		db:           db,
 		networkUtils: networkUtils,
@@ -31,44 +32,44 @@ func (e *EpochRoutes) GetEpoch(c *gin.Context) {
 	epoch, err := strconv.Atoi(epochStr)
 
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "epoch must be a valid integer",
-		})
+		respondError(c, apperror.New(apperror.InvalidInput, "epoch must be a valid integer"))
 		return
 	}
 
-	atxEpoch, err := e.db.CountAtxEpoch(uint64(epoch - 1))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to count atx for epoch",
-		})
+	times, ok := newTimeFormatter(c, e.networkUtils)
+	if !ok {
 		return
 	}
 
 	atxEpochTotals, err := e.db.GetAtxEpoch(uint64(epoch - 1))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to get atx for epoch",
-		})
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to get atx for epoch", err))
 		return
 	}
 
-	firstLayer := uint32(epoch * config.LayersPerEpoch)
+	firstLayer := uint32(epoch) * config.LayersPerEpoch
 	lastLayer := firstLayer + config.LayersPerEpoch
 
 	rewardsTotal, err := e.db.SumRewardsLayers("", firstLayer, lastLayer)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to get epoch rewards",
-		})
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to get epoch rewards", err))
+		return
+	}
+
+	performance, err := e.db.GetEpochPerformance(uint32(epoch))
+	if err != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to get epoch performance", err))
 		return
 	}
 	c.JSON(200, &types.Epoch{
 		EffectiveUnitsCommited: atxEpochTotals.TotalEffectiveNumUnits,
-		EpochSubsidy:           e.state.GetEpochSubsidy(uint32(epoch)),
+		EpochSubsidy:           requestState(c, e.state).GetEpochSubsidy(uint32(epoch)),
 		TotalWeight:            atxEpochTotals.TotalWeight,
 		TotalRewards:           rewardsTotal,
-		TotalActiveSmeshers:    uint64(atxEpoch),
+		TotalActiveSmeshers:    atxEpochTotals.TotalAtx,
+		StartTime:              times.epoch(uint64(epoch)),
+		EndTime:                times.epoch(uint64(epoch + 1)),
+		MissedRewards:          performance.MissedRewards(),
 	})
 }
 
@@ -77,35 +78,28 @@ func (e *EpochRoutes) GetEpochAtx(c *gin.Context) {
 	epoch, err := strconv.Atoi(epochStr)
 
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "epoch must be a valid integer",
-		})
+		respondError(c, apperror.New(apperror.InvalidInput, "epoch must be a valid integer"))
 		return
 	}
 
 	offsetStr := c.DefaultQuery("offset", "0")
 	limitStr := c.DefaultQuery("limit", "20")
 	sortStr := c.DefaultQuery("sort", "asc")
+	sortByStr := c.DefaultQuery("sortBy", database.AtxSortEffectiveUnits)
 
 	offset, err := strconv.Atoi(offsetStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "offset must be a valid integer",
-		})
+		respondError(c, apperror.New(apperror.InvalidInput, "offset must be a valid integer"))
 		return
 	}
 	limit, err := strconv.Atoi(limitStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "limit must be a valid integer",
-		})
+		respondError(c, apperror.New(apperror.InvalidInput, "limit must be a valid integer"))
 		return
 	}
 
 	if offset < 0 || limit < 0 {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "offset and limit must be greater or equal to 0",
-		})
+		respondError(c, apperror.New(apperror.InvalidInput, "offset and limit must be greater or equal to 0"))
 		return
 	}
 
@@ -116,39 +110,50 @@ func (e *EpochRoutes) GetEpochAtx(c *gin.Context) {
 		sort = 1
 	}
 
-	atxs, errAtx := e.db.GetAtxForEpochPaginated(uint64(epoch-1), int64(offset), int64(limit), sort)
-	count, errCount := e.db.CountAtxEpoch(uint64(epoch - 1))
+	var sortField string
+	switch sortByStr {
+	case database.AtxSortEffectiveUnits, database.AtxSortHeight:
+		sortField = sortByStr
+	default:
+		respondError(c, apperror.New(apperror.InvalidInput, "sortBy must be one of effectiveUnits or height"))
+		return
+	}
+
+	times, ok := newTimeFormatter(c, e.networkUtils)
+	if !ok {
+		return
+	}
+
+	if format := exportFormat(c); format != "" {
+		writer := newExportWriter(c, format, "epoch-"+strconv.Itoa(epoch)+"-atx")
+		writer.Close(e.db.StreamAtxForEpoch(uint64(epoch-1), sort, func(v *types.AtxDoc) error {
+			return writer.Write(toAtx(v, times))
+		}))
+		return
+	}
+
+	atxs, errAtx := e.db.GetAtxForEpochPaginated(uint64(epoch-1), sortField, int64(offset), int64(limit), sort)
+	atxEpochTotals, errCount := e.db.GetAtxEpoch(uint64(epoch - 1))
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to get epoch atx",
-		})
+		respondError(c, apperror.Wrap(apperror.Internal, "failed to get epoch atx", err))
 		return
 	}
 
 	if errAtx != nil || errCount != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"status": "Internal Error",
-			"error":  "Failed to fetch atx for epoch",
-		})
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch atx for epoch", errors.Join(errAtx, errCount)))
 	} else if atxs != nil {
 
 		atxResponse := make([]*types.Atx, len(atxs))
 
 		for i, a := range atxs {
-			atxResponse[i] = &types.Atx{
-				NodeId:            a.NodeID,
-				AtxId:             a.AtxID,
-				EffectiveNumUnits: a.EffectiveNumUnits,
-				Weight:            a.Weight,
-				Received:          a.Received,
-			}
+			atxResponse[i] = toAtx(a, times)
 		}
 
-		c.Header("total", strconv.FormatInt(count, 10))
+		c.Header("total", strconv.FormatUint(atxEpochTotals.TotalAtx, 10))
 		c.JSON(200, atxResponse)
 	} else {
-		c.Header("total", strconv.FormatInt(count, 10))
+		c.Header("total", strconv.FormatUint(atxEpochTotals.TotalAtx, 10))
 		c.JSON(200, make([]*types.Atx, 0))
 	}
 
@@ -0,0 +1,63 @@
+package route
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/pkg/apperror"
+)
+
+type errorStatus struct {
+	code   int
+	status string
+}
+
+var errorStatuses = map[apperror.Kind]errorStatus{
+	apperror.Internal:     {http.StatusInternalServerError, "Internal Error"},
+	apperror.NotFound:     {http.StatusNotFound, "Not Found"},
+	apperror.Conflict:     {http.StatusConflict, "Conflict"},
+	apperror.Unavailable:  {http.StatusServiceUnavailable, "Unavailable"},
+	apperror.InvalidInput: {http.StatusBadRequest, "Bad Request"},
+	apperror.NotSupported: {http.StatusNotImplemented, "Not Implemented"},
+	apperror.Unauthorized: {http.StatusUnauthorized, "Unauthorized"},
+}
+
+// ErrorResponses answers the error a handler recorded with respondError. Every error
+// body has the status, the code of the error kind and the error message, causes are
+// logged and never sent to the client.
+func ErrorResponses() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+		err := database.Classify(c.Errors.Last().Err)
+		kind := apperror.KindOf(err)
+		status, ok := errorStatuses[kind]
+		if !ok {
+			kind = apperror.Internal
+			status = errorStatuses[kind]
+		}
+		if status.code >= http.StatusInternalServerError {
+			log.Printf("%s %s failed: %s", c.Request.Method, c.Request.URL.Path, err.Error())
+		}
+		message := apperror.MessageOf(err)
+		if message == "" {
+			message = status.status
+		}
+		c.JSON(status.code, gin.H{
+			"status": status.status,
+			"code":   kind,
+			"error":  message,
+		})
+	}
+}
+
+// respondError records err for ErrorResponses and stops the handlers after the current
+// one.
+func respondError(c *gin.Context, err error) {
+	c.Error(err)
+	c.Abort()
+}
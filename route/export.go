@@ -0,0 +1,228 @@
+package route
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+const (
+	exportCSV    = "text/csv"
+	exportNDJSON = "application/x-ndjson"
+)
+
+// rows are flushed to the client in chunks of this size while streaming exports
+const exportFlushRows = 500
+
+// exportFormat returns the streaming format asked for in the Accept header, empty
+// when the regular paginated json response should be served.
+func exportFormat(c *gin.Context) string {
+	accept := c.GetHeader("Accept")
+	if strings.Contains(accept, exportCSV) {
+		return exportCSV
+	}
+	if strings.Contains(accept, exportNDJSON) {
+		return exportNDJSON
+	}
+	return ""
+}
+
+// exportWriter streams response rows as CSV, with the columns taken from the json
+// tags of the row struct, or as newline delimited json.
+type exportWriter struct {
+	c      *gin.Context
+	format string
+	csv    *csv.Writer
+	json   *json.Encoder
+	rows   int
+}
+
+func newExportWriter(c *gin.Context, format string, filename string) *exportWriter {
+	extension := "csv"
+	if format == exportNDJSON {
+		extension = "ndjson"
+	}
+	c.Header("Content-Type", format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.%s\"", filename, extension))
+	c.Status(http.StatusOK)
+	return &exportWriter{
+		c:      c,
+		format: format,
+		csv:    csv.NewWriter(c.Writer),
+		json:   json.NewEncoder(c.Writer),
+	}
+}
+
+// Write streams one row, the error does not depend on the format.
+func (w *exportWriter) Write(row interface{}) error {
+	var err error
+	if w.format == exportNDJSON {
+		err = w.json.Encode(row)
+	} else {
+		err = w.writeCSV(row)
+	}
+	if err != nil {
+		return fmt.Errorf("write export row %d: %w", w.rows+1, err)
+	}
+	w.rows++
+	if w.rows%exportFlushRows == 0 {
+		return w.flush()
+	}
+	return nil
+}
+
+func (w *exportWriter) writeCSV(row interface{}) error {
+	value := reflect.Indirect(reflect.ValueOf(row))
+	if w.rows == 0 {
+		if err := w.csv.Write(csvHeader(value.Type())); err != nil {
+			return err
+		}
+	}
+	return w.csv.Write(csvRecord(value))
+}
+
+func (w *exportWriter) flush() error {
+	if w.format == exportCSV {
+		w.csv.Flush()
+		if err := w.csv.Error(); err != nil {
+			return fmt.Errorf("flush export: %w", err)
+		}
+	}
+	w.c.Writer.Flush()
+	return nil
+}
+
+// Close flushes the remaining rows. The status is already sent when streaming fails
+// so the error is only logged and the client gets a truncated body.
+func (w *exportWriter) Close(err error) {
+	if flushErr := w.flush(); err == nil {
+		err = flushErr
+	}
+	if err != nil {
+		log.Printf("Failed to stream export of %s: %v", w.c.Request.URL.Path, err)
+	}
+}
+
+func csvHeader(rowType reflect.Type) []string {
+	header := make([]string, rowType.NumField())
+	for i := 0; i < rowType.NumField(); i++ {
+		header[i] = strings.Split(rowType.Field(i).Tag.Get("json"), ",")[0]
+	}
+	return header
+}
+
+func csvRecord(value reflect.Value) []string {
+	record := make([]string, value.NumField())
+	for i := 0; i < value.NumField(); i++ {
+		record[i] = fmt.Sprint(value.Field(i).Interface())
+	}
+	return record
+}
+
+func transactionMethod(method uint8) string {
+	switch method {
+	case 0:
+		return "Spawn"
+	case 16:
+		return "Spend"
+	case 17:
+		return "DrainVault"
+	}
+	return ""
+}
+
+func toTransaction(v *types.TransactionDoc, times *timeFormatter) *types.Transaction {
+	return &types.Transaction{
+		ID:               v.ID,
+		Status:           v.Status,
+		State:            v.State(),
+		Message:          v.Message,
+		Gas:              v.Gas,
+		PrincipalAccount: v.PrincipaAccount,
+		ReceiverAccount:  v.ReceiverAccount,
+		VaultAccount:     v.VaultAccount,
+		Fee:              v.Gas * v.GasPrice,
+		Amount:           v.Amount,
+		Layer:            v.Layer,
+		Counter:          v.Counter,
+		Method:           transactionMethod(v.Method),
+		Type:             v.Type,
+		Time:             times.layer(uint64(v.Layer)),
+		Timestamp:        config.GenesisEpochSeconds + int64(v.Layer)*config.LayerDuration,
+	}
+}
+
+func toReward(v *types.RewardsDoc, times *timeFormatter) *types.Reward {
+	return &types.Reward{
+		Account: v.Coinbase,
+		Rewards: int64(v.TotalReward),
+		// legacy
+		RewardsDisplay: "",
+		Layer:          v.Layer,
+		SmesherId:      v.NodeId,
+		Time:           times.layer(uint64(v.Layer)),
+		Timestamp:      config.GenesisEpochSeconds + int64(v.Layer)*config.LayerDuration,
+	}
+}
+
+// toListedReward is a reward of the account and node reward lists, which never carried
+// the account.
+func toListedReward(v *types.RewardsDoc, times *timeFormatter) *types.Reward {
+	reward := toReward(v, times)
+	reward.Account = ""
+	return reward
+}
+
+func toBlock(b *types.BlockDoc, times *timeFormatter) *types.Block {
+	return &types.Block{
+		ID:           b.ID,
+		Layer:        b.Layer,
+		Transactions: b.Transactions,
+		Time:         times.layer(uint64(b.Layer)),
+		Timestamp:    config.GenesisEpochSeconds + b.Layer*config.LayerDuration,
+	}
+}
+
+func toAtxDetail(a *types.AtxDoc, times *timeFormatter) *types.AtxDetail {
+	return &types.AtxDetail{
+		AtxId:             a.AtxID,
+		NodeId:            a.NodeID,
+		Coinbase:          a.Coinbase,
+		PublishEpoch:      a.PublishEpoch,
+		TargetEpoch:       a.PublishEpoch + 1,
+		EffectiveNumUnits: a.EffectiveNumUnits,
+		Weight:            a.Weight,
+		BaseTick:          a.BaseTick,
+		TickCount:         a.TickCount,
+		TickHeight:        a.BaseTick + a.TickCount,
+		Sequence:          a.Sequence,
+		Received:          a.Received,
+		ReceivedTime:      times.unixMilli(a.Received),
+	}
+}
+
+// toAccountAtx is an atx of the account atx list, which never carried the weight.
+func toAccountAtx(a *types.AtxDoc, times *timeFormatter) *types.Atx {
+	atx := toAtx(a, times)
+	atx.Weight = 0
+	return atx
+}
+
+func toAtx(a *types.AtxDoc, times *timeFormatter) *types.Atx {
+	return &types.Atx{
+		NodeId:            a.NodeID,
+		AtxId:             a.AtxID,
+		EffectiveNumUnits: a.EffectiveNumUnits,
+		Weight:            a.Weight,
+		Received:          a.Received,
+		ReceivedTime:      times.unixMilli(a.Received),
+	}
+}
@@ -0,0 +1,52 @@
+package route
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	"github.com/swarmbit/spacemesh-state-api/node"
+	"github.com/swarmbit/spacemesh-state-api/sink"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+// HealthRoutes reports the connections of the instance. The sink is only set once it
+// runs, on api instances and standby sinks the nats connection is not reported.
+type HealthRoutes struct {
+	nodeClient *node.NodeClient
+	sink       atomic.Pointer[sink.Sink]
+}
+
+func NewHealthRoutes(nodeClient *node.NodeClient) *HealthRoutes {
+	return &HealthRoutes{
+		nodeClient: nodeClient,
+	}
+}
+
+// SetSink makes the running sink connection part of the health.
+func (h *HealthRoutes) SetSink(s *sink.Sink) {
+	h.sink.Store(s)
+}
+
+// GetHealth answers 503 with the status degraded while the sink is disconnected from
+// nats. The node is optional, its status is reported without changing the health.
+func (h *HealthRoutes) GetHealth(c *gin.Context) {
+	health := &types.Health{
+		Status: "ok",
+	}
+	if s := h.sink.Load(); s != nil {
+		health.Nats = s.ConnectionStatus()
+		if !health.Nats.Connected {
+			health.Status = "degraded"
+		}
+	}
+	if h.nodeClient != nil {
+		health.Node = h.nodeClient.Status()
+	}
+
+	if health.Status != "ok" {
+		c.JSON(http.StatusServiceUnavailable, health)
+		return
+	}
+	c.JSON(200, health)
+}
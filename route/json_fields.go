@@ -0,0 +1,191 @@
+package route
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// jsonField is a field of a json object, objects are rewritten as their ordered fields.
+type jsonField struct {
+	key   string
+	value json.RawMessage
+}
+
+// rewriteObjects applies rewrite to every object of a json value, the inner objects
+// first, keeping the order of the fields rewrite returns.
+func rewriteObjects(value json.RawMessage, rewrite func(fields []jsonField) ([]jsonField, error)) (json.RawMessage, error) {
+	value = bytes.TrimSpace(value)
+	if len(value) == 0 || (value[0] != '{' && value[0] != '[') {
+		return value, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(value))
+	if _, err := decoder.Token(); err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	if value[0] == '[' {
+		out.WriteByte('[')
+		for i := 0; decoder.More(); i++ {
+			var item json.RawMessage
+			if err := decoder.Decode(&item); err != nil {
+				return nil, err
+			}
+			converted, err := rewriteObjects(item, rewrite)
+			if err != nil {
+				return nil, err
+			}
+			if i > 0 {
+				out.WriteByte(',')
+			}
+			out.Write(converted)
+		}
+		out.WriteByte(']')
+		return out.Bytes(), nil
+	}
+
+	fields := make([]jsonField, 0)
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		var item json.RawMessage
+		if err := decoder.Decode(&item); err != nil {
+			return nil, err
+		}
+		converted, err := rewriteObjects(item, rewrite)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, jsonField{key: token.(string), value: converted})
+	}
+	fields, err := rewrite(fields)
+	if err != nil {
+		return nil, err
+	}
+	return writeObject(fields), nil
+}
+
+func writeObject(fields []jsonField) json.RawMessage {
+	var out bytes.Buffer
+	out.WriteByte('{')
+	for i, field := range fields {
+		if i > 0 {
+			out.WriteByte(',')
+		}
+		name, _ := json.Marshal(field.key)
+		out.Write(name)
+		out.WriteByte(':')
+		out.Write(field.value)
+	}
+	out.WriteByte('}')
+	return out.Bytes()
+}
+
+// rewriteObjectsAt applies rewrite to the objects at path in value, the path of an
+// objectShape split at its dots. Values at the path that are not objects and missing
+// fields are left as they are.
+func rewriteObjectsAt(value json.RawMessage, path []string, rewrite func(fields []jsonField) []jsonField) (json.RawMessage, error) {
+	value = bytes.TrimSpace(value)
+	if len(path) > 0 && path[0] == "[]" {
+		if len(value) == 0 || value[0] != '[' {
+			return value, nil
+		}
+		var items []json.RawMessage
+		if err := json.Unmarshal(value, &items); err != nil {
+			return nil, err
+		}
+		var out bytes.Buffer
+		out.WriteByte('[')
+		for i, item := range items {
+			converted, err := rewriteObjectsAt(item, path[1:], rewrite)
+			if err != nil {
+				return nil, err
+			}
+			if i > 0 {
+				out.WriteByte(',')
+			}
+			out.Write(converted)
+		}
+		out.WriteByte(']')
+		return out.Bytes(), nil
+	}
+	if len(value) == 0 || value[0] != '{' {
+		return value, nil
+	}
+	fields, err := objectFields(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(path) == 0 {
+		return writeObject(rewrite(fields)), nil
+	}
+	i := fieldIndex(fields, path[0])
+	if i < 0 {
+		return value, nil
+	}
+	if fields[i].value, err = rewriteObjectsAt(fields[i].value, path[1:], rewrite); err != nil {
+		return nil, err
+	}
+	return writeObject(fields), nil
+}
+
+// objectFields are the fields of a json object in their order.
+func objectFields(value json.RawMessage) ([]jsonField, error) {
+	decoder := json.NewDecoder(bytes.NewReader(value))
+	if _, err := decoder.Token(); err != nil {
+		return nil, err
+	}
+	fields := make([]jsonField, 0)
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		var item json.RawMessage
+		if err := decoder.Decode(&item); err != nil {
+			return nil, err
+		}
+		fields = append(fields, jsonField{key: token.(string), value: item})
+	}
+	return fields, nil
+}
+
+// fieldIndex is the position of key in fields, -1 when missing.
+func fieldIndex(fields []jsonField, key string) int {
+	for i, field := range fields {
+		if field.key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// rewriteResponse buffers the response of the next handlers and rewrites the body of a
+// successful json response, the response is sent as it is when rewrite fails. Errors
+// and unknown routes are left to the handlers that answer them.
+func rewriteResponse(c *gin.Context, rewrite func(body json.RawMessage) (json.RawMessage, error)) {
+	writer := &bufferedWriter{ResponseWriter: c.Writer, status: c.Writer.Status()}
+	c.Writer = writer
+	c.Next()
+	c.Writer = writer.ResponseWriter
+	if !writer.written {
+		return
+	}
+
+	body := writer.body.Bytes()
+	if (writer.status == http.StatusOK || writer.status == http.StatusCreated) &&
+		strings.HasPrefix(c.Writer.Header().Get("Content-Type"), "application/json") {
+		if rewritten, err := rewrite(body); err == nil {
+			body = rewritten
+		}
+	}
+	c.Writer.WriteHeader(writer.status)
+	c.Writer.WriteHeaderNow()
+	c.Writer.Write(body)
+}
@@ -1,22 +1,22 @@
 package route
 
 import (
+	"errors"
 	"github.com/gin-gonic/gin"
-	"github.com/swarmbit/spacemesh-state-api/config"
 	"github.com/swarmbit/spacemesh-state-api/database"
 	"github.com/swarmbit/spacemesh-state-api/network"
+	"github.com/swarmbit/spacemesh-state-api/pkg/apperror"
 	"github.com/swarmbit/spacemesh-state-api/types"
-	"net/http"
 	"strconv"
 )
 
 type LayersRoutes struct {
-	db           *database.ReadDB
+	db           database.ReadStore
 	networkUtils *network.NetworkUtils
 	state        *network.NetworkState
 }
 
-func NewLayersRoutes(db *database.ReadDB, networkUtils *network.NetworkUtils, state *network.NetworkState) *LayersRoutes {
+func NewLayersRoutes(db database.ReadStore, networkUtils *network.NetworkUtils, state *network.NetworkState) *LayersRoutes {
 	routes := &LayersRoutes{
 		db:           db,
 		networkUtils: networkUtils,
@@ -33,23 +33,17 @@ func (l *LayersRoutes) GetLayers(c *gin.Context) {
 
 	offset, err := strconv.Atoi(offsetStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "offset must be a valid integer",
-		})
+		respondError(c, apperror.New(apperror.InvalidInput, "offset must be a valid integer"))
 		return
 	}
 	limit, err := strconv.Atoi(limitStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "limit must be a valid integer",
-		})
+		respondError(c, apperror.New(apperror.InvalidInput, "limit must be a valid integer"))
 		return
 	}
 
 	if offset < 0 || limit < 0 {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "offset and limit must be greater or equal to 0",
-		})
+		respondError(c, apperror.New(apperror.InvalidInput, "offset and limit must be greater or equal to 0"))
 		return
 	}
 
@@ -63,9 +57,7 @@ func (l *LayersRoutes) GetLayers(c *gin.Context) {
 	layers, err := l.db.GetProcessedsLayers(int64(offset), int64(limit), sort)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to get layers",
-		})
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to get layers", err))
 		return
 	}
 
@@ -82,27 +74,20 @@ func (l *LayersRoutes) GetLayerTransactions(c *gin.Context) {
 	offsetStr := c.DefaultQuery("offset", "0")
 	limitStr := c.DefaultQuery("limit", "20")
 	sortStr := c.DefaultQuery("sort", "asc")
-	completeStr := c.DefaultQuery("complete", "true")
 
 	offset, err := strconv.Atoi(offsetStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "offset must be a valid integer",
-		})
+		respondError(c, apperror.New(apperror.InvalidInput, "offset must be a valid integer"))
 		return
 	}
 	limit, err := strconv.Atoi(limitStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "limit must be a valid integer",
-		})
+		respondError(c, apperror.New(apperror.InvalidInput, "limit must be a valid integer"))
 		return
 	}
 
 	if offset < 0 || limit < 0 {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "offset and limit must be greater or equal to 0",
-		})
+		respondError(c, apperror.New(apperror.InvalidInput, "offset and limit must be greater or equal to 0"))
 		return
 	}
 
@@ -113,54 +98,43 @@ func (l *LayersRoutes) GetLayerTransactions(c *gin.Context) {
 		sort = 1
 	}
 
-	complete := completeStr == "true"
+	state, ok := transactionState(c)
+	if !ok {
+		return
+	}
 
 	layerStr := c.Param("layer")
 
 	layer, err := strconv.Atoi(layerStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "layer must be a valid integer",
-		})
+		respondError(c, apperror.New(apperror.InvalidInput, "layer must be a valid integer"))
+		return
+	}
+
+	times, ok := newTimeFormatter(c, l.networkUtils)
+	if !ok {
 		return
 	}
 
-	transactions, errRewards := l.db.GetLayerTransactions(layer, int64(offset), int64(limit), sort, complete)
-	count, errCount := l.db.CountLayerTransactions(layer)
+	if format := exportFormat(c); format != "" {
+		writer := newExportWriter(c, format, "layer-"+strconv.Itoa(layer)+"-transactions")
+		writer.Close(l.db.StreamLayerTransactions(layer, sort, state, func(v *types.TransactionDoc) error {
+			return writer.Write(toTransaction(v, times))
+		}))
+		return
+	}
+
+	transactions, errRewards := l.db.GetLayerTransactions(layer, int64(offset), int64(limit), sort, state)
+	count, errCount := l.db.CountLayerTransactions(layer, state)
 
 	if errRewards != nil || errCount != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"status": "Internal Error",
-			"error":  "Failed to fetch transactions for layer",
-		})
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch transactions for layer", errors.Join(errRewards, errCount)))
 	} else if transactions != nil {
 
 		transactionsResponse := make([]*types.Transaction, len(transactions))
 
 		for i, v := range transactions {
-			method := ""
-			if v.Method == 0 {
-				method = "Spawn"
-			}
-			if v.Method == 16 {
-				method = "Spend"
-			}
-			if v.Method == 17 {
-				method = "DrainVault"
-			}
-			transactionsResponse[i] = &types.Transaction{
-				ID:               v.ID,
-				Status:           v.Status,
-				PrincipalAccount: v.PrincipaAccount,
-				ReceiverAccount:  v.ReceiverAccount,
-				VaultAccount:     v.VaultAccount,
-				Fee:              v.Gas * v.GasPrice,
-				Amount:           v.Amount,
-				Layer:            v.Layer,
-				Counter:          v.Counter,
-				Method:           method,
-				Timestamp:        int64(config.GenesisEpochSeconds + (v.Layer * config.LayerDuration)),
-			}
+			transactionsResponse[i] = toTransaction(v, times)
 		}
 
 		c.Header("total", strconv.FormatInt(count, 10))
@@ -180,31 +154,23 @@ func (l *LayersRoutes) GetLayerRewards(c *gin.Context) {
 
 	layer, err := strconv.Atoi(layerStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "layer must be a valid integer",
-			})
+		respondError(c, apperror.New(apperror.InvalidInput, "layer must be a valid integer"))
 		return
 	}
 
 	offset, err := strconv.Atoi(offsetStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "offset must be a valid integer",
-			})
+		respondError(c, apperror.New(apperror.InvalidInput, "offset must be a valid integer"))
 		return
 	}
 	limit, err := strconv.Atoi(limitStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "limit must be a valid integer",
-			})
+		respondError(c, apperror.New(apperror.InvalidInput, "limit must be a valid integer"))
 		return
 	}
 
 	if offset < 0 || limit < 0 {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "offset and limit must be greater or equal to 0",
-			})
+		respondError(c, apperror.New(apperror.InvalidInput, "offset and limit must be greater or equal to 0"))
 		return
 	}
 
@@ -215,30 +181,30 @@ func (l *LayersRoutes) GetLayerRewards(c *gin.Context) {
 		sort = -1
 	}
 
+	times, ok := newTimeFormatter(c, l.networkUtils)
+	if !ok {
+		return
+	}
+
+	if format := exportFormat(c); format != "" {
+		writer := newExportWriter(c, format, "layer-"+strconv.Itoa(layer)+"-rewards")
+		writer.Close(l.db.StreamLayerRewards(layer, sort, func(v *types.RewardsDoc) error {
+			return writer.Write(toReward(v, times))
+		}))
+		return
+	}
+
 	rewards, errRewards := l.db.GetLayerRewards(layer, int64(offset), int64(limit), sort)
 	count, errCount := l.db.CountLayerRewards(layer)
 
 	if errRewards != nil || errCount != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"status": "Internal Error",
-			"error":  "Failed to fetch rewards for account",
-			})
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch rewards for account", errors.Join(errRewards, errCount)))
 	} else if rewards != nil {
 
 		rewardsResponse := make([]*types.Reward, len(rewards))
 
 		for i, v := range rewards {
-			rewardsResponse[i] = &types.Reward{
-				Account: v.Coinbase,
-				Rewards: int64(v.TotalReward),
-				// legacy
-				RewardsDisplay: "",
-				Layer:          v.Layer,
-				SmesherId:      v.NodeId,
-				// legacy
-				Time:      "2023-09-05T00:00:00Z",
-				Timestamp: config.GenesisEpochSeconds + (v.Layer * config.LayerDuration),
-				}
+			rewardsResponse[i] = toReward(v, times)
 		}
 
 		c.Header("total", strconv.FormatInt(count, 10))
@@ -248,3 +214,46 @@ func (l *LayersRoutes) GetLayerRewards(c *gin.Context) {
 		c.JSON(200, make([]*types.Reward, 0))
 	}
 }
+
+func (l *LayersRoutes) GetLayerBlocks(c *gin.Context) {
+	layer, err := strconv.Atoi(c.Param("layer"))
+	if err != nil {
+		respondError(c, apperror.New(apperror.InvalidInput, "layer must be a valid integer"))
+		return
+	}
+
+	times, ok := newTimeFormatter(c, l.networkUtils)
+	if !ok {
+		return
+	}
+
+	blocks, err := l.db.GetLayerBlocks(layer)
+	if err != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch blocks for layer", err))
+		return
+	}
+
+	blocksResponse := make([]*types.Block, len(blocks))
+	for i, v := range blocks {
+		blocksResponse[i] = toBlock(v, times)
+	}
+	c.JSON(200, blocksResponse)
+}
+
+func (l *LayersRoutes) GetBlock(c *gin.Context) {
+	times, ok := newTimeFormatter(c, l.networkUtils)
+	if !ok {
+		return
+	}
+
+	block, err := l.db.GetBlock(c.Param("blockId"))
+	if err != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch block", err))
+		return
+	}
+	if block.ID == "" {
+		respondError(c, apperror.New(apperror.NotFound, "Block not found"))
+		return
+	}
+	c.JSON(200, toBlock(block, times))
+}
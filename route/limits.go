@@ -0,0 +1,123 @@
+package route
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/pkg/apperror"
+)
+
+const defaultMaxLimit = 1000
+
+const defaultMaxBatchAccounts = 100
+
+func limitsConfig(reloader *config.Reloader) *config.LimitsConfig {
+	if limits := reloader.Current().Limits; limits != nil {
+		return limits
+	}
+	return &config.LimitsConfig{}
+}
+
+func maxBatchAccounts(reloader *config.Reloader) int {
+	if limits := limitsConfig(reloader); limits.MaxBatchAccounts > 0 {
+		return limits.MaxBatchAccounts
+	}
+	return defaultMaxBatchAccounts
+}
+
+// listLimits sets the limit query parameter of requests without one, or with 0 or less,
+// to the default limit and lowers larger ones to the max limit, before any handler reads
+// the query. Without a default limit those are left to the default of the handler.
+// Limits that are not numbers are left to the handlers to reject.
+func listLimits(reloader *config.Reloader) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limits := limitsConfig(reloader)
+		maxLimit := defaultMaxLimit
+		if limits.MaxLimit > 0 {
+			maxLimit = limits.MaxLimit
+		}
+		query := c.Request.URL.Query()
+		requested, err := strconv.Atoi(query.Get("limit"))
+		switch {
+		case query.Has("limit") && err != nil, requested > 0 && requested <= maxLimit:
+			c.Next()
+			return
+		case requested > maxLimit:
+			query.Set("limit", strconv.Itoa(maxLimit))
+		case limits.DefaultLimit > 0:
+			query.Set("limit", strconv.Itoa(min(limits.DefaultLimit, maxLimit)))
+		case query.Has("limit"):
+			query.Del("limit")
+		default:
+			c.Next()
+			return
+		}
+		c.Request.URL.RawQuery = query.Encode()
+		c.Next()
+	}
+}
+
+// routeSlots hands out the concurrency slots of each route. A route gets new slots when
+// its cap changes on reload, requests holding the old ones release them there.
+type routeSlots struct {
+	mu    sync.Mutex
+	slots map[string]chan struct{}
+}
+
+func (r *routeSlots) get(route string, capacity int) chan struct{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	slots, exists := r.slots[route]
+	if !exists || cap(slots) != capacity {
+		slots = make(chan struct{}, capacity)
+		r.slots[route] = slots
+	}
+	return slots
+}
+
+// concurrencyLimits caps the requests a route serves at once. Requests over the cap
+// wait up to the queue timeout for a slot and are answered with 503 when none frees up.
+func concurrencyLimits(reloader *config.Reloader) gin.HandlerFunc {
+	routes := &routeSlots{slots: make(map[string]chan struct{})}
+	return func(c *gin.Context) {
+		limits := limitsConfig(reloader)
+		route := versionPath(c.FullPath())
+		capacity, limited := limits.Concurrency[route]
+		if !limited || capacity <= 0 {
+			c.Next()
+			return
+		}
+		slots := routes.get(route, capacity)
+		if !acquireSlot(c, slots, time.Duration(limits.QueueTimeout)*time.Millisecond) {
+			c.Header("Retry-After", "1")
+			respondError(c, apperror.New(apperror.Unavailable, "Too many requests to "+route+", retry later"))
+			return
+		}
+		defer func() { <-slots }()
+		c.Next()
+	}
+}
+
+func acquireSlot(c *gin.Context, slots chan struct{}, wait time.Duration) bool {
+	select {
+	case slots <- struct{}{}:
+		return true
+	default:
+	}
+	if wait <= 0 {
+		return false
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-c.Request.Context().Done():
+		return false
+	}
+}
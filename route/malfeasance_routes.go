@@ -0,0 +1,124 @@
+package route
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/network"
+	"github.com/swarmbit/spacemesh-state-api/pkg/apperror"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+type MalfeasanceRoutes struct {
+	db           database.ReadStore
+	networkUtils *network.NetworkUtils
+}
+
+func NewMalfeasanceRoutes(db database.ReadStore, networkUtils *network.NetworkUtils) *MalfeasanceRoutes {
+	return &MalfeasanceRoutes{
+		db:           db,
+		networkUtils: networkUtils,
+	}
+}
+
+func (m *MalfeasanceRoutes) GetMalfeasanceNodes(c *gin.Context) {
+	offsetStr := c.DefaultQuery("offset", "0")
+	limitStr := c.DefaultQuery("limit", "20")
+
+	offset, err := strconv.Atoi(offsetStr)
+	if err != nil {
+		respondError(c, apperror.New(apperror.InvalidInput, "offset must be a valid integer"))
+		return
+	}
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil {
+		respondError(c, apperror.New(apperror.InvalidInput, "limit must be a valid integer"))
+		return
+	}
+
+	if offset < 0 || limit < 0 {
+		respondError(c, apperror.New(apperror.InvalidInput, "offset and limit must be greater or equal to 0"))
+		return
+	}
+
+	times, ok := newTimeFormatter(c, m.networkUtils)
+	if !ok {
+		return
+	}
+
+	nodes, errNodes := m.db.GetMalfeasanceNodesPaginated(int64(offset), int64(limit))
+	count, errCount := m.db.CountMalfeasanceNodes()
+
+	if errNodes != nil || errCount != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch malfeasant nodes", errors.Join(errNodes, errCount)))
+		return
+	}
+
+	nodesResponse := make([]*types.MalfeasantNode, len(nodes))
+	for i, v := range nodes {
+		nodesResponse[i] = toMalfeasantNode(v, times)
+	}
+
+	c.Header("total", strconv.FormatInt(count, 10))
+	c.JSON(200, nodesResponse)
+}
+
+func (m *MalfeasanceRoutes) GetMalfeasanceNode(c *gin.Context) {
+	times, ok := newTimeFormatter(c, m.networkUtils)
+	if !ok {
+		return
+	}
+
+	node, err := m.db.GetNode(c.Param("nodeId"))
+	if err != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch node", err))
+		return
+	}
+	if node.ID == "" || node.Malfeasance.Received == 0 {
+		respondError(c, apperror.New(apperror.NotFound, "Malfeasant node not found"))
+		return
+	}
+
+	c.JSON(200, toMalfeasantNode(node, times))
+}
+
+// toMalfeasantNode lists the target epochs of the node atxs from the epoch the proof
+// was detected in, the node gets no rewards for them. Proofs saved without a layer
+// list all the atx epochs.
+func toMalfeasantNode(node *types.NodeDoc, times *timeFormatter) *types.MalfeasantNode {
+	epoch := node.Malfeasance.Layer / config.LayersPerEpoch
+	affectedEpochs := make([]uint32, 0)
+	for _, atx := range node.Atxs {
+		if target := atx.PublishEpoch + 1; target >= epoch {
+			affectedEpochs = append(affectedEpochs, target)
+		}
+	}
+	return &types.MalfeasantNode{
+		NodeId:         node.ID,
+		Layer:          node.Malfeasance.Layer,
+		LayerTime:      times.layer(uint64(node.Malfeasance.Layer)),
+		Epoch:          epoch,
+		Received:       node.Malfeasance.Received,
+		ReceivedTime:   times.unixMilli(node.Malfeasance.Received),
+		AffectedEpochs: affectedEpochs,
+	}
+}
+
+// malfeasantNodes returns the malfeasant nodes of nodeIds as a set.
+func malfeasantNodes(db database.ReadStore, nodeIds []string) (map[string]bool, error) {
+	malfeasant := make(map[string]bool)
+	if len(nodeIds) == 0 {
+		return malfeasant, nil
+	}
+	nodes, err := db.FilterMalfeasanceNodes(nodeIds)
+	if err != nil {
+		return nil, err
+	}
+	for _, nodeId := range nodes {
+		malfeasant[nodeId] = true
+	}
+	return malfeasant, nil
+}
@@ -0,0 +1,146 @@
+package route
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/pkg/apperror"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+// chartMetrics are the network metrics recorded in the history snapshots.
+var chartMetrics = map[string]func(*types.NetworkSnapshotDoc) float64{
+	"weight": func(s *types.NetworkSnapshotDoc) float64 {
+		return float64(s.TotalWeight)
+	},
+	"smeshers": func(s *types.NetworkSnapshotDoc) float64 {
+		return float64(s.ActiveSmeshers)
+	},
+	"accounts": func(s *types.NetworkSnapshotDoc) float64 {
+		return float64(s.TotalAccounts)
+	},
+	"circulating-supply": func(s *types.NetworkSnapshotDoc) float64 {
+		return float64(s.CirculatingSupply)
+	},
+	"price": func(s *types.NetworkSnapshotDoc) float64 {
+		return s.Price
+	},
+}
+
+// GetChart returns the last recorded value of the metric for every day or epoch between
+// from and to, unix times.
+func (n *NetworkRoutes) GetChart(c *gin.Context) {
+	metric, exists := chartMetrics[c.Param("metric")]
+	if !exists {
+		respondError(c, apperror.New(apperror.InvalidInput, "metric must be one of weight, smeshers, accounts, circulating-supply or price"))
+		return
+	}
+	resolution := c.DefaultQuery("resolution", "day")
+	if resolution != "day" && resolution != "epoch" {
+		respondError(c, apperror.New(apperror.InvalidInput, "resolution must be day or epoch"))
+		return
+	}
+
+	now := time.Now()
+	defaultFrom := now.Add(-90 * 24 * time.Hour).Unix()
+	if resolution == "epoch" {
+		defaultFrom = config.GenesisEpochSeconds
+	}
+	from, err := strconv.ParseInt(c.DefaultQuery("from", strconv.FormatInt(defaultFrom, 10)), 10, 64)
+	if err != nil {
+		respondError(c, apperror.New(apperror.InvalidInput, "from must be a valid integer"))
+		return
+	}
+	to, err := strconv.ParseInt(c.DefaultQuery("to", strconv.FormatInt(now.Unix(), 10)), 10, 64)
+	if err != nil {
+		respondError(c, apperror.New(apperror.InvalidInput, "to must be a valid integer"))
+		return
+	}
+
+	times, ok := newTimeFormatter(c, n.networkUtils)
+	if !ok {
+		return
+	}
+
+	snapshots, err := n.db.GetNetworkSnapshots(time.Unix(from, 0), time.Unix(to, 0))
+	if err != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch network history", err))
+		return
+	}
+
+	// snapshots are sorted by time, a bucket keeps the last one it sees
+	points := make([]*types.ChartPoint, 0)
+	for _, snapshot := range snapshots {
+		bucket := snapshot.Timestamp.Unix() / 86400 * 86400
+		start := time.Unix(bucket, 0)
+		if resolution == "epoch" {
+			bucket = int64(snapshot.Epoch)
+			start = n.networkUtils.GetEpochTime(uint64(snapshot.Epoch))
+		}
+		if len(points) == 0 || points[len(points)-1].Bucket != bucket {
+			points = append(points, &types.ChartPoint{
+				Bucket:    bucket,
+				Timestamp: start.Unix(),
+				Time:      times.format(start),
+			})
+		}
+		points[len(points)-1].Value = metric(snapshot)
+	}
+
+	c.JSON(200, points)
+}
+
+// GetInfoHistory returns the network info of the last state refresh in every layer from
+// and to included, the current epoch when not set. Layers without a refresh are missing.
+func (n *NetworkRoutes) GetInfoHistory(c *gin.Context) {
+	layer := requestState(c, n.state).GetInfo().Layer
+	defaultFrom := n.networkUtils.GetEpochFirst(uint64(n.networkUtils.GetEpoch(layer))).Uint32()
+	from, err := strconv.ParseUint(c.DefaultQuery("from", strconv.FormatUint(uint64(defaultFrom), 10)), 10, 64)
+	if err != nil {
+		respondError(c, apperror.New(apperror.InvalidInput, "from must be a valid layer"))
+		return
+	}
+	to, err := strconv.ParseUint(c.DefaultQuery("to", strconv.FormatUint(layer, 10)), 10, 64)
+	if err != nil {
+		respondError(c, apperror.New(apperror.InvalidInput, "to must be a valid layer"))
+		return
+	}
+	maxLayers := 2 * uint64(config.LayersPerEpoch)
+	if to < from || to-from >= maxLayers {
+		respondError(c, apperror.New(apperror.InvalidInput, "to must be after from and at most "+strconv.FormatUint(maxLayers, 10)+" layers apart"))
+		return
+	}
+
+	times, ok := newTimeFormatter(c, n.networkUtils)
+	if !ok {
+		return
+	}
+
+	history, err := n.db.GetNetworkInfoHistory(from, to)
+	if err != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch network info history", err))
+		return
+	}
+	response := make([]*types.NetworkInfoHistory, len(history))
+	for i, v := range history {
+		response[i] = &types.NetworkInfoHistory{
+			Layer:                   v.Layer,
+			LayerTime:               times.layer(v.Layer),
+			Epoch:                   v.Epoch,
+			CreatedAt:               v.CreatedAt.Unix(),
+			TotalWeight:             v.TotalWeight,
+			TotalSlots:              v.TotalSlots,
+			EffectiveUnitsCommited:  v.EffectiveUnitsCommited,
+			TotalActiveSmeshers:     v.ActiveSmeshers,
+			TotalAccounts:           v.TotalAccounts,
+			CirculatingSupply:       v.CirculatingSupply,
+			Price:                   v.Price,
+			NextEpochWeight:         v.NextEpochWeight,
+			NextEpochActiveSmeshers: v.NextEpochActiveSmeshers,
+			NextEpochEffectiveUnits: v.NextEpochEffectiveUnits,
+		}
+	}
+	c.JSON(200, response)
+}
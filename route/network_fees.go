@@ -0,0 +1,176 @@
+package route
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/pkg/apperror"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+// fee estimates are computed from at most this many recent transactions
+const feeEstimateTransactions = 1000
+
+// GetFees serves the fees rollups per layer or per epoch, from and to are layers or
+// epochs, both included. Layers default to the last day of layers.
+func (n *NetworkRoutes) GetFees(c *gin.Context) {
+	granularity := c.DefaultQuery("granularity", database.RollupEpoch)
+	if granularity != database.RollupLayer && granularity != database.RollupEpoch {
+		respondError(c, apperror.New(apperror.InvalidInput, "granularity must be layer or epoch"))
+		return
+	}
+
+	defaultFrom := "0"
+	defaultTo := strconv.Itoa(math.MaxUint32)
+	if granularity == database.RollupLayer {
+		layer := (time.Now().Unix() - config.GenesisEpochSeconds) / config.LayerDuration
+		day := int64(24*time.Hour/time.Second) / config.LayerDuration
+		if layer > day {
+			defaultFrom = strconv.FormatInt(layer-day, 10)
+		}
+	}
+	from, err := strconv.ParseInt(c.DefaultQuery("from", defaultFrom), 10, 64)
+	if err != nil {
+		respondError(c, apperror.New(apperror.InvalidInput, "from must be a valid integer"))
+		return
+	}
+	to, err := strconv.ParseInt(c.DefaultQuery("to", defaultTo), 10, 64)
+	if err != nil {
+		respondError(c, apperror.New(apperror.InvalidInput, "to must be a valid integer"))
+		return
+	}
+
+	times, ok := newTimeFormatter(c, n.networkUtils)
+	if !ok {
+		return
+	}
+
+	rollups, err := n.db.GetFeesRollups(granularity, from, to)
+	if err != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch fees", err))
+		return
+	}
+
+	points := make([]*types.FeesPoint, len(rollups))
+	for i, v := range rollups {
+		start := n.networkUtils.GetLayerTime(uint64(v.Id.Bucket))
+		if granularity == database.RollupEpoch {
+			start = n.networkUtils.GetEpochTime(uint64(v.Id.Bucket))
+		}
+		point := &types.FeesPoint{
+			Bucket:       v.Id.Bucket,
+			Timestamp:    start.Unix(),
+			Time:         times.format(start),
+			Transactions: v.Transactions,
+			GasUsed:      v.Gas,
+			TotalFees:    v.Fees,
+			MedianFee:    v.MedianFee,
+			MinFee:       v.MinFee,
+			MaxFee:       v.MaxFee,
+			MinGasPrice:  v.MinGasPrice,
+			MaxGasPrice:  v.MaxGasPrice,
+		}
+		if v.Transactions > 0 {
+			point.AverageFee = v.Fees / v.Transactions
+		}
+		// fees paid and not rewarded back to smeshers are burned
+		if v.Fees > v.FeesRewarded && granularity == database.RollupEpoch {
+			point.BurnedFees = v.Fees - v.FeesRewarded
+		}
+		points[i] = point
+	}
+
+	c.JSON(200, points)
+}
+
+// GetFeeEstimate suggests gas prices for a transaction of method, spend when not given,
+// from the applied transactions of the last layers. The tiers are the 25th, 50th and
+// 90th percentiles of their gas prices.
+func (n *NetworkRoutes) GetFeeEstimate(c *gin.Context) {
+	method := 16
+	switch strings.ToLower(c.DefaultQuery("method", "spend")) {
+	case "spawn":
+		method = 0
+	case "spend":
+		method = 16
+	case "drainvault":
+		method = 17
+	case "all":
+		method = -1
+	default:
+		respondError(c, apperror.New(apperror.InvalidInput, "method must be spawn, spend, drainvault or all"))
+		return
+	}
+	layers, err := strconv.ParseUint(c.DefaultQuery("layers", "1000"), 10, 32)
+	if err != nil || layers == 0 {
+		respondError(c, apperror.New(apperror.InvalidInput, "layers must be a positive integer"))
+		return
+	}
+	times, ok := newTimeFormatter(c, n.networkUtils)
+	if !ok {
+		return
+	}
+
+	lastLayer, err := n.db.GetLastProcessedLayer()
+	if err != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to get last processed layer", err))
+		return
+	}
+	var fromLayer uint32
+	if uint64(lastLayer.Layer) > layers {
+		fromLayer = uint32(uint64(lastLayer.Layer) - layers)
+	}
+
+	transactions, err := n.db.GetRecentGas(fromLayer, method, feeEstimateTransactions)
+	if err != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch recent transactions", err))
+		return
+	}
+
+	gas := make([]uint64, len(transactions))
+	gasPrices := make([]uint64, len(transactions))
+	for i, v := range transactions {
+		gas[i] = v.Gas
+		gasPrices[i] = v.GasPrice
+	}
+	sort.Slice(gas, func(i, j int) bool { return gas[i] < gas[j] })
+	sort.Slice(gasPrices, func(i, j int) bool { return gasPrices[i] < gasPrices[j] })
+
+	estimate := &types.FeeEstimate{
+		FromLayer:     fromLayer,
+		FromLayerTime: times.layer(uint64(fromLayer)),
+		Transactions:  len(transactions),
+		Gas:           percentile(gas, 50),
+	}
+	tier := func(p int) *types.FeeEstimateTier {
+		// the node rejects transactions below a gas price of 1
+		gasPrice := percentile(gasPrices, p)
+		if gasPrice == 0 {
+			gasPrice = 1
+		}
+		return &types.FeeEstimateTier{GasPrice: gasPrice, Fee: gasPrice * estimate.Gas}
+	}
+	estimate.Slow = tier(25)
+	estimate.Average = tier(50)
+	estimate.Fast = tier(90)
+
+	c.JSON(200, estimate)
+}
+
+// percentile is the nearest rank percentile of sorted values, zero without values.
+func percentile(sorted []uint64, p int) uint64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
@@ -1,21 +1,403 @@
 package route
 
 import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+
 	"github.com/gin-gonic/gin"
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/database"
 	"github.com/swarmbit/spacemesh-state-api/network"
+	"github.com/swarmbit/spacemesh-state-api/pkg/apperror"
+	"github.com/swarmbit/spacemesh-state-api/price"
+	"github.com/swarmbit/spacemesh-state-api/types"
 )
 
 type NetworkRoutes struct {
-	state *network.NetworkState
+	db            database.ReadStore
+	networkUtils  *network.NetworkUtils
+	state         *network.NetworkState
+	calculator    *network.RewardsCalculator
+	priceResolver *price.PriceResolver
 }
 
-func NewNetworkRoutes(state *network.NetworkState) *NetworkRoutes {
+func NewNetworkRoutes(
+	db database.ReadStore,
+	networkUtils *network.NetworkUtils,
+	state *network.NetworkState,
+	calculator *network.RewardsCalculator,
+	priceResolver *price.PriceResolver,
+) *NetworkRoutes {
 	routes := &NetworkRoutes{
-		state: state,
+		db:            db,
+		networkUtils:  networkUtils,
+		state:         state,
+		calculator:    calculator,
+		priceResolver: priceResolver,
 	}
 	return routes
 }
 
 func (n *NetworkRoutes) GetInfo(c *gin.Context) {
-	c.JSON(200, n.state.GetInfo())
+	view := requestState(c, n.state)
+	if err := view.Ready(); err != nil {
+		respondError(c, err)
+		return
+	}
+	times, ok := newTimeFormatter(c, n.networkUtils)
+	if !ok {
+		return
+	}
+	currency, ok := fiatCurrency(c, n.priceResolver)
+	if !ok {
+		return
+	}
+
+	c.JSON(200, n.info(view, times, currency))
+}
+
+// info is the network info of view with the times formatted and the values in currency when set.
+func (n *NetworkRoutes) info(view *network.View, times *timeFormatter, currency string) *types.NetworkInfo {
+	// the cached info is shared between requests so times are set on a copy
+	info := *view.GetInfo()
+	info.EpochStartTime = times.epoch(uint64(info.Epoch))
+	info.LayerTime = times.layer(info.Layer)
+	if info.Supply != nil {
+		supply := *info.Supply
+		supply.LayerTime = times.layer(supply.Layer)
+		info.Supply = &supply
+	}
+	if info.NextEpoch != nil {
+		nextEpoch := *info.NextEpoch
+		nextEpoch.StartTime = times.epoch(uint64(nextEpoch.Epoch))
+		nextEpoch.RegistrationDeadlineTime = times.unix(nextEpoch.RegistrationDeadline)
+		nextEpoch.SecondsToDeadline = max(nextEpoch.RegistrationDeadline-time.Now().Unix(), 0)
+		info.NextEpoch = &nextEpoch
+	}
+	if currency != "" {
+		info.Currency = currency
+		info.FiatPrice = n.priceResolver.GetPriceIn(currency)
+		if info.FiatPrice > -1 {
+			info.FiatMarketCap = uint64(float64(info.CirculatingSupply) * info.FiatPrice)
+		}
+	}
+	return &info
+}
+
+func (n *NetworkRoutes) GetSupply(c *gin.Context) {
+	times, ok := newTimeFormatter(c, n.networkUtils)
+	if !ok {
+		return
+	}
+
+	supply := *requestState(c, n.state).GetSupply()
+	supply.LayerTime = times.layer(supply.Layer)
+	c.JSON(200, &supply)
+}
+
+// supply is recomputed by the network state every minute, so caches can hold it that long
+const supplyCacheControl = "public, max-age=60"
+
+func (n *NetworkRoutes) GetCirculatingSupply(c *gin.Context) {
+	c.String(200, network.ToSmesh(requestState(c, n.state).GetSupply().CirculatingSupply))
+}
+
+func (n *NetworkRoutes) GetTotalSupply(c *gin.Context) {
+	c.String(200, network.ToSmesh(requestState(c, n.state).GetSupply().TotalSupply))
+}
+
+// parameters only change with the network config, caches can hold them for an hour
+const parametersCacheControl = "public, max-age=3600"
+
+func (n *NetworkRoutes) GetParameters(c *gin.Context) {
+	times, ok := newTimeFormatter(c, n.networkUtils)
+	if !ok {
+		return
+	}
+	parameters := n.networkUtils.GetParameters()
+	parameters.GenesisTime = times.unix(parameters.GenesisTimestamp)
+	c.JSON(200, parameters)
+}
+
+func (n *NetworkRoutes) GetReorgs(c *gin.Context) {
+	offsetStr := c.DefaultQuery("offset", "0")
+	limitStr := c.DefaultQuery("limit", "20")
+	sortStr := c.DefaultQuery("sort", "desc")
+
+	offset, err := strconv.Atoi(offsetStr)
+	if err != nil {
+		respondError(c, apperror.New(apperror.InvalidInput, "offset must be a valid integer"))
+		return
+	}
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil {
+		respondError(c, apperror.New(apperror.InvalidInput, "limit must be a valid integer"))
+		return
+	}
+
+	if offset < 0 || limit < 0 {
+		respondError(c, apperror.New(apperror.InvalidInput, "offset and limit must be greater or equal to 0"))
+		return
+	}
+
+	var sort int8
+	if sortStr == "asc" {
+		sort = 1
+	} else {
+		sort = -1
+	}
+
+	times, ok := newTimeFormatter(c, n.networkUtils)
+	if !ok {
+		return
+	}
+
+	reorgs, errReorgs := n.db.GetReorgs(int64(offset), int64(limit), sort)
+	count, errCount := n.db.CountReorgs()
+
+	if errReorgs != nil || errCount != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch reorgs", errors.Join(errReorgs, errCount)))
+		return
+	}
+
+	reorgsResponse := make([]*types.Reorg, len(reorgs))
+	for i, v := range reorgs {
+		reorgsResponse[i] = &types.Reorg{
+			TriggerLayer:      v.TriggerLayer,
+			TriggerLayerTime:  times.layer(uint64(v.TriggerLayer)),
+			LastAppliedLayer:  v.LastAppliedLayer,
+			Depth:             v.Depth,
+			AffectedDocuments: v.AffectedDocuments,
+			Time:              times.unix(v.Timestamp),
+			Timestamp:         v.Timestamp,
+		}
+	}
+
+	c.Header("total", strconv.FormatInt(count, 10))
+	c.JSON(200, reorgsResponse)
+}
+
+func (n *NetworkRoutes) GetEstimatedRewards(c *gin.Context) {
+	numUnitsStr := c.Query("numUnits")
+	epochsStr := c.DefaultQuery("epochs", "1")
+
+	numUnits, err := strconv.ParseUint(numUnitsStr, 10, 64)
+	if err != nil || numUnits == 0 {
+		respondError(c, apperror.New(apperror.InvalidInput, "numUnits must be a valid integer greater than 0"))
+		return
+	}
+
+	epochs, err := strconv.Atoi(epochsStr)
+	if err != nil || epochs < 1 || epochs > 100 {
+		respondError(c, apperror.New(apperror.InvalidInput, "epochs must be a valid integer between 1 and 100"))
+		return
+	}
+
+	times, ok := newTimeFormatter(c, n.networkUtils)
+	if !ok {
+		return
+	}
+
+	networkInfo := requestState(c, n.state).GetInfo()
+	if networkInfo.TotalWeight == 0 {
+		respondError(c, apperror.New(apperror.Unavailable, "Network totals not available yet"))
+		return
+	}
+
+	estimated, err := n.calculator.EstimateRewards(
+		numUnits,
+		networkInfo.TotalWeight,
+		networkInfo.EffectiveUnitsCommited,
+		networkInfo.Epoch,
+		epochs,
+	)
+	if err != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to estimate rewards", err))
+		return
+	}
+
+	for _, v := range estimated.Epochs {
+		v.StartTime = times.epoch(uint64(v.Epoch))
+	}
+	c.JSON(200, estimated)
+}
+
+var priceResolutions = map[string]time.Duration{
+	"5m":  5 * time.Minute,
+	"15m": 15 * time.Minute,
+	"1h":  time.Hour,
+	"4h":  4 * time.Hour,
+	"1d":  24 * time.Hour,
+	"1w":  7 * 24 * time.Hour,
+}
+
+func (n *NetworkRoutes) GetRewardsChart(c *gin.Context) {
+	rewardsChart(c, n.db, n.networkUtils, "")
+}
+
+func (n *NetworkRoutes) GetPriceHistory(c *gin.Context) {
+	now := time.Now()
+	fromStr := c.DefaultQuery("from", strconv.FormatInt(now.Add(-30*24*time.Hour).Unix(), 10))
+	toStr := c.DefaultQuery("to", strconv.FormatInt(now.Unix(), 10))
+	resolutionStr := c.DefaultQuery("resolution", "1h")
+
+	from, err := strconv.ParseInt(fromStr, 10, 64)
+	if err != nil {
+		respondError(c, apperror.New(apperror.InvalidInput, "from must be a valid integer"))
+		return
+	}
+	to, err := strconv.ParseInt(toStr, 10, 64)
+	if err != nil {
+		respondError(c, apperror.New(apperror.InvalidInput, "to must be a valid integer"))
+		return
+	}
+	resolution, exists := priceResolutions[resolutionStr]
+	if !exists {
+		respondError(c, apperror.New(apperror.InvalidInput, "resolution must be one of 5m, 15m, 1h, 4h, 1d or 1w"))
+		return
+	}
+
+	buckets, err := n.db.GetPriceHistory(time.Unix(from, 0), time.Unix(to, 0), resolution)
+	if err != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch price history", err))
+		return
+	}
+
+	pricesResponse := make([]*types.PricePoint, len(buckets))
+	for i, v := range buckets {
+		pricesResponse[i] = &types.PricePoint{
+			Timestamp: v.Bucket / 1000,
+			USDPrice:  v.USDPrice,
+			Min:       v.Min,
+			Max:       v.Max,
+			Samples:   v.Samples,
+		}
+	}
+
+	c.JSON(200, pricesResponse)
+}
+
+func (n *NetworkRoutes) GetPriceAt(c *gin.Context) {
+	timestamp, err := strconv.ParseInt(c.Query("timestamp"), 10, 64)
+	if err != nil {
+		respondError(c, apperror.New(apperror.InvalidInput, "timestamp must be a valid integer"))
+		return
+	}
+
+	price, err := n.db.GetPriceAt(time.Unix(timestamp, 0))
+	if err != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch price", err))
+		return
+	}
+	if price == nil {
+		respondError(c, apperror.New(apperror.NotFound, "No price recorded before timestamp"))
+		return
+	}
+
+	c.JSON(200, &types.PricePoint{
+		Timestamp: price.Timestamp.Unix(),
+		USDPrice:  price.USDPrice,
+		Min:       price.USDPrice,
+		Max:       price.USDPrice,
+		Samples:   1,
+	})
+}
+
+// GetHighestAtx returns the atx the strategy picks from the atxs published in epoch, the
+// configured strategy unless strategy or rank are given, and the highest limit atxs of
+// the epoch with the nodes that are malfeasant.
+func (n *NetworkRoutes) GetHighestAtx(c *gin.Context) {
+	view := requestState(c, n.state)
+	if err := view.Ready(); err != nil {
+		respondError(c, err)
+		return
+	}
+	// the atxs published in the previous epoch are the ones of the current epoch
+	defaultEpoch := uint64(max(view.GetInfo().Epoch, 1) - 1)
+	epoch, err := strconv.ParseUint(c.DefaultQuery("epoch", strconv.FormatUint(defaultEpoch, 10)), 10, 32)
+	if err != nil {
+		respondError(c, apperror.New(apperror.InvalidInput, "epoch must be a valid epoch"))
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit < 1 || limit > config.MaxHighestAtxRank {
+		respondError(c, apperror.New(apperror.InvalidInput, "limit must be between 1 and "+strconv.Itoa(config.MaxHighestAtxRank)))
+		return
+	}
+	strategy := n.state.HighestAtxStrategy()
+	if c.Query("strategy") != "" || c.Query("rank") != "" {
+		rank, err := strconv.Atoi(c.DefaultQuery("rank", "1"))
+		if err != nil {
+			respondError(c, apperror.New(apperror.InvalidInput, "rank must be a valid integer"))
+			return
+		}
+		strategy, err = network.NewHighestAtxStrategy(c.Query("strategy"), rank)
+		if err != nil {
+			respondError(c, apperror.New(apperror.InvalidInput, err.Error()))
+			return
+		}
+	}
+
+	selected, err := n.state.HighestAtx(epoch, strategy)
+	if err != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch highest atx", err))
+		return
+	}
+	candidates, err := n.db.GetHighestAtxs(epoch, nil, int64(limit))
+	if err != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch highest atxs", err))
+		return
+	}
+	nodeIds := make([]string, 0, len(candidates)+1)
+	for _, v := range candidates {
+		nodeIds = append(nodeIds, v.NodeID)
+	}
+	if selected.AtxID != "" {
+		nodeIds = append(nodeIds, selected.NodeID)
+	}
+	malfeasantNodes, err := n.db.FilterMalfeasanceNodes(nodeIds)
+	if err != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch malfeasant nodes", err))
+		return
+	}
+	malfeasant := make(map[string]bool, len(malfeasantNodes))
+	for _, v := range malfeasantNodes {
+		malfeasant[v] = true
+	}
+
+	response := &types.HighestAtx{
+		Epoch:      uint32(epoch),
+		Strategy:   strategy.Name,
+		Rank:       strategy.Rank,
+		Candidates: make([]*types.HighestAtxCandidate, len(candidates)),
+	}
+	if selected.AtxID != "" {
+		response.Selected = highestAtxCandidate(strategy.Rank, selected, malfeasant[selected.NodeID])
+	}
+	for i, v := range candidates {
+		response.Candidates[i] = highestAtxCandidate(i+1, v, malfeasant[v.NodeID])
+	}
+	c.JSON(200, response)
+}
+
+func highestAtxCandidate(rank int, atx *types.AtxDoc, malfeasant bool) *types.HighestAtxCandidate {
+	var atxBase64 string
+	if bytes, err := hex.DecodeString(atx.AtxID); err == nil {
+		atxBase64 = base64.StdEncoding.EncodeToString(bytes)
+	}
+	return &types.HighestAtxCandidate{
+		Rank:       rank,
+		AtxHex:     atx.AtxID,
+		AtxBase64:  atxBase64,
+		NodeId:     atx.NodeID,
+		Coinbase:   atx.Coinbase,
+		BaseTick:   atx.BaseTick,
+		TickCount:  atx.TickCount,
+		TickHeight: atx.BaseTick + atx.TickCount,
+		Weight:     atx.Weight,
+		Malfeasant: malfeasant,
+	}
 }
@@ -1,24 +1,24 @@
 package route
 
 import (
-	"fmt"
-	"net/http"
+	"errors"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/swarmbit/spacemesh-state-api/config"
 	"github.com/swarmbit/spacemesh-state-api/database"
 	"github.com/swarmbit/spacemesh-state-api/network"
+	"github.com/swarmbit/spacemesh-state-api/pkg/apperror"
 	"github.com/swarmbit/spacemesh-state-api/types"
 )
 
 type NodesRoutes struct {
-	db           *database.ReadDB
+	db           database.ReadStore
 	networkUtils *network.NetworkUtils
 	state        *network.NetworkState
 }
 
-func NewNodeRoutes(db *database.ReadDB, networkUtils *network.NetworkUtils, state *network.NetworkState) *NodesRoutes {
+func NewNodeRoutes(db database.ReadStore, networkUtils *network.NetworkUtils, state *network.NetworkState) *NodesRoutes {
 	return &NodesRoutes{
 		db:           db,
 		networkUtils: networkUtils,
@@ -32,23 +32,17 @@ func (n *NodesRoutes) GetNodes(c *gin.Context) {
 
 	offset, err := strconv.Atoi(offsetStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "offset must be a valid integer",
-		})
+		respondError(c, apperror.New(apperror.InvalidInput, "offset must be a valid integer"))
 		return
 	}
 	limit, err := strconv.Atoi(limitStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "limit must be a valid integer",
-		})
+		respondError(c, apperror.New(apperror.InvalidInput, "limit must be a valid integer"))
 		return
 	}
 
 	if offset < 0 || limit < 0 {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "offset and limit must be greater or equal to 0",
-		})
+		respondError(c, apperror.New(apperror.InvalidInput, "offset and limit must be greater or equal to 0"))
 		return
 	}
 
@@ -56,10 +50,7 @@ func (n *NodesRoutes) GetNodes(c *gin.Context) {
 	count, errCount := n.db.CountNodes()
 
 	if errRewards != nil || errCount != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"status": "Internal Error",
-			"error":  "Failed to fetch transactions for layer",
-		})
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch transactions for layer", errors.Join(errRewards, errCount)))
 	} else if nodes != nil {
 
 		c.Header("total", strconv.FormatInt(count, 10))
@@ -75,17 +66,11 @@ func (n *NodesRoutes) GetNode(c *gin.Context) {
 	nodeId := c.Param("nodeId")
 	node, err := n.db.GetNode(nodeId)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"status": "Internal Error",
-			"error":  "Failed to fetch node",
-		})
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch node", err))
 		return
 	}
 	if node.ID == "" {
-		c.JSON(http.StatusNotFound, gin.H{
-			"status": "Not Found",
-			"error":  "Node not found",
-		})
+		respondError(c, apperror.New(apperror.NotFound, "Node not found"))
 		return
 	}
 
@@ -99,23 +84,17 @@ func (n *NodesRoutes) GetNodeRewards(c *gin.Context) {
 
 	offset, err := strconv.Atoi(offsetStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "offset must be a valid integer",
-		})
+		respondError(c, apperror.New(apperror.InvalidInput, "offset must be a valid integer"))
 		return
 	}
 	limit, err := strconv.Atoi(limitStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "limit must be a valid integer",
-		})
+		respondError(c, apperror.New(apperror.InvalidInput, "limit must be a valid integer"))
 		return
 	}
 
 	if offset < 0 || limit < 0 {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "offset and limit must be greater or equal to 0",
-		})
+		respondError(c, apperror.New(apperror.InvalidInput, "offset and limit must be greater or equal to 0"))
 		return
 	}
 
@@ -126,30 +105,53 @@ func (n *NodesRoutes) GetNodeRewards(c *gin.Context) {
 		sort = 1
 	}
 
+	times, ok := newTimeFormatter(c, n.networkUtils)
+	if !ok {
+		return
+	}
+
 	nodeId := c.Param("nodeId")
+	if format := exportFormat(c); format != "" {
+		writer := newExportWriter(c, format, nodeId+"-rewards")
+		writer.Close(n.db.StreamNodeRewards(nodeId, sort, func(v *types.RewardsDoc) error {
+			return writer.Write(toReward(v, times))
+		}))
+		return
+	}
+
+	page, ok := pageCursor(c, sort)
+	if !ok {
+		return
+	}
+	if page != nil {
+		rewards, err := n.db.GetNodeRewardsAfter(nodeId, page.after, int64(limit), page.sort)
+		if err != nil {
+			respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch rewards for node", err))
+			return
+		}
+		rewardsResponse := make([]*types.Reward, len(rewards))
+		for i, v := range rewards {
+			rewardsResponse[i] = toListedReward(v, times)
+		}
+		if len(rewards) > 0 {
+			last := rewards[len(rewards)-1]
+			page.next(c, limit, len(rewards), last.Layer, last.Id)
+		}
+		c.JSON(200, rewardsResponse)
+		return
+	}
+
 	rewards, errRewards := n.db.GetNodeRewards(nodeId, int64(offset), int64(limit), sort)
 	count, errCount := n.db.CountNodeRewards(nodeId)
 
 	if errRewards != nil || errCount != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"status": "Internal Error",
-			"error":  "Failed to fetch rewards for node",
-		})
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch rewards for node", errors.Join(errRewards, errCount)))
 	} else if rewards != nil {
 
 		rewardsResponse := make([]*types.Reward, len(rewards))
 
 		for i, v := range rewards {
-			rewardsResponse[i] = &types.Reward{
-				Rewards: int64(v.TotalReward),
-				// legacy
-				RewardsDisplay: "",
-				Layer:          v.Layer,
-				SmesherId:      v.NodeId,
-				// legacy
-				Time:      "2023-09-05T00:00:00Z",
-				Timestamp: config.GenesisEpochSeconds + (v.Layer * config.LayerDuration),
-			}
+			rewardsResponse[i] = toListedReward(v, times)
 		}
 
 		c.Header("total", strconv.FormatInt(count, 10))
@@ -163,38 +165,27 @@ func (n *NodesRoutes) GetNodeRewards(c *gin.Context) {
 func (n *NodesRoutes) GetNodeRewardsDetails(c *gin.Context) {
 	nodeId := c.Param("nodeId")
 
-	networkInfo := n.state.GetInfo()
+	networkInfo := requestState(c, n.state).GetInfo()
 	epoch := networkInfo.Epoch
 
-	firstLayer := uint32(epoch * config.LayersPerEpoch)
+	firstLayer := uint32(epoch) * config.LayersPerEpoch
 	lastLayer := firstLayer + config.LayersPerEpoch
 
 	countEpochResult, err := n.db.CountNodeRewardsLayers(nodeId, firstLayer, lastLayer)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"status": "Internal Error",
-			"error":  "Failed to get epoch rewards count",
-		})
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to get epoch rewards count", err))
 		return
 	}
 
 	sumEpochResult, err := n.db.SumNodeRewardsLayers(nodeId, firstLayer, lastLayer)
 	if err != nil {
-		fmt.Println(err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"status": "Internal Error",
-			"error":  "Failed to get epoch rewards sum",
-		})
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to get epoch rewards sum", err))
 		return
 	}
 
 	total, err := n.db.SumNodeRewardsLayers(nodeId, 0, uint32(networkInfo.Layer))
 	if err != nil {
-		fmt.Println(err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"status": "Internal Error",
-			"error":  "Failed to get epoch rewards sum",
-		})
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to get epoch rewards sum", err))
 		return
 	}
 
@@ -208,7 +199,7 @@ func (n *NodesRoutes) GetNodeRewardsDetails(c *gin.Context) {
 
 func (n *NodesRoutes) GetEligibility(c *gin.Context) {
 
-	networkInfo := n.state.GetInfo()
+	networkInfo := requestState(c, n.state).GetInfo()
 
 	nodeId := c.Param("nodeId")
 
@@ -216,19 +207,13 @@ func (n *NodesRoutes) GetEligibility(c *gin.Context) {
 
 	nodeAtx, err := n.db.GetAtxWeightNode(nodeId, uint64(epoch-1))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"status": "Internal Error",
-			"error":  "Failed to get node weight",
-		})
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to get node weight", err))
 		return
 	}
 
 	eligibilityCount, err := n.networkUtils.GetNumberOfSlots(uint64(nodeAtx.TotalWeight), networkInfo.TotalWeight, epoch)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"status": "Internal Error",
-			"error":  "Failed to get eligibility",
-		})
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to get eligibility", err))
 		return
 	}
 
@@ -246,3 +231,134 @@ func (n *NodesRoutes) GetEligibility(c *gin.Context) {
 		PredictedRewards:  predictedRewards,
 	})
 }
+
+func (n *NodesRoutes) GetSmesherEligibility(c *gin.Context) {
+	times, ok := newTimeFormatter(c, n.networkUtils)
+	if !ok {
+		return
+	}
+
+	nodeId := c.Param("nodeId")
+	view := requestState(c, n.state)
+	epoch := view.GetInfo().Epoch
+
+	current, err := n.getEpochEligibility(view, nodeId, epoch)
+	if err != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to get current epoch eligibility", err))
+		return
+	}
+
+	// atxs for the next epoch are still arriving so its totals are a lower bound
+	next, err := n.getEpochEligibility(view, nodeId, epoch+1)
+	if err != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to get next epoch eligibility", err))
+		return
+	}
+
+	current.StartTime = times.epoch(uint64(current.Epoch))
+	next.StartTime = times.epoch(uint64(next.Epoch))
+
+	malfeasant, err := malfeasantNodes(n.db, []string{nodeId})
+	if err != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to get node malfeasance", err))
+		return
+	}
+
+	c.JSON(200, &types.SmesherEligibility{
+		NodeId:       nodeId,
+		Malfeasant:   malfeasant[nodeId],
+		CurrentEpoch: current,
+		NextEpoch:    next,
+	})
+}
+
+func (n *NodesRoutes) getEpochEligibility(view *network.View, nodeId string, epoch uint32) (*types.EpochEligibility, error) {
+	if epoch == 0 {
+		// no atx targets the genesis epoch
+		return &types.EpochEligibility{
+			Epoch:        epoch,
+			Count:        -1,
+			EpochSubsidy: view.GetEpochSubsidy(epoch),
+		}, nil
+	}
+
+	nodeAtx, err := n.db.GetAtxWeightNode(nodeId, uint64(epoch-1))
+	if err != nil {
+		return nil, err
+	}
+
+	epochAtx, err := n.db.GetAtxEpoch(uint64(epoch - 1))
+	if err != nil {
+		return nil, err
+	}
+
+	eligibility := &types.EpochEligibility{
+		Epoch:             epoch,
+		Count:             -1,
+		EffectiveNumUnits: nodeAtx.TotalEffectiveNumUnits,
+		Weight:            nodeAtx.TotalWeight,
+		TotalWeight:       epochAtx.TotalWeight,
+		EpochSubsidy:      view.GetEpochSubsidy(epoch),
+	}
+
+	if nodeAtx.TotalWeight == 0 || epochAtx.TotalWeight == 0 {
+		return eligibility, nil
+	}
+
+	count, err := n.networkUtils.GetNumberOfSlots(uint64(nodeAtx.TotalWeight), epochAtx.TotalWeight, epoch)
+	if err != nil {
+		return nil, err
+	}
+
+	unitReward := eligibility.EpochSubsidy / epochAtx.TotalWeight
+	eligibility.Count = count
+	eligibility.PredictedRewards = unitReward * uint64(nodeAtx.TotalWeight)
+	return eligibility, nil
+}
+
+// GetSmesherPerformance returns the expected and received rewards of the smesher per
+// epoch, latest first. The score is the share of the expected rewards received.
+func (n *NodesRoutes) GetSmesherPerformance(c *gin.Context) {
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil {
+		respondError(c, apperror.New(apperror.InvalidInput, "offset must be a valid integer"))
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil {
+		respondError(c, apperror.New(apperror.InvalidInput, "limit must be a valid integer"))
+		return
+	}
+	if offset < 0 || limit < 0 {
+		respondError(c, apperror.New(apperror.InvalidInput, "offset and limit must be greater or equal to 0"))
+		return
+	}
+
+	nodeId := c.Param("nodeId")
+	performance, errPerformance := n.db.GetSmesherPerformance(nodeId, int64(offset), int64(limit))
+	count, errCount := n.db.CountSmesherPerformance(nodeId)
+	if errPerformance != nil || errCount != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch smesher performance", errors.Join(errPerformance, errCount)))
+		return
+	}
+
+	response := make([]*types.SmesherPerformance, len(performance))
+	for i, v := range performance {
+		score := 1.0
+		if v.ExpectedRewards > 0 {
+			score = float64(v.RewardsCount) / float64(v.ExpectedRewards)
+		}
+		response[i] = &types.SmesherPerformance{
+			Epoch:           v.Id.Epoch,
+			Slots:           v.Slots,
+			ExpectedRewards: v.ExpectedRewards,
+			RewardsCount:    v.RewardsCount,
+			Rewards:         v.Rewards,
+			Missed:          max(v.ExpectedRewards-v.RewardsCount, 0),
+			Score:           score,
+			Complete:        v.Complete,
+		}
+	}
+	c.Header("total", strconv.FormatInt(count, 10))
+	c.JSON(200, response)
+}
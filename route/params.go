@@ -0,0 +1,32 @@
+package route
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/swarmbit/spacemesh-state-api/pkg/address"
+	"github.com/swarmbit/spacemesh-state-api/pkg/apperror"
+)
+
+// normalizeParams validates the address and node id path parameters of every route and
+// replaces them with their stored form, so lookups do not miss on casing or a 0x prefix.
+func normalizeParams() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for i, param := range c.Params {
+			var value string
+			var err error
+			switch param.Key {
+			case "accountAddress", "address":
+				value, err = address.NormalizeAddress(param.Value)
+			case "nodeId":
+				value, err = address.NormalizeNodeId(param.Value)
+			default:
+				continue
+			}
+			if err != nil {
+				respondError(c, apperror.New(apperror.InvalidInput, err.Error()))
+				return
+			}
+			c.Params[i].Value = value
+		}
+		c.Next()
+	}
+}
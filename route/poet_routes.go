@@ -1,21 +1,175 @@
 package route
 
 import (
+	"time"
+
 	"github.com/gin-gonic/gin"
 	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/network"
+	"github.com/swarmbit/spacemesh-state-api/pkg/apperror"
+	"github.com/swarmbit/spacemesh-state-api/types"
 )
 
 type PoetRoutes struct {
-	configValues *config.Config
+	db           database.ReadStore
+	reloader     *config.Reloader
+	networkUtils *network.NetworkUtils
 }
 
-func NewPoetRoutes(configValues *config.Config) *PoetRoutes {
+func NewPoetRoutes(db database.ReadStore, reloader *config.Reloader, networkUtils *network.NetworkUtils) *PoetRoutes {
 	routes := &PoetRoutes{
-		configValues: configValues,
+		db:           db,
+		reloader:     reloader,
+		networkUtils: networkUtils,
 	}
 	return routes
 }
 
+// GetPoets serves the poets of the last loaded config, they change on reload.
 func (p *PoetRoutes) GetPoets(c *gin.Context) {
-	c.JSON(200, p.configValues.Poets)
+	c.JSON(200, p.reloader.Current().Poets)
+}
+
+// GetPoetsStatus serves the configured poets with the round each one runs now, the
+// time left until its registration opens and closes and the last health check.
+// Registrations per poet are not served, the atx events of the node do not include the
+// poet proof.
+func (p *PoetRoutes) GetPoetsStatus(c *gin.Context) {
+	times, ok := newTimeFormatter(c, p.networkUtils)
+	if !ok {
+		return
+	}
+	stored, err := p.db.GetPoetsHealth()
+	if err != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to get poets health", err))
+		return
+	}
+	health := make(map[string]*types.PoetHealthDoc, len(stored))
+	for _, v := range stored {
+		health[v.Name] = v
+	}
+	now := time.Now()
+	poets := p.reloader.Current().Poets
+	statuses := make([]*types.PoetStatus, 0, len(poets))
+	for _, poet := range poets {
+		status := &types.PoetStatus{
+			Name: poet.Name,
+		}
+		if poet.Info != nil {
+			status.Description = poet.Info.Description
+			status.DiscordLink = poet.Info.DiscordLink
+		}
+		if poet.Settings != nil {
+			status.PhaseShift = poet.Settings.PhaseShift
+			status.CycleGap = poet.Settings.CycleGap
+			round := p.networkUtils.GetPoetRound(poet.Settings.PhaseShift, poet.Settings.CycleGap, now)
+			round.RoundStartTime = times.unix(round.RoundStart)
+			round.RoundEndTime = times.unix(round.RoundEnd)
+			round.RegistrationOpensTime = times.unix(round.RegistrationOpens)
+			round.RegistrationClosesTime = times.unix(round.RegistrationCloses)
+			status.Round = round
+		}
+		if v, exists := health[poet.Name]; exists && v.Address == poet.Address {
+			status.Health = &types.PoetHealth{
+				Up:            v.Up,
+				CurrentRound:  v.CurrentRound,
+				Error:         v.Error,
+				Since:         v.Since.Unix(),
+				SinceTime:     times.unix(v.Since.Unix()),
+				CheckedAt:     v.CheckedAt.Unix(),
+				CheckedAtTime: times.unix(v.CheckedAt.Unix()),
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	c.JSON(200, statuses)
+}
+
+// GetCountdowns serves the time to the next epoch and to the registration windows and
+// cycle gaps of the configured poets, poets without settings are skipped.
+func (p *PoetRoutes) GetCountdowns(c *gin.Context) {
+	times, ok := newTimeFormatter(c, p.networkUtils)
+	if !ok {
+		return
+	}
+	now := time.Now()
+	layer := uint64(max(now.Unix()-config.GenesisEpochSeconds, 0) / config.LayerDuration)
+	epoch := p.networkUtils.GetEpoch(layer).Uint32()
+	c.JSON(200, &types.Countdowns{
+		Now:       now.Unix(),
+		Layer:     layer,
+		LayerTime: times.layer(layer),
+		Epoch:     epoch,
+		NextEpoch: newCountdown(p.networkUtils.GetEpochTime(uint64(epoch+1)).Unix(), now, times),
+		Poets:     p.poetCountdowns(now, times),
+	})
+}
+
+func newCountdown(at int64, now time.Time, times *timeFormatter) *types.Countdown {
+	return &types.Countdown{
+		At:          at,
+		AtTime:      times.unix(at),
+		SecondsLeft: max(at-now.Unix(), 0),
+	}
+}
+
+// poetCountdowns are the registration windows and cycle gaps of the configured poets at
+// now, poets without settings are skipped.
+func (p *PoetRoutes) poetCountdowns(now time.Time, times *timeFormatter) []*types.PoetCountdowns {
+	epochDuration := config.LayerDuration * int64(config.LayersPerEpoch)
+	countdowns := make([]*types.PoetCountdowns, 0)
+	for _, poet := range p.reloader.Current().Poets {
+		if poet.Settings == nil {
+			continue
+		}
+		round := p.networkUtils.GetPoetRound(poet.Settings.PhaseShift, poet.Settings.CycleGap, now)
+		// during the cycle gap the next one starts after the next round
+		cycleGapStart := round.RoundEnd
+		if cycleGapStart <= now.Unix() {
+			cycleGapStart += epochDuration
+		}
+		countdowns = append(countdowns, &types.PoetCountdowns{
+			Name:               poet.Name,
+			Phase:              round.Phase,
+			PublishEpoch:       round.PublishEpoch,
+			RegistrationOpens:  newCountdown(round.RegistrationOpens, now, times),
+			RegistrationCloses: newCountdown(round.RegistrationCloses, now, times),
+			CycleGapStart:      newCountdown(cycleGapStart, now, times),
+		})
+	}
+	return countdowns
+}
+
+// GetNodeAtxStatus tells whether the node published its atx in the current epoch, the
+// one targeting the next epoch, next to the registration windows of the configured
+// poets. The registrations of the node are not known, the atx events do not include the
+// poet proof.
+func (p *PoetRoutes) GetNodeAtxStatus(c *gin.Context) {
+	times, ok := newTimeFormatter(c, p.networkUtils)
+	if !ok {
+		return
+	}
+	nodeId := c.Param("nodeId")
+	now := time.Now()
+	layer := uint64(max(now.Unix()-config.GenesisEpochSeconds, 0) / config.LayerDuration)
+	epoch := p.networkUtils.GetEpoch(layer).Uint32()
+	atx, err := p.db.GetPreviousAtx(nodeId, epoch+1)
+	if err != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch atx", err))
+		return
+	}
+
+	status := &types.NodeAtxStatus{
+		NodeId:      nodeId,
+		Epoch:       epoch,
+		TargetEpoch: epoch + 1,
+		Deadline:    newCountdown(p.networkUtils.GetEpochTime(uint64(epoch+1)).Unix(), now, times),
+		Poets:       p.poetCountdowns(now, times),
+	}
+	if atx.AtxID != "" && atx.PublishEpoch == epoch {
+		status.Published = true
+		status.Atx = toAtx(atx, times)
+	}
+	c.JSON(200, status)
 }
@@ -0,0 +1,157 @@
+package route
+
+import (
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/network"
+	"github.com/swarmbit/spacemesh-state-api/pkg/apperror"
+	"github.com/swarmbit/spacemesh-state-api/pool"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+// maxPoolEpochs is the most epochs a payouts request spans.
+const maxPoolEpochs = 100
+
+// PoolRoutes report the payouts of the pool members from the rewards the pool processor
+// saved, with the members and shares of the current config.
+type PoolRoutes struct {
+	db           database.ReadStore
+	reloader     *config.Reloader
+	networkUtils *network.NetworkUtils
+}
+
+func NewPoolRoutes(db database.ReadStore, reloader *config.Reloader, networkUtils *network.NetworkUtils) *PoolRoutes {
+	return &PoolRoutes{
+		db:           db,
+		reloader:     reloader,
+		networkUtils: networkUtils,
+	}
+}
+
+// AddPoolRoutes adds the /pool endpoints behind the pool api keys.
+func AddPoolRoutes(router *gin.Engine, poolRoutes *PoolRoutes, reloader *config.Reloader) {
+	poolGroup := router.Group("/pool", apiKeyAuth(reloader, func(configValues *config.Config) []string {
+		if configValues.Pool == nil {
+			return nil
+		}
+		return configValues.Pool.ApiKeys
+	}))
+
+	poolGroup.GET("/payouts", poolRoutes.GetPayouts)
+	poolGroup.GET("/payouts/:epoch", poolRoutes.GetEpochPayout)
+
+	log.Println("Added pool routes")
+}
+
+func (p *PoolRoutes) poolConfig() *config.PoolConfig {
+	if poolConfig := p.reloader.Current().Pool; poolConfig != nil {
+		return poolConfig
+	}
+	return &config.PoolConfig{}
+}
+
+// withTimes sets the start and end times of the epoch of the report.
+func withTimes(report *types.PoolPayoutReport, times *timeFormatter) *types.PoolPayoutReport {
+	report.StartTime = times.layer(uint64(report.FirstLayer))
+	report.EndTime = times.layer(uint64(report.LastLayer) + 1)
+	return report
+}
+
+// GetPayouts returns the payouts of the epochs between fromEpoch and toEpoch included
+// that have rewards of the members, the last 10 epochs when not given. With an export
+// Accept header it streams one row per member and epoch.
+func (p *PoolRoutes) GetPayouts(c *gin.Context) {
+	currentLayer := (time.Now().Unix() - config.GenesisEpochSeconds) / config.LayerDuration
+	currentEpoch := max(currentLayer, 0) / int64(config.LayersPerEpoch)
+	toEpoch, err := strconv.ParseInt(c.DefaultQuery("toEpoch", strconv.FormatInt(currentEpoch, 10)), 10, 64)
+	if err != nil || toEpoch < 0 {
+		respondError(c, apperror.New(apperror.InvalidInput, "toEpoch must be a positive integer"))
+		return
+	}
+	fromEpoch, err := strconv.ParseInt(c.DefaultQuery("fromEpoch", strconv.FormatInt(max(toEpoch-9, 0), 10)), 10, 64)
+	if err != nil || fromEpoch < 0 {
+		respondError(c, apperror.New(apperror.InvalidInput, "fromEpoch must be a positive integer"))
+		return
+	}
+	if fromEpoch > toEpoch {
+		respondError(c, apperror.New(apperror.InvalidInput, "toEpoch must be greater or equal to fromEpoch"))
+		return
+	}
+	if toEpoch-fromEpoch >= maxPoolEpochs {
+		respondError(c, apperror.New(apperror.InvalidInput, "payouts are limited to "+strconv.Itoa(maxPoolEpochs)+" epochs"))
+		return
+	}
+	times, ok := newTimeFormatter(c, p.networkUtils)
+	if !ok {
+		return
+	}
+
+	reports, err := pool.Payouts(p.db, p.poolConfig(), uint32(fromEpoch), uint32(toEpoch))
+	if err != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch pool payouts", err))
+		return
+	}
+	if format := exportFormat(c); format != "" {
+		writePayouts(c, format, "pool-payouts-"+strconv.FormatInt(fromEpoch, 10)+"-"+strconv.FormatInt(toEpoch, 10), reports)
+		return
+	}
+	for _, report := range reports {
+		withTimes(report, times)
+	}
+	c.JSON(200, reports)
+}
+
+// GetEpochPayout returns the payout of the epoch, with every member and no rewards when
+// none were saved. With an export Accept header it streams one row per member.
+func (p *PoolRoutes) GetEpochPayout(c *gin.Context) {
+	epoch, err := strconv.ParseUint(c.Param("epoch"), 10, 32)
+	if err != nil {
+		respondError(c, apperror.New(apperror.InvalidInput, "epoch must be a positive integer"))
+		return
+	}
+	times, ok := newTimeFormatter(c, p.networkUtils)
+	if !ok {
+		return
+	}
+	report, err := pool.Payout(p.db, p.poolConfig(), uint32(epoch))
+	if err != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch pool payout", err))
+		return
+	}
+	if format := exportFormat(c); format != "" {
+		writePayouts(c, format, "pool-payouts-"+c.Param("epoch"), []*types.PoolPayoutReport{report})
+		return
+	}
+	c.JSON(200, withTimes(report, times))
+}
+
+func writePayouts(c *gin.Context, format string, filename string, reports []*types.PoolPayoutReport) {
+	writer := newExportWriter(c, format, filename)
+	var err error
+	for _, report := range reports {
+		for _, member := range report.Members {
+			err = writer.Write(&types.PoolPayoutExport{
+				Epoch:     member.Epoch,
+				Member:    member.Name,
+				NodeIds:   strings.Join(member.NodeIds, " "),
+				Share:     member.Share,
+				Rewards:   member.Rewards,
+				EarnedSMH: network.ToSmesh(uint64(member.Earned)),
+				PayoutSMH: network.ToSmesh(uint64(member.Payout)),
+			})
+			if err != nil {
+				break
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	writer.Close(err)
+}
@@ -5,119 +5,346 @@ import (
 	"github.com/swarmbit/spacemesh-state-api/config"
 	"github.com/swarmbit/spacemesh-state-api/database"
 	"github.com/swarmbit/spacemesh-state-api/network"
+	"github.com/swarmbit/spacemesh-state-api/node"
 	"github.com/swarmbit/spacemesh-state-api/price"
 	"log"
 )
 
-func AddRoutes(readDB *database.ReadDB, router *gin.Engine, priceResolver *price.PriceResolver, configValues *config.Config) {
-	networkUtils := network.NewNetworkUtils()
-	log.Println("Created network utils")
-	state := network.NewNetworkState(readDB, networkUtils, priceResolver)
-	log.Println("Created state")
-	accountRoutes := NewAccountRoutes(readDB, networkUtils, state, priceResolver)
-	networkRoutes := NewNetworkRoutes(state)
-	poetRoutes := NewPoetRoutes(configValues)
-	nodeRoutes := NewNodeRoutes(readDB, networkUtils, state)
-	epochRoutes := NewEpochRoutes(readDB, networkUtils, state)
-	layersRoutes := NewLayersRoutes(readDB, networkUtils, state)
-	transactionRoutes := NewTransactionRoutes(readDB, networkUtils, state)
+// apiRoutes are the handlers of the api, shared by its versions.
+type apiRoutes struct {
+	account     *AccountRoutes
+	network     *NetworkRoutes
+	poet        *PoetRoutes
+	node        *NodesRoutes
+	epoch       *EpochRoutes
+	layers      *LayersRoutes
+	transaction *TransactionRoutes
+	smeshers    *SmeshersRoutes
+	atx         *AtxRoutes
+	malfeasance *MalfeasanceRoutes
+	signature   *SignatureRoutes
+	sync        *SyncRoutes
+	stats       *StatsRoutes
+	search      *SearchRoutes
+	dashboard   *DashboardRoutes
+	utils       *UtilsRoutes
+}
+
+func AddRoutes(readDB database.ReadStore, router *gin.Engine, priceResolver *price.PriceResolver, networkUtils *network.NetworkUtils, state *network.NetworkState, reloader *config.Reloader, nodeClient *node.NodeClient) {
+	networkRoutes := NewNetworkRoutes(readDB, networkUtils, state, network.NewRewardsCalculator(networkUtils), priceResolver)
+	routes := &apiRoutes{
+		account:     NewAccountRoutes(readDB, networkUtils, state, priceResolver, reloader),
+		network:     networkRoutes,
+		poet:        NewPoetRoutes(readDB, reloader, networkUtils),
+		node:        NewNodeRoutes(readDB, networkUtils, state),
+		epoch:       NewEpochRoutes(readDB, networkUtils, state),
+		layers:      NewLayersRoutes(readDB, networkUtils, state),
+		transaction: NewTransactionRoutes(readDB, networkUtils, state, nodeClient, reloader),
+		smeshers:    NewSmeshersRoutes(readDB, networkUtils, state),
+		atx:         NewAtxRoutes(readDB, networkUtils),
+		malfeasance: NewMalfeasanceRoutes(readDB, networkUtils),
+		signature:   NewSignatureRoutes(),
+		sync:        NewSyncRoutes(readDB, networkUtils),
+		stats:       NewStatsRoutes(readDB),
+		search:      NewSearchRoutes(readDB, networkUtils),
+		dashboard:   NewDashboardRoutes(readDB, networkUtils, state, priceResolver, networkRoutes),
+		utils:       NewUtilsRoutes(networkUtils),
+	}
+
+	router.Use(normalizeParams())
+	router.Use(listLimits(reloader))
+	router.Use(stateHeaders(state))
+	router.Use(cacheHeaders(reloader, state))
+	router.Use(unitsProfile())
+	router.Use(concurrencyLimits(reloader))
+
+	// the routes without a version prefix are v1, as before the versions
+	addVersionRoutes(router.Group("", versionResponses(reloader, apiV1)), apiV1, routes)
+	for _, version := range config.ApiVersions {
+		addVersionRoutes(router.Group("/"+version, versionResponses(reloader, version)), version, routes)
+	}
+
+	log.Println("Added routes")
+}
+
+// addVersionRoutes adds the routes of version. The handlers write the v1 responses, the
+// routes whose responses differ in v2 register their v2 shapes.
+func addVersionRoutes(router gin.IRoutes, version string, routes *apiRoutes) {
+	v2 := func(shapes ...objectShape) gin.HandlerFunc {
+		return routeShapes(version, apiV2, shapes)
+	}
 
 	router.GET("/account", func(c *gin.Context) {
-		accountRoutes.GetAccounts(c)
+		routes.account.GetAccounts(c)
 	})
 
 	router.POST("/account/group", func(c *gin.Context) {
-		accountRoutes.GetAccountGroup(c)
+		routes.account.GetAccountGroup(c)
+	})
+
+	router.POST("/accounts/batch", func(c *gin.Context) {
+		routes.account.GetAccountsBatch(c)
 	})
 
 	router.GET("/account/post/epoch/:epoch", func(c *gin.Context) {
-		accountRoutes.GetAccountsPost(c)
+		routes.account.GetAccountsPost(c)
+	})
+
+	router.GET("/account/:accountAddress", v2(shapeAt("", accountV2)), func(c *gin.Context) {
+		routes.account.GetAccount(c)
 	})
 
-	router.GET("/account/:accountAddress", func(c *gin.Context) {
-		accountRoutes.GetAccount(c)
+	router.GET("/account/:accountAddress/rewards", v2(shapeAt("[]", rewardV2)), func(c *gin.Context) {
+		routes.account.GetAccountRewards(c)
 	})
 
-	router.GET("/account/:accountAddress/rewards", func(c *gin.Context) {
-		accountRoutes.GetAccountRewards(c)
+	router.GET("/account/:accountAddress/transactions", v2(shapeAt("[]", transactionV2)), func(c *gin.Context) {
+		routes.account.GetAccountTransactions(c)
 	})
 
-	router.GET("/account/:accountAddress/transactions", func(c *gin.Context) {
-		accountRoutes.GetAccountTransactions(c)
+	router.GET("/account/:accountAddress/transactions/pending", v2(shapeAt("[]", transactionV2)), func(c *gin.Context) {
+		routes.account.GetAccountPendingTransactions(c)
 	})
 
+	router.GET("/account/:accountAddress/vesting", func(c *gin.Context) {
+		routes.account.GetAccountVesting(c)
+	})
+
+	router.GET("/account/:accountAddress/multisig", func(c *gin.Context) {
+		routes.account.GetAccountMultisig(c)
+	})
+
+	router.GET("/account/:accountAddress/balance/history", func(c *gin.Context) {
+		routes.account.GetAccountBalanceHistory(c)
+	})
+
+	router.GET("/account/:accountAddress/rewards/export", func(c *gin.Context) {
+		routes.account.ExportAccountRewards(c)
+	})
+
+	router.GET("/account/:accountAddress/rewards/chart", func(c *gin.Context) {
+		routes.account.GetAccountRewardsChart(c)
+	})
 	router.GET("/account/:accountAddress/rewards/details", func(c *gin.Context) {
-		accountRoutes.GetAccountRewardsDetails(c)
+		routes.account.GetAccountRewardsDetails(c)
 	})
 
 	router.GET("/account/:accountAddress/rewards/details/:epoch", func(c *gin.Context) {
-		accountRoutes.GetAccountRewardsDetailsEpoch(c)
+		routes.account.GetAccountRewardsDetailsEpoch(c)
 	})
 
 	router.POST("/account/:accountAddress/atx/:epoch/filter-active-nodes", func(c *gin.Context) {
-		accountRoutes.FilterEpochActiveNodes(c)
+		routes.account.FilterEpochActiveNodes(c)
 	})
 
 	router.GET("/account/:accountAddress/atx/:epoch", func(c *gin.Context) {
-		accountRoutes.GetEpochAtx(c)
+		routes.account.GetEpochAtx(c)
+	})
+
+	router.GET("/network/info", v2(networkInfoShapesV2("")...), func(c *gin.Context) {
+		routes.network.GetInfo(c)
+	})
+
+	router.GET("/network/info/history", v2(shapeAt("[]", committedV2)), func(c *gin.Context) {
+		routes.network.GetInfoHistory(c)
+	})
+
+	router.GET("/network/highest-atx", func(c *gin.Context) {
+		routes.network.GetHighestAtx(c)
+	})
+
+	router.GET("/network/parameters", func(c *gin.Context) {
+		routes.network.GetParameters(c)
+	})
+
+	router.GET("/network/supply", func(c *gin.Context) {
+		routes.network.GetSupply(c)
+	})
+
+	router.GET("/network/circulating-supply", func(c *gin.Context) {
+		routes.network.GetCirculatingSupply(c)
+	})
+
+	router.GET("/network/total-supply", func(c *gin.Context) {
+		routes.network.GetTotalSupply(c)
+	})
+
+	router.GET("/network/rewards/chart", func(c *gin.Context) {
+		routes.network.GetRewardsChart(c)
+	})
+	router.GET("/network/charts/:metric", func(c *gin.Context) {
+		routes.network.GetChart(c)
+	})
+	router.GET("/network/price/history", func(c *gin.Context) {
+		routes.network.GetPriceHistory(c)
+	})
+
+	router.GET("/network/price/at", func(c *gin.Context) {
+		routes.network.GetPriceAt(c)
+	})
+
+	router.GET("/network/fees", func(c *gin.Context) {
+		routes.network.GetFees(c)
+	})
+
+	router.GET("/network/fees/estimate", func(c *gin.Context) {
+		routes.network.GetFeeEstimate(c)
 	})
 
-	router.GET("/network/info", func(c *gin.Context) {
-		networkRoutes.GetInfo(c)
+	router.GET("/network/reorgs", func(c *gin.Context) {
+		routes.network.GetReorgs(c)
+	})
+
+	router.GET("/network/countdowns", func(c *gin.Context) {
+		routes.poet.GetCountdowns(c)
+	})
+
+	router.GET("/network/estimated-rewards", func(c *gin.Context) {
+		routes.network.GetEstimatedRewards(c)
 	})
 
 	router.GET("/nodes", func(c *gin.Context) {
-		nodeRoutes.GetNodes(c)
+		routes.node.GetNodes(c)
 	})
-	
+
 	router.GET("/nodes/:nodeId", func(c *gin.Context) {
-		nodeRoutes.GetNode(c)
+		routes.node.GetNode(c)
 	})
 
-	router.GET("/nodes/:nodeId/rewards", func(c *gin.Context) {
-		nodeRoutes.GetNodeRewards(c)
+	router.GET("/nodes/:nodeId/rewards", v2(shapeAt("[]", rewardV2)), func(c *gin.Context) {
+		routes.node.GetNodeRewards(c)
 	})
 
 	router.GET("/nodes/:nodeId/rewards/details", func(c *gin.Context) {
-		nodeRoutes.GetNodeRewardsDetails(c)
+		routes.node.GetNodeRewardsDetails(c)
 	})
 
 	router.GET("/nodes/:nodeId/rewards/eligibility", func(c *gin.Context) {
-		nodeRoutes.GetEligibility(c)
+		routes.node.GetEligibility(c)
+	})
+
+	router.GET("/smesher/:nodeId/atxs", func(c *gin.Context) {
+		routes.atx.GetSmesherAtxs(c)
+	})
+
+	router.GET("/atx/:atxId", func(c *gin.Context) {
+		routes.atx.GetAtx(c)
 	})
 
-	router.GET("/epochs/:epoch", func(c *gin.Context) {
-		epochRoutes.GetEpoch(c)
+	router.GET("/malfeasance", func(c *gin.Context) {
+		routes.malfeasance.GetMalfeasanceNodes(c)
+	})
+
+	router.GET("/malfeasance/:nodeId", func(c *gin.Context) {
+		routes.malfeasance.GetMalfeasanceNode(c)
+	})
+
+	router.GET("/smesher/:nodeId/eligibility", func(c *gin.Context) {
+		routes.node.GetSmesherEligibility(c)
+	})
+
+	router.GET("/smesher/:nodeId/performance", func(c *gin.Context) {
+		routes.node.GetSmesherPerformance(c)
+	})
+
+	router.GET("/smesher/:nodeId/atx-status", func(c *gin.Context) {
+		routes.poet.GetNodeAtxStatus(c)
+	})
+
+	router.GET("/dashboard", v2(append(networkInfoShapesV2("network"),
+		shapeAt("nodes.[].recentRewards.[]", rewardV2),
+		shapeAt("coinbases.[].recentRewards.[]", rewardV2))...), func(c *gin.Context) {
+		routes.dashboard.GetDashboard(c)
+	})
+
+	router.GET("/epochs/:epoch", v2(shapeAt("", committedV2)), func(c *gin.Context) {
+		routes.epoch.GetEpoch(c)
 	})
 
 	router.GET("/epochs/:epoch/atx", func(c *gin.Context) {
-		epochRoutes.GetEpochAtx(c)
+		routes.epoch.GetEpochAtx(c)
 	})
 
 	router.GET("/layers", func(c *gin.Context) {
-		layersRoutes.GetLayers(c)
+		routes.layers.GetLayers(c)
+	})
+
+	router.GET("/layers/:layer/transactions", v2(shapeAt("[]", transactionV2)), func(c *gin.Context) {
+		routes.layers.GetLayerTransactions(c)
 	})
 
-	router.GET("/layers/:layer/transactions", func(c *gin.Context) {
-		layersRoutes.GetLayerTransactions(c)
+	router.GET("/layers/:layer/rewards", v2(shapeAt("[]", rewardV2)), func(c *gin.Context) {
+		routes.layers.GetLayerRewards(c)
 	})
 
-	router.GET("/layers/:layer/rewards", func(c *gin.Context) {
-		layersRoutes.GetLayerRewards(c)
+	router.GET("/layers/:layer/blocks", func(c *gin.Context) {
+		routes.layers.GetLayerBlocks(c)
 	})
 
-	router.GET("/transactions", func(c *gin.Context) {
-		transactionRoutes.GetTransactions(c)
+	router.GET("/blocks/:blockId", func(c *gin.Context) {
+		routes.layers.GetBlock(c)
 	})
 
-	router.GET("/transactions/:transactionId", func(c *gin.Context) {
-		transactionRoutes.GetTransaction(c)
+	router.GET("/transactions", v2(shapeAt("[]", transactionV2)), func(c *gin.Context) {
+		routes.transaction.GetTransactions(c)
 	})
 
+	router.GET("/transactions/:transactionId", v2(shapeAt("", transactionV2)), func(c *gin.Context) {
+		routes.transaction.GetTransaction(c)
+	})
+
+	// v2 serves the receipt under the transaction
+	if version == apiV1 {
+		router.GET("/transaction/:transactionId", func(c *gin.Context) {
+			routes.transaction.GetTransactionReceipt(c)
+		})
+	} else {
+		router.GET("/transactions/:transactionId/receipt", func(c *gin.Context) {
+			routes.transaction.GetTransactionReceipt(c)
+		})
+	}
+
 	router.GET("/poets", func(c *gin.Context) {
-		poetRoutes.GetPoets(c)
+		routes.poet.GetPoets(c)
 	})
 
-	log.Println("Added routes")
+	router.GET("/poets/status", func(c *gin.Context) {
+		routes.poet.GetPoetsStatus(c)
+	})
+
+	router.GET("/smeshers/top", func(c *gin.Context) {
+		routes.smeshers.GetTopSmeshers(c)
+	})
+
+	router.GET("/coinbase/:address/smeshers", func(c *gin.Context) {
+		routes.smeshers.GetCoinbaseSmeshers(c)
+	})
+
+	router.GET("/coinbase/:address/daily", func(c *gin.Context) {
+		routes.account.GetCoinbaseDaily(c)
+	})
 
+	router.POST("/signature/verify", func(c *gin.Context) {
+		routes.signature.VerifySignature(c)
+	})
+
+	router.GET("/sync/changes", func(c *gin.Context) {
+		routes.sync.GetChanges(c)
+	})
+
+	router.GET("/stats/trends", func(c *gin.Context) {
+		routes.stats.GetTrends(c)
+	})
+
+	router.GET("/search", func(c *gin.Context) {
+		routes.search.Search(c)
+	})
+
+	router.GET("/utils/layer-to-time/:layer", func(c *gin.Context) {
+		routes.utils.GetLayerToTime(c)
+	})
+
+	router.GET("/utils/time-to-layer", func(c *gin.Context) {
+		routes.utils.GetTimeToLayer(c)
+	})
 }
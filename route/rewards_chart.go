@@ -0,0 +1,69 @@
+package route
+
+import (
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/network"
+	"github.com/swarmbit/spacemesh-state-api/pkg/apperror"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+// rewardsChart serves the daily or per epoch rewards rollups of account, network wide
+// when account is empty. from and to are unix times for days and epochs for epochs,
+// both included.
+func rewardsChart(c *gin.Context, db database.ReadStore, networkUtils *network.NetworkUtils, account string) {
+	granularity := c.DefaultQuery("granularity", database.RollupDay)
+	if granularity != database.RollupDay && granularity != database.RollupEpoch {
+		respondError(c, apperror.New(apperror.InvalidInput, "granularity must be day or epoch"))
+		return
+	}
+
+	defaultFrom := strconv.FormatInt(time.Now().Add(-30*24*time.Hour).Unix(), 10)
+	defaultTo := strconv.FormatInt(time.Now().Unix(), 10)
+	if granularity == database.RollupEpoch {
+		defaultFrom = "0"
+		defaultTo = strconv.Itoa(math.MaxUint32)
+	}
+	from, err := strconv.ParseInt(c.DefaultQuery("from", defaultFrom), 10, 64)
+	if err != nil {
+		respondError(c, apperror.New(apperror.InvalidInput, "from must be a valid integer"))
+		return
+	}
+	to, err := strconv.ParseInt(c.DefaultQuery("to", defaultTo), 10, 64)
+	if err != nil {
+		respondError(c, apperror.New(apperror.InvalidInput, "to must be a valid integer"))
+		return
+	}
+
+	times, ok := newTimeFormatter(c, networkUtils)
+	if !ok {
+		return
+	}
+
+	rollups, err := db.GetRewardsRollups(account, granularity, from, to)
+	if err != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch rewards chart", err))
+		return
+	}
+
+	points := make([]*types.RewardsChartPoint, len(rollups))
+	for i, v := range rollups {
+		start := time.Unix(v.Id.Bucket, 0)
+		if granularity == database.RollupEpoch {
+			start = networkUtils.GetEpochTime(uint64(v.Id.Bucket))
+		}
+		points[i] = &types.RewardsChartPoint{
+			Bucket:    v.Id.Bucket,
+			Timestamp: start.Unix(),
+			Time:      times.format(start),
+			Rewards:   v.Rewards,
+			Count:     v.RewardsCount,
+		}
+	}
+
+	c.JSON(200, points)
+}
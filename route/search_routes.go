@@ -0,0 +1,135 @@
+package route
+
+import (
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/network"
+	"github.com/swarmbit/spacemesh-state-api/pkg/address"
+	"github.com/swarmbit/spacemesh-state-api/pkg/apperror"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+// Search result types, every one has the path of the endpoint serving it.
+const (
+	searchAccount     = "account"
+	searchNode        = "node"
+	searchAtx         = "atx"
+	searchTransaction = "transaction"
+	searchBlock       = "block"
+	searchLayer       = "layer"
+)
+
+// ids of nodes, atxs and transactions are 32 byte hashes, block ids are 20 bytes
+const (
+	hashIdSize  = 32
+	blockIdSize = 20
+)
+
+type SearchRoutes struct {
+	db           database.ReadStore
+	networkUtils *network.NetworkUtils
+}
+
+func NewSearchRoutes(db database.ReadStore, networkUtils *network.NetworkUtils) *SearchRoutes {
+	routes := &SearchRoutes{
+		db:           db,
+		networkUtils: networkUtils,
+	}
+	return routes
+}
+
+// Search detects what the query is and returns the matching entities. An address always
+// matches an account and a number a processed layer, a hex id is looked up as a node,
+// an atx and a transaction, or as a block when it is 20 bytes. Nothing found is an empty
+// list.
+func (s *SearchRoutes) Search(c *gin.Context) {
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		respondError(c, apperror.New(apperror.InvalidInput, "q is required"))
+		return
+	}
+	times, ok := newTimeFormatter(c, s.networkUtils)
+	if !ok {
+		return
+	}
+
+	results, err := s.search(query, times)
+	if err != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to search", err))
+		return
+	}
+	c.JSON(200, &types.SearchResponse{
+		Query:   query,
+		Results: results,
+	})
+}
+
+func (s *SearchRoutes) search(query string, times *timeFormatter) ([]*types.SearchResult, error) {
+	results := make([]*types.SearchResult, 0)
+
+	if account, err := address.NormalizeAddress(query); err == nil {
+		return append(results, &types.SearchResult{
+			Type: searchAccount,
+			ID:   account,
+			Path: "/account/" + account,
+		}), nil
+	}
+
+	if layer, err := strconv.ParseUint(query, 10, 32); err == nil {
+		lastLayer, err := s.db.GetLastProcessedLayer()
+		if err != nil {
+			return nil, err
+		}
+		if int64(layer) <= int64(lastLayer.Layer) {
+			epoch := layer / uint64(config.LayersPerEpoch)
+			results = append(results, &types.SearchResult{
+				Type:  searchLayer,
+				ID:    query,
+				Path:  "/layers/" + query + "/transactions",
+				Layer: &layer,
+				Epoch: &epoch,
+				Time:  times.layer(layer),
+			})
+		}
+		return results, nil
+	}
+
+	id := strings.TrimPrefix(strings.ToLower(query), "0x")
+	bytes, err := hex.DecodeString(id)
+	if err != nil {
+		return results, nil
+	}
+	switch len(bytes) {
+	case hashIdSize:
+		node, errNode := s.db.GetNode(id)
+		atx, errAtx := s.db.GetAtx(id)
+		transaction, errTransaction := s.db.GetTransaction(id)
+		if err := errors.Join(errNode, errAtx, errTransaction); err != nil {
+			return nil, err
+		}
+		if node.ID != "" {
+			results = append(results, &types.SearchResult{Type: searchNode, ID: id, Path: "/nodes/" + id})
+		}
+		if atx.AtxID != "" {
+			results = append(results, &types.SearchResult{Type: searchAtx, ID: id, Path: "/atx/" + id})
+		}
+		if transaction.ID != "" {
+			results = append(results, &types.SearchResult{Type: searchTransaction, ID: id, Path: "/transactions/" + id})
+		}
+	case blockIdSize:
+		block, err := s.db.GetBlock(id)
+		if err != nil {
+			return nil, err
+		}
+		if block.ID != "" {
+			results = append(results, &types.SearchResult{Type: searchBlock, ID: id, Path: "/blocks/" + id})
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,70 @@
+package route
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/swarmbit/spacemesh-state-api/pkg/apperror"
+	"github.com/swarmbit/spacemesh-state-api/pkg/signature"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+type SignatureRoutes struct {
+}
+
+func NewSignatureRoutes() *SignatureRoutes {
+	return &SignatureRoutes{}
+}
+
+// VerifySignature checks that the signature over message was produced by the key
+// behind the given node id, or by the key owning the given wallet address.
+func (s *SignatureRoutes) VerifySignature(c *gin.Context) {
+	var req types.VerifySignatureRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, apperror.New(apperror.InvalidInput, err.Error()))
+		return
+	}
+
+	if req.Message == "" || req.Signature == "" {
+		respondError(c, apperror.New(apperror.InvalidInput, "message and signature are required"))
+		return
+	}
+
+	publicKeyStr := req.PublicKey
+	if req.NodeId != "" {
+		if publicKeyStr != "" && !strings.EqualFold(publicKeyStr, req.NodeId) {
+			respondError(c, apperror.New(apperror.InvalidInput, "publicKey must match nodeId"))
+			return
+		}
+		publicKeyStr = req.NodeId
+	}
+
+	if publicKeyStr == "" {
+		respondError(c, apperror.New(apperror.InvalidInput, "nodeId or publicKey is required"))
+		return
+	}
+
+	publicKey, err := signature.DecodePublicKey(publicKeyStr)
+	if err != nil {
+		respondError(c, apperror.New(apperror.InvalidInput, err.Error()))
+		return
+	}
+
+	valid, err := signature.Verify(publicKey, []byte(req.Message), req.Signature)
+	if err != nil {
+		respondError(c, apperror.New(apperror.InvalidInput, err.Error()))
+		return
+	}
+
+	if req.Address != "" && signature.WalletAddress(publicKey).String() != req.Address {
+		valid = false
+	}
+
+	c.JSON(200, &types.VerifySignatureResponse{
+		Valid:     valid,
+		Address:   req.Address,
+		NodeId:    req.NodeId,
+		PublicKey: strings.ToLower(strings.TrimPrefix(publicKeyStr, "0x")),
+	})
+}
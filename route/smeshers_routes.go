@@ -0,0 +1,347 @@
+package route
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/network"
+	"github.com/swarmbit/spacemesh-state-api/pkg/apperror"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+type SmeshersRoutes struct {
+	db           database.ReadStore
+	networkUtils *network.NetworkUtils
+	state        *network.NetworkState
+}
+
+func NewSmeshersRoutes(db database.ReadStore, networkUtils *network.NetworkUtils, state *network.NetworkState) *SmeshersRoutes {
+	return &SmeshersRoutes{
+		db:           db,
+		networkUtils: networkUtils,
+		state:        state,
+	}
+}
+
+func (s *SmeshersRoutes) GetTopSmeshers(c *gin.Context) {
+	offsetStr := c.DefaultQuery("offset", "0")
+	limitStr := c.DefaultQuery("limit", "20")
+	sortStr := c.DefaultQuery("sort", "effectiveUnits")
+	epochStr := c.DefaultQuery("epoch", "-1")
+
+	offset, err := strconv.Atoi(offsetStr)
+	if err != nil {
+		respondError(c, apperror.New(apperror.InvalidInput, "offset must be a valid integer"))
+		return
+	}
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil {
+		respondError(c, apperror.New(apperror.InvalidInput, "limit must be a valid integer"))
+		return
+	}
+
+	if offset < 0 || limit < 0 {
+		respondError(c, apperror.New(apperror.InvalidInput, "offset and limit must be greater or equal to 0"))
+		return
+	}
+
+	epoch, err := strconv.Atoi(epochStr)
+	if err != nil {
+		respondError(c, apperror.New(apperror.InvalidInput, "epoch must be a valid integer"))
+		return
+	}
+
+	sortField := ""
+	switch sortStr {
+	case "effectiveUnits":
+		sortField = "effectiveNumUnits"
+	case "rewards":
+		sortField = "totalRewards"
+	case "atxs":
+		sortField = "totalAtx"
+	default:
+		respondError(c, apperror.New(apperror.InvalidInput, "sort must be one of effectiveUnits, rewards or atxs"))
+		return
+	}
+
+	if epoch > -1 {
+		if sortStr != "rewards" {
+			respondError(c, apperror.New(apperror.InvalidInput, "epoch is only supported when sorting by rewards"))
+			return
+		}
+		s.getTopSmeshersEpoch(c, uint32(epoch), int64(offset), int64(limit))
+		return
+	}
+
+	smeshers, errSmeshers := s.db.GetTopSmeshers(sortField, int64(offset), int64(limit))
+	count, errCount := s.db.CountSmeshers()
+
+	if errSmeshers != nil || errCount != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch top smeshers", errors.Join(errSmeshers, errCount)))
+		return
+	}
+
+	nodeIds := make([]string, len(smeshers))
+	for i, v := range smeshers {
+		nodeIds[i] = v.ID
+	}
+	malfeasant, err := malfeasantNodes(s.db, nodeIds)
+	if err != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch top smeshers", err))
+		return
+	}
+
+	smeshersResponse := make([]*types.TopSmesher, len(smeshers))
+	for i, v := range smeshers {
+		smeshersResponse[i] = toTopSmesher(v)
+		smeshersResponse[i].Malfeasant = malfeasant[v.ID]
+	}
+
+	c.Header("total", strconv.FormatInt(count, 10))
+	c.JSON(200, smeshersResponse)
+}
+
+func (s *SmeshersRoutes) getTopSmeshersEpoch(c *gin.Context, epoch uint32, offset int64, limit int64) {
+	epochSmeshers, errSmeshers := s.db.GetTopSmeshersEpoch(epoch, offset, limit)
+	count, errCount := s.db.CountSmeshersEpoch(epoch)
+
+	if errSmeshers != nil || errCount != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch top smeshers for epoch", errors.Join(errSmeshers, errCount)))
+		return
+	}
+
+	nodeIds := make([]string, len(epochSmeshers))
+	for i, v := range epochSmeshers {
+		nodeIds[i] = v.Id.NodeId
+	}
+
+	smeshersMap := make(map[string]*types.SmesherDoc)
+	if len(nodeIds) > 0 {
+		smeshers, err := s.db.GetSmeshers(nodeIds)
+		if err != nil {
+			respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch top smeshers for epoch", err))
+			return
+		}
+		for _, v := range smeshers {
+			smeshersMap[v.ID] = v
+		}
+	}
+	malfeasant, err := malfeasantNodes(s.db, nodeIds)
+	if err != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch top smeshers for epoch", err))
+		return
+	}
+
+	smeshersResponse := make([]*types.TopSmesher, len(epochSmeshers))
+	for i, v := range epochSmeshers {
+		smesher, exists := smeshersMap[v.Id.NodeId]
+		if !exists {
+			smesher = &types.SmesherDoc{ID: v.Id.NodeId, Coinbase: v.Coinbase}
+		}
+		topSmesher := toTopSmesher(smesher)
+		topSmesher.Malfeasant = malfeasant[v.Id.NodeId]
+		topSmesher.Epoch = v.Id.Epoch
+		topSmesher.EpochRewards = v.Rewards
+		smeshersResponse[i] = topSmesher
+	}
+
+	c.Header("total", strconv.FormatInt(count, 10))
+	c.JSON(200, smeshersResponse)
+}
+
+func toTopSmesher(smesher *types.SmesherDoc) *types.TopSmesher {
+	return &types.TopSmesher{
+		NodeId:            smesher.ID,
+		Coinbase:          smesher.Coinbase,
+		EffectiveNumUnits: smesher.EffectiveNumUnits,
+		TotalAtx:          smesher.TotalAtx,
+		TotalRewards:      smesher.TotalRewards,
+		RewardsCount:      smesher.RewardsCount,
+	}
+}
+
+// GetCoinbaseSmeshers lists the nodes paying to a coinbase, the nodes that were rewarded
+// to it and the ones with an atx to it for the current or the next epoch. The epoch
+// totals cover all the nodes, offset and limit only page the list.
+func (s *SmeshersRoutes) GetCoinbaseSmeshers(c *gin.Context) {
+	offsetStr := c.DefaultQuery("offset", "0")
+	limitStr := c.DefaultQuery("limit", "20")
+
+	offset, err := strconv.Atoi(offsetStr)
+	if err != nil {
+		respondError(c, apperror.New(apperror.InvalidInput, "offset must be a valid integer"))
+		return
+	}
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil {
+		respondError(c, apperror.New(apperror.InvalidInput, "limit must be a valid integer"))
+		return
+	}
+
+	if offset < 0 || limit < 0 {
+		respondError(c, apperror.New(apperror.InvalidInput, "offset and limit must be greater or equal to 0"))
+		return
+	}
+
+	times, ok := newTimeFormatter(c, s.networkUtils)
+	if !ok {
+		return
+	}
+
+	coinbase := c.Param("address")
+	view := requestState(c, s.state)
+	epoch := view.GetInfo().Epoch
+
+	nodeIds, err := s.db.GetCoinbaseNodes(coinbase)
+	if err != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch coinbase smeshers", err))
+		return
+	}
+
+	// atxs for the next epoch are still arriving so its totals are a lower bound
+	current, currentAtxs, err := s.getCoinbaseEpoch(view, coinbase, epoch)
+	if err != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to get current epoch eligibility", err))
+		return
+	}
+	next, nextAtxs, err := s.getCoinbaseEpoch(view, coinbase, epoch+1)
+	if err != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to get next epoch eligibility", err))
+		return
+	}
+	current.StartTime = times.epoch(uint64(current.Epoch))
+	next.StartTime = times.epoch(uint64(next.Epoch))
+
+	seen := make(map[string]bool, len(nodeIds))
+	for _, nodeId := range nodeIds {
+		seen[nodeId] = true
+	}
+	for _, atxs := range []map[string]*types.SmesherEpochAtx{currentAtxs, nextAtxs} {
+		for nodeId := range atxs {
+			if !seen[nodeId] {
+				seen[nodeId] = true
+				nodeIds = append(nodeIds, nodeId)
+			}
+		}
+	}
+	sort.Strings(nodeIds)
+
+	smeshersMap := make(map[string]*types.SmesherDoc)
+	if len(nodeIds) > 0 {
+		smeshers, err := s.db.GetSmeshers(nodeIds)
+		if err != nil {
+			respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch coinbase smeshers", err))
+			return
+		}
+		for _, v := range smeshers {
+			smeshersMap[v.ID] = v
+		}
+	}
+
+	page := nodeIds[min(offset, len(nodeIds)):min(offset+limit, len(nodeIds))]
+	malfeasant, err := malfeasantNodes(s.db, page)
+	if err != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch coinbase smeshers", err))
+		return
+	}
+
+	smeshersResponse := make([]*types.CoinbaseSmesher, len(page))
+	for i, nodeId := range page {
+		smesher := &types.CoinbaseSmesher{
+			NodeId:       nodeId,
+			Malfeasant:   malfeasant[nodeId],
+			CurrentEpoch: coinbaseSmesherEpoch(currentAtxs, nodeId, current.Epoch),
+			NextEpoch:    coinbaseSmesherEpoch(nextAtxs, nodeId, next.Epoch),
+		}
+		if v, ok := smeshersMap[nodeId]; ok {
+			smesher.EffectiveNumUnits = v.EffectiveNumUnits
+			smesher.LastEpoch = v.LastEpoch
+			smesher.TotalAtx = v.TotalAtx
+			smesher.TotalRewards = v.TotalRewards
+			smesher.RewardsCount = v.RewardsCount
+		}
+		smeshersResponse[i] = smesher
+	}
+
+	c.Header("total", strconv.Itoa(len(nodeIds)))
+	c.JSON(200, &types.CoinbaseSmeshers{
+		Coinbase:      coinbase,
+		TotalSmeshers: len(nodeIds),
+		CurrentEpoch:  current,
+		NextEpoch:     next,
+		Smeshers:      smeshersResponse,
+	})
+}
+
+// getCoinbaseEpoch sums the eligibility of the atxs published to coinbase for epoch and
+// returns the eligibility of each node by id.
+func (s *SmeshersRoutes) getCoinbaseEpoch(view *network.View, coinbase string, epoch uint32) (*types.EpochEligibility, map[string]*types.SmesherEpochAtx, error) {
+	if epoch == 0 {
+		// no atx targets the genesis epoch
+		return &types.EpochEligibility{
+			Epoch:        epoch,
+			Count:        -1,
+			EpochSubsidy: view.GetEpochSubsidy(epoch),
+		}, map[string]*types.SmesherEpochAtx{}, nil
+	}
+
+	atxs, err := s.db.GetAccountAtxList(coinbase, uint64(epoch-1))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	epochAtx, err := s.db.GetAtxEpoch(uint64(epoch - 1))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	eligibility := &types.EpochEligibility{
+		Epoch:        epoch,
+		TotalWeight:  epochAtx.TotalWeight,
+		EpochSubsidy: view.GetEpochSubsidy(epoch),
+	}
+
+	nodes := make(map[string]*types.SmesherEpochAtx, len(atxs))
+	for _, atx := range atxs {
+		node := &types.SmesherEpochAtx{
+			Epoch:             epoch,
+			Active:            true,
+			AtxId:             atx.AtxID,
+			Count:             -1,
+			EffectiveNumUnits: atx.EffectiveNumUnits,
+			Weight:            atx.Weight,
+		}
+		if atx.Weight > 0 && epochAtx.TotalWeight > 0 {
+			count, err := s.networkUtils.GetNumberOfSlots(atx.Weight, epochAtx.TotalWeight, epoch)
+			if err != nil {
+				return nil, nil, err
+			}
+			node.Count = count
+			node.PredictedRewards = eligibility.EpochSubsidy / epochAtx.TotalWeight * atx.Weight
+			eligibility.Count += count
+			eligibility.PredictedRewards += node.PredictedRewards
+		}
+		eligibility.EffectiveNumUnits += int64(atx.EffectiveNumUnits)
+		eligibility.Weight += int64(atx.Weight)
+		nodes[atx.NodeID] = node
+	}
+	if len(nodes) == 0 {
+		eligibility.Count = -1
+	}
+	return eligibility, nodes, nil
+}
+
+// coinbaseSmesherEpoch is the eligibility of a node for epoch, inactive when it has no
+// atx to the coinbase.
+func coinbaseSmesherEpoch(atxs map[string]*types.SmesherEpochAtx, nodeId string, epoch uint32) *types.SmesherEpochAtx {
+	if atx, ok := atxs[nodeId]; ok {
+		return atx
+	}
+	return &types.SmesherEpochAtx{
+		Epoch: epoch,
+		Count: -1,
+	}
+}
@@ -0,0 +1,37 @@
+package route
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/swarmbit/spacemesh-state-api/network"
+)
+
+// stateSnapshotKey holds the network state snapshot a request started with
+const stateSnapshotKey = "stateSnapshot"
+
+// stateHeaders serves the version, layer and creation time of the network state snapshot
+// at the start of the request, clients comparing responses can tell which refresh each
+// was computed from. The handlers read the state from the same snapshot with
+// requestState. Nothing is set until the network state is loaded.
+func stateHeaders(state *network.NetworkState) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		snapshot := state.GetSnapshot()
+		c.Set(stateSnapshotKey, snapshot)
+		if snapshot != nil && snapshot.Info != nil {
+			c.Header("state-version", strconv.FormatUint(snapshot.Version, 10))
+			c.Header("state-layer", strconv.FormatUint(snapshot.Info.Layer, 10))
+			c.Header("state-created-at", strconv.FormatInt(snapshot.CreatedAt.Unix(), 10))
+		}
+		c.Next()
+	}
+}
+
+// requestState is the network state of the snapshot the request started with, the one
+// of its state headers.
+func requestState(c *gin.Context, state *network.NetworkState) *network.View {
+	if snapshot, ok := c.Get(stateSnapshotKey); ok {
+		return state.View(snapshot.(*network.Snapshot))
+	}
+	return state.View(state.GetSnapshot())
+}
@@ -0,0 +1,64 @@
+package route
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/pkg/apperror"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+type StatsRoutes struct {
+	db database.ReadStore
+}
+
+func NewStatsRoutes(db database.ReadStore) *StatsRoutes {
+	return &StatsRoutes{
+		db: db,
+	}
+}
+
+// GetTrends returns the stored stats snapshots between from and to, given as unix
+// seconds, by default the last 7 days.
+func (s *StatsRoutes) GetTrends(c *gin.Context) {
+	now := time.Now()
+	fromStr := c.DefaultQuery("from", strconv.FormatInt(now.Add(-7*24*time.Hour).Unix(), 10))
+	toStr := c.DefaultQuery("to", strconv.FormatInt(now.Unix(), 10))
+	limitStr := c.DefaultQuery("limit", "1000")
+
+	from, err := strconv.ParseInt(fromStr, 10, 64)
+	if err != nil {
+		respondError(c, apperror.New(apperror.InvalidInput, "from must be a valid integer"))
+		return
+	}
+	to, err := strconv.ParseInt(toStr, 10, 64)
+	if err != nil {
+		respondError(c, apperror.New(apperror.InvalidInput, "to must be a valid integer"))
+		return
+	}
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit < 0 {
+		respondError(c, apperror.New(apperror.InvalidInput, "limit must be a valid integer greater or equal to 0"))
+		return
+	}
+
+	stats, err := s.db.GetStats(time.Unix(from, 0), time.Unix(to, 0), int64(limit))
+	if err != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch stats", err))
+		return
+	}
+
+	statsResponse := make([]*types.StatsSnapshot, len(stats))
+	for i, v := range stats {
+		statsResponse[i] = &types.StatsSnapshot{
+			Timestamp:   v.Timestamp.Unix(),
+			Collections: v.Collections,
+			IngestRates: v.IngestRates,
+			ApiQps:      v.ApiQps,
+		}
+	}
+
+	c.JSON(200, statsResponse)
+}
@@ -0,0 +1,118 @@
+package route
+
+import (
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/network"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+// summaries build the state of an address or node as the watchlist summary and the
+// dashboard show it.
+type summaries struct {
+	db           database.ReadStore
+	networkUtils *network.NetworkUtils
+	state        *network.NetworkState
+	nodes        *NodesRoutes
+}
+
+func newSummaries(db database.ReadStore, networkUtils *network.NetworkUtils, state *network.NetworkState) *summaries {
+	return &summaries{
+		db:           db,
+		networkUtils: networkUtils,
+		state:        state,
+		nodes:        NewNodeRoutes(db, networkUtils, state),
+	}
+}
+
+// account is the balance, the atxs with the address as coinbase published in epoch, the
+// eligibility they give for the next epoch and the latest rewards of the address.
+func (s *summaries) account(view *network.View, accountAddress string, epoch uint32, recentRewards int64, priceValue float64, times *timeFormatter) (*types.WatchlistAccount, error) {
+	account, err := s.db.GetAccount(accountAddress)
+	if err != nil {
+		return nil, err
+	}
+	atxs, err := s.db.GetAccountAtxList(accountAddress, uint64(epoch))
+	if err != nil {
+		return nil, err
+	}
+	epochAtx, err := s.db.GetAtxEpoch(uint64(epoch))
+	if err != nil {
+		return nil, err
+	}
+	rewards, err := s.db.GetRewards(accountAddress, 0, recentRewards, -1, -1, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	// atxs for the next epoch are still arriving so its totals are a lower bound
+	next := &types.EpochEligibility{
+		Epoch:        epoch + 1,
+		StartTime:    times.epoch(uint64(epoch + 1)),
+		Count:        -1,
+		TotalWeight:  epochAtx.TotalWeight,
+		EpochSubsidy: view.GetEpochSubsidy(epoch + 1),
+	}
+	pendingAtxs := make([]*types.Atx, len(atxs))
+	for i, atx := range atxs {
+		pendingAtxs[i] = toAtx(atx, times)
+		next.Weight += int64(atx.Weight)
+		next.EffectiveNumUnits += int64(atx.EffectiveNumUnits)
+		if epochAtx.TotalWeight == 0 {
+			continue
+		}
+		count, err := s.networkUtils.GetNumberOfSlots(atx.Weight, epochAtx.TotalWeight, epoch+1)
+		if err != nil {
+			return nil, err
+		}
+		next.Count = max(next.Count, 0) + count
+	}
+	if next.Weight > 0 && epochAtx.TotalWeight > 0 {
+		next.PredictedRewards = next.EpochSubsidy / epochAtx.TotalWeight * uint64(next.Weight)
+	}
+
+	return &types.WatchlistAccount{
+		Address:       accountAddress,
+		Balance:       account.Balance,
+		USDValue:      fiatValue(priceValue, account.Balance),
+		PendingAtxs:   pendingAtxs,
+		NextEpoch:     next,
+		RecentRewards: toRewards(rewards, times),
+	}, nil
+}
+
+// node is the atx of the node published in epoch, its eligibility for the next epoch and
+// its latest rewards. The latest atx of the node is returned with it, empty when it has
+// none.
+func (s *summaries) node(view *network.View, nodeId string, epoch uint32, recentRewards int64, times *timeFormatter) (*types.WatchlistNode, *types.AtxDoc, error) {
+	atx, err := s.db.GetPreviousAtx(nodeId, epoch+1)
+	if err != nil {
+		return nil, nil, err
+	}
+	next, err := s.nodes.getEpochEligibility(view, nodeId, epoch+1)
+	if err != nil {
+		return nil, nil, err
+	}
+	next.StartTime = times.epoch(uint64(next.Epoch))
+	rewards, err := s.db.GetNodeRewards(nodeId, 0, recentRewards, -1)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	node := &types.WatchlistNode{
+		NodeId:        nodeId,
+		NextEpoch:     next,
+		RecentRewards: toRewards(rewards, times),
+	}
+	if atx.AtxID != "" && atx.PublishEpoch == epoch {
+		node.PendingAtx = toAtx(atx, times)
+	}
+	return node, atx, nil
+}
+
+func toRewards(rewards []*types.RewardsDoc, times *timeFormatter) []*types.Reward {
+	response := make([]*types.Reward, len(rewards))
+	for i, v := range rewards {
+		response[i] = toReward(v, times)
+	}
+	return response
+}
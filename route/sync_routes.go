@@ -0,0 +1,93 @@
+package route
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/network"
+	"github.com/swarmbit/spacemesh-state-api/pkg/apperror"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+const maxChangesLimit = 1000
+
+// changes are only served once they are this old so a reader never moves its
+// checkpoint past a sequence that is still being written
+const changesSettleTime = 5 * time.Second
+
+type SyncRoutes struct {
+	db           database.ReadStore
+	networkUtils *network.NetworkUtils
+}
+
+func NewSyncRoutes(db database.ReadStore, networkUtils *network.NetworkUtils) *SyncRoutes {
+	return &SyncRoutes{
+		db:           db,
+		networkUtils: networkUtils,
+	}
+}
+
+func (s *SyncRoutes) GetChanges(c *gin.Context) {
+	sinceStr := c.DefaultQuery("since", "0")
+	limitStr := c.DefaultQuery("limit", "100")
+
+	since, err := strconv.ParseInt(sinceStr, 10, 64)
+	if err != nil || since < 0 {
+		respondError(c, apperror.New(apperror.InvalidInput, "since must be a valid positive integer"))
+		return
+	}
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil {
+		respondError(c, apperror.New(apperror.InvalidInput, "limit must be a valid integer"))
+		return
+	}
+	if limit <= 0 || limit > maxChangesLimit {
+		respondError(c, apperror.New(apperror.InvalidInput, "limit must be between 1 and "+strconv.Itoa(maxChangesLimit)))
+		return
+	}
+
+	times, ok := newTimeFormatter(c, s.networkUtils)
+	if !ok {
+		return
+	}
+
+	// fetch one extra change to know if the reader should keep paging
+	changes, err := s.db.GetChanges(since, int64(limit+1), changesSettleTime)
+	if errors.Is(err, database.ErrNotSupported) {
+		respondError(c, apperror.New(apperror.NotSupported, "Changes are not available on this storage backend"))
+		return
+	}
+	if err != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch changes", err))
+		return
+	}
+
+	hasMore := len(changes) > limit
+	if hasMore {
+		changes = changes[:limit]
+	}
+
+	checkpoint := since
+	changesResponse := make([]*types.Change, len(changes))
+	for i, v := range changes {
+		changesResponse[i] = &types.Change{
+			Sequence:   v.Sequence,
+			Entity:     v.Entity,
+			Operation:  v.Operation,
+			DocumentId: v.DocumentId,
+			Document:   v.Document,
+			Time:       times.format(v.Timestamp),
+			Timestamp:  v.Timestamp.Unix(),
+		}
+		checkpoint = v.Sequence
+	}
+
+	c.JSON(200, &types.ChangesResponse{
+		Changes:    changesResponse,
+		Checkpoint: checkpoint,
+		HasMore:    hasMore,
+	})
+}
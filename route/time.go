@@ -0,0 +1,48 @@
+package route
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/swarmbit/spacemesh-state-api/network"
+	"github.com/swarmbit/spacemesh-state-api/pkg/apperror"
+)
+
+// timeFormatter renders layer, epoch and event times as ISO8601 strings in the
+// timezone requested with ?tz=, UTC when the parameter is not set.
+type timeFormatter struct {
+	networkUtils *network.NetworkUtils
+	location     *time.Location
+}
+
+func newTimeFormatter(c *gin.Context, networkUtils *network.NetworkUtils) (*timeFormatter, bool) {
+	location, err := time.LoadLocation(c.DefaultQuery("tz", "UTC"))
+	if err != nil {
+		respondError(c, apperror.New(apperror.InvalidInput, "tz must be a valid IANA time zone"))
+		return nil, false
+	}
+	return &timeFormatter{
+		networkUtils: networkUtils,
+		location:     location,
+	}, true
+}
+
+func (t *timeFormatter) layer(layer uint64) string {
+	return t.format(t.networkUtils.GetLayerTime(layer))
+}
+
+func (t *timeFormatter) epoch(epoch uint64) string {
+	return t.format(t.networkUtils.GetEpochTime(epoch))
+}
+
+func (t *timeFormatter) unix(seconds int64) string {
+	return t.format(time.Unix(seconds, 0))
+}
+
+func (t *timeFormatter) unixMilli(milliseconds int64) string {
+	return t.format(time.UnixMilli(milliseconds))
+}
+
+func (t *timeFormatter) format(value time.Time) string {
+	return value.In(t.location).Format(time.RFC3339)
+}
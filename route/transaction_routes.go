@@ -1,36 +1,47 @@
 package route
 
 import (
+    "encoding/hex"
+    "errors"
     "github.com/gin-gonic/gin"
     "github.com/swarmbit/spacemesh-state-api/config"
     "github.com/swarmbit/spacemesh-state-api/database"
     "github.com/swarmbit/spacemesh-state-api/network"
+    "github.com/swarmbit/spacemesh-state-api/node"
+    "github.com/swarmbit/spacemesh-state-api/pkg/address"
+    "github.com/swarmbit/spacemesh-state-api/pkg/apperror"
     "github.com/swarmbit/spacemesh-state-api/types"
-    "net/http"
+    "log"
     "strconv"
     "strings"
 )
 
 type TransactionRoutes struct {
-    db           *database.ReadDB
+    db           database.ReadStore
     networkUtils *network.NetworkUtils
     state        *network.NetworkState
+    nodeClient   *node.NodeClient
+    reloader     *config.Reloader
 }
 
-func NewTransactionRoutes(db *database.ReadDB, networkUtils *network.NetworkUtils, state *network.NetworkState) *TransactionRoutes {
+func NewTransactionRoutes(db database.ReadStore, networkUtils *network.NetworkUtils, state *network.NetworkState, nodeClient *node.NodeClient, reloader *config.Reloader) *TransactionRoutes {
     routes := &TransactionRoutes{
         db:           db,
         networkUtils: networkUtils,
         state:        state,
+        nodeClient:   nodeClient,
+        reloader:     reloader,
     }
     return routes
 }
 
+// GetTransactions lists the transactions of every account. Deposit scans select the
+// transactions to the comma separated toAddress accounts between fromLayer and toLayer,
+// every transaction carries its confirmations up to the last verified layer.
 func (t *TransactionRoutes) GetTransactions(c *gin.Context) {
     offsetStr := c.DefaultQuery("offset", "0")
     limitStr := c.DefaultQuery("limit", "20")
     sortStr := c.DefaultQuery("sort", "asc")
-    completeStr := c.DefaultQuery("complete", "true")
     methodStr := strings.ToLower(c.DefaultQuery("method", ""))
     minAmountStr := c.DefaultQuery("minAmount", "-1")
 
@@ -45,31 +56,51 @@ func (t *TransactionRoutes) GetTransactions(c *gin.Context) {
 
     minAmount, err := strconv.Atoi(minAmountStr)
     if err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{
-            "error": "min amount must be a valid integer",
-        })
+        respondError(c, apperror.New(apperror.InvalidInput, "min amount must be a valid integer"))
         return
     }
 
+    fromLayer, err := strconv.Atoi(c.DefaultQuery("fromLayer", "-1"))
+    if err != nil {
+        respondError(c, apperror.New(apperror.InvalidInput, "from layer must be a valid integer"))
+        return
+    }
+    toLayer, err := strconv.Atoi(c.DefaultQuery("toLayer", "-1"))
+    if err != nil {
+        respondError(c, apperror.New(apperror.InvalidInput, "to layer must be a valid integer"))
+        return
+    }
+    if fromLayer > -1 && toLayer > -1 && toLayer < fromLayer {
+        respondError(c, apperror.New(apperror.InvalidInput, "to layer must not be before from layer"))
+        return
+    }
+
+    var receivers []string
+    if toAddress := c.Query("toAddress"); toAddress != "" {
+        receivers, err = address.NormalizeAddresses(strings.Split(toAddress, ","))
+        if err != nil {
+            respondError(c, apperror.New(apperror.InvalidInput, err.Error()))
+            return
+        }
+        if maxAccounts := maxBatchAccounts(t.reloader); len(receivers) > maxAccounts {
+            respondError(c, apperror.New(apperror.InvalidInput, "to address must have at most "+strconv.Itoa(maxAccounts)+" addresses"))
+            return
+        }
+    }
+
     offset, err := strconv.Atoi(offsetStr)
     if err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{
-            "error": "offset must be a valid integer",
-        })
+        respondError(c, apperror.New(apperror.InvalidInput, "offset must be a valid integer"))
         return
     }
     limit, err := strconv.Atoi(limitStr)
     if err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{
-            "error": "limit must be a valid integer",
-        })
+        respondError(c, apperror.New(apperror.InvalidInput, "limit must be a valid integer"))
         return
     }
 
     if offset < 0 || limit < 0 {
-        c.JSON(http.StatusBadRequest, gin.H{
-            "error": "offset and limit must be greater or equal to 0",
-        })
+        respondError(c, apperror.New(apperror.InvalidInput, "offset and limit must be greater or equal to 0"))
         return
     }
 
@@ -80,44 +111,72 @@ func (t *TransactionRoutes) GetTransactions(c *gin.Context) {
         sort = 1
     }
 
-    complete := completeStr == "true"
+    state, ok := transactionState(c)
+    if !ok {
+        return
+    }
+
+    times, ok := newTimeFormatter(c, t.networkUtils)
+    if !ok {
+        return
+    }
+
+    filter := &database.TransactionsFilter{
+        State:      state,
+        Method:     method,
+        MinAmount:  minAmount,
+        Receivers:  receivers,
+        FirstLayer: fromLayer,
+        LastLayer:  toLayer,
+    }
+    verifiedLayer, err := t.verifiedLayer()
+    if err != nil {
+        respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch last verified layer", err))
+        return
+    }
+    c.Header("verified-layer", strconv.FormatUint(uint64(verifiedLayer), 10))
+
+    if format := exportFormat(c); format != "" {
+        writer := newExportWriter(c, format, "transactions")
+        writer.Close(t.db.StreamAllTransactions(sort, filter, func(v *types.TransactionDoc) error {
+            return writer.Write(toScannedTransaction(v, times, verifiedLayer))
+        }))
+        return
+    }
+
+    page, ok := pageCursor(c, sort)
+    if !ok {
+        return
+    }
+    if page != nil {
+        transactions, err := t.db.GetAllTransactionsAfter(page.after, int64(limit), page.sort, filter)
+        if err != nil {
+            respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch transactions", err))
+            return
+        }
+        transactionsResponse := make([]*types.Transaction, len(transactions))
+        for i, v := range transactions {
+            transactionsResponse[i] = toScannedTransaction(v, times, verifiedLayer)
+        }
+        if len(transactions) > 0 {
+            last := transactions[len(transactions)-1]
+            page.next(c, limit, len(transactions), int64(last.Layer), last.ID)
+        }
+        c.JSON(200, transactionsResponse)
+        return
+    }
 
-    transactions, errRewards := t.db.GetAllTransactions(int64(offset), int64(limit), sort, complete, method, minAmount)
-    count, errCount := t.db.CountAllTransactions(complete, method, minAmount)
+    transactions, errRewards := t.db.GetAllTransactions(int64(offset), int64(limit), sort, filter)
+    count, errCount := t.db.CountAllTransactions(filter)
 
     if errRewards != nil || errCount != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{
-            "status": "Internal Error",
-            "error":  "Failed to fetch transactions for layer",
-        })
+        respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch transactions for layer", errors.Join(errRewards, errCount)))
     } else if transactions != nil {
 
         transactionsResponse := make([]*types.Transaction, len(transactions))
 
         for i, v := range transactions {
-            method := ""
-            if v.Method == 0 {
-                method = "Spawn"
-            }
-            if v.Method == 16 {
-                method = "Spend"
-            }
-            if v.Method == 17 {
-                method = "DrainVault"
-            }
-            transactionsResponse[i] = &types.Transaction{
-                ID:               v.ID,
-                Status:           v.Status,
-                PrincipalAccount: v.PrincipaAccount,
-                ReceiverAccount:  v.ReceiverAccount,
-                VaultAccount:     v.VaultAccount,
-                Fee:              v.Gas * v.GasPrice,
-                Amount:           v.Amount,
-                Layer:            v.Layer,
-                Counter:          v.Counter,
-                Method:           method,
-                Timestamp:        int64(config.GenesisEpochSeconds + (v.Layer * config.LayerDuration)),
-            }
+            transactionsResponse[i] = toScannedTransaction(v, times, verifiedLayer)
         }
 
         c.Header("total", strconv.FormatInt(count, 10))
@@ -129,46 +188,97 @@ func (t *TransactionRoutes) GetTransactions(c *gin.Context) {
 
 }
 
+// verifiedLayer is the last layer the node verified, the last layer the connector
+// processed while the node client is disabled or not connected.
+func (t *TransactionRoutes) verifiedLayer() (uint32, error) {
+    if status := t.nodeClient.Status(); status != nil && status.Connected && status.VerifiedLayer > 0 {
+        return status.VerifiedLayer, nil
+    }
+    layer, err := t.db.GetLastProcessedLayer()
+    if err != nil {
+        return 0, err
+    }
+    return uint32(max(layer.Layer, 0)), nil
+}
+
+// toScannedTransaction counts the layer of an applied transaction as its first
+// confirmation, created and not yet verified transactions have none.
+func toScannedTransaction(v *types.TransactionDoc, times *timeFormatter, verifiedLayer uint32) *types.Transaction {
+    transaction := toTransaction(v, times)
+    var confirmations uint32
+    if v.Complete && v.Layer <= verifiedLayer {
+        confirmations = verifiedLayer - v.Layer + 1
+    }
+    transaction.Confirmations = &confirmations
+    return transaction
+}
+
 func (t *TransactionRoutes) GetTransaction(c *gin.Context) {
+    times, ok := newTimeFormatter(c, t.networkUtils)
+    if !ok {
+        return
+    }
+
     transactionId := c.Param("transactionId")
     transaction, err := t.db.GetTransaction(transactionId)
     if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{
-            "status": "Internal Error",
-            "error":  "Failed to fetch transaction",
-        })
+        respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch transaction", err))
+        return
+    }
+    if transaction.ID == "" {
+        respondError(c, apperror.New(apperror.NotFound, "Node not found"))
+        return
+    }
+
+    c.JSON(200, toTransaction(transaction, times))
+}
+
+// GetTransactionReceipt returns the lifecycle of a transaction. The layer hash is asked
+// from the node once the result is applied, it is left out without the node client or
+// while the node can not return the layer.
+func (t *TransactionRoutes) GetTransactionReceipt(c *gin.Context) {
+    times, ok := newTimeFormatter(c, t.networkUtils)
+    if !ok {
+        return
+    }
+
+    transaction, err := t.db.GetTransaction(c.Param("transactionId"))
+    if err != nil {
+        respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch transaction", err))
         return
     }
     if transaction.ID == "" {
-        c.JSON(http.StatusNotFound, gin.H{
-            "status": "Not Found",
-            "error":  "Node not found",
-        })
+        respondError(c, apperror.New(apperror.NotFound, "Transaction not found"))
         return
     }
 
-    method := ""
-    if transaction.Method == 0 {
-        method = "Spawn"
+    receipt := &types.TransactionReceipt{
+        ID:        transaction.ID,
+        State:     transaction.State(),
+        Status:    transaction.Status,
+        Message:   transaction.Message,
+        Layer:     transaction.Layer,
+        BlockId:   transaction.BlockID,
+        Time:      times.layer(uint64(transaction.Layer)),
+        Timestamp: config.GenesisEpochSeconds + int64(transaction.Layer)*config.LayerDuration,
+        Raw:       hex.EncodeToString(transaction.Raw),
     }
-    if transaction.Method == 16 {
-        method = "Spend"
+    if transaction.CreatedAt > 0 {
+        receipt.CreatedTime = times.unix(transaction.CreatedAt)
+        receipt.CreatedTimestamp = transaction.CreatedAt
     }
-    if transaction.Method == 17 {
-        method = "DrainVault"
+    if transaction.Complete {
+        receipt.GasUsed = transaction.Gas
+        receipt.FeePaid = transaction.Gas * transaction.GasPrice
+        if t.nodeClient != nil {
+            layer, err := t.nodeClient.Layer(transaction.Layer)
+            if err != nil {
+                log.Printf("Failed to get layer %d from the node: %s", transaction.Layer, err.Error())
+            } else if layer != nil {
+                receipt.LayerHash = hex.EncodeToString(layer.Hash)
+            }
+        }
     }
 
-    c.JSON(200, &types.Transaction{
-        ID:               transaction.ID,
-        Status:           transaction.Status,
-        PrincipalAccount: transaction.PrincipaAccount,
-        ReceiverAccount:  transaction.ReceiverAccount,
-        VaultAccount:     transaction.VaultAccount,
-        Fee:              transaction.Gas * transaction.GasPrice,
-        Amount:           transaction.Amount,
-        Layer:            transaction.Layer,
-        Counter:          transaction.Counter,
-        Method:           method,
-        Timestamp:        int64(config.GenesisEpochSeconds + (transaction.Layer * config.LayerDuration)),
-    })
+    c.JSON(200, receipt)
 }
@@ -0,0 +1,27 @@
+package route
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/swarmbit/spacemesh-state-api/pkg/apperror"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+// transactionState reads the ?status= filter of the transaction lists, created, success,
+// failure or applied for both results. Without it the complete parameter selects the
+// applied transactions, or the created ones with complete=false.
+func transactionState(c *gin.Context) (string, bool) {
+	status := strings.ToLower(c.Query("status"))
+	switch status {
+	case "":
+		if c.DefaultQuery("complete", "true") == "true" {
+			return types.TransactionApplied, true
+		}
+		return types.TransactionCreated, true
+	case types.TransactionCreated, types.TransactionSuccess, types.TransactionFailure, types.TransactionApplied:
+		return status, true
+	}
+	respondError(c, apperror.New(apperror.InvalidInput, "status must be one of created, success, failure or applied"))
+	return "", false
+}
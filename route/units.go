@@ -0,0 +1,170 @@
+package route
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/swarmbit/spacemesh-state-api/network"
+	"github.com/swarmbit/spacemesh-state-api/pkg/apperror"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+// smhProfile is the profile of the responses whose amounts also come in smesh.
+const smhProfile = "smh"
+
+// smidgeKeys are the json keys of the amounts in smidge. rewards is a count of rewards
+// in smesher performances and pool payouts, the objects with one of rewardsCountKeys.
+var smidgeKeys = map[string]bool{
+	"amount":                 true,
+	"available":              true,
+	"averageFee":             true,
+	"balance":                true,
+	"burnedFees":             true,
+	"change":                 true,
+	"circulatingSupply":      true,
+	"currentEpochRewardsSum": true,
+	"drained":                true,
+	"earned":                 true,
+	"epochRewards":           true,
+	"epochSubsidy":           true,
+	"fee":                    true,
+	"feePaid":                true,
+	"feesPaid":               true,
+	"genesisVaults":          true,
+	"initialUnlockAmount":    true,
+	"layerReward":            true,
+	"locked":                 true,
+	"maxFee":                 true,
+	"medianFee":              true,
+	"minFee":                 true,
+	"operatorFee":            true,
+	"payout":                 true,
+	"payouts":                true,
+	"predictedRewards":       true,
+	"projectedRewardPerSlot": true,
+	"rewards":                true,
+	"rewardsIssued":          true,
+	"rewardsSum":             true,
+	"subsidyIssued":          true,
+	"tenYearTarget":          true,
+	"totalFees":              true,
+	"totalIssuance":          true,
+	"totalRewards":           true,
+	"totalSubsidy":           true,
+	"totalSum":               true,
+	"totalSupply":            true,
+	"totalVaulted":           true,
+	"vested":                 true,
+}
+
+var rewardsCountKeys = []string{"expectedRewards", "earned", "operatorFee"}
+
+var smhUnits = &types.Units{
+	Amounts:        "smidge",
+	SmhSuffix:      "SMH",
+	SmidgePerSmh:   network.OneSmesh,
+	FiatValueScale: 1000000000,
+}
+
+// smhRequested reports if the request asks for the smh profile with ?units=smh or an
+// Accept-Profile header, false with a units parameter that is not valid.
+func smhRequested(c *gin.Context) (bool, bool) {
+	switch strings.ToLower(c.Query("units")) {
+	case smhProfile:
+		return true, true
+	case "smidge":
+		return false, true
+	case "":
+	default:
+		respondError(c, apperror.New(apperror.InvalidInput, "units must be smidge or smh"))
+		return false, false
+	}
+	for _, value := range strings.Split(c.GetHeader("Accept-Profile"), ",") {
+		value = strings.Trim(strings.TrimSpace(value), "<>")
+		if strings.EqualFold(value, smhProfile) {
+			return true, true
+		}
+	}
+	return false, true
+}
+
+// unitsProfile serves the smh profile, every amount in smidge of a successful json
+// response is followed by the same amount in smesh as a decimal string, its key with
+// the SMH suffix, and an object response gets the units block. Exports are left as they
+// are.
+func unitsProfile() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Writer.Header().Add("Vary", "Accept-Profile")
+		smh, ok := smhRequested(c)
+		if !ok {
+			c.Abort()
+			return
+		}
+		if !smh || exportFormat(c) != "" {
+			c.Next()
+			return
+		}
+		rewriteResponse(c, func(body json.RawMessage) (json.RawMessage, error) {
+			converted, err := withSmh(body)
+			if err == nil {
+				c.Header("Content-Profile", smhProfile)
+			}
+			return converted, err
+		})
+	}
+}
+
+// withSmh adds the smesh amounts to a json response and the units block to an object.
+func withSmh(body json.RawMessage) (json.RawMessage, error) {
+	converted, err := rewriteObjects(body, func(fields []jsonField) ([]jsonField, error) {
+		rewardsCount := false
+		for _, key := range rewardsCountKeys {
+			if fieldIndex(fields, key) >= 0 {
+				rewardsCount = true
+			}
+		}
+		withAmounts := make([]jsonField, 0, len(fields))
+		for _, field := range fields {
+			withAmounts = append(withAmounts, field)
+			if smidgeKeys[field.key] && !(field.key == "rewards" && rewardsCount) {
+				if smh, ok := toSmh(field.value); ok {
+					withAmounts = append(withAmounts, jsonField{key: field.key + "SMH", value: smh})
+				}
+			}
+		}
+		return withAmounts, nil
+	})
+	if err != nil || len(converted) == 0 || converted[0] != '{' {
+		return converted, err
+	}
+	units, err := json.Marshal(smhUnits)
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	out.Write(converted[:len(converted)-1])
+	if len(bytes.TrimSpace(converted[1:len(converted)-1])) > 0 {
+		out.WriteByte(',')
+	}
+	out.WriteString(`"units":`)
+	out.Write(units)
+	out.WriteByte('}')
+	return out.Bytes(), nil
+}
+
+// toSmh is the json string of a smidge amount in smesh, false when the value is not an
+// integer.
+func toSmh(value json.RawMessage) (json.RawMessage, bool) {
+	number := string(value)
+	if smidge, err := strconv.ParseUint(number, 10, 64); err == nil {
+		return json.RawMessage(strconv.Quote(network.ToSmesh(smidge))), true
+	}
+	smidge, err := strconv.ParseInt(number, 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	return json.RawMessage(strconv.Quote("-" + network.ToSmesh(uint64(-smidge)))), true
+}
@@ -0,0 +1,82 @@
+package route
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/network"
+	"github.com/swarmbit/spacemesh-state-api/pkg/apperror"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+// UtilsRoutes convert between layers and times with the genesis time and layer duration
+// of the network, without reading the database.
+type UtilsRoutes struct {
+	networkUtils *network.NetworkUtils
+}
+
+func NewUtilsRoutes(networkUtils *network.NetworkUtils) *UtilsRoutes {
+	return &UtilsRoutes{
+		networkUtils: networkUtils,
+	}
+}
+
+func (u *UtilsRoutes) toLayerTime(layer uint64, times *timeFormatter) *types.LayerTime {
+	epoch := u.networkUtils.GetEpoch(layer)
+	start := u.networkUtils.GetLayerTime(layer)
+	end := u.networkUtils.GetLayerTime(layer + 1)
+	return &types.LayerTime{
+		Layer:          layer,
+		Epoch:          epoch.Uint32(),
+		Start:          start.Unix(),
+		StartTime:      times.format(start),
+		End:            end.Unix(),
+		EndTime:        times.format(end),
+		EpochStartTime: times.epoch(uint64(epoch)),
+	}
+}
+
+// GetLayerToTime returns when the layer runs, layers in the future included.
+func (u *UtilsRoutes) GetLayerToTime(c *gin.Context) {
+	layer, err := strconv.ParseUint(c.Param("layer"), 10, 32)
+	if err != nil {
+		respondError(c, apperror.New(apperror.InvalidInput, "layer must be a positive integer"))
+		return
+	}
+	times, ok := newTimeFormatter(c, u.networkUtils)
+	if !ok {
+		return
+	}
+	c.JSON(200, u.toLayerTime(layer, times))
+}
+
+// GetTimeToLayer returns the layer running at timestamp, in unix seconds or RFC 3339.
+// Times before genesis have no layer.
+func (u *UtilsRoutes) GetTimeToLayer(c *gin.Context) {
+	timestamp := c.Query("timestamp")
+	var at time.Time
+	if seconds, err := strconv.ParseInt(timestamp, 10, 64); err == nil {
+		at = time.Unix(seconds, 0)
+	} else if parsed, err := time.Parse(time.RFC3339, timestamp); err == nil {
+		at = parsed
+	} else {
+		respondError(c, apperror.New(apperror.InvalidInput, "timestamp must be unix seconds or an RFC 3339 time"))
+		return
+	}
+	layer, ok := u.networkUtils.GetLayerAt(at)
+	if !ok {
+		respondError(c, apperror.New(apperror.InvalidInput, "timestamp must not be before genesis, "+time.Unix(config.GenesisEpochSeconds, 0).UTC().Format(time.RFC3339)))
+		return
+	}
+	if layer > uint64(^uint32(0)) {
+		respondError(c, apperror.New(apperror.InvalidInput, "timestamp is past the last layer"))
+		return
+	}
+	times, ok := newTimeFormatter(c, u.networkUtils)
+	if !ok {
+		return
+	}
+	c.JSON(200, u.toLayerTime(layer, times))
+}
@@ -0,0 +1,187 @@
+package route
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/swarmbit/spacemesh-state-api/config"
+)
+
+// API versions, the routes without a version prefix serve v1.
+const (
+	apiV1 = "v1"
+	apiV2 = "v2"
+)
+
+// objectShape reshapes the objects at path in a response. The path is "" for the
+// response itself, the name of a field for the value of the field and "[]" for every
+// item of an array, joined with dots: "nodes.[].recentRewards.[]".
+type objectShape struct {
+	path  string
+	apply func(fields []jsonField) []jsonField
+}
+
+func shapeAt(path string, apply func(fields []jsonField) []jsonField) objectShape {
+	return objectShape{path: path, apply: apply}
+}
+
+// routeShapes reshapes the responses of a route in version, the route keeps the v1
+// responses in the other versions. Exports are left as they are.
+func routeShapes(version string, shapesVersion string, shapes []objectShape) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if version != shapesVersion || exportFormat(c) != "" {
+			c.Next()
+			return
+		}
+		reshapeResponse(c, shapes)
+	}
+}
+
+// reshapeResponse applies shapes to the response of the next handlers, in order.
+func reshapeResponse(c *gin.Context, shapes []objectShape) {
+	rewriteResponse(c, func(body json.RawMessage) (json.RawMessage, error) {
+		for _, shape := range shapes {
+			var path []string
+			if shape.path != "" {
+				path = strings.Split(shape.path, ".")
+			}
+			rewritten, err := rewriteObjectsAt(body, path, shape.apply)
+			if err != nil {
+				return nil, err
+			}
+			body = rewritten
+		}
+		return body, nil
+	})
+}
+
+// renameFields renames the fields of names, an empty name drops the field.
+func renameFields(names map[string]string) func(fields []jsonField) []jsonField {
+	return func(fields []jsonField) []jsonField {
+		renamed := make([]jsonField, 0, len(fields))
+		for _, field := range fields {
+			name, ok := names[field.key]
+			switch {
+			case !ok:
+				renamed = append(renamed, field)
+			case name != "":
+				renamed = append(renamed, jsonField{key: name, value: field.value})
+			}
+		}
+		return renamed
+	}
+}
+
+// The v2 shapes of the v1 responses. v2 fixes the names of v1 and drops its legacy
+// fields.
+var (
+	committedV2 = renameFields(map[string]string{"effectiveUnitsCommited": "effectiveUnitsCommitted"})
+	accountV2   = renameFields(map[string]string{"balanceDisplay": ""})
+	rewardV2    = renameFields(map[string]string{"smesherId": "nodeId", "rewardsDisplay": ""})
+)
+
+// networkInfoV2 keeps the supply amounts of the network info only in its supply
+// breakdown, the rewards of the epoch are its totalRewards.
+func networkInfoV2(fields []jsonField) []jsonField {
+	fields = dropFields(fields, "circulatingSupply", "vested", "totalVaulted")
+	if i := fieldIndex(fields, "rewards"); i >= 0 {
+		fields[i].key = "totalRewards"
+	}
+	return committedV2(fields)
+}
+
+// transactionV2 groups the fields decoded from the payload of a transaction in decoded,
+// the counter is the nonce of the principal.
+func transactionV2(fields []jsonField) []jsonField {
+	decoded := make([]jsonField, 0)
+	shaped := make([]jsonField, 0, len(fields))
+	for _, field := range fields {
+		switch field.key {
+		case "method", "receiverAccount", "vaultAccount", "amount":
+			decoded = append(decoded, field)
+		case "counter":
+			shaped = append(shaped, jsonField{key: "nonce", value: field.value})
+		default:
+			shaped = append(shaped, field)
+		}
+	}
+	return append(shaped, jsonField{key: "decoded", value: writeObject(decoded)})
+}
+
+// networkInfoShapesV2 are the shapes of the network info at path.
+func networkInfoShapesV2(path string) []objectShape {
+	return []objectShape{
+		shapeAt(path, networkInfoV2),
+		shapeAt(joinShapePath(path, "nextEpoch"), committedV2),
+	}
+}
+
+func joinShapePath(path string, field string) string {
+	if path == "" {
+		return field
+	}
+	return path + "." + field
+}
+
+func dropFields(fields []jsonField, keys ...string) []jsonField {
+	kept := make([]jsonField, 0, len(fields))
+	for _, field := range fields {
+		if !slices.Contains(keys, field.key) {
+			kept = append(kept, field)
+		}
+	}
+	return kept
+}
+
+// configuredShapes are the renames the config makes in the responses of route in
+// version. A field is renamed in the object at the path before its last dot, in the
+// response itself when it has none.
+func configuredShapes(reloader *config.Reloader, version string, route string) []objectShape {
+	api := reloader.Current().Api
+	if api == nil {
+		return nil
+	}
+	byPath := make(map[string]map[string]string)
+	for field, name := range api.FieldNames[version][route] {
+		path := ""
+		if i := strings.LastIndex(field, "."); i >= 0 {
+			path, field = field[:i], field[i+1:]
+		}
+		if byPath[path] == nil {
+			byPath[path] = make(map[string]string)
+		}
+		byPath[path][field] = name
+	}
+	shapes := make([]objectShape, 0, len(byPath))
+	for path, names := range byPath {
+		shapes = append(shapes, shapeAt(path, renameFields(names)))
+	}
+	return shapes
+}
+
+// versionPath is the route without its version prefix, the config keys routes by it.
+func versionPath(route string) string {
+	for _, version := range config.ApiVersions {
+		if rest, ok := strings.CutPrefix(route, "/"+version+"/"); ok {
+			return "/" + rest
+		}
+	}
+	return route
+}
+
+// versionResponses serves the responses of a version, the handlers write the v1 ones and
+// the routes reshape theirs for the version with routeShapes. The renames of the config
+// for the route are applied over them. Exports are left as they are.
+func versionResponses(reloader *config.Reloader, version string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Api-Version", version)
+		shapes := configuredShapes(reloader, version, versionPath(c.FullPath()))
+		if len(shapes) == 0 || exportFormat(c) != "" {
+			c.Next()
+			return
+		}
+		reshapeResponse(c, shapes)
+	}
+}
@@ -0,0 +1,240 @@
+package route
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/network"
+	"github.com/swarmbit/spacemesh-state-api/pkg/address"
+	"github.com/swarmbit/spacemesh-state-api/pkg/apperror"
+	"github.com/swarmbit/spacemesh-state-api/price"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+// WatchlistRoutes manage the watchlist of the api key of the caller and summarize what
+// it watches. They are served by the instances that open the write store and run the
+// network state.
+type WatchlistRoutes struct {
+	db            database.ReadStore
+	writeDB       database.WriteStore
+	priceResolver *price.PriceResolver
+	networkUtils  *network.NetworkUtils
+	state         *network.NetworkState
+	summaries     *summaries
+	reloader      *config.Reloader
+}
+
+func NewWatchlistRoutes(db database.ReadStore, writeDB database.WriteStore, priceResolver *price.PriceResolver, networkUtils *network.NetworkUtils, state *network.NetworkState, reloader *config.Reloader) *WatchlistRoutes {
+	return &WatchlistRoutes{
+		db:            db,
+		writeDB:       writeDB,
+		priceResolver: priceResolver,
+		networkUtils:  networkUtils,
+		state:         state,
+		summaries:     newSummaries(db, networkUtils, state),
+		reloader:      reloader,
+	}
+}
+
+// AddWatchlistRoutes adds the /watchlist endpoints behind the watchlist api keys.
+func AddWatchlistRoutes(router *gin.Engine, watchlistRoutes *WatchlistRoutes, reloader *config.Reloader) {
+	watchlist := router.Group("/watchlist", apiKeyAuth(reloader, func(configValues *config.Config) []string {
+		if configValues.Watchlists == nil {
+			return nil
+		}
+		return configValues.Watchlists.ApiKeys
+	}))
+
+	watchlist.GET("", watchlistRoutes.GetWatchlist)
+	watchlist.POST("", watchlistRoutes.AddToWatchlist)
+	watchlist.DELETE("/addresses/:accountAddress", watchlistRoutes.RemoveAddress)
+	watchlist.DELETE("/nodes/:nodeId", watchlistRoutes.RemoveNode)
+	watchlist.GET("/summary", watchlistRoutes.GetWatchlistSummary)
+
+	log.Println("Added watchlist routes")
+}
+
+func (w *WatchlistRoutes) watchlistsConfig() *config.WatchlistsConfig {
+	if watchlists := w.reloader.Current().Watchlists; watchlists != nil {
+		return watchlists
+	}
+	return &config.WatchlistsConfig{}
+}
+
+// watchlist reads the watchlist of the caller.
+func (w *WatchlistRoutes) watchlist(c *gin.Context) (*types.Watchlist, bool) {
+	items, err := w.db.GetWatchlistItems(c.GetString(apiKeyOwner))
+	if err != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch watchlist", err))
+		return nil, false
+	}
+	watchlist := &types.Watchlist{
+		Addresses: make([]string, 0),
+		NodeIds:   make([]string, 0),
+	}
+	for _, item := range items {
+		switch item.Kind {
+		case types.WatchlistKindAddress:
+			watchlist.Addresses = append(watchlist.Addresses, item.Item)
+		case types.WatchlistKindNode:
+			watchlist.NodeIds = append(watchlist.NodeIds, item.Item)
+		}
+	}
+	return watchlist, true
+}
+
+func (w *WatchlistRoutes) GetWatchlist(c *gin.Context) {
+	watchlist, ok := w.watchlist(c)
+	if !ok {
+		return
+	}
+	c.JSON(200, watchlist)
+}
+
+// AddToWatchlist adds the addresses and node ids of the body, the ones already watched
+// are kept, and returns the watchlist.
+func (w *WatchlistRoutes) AddToWatchlist(c *gin.Context) {
+	var req types.WatchlistRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, apperror.New(apperror.InvalidInput, err.Error()))
+		return
+	}
+	addresses, err := address.NormalizeAddresses(req.Addresses)
+	if err != nil {
+		respondError(c, apperror.New(apperror.InvalidInput, err.Error()))
+		return
+	}
+	nodeIds, err := address.NormalizeNodeIds(req.NodeIds)
+	if err != nil {
+		respondError(c, apperror.New(apperror.InvalidInput, err.Error()))
+		return
+	}
+	if len(addresses) == 0 && len(nodeIds) == 0 {
+		respondError(c, apperror.New(apperror.InvalidInput, "addresses or nodeIds are required"))
+		return
+	}
+
+	watchlist, ok := w.watchlist(c)
+	if !ok {
+		return
+	}
+	owner := c.GetString(apiKeyOwner)
+	watched := make(map[string]bool)
+	for _, v := range watchlist.Addresses {
+		watched[types.WatchlistKindAddress+"-"+v] = true
+	}
+	for _, v := range watchlist.NodeIds {
+		watched[types.WatchlistKindNode+"-"+v] = true
+	}
+	total := len(watched)
+	now := time.Now().Unix()
+	items := make([]*types.WatchlistItemDoc, 0, len(addresses)+len(nodeIds))
+	add := func(kind string, item string) {
+		if watched[kind+"-"+item] {
+			return
+		}
+		watched[kind+"-"+item] = true
+		items = append(items, &types.WatchlistItemDoc{
+			ID:        owner + "-" + kind + "-" + item,
+			Owner:     owner,
+			Kind:      kind,
+			Item:      item,
+			CreatedAt: now,
+		})
+	}
+	for _, v := range addresses {
+		add(types.WatchlistKindAddress, v)
+	}
+	for _, v := range nodeIds {
+		add(types.WatchlistKindNode, v)
+	}
+
+	maxItems := 200
+	if configured := w.watchlistsConfig().MaxItems; configured > 0 {
+		maxItems = configured
+	}
+	if total+len(items) > maxItems {
+		respondError(c, apperror.New(apperror.InvalidInput, "watchlists are limited to "+strconv.Itoa(maxItems)+" addresses and node ids"))
+		return
+	}
+	if err := w.writeDB.AddWatchlistItems(items); err != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to update watchlist", err))
+		return
+	}
+	for _, item := range items {
+		if item.Kind == types.WatchlistKindAddress {
+			watchlist.Addresses = append(watchlist.Addresses, item.Item)
+		} else {
+			watchlist.NodeIds = append(watchlist.NodeIds, item.Item)
+		}
+	}
+	c.JSON(200, watchlist)
+}
+
+func (w *WatchlistRoutes) RemoveAddress(c *gin.Context) {
+	w.remove(c, types.WatchlistKindAddress, c.Param("accountAddress"))
+}
+
+func (w *WatchlistRoutes) RemoveNode(c *gin.Context) {
+	w.remove(c, types.WatchlistKindNode, c.Param("nodeId"))
+}
+
+func (w *WatchlistRoutes) remove(c *gin.Context, kind string, item string) {
+	removed, err := w.writeDB.RemoveWatchlistItem(c.GetString(apiKeyOwner), kind, item)
+	if err != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to update watchlist", err))
+		return
+	}
+	if !removed {
+		respondError(c, apperror.New(apperror.NotFound, "Not in the watchlist"))
+		return
+	}
+	c.Status(204)
+}
+
+// GetWatchlistSummary returns the balance, pending atxs, next epoch eligibility and
+// latest rewards of every watched address and node.
+func (w *WatchlistRoutes) GetWatchlistSummary(c *gin.Context) {
+	times, ok := newTimeFormatter(c, w.networkUtils)
+	if !ok {
+		return
+	}
+	watchlist, ok := w.watchlist(c)
+	if !ok {
+		return
+	}
+	recentRewards := int64(5)
+	if configured := w.watchlistsConfig().RecentRewards; configured > 0 {
+		recentRewards = int64(configured)
+	}
+
+	view := requestState(c, w.state)
+	epoch := view.GetInfo().Epoch
+	summary := &types.WatchlistSummary{
+		Epoch:     epoch,
+		Addresses: make([]*types.WatchlistAccount, 0, len(watchlist.Addresses)),
+		Nodes:     make([]*types.WatchlistNode, 0, len(watchlist.NodeIds)),
+	}
+	priceValue := w.priceResolver.GetPrice()
+	for _, accountAddress := range watchlist.Addresses {
+		account, err := w.summaries.account(view, accountAddress, epoch, recentRewards, priceValue, times)
+		if err != nil {
+			respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch watched address "+accountAddress, err))
+			return
+		}
+		summary.Addresses = append(summary.Addresses, account)
+	}
+	for _, nodeId := range watchlist.NodeIds {
+		node, _, err := w.summaries.node(view, nodeId, epoch, recentRewards, times)
+		if err != nil {
+			respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch watched node "+nodeId, err))
+			return
+		}
+		summary.Nodes = append(summary.Nodes, node)
+	}
+	c.JSON(200, summary)
+}
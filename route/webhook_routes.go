@@ -0,0 +1,249 @@
+package route
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/pkg/address"
+	"github.com/swarmbit/spacemesh-state-api/pkg/apperror"
+	"github.com/swarmbit/spacemesh-state-api/types"
+	"github.com/swarmbit/spacemesh-state-api/webhook"
+)
+
+// maxWebhookAddresses is how many addresses a webhook can follow
+const maxWebhookAddresses = 100
+
+var webhookEvents = []string{types.WebhookEventReward, types.WebhookEventTransaction, types.WebhookEventDigest}
+
+// WebhookRoutes manage the webhooks of the api key of the caller, keys only see their
+// own webhooks. They are served by the instances that open the write store.
+type WebhookRoutes struct {
+	db      database.ReadStore
+	writeDB database.WriteStore
+}
+
+func NewWebhookRoutes(db database.ReadStore, writeDB database.WriteStore) *WebhookRoutes {
+	return &WebhookRoutes{
+		db:      db,
+		writeDB: writeDB,
+	}
+}
+
+// AddWebhookRoutes adds the /webhooks endpoints behind the webhook api keys.
+func AddWebhookRoutes(router *gin.Engine, webhookRoutes *WebhookRoutes, reloader *config.Reloader) {
+	webhooks := router.Group("/webhooks", webhookAuth(reloader))
+
+	webhooks.POST("", webhookRoutes.CreateWebhook)
+	webhooks.GET("", webhookRoutes.GetWebhooks)
+	webhooks.GET("/:webhookId", webhookRoutes.GetWebhook)
+	webhooks.DELETE("/:webhookId", webhookRoutes.DeleteWebhook)
+	webhooks.GET("/:webhookId/deliveries", webhookRoutes.GetWebhookDeliveries)
+
+	log.Println("Added webhook routes")
+}
+
+// webhookAuth accepts the webhook api keys of the current config.
+func webhookAuth(reloader *config.Reloader) gin.HandlerFunc {
+	return apiKeyAuth(reloader, func(configValues *config.Config) []string {
+		if configValues.Webhooks == nil {
+			return nil
+		}
+		return configValues.Webhooks.ApiKeys
+	})
+}
+
+func randomHex(size int) (string, error) {
+	value := make([]byte, size)
+	if _, err := rand.Read(value); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(value), nil
+}
+
+func toWebhook(w *types.WebhookDoc) *types.Webhook {
+	return &types.Webhook{
+		ID:        w.ID,
+		Url:       w.Url,
+		Addresses: w.Addresses,
+		Events:    w.Events,
+		CreatedAt: w.CreatedAt,
+	}
+}
+
+// CreateWebhook registers a webhook for the addresses, the response has the secret the
+// deliveries are signed with, it is not returned again.
+func (w *WebhookRoutes) CreateWebhook(c *gin.Context) {
+	var req types.WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, apperror.New(apperror.InvalidInput, err.Error()))
+		return
+	}
+
+	target, err := url.Parse(req.Url)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		respondError(c, apperror.New(apperror.InvalidInput, "url must be an absolute http or https url"))
+		return
+	}
+	if err := webhook.CheckTarget(c.Request.Context(), target); err != nil {
+		respondError(c, err)
+		return
+	}
+	if len(req.Addresses) == 0 || len(req.Addresses) > maxWebhookAddresses {
+		respondError(c, apperror.New(apperror.InvalidInput, "addresses must have between 1 and "+strconv.Itoa(maxWebhookAddresses)+" addresses"))
+		return
+	}
+	addresses := make([]string, 0, len(req.Addresses))
+	for _, v := range req.Addresses {
+		normalized, err := address.NormalizeAddress(v)
+		if err != nil {
+			respondError(c, apperror.New(apperror.InvalidInput, err.Error()))
+			return
+		}
+		if !slices.Contains(addresses, normalized) {
+			addresses = append(addresses, normalized)
+		}
+	}
+	events := req.Events
+	if len(events) == 0 {
+		events = webhookEvents
+	}
+	for _, event := range events {
+		if !slices.Contains(webhookEvents, event) {
+			respondError(c, apperror.New(apperror.InvalidInput, "events must be reward, transaction or digest"))
+			return
+		}
+	}
+
+	id, errId := randomHex(16)
+	secret, errSecret := randomHex(32)
+	if errId != nil || errSecret != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to create webhook", errors.Join(errId, errSecret)))
+		return
+	}
+	webhookDoc := &types.WebhookDoc{
+		ID:        id,
+		Owner:     c.GetString(apiKeyOwner),
+		Url:       target.String(),
+		Addresses: addresses,
+		Events:    events,
+		Secret:    secret,
+		CreatedAt: time.Now().Unix(),
+	}
+	if err := w.writeDB.SaveWebhook(webhookDoc); err != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to create webhook", err))
+		return
+	}
+
+	response := toWebhook(webhookDoc)
+	response.Secret = webhookDoc.Secret
+	c.JSON(201, response)
+}
+
+func (w *WebhookRoutes) GetWebhooks(c *gin.Context) {
+	webhooks, err := w.db.GetWebhooks(c.GetString(apiKeyOwner))
+	if err != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch webhooks", err))
+		return
+	}
+	response := make([]*types.Webhook, len(webhooks))
+	for i, v := range webhooks {
+		response[i] = toWebhook(v)
+	}
+	c.JSON(200, response)
+}
+
+// ownWebhook returns the webhook of the path when it belongs to the caller.
+func (w *WebhookRoutes) ownWebhook(c *gin.Context) (*types.WebhookDoc, bool) {
+	webhook, err := w.db.GetWebhook(c.Param("webhookId"))
+	if err != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch webhook", err))
+		return nil, false
+	}
+	if webhook.ID == "" || subtle.ConstantTimeCompare([]byte(webhook.Owner), []byte(c.GetString(apiKeyOwner))) != 1 {
+		respondError(c, apperror.New(apperror.NotFound, "Webhook not found"))
+		return nil, false
+	}
+	return webhook, true
+}
+
+func (w *WebhookRoutes) GetWebhook(c *gin.Context) {
+	webhook, ok := w.ownWebhook(c)
+	if !ok {
+		return
+	}
+	c.JSON(200, toWebhook(webhook))
+}
+
+// DeleteWebhook deletes the webhook and its deliveries, pending deliveries are not sent.
+func (w *WebhookRoutes) DeleteWebhook(c *gin.Context) {
+	deleted, err := w.writeDB.DeleteWebhook(c.Param("webhookId"), c.GetString(apiKeyOwner))
+	if err != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to delete webhook", err))
+		return
+	}
+	if !deleted {
+		respondError(c, apperror.New(apperror.NotFound, "Webhook not found"))
+		return
+	}
+	c.Status(204)
+}
+
+// GetWebhookDeliveries returns the deliveries of the webhook with their status, latest
+// first.
+func (w *WebhookRoutes) GetWebhookDeliveries(c *gin.Context) {
+	offsetStr := c.DefaultQuery("offset", "0")
+	limitStr := c.DefaultQuery("limit", "20")
+
+	offset, err := strconv.Atoi(offsetStr)
+	if err != nil {
+		respondError(c, apperror.New(apperror.InvalidInput, "offset must be a valid integer"))
+		return
+	}
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil {
+		respondError(c, apperror.New(apperror.InvalidInput, "limit must be a valid integer"))
+		return
+	}
+	if offset < 0 || limit < 0 {
+		respondError(c, apperror.New(apperror.InvalidInput, "offset and limit must be greater or equal to 0"))
+		return
+	}
+
+	webhook, ok := w.ownWebhook(c)
+	if !ok {
+		return
+	}
+	deliveries, errDeliveries := w.db.GetWebhookDeliveries(webhook.ID, int64(offset), int64(limit))
+	count, errCount := w.db.CountWebhookDeliveries(webhook.ID)
+	if errDeliveries != nil || errCount != nil {
+		respondError(c, apperror.Wrap(apperror.Internal, "Failed to fetch webhook deliveries", errors.Join(errDeliveries, errCount)))
+		return
+	}
+
+	response := make([]*types.WebhookDelivery, len(deliveries))
+	for i, v := range deliveries {
+		response[i] = &types.WebhookDelivery{
+			ID:          v.ID,
+			Event:       v.Event,
+			Status:      v.Status,
+			Attempts:    v.Attempts,
+			NextAttempt: v.NextAttempt,
+			LastStatus:  v.LastStatus,
+			LastError:   v.LastError,
+			CreatedAt:   v.CreatedAt,
+			DeliveredAt: v.DeliveredAt,
+		}
+	}
+	c.Header("total", strconv.FormatInt(count, 10))
+	c.JSON(200, response)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+    "fmt"
+    "log"
+    "os"
+
+    "github.com/swarmbit/spacemesh-state-api/database"
+)
+
+// Recomputes a derived collection into a shadow collection and swaps it in, reads
+// keep being served from the current collection until the swap. The sink must be
+// stopped while it runs, its saves to the current collection would be lost.
+func main() {
+    if len(os.Args) < 3 {
+        fmt.Println("Usage: rebuild <mongo uri> <collection>")
+        fmt.Println("Collections:")
+        for _, v := range database.Rebuilds {
+            fmt.Println("  ", v.Collection)
+        }
+        os.Exit(1)
+    }
+
+    rebuild := database.GetRebuild(os.Args[2])
+    if rebuild == nil {
+        log.Fatalf("Collection %s can not be rebuilt", os.Args[2])
+    }
+
+    writeDB, err := database.NewWriteDB(os.Args[1], nil)
+    if err != nil {
+        log.Fatal(err)
+    }
+    defer writeDB.CloseWrite()
+
+    err = writeDB.RebuildCollection(rebuild)
+    if err != nil {
+        log.Fatal("Rebuild failed: ", err)
+    }
+    fmt.Println("Rebuilt", rebuild.Collection)
+}
@@ -130,11 +130,11 @@ func processAccountAtxEpoch(client *mongo.Client, epoch int32) error {
             },
             }},
         bson.D{
-            {"$group", bson.D{
-                {"_id", bson.D{{"coinbase", "$coinbase"}}},
-                {"totalEffectiveNumUnits", bson.D{{"$sum", "$effective_num_units"}}},
-                {"totalWeight", bson.D{{"$sum", "$weight"}}},
-                {"totalAtx", bson.D{{"$sum", 1}}},
+            {Key: "$group", Value: bson.D{
+                {Key: "_id", Value: bson.D{{Key: "coinbase", Value: "$coinbase"}}},
+                {Key: "totalEffectiveNumUnits", Value: bson.D{{Key: "$sum", Value: "$effective_num_units"}}},
+                {Key: "totalWeight", Value: bson.D{{Key: "$sum", Value: "$weight"}}},
+                {Key: "totalAtx", Value: bson.D{{Key: "$sum", Value: 1}}},
             }},
         },
     }
@@ -0,0 +1,92 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "log"
+    "os"
+
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Backfills the issued subsidy and fees paid totals of the network info document
+// for databases populated before the sink started maintaining them.
+func main() {
+    if len(os.Args) < 2 {
+        log.Fatal("Usage: update_network_info <mongo uri>")
+    }
+
+    clientOptions := options.Client().ApplyURI(os.Args[1])
+
+    client, err := mongo.Connect(context.TODO(), clientOptions)
+    if err != nil {
+        log.Fatal(err)
+    }
+
+    err = client.Ping(context.TODO(), nil)
+    if err != nil {
+        log.Fatal(err)
+    }
+
+    fmt.Println("Connected to MongoDB!")
+
+    issuedSubsidy, err := sum(client, "rewards", "$layerReward")
+    if err != nil {
+        log.Fatal("Failed to sum issued subsidy: ", err)
+    }
+    fmt.Println("Issued subsidy: ", issuedSubsidy)
+
+    feesPaid, err := sum(client, "accounts", "$fees")
+    if err != nil {
+        log.Fatal("Failed to sum fees paid: ", err)
+    }
+    fmt.Println("Fees paid: ", feesPaid)
+
+    _, err = client.Database("spacemesh").Collection("networkInfo").UpdateOne(
+        context.TODO(),
+        bson.D{{Key: "_id", Value: "info"}},
+        bson.D{{Key: "$set", Value: bson.D{
+            {Key: "issuedSubsidy", Value: issuedSubsidy},
+            {Key: "feesPaid", Value: feesPaid},
+        }}},
+        options.Update().SetUpsert(true),
+    )
+    if err != nil {
+        log.Fatal(err)
+    }
+
+    err = client.Disconnect(context.TODO())
+    if err != nil {
+        log.Fatal(err)
+    }
+    fmt.Println("Connection to MongoDB closed.")
+}
+
+func sum(client *mongo.Client, collection string, field string) (int64, error) {
+    coll := client.Database("spacemesh").Collection(collection)
+
+    group := bson.D{
+        {Key: "$group", Value: bson.D{
+            {Key: "_id", Value: nil},
+            {Key: "totalSum", Value: bson.D{{Key: "$sum", Value: field}}},
+        }},
+    }
+
+    cursor, err := coll.Aggregate(context.TODO(), mongo.Pipeline{group}, options.Aggregate().SetAllowDiskUse(true))
+    if err != nil {
+        return 0, err
+    }
+
+    var results []struct {
+        TotalSum int64 `bson:"totalSum"`
+    }
+    if err = cursor.All(context.TODO(), &results); err != nil {
+        return 0, err
+    }
+    if len(results) == 0 {
+        return 0, nil
+    }
+    return results[0].TotalSum, nil
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/swarmbit/spacemesh-state-api/checkpoint"
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/sink"
+	"github.com/swarmbit/spacemesh-state-api/verify"
+)
+
+// invocation is a command line after its flags were parsed, args are the positional
+// arguments before the config path. The config is only loaded by commands that need it,
+// after their flags added overrides.
+type invocation struct {
+	args       []string
+	configPath string
+	overrides  []string
+}
+
+func (i *invocation) load() *config.Config {
+	return readConfig(i.configPath, i.overrides)
+}
+
+// command is a subcommand of the server binary, they all take the -set overrides and
+// an optional config path after their arguments. flags defines the flags of the command
+// and returns what runs it.
+type command struct {
+	name       string
+	arguments  string
+	summary    string
+	positional int
+	flags      func(flagSet *flag.FlagSet) func(inv *invocation) error
+}
+
+// defaultCommand runs when the first argument is not a command, so `server config.json`
+// keeps serving.
+const defaultCommand = "serve"
+
+var commands = []*command{
+	{
+		name:    "serve",
+		summary: "run the api and the sink as the config mode says, all when empty",
+		flags: func(flagSet *flag.FlagSet) func(inv *invocation) error {
+			mode := flagSet.String("mode", "", "run mode all, sink or api, overrides the config")
+			migrateOnly := flagSet.Bool("migrate", false, "apply database migrations and exit, like the migrate command")
+			return func(inv *invocation) error {
+				if *migrateOnly {
+					return migrate(inv)
+				}
+				if *mode != "" {
+					inv.overrides = append(inv.overrides, "mode="+*mode)
+				}
+				serve(inv)
+				return nil
+			}
+		},
+	},
+	{
+		name:    "sink",
+		summary: "run only the sink and the writers, like serve -mode sink",
+		flags: func(flagSet *flag.FlagSet) func(inv *invocation) error {
+			return func(inv *invocation) error {
+				inv.overrides = append(inv.overrides, "mode="+config.ModeSink)
+				serve(inv)
+				return nil
+			}
+		},
+	},
+	{
+		name:    "migrate",
+		summary: "apply the database migrations and exit",
+		flags: func(flagSet *flag.FlagSet) func(inv *invocation) error {
+			return migrate
+		},
+	},
+	{
+		name:    "backfill",
+		summary: "recompute derived collections from the stored rewards and atxs with the sink stopped, mongo only",
+		flags: func(flagSet *flag.FlagSet) func(inv *invocation) error {
+			collections := flagSet.String("collections", "", "comma separated collections to recompute, all of "+rebuildNames()+" when empty")
+			return func(inv *invocation) error {
+				return backfill(inv, *collections)
+			}
+		},
+	},
+	{
+		name:    "upgrade",
+		summary: "store the current format of outdated versioned documents, mongo only",
+		flags: func(flagSet *flag.FlagSet) func(inv *invocation) error {
+			collections := flagSet.String("collections", "", "comma separated collections to upgrade, all of "+upgradeNames()+" when empty")
+			return func(inv *invocation) error {
+				return upgradeDocuments(inv, *collections)
+			}
+		},
+	},
+	{
+		name:       "snapshot",
+		arguments:  "create|restore <dir>",
+		summary:    "dump the database into dir or load a dump into an empty database, mongo only",
+		positional: 2,
+		flags: func(flagSet *flag.FlagSet) func(inv *invocation) error {
+			return snapshot
+		},
+	},
+	{
+		name:       "checkpoint",
+		arguments:  "import|export <file>",
+		summary:    "seed the database from a go-spacemesh checkpoint file or write one",
+		positional: 2,
+		flags: func(flagSet *flag.FlagSet) func(inv *invocation) error {
+			restoreLayer := flagSet.Uint("restore-layer", 0, "restore layer of the imported checkpoint, read from the checkpoint id when 0")
+			return func(inv *invocation) error {
+				return checkpointFile(inv, uint32(*restoreLayer))
+			}
+		},
+	},
+	{
+		name:    "verify",
+		summary: "cross check rewards, atxs and sampled balances against the state database of a node",
+		flags: func(flagSet *flag.FlagSet) func(inv *invocation) error {
+			stateDB := flagSet.String("state-db", "", "path to state.sql of a go-spacemesh node synced past the verified layers")
+			from := flagSet.Uint("from", 0, "first verified layer, the epoch before -to when 0")
+			to := flagSet.Uint("to", 0, "last verified layer, the last layer both processed when 0")
+			sample := flagSet.Int("sample", 100, "number of accounts chosen at random to compare the balances of")
+			return func(inv *invocation) error {
+				if *stateDB == "" {
+					return errors.New("verify needs the -state-db of a node")
+				}
+				return verifyState(inv, *stateDB, uint32(*from), uint32(*to), *sample)
+			}
+		},
+	},
+}
+
+func findCommand(name string) *command {
+	for _, v := range commands {
+		if v.name == name {
+			return v
+		}
+	}
+	return nil
+}
+
+func printCommands() {
+	log.Printf("Usage: server [command] [flags] [arguments] [path to config json or yaml]")
+	log.Printf("The config file is optional, fields are overridden by %s environment variables and then by -set", config.EnvPrefix)
+	log.Printf("Commands, %s when none is given:", defaultCommand)
+	for _, v := range commands {
+		log.Printf("  %-10s %s", v.name, v.summary)
+	}
+	log.Printf("Run server <command> -h for the flags of a command")
+}
+
+func migrate(inv *invocation) error {
+	configValues := inv.load()
+	if err := database.RunMigrations(configValues.DB); err != nil {
+		return err
+	}
+	log.Println("Migrations applied")
+	return nil
+}
+
+func serve(inv *invocation) {
+	configValues := inv.load()
+	// the same file and overrides are read again on reload
+	reloader := config.NewReloader(inv.configPath, inv.overrides, configValues)
+	reloader.Start()
+	StartServer(reloader)
+}
+
+func rebuildNames() string {
+	names := make([]string, len(database.Rebuilds))
+	for i, v := range database.Rebuilds {
+		names[i] = v.Collection
+	}
+	return strings.Join(names, ", ")
+}
+
+func backfill(inv *invocation, collections string) error {
+	var names []string
+	if collections == "" {
+		for _, v := range database.Rebuilds {
+			names = append(names, v.Collection)
+		}
+	} else {
+		names = strings.Split(collections, ",")
+	}
+	for _, name := range names {
+		if database.GetRebuild(strings.TrimSpace(name)) == nil {
+			return fmt.Errorf("collection %s can not be recomputed, it must be one of %s", name, rebuildNames())
+		}
+	}
+
+	configValues := inv.load()
+	writeDB, readDB, err := database.NewStores(configValues.DB)
+	if err != nil {
+		return err
+	}
+	defer readDB.CloseRead()
+	defer writeDB.CloseWrite()
+	for _, name := range names {
+		if err := writeDB.RebuildAggregate(strings.TrimSpace(name)); err != nil {
+			return fmt.Errorf("failed to recompute %s: %w", name, err)
+		}
+		log.Printf("Recomputed %s", name)
+	}
+	return nil
+}
+
+func upgradeNames() string {
+	names := make([]string, len(database.DocumentUpgrades))
+	for i, v := range database.DocumentUpgrades {
+		names[i] = v.CollectionName()
+	}
+	return strings.Join(names, ", ")
+}
+
+// upgradeDocuments can run while the api and the sink run, reads upgrade the documents
+// it did not store yet and writes store the current format.
+func upgradeDocuments(inv *invocation, collections string) error {
+	var names []string
+	if collections == "" {
+		for _, v := range database.DocumentUpgrades {
+			names = append(names, v.CollectionName())
+		}
+	} else {
+		names = strings.Split(collections, ",")
+	}
+	for _, name := range names {
+		if database.GetDocumentUpgrade(strings.TrimSpace(name)) == nil {
+			return fmt.Errorf("collection %s has no versioned documents, it must be one of %s", name, upgradeNames())
+		}
+	}
+
+	configValues := inv.load()
+	writeDB, readDB, err := database.NewStores(configValues.DB)
+	if err != nil {
+		return err
+	}
+	defer readDB.CloseRead()
+	defer writeDB.CloseWrite()
+	for _, name := range names {
+		upgraded, err := writeDB.UpgradeDocuments(strings.TrimSpace(name))
+		if err != nil {
+			return fmt.Errorf("failed to upgrade %s: %w", name, err)
+		}
+		log.Printf("Upgraded %d %s documents", upgraded, name)
+	}
+	return nil
+}
+
+// snapshot is taken with the sink stopped and restored before it starts on the new
+// database.
+func snapshot(inv *invocation) error {
+	action, dir := inv.args[0], inv.args[1]
+	if action != "create" && action != "restore" {
+		return errors.New("snapshot takes create or restore")
+	}
+	configValues := inv.load()
+	if action == "create" {
+		manifest, err := database.CreateSnapshot(configValues.DB, dir)
+		if err != nil {
+			return err
+		}
+		log.Printf("Created snapshot of %d collections at layer %d", len(manifest.Collections), manifest.LastProcessedLayer)
+		return nil
+	}
+	manifest, err := database.RestoreSnapshot(configValues.DB, dir)
+	if err != nil {
+		return err
+	}
+	log.Printf("Restored snapshot of %d collections at layer %d", len(manifest.Collections), manifest.LastProcessedLayer)
+	return nil
+}
+
+func checkpointFile(inv *invocation, restoreLayer uint32) error {
+	action, file := inv.args[0], inv.args[1]
+	if action != "import" && action != "export" {
+		return errors.New("checkpoint takes import or export")
+	}
+	configValues := inv.load()
+	writeDB, readDB, err := database.NewStores(configValues.DB)
+	if err != nil {
+		return err
+	}
+	defer readDB.CloseRead()
+	defer writeDB.CloseWrite()
+	if action == "import" {
+		restoreLayer, err = checkpoint.Import(file, restoreLayer, writeDB)
+		if err != nil {
+			return err
+		}
+		if configValues.Nats == nil || !configValues.Nats.Enabled {
+			log.Printf("Nats is disabled, the sink consumers were not aligned with restore layer %d", restoreLayer)
+			return nil
+		}
+		// the events of the restore layer are published once it started
+		start := time.Unix(config.GenesisEpochSeconds+int64(restoreLayer)*config.LayerDuration, 0)
+		return sink.AlignConsumers(configValues.Nats, writeDB, start, restoreLayer-1)
+	}
+	return checkpoint.Export(file, readDB)
+}
+
+// verifyState fails when a divergence is found, so it can gate a deployment.
+func verifyState(inv *invocation, stateDBPath string, from uint32, to uint32, sample int) error {
+	configValues := inv.load()
+	stateDB, err := verify.OpenStateDB(stateDBPath)
+	if err != nil {
+		return err
+	}
+	defer stateDB.Close()
+	writeDB, readDB, err := database.NewStores(configValues.DB)
+	if err != nil {
+		return err
+	}
+	defer readDB.CloseRead()
+	defer writeDB.CloseWrite()
+
+	report, err := verify.Run(readDB, stateDB, from, to, sample)
+	if err != nil {
+		return err
+	}
+	for _, v := range report.Divergences {
+		log.Printf("Divergent %s of %s, connector %d and node %d", v.Check, v.Key, v.Connector, v.Node)
+	}
+	if len(report.Divergences) > 0 {
+		return fmt.Errorf("found %d divergences between layers %d and %d", len(report.Divergences), report.From, report.To)
+	}
+	log.Printf("No divergences between layers %d and %d", report.From, report.To)
+	return nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// httpServer serves the api over http, or https and http/2 when tls is enabled. With
+// autocert a second server answers the challenges of Let's Encrypt.
+type httpServer struct {
+	server    *http.Server
+	challenge *http.Server
+	tls       *config.TlsConfig
+}
+
+// serverSeconds is the configured seconds, or fallback when not configured.
+func serverSeconds(seconds int, fallback time.Duration) time.Duration {
+	if seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return fallback
+}
+
+func newHttpServer(serverConfig *config.ServerConfig, handler http.Handler) *httpServer {
+	maxHeaderBytes := http.DefaultMaxHeaderBytes
+	if serverConfig.MaxHeaderBytes > 0 {
+		maxHeaderBytes = serverConfig.MaxHeaderBytes
+	}
+	s := &httpServer{
+		server: &http.Server{
+			Addr:              serverConfig.Port,
+			Handler:           handler,
+			ReadTimeout:       serverSeconds(serverConfig.ReadTimeout, 0),
+			ReadHeaderTimeout: serverSeconds(serverConfig.ReadHeaderTimeout, 10*time.Second),
+			WriteTimeout:      serverSeconds(serverConfig.WriteTimeout, 0),
+			IdleTimeout:       serverSeconds(serverConfig.IdleTimeout, 120*time.Second),
+			MaxHeaderBytes:    maxHeaderBytes,
+		},
+	}
+	if serverConfig.Tls == nil || !serverConfig.Tls.Enabled {
+		return s
+	}
+	s.tls = serverConfig.Tls
+	if !s.tls.Autocert {
+		s.server.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		return s
+	}
+
+	cacheDir := "autocert"
+	if s.tls.CacheDir != "" {
+		cacheDir = s.tls.CacheDir
+	}
+	manager := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(s.tls.Domains...),
+		Cache:      autocert.DirCache(cacheDir),
+		Email:      s.tls.Email,
+	}
+	s.server.TLSConfig = manager.TLSConfig()
+	httpPort := ":80"
+	if s.tls.HttpPort != "" {
+		httpPort = s.tls.HttpPort
+	}
+	s.challenge = &http.Server{
+		Addr:              httpPort,
+		Handler:           manager.HTTPHandler(nil),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return s
+}
+
+// listen blocks serving until the server is shut down, it returns
+// http.ErrServerClosed then.
+func (s *httpServer) listen() error {
+	if s.tls == nil {
+		log.Printf("Listen and serve http on %s", s.server.Addr)
+		return s.server.ListenAndServe()
+	}
+	if s.challenge != nil {
+		go func() {
+			log.Printf("Listen for certificate challenges on %s", s.challenge.Addr)
+			if err := s.challenge.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Printf("Certificate challenge server closed: %v", err)
+			}
+		}()
+		log.Printf("Listen and serve https on %s for %v", s.server.Addr, s.tls.Domains)
+		return s.server.ListenAndServeTLS("", "")
+	}
+	log.Printf("Listen and serve https on %s", s.server.Addr)
+	return s.server.ListenAndServeTLS(s.tls.CertFile, s.tls.KeyFile)
+}
+
+// shutdown stops accepting connections and waits for the in flight requests until ctx
+// is done, the connections still open then are closed.
+func (s *httpServer) shutdown(ctx context.Context) {
+	if s.challenge != nil {
+		s.challenge.Shutdown(ctx)
+	}
+	if err := s.server.Shutdown(ctx); err != nil {
+		log.Printf("Requests still in flight, closing: %v", err)
+		s.server.Close()
+	}
+}
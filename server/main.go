@@ -1,34 +1,76 @@
 package main
 
 import (
-	"encoding/json"
+	"flag"
 	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/network"
 	"log"
 	"os"
+	"strings"
 )
 
-func main() {
-	StartServer(readConfig())
+// overrideFlags collects the repeated -set flags.
+type overrideFlags []string
+
+func (o *overrideFlags) String() string {
+	return strings.Join(*o, ",")
+}
+
+func (o *overrideFlags) Set(value string) error {
+	*o = append(*o, value)
+	return nil
 }
 
-func readConfig() *config.Config {
-	if len(os.Args) < 2 {
-		log.Fatal("Usage: server <path to config>")
+func main() {
+	args := os.Args[1:]
+	if len(args) > 0 && (args[0] == "help" || args[0] == "-h" || args[0] == "-help" || args[0] == "--help") {
+		printCommands()
+		return
+	}
+	cmd := findCommand(defaultCommand)
+	if len(args) > 0 {
+		if named := findCommand(args[0]); named != nil {
+			cmd = named
+			args = args[1:]
+		}
 	}
 
-	filePath := os.Args[1]
+	flagSet := flag.NewFlagSet(cmd.name, flag.ExitOnError)
+	var overrides overrideFlags
+	flagSet.Var(&overrides, "set", "override a config field with path=value, like db.uri=mongodb://localhost:27017, can be repeated")
+	run := cmd.flags(flagSet)
+	flagSet.Usage = func() {
+		log.Printf("Usage: server %s [flags] %s [path to config json or yaml]", cmd.name, cmd.arguments)
+		log.Printf("%s", cmd.summary)
+		flagSet.PrintDefaults()
+	}
+	flagSet.Parse(args)
 
-	file, err := os.Open(filePath)
-	if err != nil {
+	positional := flagSet.Args()
+	if len(positional) < cmd.positional || len(positional) > cmd.positional+1 {
+		flagSet.Usage()
+		os.Exit(2)
+	}
+	inv := &invocation{
+		args:      positional[:cmd.positional],
+		overrides: overrides,
+	}
+	if len(positional) > cmd.positional {
+		inv.configPath = positional[cmd.positional]
+	}
+	if err := run(inv); err != nil {
 		log.Fatal(err)
 	}
-	defer file.Close()
+}
 
-	decoder := json.NewDecoder(file)
-	configValues := config.Config{}
-	err = decoder.Decode(&configValues)
+// readConfig loads the config and sets the network it follows.
+func readConfig(path string, overrides []string) *config.Config {
+	configValues, err := config.Load(path, overrides)
 	if err != nil {
 		log.Fatal(err)
 	}
-	return &configValues
+	if err := network.UseNetwork(configValues.Network); err != nil {
+		log.Fatal(err)
+	}
+	return configValues
 }
@@ -1,88 +1,337 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+	_ "time/tzdata"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/swarmbit/spacemesh-state-api/aggregation"
+	"github.com/swarmbit/spacemesh-state-api/alert"
 	"github.com/swarmbit/spacemesh-state-api/config"
 	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/email"
+	"github.com/swarmbit/spacemesh-state-api/events"
+	"github.com/swarmbit/spacemesh-state-api/metrics"
+	"github.com/swarmbit/spacemesh-state-api/network"
+	"github.com/swarmbit/spacemesh-state-api/node"
 	"github.com/swarmbit/spacemesh-state-api/price"
+	"github.com/swarmbit/spacemesh-state-api/processor"
 	"github.com/swarmbit/spacemesh-state-api/route"
 	"github.com/swarmbit/spacemesh-state-api/sink"
+	"github.com/swarmbit/spacemesh-state-api/tracing"
 )
 
-func StartServer(configValues *config.Config) {
+func StartServer(reloader *config.Reloader) {
+	configValues := reloader.Current()
 
-	connection := configValues.DB.Uri
-	writeDB, err := database.NewWriteDB(connection)
-	if err != nil {
-		panic("Failed to open document write db")
+	mode := configValues.Mode
+	if mode == "" {
+		mode = config.ModeAll
+	}
+	if mode != config.ModeAll && mode != config.ModeSink && mode != config.ModeApi {
+		log.Fatalf("Unknown run mode %s, must be all, sink or api", mode)
+	}
+	runSink := mode != config.ModeApi
+	runApi := mode != config.ModeSink
+	log.Printf("Running in %s mode", mode)
+
+	// before the stores so their clients are instrumented
+	shutdownTracing := tracing.Init(configValues.Tracing)
+
+	// api instances never open the write store, only the sink writes
+	var writeDB database.WriteStore
+	var readDB database.ReadStore
+	var err error
+	if runSink {
+		writeDB, readDB, err = database.NewStores(configValues.DB)
+	} else {
+		readDB, err = database.NewReadStore(configValues.DB)
 	}
-	readDB, err := database.NewReadDB(connection)
 	if err != nil {
-		panic("Failed to open document read db")
+		log.Println(err)
+		panic("Failed to open dbs")
 	}
 	log.Println("Created dbs")
 
-	priceResolver := price.NewPriceResolver(configValues)
+	priceResolver := price.NewPriceResolver(configValues, writeDB)
+	reloader.OnReload(priceResolver.Reload)
 	log.Println("Created price resolver")
 
-	if configValues.Nats.Enabled {
-		s := sink.NewSink(configValues, writeDB)
-		s.StartRewardsSink()
-		s.StartLayersSink()
-		s.StartAtxSink()
-		s.StartTransactionCreatedSink()
-		s.StartTransactionResultSink()
-		s.StartMalfeasanceSink()
+	if runSink && configValues.Sync != nil && configValues.Sync.Enabled && !writeDB.Capabilities().ChangeFeed {
+		log.Printf("Change feed is not supported by the %s backend, sync disabled", configValues.DB.Backend)
+	} else if runSink && configValues.Sync != nil && configValues.Sync.Enabled {
+		retentionDays := 7
+		if configValues.Sync.RetentionDays > 0 {
+			retentionDays = configValues.Sync.RetentionDays
+		}
+		err = writeDB.EnableChangeFeed(time.Duration(retentionDays) * 24 * time.Hour)
+		if err != nil {
+			panic("Failed to enable change feed")
+		}
+		log.Println("Enabled change feed")
 	}
 
-	gin.SetMode(gin.ReleaseMode)
-	router := gin.Default()
+	recordHistory := runSink && configValues.History != nil && configValues.History.Enabled
 
-	router.Use(func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+	networkUtils := network.NewNetworkUtils()
+	log.Println("Created network utils")
+	var nodeClient *node.NodeClient
+	if configValues.Node.Enabled {
+		nodeClient, err = node.NewNodeClient(configValues)
+		if err != nil {
+			log.Println(err)
+			panic("Failed to create node client")
+		}
+		reloader.OnReload(nodeClient.Reload)
+		log.Println("Created node client")
+	}
+
+	var state *network.NetworkState
+	if runApi || recordHistory {
+		state = network.NewNetworkState(readDB, networkUtils, priceResolver, nodeClient, configValues.State)
+		reloader.OnReload(func(reloaded *config.Config) {
+			state.Reload(reloaded.State)
+		})
+		log.Println("Created state")
+	}
+
+	healthRoutes := route.NewHealthRoutes(nodeClient)
+
+	var adminRoutes *route.AdminRoutes
+	if configValues.Admin != nil && configValues.Admin.Enabled && configValues.Admin.ApiKey != "" {
+		adminRoutes = route.NewAdminRoutes(readDB, writeDB, priceResolver, state)
+	}
+
+	// the sink publishes what it saved on it, modules of this instance subscribe to it
+	// before the sink starts
+	bus := events.NewBus()
+
+	// set once the writers started, it is stopped on shutdown before the stores close
+	var runningSink atomic.Pointer[sink.Sink]
+
+	// everything that writes, with leader election it only starts on the leader
+	startWriters := func() {
+		if recordHistory {
+			recorder := aggregation.NewNetworkHistoryRecorder(configValues, writeDB, state)
+			reloader.OnReload(recorder.Reload)
+			log.Println("Created network history recorder")
+		}
+
+		if configValues.Nats.Enabled {
+			// before the sink so the first rewards and transactions find the genesis balances
+			imported, err := writeDB.ImportGenesisLedger(network.GenesisLedger())
+			if err != nil {
+				log.Println(err)
+				panic("Failed to import genesis ledger")
+			}
+			if imported {
+				log.Println("Imported genesis ledger")
+			}
+
+			if configValues.Processors != nil && configValues.Processors.Enabled {
+				if err := processor.Start(reloader, bus, writeDB, readDB); err != nil {
+					log.Println(err)
+					panic("Failed to start processors")
+				}
+			}
+
+			s := sink.NewSink(configValues, writeDB, readDB, priceResolver, bus)
+			s.Start()
+			runningSink.Store(s)
+			adminRoutes.SetSink(s)
+			healthRoutes.SetSink(s)
+
+			if configValues.Consistency != nil && configValues.Consistency.Enabled {
+				checker := sink.NewConsistencyChecker(configValues, s, readDB)
+				reloader.OnReload(checker.Reload)
+				log.Println("Created consistency checker")
+			}
+
+			smeshersAggregator := aggregation.NewSmeshersAggregator(configValues, writeDB, readDB)
+			reloader.OnReload(smeshersAggregator.Reload)
+			log.Println("Created smeshers aggregator")
+
+			rollupAggregator := aggregation.NewRewardsRollupAggregator(configValues, writeDB, readDB)
+			reloader.OnReload(rollupAggregator.Reload)
+			log.Println("Created rewards rollup aggregator")
+
+			feesAggregator := aggregation.NewFeesRollupAggregator(configValues, writeDB, readDB)
+			reloader.OnReload(feesAggregator.Reload)
+			log.Println("Created fees rollup aggregator")
+
+			if configValues.Digests != nil && configValues.Digests.Enabled {
+				digestAggregator := aggregation.NewRewardDigestAggregator(configValues, writeDB, readDB, bus)
+				reloader.OnReload(digestAggregator.Reload)
+				log.Println("Created reward digest aggregator")
+			}
+
+			pendingExpirer := aggregation.NewPendingExpirer(configValues, writeDB, readDB, nodeClient)
+			reloader.OnReload(pendingExpirer.Reload)
+			log.Println("Created pending transactions expirer")
+		}
+
+		if configValues.Stats != nil && configValues.Stats.Enabled {
+			statsRecorder := aggregation.NewStatsRecorder(configValues, writeDB, readDB)
+			reloader.OnReload(statsRecorder.Reload)
+			log.Println("Created stats recorder")
+		}
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
+		if configValues.PoetHealth.Enabled {
+			checker := aggregation.NewPoetHealthChecker(reloader, writeDB, readDB)
+			reloader.OnReload(checker.Reload)
+			log.Println("Created poet health checker")
 		}
+
+		if configValues.Alerts != nil && configValues.Alerts.Enabled {
+			alerter := alert.NewAlerter(reloader, writeDB, readDB, networkUtils)
+			reloader.OnReload(alerter.Reload)
+			log.Println("Created alerter")
+		}
+
+		if configValues.Emails != nil && configValues.Emails.Enabled {
+			mailer := email.NewDigestMailer(reloader, writeDB, readDB, networkUtils)
+			reloader.OnReload(mailer.Reload)
+			log.Println("Created email digest mailer")
+		}
+
+		if configValues.Retention != nil && configValues.Retention.Enabled {
+			pruner := aggregation.NewRetentionPruner(configValues, writeDB, readDB)
+			reloader.OnReload(pruner.Reload)
+			log.Println("Created retention pruner")
+		}
+	}
+
+	var election *sink.LeaderElection
+	if runSink && configValues.Nats.Enabled {
+		instanceId := configValues.Nats.InstanceId
+		if instanceId == "" {
+			hostname, _ := os.Hostname()
+			instanceId = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+		}
+		if configValues.Nats.LeaderElection {
+			election = sink.NewLeaderElection(configValues.Nats, writeDB, instanceId)
+			started := false
+			go election.Run(func() {
+				writeDB.StartFenceCheck(10 * time.Second)
+				// the periodic writers skip their runs while fenced off and carry on
+				// once acquired again, only the sink consumers stopped
+				if started {
+					if s := runningSink.Load(); s != nil {
+						s.Resume()
+					}
+					return
+				}
+				started = true
+				startWriters()
+			})
+		} else {
+			_, err = writeDB.AcquireFence(instanceId)
+			if err != nil {
+				panic("Failed to acquire sink fence")
+			}
+			writeDB.StartFenceCheck(10 * time.Second)
+			startWriters()
+		}
+	} else if runSink {
+		startWriters()
+	}
+
+	gin.SetMode(gin.ReleaseMode)
+	var router *gin.Engine
+	if accessLog := configValues.Server.AccessLog; accessLog != nil && accessLog.Enabled {
+		// the access log replaces the gin request log
+		router = gin.New()
+		router.Use(gin.Recovery())
+		router.Use(tracing.Middleware())
+		router.Use(route.AccessLog(accessLog))
+	} else {
+		router = gin.Default()
+		router.Use(tracing.Middleware())
+	}
+	router.Use(route.Compression(configValues.Server.Compression))
+	router.Use(route.ErrorResponses())
+	router.Use(route.Cors(configValues.Server.Cors))
+
+	router.Use(func(c *gin.Context) {
+		metrics.ApiRequests.Inc()
 		c.Next()
 	})
-	route.AddRoutes(readDB, router, priceResolver, configValues)
+	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	router.GET("/health", healthRoutes.GetHealth)
+	// sink instances only serve metrics and the admin endpoints
+	if runApi {
+		route.AddRoutes(readDB, router, priceResolver, networkUtils, state, reloader, nodeClient)
+	}
+	if adminRoutes != nil {
+		route.AddAdminRoutes(router, adminRoutes, configValues.Admin)
+	}
+	// webhooks are written through the write store, only sink instances serve them
+	if writeDB != nil && configValues.Webhooks != nil && configValues.Webhooks.Enabled {
+		route.AddWebhookRoutes(router, route.NewWebhookRoutes(readDB, writeDB), reloader)
+	}
+	// watchlists are written through the write store and summarized from the network state
+	if writeDB != nil && state != nil && configValues.Watchlists != nil && configValues.Watchlists.Enabled {
+		watchlistRoutes := route.NewWatchlistRoutes(readDB, writeDB, priceResolver, networkUtils, state, reloader)
+		route.AddWatchlistRoutes(router, watchlistRoutes, reloader)
+		if configValues.Emails != nil && configValues.Emails.Enabled {
+			route.AddEmailRoutes(router, route.NewEmailRoutes(readDB, writeDB, reloader), reloader)
+		}
+	}
+
+	// payouts are read from the rewards the pool processor of the sink saved
+	if runApi && configValues.Pool != nil && configValues.Pool.Enabled {
+		route.AddPoolRoutes(router, route.NewPoolRoutes(readDB, reloader, networkUtils), reloader)
+	}
 
-	server := &http.Server{
-		Addr:    configValues.Server.Port,
-		Handler: router,
+	// the docs describe the routes added above
+	if docs := configValues.Server.Docs; docs != nil && docs.Enabled {
+		route.AddDocsRoutes(router, route.NewDocsRoutes(router, docs))
 	}
 
+	server := newHttpServer(configValues.Server, router)
+	shutdownTimeout := serverSeconds(configValues.Server.ShutdownTimeout, 30*time.Second)
+
 	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, os.Interrupt)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	stopped := make(chan struct{})
 
 	go func() {
-		<-quit
-		writeDB.CloseWrite()
+		received := <-quit
+		log.Printf("Received %s, shutting down", received)
+		stopBy := time.Now().Add(shutdownTimeout)
+		ctx, cancel := context.WithDeadline(context.Background(), stopBy)
+		defer cancel()
+		server.shutdown(ctx)
+		if s := runningSink.Load(); s != nil {
+			s.Stop(time.Until(stopBy))
+		}
+		if election != nil {
+			election.Release()
+		}
+		if writeDB != nil {
+			writeDB.CloseWrite()
+		}
 		readDB.CloseRead()
-		log.Println("receive interrupt signal")
-		if err := server.Close(); err != nil {
-			log.Fatal("Server Close:", err)
+		if nodeClient != nil {
+			nodeClient.Close()
 		}
+		shutdownTracing()
+		close(stopped)
 	}()
 
-	log.Println("Listen and serve")
-	if err := server.ListenAndServe(); err != nil {
-		if err == http.ErrServerClosed {
-			log.Println("Server closed under request")
-		} else {
-			log.Fatal("Server closed unexpect")
-		}
+	if err := server.listen(); err != http.ErrServerClosed {
+		log.Fatalf("Server closed unexpectedly: %v", err)
 	}
-
+	// the stores close after the requests in flight and the sinks finished
+	<-stopped
 	log.Println("Server exiting")
 }
@@ -0,0 +1,223 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/metrics"
+	"github.com/swarmbit/spacemesh-state-api/pkg/apperror"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+// checkpointer keeps the highest acked stream sequence of every consumer and saves
+// them periodically instead of on every message. Messages are processed in parallel,
+// some below the checkpoint may still be redelivered after a restart.
+type checkpointer struct {
+	writeDB database.WriteStore
+	mu      sync.Mutex
+	pending map[string]*types.StreamCheckpointDoc
+}
+
+func newCheckpointer(writeDB database.WriteStore, interval time.Duration) *checkpointer {
+	c := &checkpointer{
+		writeDB: writeDB,
+		pending: make(map[string]*types.StreamCheckpointDoc),
+	}
+	ticker := time.NewTicker(interval)
+	go func() {
+		for range ticker.C {
+			c.flush()
+		}
+	}()
+	return c
+}
+
+func (c *checkpointer) record(msg *nats.Msg, layer uint32) {
+	meta, err := msg.Metadata()
+	if err != nil {
+		return
+	}
+	c.add(&types.StreamCheckpointDoc{
+		Consumer:  meta.Consumer,
+		Stream:    meta.Stream,
+		Sequence:  meta.Sequence.Stream,
+		Layer:     layer,
+		UpdatedAt: time.Now(),
+	})
+}
+
+func (c *checkpointer) add(checkpoint *types.StreamCheckpointDoc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	current, exists := c.pending[checkpoint.Consumer]
+	if exists && current.Sequence >= checkpoint.Sequence {
+		return
+	}
+	if exists && current.Layer > checkpoint.Layer {
+		checkpoint.Layer = current.Layer
+	}
+	c.pending[checkpoint.Consumer] = checkpoint
+}
+
+func (c *checkpointer) flush() {
+	c.mu.Lock()
+	pending := c.pending
+	c.pending = make(map[string]*types.StreamCheckpointDoc)
+	c.mu.Unlock()
+
+	for _, checkpoint := range pending {
+		if err := c.writeDB.SaveStreamCheckpoint(checkpoint); err != nil {
+			log.Printf("Failed to save checkpoint of %s: %v", checkpoint.Consumer, err)
+			// retried with the next flush unless a newer one arrived
+			c.add(checkpoint)
+		}
+	}
+}
+
+// verifyCheckpoints compares the stored checkpoints with the consumers jetstream
+// reports. A consumer created after its last checkpoint was deleted while the sink was
+// down and starts at the last message, the messages since the checkpoint are logged as
+// missing and replayed from the stream when enabled.
+func (s *Sink) verifyCheckpoints(js nats.JetStreamContext, readDB database.ReadStore, configValues *config.NatsConfig) {
+	checkpoints, err := readDB.GetStreamCheckpoints()
+	if err != nil {
+		log.Printf("Failed to load stream checkpoints: %v", err)
+		return
+	}
+	byConsumer := make(map[string]*types.StreamCheckpointDoc, len(checkpoints))
+	for _, checkpoint := range checkpoints {
+		byConsumer[checkpoint.Consumer] = checkpoint
+	}
+	maxReplay := 10000
+	if configValues.MaxReplay > 0 {
+		maxReplay = configValues.MaxReplay
+	}
+
+	for _, consumer := range sinkConsumers {
+		checkpoint, exists := byConsumer[consumer.durable]
+		if !exists {
+			log.Printf("No checkpoint for %s yet", consumer.durable)
+			continue
+		}
+		info, err := js.ConsumerInfo(consumer.stream, consumer.durable)
+		if err != nil {
+			log.Printf("Failed to get consumer %s: %v", consumer.durable, err)
+			continue
+		}
+		if info.AckFloor.Stream < checkpoint.Sequence && !info.Created.After(checkpoint.UpdatedAt) {
+			log.Printf("Consumer %s acked up to %d, behind its checkpoint %d, saved messages will be written again",
+				consumer.durable, info.AckFloor.Stream, checkpoint.Sequence)
+			continue
+		}
+		if !info.Created.After(checkpoint.UpdatedAt) || info.Delivered.Stream <= checkpoint.Sequence {
+			log.Printf("Consumer %s matches its checkpoint %d", consumer.durable, checkpoint.Sequence)
+			continue
+		}
+
+		first := checkpoint.Sequence + 1
+		last := info.Delivered.Stream
+		streamInfo, err := js.StreamInfo(consumer.stream)
+		if err == nil && streamInfo.State.FirstSeq > first {
+			log.Printf("Stream %s sequences %d to %d of %s were removed from the stream and can not be replayed",
+				consumer.stream, first, streamInfo.State.FirstSeq-1, consumer.durable)
+			first = streamInfo.State.FirstSeq
+		}
+		log.Printf("Consumer %s was recreated after checkpoint %d, stream %s sequences %d to %d may be missing",
+			consumer.durable, checkpoint.Sequence, consumer.stream, first, last)
+		if configValues.ReplayGaps && first <= last {
+			s.replay(js, consumer, first, last, maxReplay)
+		}
+	}
+}
+
+func (s *Sink) replay(js nats.JetStreamContext, consumer *sinkConsumer, first uint64, last uint64, maxReplay int) {
+	if last-first+1 > uint64(maxReplay) {
+		log.Printf("Replaying only %d of %d messages of %s", maxReplay, last-first+1, consumer.durable)
+		last = first + uint64(maxReplay) - 1
+	}
+	replayed := 0
+	for sequence := first; sequence <= last; sequence++ {
+		msg, err := js.GetMsg(consumer.stream, sequence)
+		if errors.Is(err, nats.ErrMsgNotFound) {
+			continue
+		}
+		if err != nil {
+			log.Printf("Failed to get %s message %d, stop replay: %v", consumer.stream, sequence, err)
+			break
+		}
+		// streams with several subjects are shared between consumers
+		if msg.Subject != consumer.subject {
+			continue
+		}
+		layer, err := consumer.resave(context.TODO(), s.WriteDB, msg.Data)
+		if apperror.KindOf(err) == apperror.InvalidInput {
+			log.Printf("Skipping invalid %s message %d: %v", consumer.stream, sequence, err)
+			continue
+		}
+		if err != nil {
+			log.Printf("Failed to replay %s message %d, stop replay: %v", consumer.stream, sequence, err)
+			break
+		}
+		replayed++
+		metrics.ReplayedMessages.WithLabelValues(consumer.stream).Inc()
+		s.checkpoints.add(&types.StreamCheckpointDoc{
+			Consumer:  consumer.durable,
+			Stream:    consumer.stream,
+			Sequence:  sequence,
+			Layer:     layer,
+			UpdatedAt: time.Now(),
+		})
+	}
+	log.Printf("Replayed %d messages of %s", replayed, consumer.durable)
+}
+
+// AlignConsumers recreates the durable consumers of the sink to start at the first
+// message published from start, and saves their stream checkpoints at layer. An imported
+// checkpoint restores the state before its restore layer, the consumers kept the position
+// of the state they were used for and would skip or apply again the events after it.
+func AlignConsumers(natsConfig *config.NatsConfig, writeDB database.WriteStore, start time.Time, layer uint32) error {
+	conn, err := connect(natsConfig)
+	if err != nil {
+		return err
+	}
+	defer conn.nc.Close()
+	js, err := conn.nc.JetStream()
+	if err != nil {
+		return err
+	}
+
+	size := queueSize(natsConfig)
+	for _, consumer := range sinkConsumers {
+		err := js.DeleteConsumer(consumer.stream, consumer.durable)
+		if err != nil && !errors.Is(err, nats.ErrConsumerNotFound) {
+			return fmt.Errorf("failed to delete consumer %s: %w", consumer.durable, err)
+		}
+		consumerConfig := consumer.config(size)
+		consumerConfig.DeliverPolicy = nats.DeliverByStartTimePolicy
+		consumerConfig.OptStartTime = &start
+		info, err := js.AddConsumer(consumer.stream, consumerConfig)
+		if err != nil {
+			return fmt.Errorf("failed to create consumer %s: %w", consumer.durable, err)
+		}
+		// the consumer delivered nothing yet, its position is the message before start
+		err = writeDB.SaveStreamCheckpoint(&types.StreamCheckpointDoc{
+			Consumer:  consumer.durable,
+			Stream:    consumer.stream,
+			Sequence:  info.Delivered.Stream,
+			Layer:     layer,
+			UpdatedAt: time.Now(),
+		})
+		if err != nil {
+			return err
+		}
+		log.Printf("Consumer %s starts after stream %s sequence %d", consumer.durable, consumer.stream, info.Delivered.Stream)
+	}
+	return nil
+}
@@ -0,0 +1,345 @@
+package sink
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	natsS "github.com/spacemeshos/go-spacemesh/nats"
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/metrics"
+	"github.com/swarmbit/spacemesh-state-api/pkg/transactionparser"
+	transactionparsertypes "github.com/swarmbit/spacemesh-state-api/pkg/transactionparser/transaction"
+)
+
+const (
+	clickHouseRewards      = "rewards"
+	clickHouseAtxs         = "atxs"
+	clickHouseTransactions = "transactions"
+)
+
+const clickHouseTimeFormat = "2006-01-02 15:04:05"
+const clickHouseMillisFormat = "2006-01-02 15:04:05.000"
+
+// the replacing engine collapses rows written twice when a batch is retried
+var clickHouseSchema = []string{
+	`CREATE TABLE IF NOT EXISTS %s.rewards (
+		id String,
+		layer UInt32,
+		layer_time DateTime('UTC'),
+		node_id String,
+		coinbase String,
+		atx_id String,
+		layer_reward UInt64,
+		total_reward UInt64
+	) ENGINE = ReplacingMergeTree ORDER BY (coinbase, layer, id)`,
+	`CREATE TABLE IF NOT EXISTS %s.atxs (
+		id String,
+		node_id String,
+		coinbase String,
+		publish_epoch UInt32,
+		effective_num_units UInt32,
+		weight UInt64,
+		base_tick UInt64,
+		tick_count UInt64,
+		sequence UInt64,
+		received DateTime64(3, 'UTC')
+	) ENGINE = ReplacingMergeTree ORDER BY (publish_epoch, node_id, id)`,
+	`CREATE TABLE IF NOT EXISTS %s.transactions (
+		id String,
+		layer UInt32,
+		layer_time DateTime('UTC'),
+		status UInt8,
+		method UInt8,
+		principal_account String,
+		receiver_account String,
+		vault_account String,
+		amount UInt64,
+		fee UInt64,
+		gas UInt64,
+		gas_price UInt64,
+		counter UInt64
+	) ENGINE = ReplacingMergeTree ORDER BY (layer, id)`,
+}
+
+type clickHouseReward struct {
+	ID          string `json:"id"`
+	Layer       uint32 `json:"layer"`
+	LayerTime   string `json:"layer_time"`
+	NodeID      string `json:"node_id"`
+	Coinbase    string `json:"coinbase"`
+	AtxID       string `json:"atx_id"`
+	LayerReward uint64 `json:"layer_reward"`
+	TotalReward uint64 `json:"total_reward"`
+}
+
+type clickHouseAtx struct {
+	ID                string `json:"id"`
+	NodeID            string `json:"node_id"`
+	Coinbase          string `json:"coinbase"`
+	PublishEpoch      uint32 `json:"publish_epoch"`
+	EffectiveNumUnits uint32 `json:"effective_num_units"`
+	Weight            uint64 `json:"weight"`
+	BaseTick          uint64 `json:"base_tick"`
+	TickCount         uint64 `json:"tick_count"`
+	Sequence          uint64 `json:"sequence"`
+	Received          string `json:"received"`
+}
+
+type clickHouseTransaction struct {
+	ID               string `json:"id"`
+	Layer            uint32 `json:"layer"`
+	LayerTime        string `json:"layer_time"`
+	Status           uint8  `json:"status"`
+	Method           uint8  `json:"method"`
+	PrincipalAccount string `json:"principal_account"`
+	ReceiverAccount  string `json:"receiver_account"`
+	VaultAccount     string `json:"vault_account"`
+	Amount           uint64 `json:"amount"`
+	Fee              uint64 `json:"fee"`
+	Gas              uint64 `json:"gas"`
+	GasPrice         uint64 `json:"gas_price"`
+	Counter          uint64 `json:"counter"`
+}
+
+type clickHouseRow struct {
+	table  string
+	data   []byte
+	queued time.Time
+}
+
+type clickHouseBatch struct {
+	rows   [][]byte
+	oldest time.Time
+	// retryAt is when a batch that failed to insert is flushed again
+	retryAt time.Time
+}
+
+// ClickHouseSink copies what the sink saved in the primary db into clickhouse. Rows are
+// queued without blocking and inserted in batches by a single goroutine, when clickhouse
+// is slow or down the primary path is not affected and rows over the queue size are
+// dropped and counted. A failed batch is retried once per flush interval, not on every
+// row added to it.
+type ClickHouseSink struct {
+	client        *http.Client
+	uri           string
+	database      string
+	user          string
+	password      string
+	batchSize     int
+	queueSize     int
+	flushInterval time.Duration
+	rows          chan *clickHouseRow
+	batches       map[string]*clickHouseBatch
+}
+
+func NewClickHouseSink(configValues *config.ClickHouseConfig) (*ClickHouseSink, error) {
+	database := configValues.Database
+	if database == "" {
+		database = "spacemesh"
+	}
+	batchSize := 1000
+	if configValues.BatchSize > 0 {
+		batchSize = configValues.BatchSize
+	}
+	flushInterval := 5
+	if configValues.FlushInterval > 0 {
+		flushInterval = configValues.FlushInterval
+	}
+	queueSize := 100000
+	if configValues.QueueSize > 0 {
+		queueSize = configValues.QueueSize
+	}
+
+	c := &ClickHouseSink{
+		client:        &http.Client{Timeout: 30 * time.Second},
+		uri:           configValues.Uri,
+		database:      database,
+		user:          configValues.User,
+		password:      configValues.Password,
+		batchSize:     batchSize,
+		queueSize:     queueSize,
+		flushInterval: time.Duration(flushInterval) * time.Second,
+		rows:          make(chan *clickHouseRow, queueSize),
+		batches:       make(map[string]*clickHouseBatch),
+	}
+
+	for _, statement := range clickHouseSchema {
+		if err := c.exec(fmt.Sprintf(statement, database), nil); err != nil {
+			return nil, err
+		}
+	}
+	go c.run()
+	return c, nil
+}
+
+func (c *ClickHouseSink) AddReward(reward *natsS.Reward) {
+	if c == nil {
+		return
+	}
+	c.enqueue(clickHouseRewards, &clickHouseReward{
+		ID:          reward.ID,
+		Layer:       reward.Layer,
+		LayerTime:   layerTime(reward.Layer),
+		NodeID:      reward.NodeID,
+		Coinbase:    reward.Coinbase,
+		AtxID:       reward.AtxID,
+		LayerReward: reward.LayerReward,
+		TotalReward: reward.Total,
+	})
+}
+
+func (c *ClickHouseSink) AddAtx(atx *natsS.Atx) {
+	if c == nil {
+		return
+	}
+	c.enqueue(clickHouseAtxs, &clickHouseAtx{
+		ID:                atx.AtxID,
+		NodeID:            atx.NodeID,
+		Coinbase:          atx.Coinbase,
+		PublishEpoch:      atx.PublishEpoch,
+		EffectiveNumUnits: atx.EffectiveNumUnits,
+		Weight:            database.ATXWeight(atx.TickCount, uint64(atx.EffectiveNumUnits)),
+		BaseTick:          atx.BaseTick,
+		TickCount:         atx.TickCount,
+		Sequence:          atx.Sequence,
+		Received:          time.UnixMilli(atx.Received).UTC().Format(clickHouseMillisFormat),
+	})
+}
+
+// AddTransaction only takes transaction results, created transactions have no amount
+// or receiver yet.
+func (c *ClickHouseSink) AddTransaction(transaction *natsS.Transaction) {
+	if c == nil {
+		return
+	}
+	transactionData, err := transactionparser.Parse(transaction.Raw)
+	if err != nil {
+		log.Printf("Failed to parse transaction %s for clickhouse: %v", transaction.ID, err)
+		return
+	}
+	receiver := ""
+	if len(transactionData.Tx.GetReceiver().Bytes()) > 0 {
+		receiver = transactionData.Tx.GetReceiver().String()
+	}
+	vault := ""
+	if transactionData.Type == transactionparsertypes.TypeDrainVault {
+		vault = transactionData.Vault.GetVault().String()
+	}
+	gasPrice := transactionData.Tx.GetGasPrice()
+	c.enqueue(clickHouseTransactions, &clickHouseTransaction{
+		ID:               transaction.ID,
+		Layer:            transaction.Header.LayerID,
+		LayerTime:        layerTime(transaction.Header.LayerID),
+		Status:           transaction.Header.Status,
+		Method:           transaction.Header.Method,
+		PrincipalAccount: transaction.Header.Principal,
+		ReceiverAccount:  receiver,
+		VaultAccount:     vault,
+		Amount:           transactionData.Tx.GetAmount(),
+		Fee:              transaction.Header.Gas * gasPrice,
+		Gas:              transaction.Header.Gas,
+		GasPrice:         gasPrice,
+		Counter:          transactionData.Tx.GetCounter(),
+	})
+}
+
+func layerTime(layer uint32) string {
+	return time.Unix(config.GenesisEpochSeconds+int64(layer)*config.LayerDuration, 0).UTC().Format(clickHouseTimeFormat)
+}
+
+func (c *ClickHouseSink) enqueue(table string, row interface{}) {
+	data, err := json.Marshal(row)
+	if err != nil {
+		log.Printf("Failed to serialize %s row for clickhouse: %v", table, err)
+		return
+	}
+	select {
+	case c.rows <- &clickHouseRow{table: table, data: data, queued: time.Now()}:
+		metrics.ClickHousePending.WithLabelValues(table).Inc()
+	default:
+		metrics.ClickHouseDropped.WithLabelValues(table).Inc()
+	}
+}
+
+func (c *ClickHouseSink) run() {
+	ticker := time.NewTicker(c.flushInterval)
+	for {
+		select {
+		case row := <-c.rows:
+			batch, exists := c.batches[row.table]
+			if !exists {
+				batch = &clickHouseBatch{}
+				c.batches[row.table] = batch
+			}
+			// the batch keeps failing and already holds as many rows as the queue
+			if len(batch.rows) >= c.queueSize {
+				metrics.ClickHouseDropped.WithLabelValues(row.table).Inc()
+				metrics.ClickHousePending.WithLabelValues(row.table).Dec()
+				continue
+			}
+			if len(batch.rows) == 0 {
+				batch.oldest = row.queued
+			}
+			batch.rows = append(batch.rows, row.data)
+			if len(batch.rows) >= c.batchSize && time.Now().After(batch.retryAt) {
+				c.flush(row.table, batch)
+			}
+		case <-ticker.C:
+			for table, batch := range c.batches {
+				if time.Now().After(batch.retryAt) {
+					c.flush(table, batch)
+				}
+			}
+		}
+	}
+}
+
+// flush inserts the batch, on failure the rows are kept and inserted again after the
+// flush interval.
+func (c *ClickHouseSink) flush(table string, batch *clickHouseBatch) {
+	if len(batch.rows) == 0 {
+		return
+	}
+	body := bytes.Join(batch.rows, []byte("\n"))
+	query := fmt.Sprintf("INSERT INTO %s.%s FORMAT JSONEachRow", c.database, table)
+	if err := c.exec(query, body); err != nil {
+		log.Printf("Failed to insert %d rows in clickhouse %s: %v", len(batch.rows), table, err)
+		metrics.ClickHouseFailures.WithLabelValues(table).Inc()
+		batch.retryAt = time.Now().Add(c.flushInterval)
+		return
+	}
+	metrics.ClickHouseInserted.WithLabelValues(table).Add(float64(len(batch.rows)))
+	metrics.ClickHousePending.WithLabelValues(table).Sub(float64(len(batch.rows)))
+	metrics.ClickHouseLag.WithLabelValues(table).Set(time.Since(batch.oldest).Seconds())
+	batch.rows = nil
+}
+
+// exec runs query over the clickhouse http interface, body holds the rows of inserts.
+func (c *ClickHouseSink) exec(query string, body []byte) error {
+	endpoint := c.uri + "/?query=" + url.QueryEscape(query)
+	request, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if c.user != "" {
+		request.Header.Set("X-ClickHouse-User", c.user)
+		request.Header.Set("X-ClickHouse-Key", c.password)
+	}
+	response, err := c.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		return fmt.Errorf("clickhouse returned %d: %s", response.StatusCode, bytes.TrimSpace(message))
+	}
+	return nil
+}
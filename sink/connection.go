@@ -0,0 +1,189 @@
+package sink
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/metrics"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+// connection is the nats connection of the sink. Fetches wait on its context, it is
+// cancelled when the connection drops or comes back, so the pull requests the server
+// lost are not waited on until their max wait.
+type connection struct {
+	nc     *nats.Conn
+	mu     sync.Mutex
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func connect(natsConfig *config.NatsConfig) (*connection, error) {
+	conn := &connection{}
+	conn.ctx, conn.cancel = context.WithCancel(context.Background())
+
+	options, err := natsOptions(natsConfig)
+	if err != nil {
+		return nil, err
+	}
+	options = append(options,
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			log.Printf("Disconnected from nats: %v", err)
+			metrics.NatsConnected.Set(0)
+			conn.reset()
+		}),
+		nats.ReconnectHandler(func(nc *nats.Conn) {
+			log.Printf("Reconnected to nats at %s", nc.ConnectedUrlRedacted())
+			metrics.NatsConnected.Set(1)
+			conn.reset()
+		}),
+		nats.ClosedHandler(func(_ *nats.Conn) {
+			log.Println("Nats connection closed")
+			metrics.NatsConnected.Set(0)
+			conn.reset()
+		}),
+	)
+	conn.nc, err = nats.Connect(natsConfig.Uri, options...)
+	if err != nil {
+		return nil, err
+	}
+	metrics.NatsConnected.Set(1)
+	return conn, nil
+}
+
+// natsOptions are the reconnect, authentication and tls options of natsConfig.
+func natsOptions(natsConfig *config.NatsConfig) ([]nats.Option, error) {
+	reconnectWait := 2 * time.Second
+	if natsConfig.ReconnectWait > 0 {
+		reconnectWait = time.Duration(natsConfig.ReconnectWait) * time.Second
+	}
+	options := []nats.Option{
+		nats.Name("spacemesh-state-api"),
+		nats.MaxReconnects(-1),
+		nats.ReconnectWait(reconnectWait),
+	}
+
+	switch {
+	case natsConfig.CredentialsFile != "":
+		options = append(options, nats.UserCredentials(natsConfig.CredentialsFile))
+	case natsConfig.NkeyFile != "":
+		option, err := nats.NkeyOptionFromSeed(natsConfig.NkeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read nats nkey: %w", err)
+		}
+		options = append(options, option)
+	case natsConfig.Token != "":
+		options = append(options, nats.Token(natsConfig.Token))
+	case natsConfig.User != "":
+		options = append(options, nats.UserInfo(natsConfig.User, natsConfig.Password))
+	}
+
+	if natsConfig.Tls != nil && natsConfig.Tls.Enabled {
+		tlsConfig, err := natsTlsConfig(natsConfig.Tls)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, nats.Secure(tlsConfig))
+	}
+	return options, nil
+}
+
+func natsTlsConfig(tlsConfig *config.NatsTlsConfig) (*tls.Config, error) {
+	secure := &tls.Config{MinVersion: tls.VersionTLS12}
+	if tlsConfig.CaFile != "" {
+		ca, err := os.ReadFile(tlsConfig.CaFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read nats ca: %w", err)
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificate in nats ca %s", tlsConfig.CaFile)
+		}
+		secure.RootCAs = roots
+	}
+	if tlsConfig.CertFile != "" || tlsConfig.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(tlsConfig.CertFile, tlsConfig.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read nats client certificate: %w", err)
+		}
+		secure.Certificates = []tls.Certificate{cert}
+	}
+	return secure, nil
+}
+
+// reset cancels the fetches waiting on the current context.
+func (c *connection) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cancel()
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+}
+
+func (c *connection) context() context.Context {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ctx
+}
+
+// waitConnected blocks while the connection is reconnecting.
+func (c *connection) waitConnected() {
+	for !c.nc.IsConnected() && !c.nc.IsClosed() {
+		time.Sleep(time.Second)
+	}
+}
+
+// fetch pulls up to batch messages of sub waiting up to maxWait. It returns no messages
+// on timeouts and, once the connection is back, when the connection dropped.
+func (s *Sink) fetch(sub *nats.Subscription, maxWait time.Duration, batch int) []*nats.Msg {
+	ctx, cancel := context.WithTimeout(s.conn.context(), maxWait)
+	defer cancel()
+	msgs, err := sub.Fetch(batch, nats.Context(ctx))
+	if err == nil || errors.Is(err, nats.ErrTimeout) || errors.Is(err, context.DeadlineExceeded) {
+		return msgs
+	}
+	if !errors.Is(err, context.Canceled) {
+		log.Printf("Failed to fetch %s: %s", sub.Subject, err.Error())
+		// do not spin on errors the connection does not explain
+		time.Sleep(time.Second)
+	}
+	s.conn.waitConnected()
+	return msgs
+}
+
+// ConnectionStatus reports the state of the nats connection.
+func (s *Sink) ConnectionStatus() *types.NatsStatus {
+	status := &types.NatsStatus{
+		Connected:  s.conn.nc.IsConnected(),
+		Status:     natsStatusName(s.conn.nc.Status()),
+		Url:        s.conn.nc.ConnectedUrlRedacted(),
+		Reconnects: s.conn.nc.Stats().Reconnects,
+	}
+	if err := s.conn.nc.LastError(); err != nil {
+		status.LastError = err.Error()
+	}
+	return status
+}
+
+func natsStatusName(status nats.Status) string {
+	switch status {
+	case nats.CONNECTED:
+		return "connected"
+	case nats.RECONNECTING:
+		return "reconnecting"
+	case nats.CONNECTING:
+		return "connecting"
+	case nats.CLOSED:
+		return "closed"
+	default:
+		return "disconnected"
+	}
+}
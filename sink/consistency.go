@@ -0,0 +1,244 @@
+package sink
+
+import (
+	"log"
+	"sort"
+	"time"
+
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/metrics"
+)
+
+const layerStatusApplied = 3
+
+// refetchSlack widens the refetched time ranges, events of a layer are published
+// some layers after the layer started.
+func refetchSlack() time.Duration {
+	return 10 * time.Duration(config.LayerDuration) * time.Second
+}
+
+// ConsistencyChecker periodically looks for data the sink should have saved but did
+// not, and reads the affected time ranges again from the streams instead of requiring
+// a full resync. Refetched layers and epochs are remembered so genuinely empty ones are
+// only refetched once, until they leave the checked epochs.
+type ConsistencyChecker struct {
+	sink        *Sink
+	readDB      database.ReadStore
+	epochs      uint32
+	refetch     bool
+	refetched   map[string]map[uint32]bool
+	ticker      *time.Ticker
+	refreshTime int
+}
+
+// consistencyRefreshTime is the minutes between checks, 30 when not configured.
+func consistencyRefreshTime(configValues *config.Config) int {
+	if configValues.Consistency.RefreshTime > 0 {
+		return configValues.Consistency.RefreshTime
+	}
+	return 30
+}
+
+func NewConsistencyChecker(configValues *config.Config, s *Sink, readDB database.ReadStore) *ConsistencyChecker {
+	refreshTime := consistencyRefreshTime(configValues)
+	epochs := 2
+	if configValues.Consistency.Epochs > 0 {
+		epochs = configValues.Consistency.Epochs
+	}
+	checker := &ConsistencyChecker{
+		sink:      s,
+		readDB:    readDB,
+		epochs:    uint32(epochs),
+		refetch:   configValues.Consistency.Refetch,
+		refetched: make(map[string]map[uint32]bool),
+	}
+	go checker.check()
+	checker.periodicCheck(refreshTime)
+	return checker
+}
+
+func (c *ConsistencyChecker) periodicCheck(refreshTime int) {
+	c.refreshTime = refreshTime
+	c.ticker = time.NewTicker(time.Duration(refreshTime) * time.Minute)
+	go func() {
+		for range c.ticker.C {
+			c.check()
+		}
+	}()
+}
+
+// Reload applies a changed refresh time, the next check waits the new time.
+func (c *ConsistencyChecker) Reload(configValues *config.Config) {
+	refreshTime := consistencyRefreshTime(configValues)
+	if refreshTime != c.refreshTime {
+		c.refreshTime = refreshTime
+		c.ticker.Reset(time.Duration(refreshTime) * time.Minute)
+	}
+}
+
+func (c *ConsistencyChecker) check() {
+	last, err := c.readDB.GetLastProcessedLayer()
+	if err != nil {
+		log.Printf("Failed to get last processed layer: %s", err.Error())
+		return
+	}
+	if last.Layer <= 0 {
+		return
+	}
+	// the last layer may still be receiving its rewards
+	to := uint32(last.Layer) - 1
+	epoch := to / config.LayersPerEpoch
+	from := uint32(0)
+	if epoch > c.epochs {
+		from = (epoch - c.epochs) * config.LayersPerEpoch
+	}
+
+	c.forgetBefore(from)
+	c.checkLayers(from, to)
+	c.checkAtxs(from/config.LayersPerEpoch, epoch)
+}
+
+// forgetBefore drops the refetched layers before from and the epochs before its epoch,
+// they are not checked anymore.
+func (c *ConsistencyChecker) forgetBefore(from uint32) {
+	for durable, done := range c.refetched {
+		before := from
+		// the atx consumer remembers publish epochs
+		if durable == "state-api-process-atx" {
+			before = from / config.LayersPerEpoch
+		}
+		for value := range done {
+			if value < before {
+				delete(done, value)
+			}
+		}
+	}
+}
+
+func (c *ConsistencyChecker) checkLayers(from uint32, to uint32) {
+	layers, err := c.readDB.GetLayersBetween(from, to)
+	if err != nil {
+		log.Printf("Failed to get layers %d to %d: %s", from, to, err.Error())
+		return
+	}
+	rewarded, err := c.readDB.GetRewardedLayers(from, to)
+	if err != nil {
+		log.Printf("Failed to get rewarded layers %d to %d: %s", from, to, err.Error())
+		return
+	}
+
+	stored := make(map[uint32]bool, len(layers))
+	applied := make([]uint32, 0, len(layers))
+	for _, layer := range layers {
+		stored[uint32(layer.Layer)] = true
+		if layer.Status == layerStatusApplied {
+			applied = append(applied, uint32(layer.Layer))
+		}
+	}
+	withRewards := make(map[uint32]bool, len(rewarded))
+	for _, layer := range rewarded {
+		withRewards[layer] = true
+	}
+
+	missing := make([]uint32, 0)
+	for layer := from; layer <= to; layer++ {
+		if !stored[layer] {
+			missing = append(missing, layer)
+		}
+	}
+	withoutRewards := make([]uint32, 0)
+	for _, layer := range applied {
+		if !withRewards[layer] {
+			withoutRewards = append(withoutRewards, layer)
+		}
+	}
+
+	metrics.ConsistencyGaps.WithLabelValues("missing_layers").Set(float64(len(missing)))
+	metrics.ConsistencyGaps.WithLabelValues("layers_without_rewards").Set(float64(len(withoutRewards)))
+	if len(missing) > 0 {
+		log.Printf("Found %d missing layers between %d and %d", len(missing), from, to)
+	}
+	if len(withoutRewards) > 0 {
+		log.Printf("Found %d applied layers without rewards between %d and %d", len(withoutRewards), from, to)
+	}
+	if !c.refetch {
+		return
+	}
+	c.refetchLayers("state-api-process-layers", missing)
+	c.refetchLayers("state-api-process-rewards", withoutRewards)
+}
+
+// checkAtxs looks for publish epochs without atxs, the current epoch is still open.
+func (c *ConsistencyChecker) checkAtxs(from uint32, current uint32) {
+	empty := make([]uint32, 0)
+	for epoch := from; epoch < current; epoch++ {
+		totals, err := c.readDB.GetAtxEpoch(uint64(epoch))
+		if err != nil {
+			log.Printf("Failed to count atxs of epoch %d: %s", epoch, err.Error())
+			return
+		}
+		if totals.TotalAtx == 0 {
+			empty = append(empty, epoch)
+		}
+	}
+	metrics.ConsistencyGaps.WithLabelValues("epochs_without_atxs").Set(float64(len(empty)))
+	if len(empty) > 0 {
+		log.Printf("Found %d epochs without atxs between %d and %d", len(empty), from, current-1)
+	}
+	if !c.refetch {
+		return
+	}
+	consumer := sinkConsumerFor("state-api-process-atx")
+	for _, epoch := range c.notRefetched(consumer.durable, empty) {
+		start := layerStart(epoch * config.LayersPerEpoch)
+		end := layerStart((epoch + 1) * config.LayersPerEpoch).Add(refetchSlack())
+		c.sink.refetchRange(consumer, start, end)
+	}
+}
+
+// refetchLayers merges consecutive layers so every range is read once.
+func (c *ConsistencyChecker) refetchLayers(durable string, layers []uint32) {
+	consumer := sinkConsumerFor(durable)
+	layers = c.notRefetched(durable, layers)
+	sort.Slice(layers, func(i, j int) bool { return layers[i] < layers[j] })
+	for i := 0; i < len(layers); {
+		j := i
+		for j+1 < len(layers) && layers[j+1] == layers[j]+1 {
+			j++
+		}
+		start := layerStart(layers[i]).Add(-time.Duration(config.LayerDuration) * time.Second)
+		end := layerStart(layers[j]).Add(refetchSlack())
+		c.sink.refetchRange(consumer, start, end)
+		i = j + 1
+	}
+}
+
+func (c *ConsistencyChecker) notRefetched(durable string, values []uint32) []uint32 {
+	done, exists := c.refetched[durable]
+	if !exists {
+		done = make(map[uint32]bool)
+		c.refetched[durable] = done
+	}
+	pending := make([]uint32, 0, len(values))
+	for _, value := range values {
+		if !done[value] {
+			done[value] = true
+			pending = append(pending, value)
+		}
+	}
+	return pending
+}
+
+func sinkConsumerFor(durable string) *sinkConsumer {
+	for _, consumer := range sinkConsumers {
+		if consumer.durable == durable {
+			return consumer
+		}
+	}
+	return nil
+}
+
+func layerStart(layer uint32) time.Time {
+	return time.Unix(config.GenesisEpochSeconds+int64(layer)*config.LayerDuration, 0)
+}
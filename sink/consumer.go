@@ -0,0 +1,285 @@
+package sink
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	natsS "github.com/spacemeshos/go-spacemesh/nats"
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/events"
+	"github.com/swarmbit/spacemesh-state-api/metrics"
+	"github.com/swarmbit/spacemesh-state-api/pkg/apperror"
+)
+
+// consumer is a durable consumer of one event type. The fetch, ack, retry and metrics
+// logic is shared, an event type only declares how its messages are read and saved.
+// Saved events are published on the event bus with their entity as kind.
+type consumer[T any] struct {
+	// name pauses and resumes the consumer, entity labels its metrics and traces
+	name    string
+	entity  string
+	stream  string
+	durable string
+	subject string
+	group   string
+	maxWait time.Duration
+	// parallel saves the messages of a batch concurrently, the others keep their order
+	parallel bool
+	// decode reads the event of a message, json when nil
+	decode    func(data []byte) (*T, error)
+	normalize func(event *T)
+	// save writes event with the context of its message span
+	save func(ctx context.Context, writeDB database.WriteStore, event *T) error
+	// resave saves an event read again by a replay, resync or refetch, save when nil
+	resave func(ctx context.Context, writeDB database.WriteStore, event *T) error
+	// layer is the layer the event belongs to, recorded with the checkpoint and published
+	// with the event
+	layer func(event *T) uint32
+}
+
+// sinkConsumer is a consumer without its event type. save decodes and writes one
+// message of the subject and returns the layer it belongs to, resave does the same for
+// a message read again. run fetches and saves messages from sub until the sink is
+// fenced off.
+type sinkConsumer struct {
+	name    string
+	stream  string
+	durable string
+	subject string
+	group   string
+	save    func(ctx context.Context, writeDB database.WriteStore, data []byte) (uint32, error)
+	resave  func(ctx context.Context, writeDB database.WriteStore, data []byte) (uint32, error)
+	run     func(s *Sink, sub *nats.Subscription)
+}
+
+func newConsumer[T any](c *consumer[T]) *sinkConsumer {
+	return &sinkConsumer{
+		name:    c.name,
+		stream:  c.stream,
+		durable: c.durable,
+		subject: c.subject,
+		group:   c.group,
+		save:    c.saveMessage,
+		resave:  c.resaveMessage,
+		run:     c.run,
+	}
+}
+
+// config is the jetstream config of the durable consumer, size is the queue size of the
+// sink. A new consumer starts at the last message of its stream.
+func (c *sinkConsumer) config(size int) *nats.ConsumerConfig {
+	return &nats.ConsumerConfig{
+		Durable:        c.durable,
+		DeliverSubject: c.subject,
+		DeliverGroup:   c.group,
+		AckPolicy:      nats.AckExplicitPolicy,
+		AckWait:        ackWait,
+		MaxAckPending:  size + fetchBatch,
+		DeliverPolicy:  nats.DeliverLastPolicy,
+	}
+}
+
+// sinkConsumers are the consumers of the sink, a new event type only needs its entry.
+var sinkConsumers = []*sinkConsumer{
+	newConsumer(&consumer[natsS.LayerUpdate]{
+		name: SinkLayers, entity: events.Layer,
+		stream: "layers", durable: "state-api-process-layers", subject: "layers", group: "state-api-process-layers",
+		maxWait: 2 * time.Hour,
+		save: func(ctx context.Context, writeDB database.WriteStore, layer *natsS.LayerUpdate) error {
+			return writeDB.SaveLayer(ctx, layer)
+		},
+		// a layer applied again by the sink itself is not a rollback of the node
+		resave: func(ctx context.Context, writeDB database.WriteStore, layer *natsS.LayerUpdate) error {
+			return writeDB.SaveReplayedLayer(ctx, layer)
+		},
+		layer: func(layer *natsS.LayerUpdate) uint32 { return layer.LayerID },
+	}),
+	newConsumer(&consumer[natsS.Reward]{
+		name: SinkRewards, entity: events.Reward,
+		stream: "rewards", durable: "state-api-process-rewards", subject: "rewards", group: "state-api-process-rewards",
+		maxWait: 2 * time.Hour, parallel: true,
+		normalize: normalizeReward,
+		save: func(ctx context.Context, writeDB database.WriteStore, reward *natsS.Reward) error {
+			return writeDB.SaveReward(ctx, reward)
+		},
+		layer: func(reward *natsS.Reward) uint32 { return reward.Layer },
+	}),
+	newConsumer(&consumer[natsS.Atx]{
+		name: SinkAtx, entity: events.Atx,
+		stream: "atx", durable: "state-api-process-atx", subject: "atx", group: "state-api-process-atx",
+		maxWait: 360 * time.Hour, parallel: true,
+		normalize: normalizeAtx,
+		save: func(ctx context.Context, writeDB database.WriteStore, atx *natsS.Atx) error {
+			return writeDB.SaveAtx(ctx, atx)
+		},
+		layer: func(atx *natsS.Atx) uint32 { return atx.PublishEpoch * config.LayersPerEpoch },
+	}),
+	newConsumer(&consumer[natsS.Transaction]{
+		name: SinkTransactionsResult, entity: events.TransactionResult,
+		stream: "transactions", durable: "state-api-process-transactions-result", subject: "transactions.result", group: "state-api-process-transactions",
+		maxWait:   2 * time.Hour,
+		normalize: normalizeTransaction,
+		save: func(ctx context.Context, writeDB database.WriteStore, transaction *natsS.Transaction) error {
+			return writeDB.SaveTransactions(ctx, transaction, true)
+		},
+		layer: transactionLayer,
+	}),
+	newConsumer(&consumer[natsS.Transaction]{
+		name: SinkTransactionsCreated, entity: events.TransactionCreated,
+		stream: "transactions", durable: "state-api-process-transactions-created", subject: "transactions.created", group: "state-api-process-transactions",
+		maxWait:   2 * time.Hour,
+		normalize: normalizeTransaction,
+		save: func(ctx context.Context, writeDB database.WriteStore, transaction *natsS.Transaction) error {
+			return writeDB.SaveTransactions(ctx, transaction, false)
+		},
+		layer: transactionLayer,
+	}),
+	newConsumer(&consumer[natsS.Malfeasance]{
+		name: SinkMalfeasance, entity: events.Malfeasance,
+		stream: "malfeasance", durable: "state-api-process-malfeasance", subject: "malfeasance", group: "state-api-process-malfeasance",
+		maxWait:   8736 * time.Hour,
+		normalize: normalizeMalfeasance,
+		save: func(ctx context.Context, writeDB database.WriteStore, malfeasance *natsS.Malfeasance) error {
+			return writeDB.SaveMalfeasance(ctx, malfeasance)
+		},
+		// malfeasance proofs are not tied to a layer of the stream
+		layer: func(*natsS.Malfeasance) uint32 { return 0 },
+	}),
+}
+
+func transactionLayer(transaction *natsS.Transaction) uint32 {
+	if transaction.Header == nil {
+		return 0
+	}
+	return transaction.Header.LayerID
+}
+
+// decodeEvent reads and normalizes the event of a message.
+func (c *consumer[T]) decodeEvent(data []byte) (*T, error) {
+	var event *T
+	var err error
+	if c.decode != nil {
+		event, err = c.decode(data)
+		if err != nil && apperror.KindOf(err) == apperror.Internal {
+			err = apperror.Wrap(apperror.InvalidInput, "invalid "+c.entity+" message", err)
+		}
+	} else {
+		err = decodeMessage(c.entity, data, &event)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if event == nil {
+		return nil, apperror.New(apperror.InvalidInput, "empty "+c.entity+" message")
+	}
+	if c.normalize != nil {
+		c.normalize(event)
+	}
+	return event, nil
+}
+
+func (c *consumer[T]) saveMessage(ctx context.Context, writeDB database.WriteStore, data []byte) (uint32, error) {
+	event, err := c.decodeEvent(data)
+	if err != nil {
+		return 0, err
+	}
+	return c.layer(event), c.save(ctx, writeDB, event)
+}
+
+func (c *consumer[T]) resaveMessage(ctx context.Context, writeDB database.WriteStore, data []byte) (uint32, error) {
+	if c.resave == nil {
+		return c.saveMessage(ctx, writeDB, data)
+	}
+	event, err := c.decodeEvent(data)
+	if err != nil {
+		return 0, err
+	}
+	return c.layer(event), c.resave(ctx, writeDB, event)
+}
+
+// run fetches messages from sub while the queue has room and decodes them into the
+// queue, the writers save and ack them. Parallel consumers have a pool of writers when
+// the store saves in parallel, the others a single one so their events are saved in the
+// order of the stream. Once the
+// sink stops or is fenced off the writers save what is queued before run returns.
+func (c *consumer[T]) run(s *Sink, sub *nats.Subscription) {
+	log.Printf("Start %s sink", c.name)
+	queue := newWriteQueue[T](c.entity, s.queueSize)
+	state := s.paused[c.name]
+	count := 1
+	if c.parallel && s.WriteDB.Capabilities().ParallelSaves {
+		count = s.writers
+	}
+	var wg sync.WaitGroup
+	wg.Add(count)
+	for i := 0; i < count; i++ {
+		go func() {
+			defer wg.Done()
+			queue.each(func(entry *queuedEvent[T]) {
+				c.process(s, entry.msg, entry.event)
+				state.done()
+			})
+		}()
+	}
+	go queue.keepInProgress(progressInterval)
+	defer func() {
+		queue.close()
+		wg.Wait()
+		queue.stop()
+	}()
+
+	for {
+		if s.WriteDB.Fenced() {
+			log.Printf("Stop %s sink, a newer instance took over", c.name)
+			return
+		}
+		s.waitWhilePaused(c.name)
+		s.breaker.wait()
+		free := queue.waitForSpace()
+		if s.stopping.Load() {
+			log.Printf("Stop %s sink, shutting down", c.name)
+			return
+		}
+		var entries []*queuedEvent[T]
+		for _, msg := range s.fetch(sub, c.maxWait, min(free, fetchBatch)) {
+			event, err := c.decodeEvent(msg.Data)
+			if err != nil {
+				s.failMessage(msg, c.entity, err)
+				continue
+			}
+			entries = append(entries, &queuedEvent[T]{msg: msg, event: event})
+		}
+		// a pause made during the fetch hands its events back to the stream for the resume
+		if !state.admit(len(entries)) {
+			for _, entry := range entries {
+				entry.msg.Nak()
+			}
+			continue
+		}
+		for _, entry := range entries {
+			queue.push(entry)
+		}
+	}
+}
+
+// process saves one event, acks its message once the save committed and records its
+// checkpoint. Events that fail are left to failMessage. Events are not saved in bulk:
+// each save is the transaction of one event with the aggregates it changes, so its
+// message is acked, retried or dead lettered on its own and one invalid event does not
+// hold back the others, the writers of parallel consumers save concurrently instead.
+func (c *consumer[T]) process(s *Sink, msg *nats.Msg, event *T) {
+	err := traceSave(msg, c.entity, func(ctx context.Context) error { return c.save(ctx, s.WriteDB, event) })
+	if err != nil {
+		s.failMessage(msg, c.entity, err)
+		return
+	}
+	s.breaker.success()
+	metrics.IngestedEvents.WithLabelValues(c.entity).Inc()
+	s.bus.Publish(&events.Event{Kind: c.entity, Layer: c.layer(event), Payload: event})
+	msg.AckSync()
+	s.checkpoints.record(msg, c.layer(event))
+}
@@ -0,0 +1,185 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/metrics"
+	"github.com/swarmbit/spacemesh-state-api/pkg/apperror"
+)
+
+// Sink names used to pause and resume them at runtime.
+const (
+	SinkLayers              = "layers"
+	SinkRewards             = "rewards"
+	SinkAtx                 = "atx"
+	SinkTransactionsResult  = "transactions-result"
+	SinkTransactionsCreated = "transactions-created"
+	SinkMalfeasance         = "malfeasance"
+)
+
+// drainTimeout bounds how long a pause waits for the fetched events to be saved.
+const drainTimeout = 2 * time.Minute
+
+// pauseState is the pause flag of a sink and the count of its fetched events not yet
+// saved, a fetch only queues events while the sink is not paused.
+type pauseState struct {
+	mu       sync.Mutex
+	paused   bool
+	inflight int
+}
+
+// admit counts n fetched events as in flight unless the sink is paused.
+func (p *pauseState) admit(n int) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.paused {
+		return false
+	}
+	p.inflight += n
+	return true
+}
+
+// done counts an event as saved, or failed.
+func (p *pauseState) done() {
+	p.mu.Lock()
+	p.inflight--
+	p.mu.Unlock()
+}
+
+func (p *pauseState) set(paused bool) {
+	p.mu.Lock()
+	p.paused = paused
+	p.mu.Unlock()
+}
+
+func (p *pauseState) get() (bool, int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.paused, p.inflight
+}
+
+func newPausedSinks() map[string]*pauseState {
+	paused := make(map[string]*pauseState, len(sinkConsumers))
+	for _, consumer := range sinkConsumers {
+		paused[consumer.name] = &pauseState{}
+	}
+	return paused
+}
+
+// SetPaused stops or restarts the named sink. A pause returns once the events already
+// queued are saved, events fetched meanwhile are handed back to the stream.
+func (s *Sink) SetPaused(name string, paused bool) error {
+	state, exists := s.paused[name]
+	if !exists {
+		return apperror.New(apperror.InvalidInput, fmt.Sprintf("unknown sink %s", name))
+	}
+	state.set(paused)
+	log.Printf("Sink %s paused: %t", name, paused)
+	if !paused {
+		return nil
+	}
+	deadline := time.Now().Add(drainTimeout)
+	for {
+		_, inflight := state.get()
+		if inflight == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return apperror.New(apperror.Unavailable, fmt.Sprintf("sink %s paused with %d events still being saved", name, inflight))
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// PauseAll pauses every sink that is not paused yet and waits until their queued events
+// are saved. resume restarts the sinks it paused, also when the pause failed.
+func (s *Sink) PauseAll() (resume func(), err error) {
+	var paused []string
+	resume = func() {
+		for _, name := range paused {
+			s.SetPaused(name, false)
+		}
+	}
+	for name, state := range s.paused {
+		if already, _ := state.get(); already {
+			continue
+		}
+		paused = append(paused, name)
+		if err = s.SetPaused(name, true); err != nil {
+			return resume, err
+		}
+	}
+	return resume, nil
+}
+
+// PausedSinks reports for every sink if it is paused.
+func (s *Sink) PausedSinks() map[string]bool {
+	paused := make(map[string]bool, len(s.paused))
+	for name, state := range s.paused {
+		paused[name], _ = state.get()
+	}
+	return paused
+}
+
+// waitWhilePaused blocks while the named sink is paused and the sink is not stopping.
+func (s *Sink) waitWhilePaused(name string) {
+	for paused, _ := s.paused[name].get(); paused && !s.stopping.Load(); paused, _ = s.paused[name].get() {
+		time.Sleep(time.Second)
+	}
+}
+
+// Resync saves again the layers, rewards and transactions of the layers from and to
+// included, read from the streams by publish time. It returns the number of messages
+// saved.
+func (s *Sink) Resync(from uint32, to uint32) int {
+	start := layerStart(from).Add(-time.Duration(config.LayerDuration) * time.Second)
+	end := layerStart(to).Add(refetchSlack())
+	resynced := 0
+	for _, durable := range []string{
+		"state-api-process-layers",
+		"state-api-process-rewards",
+		"state-api-process-transactions-created",
+		"state-api-process-transactions-result",
+	} {
+		resynced += s.refetchRange(sinkConsumerFor(durable), start, end)
+	}
+	return resynced
+}
+
+// refetchRange saves again the messages of the consumer subject published between start
+// and end, reading the stream with an ordered consumer that is not acked.
+func (s *Sink) refetchRange(consumer *sinkConsumer, start time.Time, end time.Time) int {
+	sub, err := s.js.SubscribeSync(consumer.subject, nats.OrderedConsumer(), nats.StartTime(start), nats.BindStream(consumer.stream))
+	if err != nil {
+		log.Printf("Failed to refetch %s from %s: %v", consumer.subject, start, err)
+		return 0
+	}
+	defer sub.Unsubscribe()
+
+	refetched := 0
+	for {
+		msg, err := sub.NextMsg(5 * time.Second)
+		if err != nil {
+			// a timeout means the end of the stream was reached
+			break
+		}
+		meta, err := msg.Metadata()
+		if err != nil || meta.Timestamp.After(end) {
+			break
+		}
+		if _, err = consumer.resave(context.TODO(), s.WriteDB, msg.Data); err != nil {
+			log.Printf("Failed to save refetched %s message %d: %v", consumer.subject, meta.Sequence.Stream, err)
+			continue
+		}
+		refetched++
+		metrics.ReplayedMessages.WithLabelValues(consumer.stream).Inc()
+	}
+	log.Printf("Refetched %d %s messages published between %s and %s", refetched, consumer.subject, start, end)
+	return refetched
+}
@@ -0,0 +1,39 @@
+package sink
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/metrics"
+	"github.com/swarmbit/spacemesh-state-api/pkg/apperror"
+)
+
+// decodeMessage decodes a stream message into v. A message that does not decode is
+// invalid input, it fails the same way on every delivery.
+func decodeMessage(entity string, data []byte, v any) error {
+	if err := json.Unmarshal(data, v); err != nil {
+		return apperror.Wrap(apperror.InvalidInput, "invalid "+entity+" message", err)
+	}
+	return nil
+}
+
+// failMessage logs why msg was not saved and tells the stream what to do with it.
+// Invalid messages are terminated, the others are redelivered after the retry delay and
+// count towards opening the breaker.
+func (s *Sink) failMessage(msg *nats.Msg, entity string, err error) {
+	err = database.Classify(err)
+	kind := apperror.KindOf(err)
+	metrics.SinkSaveFailures.WithLabelValues(entity, string(kind)).Inc()
+	if kind == apperror.InvalidInput {
+		log.Printf("Dropping %s message: %s", entity, err.Error())
+		msg.Term()
+		return
+	}
+	s.breaker.failure()
+	delay := s.retry.delay(deliveries(msg))
+	log.Printf("Failed to save %s, %s, retry in %s: %s", entity, kind, delay.Round(time.Millisecond), err.Error())
+	msg.NakWithDelay(delay)
+}
@@ -0,0 +1,108 @@
+package sink
+
+import (
+	"errors"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/metrics"
+)
+
+// LeaderElection lets several sink instances run against the same database while only
+// one of them consumes and writes. The leader renews a lease stored next to the sink
+// fence, standbys poll it and take over once it expires.
+type LeaderElection struct {
+	writeDB    database.WriteStore
+	instanceId string
+	ttl        time.Duration
+	released   atomic.Bool
+}
+
+func NewLeaderElection(configValues *config.NatsConfig, writeDB database.WriteStore, instanceId string) *LeaderElection {
+	leaseSeconds := 30
+	if configValues.LeaseSeconds > 0 {
+		leaseSeconds = configValues.LeaseSeconds
+	}
+	return &LeaderElection{
+		writeDB:    writeDB,
+		instanceId: instanceId,
+		ttl:        time.Duration(leaseSeconds) * time.Second,
+	}
+}
+
+// Run waits for the lease and calls lead once this instance holds it, then keeps
+// renewing it three times per ttl so a slow database does not lose it. When the lease
+// is taken over, or may have expired because renewals failed, the writes of this
+// instance are fenced off and it waits for the lease again, lead is called on every
+// new leadership. Run returns once the lease is released.
+func (l *LeaderElection) Run(lead func()) {
+	interval := l.ttl / 3
+	for !l.released.Load() {
+		if !l.waitForLeadership(interval) {
+			return
+		}
+		lead()
+		l.renew(interval)
+	}
+}
+
+// waitForLeadership blocks until this instance holds the lease, it reports false when
+// the lease was released meanwhile.
+func (l *LeaderElection) waitForLeadership(interval time.Duration) bool {
+	log.Printf("Waiting for the writer lease as %s", l.instanceId)
+	for !l.released.Load() {
+		_, err := l.writeDB.AcquireLease(l.instanceId, l.ttl)
+		if err == nil {
+			metrics.SinkLeader.Set(1)
+			return true
+		}
+		if !errors.Is(err, database.ErrLeaseHeld) {
+			log.Printf("Failed to acquire writer lease: %v", err)
+		}
+		time.Sleep(interval)
+	}
+	return false
+}
+
+// renew extends the lease until it is lost, the writes stop before the lease can expire
+// so a standby never takes over while this instance still writes.
+func (l *LeaderElection) renew(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	validUntil := time.Now().Add(l.ttl)
+	for range ticker.C {
+		if l.released.Load() {
+			return
+		}
+		renewedAt := time.Now()
+		err := l.writeDB.RenewLease(l.ttl)
+		if errors.Is(err, database.ErrFenced) {
+			log.Printf("Writer lease of %s taken over, stop writing", l.instanceId)
+			metrics.SinkLeader.Set(0)
+			return
+		}
+		if err == nil {
+			validUntil = renewedAt.Add(l.ttl)
+			continue
+		}
+		log.Printf("Failed to renew writer lease: %v", err)
+		if time.Until(validUntil) < interval {
+			log.Printf("Writer lease of %s expires before the next renewal, stop writing", l.instanceId)
+			l.writeDB.FenceOff()
+			metrics.SinkLeader.Set(0)
+			return
+		}
+	}
+}
+
+// Release gives up the lease on shutdown so a standby does not wait for the ttl.
+func (l *LeaderElection) Release() {
+	l.released.Store(true)
+	if err := l.writeDB.ReleaseLease(); err != nil {
+		log.Printf("Failed to release writer lease: %v", err)
+	}
+	metrics.SinkLeader.Set(0)
+}
@@ -0,0 +1,54 @@
+package sink
+
+import (
+	"log"
+
+	natsS "github.com/spacemeshos/go-spacemesh/nats"
+	"github.com/swarmbit/spacemesh-state-api/pkg/address"
+)
+
+// Stream messages are normalized before they are saved so the stored addresses and
+// node ids match the form lookups use. A value that does not validate is logged and
+// kept as it came, the node published it and dropping the message would lose the event.
+
+func normalizeAddress(kind string, value string) string {
+	normalized, err := address.NormalizeAddress(value)
+	if err != nil {
+		log.Printf("Invalid address in %s %q: %s", kind, value, err.Error())
+		return value
+	}
+	return normalized
+}
+
+func normalizeNodeId(kind string, value string) string {
+	normalized, err := address.NormalizeNodeId(value)
+	if err != nil {
+		log.Printf("Invalid node id in %s %q: %s", kind, value, err.Error())
+		return value
+	}
+	return normalized
+}
+
+func normalizeReward(reward *natsS.Reward) {
+	reward.Coinbase = normalizeAddress("reward", reward.Coinbase)
+	reward.NodeID = normalizeNodeId("reward", reward.NodeID)
+}
+
+func normalizeAtx(atx *natsS.Atx) {
+	atx.Coinbase = normalizeAddress("atx", atx.Coinbase)
+	atx.NodeID = normalizeNodeId("atx", atx.NodeID)
+}
+
+func normalizeTransaction(transaction *natsS.Transaction) {
+	if transaction.Header == nil {
+		return
+	}
+	transaction.Header.Principal = normalizeAddress("transaction", transaction.Header.Principal)
+	for i, v := range transaction.Header.Addresses {
+		transaction.Header.Addresses[i] = normalizeAddress("transaction", v)
+	}
+}
+
+func normalizeMalfeasance(malfeasance *natsS.Malfeasance) {
+	malfeasance.NodeID = normalizeNodeId("malfeasance", malfeasance.NodeID)
+}
@@ -0,0 +1,132 @@
+package sink
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/nats-io/nats.go"
+	natsS "github.com/spacemeshos/go-spacemesh/nats"
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/metrics"
+	"github.com/swarmbit/spacemesh-state-api/pkg/transactionparser"
+	transactionparsertypes "github.com/swarmbit/spacemesh-state-api/pkg/transactionparser/transaction"
+	"github.com/swarmbit/spacemesh-state-api/price"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+// Publisher publishes what the sink saved on the state subjects, decoded and enriched,
+// so other services read normalized events instead of decoding the node events again.
+// Events are published after the save and before the ack, a redelivered message is
+// published again and consumers dedupe by id.
+type Publisher struct {
+	nc            *nats.Conn
+	priceResolver *price.PriceResolver
+	rewards       string
+	transactions  string
+	atxs          string
+}
+
+func NewPublisher(publishConfig *config.NatsPublishConfig, nc *nats.Conn, priceResolver *price.PriceResolver) *Publisher {
+	prefix := "state"
+	if publishConfig.Prefix != "" {
+		prefix = publishConfig.Prefix
+	}
+	return &Publisher{
+		nc:            nc,
+		priceResolver: priceResolver,
+		rewards:       prefix + ".rewards",
+		transactions:  prefix + ".transactions",
+		atxs:          prefix + ".atx",
+	}
+}
+
+func (p *Publisher) AddReward(reward *natsS.Reward) {
+	if p == nil {
+		return
+	}
+	usdValue := int64(-1)
+	if priceValue := p.priceResolver.GetPrice(); priceValue >= 0 {
+		usdValue = int64(priceValue * float64(reward.Total))
+	}
+	p.publish(p.rewards, &types.StateReward{
+		ID:          reward.ID,
+		Layer:       reward.Layer,
+		Timestamp:   config.GenesisEpochSeconds + int64(reward.Layer)*config.LayerDuration,
+		Coinbase:    reward.Coinbase,
+		NodeId:      reward.NodeID,
+		AtxId:       reward.AtxID,
+		LayerReward: reward.LayerReward,
+		Total:       reward.Total,
+		UsdValue:    usdValue,
+	})
+}
+
+func (p *Publisher) AddAtx(atx *natsS.Atx) {
+	if p == nil {
+		return
+	}
+	p.publish(p.atxs, &types.StateAtx{
+		ID:                atx.AtxID,
+		NodeId:            atx.NodeID,
+		Coinbase:          atx.Coinbase,
+		PublishEpoch:      atx.PublishEpoch,
+		TargetEpoch:       atx.PublishEpoch + 1,
+		EffectiveNumUnits: atx.EffectiveNumUnits,
+		BaseTick:          atx.BaseTick,
+		TickCount:         atx.TickCount,
+		Height:            atx.BaseTick + atx.TickCount,
+		Weight:            atx.TickCount * uint64(atx.EffectiveNumUnits),
+		Sequence:          atx.Sequence,
+		Received:          atx.Received,
+	})
+}
+
+// AddTransaction only takes transaction results, created transactions have no amount
+// or receiver yet.
+func (p *Publisher) AddTransaction(transaction *natsS.Transaction) {
+	if p == nil || transaction.Header == nil {
+		return
+	}
+	stateTransaction := &types.StateTransaction{
+		ID:               transaction.ID,
+		Layer:            transaction.Header.LayerID,
+		Timestamp:        config.GenesisEpochSeconds + int64(transaction.Header.LayerID)*config.LayerDuration,
+		Status:           transaction.Header.Status,
+		Message:          transaction.Header.Message,
+		Method:           transaction.Header.Method,
+		PrincipalAccount: transaction.Header.Principal,
+		Addresses:        transaction.Header.Addresses,
+		Gas:              transaction.Header.Gas,
+		Fee:              transaction.Header.Fee,
+	}
+	transactionData, err := transactionparser.Parse(transaction.Raw)
+	if err != nil {
+		log.Printf("Failed to parse transaction %s for publishing: %v", transaction.ID, err)
+	} else {
+		if receiver := transactionData.Tx.GetReceiver(); len(receiver.Bytes()) > 0 {
+			stateTransaction.ReceiverAccount = receiver.String()
+		}
+		if transactionData.Type == transactionparsertypes.TypeDrainVault {
+			stateTransaction.VaultAccount = transactionData.Vault.GetVault().String()
+		}
+		stateTransaction.Type = uint8(transactionData.Type)
+		stateTransaction.Amount = transactionData.Tx.GetAmount()
+		stateTransaction.GasPrice = transactionData.Tx.GetGasPrice()
+		stateTransaction.Fee = transaction.Header.Gas * stateTransaction.GasPrice
+		stateTransaction.Counter = transactionData.Tx.GetCounter()
+	}
+	p.publish(p.transactions, stateTransaction)
+}
+
+func (p *Publisher) publish(subject string, event interface{}) {
+	data, err := json.Marshal(event)
+	if err == nil {
+		err = p.nc.Publish(subject, data)
+	}
+	if err != nil {
+		log.Printf("Failed to publish on %s: %v", subject, err)
+		metrics.PublishedEvents.WithLabelValues(subject, "failed").Inc()
+		return
+	}
+	metrics.PublishedEvents.WithLabelValues(subject, "published").Inc()
+}
@@ -0,0 +1,137 @@
+package sink
+
+import (
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/metrics"
+)
+
+const (
+	defaultQueueSize = 1000
+	defaultWriters   = 16
+	// fetchBatch is the most messages a fetch pulls
+	fetchBatch = 100
+	// ackWait is how long the stream waits for the ack of a message before it delivers it
+	// again, the messages of queued events are marked in progress every progressInterval
+	ackWait          = 30 * time.Second
+	progressInterval = ackWait / 3
+)
+
+// queuedEvent is a decoded event waiting for a writer with the message it acks once
+// saved.
+type queuedEvent[T any] struct {
+	msg   *nats.Msg
+	event *T
+}
+
+// writeQueue is the bounded queue between the fetch of a consumer and its writers. The
+// fetch only pulls as many messages as the queue has room for and waits while it is
+// full, so a slow database pauses fetching instead of piling up unacked messages. The
+// messages of the events waiting or being saved are kept in progress so the stream does
+// not deliver them again while they are queued.
+type writeQueue[T any] struct {
+	entity  string
+	entries chan *queuedEvent[T]
+	// space wakes a fetch waiting for room, writers signal it on every event they take
+	space chan struct{}
+
+	mu      sync.Mutex
+	pending map[*queuedEvent[T]]struct{}
+	stopped chan struct{}
+}
+
+func newWriteQueue[T any](entity string, size int) *writeQueue[T] {
+	return &writeQueue[T]{
+		entity:  entity,
+		entries: make(chan *queuedEvent[T], size),
+		space:   make(chan struct{}, 1),
+		pending: make(map[*queuedEvent[T]]struct{}, size),
+		stopped: make(chan struct{}),
+	}
+}
+
+// waitForSpace blocks while the queue is full and returns how many events fit.
+func (q *writeQueue[T]) waitForSpace() int {
+	for {
+		free := cap(q.entries) - len(q.entries)
+		if free > 0 {
+			return free
+		}
+		metrics.SinkBackpressureWaits.WithLabelValues(q.entity).Inc()
+		<-q.space
+	}
+}
+
+// push never blocks once waitForSpace made room, the fetch is the only producer.
+func (q *writeQueue[T]) push(entry *queuedEvent[T]) {
+	q.mu.Lock()
+	q.pending[entry] = struct{}{}
+	q.mu.Unlock()
+	q.entries <- entry
+	metrics.SinkQueueDepth.WithLabelValues(q.entity).Set(float64(len(q.entries)))
+}
+
+// each hands the queued events to write until the queue is closed and drained.
+func (q *writeQueue[T]) each(write func(entry *queuedEvent[T])) {
+	for entry := range q.entries {
+		metrics.SinkQueueDepth.WithLabelValues(q.entity).Set(float64(len(q.entries)))
+		select {
+		case q.space <- struct{}{}:
+		default:
+		}
+		write(entry)
+		q.mu.Lock()
+		delete(q.pending, entry)
+		q.mu.Unlock()
+	}
+}
+
+// keepInProgress marks the messages of the pending events in progress every interval,
+// which restarts their ack wait, until stop.
+func (q *writeQueue[T]) keepInProgress(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-q.stopped:
+			return
+		case <-ticker.C:
+		}
+		q.mu.Lock()
+		msgs := make([]*nats.Msg, 0, len(q.pending))
+		for entry := range q.pending {
+			msgs = append(msgs, entry.msg)
+		}
+		q.mu.Unlock()
+		for _, msg := range msgs {
+			msg.InProgress()
+		}
+	}
+}
+
+// close lets the writers save what is queued and stop.
+func (q *writeQueue[T]) close() {
+	close(q.entries)
+}
+
+// stop ends keepInProgress once the writers returned.
+func (q *writeQueue[T]) stop() {
+	close(q.stopped)
+}
+
+func queueSize(natsConfig *config.NatsConfig) int {
+	if natsConfig.QueueSize > 0 {
+		return natsConfig.QueueSize
+	}
+	return defaultQueueSize
+}
+
+func writers(natsConfig *config.NatsConfig) int {
+	if natsConfig.Writers > 0 {
+		return natsConfig.Writers
+	}
+	return defaultWriters
+}
@@ -0,0 +1,134 @@
+package sink
+
+import (
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/metrics"
+)
+
+// retryPolicy spaces the redeliveries of a message that failed to save, so a database
+// hiccup is not answered with the same messages right away.
+type retryPolicy struct {
+	minDelay time.Duration
+	maxDelay time.Duration
+}
+
+func newRetryPolicy(retryConfig *config.RetryConfig) *retryPolicy {
+	policy := &retryPolicy{minDelay: 500 * time.Millisecond, maxDelay: time.Minute}
+	if retryConfig == nil {
+		return policy
+	}
+	if retryConfig.MinDelay > 0 {
+		policy.minDelay = time.Duration(retryConfig.MinDelay) * time.Millisecond
+	}
+	if retryConfig.MaxDelay > 0 {
+		policy.maxDelay = time.Duration(retryConfig.MaxDelay) * time.Second
+	}
+	return policy
+}
+
+// delay is the wait before the next delivery of a message delivered deliveries times,
+// doubled on every delivery with up to a fifth of jitter so messages that failed
+// together are not redelivered together.
+func (r *retryPolicy) delay(deliveries uint64) time.Duration {
+	delay := r.minDelay
+	for i := uint64(1); i < deliveries && delay < r.maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > r.maxDelay {
+		delay = r.maxDelay
+	}
+	return delay + time.Duration(rand.Int63n(int64(delay)/5+1))
+}
+
+// deliveries is how many times the stream delivered msg, 1 when it is not known.
+func deliveries(msg *nats.Msg) uint64 {
+	meta, err := msg.Metadata()
+	if err != nil || meta.NumDelivered == 0 {
+		return 1
+	}
+	return meta.NumDelivered
+}
+
+const (
+	breakerClosed = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// breaker stops the sinks from fetching while every save fails. It opens after
+// threshold consecutive failures and half opens after the cooldown, the sinks fetch
+// again and the next save closes it or opens it again.
+type breaker struct {
+	mu        sync.Mutex
+	state     int
+	failures  int
+	threshold int
+	cooldown  time.Duration
+	openUntil time.Time
+}
+
+func newBreaker(retryConfig *config.RetryConfig) *breaker {
+	b := &breaker{threshold: 10, cooldown: 30 * time.Second}
+	if retryConfig != nil && retryConfig.BreakerFailures > 0 {
+		b.threshold = retryConfig.BreakerFailures
+	}
+	if retryConfig != nil && retryConfig.BreakerCooldown > 0 {
+		b.cooldown = time.Duration(retryConfig.BreakerCooldown) * time.Second
+	}
+	metrics.SinkBreakerState.Set(breakerClosed)
+	return b
+}
+
+func (b *breaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	if b.state != breakerClosed {
+		log.Println("Sink breaker closed, saves succeed again")
+		b.setState(breakerClosed)
+	}
+}
+
+func (b *breaker) failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.state == breakerOpen || (b.state == breakerClosed && b.failures < b.threshold) {
+		return
+	}
+	log.Printf("Sink breaker opened after %d failed saves, stop fetching for %s", b.failures, b.cooldown)
+	b.openUntil = time.Now().Add(b.cooldown)
+	b.setState(breakerOpen)
+	metrics.SinkBreakerTrips.Inc()
+}
+
+// wait blocks while the breaker is open.
+func (b *breaker) wait() {
+	for {
+		b.mu.Lock()
+		if b.state != breakerOpen {
+			b.mu.Unlock()
+			return
+		}
+		remaining := time.Until(b.openUntil)
+		if remaining <= 0 {
+			log.Println("Sink breaker half open, fetch again")
+			b.setState(breakerHalfOpen)
+			b.mu.Unlock()
+			return
+		}
+		b.mu.Unlock()
+		time.Sleep(min(remaining, time.Second))
+	}
+}
+
+func (b *breaker) setState(state int) {
+	b.state = state
+	metrics.SinkBreakerState.Set(float64(state))
+}
@@ -1,338 +1,161 @@
 package sink
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
+	"log"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/nats-io/nats.go"
-	natsS "github.com/spacemeshos/go-spacemesh/nats"
-	"github.com/swarmbit/spacemesh-state-api/database"
 	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/events"
+	"github.com/swarmbit/spacemesh-state-api/price"
+	"github.com/swarmbit/spacemesh-state-api/tracing"
+	"github.com/swarmbit/spacemesh-state-api/webhook"
 )
 
 type Sink struct {
-	WriteDB                *database.WriteDB
-	layersSub              *nats.Subscription
-	rewardsSub             *nats.Subscription
-	atxSub                 *nats.Subscription
-	transactionsResultSub  *nats.Subscription
-	transactionsCreatedSub *nats.Subscription
-	malfeasanceSub         *nats.Subscription
+	WriteDB       database.WriteStore
+	conn          *connection
+	js            nats.JetStreamContext
+	subscriptions map[string]*nats.Subscription
+	checkpoints   *checkpointer
+	retry         *retryPolicy
+	breaker       *breaker
+	paused        map[string]*pauseState
+	stopping      atomic.Bool
+	running       sync.WaitGroup
+	// every saved event is published on it
+	bus *events.Bus
+	// events each consumer queues for its writers and the writers of parallel consumers
+	queueSize int
+	writers   int
 }
 
-func NewSink(configValues *config.Config, writeDB *database.WriteDB) *Sink {
-	nc, err := nats.Connect(configValues.Nats.Uri)
+// NewSink subscribes the clickhouse copy, the webhooks and the publisher that are enabled
+// to bus, other modules subscribe to it on their own.
+func NewSink(configValues *config.Config, writeDB database.WriteStore, readDB database.ReadStore, priceResolver *price.PriceResolver, bus *events.Bus) *Sink {
+	conn, err := connect(configValues.Nats)
 	if err != nil {
+		log.Println(err)
 		panic("Failed to connect to NATS")
 	}
-	js, _ := nc.JetStream()
-
-	js.AddConsumer("layers", &nats.ConsumerConfig{
-		Durable:        "state-api-process-layers",
-		DeliverSubject: "layers",
-		DeliverGroup:   "state-api-process-layers",
-		AckPolicy:      nats.AckExplicitPolicy,
-		DeliverPolicy:  nats.DeliverLastPolicy,
-	})
-
-	js.AddConsumer("rewards", &nats.ConsumerConfig{
-		Durable:        "state-api-process-rewards",
-		DeliverSubject: "rewards",
-		DeliverGroup:   "state-api-process-rewards",
-		AckPolicy:      nats.AckExplicitPolicy,
-		DeliverPolicy:  nats.DeliverLastPolicy,
-	})
+	js, _ := conn.nc.JetStream()
 
-	js.AddConsumer("atx", &nats.ConsumerConfig{
-		Durable:        "state-api-process-atx",
-		DeliverSubject: "atx",
-		DeliverGroup:   "state-api-process-atx",
-		AckPolicy:      nats.AckExplicitPolicy,
-		DeliverPolicy:  nats.DeliverLastPolicy,
-	})
-
-	js.AddConsumer("transactions", &nats.ConsumerConfig{
-		Durable:        "state-api-process-transactions-result",
-		DeliverSubject: "transactions.result",
-		DeliverGroup:   "state-api-process-transactions",
-		AckPolicy:      nats.AckExplicitPolicy,
-		DeliverPolicy:  nats.DeliverLastPolicy,
-	})
-
-	js.AddConsumer("transactions", &nats.ConsumerConfig{
-		Durable:        "state-api-process-transactions-created",
-		DeliverSubject: "transactions.created",
-		DeliverGroup:   "state-api-process-transactions",
-		AckPolicy:      nats.AckExplicitPolicy,
-		DeliverPolicy:  nats.DeliverLastPolicy,
-	})
-
-	js.AddConsumer("malfeasance", &nats.ConsumerConfig{
-		Durable:        "state-api-process-malfeasance",
-		DeliverSubject: "malfeasance",
-		DeliverGroup:   "state-api-process-malfeasance",
-		AckPolicy:      nats.AckExplicitPolicy,
-		DeliverPolicy:  nats.DeliverLastPolicy,
-	})
+	// a consumer can hold its queue and a fetch unacked, a consumer that already exists
+	// keeps the config it was created with
+	size := queueSize(configValues.Nats)
+	for _, consumer := range sinkConsumers {
+		js.AddConsumer(consumer.stream, consumer.config(size))
+	}
 
 	fmt.Println("Connect to nats stream")
-	layersSub, err := js.PullSubscribe("layers", "state-api-process-layers", nats.BindStream("layers"))
-	if err != nil {
-		fmt.Println("Failed to subscribe: ", err)
-	}
-	rewardsSub, err := js.PullSubscribe("rewards", "state-api-process-rewards", nats.BindStream("rewards"))
-	if err != nil {
-		fmt.Println("Failed to subscribe: ", err)
-	}
-	atxSub, err := js.PullSubscribe("atx", "state-api-process-atx", nats.BindStream("atx"))
-	if err != nil {
-		fmt.Println("Failed to subscribe: ", err)
+	subscriptions := make(map[string]*nats.Subscription, len(sinkConsumers))
+	for _, consumer := range sinkConsumers {
+		sub, err := js.PullSubscribe(consumer.subject, consumer.durable, nats.BindStream(consumer.stream))
+		if err != nil {
+			fmt.Println("Failed to subscribe: ", err)
+			continue
+		}
+		subscriptions[consumer.durable] = sub
 	}
-	transactionsResultSub, err := js.PullSubscribe("transactions.result", "state-api-process-transactions-result", nats.BindStream("transactions"))
-	if err != nil {
-		fmt.Println("Failed to subscribe: ", err)
+	if configValues.ClickHouse != nil && configValues.ClickHouse.Enabled {
+		clickHouse, err := NewClickHouseSink(configValues.ClickHouse)
+		if err != nil {
+			fmt.Println("Failed to start clickhouse sink, continue without it: ", err)
+		} else {
+			events.Subscribe(bus, "clickhouse", events.Reward, clickHouse.AddReward)
+			events.Subscribe(bus, "clickhouse", events.Atx, clickHouse.AddAtx)
+			events.Subscribe(bus, "clickhouse", events.TransactionResult, clickHouse.AddTransaction)
+		}
 	}
-	transactionsCreatedSub, err := js.PullSubscribe("transactions.created", "state-api-process-transactions-created", nats.BindStream("transactions"))
-	if err != nil {
-		fmt.Println("Failed to subscribe: ", err)
+	if configValues.Webhooks != nil && configValues.Webhooks.Enabled {
+		webhooks := webhook.NewNotifier(configValues.Webhooks, writeDB, readDB)
+		events.Subscribe(bus, "webhooks", events.Reward, webhooks.AddReward)
+		events.Subscribe(bus, "webhooks", events.TransactionResult, webhooks.AddTransaction)
+		events.Subscribe(bus, "webhooks", events.RewardDigest, webhooks.AddDigest)
 	}
-	malfeasanceSub, err := js.PullSubscribe("malfeasance", "state-api-process-malfeasance", nats.BindStream("malfeasance"))
-	if err != nil {
-		fmt.Println("Failed to subscribe: ", err)
+	if configValues.Nats.Publish != nil && configValues.Nats.Publish.Enabled {
+		publisher := NewPublisher(configValues.Nats.Publish, conn.nc, priceResolver)
+		events.Subscribe(bus, "publisher", events.Reward, publisher.AddReward)
+		events.Subscribe(bus, "publisher", events.Atx, publisher.AddAtx)
+		events.Subscribe(bus, "publisher", events.TransactionResult, publisher.AddTransaction)
 	}
-	return &Sink{
-		layersSub:              layersSub,
-		rewardsSub:             rewardsSub,
-		atxSub:                 atxSub,
-		transactionsResultSub:  transactionsResultSub,
-		transactionsCreatedSub: transactionsCreatedSub,
-		malfeasanceSub:         malfeasanceSub,
-		WriteDB:                writeDB,
+	s := &Sink{
+		conn:          conn,
+		js:            js,
+		subscriptions: subscriptions,
+		WriteDB:       writeDB,
+		checkpoints:   newCheckpointer(writeDB, 10*time.Second),
+		retry:         newRetryPolicy(configValues.Retry),
+		breaker:       newBreaker(configValues.Retry),
+		paused:        newPausedSinks(),
+		queueSize:     size,
+		writers:       writers(configValues.Nats),
+		bus:           bus,
 	}
+	s.verifyCheckpoints(js, readDB, configValues.Nats)
+	return s
 }
 
-func (s *Sink) StartRewardsSink() {
-	fmt.Println("Start rewards sink")
-	go func() {
-		for {
-			msgs, err := s.rewardsSub.Fetch(100, nats.MaxWait(2*time.Hour))
-			if err == nats.ErrTimeout {
-				fmt.Println("Error ", err.Error())
-				continue
-			}
-			var wg sync.WaitGroup
-			wg.Add(len(msgs))
-			for _, msg := range msgs {
-				go s.processRewardMessage(msg, &wg)
-			}
-			wg.Wait()
+// Start runs every consumer that subscribed, each one fetches in its own goroutine.
+func (s *Sink) Start() {
+	for _, consumer := range sinkConsumers {
+		sub, exists := s.subscriptions[consumer.durable]
+		if !exists {
+			log.Printf("Not starting %s sink, it did not subscribe", consumer.name)
+			continue
 		}
-	}()
-}
-
-func (s *Sink) processRewardMessage(msg *nats.Msg, wg *sync.WaitGroup) {
-	defer wg.Done()
-	fmt.Println("New reward")
-	var reward *natsS.Reward
-	errJson := json.Unmarshal(msg.Data, &reward)
-	fmt.Println("Next reward: ", reward.Layer)
-	if errJson != nil {
-		fmt.Println("Error parsing json reward: ", errJson)
-		msg.Nak()
-		return
-	}
-	saveErr := s.WriteDB.SaveReward(reward)
-
-	if saveErr != nil {
-		fmt.Println("Failed to save reward")
-		msg.Nak()
-	} else {
-		fmt.Println("Reward saved")
-		msg.AckSync()
+		s.running.Add(1)
+		go func() {
+			defer s.running.Done()
+			consumer.run(s, sub)
+		}()
 	}
 }
 
-func (s *Sink) StartLayersSink() {
-	fmt.Println("Start layers sink")
-
-	go func() {
-		for {
-			msgs, err := s.layersSub.Fetch(100, nats.MaxWait(2*time.Hour))
-			fmt.Println("New layers")
-			if err == nats.ErrTimeout {
-				fmt.Println("Error ", err.Error())
-				continue
-			}
-			for _, msg := range msgs {
-				fmt.Println("Layer: ", string(msg.Data))
-				var layer *natsS.LayerUpdate
-				errJson := json.Unmarshal(msg.Data, &layer)
-				fmt.Println("Next layer: ", layer.LayerID)
-				if errJson != nil {
-					fmt.Println("Error parsing json layer: ", errJson)
-					msg.Nak()
-					continue
-				}
-				saveErr := s.WriteDB.SaveLayer(layer)
-				if saveErr != nil {
-					fmt.Println("Failed to save layer")
-					msg.Nak()
-				} else {
-					fmt.Println("Layer saved")
-					msg.AckSync()
-				}
-			}
-		}
-	}()
+// Resume starts the consumers again once the ones that stopped when this instance was
+// fenced off returned, the subscriptions are kept while fenced off.
+func (s *Sink) Resume() {
+	s.running.Wait()
+	s.Start()
 }
 
-func (s *Sink) StartAtxSink() {
-	fmt.Println("Start atx sink")
+// Stop lets every consumer save the events it queued and waits for them up to timeout,
+// then saves the checkpoints and drains the nats connection. Messages fetched but not
+// saved by then are redelivered after the ack wait.
+func (s *Sink) Stop(timeout time.Duration) {
+	s.stopping.Store(true)
+	// wakes the fetches waiting for messages
+	s.conn.reset()
+	stopped := make(chan struct{})
 	go func() {
-		for {
-			msgs, err := s.atxSub.Fetch(100, nats.MaxWait(360*time.Hour))
-			if err == nats.ErrTimeout {
-				fmt.Println("Error ", err.Error())
-				continue
-			}
-
-			var wg sync.WaitGroup
-			wg.Add(len(msgs))
-			for _, msg := range msgs {
-				go s.processAtxMessage(msg, &wg)
-			}
-			wg.Wait()
-		}
+		s.running.Wait()
+		close(stopped)
 	}()
-}
-
-func (s *Sink) processAtxMessage(msg *nats.Msg, wg *sync.WaitGroup) {
-	defer wg.Done()
-	fmt.Println("Atx: ", string(msg.Data))
-	var atx *natsS.Atx
-	errJson := json.Unmarshal(msg.Data, &atx)
-	fmt.Println("Next atx: ", atx.NodeID)
-	if errJson != nil {
-		fmt.Println("Error parsing json atx: ", errJson)
-		msg.Nak()
-		return
+	select {
+	case <-stopped:
+		log.Println("Stopped sinks")
+	case <-time.After(timeout):
+		log.Printf("Sinks still running after %s, stop waiting", timeout)
 	}
-	saveErr := s.WriteDB.SaveAtx(atx)
-	if saveErr != nil {
-		fmt.Println("Failed to save atx")
-		msg.Nak()
-	} else {
-		fmt.Println("Atx saved")
-		msg.AckSync()
+	s.checkpoints.flush()
+	if err := s.conn.nc.Drain(); err != nil {
+		log.Printf("Failed to drain nats connection: %v", err)
 	}
 }
 
-func (s *Sink) StartTransactionResultSink() {
-	fmt.Println("Start transaction result sink")
-
-	go func() {
-		for {
-
-			msgs, err := s.transactionsResultSub.Fetch(100, nats.MaxWait(2*time.Hour))
-			if err == nats.ErrTimeout {
-				fmt.Println("Error ", err.Error())
-				continue
-			}
-			for _, msg := range msgs {
-
-				fmt.Println("Transaction: ", string(msg.Data))
-				var transaction *natsS.Transaction
-				errJson := json.Unmarshal(msg.Data, &transaction)
-				fmt.Println("Next transaction: ", transaction)
-				if errJson != nil {
-					fmt.Println("Error parsing json transaction: ", errJson)
-					msg.Nak()
-					continue
-				}
-				saveErr := s.WriteDB.SaveTransactions(transaction, true)
-				if saveErr != nil {
-					fmt.Println("Failed to save transaction")
-					msg.Nak()
-				} else {
-					fmt.Println("Transaction saved")
-					msg.AckSync()
-				}
-			}
-
-		}
-	}()
-}
-
-func (s *Sink) StartTransactionCreatedSink() {
-	fmt.Println("Start transaction created sink")
-
-	go func() {
-		for {
-
-			msgs, err := s.transactionsCreatedSub.Fetch(100, nats.MaxWait(2*time.Hour))
-			if err == nats.ErrTimeout {
-				fmt.Println("Error ", err.Error())
-				continue
-			}
-			for _, msg := range msgs {
-
-				fmt.Println("Transaction: ", string(msg.Data))
-				var transaction *natsS.Transaction
-				errJson := json.Unmarshal(msg.Data, &transaction)
-				fmt.Println("Next transaction: ", transaction)
-				if errJson != nil {
-					fmt.Println("Error parsing json transaction: ", errJson)
-					msg.Nak()
-					continue
-				}
-				saveErr := s.WriteDB.SaveTransactions(transaction, false)
-				if saveErr != nil {
-					fmt.Println("Failed to save transaction")
-					msg.Nak()
-				} else {
-					fmt.Println("Transaction saved")
-					msg.AckSync()
-				}
-			}
-
-		}
-	}()
-}
-
-func (s *Sink) StartMalfeasanceSink() {
-	fmt.Println("Start malfeasance created sink")
-
-	go func() {
-		for {
-
-			msgs, err := s.malfeasanceSub.Fetch(100, nats.MaxWait(8736*time.Hour))
-			if err == nats.ErrTimeout {
-				fmt.Println("Error ", err.Error())
-				continue
-			}
-			for _, msg := range msgs {
-
-				fmt.Println("Malfeasance: ", string(msg.Data))
-				var malfeasance *natsS.Malfeasance
-				errJson := json.Unmarshal(msg.Data, &malfeasance)
-				fmt.Println("Next Malfeasance: ", malfeasance)
-				if errJson != nil {
-					fmt.Println("Error parsing json malfeasance: ", errJson)
-					msg.Nak()
-					continue
-				}
-				saveErr := s.WriteDB.SaveMalfeasance(malfeasance)
-				if saveErr != nil {
-					fmt.Println("Failed to save malfeasance")
-					msg.Nak()
-				} else {
-					fmt.Println("Malfeasance saved")
-					msg.AckSync()
-				}
-			}
-
-		}
-	}()
+// traceSave runs save in a span of the consumed message, the message span also records
+// how long the message waited in the stream. save gets the context of its span so the
+// database commands are traced as its children.
+func traceSave(msg *nats.Msg, entity string, save func(ctx context.Context) error) error {
+	ctx, span := tracing.StartMessage(msg, "process "+entity)
+	saveCtx, saveSpan := tracing.Start(ctx, "save "+entity)
+	err := save(saveCtx)
+	tracing.End(saveSpan, err)
+	tracing.End(span, err)
+	return err
 }
@@ -0,0 +1,137 @@
+// Package tracing sets up opentelemetry and the spans of the sink, the database and
+// the api. When tracing is disabled the global noop provider is kept and starting
+// spans costs close to nothing.
+package tracing
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/swarmbit/spacemesh-state-api"
+
+var enabled bool
+
+// Init installs the exporter and the tracer provider, the returned function flushes
+// the pending spans on shutdown.
+func Init(configValues *config.TracingConfig) func() {
+	if configValues == nil || !configValues.Enabled {
+		return func() {}
+	}
+	endpoint := "localhost:4318"
+	if configValues.Endpoint != "" {
+		endpoint = configValues.Endpoint
+	}
+	serviceName := "spacemesh-state-api"
+	if configValues.ServiceName != "" {
+		serviceName = configValues.ServiceName
+	}
+	sampleRatio := 1.0
+	if configValues.SampleRatio > 0 {
+		sampleRatio = configValues.SampleRatio
+	}
+
+	exporterOptions := []otlptracehttp.Option{otlptracehttp.WithEndpoint(endpoint)}
+	if configValues.Insecure {
+		exporterOptions = append(exporterOptions, otlptracehttp.WithInsecure())
+	}
+	exporter, err := otlptracehttp.New(context.Background(), exporterOptions...)
+	if err != nil {
+		log.Printf("Failed to create trace exporter, tracing disabled: %v", err)
+		return func() {}
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(serviceName))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	enabled = true
+	log.Printf("Tracing enabled, exporting to %s", endpoint)
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := provider.Shutdown(ctx); err != nil {
+			log.Printf("Failed to flush traces: %v", err)
+		}
+	}
+}
+
+// Enabled reports if spans are exported.
+func Enabled() bool {
+	return enabled
+}
+
+func tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Start starts a span, child of the span in ctx if any.
+func Start(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer().Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// End records err on span and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// natsHeaderCarrier reads and writes the trace context in nats message headers.
+type natsHeaderCarrier nats.Header
+
+func (c natsHeaderCarrier) Get(key string) string {
+	return nats.Header(c).Get(key)
+}
+
+func (c natsHeaderCarrier) Set(key string, value string) {
+	nats.Header(c).Set(key, value)
+}
+
+func (c natsHeaderCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// StartMessage starts the span of a consumed message. It continues the trace of the
+// publisher when the message carries one and records how long the message waited in
+// the stream.
+func StartMessage(msg *nats.Msg, name string) (context.Context, trace.Span) {
+	ctx := context.Background()
+	if msg.Header != nil {
+		ctx = otel.GetTextMapPropagator().Extract(ctx, natsHeaderCarrier(msg.Header))
+	}
+	attributes := []attribute.KeyValue{
+		semconv.MessagingSystemKey.String("nats"),
+		semconv.MessagingDestinationName(msg.Subject),
+	}
+	if meta, err := msg.Metadata(); err == nil {
+		attributes = append(attributes,
+			attribute.Int64("messaging.nats.stream_sequence", int64(meta.Sequence.Stream)),
+			attribute.Int64("messaging.nats.queue_time_ms", time.Since(meta.Timestamp).Milliseconds()),
+		)
+	}
+	return tracer().Start(ctx, name, trace.WithSpanKind(trace.SpanKindConsumer), trace.WithAttributes(attributes...))
+}
@@ -1,5 +1,11 @@
 package types
 
+import (
+    "time"
+
+    "go.mongodb.org/mongo-driver/bson"
+)
+
 type RewardsDoc struct {
     Id          string `bson:"_id"`
     NodeId      string `bson:"node_id"`
@@ -15,6 +21,13 @@ type LayerDoc struct {
     Status int   `bson:"status"`
 }
 
+// LayerRewardsDoc is the number and the total of the rewards of a layer.
+type LayerRewardsDoc struct {
+    Layer       uint32 `bson:"_id"`
+    Count       int64  `bson:"count"`
+    TotalReward int64  `bson:"totalReward"`
+}
+
 type NodeDoc struct {
     ID          string             `bson:"_id"`
     Atxs        []NodeAtxDoc       `bson:"atxs"`
@@ -27,12 +40,13 @@ type NodesCount struct {
 }
 
 type MalfeasanceNodeDoc struct {
-    Received int64 `json:"received"`
+    Received int64  `json:"received"`
+    Layer    uint32 `json:"layer"`
 }
 
 type NodeAtxDoc struct {
     Coinbase          string `bson:"coinbase"`
-    PublishEpoch      uint32 `json:"publish_epoch"`
+    PublishEpoch      uint32 `bson:"publishEpoch" json:"publish_epoch"`
     EffectiveNumUnits uint32 `bson:"effectiveNumUnits"`
     Weight            uint64 `bson:"weight"`
     Sequence          uint64 `json:"sequence"`
@@ -62,13 +76,20 @@ type AtxDoc struct {
     TickCount         uint64 `bson:"tick_count"`
     Sequence          uint64 `json:"sequence"`
     Received          int64  `json:"received"`
+    // version of the stored format, see migrations.DocumentUpgrade
+    SchemaVersion     int    `bson:"schemaVersion"`
 }
 
+// AtxEpochDoc holds the totals of the atxs published in an epoch and its highest atx,
+// atxs of malfeasant nodes are not candidates for the highest.
 type AtxEpochDoc struct {
     ID                     int64  `bson:"_id"`
     TotalEffectiveNumUnits uint64 `bson:"totalEffectiveNumUnits"`
     TotalWeight            uint64 `bson:"totalWeight"`
     TotalAtx               uint64 `bson:"totalAtx"`
+    HighestAtx             string `bson:"highestAtx"`
+    HighestTick            uint64 `bson:"highestTick"`
+    HighestNode            string `bson:"highestNode"`
 }
 
 type TransactionDoc struct {
@@ -86,19 +107,75 @@ type TransactionDoc struct {
     Method          uint8  `json:"method"`
     Type            uint8  `json:"type"`
     Complete        bool   `json:"complete"`
+    // error of a failed result
+    Message         string `bson:"message"`
+    // block of the layer the result was applied in
+    BlockID         string `bson:"block_id"`
+    // unix seconds the created event was saved, 0 when the result came first
+    CreatedAt       int64  `bson:"created_at,omitempty"`
+    Raw             []byte `bson:"raw,omitempty"`
+    // version of the stored format, see migrations.DocumentUpgrade
+    SchemaVersion   int    `bson:"schemaVersion"`
+}
+
+// Transaction states, a created transaction moves to success or failure with its result.
+// TransactionApplied selects both results in filters.
+const (
+    TransactionCreated = "created"
+    TransactionSuccess = "success"
+    TransactionFailure = "failure"
+    TransactionApplied = "applied"
+)
+
+// State returns created until the result is saved, then success or failure.
+func (t *TransactionDoc) State() string {
+    if !t.Complete {
+        return TransactionCreated
+    }
+    if t.Status == 0 {
+        return TransactionSuccess
+    }
+    return TransactionFailure
 }
 
+// AccountDoc is an account with its totals. The activity is counted by the sink from the
+// rewards and successful transfers it saves, the layers are 0 before the first one.
 type AccountDoc struct {
-    Address      string `bson:"_id"`
-    Balance      uint64 `bson:"balance"`
-    TotalRewards uint64 `bson:"totalRewards"`
-    Fees         uint64 `bson:"fees"`
-    Sent         uint64 `bson:"sent"`
+    Address           string `bson:"_id"`
+    Balance           uint64 `bson:"balance"`
+    TotalRewards      uint64 `bson:"totalRewards"`
+    Fees              uint64 `bson:"fees"`
+    Sent              uint64 `bson:"sent"`
+    FirstSeenLayer    uint32 `bson:"firstSeenLayer"`
+    LastActivityLayer uint32 `bson:"lastActivityLayer"`
+    IncomingTransfers uint64 `bson:"incomingTransfers"`
+    OutgoingTransfers uint64 `bson:"outgoingTransfers"`
+}
+
+// AccountCounterDoc is the highest counter, the nonce, of the applied transactions an
+// account is the principal of.
+type AccountCounterDoc struct {
+    Address string `bson:"_id"`
+    Counter uint64 `bson:"counter"`
+}
+
+// BalanceChangeDoc is the net change of the balance of an account in a layer by the
+// rewards and transactions applied, the balance history sums them.
+type BalanceChangeDoc struct {
+    Id    BalanceChangeId `bson:"_id"`
+    Delta int64           `bson:"delta"`
+}
+
+type BalanceChangeId struct {
+    Account string `bson:"account"`
+    Layer   uint32 `bson:"layer"`
 }
 
 type NetworkInfoDoc struct {
     Id                string `bson:"_id"`
     CirculatingSupply uint64 `bson:"circulatingSupply"`
+    IssuedSubsidy     uint64 `bson:"issuedSubsidy"`
+    FeesPaid          uint64 `bson:"feesPaid"`
 }
 
 type AccountGroup struct {
@@ -117,3 +194,457 @@ type AggregationAtxTotals struct {
     TotalWeight            int64 `bson:"totalWeight"`
     TotalEffectiveNumUnits int64 `bson:"totalEffectiveNumUnits"`
 }
+
+type SmesherDoc struct {
+    ID                string `bson:"_id"`
+    Coinbase          string `bson:"coinbase"`
+    EffectiveNumUnits uint32 `bson:"effectiveNumUnits"`
+    LastEpoch         uint32 `bson:"lastEpoch"`
+    TotalAtx          int64  `bson:"totalAtx"`
+    TotalRewards      int64  `bson:"totalRewards"`
+    RewardsCount      int64  `bson:"rewardsCount"`
+}
+
+type SmesherEpochDoc struct {
+    Id           SmesherEpochId `bson:"_id"`
+    Coinbase     string         `bson:"coinbase"`
+    Rewards      int64          `bson:"rewards"`
+    RewardsCount int64          `bson:"rewardsCount"`
+}
+
+type SmesherEpochId struct {
+    NodeId string `bson:"node_id"`
+    Epoch  uint32 `bson:"epoch"`
+}
+
+// SmesherPerformanceDoc compares the rewards of a smesher in an epoch with the slots its
+// atx targeting the epoch was eligible for. ExpectedRewards are the slots of the layers
+// processed so far, every slot once the epoch is Complete.
+type SmesherPerformanceDoc struct {
+    Id              SmesherEpochId `bson:"_id"`
+    Coinbase        string         `bson:"coinbase"`
+    Weight          uint64         `bson:"weight"`
+    Slots           int32          `bson:"slots"`
+    ExpectedRewards int64          `bson:"expectedRewards"`
+    Rewards         int64          `bson:"rewards"`
+    RewardsCount    int64          `bson:"rewardsCount"`
+    Complete        bool           `bson:"complete"`
+}
+
+// EpochPerformanceDoc sums the performance of every smesher eligible in an epoch.
+type EpochPerformanceDoc struct {
+    Smeshers        int64 `bson:"smeshers"`
+    Slots           int64 `bson:"slots"`
+    ExpectedRewards int64 `bson:"expectedRewards"`
+    RewardsCount    int64 `bson:"rewardsCount"`
+    Complete        bool  `bson:"complete"`
+}
+
+// MissedRewards returns the slots of the epoch that were not paid, nil when the epoch
+// performance was not aggregated yet.
+func (e *EpochPerformanceDoc) MissedRewards() *MissedRewards {
+    if e.Smeshers == 0 {
+        return nil
+    }
+    missed := max(e.ExpectedRewards-e.RewardsCount, 0)
+    missRate := 0.0
+    if e.ExpectedRewards > 0 {
+        missRate = float64(missed) / float64(e.ExpectedRewards)
+    }
+    return &MissedRewards{
+        Slots:           e.Slots,
+        ExpectedRewards: e.ExpectedRewards,
+        RewardsCount:    e.RewardsCount,
+        Missed:          missed,
+        MissRate:        missRate,
+        Complete:        e.Complete,
+    }
+}
+
+// CoinbaseRewardsTotalDoc is the rewards of a coinbase in an epoch, or in every epoch
+// with the all epochs id, updated as each reward is saved.
+type CoinbaseRewardsTotalDoc struct {
+    Id           CoinbaseRewardsTotalId `bson:"_id"`
+    Rewards      int64                  `bson:"rewards"`
+    RewardsCount int64                  `bson:"rewardsCount"`
+}
+
+type CoinbaseRewardsTotalId struct {
+    Coinbase string `bson:"coinbase"`
+    Epoch    int64  `bson:"epoch"`
+}
+
+type RewardsRollupDoc struct {
+    Id           RewardsRollupId `bson:"_id"`
+    Rewards      int64           `bson:"rewards"`
+    RewardsCount int64           `bson:"rewardsCount"`
+}
+
+// RewardsRollupId has no account in network wide rollups. Bucket is the epoch or the
+// unix time of the start of the day.
+type RewardsRollupId struct {
+    Account     string `bson:"account,omitempty"`
+    Granularity string `bson:"granularity"`
+    Bucket      int64  `bson:"bucket"`
+}
+
+// FeesRollupDoc sums the fees of the applied transactions of a layer or an epoch,
+// FeesRewarded and MedianFee are only set for epochs.
+type FeesRollupDoc struct {
+    Id           FeesRollupId `bson:"_id"`
+    Transactions int64        `bson:"transactions"`
+    Gas          int64        `bson:"gas"`
+    Fees         int64        `bson:"fees"`
+    MinFee       int64        `bson:"minFee"`
+    MaxFee       int64        `bson:"maxFee"`
+    MedianFee    int64        `bson:"medianFee"`
+    MinGasPrice  int64        `bson:"minGasPrice"`
+    MaxGasPrice  int64        `bson:"maxGasPrice"`
+    FeesRewarded int64        `bson:"feesRewarded"`
+}
+
+// FeesRollupId Bucket is the layer or the epoch.
+type FeesRollupId struct {
+    Granularity string `bson:"granularity"`
+    Bucket      int64  `bson:"bucket"`
+}
+
+type ReorgDoc struct {
+    TriggerLayer      uint32 `bson:"triggerLayer"`
+    LastAppliedLayer  uint32 `bson:"lastAppliedLayer"`
+    Depth             uint32 `bson:"depth"`
+    AffectedDocuments int64  `bson:"affectedDocuments"`
+    Timestamp         int64  `bson:"timestamp"`
+}
+
+type ChangeDoc struct {
+    Sequence   int64       `bson:"_id"`
+    Entity     string      `bson:"entity"`
+    Operation  string      `bson:"operation"`
+    DocumentId interface{} `bson:"documentId"`
+    Document   bson.M      `bson:"document"`
+    Timestamp  time.Time   `bson:"timestamp"`
+}
+
+type StatsDoc struct {
+    Timestamp   time.Time          `bson:"timestamp"`
+    Collections map[string]int64   `bson:"collections"`
+    IngestRates map[string]float64 `bson:"ingestRates"`
+    ApiQps      float64            `bson:"apiQps"`
+}
+
+type NetworkSnapshotDoc struct {
+    Timestamp         time.Time `bson:"timestamp"`
+    Epoch             uint32    `bson:"epoch"`
+    Layer             uint64    `bson:"layer"`
+    TotalWeight       uint64    `bson:"totalWeight"`
+    ActiveSmeshers    uint64    `bson:"activeSmeshers"`
+    TotalAccounts     uint64    `bson:"totalAccounts"`
+    CirculatingSupply uint64    `bson:"circulatingSupply"`
+    Price             float64   `bson:"price"`
+}
+
+// NetworkInfoHistoryDoc is the network info of the last state refresh in a layer.
+type NetworkInfoHistoryDoc struct {
+    Layer                   uint64    `bson:"_id"`
+    Epoch                   uint32    `bson:"epoch"`
+    CreatedAt               time.Time `bson:"createdAt"`
+    TotalWeight             uint64    `bson:"totalWeight"`
+    TotalSlots              uint64    `bson:"totalSlots"`
+    EffectiveUnitsCommited  uint64    `bson:"effectiveUnitsCommited"`
+    ActiveSmeshers          uint64    `bson:"activeSmeshers"`
+    TotalAccounts           uint64    `bson:"totalAccounts"`
+    CirculatingSupply       uint64    `bson:"circulatingSupply"`
+    Price                   float64   `bson:"price"`
+    NextEpochWeight         uint64    `bson:"nextEpochWeight"`
+    NextEpochActiveSmeshers uint64    `bson:"nextEpochActiveSmeshers"`
+    NextEpochEffectiveUnits uint64    `bson:"nextEpochEffectiveUnits"`
+}
+
+// StreamCheckpointDoc is the last position the sink acked on a jetstream consumer and
+// the fence of the instance that saved it.
+type StreamCheckpointDoc struct {
+    Consumer         string    `bson:"_id"`
+    Stream           string    `bson:"stream"`
+    Sequence         uint64    `bson:"sequence"`
+    Layer            uint32    `bson:"layer"`
+    UpdatedAt        time.Time `bson:"updatedAt"`
+    WriterInstance   string    `bson:"writerInstance,omitempty"`
+    WriterGeneration int64     `bson:"writerGeneration,omitempty"`
+}
+
+// PoetHealthDoc is the last probe of a configured poet, Since is when it last went up
+// or down.
+type PoetHealthDoc struct {
+    Name         string    `bson:"_id"`
+    Address      string    `bson:"address"`
+    Up           bool      `bson:"up"`
+    CurrentRound string    `bson:"currentRound"`
+    Error        string    `bson:"error"`
+    Since        time.Time `bson:"since"`
+    CheckedAt    time.Time `bson:"checkedAt"`
+}
+
+type PriceDoc struct {
+    Timestamp time.Time `bson:"timestamp"`
+    USDPrice  float64   `bson:"usdPrice"`
+    Source    string    `bson:"source"`
+}
+
+type PriceBucketDoc struct {
+    Bucket   int64   `bson:"_id"`
+    USDPrice float64 `bson:"usdPrice"`
+    Min      float64 `bson:"min"`
+    Max      float64 `bson:"max"`
+    Samples  int64   `bson:"samples"`
+}
+
+type FenceDoc struct {
+    Id         string    `bson:"_id"`
+    InstanceId string    `bson:"instanceId"`
+    Generation int64     `bson:"generation"`
+    AcquiredAt time.Time `bson:"acquiredAt"`
+    LeaseUntil time.Time `bson:"leaseUntil,omitempty"`
+}
+
+// StateReward is a saved reward as published on the state rewards subject, UsdValue is
+// the value at the price when it was saved, -1 when the price is unknown.
+type StateReward struct {
+    ID          string `json:"id"`
+    Layer       uint32 `json:"layer"`
+    Timestamp   int64  `json:"timestamp"`
+    Coinbase    string `json:"coinbase"`
+    NodeId      string `json:"nodeId"`
+    AtxId       string `json:"atxId"`
+    LayerReward uint64 `json:"layerReward"`
+    Total       uint64 `json:"total"`
+    UsdValue    int64  `json:"usdValue"`
+}
+
+// StateTransaction is a transaction result with its decoded body as published on the
+// state transactions subject, the decoded fields are empty when it could not be parsed.
+type StateTransaction struct {
+    ID               string   `json:"id"`
+    Layer            uint32   `json:"layer"`
+    Timestamp        int64    `json:"timestamp"`
+    Status           uint8    `json:"status"`
+    Message          string   `json:"message,omitempty"`
+    Method           uint8    `json:"method"`
+    Type             uint8    `json:"type"`
+    PrincipalAccount string   `json:"principalAccount"`
+    ReceiverAccount  string   `json:"receiverAccount,omitempty"`
+    VaultAccount     string   `json:"vaultAccount,omitempty"`
+    Addresses        []string `json:"addresses"`
+    Amount           uint64   `json:"amount"`
+    Gas              uint64   `json:"gas"`
+    GasPrice         uint64   `json:"gasPrice"`
+    Fee              uint64   `json:"fee"`
+    Counter          uint64   `json:"counter"`
+}
+
+// StateAtx is a saved atx as published on the state atx subject, Height is the base tick
+// plus the tick count and Weight the tick count times the effective units.
+type StateAtx struct {
+    ID                string `json:"id"`
+    NodeId            string `json:"nodeId"`
+    Coinbase          string `json:"coinbase"`
+    PublishEpoch      uint32 `json:"publishEpoch"`
+    TargetEpoch       uint32 `json:"targetEpoch"`
+    EffectiveNumUnits uint32 `json:"effectiveNumUnits"`
+    BaseTick          uint64 `json:"baseTick"`
+    TickCount         uint64 `json:"tickCount"`
+    Height            uint64 `json:"height"`
+    Weight            uint64 `json:"weight"`
+    Sequence          uint64 `json:"sequence"`
+    Received          int64  `json:"received"`
+}
+
+// BlockDoc is a block of a layer, counted from the transaction results applied in it.
+type BlockDoc struct {
+    ID           string `bson:"_id"`
+    Layer        int64  `bson:"layer"`
+    Transactions int64  `bson:"transactions"`
+}
+
+// VaultDoc is a vault spawned by a transaction, vesting bounds are layers. Genesis vaults
+// are only in the vesting schedule until their spawn is seen.
+type VaultDoc struct {
+    Address             string `bson:"_id"`
+    Owner               string `bson:"owner"`
+    TotalAmount         uint64 `bson:"totalAmount"`
+    InitialUnlockAmount uint64 `bson:"initialUnlockAmount"`
+    VestingStart        uint32 `bson:"vestingStart"`
+    VestingEnd          uint32 `bson:"vestingEnd"`
+    SpawnTransaction    string `bson:"spawnTransaction"`
+    SpawnLayer          uint32 `bson:"spawnLayer"`
+}
+
+// MultisigDoc is a multisig or vesting account spawned by a transaction, public keys are
+// hex in the order signatures reference them.
+type MultisigDoc struct {
+    Address          string   `bson:"_id"`
+    Template         string   `bson:"template"`
+    Required         uint8    `bson:"required"`
+    PublicKeys       []string `bson:"publicKeys"`
+    SpawnTransaction string   `bson:"spawnTransaction"`
+    SpawnLayer       uint32   `bson:"spawnLayer"`
+}
+
+// Multisig templates, a vesting account is a multisig that can drain its vault.
+const (
+    MultisigTemplate = "multisig"
+    VestingTemplate  = "vesting"
+)
+
+// MultisigSignaturesDoc are the signatures of a transaction with a multisig principal,
+// Refs index the public keys of the multisig.
+type MultisigSignaturesDoc struct {
+    Transaction string   `bson:"_id"`
+    Account     string   `bson:"account"`
+    Layer       uint32   `bson:"layer"`
+    Status      uint8    `bson:"status"`
+    Type        uint8    `bson:"type"`
+    Refs        []uint32 `bson:"refs"`
+}
+
+// WebhookDoc is a webhook registered by the holder of an api key, Owner is the hash of
+// the key. It receives the Events, reward and transaction, of the Addresses.
+type WebhookDoc struct {
+    ID        string   `bson:"_id"`
+    Owner     string   `bson:"owner"`
+    Url       string   `bson:"url"`
+    Addresses []string `bson:"addresses"`
+    Events    []string `bson:"events"`
+    Secret    string   `bson:"secret"`
+    CreatedAt int64    `bson:"createdAt"`
+}
+
+// Webhook events and delivery statuses.
+const (
+    WebhookEventReward      = "reward"
+    WebhookEventTransaction = "transaction"
+    WebhookEventDigest      = "digest"
+
+    WebhookDeliveryPending   = "pending"
+    WebhookDeliveryDelivered = "delivered"
+    WebhookDeliveryFailed    = "failed"
+)
+
+// WebhookDeliveryDoc is an event queued for a webhook. The id is made of the webhook,
+// the event and the reward or transaction so a redelivered message is not queued twice.
+// Pending deliveries are sent from NextAttempt, a unix time.
+type WebhookDeliveryDoc struct {
+    ID          string `bson:"_id"`
+    Webhook     string `bson:"webhook"`
+    Event       string `bson:"event"`
+    Payload     string `bson:"payload"`
+    Status      string `bson:"status"`
+    Attempts    int    `bson:"attempts"`
+    NextAttempt int64  `bson:"nextAttempt"`
+    LastStatus  int    `bson:"lastStatus"`
+    LastError   string `bson:"lastError"`
+    CreatedAt   int64  `bson:"createdAt"`
+    DeliveredAt int64  `bson:"deliveredAt"`
+}
+
+// Kinds of the watchlist items.
+const (
+    WatchlistKindAddress = "address"
+    WatchlistKindNode    = "node"
+)
+
+// WatchlistItemDoc is an address or node id of the watchlist of Owner, the hash of its
+// api key. ID is the owner, kind and item so an item is watched once.
+type WatchlistItemDoc struct {
+    ID        string `bson:"_id"`
+    Owner     string `bson:"owner"`
+    Kind      string `bson:"kind"`
+    Item      string `bson:"item"`
+    CreatedAt int64  `bson:"createdAt"`
+}
+
+// Frequencies of the email digests.
+const (
+    EmailDaily  = "daily"
+    EmailWeekly = "weekly"
+)
+
+// EmailSubscriptionDoc is an email address getting the digest of the watchlist of Owner,
+// the hash of its api key. Token is the secret of its confirmation and unsubscribe links,
+// LastDay the start of the last UTC day a digest was sent for. A Pending subscription gets
+// no digest until the link sent to the address is confirmed. Slot is unique per owner and
+// below the max emails, so concurrent subscribes can not go over it.
+type EmailSubscriptionDoc struct {
+    ID        string `bson:"_id"`
+    Owner     string `bson:"owner"`
+    Email     string `bson:"email"`
+    Frequency string `bson:"frequency"`
+    Token     string `bson:"token"`
+    CreatedAt int64  `bson:"createdAt"`
+    LastDay   int64  `bson:"lastDay"`
+    Pending   bool   `bson:"pending"`
+    Slot      int    `bson:"slot"`
+}
+
+// PoolRewardDoc is a reward of a member node of the pool, ID is the reward id so a reward
+// processed again is saved once.
+type PoolRewardDoc struct {
+    ID          string `bson:"_id"`
+    NodeId      string `bson:"nodeId"`
+    Coinbase    string `bson:"coinbase"`
+    Layer       uint32 `bson:"layer"`
+    Epoch       uint32 `bson:"epoch"`
+    TotalReward int64  `bson:"totalReward"`
+}
+
+type PoolNodeRewardsId struct {
+    Epoch  uint32 `bson:"epoch"`
+    NodeId string `bson:"nodeId"`
+}
+
+// PoolNodeRewardsDoc is the number and the total of the saved rewards of a node in an
+// epoch.
+type PoolNodeRewardsDoc struct {
+    Id          PoolNodeRewardsId `bson:"_id"`
+    Count       int64             `bson:"count"`
+    TotalReward int64             `bson:"totalReward"`
+}
+
+// CoinbaseRewardsDoc is the number and the total of the rewards of a coinbase.
+type CoinbaseRewardsDoc struct {
+    Coinbase    string `bson:"_id"`
+    Count       int64  `bson:"count"`
+    TotalReward int64  `bson:"totalReward"`
+}
+
+// RewardDigestId is the coinbase and the unix time of the start of the UTC day.
+type RewardDigestId struct {
+    Coinbase string `bson:"coinbase"`
+    Day      int64  `bson:"day"`
+}
+
+// RewardDigestDoc sums the rewards of a coinbase in a day. USDValue is the rewards at
+// USDPrice, the last price recorded in the day, -1 when none was. Complete is set once
+// every layer of the day was processed.
+type RewardDigestDoc struct {
+    Id       RewardDigestId `bson:"_id"`
+    Count    int64          `bson:"count"`
+    Rewards  int64          `bson:"rewards"`
+    USDPrice float64        `bson:"usdPrice"`
+    USDValue int64          `bson:"usdValue"`
+    Complete bool           `bson:"complete"`
+}
+
+// Digest is the digest posted to webhooks and served by the api.
+func (d *RewardDigestDoc) Digest() *CoinbaseDigest {
+    return &CoinbaseDigest{
+        Coinbase: d.Id.Coinbase,
+        Day:      d.Id.Day,
+        Date:     time.Unix(d.Id.Day, 0).UTC().Format(time.DateOnly),
+        Count:    d.Count,
+        Rewards:  d.Rewards,
+        USDPrice: d.USDPrice,
+        USDValue: d.USDValue,
+        Complete: d.Complete,
+    }
+}
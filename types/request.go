@@ -6,4 +6,32 @@ type NodeFilterRequest struct {
 
 type AccounGroupRequest struct {
 	Accounts []string `json:"accounts"`
-}
\ No newline at end of file
+}
+type VerifySignatureRequest struct {
+	Address   string `json:"address"`
+	NodeId    string `json:"nodeId"`
+	PublicKey string `json:"publicKey"`
+	Message   string `json:"message"`
+	Signature string `json:"signature"`
+}
+
+// WebhookRequest registers a webhook, Events are reward and transaction, both when
+// empty.
+type WebhookRequest struct {
+	Url       string   `json:"url"`
+	Addresses []string `json:"addresses"`
+	Events    []string `json:"events"`
+}
+
+// WatchlistRequest adds addresses and node ids to the watchlist.
+type WatchlistRequest struct {
+	Addresses []string `json:"addresses"`
+	NodeIds   []string `json:"nodeIds"`
+}
+
+// EmailSubscriptionRequest registers an email address for the digest of the watchlist,
+// Frequency is daily or weekly, daily when empty.
+type EmailSubscriptionRequest struct {
+	Email     string `json:"email"`
+	Frequency string `json:"frequency"`
+}
@@ -10,6 +10,21 @@ type Epoch struct {
     TotalWeight            uint64 `json:"totalWeight"`
     TotalRewards           int64  `json:"totalRewards"`
     TotalActiveSmeshers    uint64 `json:"totalActiveSmeshers"`
+    StartTime              string         `json:"startTime"`
+    EndTime                string         `json:"endTime"`
+    MissedRewards          *MissedRewards `json:"missedRewards"`
+}
+
+// MissedRewards compares the slots of the smeshers eligible in an epoch with the rewards
+// paid. ExpectedRewards are the slots of the layers processed so far, MissRate the
+// share of them that were not paid.
+type MissedRewards struct {
+    Slots           int64   `json:"slots"`
+    ExpectedRewards int64   `json:"expectedRewards"`
+    RewardsCount    int64   `json:"rewardsCount"`
+    Missed          int64   `json:"missed"`
+    MissRate        float64 `json:"missRate"`
+    Complete        bool    `json:"complete"`
 }
 
 type Atx struct {
@@ -18,12 +33,32 @@ type Atx struct {
     EffectiveNumUnits uint32 `json:"effectiveNumUnits"`
     Weight            uint64 `json:"weight"`
     Received          int64  `json:"received"`
+    ReceivedTime      string `json:"receivedTime"`
+}
+
+type AtxDetail struct {
+    AtxId             string `json:"atxId"`
+    NodeId            string `json:"nodeId"`
+    Coinbase          string `json:"coinbase"`
+    PublishEpoch      uint32 `json:"publishEpoch"`
+    TargetEpoch       uint32 `json:"targetEpoch"`
+    EffectiveNumUnits uint32 `json:"effectiveNumUnits"`
+    Weight            uint64 `json:"weight"`
+    BaseTick          uint64 `json:"baseTick"`
+    TickCount         uint64 `json:"tickCount"`
+    TickHeight        uint64 `json:"tickHeight"`
+    Sequence          uint64 `json:"sequence"`
+    PreviousAtx       string `json:"previousAtx,omitempty"`
+    Received          int64  `json:"received"`
+    ReceivedTime      string `json:"receivedTime"`
 }
 
 type ShortAccount struct {
     TotalRewards uint64 `json:"totalRewards"`
     Balance      uint64 `json:"balance"`
     USDValue     int64  `json:"usdValue"`
+    FiatValue    int64  `json:"fiatValue,omitempty"`
+    Currency     string `json:"currency,omitempty"`
     Address      string `json:"address"`
 }
 
@@ -31,6 +66,22 @@ type AccountGroupResponse struct {
     TotalRewards uint64 `json:"totalRewards"`
     Balance      uint64 `json:"balance"`
     USDValue     int64  `json:"usdValue"`
+    FiatValue    int64  `json:"fiatValue,omitempty"`
+    Currency     string `json:"currency,omitempty"`
+}
+
+// AccountBatchItem is an account of a batch lookup, in the order of the request. Found is
+// false for addresses without rewards or transactions yet. Nonce is the next nonce, one
+// more than the counter of the last applied transaction the account sent.
+type AccountBatchItem struct {
+    Address      string `json:"address"`
+    Found        bool   `json:"found"`
+    Balance      uint64 `json:"balance"`
+    TotalRewards uint64 `json:"totalRewards"`
+    Nonce        uint64 `json:"nonce"`
+    USDValue     int64  `json:"usdValue"`
+    FiatValue    int64  `json:"fiatValue,omitempty"`
+    Currency     string `json:"currency,omitempty"`
 }
 type AccountPostResponse struct {
     Account                string `json:"account"`
@@ -39,15 +90,29 @@ type AccountPostResponse struct {
     TotalWeight            uint64 `json:"totalWeight"`
 }
 
+// Account is an account with its activity summary, the first seen and last activity
+// times are left out before the sink recorded a reward or transfer of the account.
 type Account struct {
-    Balance              uint64 `json:"balance"`
-    USDValue             int64  `json:"usdValue"`
-    BalanceDisplay       string `json:"balanceDisplay"`
-    NumberOfTransactions int64  `json:"numberOfTransactions"`
-    Counter              int64  `json:"counter"`
-    NumberOfRewards      int64  `json:"numberOfRewards"`
-    TotalRewards         uint64 `json:"totalRewards"`
-    Address              string `json:"address"`
+    Balance               uint64 `json:"balance"`
+    USDValue              int64  `json:"usdValue"`
+    FiatValue             int64  `json:"fiatValue,omitempty"`
+    Currency              string `json:"currency,omitempty"`
+    BalanceDisplay        string `json:"balanceDisplay"`
+    NumberOfTransactions  int64  `json:"numberOfTransactions"`
+    Counter               int64  `json:"counter"`
+    NumberOfRewards       int64  `json:"numberOfRewards"`
+    TotalRewards          uint64 `json:"totalRewards"`
+    Address               string `json:"address"`
+    // the activity is left out until it was computed for the accounts saved before it
+    FirstSeenLayer        *uint32 `json:"firstSeenLayer,omitempty"`
+    FirstSeenTime         string  `json:"firstSeenTime,omitempty"`
+    FirstSeenTimestamp    int64   `json:"firstSeenTimestamp,omitempty"`
+    LastActivityLayer     *uint32 `json:"lastActivityLayer,omitempty"`
+    LastActivityTime      string  `json:"lastActivityTime,omitempty"`
+    LastActivityTimestamp int64   `json:"lastActivityTimestamp,omitempty"`
+    IncomingTransfers     *uint64 `json:"incomingTransfers,omitempty"`
+    OutgoingTransfers     *uint64 `json:"outgoingTransfers,omitempty"`
+    TotalFees             uint64  `json:"totalFees"`
 }
 
 type Reward struct {
@@ -60,19 +125,159 @@ type Reward struct {
     Timestamp      int64  `json:"timestamp"`
 }
 
-type Transaction struct {
+// TransactionReceipt is the lifecycle of a transaction, the layer hash and the block id
+// are what light clients verify its inclusion against.
+type TransactionReceipt struct {
     ID               string `json:"id"`
+    State            string `json:"state"`
     Status           uint8  `json:"status"`
-    PrincipalAccount string `json:"principalAccount"`
-    ReceiverAccount  string `json:"receiverAccount"`
-    VaultAccount     string `json:"vaultAccount"`
-    Fee              uint64 `json:"fee"`
-    Amount           uint64 `json:"amount"`
+    Message          string `json:"message,omitempty"`
+    CreatedTime      string `json:"createdTime,omitempty"`
+    CreatedTimestamp int64  `json:"createdTimestamp,omitempty"`
     Layer            uint32 `json:"layer"`
-    Counter          uint64 `json:"counter"`
-    Method           string `json:"method"`
-    Type             uint8  `json:"type"`
+    LayerHash        string `json:"layerHash,omitempty"`
+    BlockId          string `json:"blockId,omitempty"`
+    Time             string `json:"time"`
     Timestamp        int64  `json:"timestamp"`
+    GasUsed          uint64 `json:"gasUsed"`
+    FeePaid          uint64 `json:"feePaid"`
+    Raw              string `json:"raw"`
+}
+
+// FeesPoint is the fees of a layer or an epoch, BurnedFees and MedianFee are only set
+// for epochs.
+type FeesPoint struct {
+    Bucket       int64  `json:"bucket"`
+    Timestamp    int64  `json:"timestamp"`
+    Time         string `json:"time"`
+    Transactions int64  `json:"transactions"`
+    GasUsed      int64  `json:"gasUsed"`
+    TotalFees    int64  `json:"totalFees"`
+    AverageFee   int64  `json:"averageFee"`
+    MedianFee    int64  `json:"medianFee,omitempty"`
+    MinFee       int64  `json:"minFee"`
+    MaxFee       int64  `json:"maxFee"`
+    MinGasPrice  int64  `json:"minGasPrice"`
+    MaxGasPrice  int64  `json:"maxGasPrice"`
+    BurnedFees   int64  `json:"burnedFees,omitempty"`
+}
+
+// FeeEstimate suggests gas prices from the recent applied transactions, Gas is their
+// median gas so the fees are what a similar transaction pays.
+type FeeEstimate struct {
+    FromLayer     uint32           `json:"fromLayer"`
+    FromLayerTime string           `json:"fromLayerTime"`
+    Transactions  int              `json:"transactions"`
+    Gas           uint64           `json:"gas"`
+    Slow          *FeeEstimateTier `json:"slow"`
+    Average       *FeeEstimateTier `json:"average"`
+    Fast          *FeeEstimateTier `json:"fast"`
+}
+
+type FeeEstimateTier struct {
+    GasPrice uint64 `json:"gasPrice"`
+    Fee      uint64 `json:"fee"`
+}
+
+// AccountVesting is the vault of an account, amounts are in smidge and the vesting
+// bounds are layers. Schedule is what vests at the start of each epoch left.
+type AccountVesting struct {
+    Address             string          `json:"address"`
+    Owner               string          `json:"owner,omitempty"`
+    Genesis             bool            `json:"genesis"`
+    Spawned             bool            `json:"spawned"`
+    TotalVaulted        uint64          `json:"totalVaulted"`
+    InitialUnlockAmount uint64          `json:"initialUnlockAmount"`
+    VestingStart        uint64          `json:"vestingStart"`
+    VestingStartTime    string          `json:"vestingStartTime"`
+    VestingEnd          uint64          `json:"vestingEnd"`
+    VestingEndTime      string          `json:"vestingEndTime"`
+    Layer               uint64          `json:"layer"`
+    Vested              uint64          `json:"vested"`
+    Drained             uint64          `json:"drained"`
+    Available           uint64          `json:"available"`
+    Locked              uint64          `json:"locked"`
+    Schedule            []*VestingPoint `json:"schedule"`
+}
+
+type VestingPoint struct {
+    Epoch     uint64 `json:"epoch"`
+    Layer     uint64 `json:"layer"`
+    Time      string `json:"time"`
+    Timestamp int64  `json:"timestamp"`
+    Vested    uint64 `json:"vested"`
+}
+
+// AccountMultisig is the configuration of a multisig or vesting account, Required of
+// the public keys must sign. Signatures are the ones seen on its transactions.
+type AccountMultisig struct {
+    Address          string                `json:"address"`
+    Template         string                `json:"template"`
+    Required         uint8                 `json:"required"`
+    PublicKeys       []string              `json:"publicKeys"`
+    SpawnTransaction string                `json:"spawnTransaction"`
+    SpawnLayer       uint32                `json:"spawnLayer"`
+    SpawnTime        string                `json:"spawnTime"`
+    Signatures       []*MultisigSignatures `json:"signatures"`
+}
+
+type MultisigSignatures struct {
+    TransactionId string            `json:"transactionId"`
+    Layer         uint32            `json:"layer"`
+    Time          string            `json:"time"`
+    Status        uint8             `json:"status"`
+    Type          uint8             `json:"type"`
+    Signers       []*MultisigSigner `json:"signers"`
+}
+
+type MultisigSigner struct {
+    Ref       uint32 `json:"ref"`
+    PublicKey string `json:"publicKey"`
+}
+
+// SearchResponse is what a search query matched, Path is the endpoint of each result.
+type SearchResponse struct {
+    Query   string          `json:"query"`
+    Results []*SearchResult `json:"results"`
+}
+
+type SearchResult struct {
+    Type  string  `json:"type"`
+    ID    string  `json:"id"`
+    Path  string  `json:"path"`
+    Layer *uint64 `json:"layer,omitempty"`
+    Epoch *uint64 `json:"epoch,omitempty"`
+    Time  string  `json:"time,omitempty"`
+}
+
+type Block struct {
+    ID           string `json:"id"`
+    Layer        int64  `json:"layer"`
+    Transactions int64  `json:"transactions"`
+    Time         string `json:"time"`
+    Timestamp    int64  `json:"timestamp"`
+}
+
+// Transaction is an applied or created transaction. Confirmations are the layers from its
+// layer to the last verified one, set by the transaction scans.
+type Transaction struct {
+    ID               string  `json:"id"`
+    Status           uint8   `json:"status"`
+    State            string  `json:"state"`
+    Message          string  `json:"message,omitempty"`
+    Gas              uint64  `json:"gas"`
+    PrincipalAccount string  `json:"principalAccount"`
+    ReceiverAccount  string  `json:"receiverAccount"`
+    VaultAccount     string  `json:"vaultAccount"`
+    Fee              uint64  `json:"fee"`
+    Amount           uint64  `json:"amount"`
+    Layer            uint32  `json:"layer"`
+    Counter          uint64  `json:"counter"`
+    Method           string  `json:"method"`
+    Type             uint8   `json:"type"`
+    Time             string  `json:"time"`
+    Timestamp        int64   `json:"timestamp"`
+    Confirmations    *uint32 `json:"confirmations,omitempty"`
 }
 
 type RewardDetails struct {
@@ -99,6 +304,8 @@ type Eligibility struct {
 type NetworkInfo struct {
     Epoch                  uint32                `json:"epoch"`
     Layer                  uint64                `json:"layer"`
+    EpochStartTime         string                `json:"epochStartTime"`
+    LayerTime              string                `json:"layerTime"`
     EffectiveUnitsCommited uint64                `json:"effectiveUnitsCommited"`
     EpochSubsidy           uint64                `json:"epochSubsidy"`
     TotalSlots             uint64                `json:"totalSlots"`
@@ -107,17 +314,660 @@ type NetworkInfo struct {
     TotalRewards           uint64                `json:"rewards"`
     Price                  float64               `json:"price"`
     MarketCap              uint64                `json:"marketCap"`
+    Currency               string                `json:"currency,omitempty"`
+    FiatPrice              float64               `json:"fiatPrice,omitempty"`
+    FiatMarketCap          uint64                `json:"fiatMarketCap,omitempty"`
     TotalAccounts          uint64                `json:"totalAccounts"`
     TotalActiveSmeshers    uint64                `json:"totalActiveSmeshers"`
     AtxHex                 string                `json:"atxHex"`
     AtxBase64              string                `json:"atxBase64"`
     Vested                 uint64                `json:"vested"`
     TotalVaulted           uint64                `json:"totalVaulted"`
+    Supply                 *SupplyBreakdown      `json:"supply"`
     NextEpoch              *NetworkInfoNextEpoch `json:"nextEpoch"`
+    MissedRewards          *MissedRewards        `json:"missedRewards"`
+    Snapshot               *StateSnapshot        `json:"snapshot,omitempty"`
+    Node                   *NodeStatus           `json:"node,omitempty"`
 }
 
+// HighestAtxCandidate is an atx of a publish epoch by tick height, the highest has rank 1.
+type HighestAtxCandidate struct {
+    Rank       int    `json:"rank"`
+    AtxHex     string `json:"atxHex"`
+    AtxBase64  string `json:"atxBase64"`
+    NodeId     string `json:"nodeId"`
+    Coinbase   string `json:"coinbase"`
+    BaseTick   uint64 `json:"baseTick"`
+    TickCount  uint64 `json:"tickCount"`
+    TickHeight uint64 `json:"tickHeight"`
+    Weight     uint64 `json:"weight"`
+    Malfeasant bool   `json:"malfeasant"`
+}
+
+// HighestAtx is the atx the strategy picks from the atxs of a publish epoch, ranked among
+// the candidates of the strategy, and the highest atxs of the epoch ranked among all.
+type HighestAtx struct {
+    Epoch      uint32                 `json:"epoch"`
+    Strategy   string                 `json:"strategy"`
+    Rank       int                    `json:"rank"`
+    Selected   *HighestAtxCandidate   `json:"selected"`
+    Candidates []*HighestAtxCandidate `json:"candidates"`
+}
+
+// NetworkInfoHistory is the network info as of the last state refresh in a layer.
+type NetworkInfoHistory struct {
+    Layer                   uint64  `json:"layer"`
+    LayerTime               string  `json:"layerTime"`
+    Epoch                   uint32  `json:"epoch"`
+    CreatedAt               int64   `json:"createdAt"`
+    TotalWeight             uint64  `json:"totalWeight"`
+    TotalSlots              uint64  `json:"totalSlots"`
+    EffectiveUnitsCommited  uint64  `json:"effectiveUnitsCommited"`
+    TotalActiveSmeshers     uint64  `json:"totalActiveSmeshers"`
+    TotalAccounts           uint64  `json:"totalAccounts"`
+    CirculatingSupply       uint64  `json:"circulatingSupply"`
+    Price                   float64 `json:"price"`
+    NextEpochWeight         uint64  `json:"nextEpochWeight"`
+    NextEpochActiveSmeshers uint64  `json:"nextEpochActiveSmeshers"`
+    NextEpochEffectiveUnits uint64  `json:"nextEpochEffectiveUnits"`
+}
+
+// StateSnapshot identifies the network state refresh a response was computed from, the
+// version grows on every refresh.
+type StateSnapshot struct {
+    Version   uint64 `json:"version"`
+    Layer     uint64 `json:"layer"`
+    CreatedAt int64  `json:"createdAt"`
+}
+
+// NatsStatus is the connection of the sink to the nats server.
+type NatsStatus struct {
+    Connected  bool   `json:"connected"`
+    Status     string `json:"status"`
+    Url        string `json:"url,omitempty"`
+    Reconnects uint64 `json:"reconnects"`
+    LastError  string `json:"lastError,omitempty"`
+}
+
+// Health is the state of the connections of the instance, Nats and Node are only set
+// when the sink or the node client run.
+type Health struct {
+    Status string      `json:"status"`
+    Nats   *NatsStatus `json:"nats,omitempty"`
+    Node   *NodeStatus `json:"node,omitempty"`
+}
+
+// NodeStatus is the last query of the node grpc api, only set while the node client
+// runs. The layers are kept from the last successful query when the node is unreachable.
+type NodeStatus struct {
+    Connected      bool   `json:"connected"`
+    Synced         bool   `json:"synced"`
+    ConnectedPeers uint64 `json:"connectedPeers"`
+    CurrentLayer   uint32 `json:"currentLayer"`
+    SyncedLayer    uint32 `json:"syncedLayer"`
+    TopLayer       uint32 `json:"topLayer"`
+    VerifiedLayer  uint32 `json:"verifiedLayer"`
+    Error          string `json:"error,omitempty"`
+    CheckedAt      int64  `json:"checkedAt"`
+}
+
+// NetworkInfoNextEpoch is the next epoch as of the atxs published so far, the projections
+// change until the registration deadline, the start of the next epoch, when the atxs
+// for it can no longer be published.
 type NetworkInfoNextEpoch struct {
-    Epoch                  uint32 `json:"epoch"`
-    EffectiveUnitsCommited int64  `json:"effectiveUnitsCommited"`
-    TotalActiveSmeshers    int64  `json:"totalActiveSmeshers"`
+    Epoch                    uint32 `json:"epoch"`
+    StartTime                string `json:"startTime"`
+    EffectiveUnitsCommited   int64  `json:"effectiveUnitsCommited"`
+    TotalActiveSmeshers      int64  `json:"totalActiveSmeshers"`
+    TotalWeight              uint64 `json:"totalWeight"`
+    EpochSubsidy             uint64 `json:"epochSubsidy"`
+    ProjectedTotalSlots      uint64 `json:"projectedTotalSlots"`
+    ProjectedRewardPerSlot   uint64 `json:"projectedRewardPerSlot"`
+    RegistrationDeadline     int64  `json:"registrationDeadline"`
+    RegistrationDeadlineTime string `json:"registrationDeadlineTime"`
+    SecondsToDeadline        int64  `json:"secondsToDeadline"`
+}
+
+type TopSmesher struct {
+    NodeId            string `json:"nodeId"`
+    Coinbase          string `json:"coinbase"`
+    EffectiveNumUnits uint32 `json:"effectiveNumUnits"`
+    TotalAtx          int64  `json:"totalAtx"`
+    TotalRewards      int64  `json:"totalRewards"`
+    RewardsCount      int64  `json:"rewardsCount"`
+    Malfeasant        bool   `json:"malfeasant"`
+    Epoch             uint32 `json:"epoch,omitempty"`
+    EpochRewards      int64  `json:"epochRewards,omitempty"`
+}
+
+type MalfeasantNode struct {
+    NodeId         string   `json:"nodeId"`
+    Layer          uint32   `json:"layer"`
+    LayerTime      string   `json:"layerTime"`
+    Epoch          uint32   `json:"epoch"`
+    Received       int64    `json:"received"`
+    ReceivedTime   string   `json:"receivedTime"`
+    AffectedEpochs []uint32 `json:"affectedEpochs"`
+}
+
+type VerifySignatureResponse struct {
+    Valid     bool   `json:"valid"`
+    Address   string `json:"address,omitempty"`
+    NodeId    string `json:"nodeId,omitempty"`
+    PublicKey string `json:"publicKey"`
+}
+
+type SmesherEligibility struct {
+    NodeId       string            `json:"nodeId"`
+    Malfeasant   bool              `json:"malfeasant"`
+    CurrentEpoch *EpochEligibility `json:"currentEpoch"`
+    NextEpoch    *EpochEligibility `json:"nextEpoch"`
+}
+
+type EpochEligibility struct {
+    Epoch             uint32 `json:"epoch"`
+    StartTime         string `json:"startTime"`
+    Count             int32  `json:"count"`
+    EffectiveNumUnits int64  `json:"effectiveNumUnits"`
+    Weight            int64  `json:"weight"`
+    TotalWeight       uint64 `json:"totalWeight"`
+    EpochSubsidy      uint64 `json:"epochSubsidy"`
+    PredictedRewards  uint64 `json:"predictedRewards"`
+}
+
+// SmesherPerformance is the rewards of a smesher in an epoch against its eligibility,
+// Score is the rewards received over the rewards expected so far, Missed the expected
+// rewards it did not receive.
+type SmesherPerformance struct {
+    Epoch           uint32  `json:"epoch"`
+    Slots           int32   `json:"slots"`
+    ExpectedRewards int64   `json:"expectedRewards"`
+    RewardsCount    int64   `json:"rewardsCount"`
+    Rewards         int64   `json:"rewards"`
+    Missed          int64   `json:"missed"`
+    Score           float64 `json:"score"`
+    Complete        bool    `json:"complete"`
+}
+
+type CoinbaseSmeshers struct {
+    Coinbase      string             `json:"coinbase"`
+    TotalSmeshers int                `json:"totalSmeshers"`
+    CurrentEpoch  *EpochEligibility  `json:"currentEpoch"`
+    NextEpoch     *EpochEligibility  `json:"nextEpoch"`
+    Smeshers      []*CoinbaseSmesher `json:"smeshers"`
+}
+
+type CoinbaseSmesher struct {
+    NodeId            string           `json:"nodeId"`
+    Malfeasant        bool             `json:"malfeasant"`
+    EffectiveNumUnits uint32           `json:"effectiveNumUnits"`
+    LastEpoch         uint32           `json:"lastEpoch"`
+    TotalAtx          int64            `json:"totalAtx"`
+    TotalRewards      int64            `json:"totalRewards"`
+    RewardsCount      int64            `json:"rewardsCount"`
+    CurrentEpoch      *SmesherEpochAtx `json:"currentEpoch"`
+    NextEpoch         *SmesherEpochAtx `json:"nextEpoch"`
+}
+
+type SmesherEpochAtx struct {
+    Epoch             uint32 `json:"epoch"`
+    Active            bool   `json:"active"`
+    AtxId             string `json:"atxId,omitempty"`
+    Count             int32  `json:"count"`
+    EffectiveNumUnits uint32 `json:"effectiveNumUnits"`
+    Weight            uint64 `json:"weight"`
+    PredictedRewards  uint64 `json:"predictedRewards"`
+}
+
+type Reorg struct {
+    TriggerLayer      uint32 `json:"triggerLayer"`
+    TriggerLayerTime  string `json:"triggerLayerTime"`
+    LastAppliedLayer  uint32 `json:"lastAppliedLayer"`
+    Depth             uint32 `json:"depth"`
+    AffectedDocuments int64  `json:"affectedDocuments"`
+    Time              string `json:"time"`
+    Timestamp         int64  `json:"timestamp"`
+}
+
+type EstimatedRewards struct {
+    NumUnits        uint64                   `json:"numUnits"`
+    EstimatedWeight uint64                   `json:"estimatedWeight"`
+    TotalWeight     uint64                   `json:"totalWeight"`
+    Epochs          []*EstimatedEpochRewards `json:"epochs"`
+}
+
+type EstimatedEpochRewards struct {
+    Epoch            uint32 `json:"epoch"`
+    StartTime        string `json:"startTime"`
+    EpochSubsidy     uint64 `json:"epochSubsidy"`
+    Slots            int32  `json:"slots"`
+    PredictedRewards uint64 `json:"predictedRewards"`
+}
+
+type SupplyBreakdown struct {
+    Layer             uint64 `json:"layer"`
+    LayerTime         string `json:"layerTime"`
+    GenesisVaults     uint64 `json:"genesisVaults"`
+    Vested            uint64 `json:"vested"`
+    Locked            uint64 `json:"locked"`
+    RewardsIssued     uint64 `json:"rewardsIssued"`
+    SubsidyIssued     uint64 `json:"subsidyIssued"`
+    FeesPaid          uint64 `json:"feesPaid"`
+    BurnedFees        uint64 `json:"burnedFees"`
+    CirculatingSupply uint64 `json:"circulatingSupply"`
+    TotalSupply       uint64 `json:"totalSupply"`
+}
+
+type Change struct {
+    Sequence   int64       `json:"sequence"`
+    Entity     string      `json:"entity"`
+    Operation  string      `json:"operation"`
+    DocumentId interface{} `json:"documentId"`
+    Document   interface{} `json:"document"`
+    Time       string      `json:"time"`
+    Timestamp  int64       `json:"timestamp"`
+}
+
+type ChangesResponse struct {
+    Changes    []*Change `json:"changes"`
+    Checkpoint int64     `json:"checkpoint"`
+    HasMore    bool      `json:"hasMore"`
+}
+
+type StatsSnapshot struct {
+    Timestamp   int64              `json:"timestamp"`
+    Collections map[string]int64   `json:"collections"`
+    IngestRates map[string]float64 `json:"ingestRates"`
+    ApiQps      float64            `json:"apiQps"`
+}
+
+type StreamCheckpoint struct {
+    Consumer         string `json:"consumer"`
+    Stream           string `json:"stream"`
+    Sequence         uint64 `json:"sequence"`
+    Layer            uint32 `json:"layer"`
+    UpdatedAt        int64  `json:"updatedAt"`
+    WriterInstance   string `json:"writerInstance,omitempty"`
+    WriterGeneration int64  `json:"writerGeneration,omitempty"`
+}
+
+type AdminOperation struct {
+    Id         int64  `json:"id"`
+    Action     string `json:"action"`
+    StartedAt  int64  `json:"startedAt"`
+    FinishedAt int64  `json:"finishedAt,omitempty"`
+    Result     string `json:"result,omitempty"`
+    Error      string `json:"error,omitempty"`
+}
+
+type PricePoint struct {
+    Timestamp int64   `json:"timestamp"`
+    USDPrice  float64 `json:"usdPrice"`
+    Min       float64 `json:"min"`
+    Max       float64 `json:"max"`
+    Samples   int64   `json:"samples"`
+}
+
+// RewardsChartPoint is a day or an epoch of rewards, Bucket is the epoch number or the
+// unix time of the start of the day.
+type RewardsChartPoint struct {
+    Bucket    int64  `json:"bucket"`
+    Timestamp int64  `json:"timestamp"`
+    Time      string `json:"time"`
+    Rewards   int64  `json:"rewards"`
+    Count     int64  `json:"count"`
+}
+
+// BalanceHistoryPoint is the balance of an account at the end of a layer, a day or an
+// epoch and the net change in it, Bucket is the layer, the unix time of the start of the
+// day or the epoch number.
+type BalanceHistoryPoint struct {
+    Bucket    int64  `json:"bucket"`
+    Timestamp int64  `json:"timestamp"`
+    Time      string `json:"time"`
+    Balance   int64  `json:"balance"`
+    Change    int64  `json:"change"`
+}
+
+// ChartPoint is the last value of a network metric in a day or an epoch, Bucket is the
+// epoch number or the unix time of the start of the day.
+type ChartPoint struct {
+    Bucket    int64   `json:"bucket"`
+    Timestamp int64   `json:"timestamp"`
+    Time      string  `json:"time"`
+    Value     float64 `json:"value"`
+}
+
+type RewardExport struct {
+    Layer     int64  `json:"layer"`
+    Time      string `json:"time"`
+    SmesherId string `json:"smesher_id"`
+    AmountSMH string `json:"amount_smh"`
+    USDPrice  string `json:"usd_price"`
+    USDValue  string `json:"usd_value"`
+}
+
+// NetworkParameters are the protocol constants of the followed network, times are
+// unix seconds and durations seconds.
+type NetworkParameters struct {
+    Network               string             `json:"network"`
+    GenesisId             string             `json:"genesisId"`
+    GenesisTimestamp      int64              `json:"genesisTimestamp"`
+    GenesisTime           string             `json:"genesisTime"`
+    LayerDuration         int64              `json:"layerDuration"`
+    LayersPerEpoch        uint32             `json:"layersPerEpoch"`
+    EpochDuration         int64              `json:"epochDuration"`
+    EffectiveGenesisLayer uint32             `json:"effectiveGenesisLayer"`
+    AddressPrefix         string             `json:"addressPrefix"`
+    Subsidy               *SubsidyParameters `json:"subsidy"`
+}
+
+// SubsidyParameters describe the issuance curve, the subsidy accumulated n layers after
+// the effective genesis is totalSubsidy * (1 - e^(-lambda * (n + 1))). Amounts are in
+// smidge and the vesting bounds are layers.
+type SubsidyParameters struct {
+    TotalIssuance  uint64  `json:"totalIssuance"`
+    TotalSubsidy   uint64  `json:"totalSubsidy"`
+    TotalVaulted   uint64  `json:"totalVaulted"`
+    TenYearTarget  uint64  `json:"tenYearTarget"`
+    Lambda         float64 `json:"lambda"`
+    HalfLifeLayers float64 `json:"halfLifeLayers"`
+    FinalLayer     float64 `json:"finalLayer"`
+    VestStart      uint32  `json:"vestStart"`
+    VestEnd        uint32  `json:"vestEnd"`
+}
+
+// PoetStatus is a configured poet with the round it runs now.
+type PoetStatus struct {
+    Name        string      `json:"name"`
+    Description string      `json:"description"`
+    DiscordLink string      `json:"discordLink"`
+    PhaseShift  int         `json:"phaseShift"`
+    CycleGap    int         `json:"cycleGap"`
+    Round       *PoetRound  `json:"round"`
+    Health      *PoetHealth `json:"health,omitempty"`
+}
+
+// Countdown is a moment of the smeshing calendar, At in unix seconds, and the seconds
+// left until it, 0 once it passed.
+type Countdown struct {
+    At          int64  `json:"at"`
+    AtTime      string `json:"atTime"`
+    SecondsLeft int64  `json:"secondsLeft"`
+}
+
+// Countdowns are the next epoch start and the registration windows of the configured
+// poets, computed from the clock and not from the processed layers.
+type Countdowns struct {
+    Now       int64             `json:"now"`
+    Layer     uint64            `json:"layer"`
+    LayerTime string            `json:"layerTime"`
+    Epoch     uint32            `json:"epoch"`
+    NextEpoch *Countdown        `json:"nextEpoch"`
+    Poets     []*PoetCountdowns `json:"poets"`
+}
+
+// PoetCountdowns is the registration window of a poet open now or the next one, for the
+// atxs of PublishEpoch, and the start of its next cycle gap.
+type PoetCountdowns struct {
+    Name               string     `json:"name"`
+    Phase              string     `json:"phase"`
+    PublishEpoch       uint32     `json:"publishEpoch"`
+    RegistrationOpens  *Countdown `json:"registrationOpens"`
+    RegistrationCloses *Countdown `json:"registrationCloses"`
+    CycleGapStart      *Countdown `json:"cycleGapStart"`
+}
+
+// NodeAtxStatus tells whether a node published its atx of Epoch, the one targeting
+// TargetEpoch, before Deadline, the start of the target epoch. Atx is nil until it is
+// published. Poets are the registration windows of the configured poets.
+type NodeAtxStatus struct {
+    NodeId      string            `json:"nodeId"`
+    Epoch       uint32            `json:"epoch"`
+    TargetEpoch uint32            `json:"targetEpoch"`
+    Published   bool              `json:"published"`
+    Atx         *Atx              `json:"atx"`
+    Deadline    *Countdown        `json:"deadline"`
+    Poets       []*PoetCountdowns `json:"poets"`
+}
+
+// Units are the units of a response served with the smh profile. Amounts are integers
+// of smidge, each followed by the same amount in smesh as a decimal string under its key
+// with SmhSuffix. USD and fiat values are the value times FiatValueScale.
+type Units struct {
+    Amounts        string `json:"amounts"`
+    SmhSuffix      string `json:"smhSuffix"`
+    SmidgePerSmh   uint64 `json:"smidgePerSmh"`
+    FiatValueScale uint64 `json:"fiatValueScale"`
+}
+
+// LayerTime is when a layer runs, from Start included to End excluded in unix seconds,
+// computed from the genesis time and the layer duration.
+type LayerTime struct {
+    Layer          uint64 `json:"layer"`
+    Epoch          uint32 `json:"epoch"`
+    Start          int64  `json:"start"`
+    StartTime      string `json:"startTime"`
+    End            int64  `json:"end"`
+    EndTime        string `json:"endTime"`
+    EpochStartTime string `json:"epochStartTime"`
+}
+
+// PoetHealth is the last probe of a poet info endpoint, only set for poets with an
+// address while the health checker runs.
+type PoetHealth struct {
+    Up            bool   `json:"up"`
+    CurrentRound  string `json:"currentRound"`
+    Error         string `json:"error,omitempty"`
+    Since         int64  `json:"since"`
+    SinceTime     string `json:"sinceTime"`
+    CheckedAt     int64  `json:"checkedAt"`
+    CheckedAtTime string `json:"checkedAtTime"`
+}
+
+// PoetRound places a poet in its cycle. A round starts phaseShift hours into an epoch
+// and runs until cycleGap hours before the same point of the next epoch, smeshers then
+// build their proofs and register for the next round until it starts. Atxs built on the
+// current round are published in PublishEpoch, the open or next registration is for
+// PublishEpoch + 1.
+type PoetRound struct {
+    Phase                    string `json:"phase"`
+    PublishEpoch             uint32 `json:"publishEpoch"`
+    RoundStart               int64  `json:"roundStart"`
+    RoundStartTime           string `json:"roundStartTime"`
+    RoundEnd                 int64  `json:"roundEnd"`
+    RoundEndTime             string `json:"roundEndTime"`
+    RegistrationOpens        int64  `json:"registrationOpens"`
+    RegistrationOpensTime    string `json:"registrationOpensTime"`
+    RegistrationCloses       int64  `json:"registrationCloses"`
+    RegistrationClosesTime   string `json:"registrationClosesTime"`
+    SecondsToRegistration    int64  `json:"secondsToRegistration"`
+    SecondsToRegistrationEnd int64  `json:"secondsToRegistrationEnd"`
+}
+
+// Webhook is a registered webhook, the secret signing its deliveries is only returned
+// when it is created.
+type Webhook struct {
+    ID        string   `json:"id"`
+    Url       string   `json:"url"`
+    Addresses []string `json:"addresses"`
+    Events    []string `json:"events"`
+    Secret    string   `json:"secret,omitempty"`
+    CreatedAt int64    `json:"createdAt"`
+}
+
+// Watchlist is what the api key of the caller watches.
+type Watchlist struct {
+    Addresses []string `json:"addresses"`
+    NodeIds   []string `json:"nodeIds"`
+}
+
+// WatchlistSummary is the state of everything watched, Epoch is the current epoch and
+// the eligibility is for the next one, from the atxs published so far.
+type WatchlistSummary struct {
+    Epoch     uint32              `json:"epoch"`
+    Addresses []*WatchlistAccount `json:"addresses"`
+    Nodes     []*WatchlistNode    `json:"nodes"`
+}
+
+// WatchlistAccount is a watched address, PendingAtxs are the atxs with it as coinbase
+// published in the current epoch.
+type WatchlistAccount struct {
+    Address       string            `json:"address"`
+    Balance       uint64            `json:"balance"`
+    USDValue      int64             `json:"usdValue"`
+    PendingAtxs   []*Atx            `json:"pendingAtxs"`
+    NextEpoch     *EpochEligibility `json:"nextEpoch"`
+    RecentRewards []*Reward         `json:"recentRewards"`
+}
+
+// WatchlistNode is a watched node, PendingAtx is its atx published in the current epoch,
+// nil until it is published.
+type WatchlistNode struct {
+    NodeId        string            `json:"nodeId"`
+    PendingAtx    *Atx              `json:"pendingAtx"`
+    NextEpoch     *EpochEligibility `json:"nextEpoch"`
+    RecentRewards []*Reward         `json:"recentRewards"`
+}
+
+// EmailSubscription is an email address getting the digest of the watchlist.
+type EmailSubscription struct {
+    ID        string `json:"id"`
+    Email     string `json:"email"`
+    Frequency string `json:"frequency"`
+    CreatedAt int64  `json:"createdAt"`
+    // the address has not confirmed it yet
+    Pending   bool   `json:"pending"`
+}
+
+// Dashboard is the state of the nodes and coinbases of a node operator dashboard with
+// the network info, the next epoch eligibility is from the atxs published so far.
+type Dashboard struct {
+    Network   *NetworkInfo        `json:"network"`
+    Nodes     []*DashboardNode    `json:"nodes"`
+    Coinbases []*WatchlistAccount `json:"coinbases"`
+}
+
+// DashboardNode is a node of a dashboard, LastAtx is its latest atx and EffectiveNumUnits
+// its units, PendingAtx is its atx published in the current epoch, nil until it is
+// published. Eligibility is for the current epoch.
+type DashboardNode struct {
+    NodeId            string            `json:"nodeId"`
+    LastAtx           *Atx              `json:"lastAtx"`
+    PendingAtx        *Atx              `json:"pendingAtx"`
+    EffectiveNumUnits uint32            `json:"effectiveNumUnits"`
+    Eligibility       *EpochEligibility `json:"eligibility"`
+    NextEpoch         *EpochEligibility `json:"nextEpoch"`
+    RecentRewards     []*Reward         `json:"recentRewards"`
+}
+
+// WebhookDelivery is the delivery status of an event sent to a webhook, NextAttempt is
+// set while it is pending.
+type WebhookDelivery struct {
+    ID          string `json:"id"`
+    Event       string `json:"event"`
+    Status      string `json:"status"`
+    Attempts    int    `json:"attempts"`
+    NextAttempt int64  `json:"nextAttempt,omitempty"`
+    LastStatus  int    `json:"lastStatus,omitempty"`
+    LastError   string `json:"lastError,omitempty"`
+    CreatedAt   int64  `json:"createdAt"`
+    DeliveredAt int64  `json:"deliveredAt,omitempty"`
+}
+
+// WebhookEvent is the body posted to a webhook, Reward, Transaction or Digest is set by
+// the event. Address is the registered address the event is sent for.
+type WebhookEvent struct {
+    ID          string              `json:"id"`
+    Event       string              `json:"event"`
+    Webhook     string              `json:"webhook"`
+    Address     string              `json:"address"`
+    CreatedAt   int64               `json:"createdAt"`
+    Reward      *WebhookReward      `json:"reward,omitempty"`
+    Transaction *WebhookTransaction `json:"transaction,omitempty"`
+    Digest      *CoinbaseDigest     `json:"digest,omitempty"`
+}
+
+// CoinbaseDigest sums the rewards of a coinbase in a UTC day, Day is the unix time of its
+// start. USDValue is the rewards at USDPrice, the last price recorded in the day, both -1
+// when no price was. Complete is false while layers of the day are still processed.
+type CoinbaseDigest struct {
+    Coinbase  string  `json:"coinbase"`
+    Day       int64   `json:"day"`
+    Date      string  `json:"date"`
+    Count     int64   `json:"count"`
+    Rewards   int64   `json:"rewards"`
+    USDPrice  float64 `json:"usdPrice"`
+    USDValue  int64   `json:"usdValue"`
+    FiatValue int64   `json:"fiatValue,omitempty"`
+    Currency  string  `json:"currency,omitempty"`
+    Complete  bool    `json:"complete"`
+}
+
+type WebhookReward struct {
+    ID          string `json:"id"`
+    Coinbase    string `json:"coinbase"`
+    NodeId      string `json:"nodeId"`
+    AtxId       string `json:"atxId"`
+    Layer       uint32 `json:"layer"`
+    Total       uint64 `json:"total"`
+    LayerReward uint64 `json:"layerReward"`
+    Timestamp   int64  `json:"timestamp"`
+}
+
+// WebhookTransaction is an applied or failed transaction, the receiver, vault, amount
+// and fee are empty when the transaction could not be parsed.
+type WebhookTransaction struct {
+    ID               string   `json:"id"`
+    Status           uint8    `json:"status"`
+    Message          string   `json:"message,omitempty"`
+    PrincipalAccount string   `json:"principalAccount"`
+    ReceiverAccount  string   `json:"receiverAccount,omitempty"`
+    VaultAccount     string   `json:"vaultAccount,omitempty"`
+    Amount           uint64   `json:"amount"`
+    Fee              uint64   `json:"fee"`
+    Type             uint8    `json:"type"`
+    Addresses        []string `json:"addresses"`
+    Layer            uint32   `json:"layer"`
+    Timestamp        int64    `json:"timestamp"`
+}
+
+// PoolPayoutMember is what a member of the pool earned in an epoch, the rewards of its
+// nodes, and what it is paid, Share percent of the rewards of all the members.
+type PoolPayoutMember struct {
+    Epoch   uint32   `json:"epoch"`
+    Name    string   `json:"name"`
+    NodeIds []string `json:"nodeIds"`
+    Share   float64  `json:"share"`
+    Rewards int64    `json:"rewards"`
+    Earned  int64    `json:"earned"`
+    Payout  int64    `json:"payout"`
+}
+
+// PoolPayoutReport is the payout of the members of the pool for an epoch, OperatorFee is
+// what is left of TotalRewards once the members are paid. StartTime and EndTime are the
+// start and end of the epoch.
+type PoolPayoutReport struct {
+    Epoch        uint32              `json:"epoch"`
+    FirstLayer   uint32              `json:"firstLayer"`
+    LastLayer    uint32              `json:"lastLayer"`
+    StartTime    string              `json:"startTime"`
+    EndTime      string              `json:"endTime"`
+    Rewards      int64               `json:"rewards"`
+    TotalRewards int64               `json:"totalRewards"`
+    Payouts      int64               `json:"payouts"`
+    OperatorFee  int64               `json:"operatorFee"`
+    Members      []*PoolPayoutMember `json:"members"`
+}
+
+// PoolPayoutExport is a row of the payouts export, one per member and epoch. NodeIds are
+// separated by spaces.
+type PoolPayoutExport struct {
+    Epoch     uint32  `json:"epoch"`
+    Member    string  `json:"member"`
+    NodeIds   string  `json:"node_ids"`
+    Share     float64 `json:"share"`
+    Rewards   int64   `json:"rewards"`
+    EarnedSMH string  `json:"earned_smh"`
+    PayoutSMH string  `json:"payout_smh"`
 }
@@ -0,0 +1,83 @@
+package verify
+
+import (
+	"database/sql"
+	"fmt"
+
+	sTypes "github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/swarmbit/spacemesh-state-api/types"
+	_ "modernc.org/sqlite"
+)
+
+// StateDB reads the state database of a go-spacemesh node, state.sql in its data
+// directory. It is opened read only, the node can keep running but should be synced past
+// the verified layers.
+type StateDB struct {
+	db *sql.DB
+}
+
+func OpenStateDB(path string) (*StateDB, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?mode=ro&_pragma=busy_timeout(10000)&_pragma=query_only(1)")
+	if err != nil {
+		return nil, err
+	}
+	stateDB := &StateDB{db: db}
+	if _, err := stateDB.LastAppliedLayer(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to read node state %s: %w", path, err)
+	}
+	return stateDB, nil
+}
+
+func (s *StateDB) Close() {
+	s.db.Close()
+}
+
+// LastAppliedLayer is the last layer the node applied a block or an empty layer to the
+// state.
+func (s *StateDB) LastAppliedLayer() (uint32, error) {
+	var layer uint32
+	err := s.db.QueryRow(`SELECT COALESCE(MAX(id), 0) FROM layers WHERE applied_block IS NOT NULL`).Scan(&layer)
+	return layer, err
+}
+
+// LayersRewards returns the rewards count and total of the layers from and to included
+// that have rewards, by layer.
+func (s *StateDB) LayersRewards(from uint32, to uint32) (map[uint32]*types.LayerRewardsDoc, error) {
+	rows, err := s.db.Query(`SELECT layer, COUNT(*), COALESCE(SUM(total_reward), 0) FROM rewards WHERE layer >= ? AND layer <= ? GROUP BY layer`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	layers := make(map[uint32]*types.LayerRewardsDoc)
+	for rows.Next() {
+		doc := &types.LayerRewardsDoc{}
+		if err := rows.Scan(&doc.Layer, &doc.Count, &doc.TotalReward); err != nil {
+			return nil, err
+		}
+		layers[doc.Layer] = doc
+	}
+	return layers, rows.Err()
+}
+
+// CountAtxs returns the number of atxs published in epoch.
+func (s *StateDB) CountAtxs(epoch uint32) (int64, error) {
+	var count int64
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM atxs WHERE epoch = ?`, epoch).Scan(&count)
+	return count, err
+}
+
+// Balance returns the balance of the account after layer, 0 when the account had no
+// balance yet.
+func (s *StateDB) Balance(account string, layer uint32) (uint64, error) {
+	address, err := sTypes.StringToAddress(account)
+	if err != nil {
+		return 0, fmt.Errorf("invalid account %s: %w", account, err)
+	}
+	var balance uint64
+	err = s.db.QueryRow(`SELECT balance FROM accounts WHERE address = ? AND layer_updated <= ? ORDER BY layer_updated DESC LIMIT 1`, address.Bytes(), layer).Scan(&balance)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return balance, err
+}
@@ -0,0 +1,190 @@
+package verify
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+// Checks of a divergence.
+const (
+	CheckRewardsCount = "rewards count"
+	CheckRewardsTotal = "rewards total"
+	CheckAtxsCount    = "atxs count"
+	CheckBalance      = "balance"
+)
+
+// Divergence is a value the connector and the node disagree on. Key is the layer, the
+// epoch or the account the value belongs to.
+type Divergence struct {
+	Check     string `json:"check"`
+	Key       string `json:"key"`
+	Connector int64  `json:"connector"`
+	Node      int64  `json:"node"`
+}
+
+// Report lists the divergences found between the layers From and To included. Epochs
+// are the publish epochs whose atxs were counted and Accounts the sampled balances.
+type Report struct {
+	From        uint32        `json:"from"`
+	To          uint32        `json:"to"`
+	Layers      int           `json:"layers"`
+	Epochs      int           `json:"epochs"`
+	Accounts    int           `json:"accounts"`
+	Divergences []*Divergence `json:"divergences"`
+}
+
+func (r *Report) add(check string, key string, connector int64, node int64) {
+	r.Divergences = append(r.Divergences, &Divergence{Check: check, Key: key, Connector: connector, Node: node})
+}
+
+// Run cross checks the connector store against the state of a node for the layers from
+// and to included: the rewards count and total of every layer, the atxs count of every
+// publish epoch that ended in the range and the balances after to of sample accounts
+// chosen at random. When to is 0 it is the last layer both processed, when from is 0 the
+// range is the epoch before to. Rewards pruned by retention are reported as divergences.
+func Run(readDB database.ReadStore, stateDB *StateDB, from uint32, to uint32, sample int) (*Report, error) {
+	last, err := readDB.GetLastProcessedLayer()
+	if err != nil {
+		return nil, err
+	}
+	nodeLast, err := stateDB.LastAppliedLayer()
+	if err != nil {
+		return nil, err
+	}
+	// the last processed layer may still be receiving its rewards
+	connectorLast := uint32(max(last.Layer-1, 0))
+	if to == 0 {
+		to = min(connectorLast, nodeLast)
+	}
+	if to > connectorLast {
+		return nil, fmt.Errorf("the connector processed up to layer %d", connectorLast)
+	}
+	if to > nodeLast {
+		return nil, fmt.Errorf("the node applied up to layer %d", nodeLast)
+	}
+	if from == 0 && to >= config.LayersPerEpoch {
+		from = to - config.LayersPerEpoch + 1
+	}
+	if from > to {
+		return nil, fmt.Errorf("from layer %d is after to layer %d", from, to)
+	}
+
+	report := &Report{From: from, To: to, Layers: int(to-from) + 1, Divergences: make([]*Divergence, 0)}
+	if err := verifyRewards(report, readDB, stateDB); err != nil {
+		return nil, fmt.Errorf("failed to verify rewards: %w", err)
+	}
+	if err := verifyAtxs(report, readDB, stateDB); err != nil {
+		return nil, fmt.Errorf("failed to verify atxs: %w", err)
+	}
+	if err := verifyBalances(report, readDB, stateDB, sample); err != nil {
+		return nil, fmt.Errorf("failed to verify balances: %w", err)
+	}
+	return report, nil
+}
+
+func verifyRewards(report *Report, readDB database.ReadStore, stateDB *StateDB) error {
+	layers, err := readDB.GetLayersRewards(report.From, report.To)
+	if err != nil {
+		return err
+	}
+	nodeLayers, err := stateDB.LayersRewards(report.From, report.To)
+	if err != nil {
+		return err
+	}
+	connectorLayers := make(map[uint32]*types.LayerRewardsDoc, len(layers))
+	for _, v := range layers {
+		connectorLayers[v.Layer] = v
+	}
+	empty := &types.LayerRewardsDoc{}
+	for layer := report.From; layer <= report.To; layer++ {
+		connector, node := connectorLayers[layer], nodeLayers[layer]
+		if connector == nil {
+			connector = empty
+		}
+		if node == nil {
+			node = empty
+		}
+		key := fmt.Sprintf("layer %d", layer)
+		if connector.Count != node.Count {
+			report.add(CheckRewardsCount, key, connector.Count, node.Count)
+		}
+		if connector.TotalReward != node.TotalReward {
+			report.add(CheckRewardsTotal, key, connector.TotalReward, node.TotalReward)
+		}
+	}
+	log.Printf("Verified the rewards of %d layers", report.Layers)
+	return nil
+}
+
+// verifyAtxs counts the atxs of the publish epochs that ended in the range, the atxs of
+// an open epoch are still arriving.
+func verifyAtxs(report *Report, readDB database.ReadStore, stateDB *StateDB) error {
+	for epoch := report.From / config.LayersPerEpoch; (epoch+1)*config.LayersPerEpoch-1 <= report.To; epoch++ {
+		totals, err := readDB.GetAtxEpoch(uint64(epoch))
+		if err != nil {
+			return err
+		}
+		count, err := stateDB.CountAtxs(epoch)
+		if err != nil {
+			return err
+		}
+		if int64(totals.TotalAtx) != count {
+			report.add(CheckAtxsCount, fmt.Sprintf("epoch %d", epoch), int64(totals.TotalAtx), count)
+		}
+		report.Epochs++
+	}
+	log.Printf("Verified the atxs of %d epochs", report.Epochs)
+	return nil
+}
+
+// verifyBalances compares the balances after the last layer of the range, the connector
+// one is the current balance without the balance changes after that layer. Changes are
+// only recorded since the sink writes them, summing them from zero misses the older ones.
+func verifyBalances(report *Report, readDB database.ReadStore, stateDB *StateDB, sample int) error {
+	if sample <= 0 {
+		return nil
+	}
+	// reservoir sample so every account is equally likely in one pass
+	accounts := make([]types.AccountDoc, 0, sample)
+	seen := 0
+	err := readDB.StreamAccounts(-1, func(account *types.AccountDoc) error {
+		seen++
+		if len(accounts) < sample {
+			accounts = append(accounts, *account)
+		} else if i := rand.Intn(seen); i < sample {
+			accounts[i] = *account
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, account := range accounts {
+		changes, err := readDB.GetBalanceChanges(account.Address)
+		if err != nil {
+			return err
+		}
+		balance := int64(account.Balance)
+		for _, change := range changes {
+			if change.Id.Layer > report.To {
+				balance -= change.Delta
+			}
+		}
+		nodeBalance, err := stateDB.Balance(account.Address, report.To)
+		if err != nil {
+			return err
+		}
+		if balance != int64(nodeBalance) {
+			report.add(CheckBalance, account.Address, balance, int64(nodeBalance))
+		}
+	}
+	report.Accounts = len(accounts)
+	log.Printf("Verified the balances of %d accounts", report.Accounts)
+	return nil
+}
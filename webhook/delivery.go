@@ -0,0 +1,142 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/swarmbit/spacemesh-state-api/metrics"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+const (
+	// due deliveries read per run
+	dispatchBatch = 100
+	// deliveries sent at once
+	dispatchWorkers = 8
+	// batches sent per run, the rest waits for the next run
+	dispatchBatches = 10
+)
+
+// Sign is the signature of body sent at timestamp with secret, receivers compute it
+// again to check a delivery came from the api and was not replayed later.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// dispatch sends the due deliveries, up to dispatchBatches batches.
+func (n *Notifier) dispatch() {
+	for batch := 0; batch < dispatchBatches; batch++ {
+		deliveries, err := n.writeDB.GetDueWebhookDeliveries(time.Now().Unix(), dispatchBatch)
+		if err != nil {
+			log.Printf("Failed to get due webhook deliveries: %v", err)
+			return
+		}
+		var wg sync.WaitGroup
+		workers := make(chan struct{}, dispatchWorkers)
+		for _, delivery := range deliveries {
+			wg.Add(1)
+			workers <- struct{}{}
+			go func() {
+				defer func() {
+					<-workers
+					wg.Done()
+				}()
+				n.deliver(delivery)
+			}()
+		}
+		wg.Wait()
+		if len(deliveries) < dispatchBatch {
+			return
+		}
+	}
+}
+
+// deliver posts the delivery and records the outcome, failed deliveries are tried again
+// after the backoff until the attempts run out.
+func (n *Notifier) deliver(delivery *types.WebhookDeliveryDoc) {
+	webhook := n.webhook(delivery.Webhook)
+	now := time.Now()
+	// the attempt is claimed before posting, a delivery another run or a new leader
+	// claimed is not posted again, and a crash while posting retries after the backoff
+	claimed, err := n.writeDB.ClaimWebhookDelivery(delivery.ID, delivery.Attempts, now.Add(n.delay(delivery.Attempts+1)).Unix())
+	if err != nil {
+		log.Printf("Failed to claim webhook delivery %s: %v", delivery.ID, err)
+		return
+	}
+	if !claimed {
+		return
+	}
+	delivery.Attempts++
+	if webhook == nil {
+		delivery.Status = types.WebhookDeliveryFailed
+		delivery.LastError = "webhook deleted"
+		metrics.WebhookDeliveries.WithLabelValues("failed").Inc()
+	} else if status, err := n.post(webhook, delivery, now); err != nil {
+		delivery.LastStatus = status
+		delivery.LastError = err.Error()
+		delivery.NextAttempt = now.Add(n.delay(delivery.Attempts)).Unix()
+		if delivery.Attempts >= n.maxAttempts {
+			delivery.Status = types.WebhookDeliveryFailed
+			delivery.NextAttempt = 0
+			metrics.WebhookDeliveries.WithLabelValues("failed").Inc()
+		} else {
+			metrics.WebhookDeliveries.WithLabelValues("retried").Inc()
+		}
+	} else {
+		metrics.WebhookDeliveries.WithLabelValues("delivered").Inc()
+		delivery.Status = types.WebhookDeliveryDelivered
+		delivery.LastStatus = status
+		delivery.LastError = ""
+		delivery.NextAttempt = 0
+		delivery.DeliveredAt = now.Unix()
+	}
+	if err := n.writeDB.UpdateWebhookDelivery(delivery); err != nil {
+		log.Printf("Failed to update webhook delivery %s: %v", delivery.ID, err)
+	}
+}
+
+func (n *Notifier) post(webhook *types.WebhookDoc, delivery *types.WebhookDeliveryDoc, now time.Time) (int, error) {
+	body := []byte(delivery.Payload)
+	request, err := http.NewRequest(http.MethodPost, webhook.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "spacemesh-state-api")
+	request.Header.Set("X-Webhook-Id", delivery.ID)
+	request.Header.Set("X-Webhook-Event", delivery.Event)
+	request.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(now.Unix(), 10))
+	request.Header.Set("X-Webhook-Signature", Sign(webhook.Secret, now.Unix(), body))
+	response, err := n.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return response.StatusCode, fmt.Errorf("receiver answered %s", response.Status)
+	}
+	return response.StatusCode, nil
+}
+
+// delay is the wait after the attempts failed, doubled on every attempt.
+func (n *Notifier) delay(attempts int) time.Duration {
+	delay := n.minDelay
+	for i := 1; i < attempts && delay < n.maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > n.maxDelay {
+		delay = n.maxDelay
+	}
+	return delay
+}
@@ -0,0 +1,217 @@
+package webhook
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/spacemeshos/go-spacemesh/nats"
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/pkg/transactionparser"
+	transactionparsertypes "github.com/swarmbit/spacemesh-state-api/pkg/transactionparser/transaction"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+// Notifier queues the rewards and transactions the sink saves, and the daily digests,
+// for the webhooks of their addresses and sends the due deliveries. The webhooks are
+// loaded every refresh, the sink matches them without a query per message, so a new
+// webhook gets events from the next refresh. It only runs with the writers, on the leader.
+type Notifier struct {
+	writeDB     database.WriteStore
+	readDB      database.ReadStore
+	client      *http.Client
+	maxAttempts int
+	minDelay    time.Duration
+	maxDelay    time.Duration
+
+	mu        sync.RWMutex
+	webhooks  map[string]*types.WebhookDoc
+	byAddress map[string][]*types.WebhookDoc
+}
+
+func NewNotifier(webhooksConfig *config.WebhooksConfig, writeDB database.WriteStore, readDB database.ReadStore) *Notifier {
+	n := &Notifier{
+		writeDB:     writeDB,
+		readDB:      readDB,
+		client:      &http.Client{Timeout: seconds(webhooksConfig.Timeout, 10), Transport: deliveryTransport()},
+		maxAttempts: 8,
+		minDelay:    seconds(webhooksConfig.MinDelay, 30),
+		maxDelay:    seconds(webhooksConfig.MaxDelay, 3600),
+	}
+	if webhooksConfig.MaxAttempts > 0 {
+		n.maxAttempts = webhooksConfig.MaxAttempts
+	}
+	n.refresh()
+	ticker := time.NewTicker(seconds(webhooksConfig.RefreshTime, 5))
+	go func() {
+		for range ticker.C {
+			// a fenced off leader leaves the deliveries to the new one
+			if n.writeDB.Fenced() {
+				continue
+			}
+			n.refresh()
+			n.dispatch()
+		}
+	}()
+	return n
+}
+
+// seconds is the configured seconds, or fallback seconds when not configured.
+func seconds(configured int, fallback int) time.Duration {
+	if configured > 0 {
+		return time.Duration(configured) * time.Second
+	}
+	return time.Duration(fallback) * time.Second
+}
+
+func (n *Notifier) refresh() {
+	webhooks, err := n.readDB.GetWebhooks("")
+	if err != nil {
+		log.Printf("Failed to load webhooks: %v", err)
+		return
+	}
+	byId := make(map[string]*types.WebhookDoc, len(webhooks))
+	byAddress := make(map[string][]*types.WebhookDoc)
+	for _, webhook := range webhooks {
+		byId[webhook.ID] = webhook
+		for _, address := range webhook.Addresses {
+			byAddress[address] = append(byAddress[address], webhook)
+		}
+	}
+	n.mu.Lock()
+	n.webhooks = byId
+	n.byAddress = byAddress
+	n.mu.Unlock()
+}
+
+func (n *Notifier) webhook(id string) *types.WebhookDoc {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.webhooks[id]
+}
+
+// subscribed returns the webhooks of address that receive event.
+func (n *Notifier) subscribed(address string, event string) []*types.WebhookDoc {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	subscribed := make([]*types.WebhookDoc, 0)
+	for _, webhook := range n.byAddress[address] {
+		if slices.Contains(webhook.Events, event) {
+			subscribed = append(subscribed, webhook)
+		}
+	}
+	return subscribed
+}
+
+// AddReward queues the reward for the webhooks of its coinbase.
+func (n *Notifier) AddReward(reward *nats.Reward) {
+	if n == nil {
+		return
+	}
+	payload := &types.WebhookReward{
+		ID:          reward.ID,
+		Coinbase:    reward.Coinbase,
+		NodeId:      reward.NodeID,
+		AtxId:       reward.AtxID,
+		Layer:       reward.Layer,
+		Total:       reward.Total,
+		LayerReward: reward.LayerReward,
+		Timestamp:   config.GenesisEpochSeconds + int64(reward.Layer)*config.LayerDuration,
+	}
+	for _, webhook := range n.subscribed(reward.Coinbase, types.WebhookEventReward) {
+		n.queue(webhook, reward.Coinbase, reward.ID, &types.WebhookEvent{Reward: payload})
+	}
+}
+
+// AddTransaction queues the transaction result for the webhooks of every address it
+// touches, once per webhook.
+func (n *Notifier) AddTransaction(transaction *nats.Transaction) {
+	if n == nil || transaction.Header == nil {
+		return
+	}
+	var payload *types.WebhookTransaction
+	queued := make(map[string]bool)
+	for _, address := range transaction.Header.Addresses {
+		for _, webhook := range n.subscribed(address, types.WebhookEventTransaction) {
+			if queued[webhook.ID] {
+				continue
+			}
+			queued[webhook.ID] = true
+			if payload == nil {
+				payload = webhookTransaction(transaction)
+			}
+			n.queue(webhook, address, transaction.ID, &types.WebhookEvent{Transaction: payload})
+		}
+	}
+}
+
+// AddDigest queues the daily digest for the webhooks of its coinbase.
+func (n *Notifier) AddDigest(digest *types.RewardDigestDoc) {
+	if n == nil {
+		return
+	}
+	for _, webhook := range n.subscribed(digest.Id.Coinbase, types.WebhookEventDigest) {
+		n.queue(webhook, digest.Id.Coinbase, digest.Id.Coinbase+"-"+strconv.FormatInt(digest.Id.Day, 10), &types.WebhookEvent{Digest: digest.Digest()})
+	}
+}
+
+func webhookTransaction(transaction *nats.Transaction) *types.WebhookTransaction {
+	payload := &types.WebhookTransaction{
+		ID:               transaction.ID,
+		Status:           transaction.Header.Status,
+		Message:          transaction.Header.Message,
+		PrincipalAccount: transaction.Header.Principal,
+		Addresses:        transaction.Header.Addresses,
+		Layer:            transaction.Header.LayerID,
+		Timestamp:        config.GenesisEpochSeconds + int64(transaction.Header.LayerID)*config.LayerDuration,
+	}
+	transactionData, err := transactionparser.Parse(transaction.Raw)
+	if err != nil {
+		return payload
+	}
+	if receiver := transactionData.Tx.GetReceiver(); len(receiver.Bytes()) > 0 {
+		payload.ReceiverAccount = receiver.String()
+	}
+	if transactionData.Type == transactionparsertypes.TypeDrainVault {
+		payload.VaultAccount = transactionData.Vault.GetVault().String()
+	}
+	payload.Type = uint8(transactionData.Type)
+	payload.Amount = transactionData.Tx.GetAmount()
+	payload.Fee = transaction.Header.Gas * transactionData.Tx.GetGasPrice()
+	return payload
+}
+
+func (n *Notifier) queue(webhook *types.WebhookDoc, address string, entityId string, event *types.WebhookEvent) {
+	event.Event = types.WebhookEventReward
+	if event.Transaction != nil {
+		event.Event = types.WebhookEventTransaction
+	} else if event.Digest != nil {
+		event.Event = types.WebhookEventDigest
+	}
+	event.ID = webhook.ID + "-" + event.Event + "-" + entityId
+	event.Webhook = webhook.ID
+	event.Address = address
+	event.CreatedAt = time.Now().Unix()
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Failed to encode webhook event %s: %v", event.ID, err)
+		return
+	}
+	err = n.writeDB.QueueWebhookDelivery(&types.WebhookDeliveryDoc{
+		ID:          event.ID,
+		Webhook:     webhook.ID,
+		Event:       event.Event,
+		Payload:     string(payload),
+		Status:      types.WebhookDeliveryPending,
+		NextAttempt: event.CreatedAt,
+		CreatedAt:   event.CreatedAt,
+	})
+	if err != nil {
+		log.Printf("Failed to queue webhook event %s: %v", event.ID, err)
+	}
+}
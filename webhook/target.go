@@ -0,0 +1,69 @@
+package webhook
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+
+	"github.com/swarmbit/spacemesh-state-api/pkg/apperror"
+)
+
+// ErrPrivateTarget is returned for webhook urls that reach a loopback, private or link
+// local address, deliveries must not reach the network the server runs in.
+var ErrPrivateTarget error = apperror.New(apperror.InvalidInput, "url must not resolve to a loopback, private or link local address")
+
+// sharedAddressSpace is the carrier grade nat range, private although netip does not
+// report it as such.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+func publicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() && !sharedAddressSpace.Contains(addr)
+}
+
+// CheckTarget resolves the host of a webhook url and fails when one of its addresses is
+// not public.
+func CheckTarget(ctx context.Context, target *url.URL) error {
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", target.Hostname())
+	if err != nil {
+		return apperror.Wrap(apperror.InvalidInput, "url host can not be resolved", err)
+	}
+	for _, addr := range addrs {
+		if !publicAddr(addr) {
+			return ErrPrivateTarget
+		}
+	}
+	return nil
+}
+
+// dialPublic refuses connections to addresses that are not public once the host was
+// resolved, a host resolving to another address after its webhook was checked and
+// redirects are caught there.
+func dialPublic(network string, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !publicAddr(addrPort.Addr()) {
+		return ErrPrivateTarget
+	}
+	return nil
+}
+
+// deliveryTransport dials only public addresses. It does not use a proxy, the proxy
+// would be dialed and checked instead of the webhook.
+func deliveryTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   dialPublic,
+	}
+	transport.DialContext = dialer.DialContext
+	return transport
+}